@@ -13,6 +13,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/couchbase/sync_gateway/base"
@@ -38,6 +39,8 @@ type AuthenticatorOptions struct {
 	SessionCookieName          string
 	BcryptCost                 int
 	LogCtx                     context.Context
+	GuestChannelAllowlist      base.Set // If non-nil, the guest user can only see these channels, regardless of their grants
+	SystemChannels             base.Set // Channels that aren't included in a grant of the star channel, and can only be seen with an explicit grant
 
 	// Collections defines the set of collections used by the authenticator when rebuilding channels.
 	// Channels are only recomputed for collections included in this set.
@@ -46,6 +49,8 @@ type AuthenticatorOptions struct {
 	Collections map[string]map[string]struct{}
 	// MetaKeys generates key formats used to persist auth users, roles and sessions
 	MetaKeys *base.MetadataKeys
+	// SessionCookieEncryption, if set, encrypts sessions into their cookies rather than using the session ID
+	SessionCookieEncryption *SessionCookieEncryption
 }
 
 // Interface for deriving the set of channels and roles a User/Role has access to.
@@ -58,6 +63,16 @@ type ChannelComputer interface {
 	ComputeRolesForUser(ctx context.Context, u User) (ch.TimedSet, error)
 }
 
+// AccessExpiryHandler is optionally implemented by a ChannelComputer that supports channel grants with an expiry.
+type AccessExpiryHandler interface {
+	// AccessExpirySequence returns the sequence at which expired channel grants are revoked
+	AccessExpirySequence(ctx context.Context) (uint64, error)
+
+	// ScheduleAccessExpiry requests that the principal's channels for scope.collection are invalidated once expiry
+	// (unix time in seconds) has passed, so that the expired grants are revoked
+	ScheduleAccessExpiry(ctx context.Context, name string, isUser bool, scope string, collection string, expiry uint32)
+}
+
 type userByEmailInfo struct {
 	Username string
 }
@@ -138,6 +153,9 @@ func (auth *Authenticator) GetUser(name string) (User, error) {
 		}
 	}
 	princ.(*userImpl).auth = auth
+	if name == "" {
+		princ.(*userImpl).channelAllowlist = auth.GuestChannelAllowlist
+	}
 	return princ.(User), err
 }
 
@@ -312,6 +330,9 @@ func (auth *Authenticator) rebuildChannels(princ Principal) (changed bool, err e
 
 	for scope, collections := range auth.Collections {
 		for collection, _ := range collections {
+			if err := auth.invalidateExpiredChannels(princ, scope, collection); err != nil {
+				return changed, err
+			}
 			// If collection channels are nil, they have been invalidated and must be rebuilt
 			if princ.CollectionChannels(scope, collection) == nil {
 				err := auth.rebuildCollectionChannels(princ, scope, collection)
@@ -320,12 +341,46 @@ func (auth *Authenticator) rebuildChannels(princ Principal) (changed bool, err e
 				}
 				changed = true
 			}
+			auth.scheduleChannelExpiry(princ, scope, collection)
 		}
 	}
 
 	return changed, nil
 }
 
+// invalidateExpiredChannels invalidates the principal's channels for scope.collection if any of them were granted with
+// an expiry that has passed, so that they are rebuilt without the expired grants.
+func (auth *Authenticator) invalidateExpiredChannels(princ Principal, scope, collection string) error {
+	expiryHandler, ok := auth.channelComputer.(AccessExpiryHandler)
+	if !ok {
+		return nil
+	}
+	expiry, ok := princ.CollectionChannels(scope, collection).NextExpiry()
+	if !ok || int64(expiry) > time.Now().Unix() {
+		return nil
+	}
+	invalSeq, err := expiryHandler.AccessExpirySequence(auth.LogCtx)
+	if err != nil {
+		return err
+	}
+	base.InfofCtx(auth.LogCtx, base.KeyAccess, "Channel access of %q (%s.%s) has expired", base.UD(princ.Name()), base.MD(scope), base.MD(collection))
+	princ.setCollectionChannelInvalSeq(scope, collection, invalSeq)
+	return nil
+}
+
+// scheduleChannelExpiry requests the principal's channels for scope.collection are invalidated when the earliest of
+// its time-boxed channel grants expires.
+func (auth *Authenticator) scheduleChannelExpiry(princ Principal, scope, collection string) {
+	expiryHandler, ok := auth.channelComputer.(AccessExpiryHandler)
+	if !ok {
+		return
+	}
+	if expiry, ok := princ.CollectionChannels(scope, collection).NextExpiry(); ok {
+		_, isUser := princ.(User)
+		expiryHandler.ScheduleAccessExpiry(auth.LogCtx, princ.Name(), isUser, scope, collection, expiry)
+	}
+}
+
 func (auth *Authenticator) rebuildCollectionChannels(princ Principal, scope, collection string) error {
 
 	// For the default collection, rebuild the top-level channels properties on the principal.  Otherwise rebuild the appropriate entry
@@ -802,22 +857,7 @@ func (auth *Authenticator) AuthenticateUntrustedJWT(rawToken string, oidcProvide
 		authenticator = single
 	}
 	if authenticator == nil {
-		for _, provider := range oidcProviders {
-			if provider.ValidFor(auth.LogCtx, issuer, audiences) {
-				base.TracefCtx(auth.LogCtx, base.KeyAuth, "Using OIDC provider %v", base.UD(provider.Issuer))
-				authenticator = provider
-				break
-			}
-		}
-	}
-	if authenticator == nil {
-		for _, provider := range localJWT {
-			if provider.ValidFor(auth.LogCtx, issuer, audiences) {
-				base.TracefCtx(auth.LogCtx, base.KeyAuth, "Using local JWT provider %v", base.UD(provider.Issuer))
-				authenticator = provider
-				break
-			}
-		}
+		authenticator = auth.findJWTAuthenticator(issuer, audiences, oidcProviders, localJWT)
 	}
 	if authenticator == nil {
 		base.DebugfCtx(auth.LogCtx, base.KeyAuth, "No matching JWT/OIDC provider for issuer %v and audiences %v", base.UD(issuer), base.UD(audiences))
@@ -835,6 +875,42 @@ func (auth *Authenticator) AuthenticateUntrustedJWT(rawToken string, oidcProvide
 	return user, updates, err
 }
 
+// findJWTAuthenticator returns the provider that should verify a token with the given issuer and audiences, or nil if
+// there's no matching provider. Multiple providers can share an issuer (e.g. to federate several client IDs from one
+// identity provider), so providers whose client ID is one of the audiences are preferred over providers with audience
+// checking disabled. OIDC providers are preferred over local JWT providers, and otherwise providers are checked in
+// name order so that the same provider is always selected.
+func (auth *Authenticator) findJWTAuthenticator(issuer string, audiences []string, oidcProviders OIDCProviderMap, localJWT LocalJWTProviderMap) jwtAuthenticator {
+	oidcNames := make([]string, 0, len(oidcProviders))
+	for name := range oidcProviders {
+		oidcNames = append(oidcNames, name)
+	}
+	sort.Strings(oidcNames)
+	localNames := make([]string, 0, len(localJWT))
+	for name := range localJWT {
+		localNames = append(localNames, name)
+	}
+	sort.Strings(localNames)
+
+	for _, exactAudience := range []bool{true, false} {
+		for _, name := range oidcNames {
+			provider := oidcProviders[name]
+			if (exactAudience && provider.matchesAudience(issuer, audiences)) || (!exactAudience && provider.ValidFor(auth.LogCtx, issuer, audiences)) {
+				base.TracefCtx(auth.LogCtx, base.KeyAuth, "Using OIDC provider %v (%v)", base.UD(name), base.UD(provider.Issuer))
+				return provider
+			}
+		}
+		for _, name := range localNames {
+			provider := localJWT[name]
+			if (exactAudience && provider.matchesAudience(issuer, audiences)) || (!exactAudience && provider.ValidFor(auth.LogCtx, issuer, audiences)) {
+				base.TracefCtx(auth.LogCtx, base.KeyAuth, "Using local JWT provider %v (%v)", base.UD(name), base.UD(provider.Issuer))
+				return provider
+			}
+		}
+	}
+	return nil
+}
+
 // Authenticates a user based on a JWT token obtained directly from a provider (auth code flow, refresh flow).
 // Verifies the token claims, but doesn't require signature verification if allow_unsigned_provider_tokens is enabled.
 // If the token is validated but the user for the username defined in the subject claim doesn't exist,
@@ -895,6 +971,13 @@ func (auth *Authenticator) authenticateJWTIdentity(identity *Identity, provider
 	} else {
 		jwtChannels = base.Set{}
 	}
+	if len(provider.ChannelsClaimMappings) > 0 {
+		mappedChannels, err := getJWTClaimMappedChannels(auth.LogCtx, identity, provider.ChannelsClaimMappings)
+		if err != nil {
+			return nil, PrincipalConfig{}, time.Time{}, fmt.Errorf("failed to map JWT claims to channels: %w", err)
+		}
+		jwtChannels = jwtChannels.Union(mappedChannels)
+	}
 
 	user, err = auth.GetUser(username)
 	if err != nil {
@@ -56,6 +56,9 @@ type JWTConfigCommon struct {
 	// sync function). If the claim is absent from the access/ID token, no roles/channels will be added.
 	RolesClaim    string `json:"roles_claim"`
 	ChannelsClaim string `json:"channels_claim"`
+
+	// ChannelsClaimMappings derive channels from claim values using templates, in addition to ChannelsClaim.
+	ChannelsClaimMappings []ClaimChannelMapping `json:"channels_claim_mappings,omitempty"`
 }
 
 // ValidFor returns whether the issuer matches, and one of the audiences matches
@@ -79,6 +82,20 @@ func (j JWTConfigCommon) ValidFor(ctx context.Context, issuer string, audiences
 	return false
 }
 
+// matchesAudience returns whether the issuer matches, and the client ID is one of the audiences. Unlike ValidFor, a
+// provider with audience checking disabled never matches.
+func (j JWTConfigCommon) matchesAudience(issuer string, audiences audience) bool {
+	if j.Issuer != issuer || j.ClientID == nil || *j.ClientID == "" {
+		return false
+	}
+	for _, aud := range audiences {
+		if aud == *j.ClientID {
+			return true
+		}
+	}
+	return false
+}
+
 var ErrNoMatchingProvider = errors.New("no matching OIDC/JWT provider")
 
 type (
@@ -0,0 +1,141 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/couchbase/sync_gateway/base"
+	ch "github.com/couchbase/sync_gateway/channels"
+)
+
+const (
+	claimPathSeparator    = "."
+	claimPathExpandArray  = "[*]"
+	claimValuePlaceholder = "{}"
+)
+
+// ClaimChannelMapping is a rule deriving channels from a JWT claim. Claim is a dot-separated path to the claim, where
+// an element suffixed with [*] expands each element of an array, e.g. `groups[*]` or `orgs[*].id`. Channel is a
+// template for the channel name, where {} is replaced with each value found at the claim path, e.g. `grp-{}`.
+type ClaimChannelMapping struct {
+	Claim   string `json:"claim"`
+	Channel string `json:"channel"`
+}
+
+// claimPathElement is a single element of a parsed claim path.
+type claimPathElement struct {
+	name   string
+	expand bool // Whether the value of the element is an array to be expanded
+}
+
+// Validate returns an error if the claim path can't be parsed, or the channel template is empty.
+func (m ClaimChannelMapping) Validate() error {
+	if _, err := parseClaimPath(m.Claim); err != nil {
+		return err
+	}
+	if m.Channel == "" {
+		return fmt.Errorf("channel template required for claim %q", m.Claim)
+	}
+	return nil
+}
+
+// parseClaimPath splits a claim path into its elements.
+func parseClaimPath(path string) ([]claimPathElement, error) {
+	if path == "" {
+		return nil, errors.New("claim path required")
+	}
+	parts := strings.Split(path, claimPathSeparator)
+	elements := make([]claimPathElement, 0, len(parts))
+	for _, part := range parts {
+		element := claimPathElement{name: part}
+		if strings.HasSuffix(part, claimPathExpandArray) {
+			element.name = strings.TrimSuffix(part, claimPathExpandArray)
+			element.expand = true
+		}
+		if element.name == "" || strings.ContainsAny(element.name, "[]") {
+			return nil, fmt.Errorf("invalid claim path %q", path)
+		}
+		elements = append(elements, element)
+	}
+	return elements, nil
+}
+
+// resolveClaimPath returns the values found at the given path in claims. Missing claims resolve to no values.
+func resolveClaimPath(claims map[string]interface{}, path []claimPathElement) []interface{} {
+	value, ok := claims[path[0].name]
+	if !ok {
+		return nil
+	}
+	var values []interface{}
+	if path[0].expand {
+		array, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+		values = array
+	} else {
+		values = []interface{}{value}
+	}
+
+	if len(path) == 1 {
+		return values
+	}
+	var results []interface{}
+	for _, v := range values {
+		if nested, ok := v.(map[string]interface{}); ok {
+			results = append(results, resolveClaimPath(nested, path[1:])...)
+		}
+	}
+	return results
+}
+
+// claimValueToString converts a scalar claim value to a string, returning false for other types.
+func claimValueToString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		return "", false
+	}
+}
+
+// getJWTClaimMappedChannels applies the mapping rules to the identity's claims, returning the resulting channels.
+// Values that aren't scalars, or that produce an invalid channel name, are skipped.
+func getJWTClaimMappedChannels(ctx context.Context, identity *Identity, mappings []ClaimChannelMapping) (base.Set, error) {
+	channels := base.Set{}
+	for _, mapping := range mappings {
+		path, err := parseClaimPath(mapping.Claim)
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range resolveClaimPath(identity.Claims, path) {
+			str, ok := claimValueToString(value)
+			if !ok {
+				base.DebugfCtx(ctx, base.KeyAuth, "Skipping non-scalar value of claim %q for channel mapping", base.UD(mapping.Claim))
+				continue
+			}
+			channel := strings.ReplaceAll(mapping.Channel, claimValuePlaceholder, str)
+			if !ch.IsValidChannel(channel) || channel == ch.AllChannelWildcard {
+				base.InfofCtx(ctx, base.KeyAuth, "Skipping invalid channel name %q mapped from claim %q", base.UD(channel), base.UD(mapping.Claim))
+				continue
+			}
+			channels.Add(channel)
+		}
+	}
+	return channels, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimChannelMappingValidate(t *testing.T) {
+	assert.NoError(t, ClaimChannelMapping{Claim: "groups[*]", Channel: "grp-{}"}.Validate())
+	assert.NoError(t, ClaimChannelMapping{Claim: "orgs[*].id", Channel: "org-{}"}.Validate())
+	assert.NoError(t, ClaimChannelMapping{Claim: "admin", Channel: "admins"}.Validate())
+
+	assert.Error(t, ClaimChannelMapping{Claim: "", Channel: "grp-{}"}.Validate())
+	assert.Error(t, ClaimChannelMapping{Claim: "groups", Channel: ""}.Validate())
+	assert.Error(t, ClaimChannelMapping{Claim: "groups..id", Channel: "grp-{}"}.Validate())
+	assert.Error(t, ClaimChannelMapping{Claim: "groups[0]", Channel: "grp-{}"}.Validate())
+	assert.Error(t, ClaimChannelMapping{Claim: "[*]", Channel: "grp-{}"}.Validate())
+}
+
+func TestGetJWTClaimMappedChannels(t *testing.T) {
+	var claims map[string]interface{}
+	require.NoError(t, base.JSONUnmarshal([]byte(`{
+		"groups": ["eng", "ops", {"nested": true}, "a,b"],
+		"department": "sales",
+		"level": 3,
+		"orgs": [{"id": "acme"}, {"id": 42}, {"name": "missing id"}],
+		"wildcard": "*"
+	}`), &claims))
+	identity := &Identity{Claims: claims}
+
+	tests := []struct {
+		name     string
+		mappings []ClaimChannelMapping
+		expected base.Set
+	}{
+		{
+			name:     "array",
+			mappings: []ClaimChannelMapping{{Claim: "groups[*]", Channel: "grp-{}"}},
+			expected: base.SetOf("grp-eng", "grp-ops"),
+		},
+		{
+			name:     "scalars",
+			mappings: []ClaimChannelMapping{{Claim: "department", Channel: "dept-{}"}, {Claim: "level", Channel: "level-{}"}},
+			expected: base.SetOf("dept-sales", "level-3"),
+		},
+		{
+			name:     "array of objects",
+			mappings: []ClaimChannelMapping{{Claim: "orgs[*].id", Channel: "org-{}"}},
+			expected: base.SetOf("org-acme", "org-42"),
+		},
+		{
+			name:     "missing claim",
+			mappings: []ClaimChannelMapping{{Claim: "teams[*]", Channel: "team-{}"}, {Claim: "department.name", Channel: "dept-{}"}},
+			expected: base.Set{},
+		},
+		{
+			name:     "unexpanded array",
+			mappings: []ClaimChannelMapping{{Claim: "groups", Channel: "grp-{}"}},
+			expected: base.Set{},
+		},
+		{
+			name:     "constant channel",
+			mappings: []ClaimChannelMapping{{Claim: "department", Channel: "staff"}},
+			expected: base.SetOf("staff"),
+		},
+		{
+			name:     "star channel",
+			mappings: []ClaimChannelMapping{{Claim: "wildcard", Channel: "{}"}},
+			expected: base.Set{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			channels, err := getJWTClaimMappedChannels(base.TestCtx(t), identity, test.mappings)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, channels)
+		})
+	}
+}
@@ -183,6 +183,40 @@ func TestOIDCProviderMap_GetProviderForIssuer(t *testing.T) {
 	}
 }
 
+// TestFindJWTAuthenticatorSharedIssuer ensures tokens are routed between providers sharing an issuer by audience,
+// falling back to providers with audience checking disabled.
+func TestFindJWTAuthenticatorSharedIssuer(t *testing.T) {
+	const issuer = "http://127.0.0.1:1234"
+	mobileClientID, webClientID, anyClientID := "mobile", "web", ""
+	mobileProvider := &OIDCProvider{Name: "mobile", JWTConfigCommon: JWTConfigCommon{Issuer: issuer, ClientID: &mobileClientID, UserPrefix: "mobile"}}
+	webProvider := &OIDCProvider{Name: "web", JWTConfigCommon: JWTConfigCommon{Issuer: issuer, ClientID: &webClientID, UserPrefix: "web"}}
+	anyProvider := &LocalJWTAuthProvider{name: "any", LocalJWTAuthConfig: LocalJWTAuthConfig{JWTConfigCommon: JWTConfigCommon{Issuer: issuer, ClientID: &anyClientID}}}
+
+	oidcProviders := OIDCProviderMap{"mobile": mobileProvider, "web": webProvider}
+	localJWT := LocalJWTProviderMap{"any": anyProvider}
+	authenticator := &Authenticator{AuthenticatorOptions: AuthenticatorOptions{LogCtx: base.TestCtx(t)}}
+
+	tests := []struct {
+		name      string
+		issuer    string
+		audiences []string
+		expected  jwtAuthenticator
+	}{
+		{name: "mobile audience", issuer: issuer, audiences: []string{"mobile"}, expected: mobileProvider},
+		{name: "web audience", issuer: issuer, audiences: []string{"other", "web"}, expected: webProvider},
+		{name: "unknown audience", issuer: issuer, audiences: []string{"other"}, expected: anyProvider},
+		{name: "unknown issuer", issuer: "http://127.0.0.1:1235", audiences: []string{"mobile"}, expected: nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Repeat to ensure selection doesn't depend on map iteration order
+			for i := 0; i < 10; i++ {
+				assert.Equal(t, test.expected, authenticator.findJWTAuthenticator(test.issuer, test.audiences, oidcProviders, localJWT))
+			}
+		})
+	}
+}
+
 func TestOIDCUsername(t *testing.T) {
 	provider := OIDCProvider{
 		Name: "Some_Provider",
@@ -36,14 +36,35 @@ func (auth *Authenticator) AuthenticateCookie(rq *http.Request, response http.Re
 	}
 
 	var session LoginSession
-	_, err := auth.datastore.Get(auth.DocIDForSession(cookie.Value), &session)
-	if err != nil {
-		if base.IsDocNotFoundError(err) {
+	encrypted := auth.SessionCookieEncryption != nil && isEncryptedCookie(cookie.Value)
+	if encrypted {
+		// The session is verified from the cookie, without looking up the session document
+		decrypted, err := auth.SessionCookieEncryption.decrypt(cookie.Value)
+		if err != nil {
+			base.InfofCtx(auth.LogCtx, base.KeyAuth, "Invalid encrypted session cookie: %v", err)
 			return nil, base.HTTPErrorf(http.StatusUnauthorized, "Session Invalid")
 		}
-		return nil, err
+		session = *decrypted
+		if !time.Now().Before(session.Expiration) {
+			return nil, base.HTTPErrorf(http.StatusUnauthorized, "Session Invalid")
+		}
+		denied, err := auth.SessionCookieEncryption.isDenied(auth.LogCtx, session.ID)
+		if err != nil {
+			return nil, err
+		}
+		if denied {
+			return nil, base.HTTPErrorf(http.StatusUnauthorized, "Session Invalid")
+		}
+	} else {
+		_, err := auth.datastore.Get(auth.DocIDForSession(cookie.Value), &session)
+		if err != nil {
+			if base.IsDocNotFoundError(err) {
+				return nil, base.HTTPErrorf(http.StatusUnauthorized, "Session Invalid")
+			}
+			return nil, err
+		}
 	}
-	// Don't need to check session.Expiration, because Couchbase will have nuked the document.
+	// Don't need to check session.Expiration of session documents, because Couchbase will have nuked the document.
 	// update the session Expiration if 10% or more of the current expiration time has elapsed
 	// if the session does not contain a Ttl (probably created prior to upgrading SG), use
 	// default value of 24Hours
@@ -57,9 +78,16 @@ func (auth *Authenticator) AuthenticateCookie(rq *http.Request, response http.Re
 	tenPercentOfTtl := int(duration.Nanoseconds()) / 10
 	if sessionTimeElapsed > tenPercentOfTtl {
 		session.Expiration = time.Now().Add(duration)
-		if err = auth.datastore.Set(auth.DocIDForSession(session.ID), base.DurationToCbsExpiry(duration), nil, session); err != nil {
+		if err := auth.datastore.Set(auth.DocIDForSession(session.ID), base.DurationToCbsExpiry(duration), nil, session); err != nil {
 			return nil, err
 		}
+		if encrypted {
+			value, err := auth.SessionCookieEncryption.encrypt(&session)
+			if err != nil {
+				return nil, err
+			}
+			cookie.Value = value
+		}
 		base.AddDbPathToCookie(rq, cookie)
 		cookie.Expires = session.Expiration
 		http.SetCookie(response, cookie)
@@ -122,31 +150,74 @@ func (auth *Authenticator) MakeSessionCookie(session *LoginSession, secureCookie
 	if session == nil {
 		return nil
 	}
+	value := session.ID
+	if auth.SessionCookieEncryption != nil {
+		encrypted, err := auth.SessionCookieEncryption.encrypt(session)
+		if err != nil {
+			// The session ID is still a valid cookie, as the session document exists
+			base.WarnfCtx(auth.LogCtx, "Unable to encrypt session cookie, using the session ID instead: %v", err)
+		} else {
+			value = encrypted
+		}
+	}
 	return &http.Cookie{
 		Name:     auth.SessionCookieName,
-		Value:    session.ID,
+		Value:    value,
 		Expires:  session.Expiration,
 		Secure:   secureCookie,
 		HttpOnly: httpOnly,
 	}
 }
 
-func (auth Authenticator) DeleteSessionForCookie(rq *http.Request) *http.Cookie {
+// DeleteSessionForCookie deletes the session of the request's cookie, returning a cookie that clears it, or nil if the
+// request has no session cookie.  An encrypted cookie's session is marked as denied, and an error is returned if it
+// can't be, as the cookie would still be accepted.
+func (auth Authenticator) DeleteSessionForCookie(rq *http.Request) (*http.Cookie, error) {
 	cookie, _ := rq.Cookie(auth.SessionCookieName)
 	if cookie == nil {
-		return nil
+		return nil, nil
 	}
 
-	if err := auth.datastore.Delete(auth.DocIDForSession(cookie.Value)); err != nil {
-		base.InfofCtx(auth.LogCtx, base.KeyAuth, "Error while deleting session for cookie %s, Error: %v", base.UD(cookie.Value), err)
+	sessionID := cookie.Value
+	if auth.SessionCookieEncryption != nil && isEncryptedCookie(cookie.Value) {
+		session, err := auth.SessionCookieEncryption.decrypt(cookie.Value)
+		if err != nil {
+			base.InfofCtx(auth.LogCtx, base.KeyAuth, "Invalid encrypted session cookie: %v", err)
+			sessionID = ""
+		} else {
+			sessionID = session.ID
+			if err := auth.SessionCookieEncryption.deny(session.ID, session.Expiration); err != nil {
+				base.WarnfCtx(auth.LogCtx, "Error while revoking session for cookie %s, Error: %v", base.UD(sessionID), err)
+				return nil, err
+			}
+		}
+	}
+	if sessionID != "" {
+		if err := auth.datastore.Delete(auth.DocIDForSession(sessionID)); err != nil {
+			base.InfofCtx(auth.LogCtx, base.KeyAuth, "Error while deleting session for cookie %s, Error: %v", base.UD(sessionID), err)
+		}
 	}
 
 	newCookie := *cookie
 	newCookie.Value = ""
 	newCookie.Expires = time.Now()
-	return &newCookie
+	return &newCookie, nil
 }
 
+// DeleteSession deletes a session.  When session cookies are encrypted, the session is also marked as denied, so
+// its cookie is no longer accepted.
 func (auth Authenticator) DeleteSession(sessionID string) error {
+	if auth.SessionCookieEncryption != nil {
+		session, err := auth.GetSession(sessionID)
+		if err != nil {
+			return err
+		}
+		// The session document expires along with the session's cookie, so a session without one has expired
+		if session != nil {
+			if err := auth.SessionCookieEncryption.deny(sessionID, session.Expiration); err != nil {
+				return err
+			}
+		}
+	}
 	return auth.datastore.Delete(auth.DocIDForSession(sessionID))
 }
@@ -0,0 +1,217 @@
+//  Copyright 2023-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included
+//  in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+//  in that file, in accordance with the Business Source License, use of this
+//  software will be governed by the Apache License, Version 2.0, included in
+//  the file licenses/APL2.txt.
+
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// SessionCookieKeyLength is the length in bytes of the AES-256 keys that encrypt session cookies.
+const SessionCookieKeyLength = 32
+
+// sessionDenyCacheInterval is how long this node caches whether sessions have been revoked, and so how long other
+// nodes may take to reject a revoked session's cookie.
+const sessionDenyCacheInterval = 5 * time.Second
+
+// sessionCookieKeyIDRegex matches valid key IDs, which are stored in cookie values.
+var sessionCookieKeyIDRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// SessionCookieKey is a key that encrypts session cookies.  Cookies record the ID of the key that encrypted them, so
+// keys can be rotated: cookies encrypted with an older key are accepted for as long as the key is kept.
+type SessionCookieKey struct {
+	ID  string
+	Key []byte
+}
+
+// SessionCookieEncryption encrypts a session's details into its cookie, so a session can be verified without looking
+// up the session document on every request.  Sessions deleted before their cookie expires are marked as denied by a
+// document per session, shared by all nodes, that expires along with the session's cookie.
+type SessionCookieEncryption struct {
+	keyIDs        []string               // IDs of the keys, the first of which encrypts new cookies
+	ciphers       map[string]cipher.AEAD // Ciphers by key ID
+	metadataStore base.DataStore
+	metaKeys      *base.MetadataKeys
+	lock          sync.RWMutex
+	denyCache     map[string]bool  // Whether sessions are denied, by session ID, as looked up since denyCacheTime
+	denyCacheTime time.Time        // When the deny cache was last cleared
+	now           func() time.Time // Testing seam
+}
+
+// sessionDeniedDoc is the document marking a session as revoked.
+type sessionDeniedDoc struct {
+	Expiration int64 `json:"exp"` // Expiry (unix time) of the session's cookie
+}
+
+// ValidateSessionCookieKeys returns an error if the keys can't be used to encrypt session cookies.
+func ValidateSessionCookieKeys(keys []SessionCookieKey) error {
+	if len(keys) == 0 {
+		return errors.New("at least one key is required")
+	}
+	ids := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if !sessionCookieKeyIDRegex.MatchString(key.ID) {
+			return fmt.Errorf("key ID %q must only contain letters, digits, '-' and '_'", key.ID)
+		}
+		if _, ok := ids[key.ID]; ok {
+			return fmt.Errorf("duplicate key ID %q", key.ID)
+		}
+		ids[key.ID] = struct{}{}
+		if len(key.Key) != SessionCookieKeyLength {
+			return fmt.Errorf("key %q must be %d bytes", key.ID, SessionCookieKeyLength)
+		}
+	}
+	return nil
+}
+
+// NewSessionCookieEncryption returns the encryption for session cookies using the given keys, the first of which
+// encrypts new cookies.
+func NewSessionCookieEncryption(keys []SessionCookieKey, metadataStore base.DataStore, metaKeys *base.MetadataKeys) (*SessionCookieEncryption, error) {
+	if err := ValidateSessionCookieKeys(keys); err != nil {
+		return nil, err
+	}
+	if metaKeys == nil {
+		metaKeys = base.DefaultMetadataKeys
+	}
+	e := &SessionCookieEncryption{
+		ciphers:       make(map[string]cipher.AEAD, len(keys)),
+		metadataStore: metadataStore,
+		metaKeys:      metaKeys,
+		now:           time.Now,
+	}
+	for _, key := range keys {
+		block, err := aes.NewCipher(key.Key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		e.keyIDs = append(e.keyIDs, key.ID)
+		e.ciphers[key.ID] = aead
+	}
+	return e, nil
+}
+
+// isEncryptedCookie returns true if the cookie value is an encrypted session, rather than a session ID.  Session IDs
+// are hex, so never contain the separator.
+func isEncryptedCookie(value string) bool {
+	return strings.Contains(value, ".")
+}
+
+// encrypt returns a cookie value holding the session, encrypted with the current key.  The value is the key ID and the
+// nonce and ciphertext, base64 encoded, separated by a '.'.
+func (e *SessionCookieEncryption) encrypt(session *LoginSession) (string, error) {
+	plaintext, err := base.JSONMarshal(session)
+	if err != nil {
+		return "", err
+	}
+	keyID := e.keyIDs[0]
+	aead := e.ciphers[keyID]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(keyID))
+	return keyID + "." + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt returns the session held by an encrypted cookie value.  Returns an error if the value was encrypted with an
+// unknown key, or has been tampered with.
+func (e *SessionCookieEncryption) decrypt(value string) (*LoginSession, error) {
+	keyID, encoded, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil, errors.New("not an encrypted session")
+	}
+	aead, ok := e.ciphers[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", keyID)
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("encrypted session too short")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return nil, err
+	}
+	var session LoginSession
+	if err := base.JSONUnmarshal(plaintext, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// isDenied returns true if the session has been revoked.  Lookups are cached for sessionDenyCacheInterval, so the
+// metadata store is read at most once per interval for each session in use, and sessions revoked on other nodes are
+// rejected within the interval.
+func (e *SessionCookieEncryption) isDenied(ctx context.Context, sessionID string) (bool, error) {
+	e.lock.RLock()
+	denied, cached := e.denyCache[sessionID]
+	stale := e.now().Sub(e.denyCacheTime) >= sessionDenyCacheInterval
+	e.lock.RUnlock()
+	if cached && !stale {
+		return denied, nil
+	}
+
+	var doc sessionDeniedDoc
+	_, err := e.metadataStore.Get(e.metaKeys.SessionDeniedKey(sessionID), &doc)
+	if err != nil && !base.IsDocNotFoundError(err) {
+		base.WarnfCtx(ctx, "Unable to check whether session %s has been revoked: %v", base.UD(sessionID), err)
+		return false, err
+	}
+	denied = err == nil
+	e.cacheDenied(sessionID, denied)
+	return denied, nil
+}
+
+// deny marks a session as revoked until it expires, when its cookie would no longer be accepted anyway.
+func (e *SessionCookieEncryption) deny(sessionID string, expiration time.Time) error {
+	ttl := expiration.Sub(e.now())
+	if ttl <= 0 {
+		return nil
+	}
+	body, err := base.JSONMarshal(sessionDeniedDoc{Expiration: expiration.Unix()})
+	if err != nil {
+		return err
+	}
+	if err := e.metadataStore.SetRaw(e.metaKeys.SessionDeniedKey(sessionID), base.DurationToCbsExpiry(ttl), nil, body); err != nil {
+		return err
+	}
+	e.cacheDenied(sessionID, true)
+	return nil
+}
+
+// cacheDenied records whether a session is denied.  The cache is cleared once it's older than
+// sessionDenyCacheInterval, so it only holds the sessions used within the interval.
+func (e *SessionCookieEncryption) cacheDenied(sessionID string, denied bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	now := e.now()
+	if e.denyCache == nil || now.Sub(e.denyCacheTime) >= sessionDenyCacheInterval {
+		e.denyCache = make(map[string]bool)
+		e.denyCacheTime = now
+	}
+	e.denyCache[sessionID] = denied
+}
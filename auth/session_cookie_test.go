@@ -0,0 +1,216 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package auth
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionCookieEncryptionKeys(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, SessionCookieKeyLength)
+	require.NoError(t, ValidateSessionCookieKeys([]SessionCookieKey{{ID: "key-1", Key: key}, {ID: "key_2", Key: key}}))
+	require.Error(t, ValidateSessionCookieKeys(nil))
+	require.Error(t, ValidateSessionCookieKeys([]SessionCookieKey{{ID: "key.1", Key: key}}))
+	require.Error(t, ValidateSessionCookieKeys([]SessionCookieKey{{ID: "key1", Key: key}, {ID: "key1", Key: key}}))
+	require.Error(t, ValidateSessionCookieKeys([]SessionCookieKey{{ID: "key1", Key: key[:16]}}))
+}
+
+func TestSessionCookieEncryptionRotation(t *testing.T) {
+	oldKey := SessionCookieKey{ID: "old", Key: bytes.Repeat([]byte{1}, SessionCookieKeyLength)}
+	newKey := SessionCookieKey{ID: "new", Key: bytes.Repeat([]byte{2}, SessionCookieKeyLength)}
+	session := &LoginSession{
+		ID:          "abc123",
+		Username:    "alice",
+		Expiration:  time.Now().UTC().Add(time.Hour).Round(0),
+		Ttl:         time.Hour,
+		SessionUUID: "uuid",
+	}
+
+	oldEncryption, err := NewSessionCookieEncryption([]SessionCookieKey{oldKey}, nil, nil)
+	require.NoError(t, err)
+	oldValue, err := oldEncryption.encrypt(session)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(oldValue, "old."))
+	assert.True(t, isEncryptedCookie(oldValue))
+	assert.False(t, isEncryptedCookie(session.ID))
+
+	// Once rotated, new cookies are encrypted with the new key, and cookies encrypted with the old key still decrypt
+	rotatedEncryption, err := NewSessionCookieEncryption([]SessionCookieKey{newKey, oldKey}, nil, nil)
+	require.NoError(t, err)
+	newValue, err := rotatedEncryption.encrypt(session)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(newValue, "new."))
+	for _, value := range []string{oldValue, newValue} {
+		decrypted, err := rotatedEncryption.decrypt(value)
+		require.NoError(t, err)
+		assert.Equal(t, session, decrypted)
+	}
+
+	// Cookies encrypted with a key that's been removed, or that have been tampered with, don't decrypt
+	newEncryption, err := NewSessionCookieEncryption([]SessionCookieKey{newKey}, nil, nil)
+	require.NoError(t, err)
+	_, err = newEncryption.decrypt(oldValue)
+	require.Error(t, err)
+	tampered := []byte(newValue)
+	tampered[len(tampered)/2] ^= 1
+	_, err = newEncryption.decrypt(string(tampered))
+	require.Error(t, err)
+	_, err = newEncryption.decrypt("new." + strings.Split(oldValue, ".")[1])
+	require.Error(t, err)
+}
+
+func TestEncryptedSessionCookie(t *testing.T) {
+	const defaultEndpoint = "http://localhost/"
+	base.SetUpTestLogging(t, base.LevelDebug, base.KeyAuth)
+	ctx := base.TestCtx(t)
+	testBucket := base.GetTestBucket(t)
+	defer testBucket.Close(ctx)
+	dataStore := testBucket.GetSingleDataStore()
+
+	encryption, err := NewSessionCookieEncryption([]SessionCookieKey{{ID: "key1", Key: bytes.Repeat([]byte{1}, SessionCookieKeyLength)}}, dataStore, nil)
+	require.NoError(t, err)
+	options := DefaultAuthenticatorOptions(ctx)
+	options.SessionCookieEncryption = encryption
+	auth := NewAuthenticator(dataStore, nil, options)
+
+	user, err := auth.NewUser("alice", "password", base.Set{})
+	require.NoError(t, err)
+	require.NoError(t, auth.Save(user))
+
+	authenticate := func(cookie *http.Cookie) (User, error) {
+		request, _ := http.NewRequest(http.MethodGet, defaultEndpoint, nil)
+		request.AddCookie(cookie)
+		return auth.AuthenticateCookie(request, httptest.NewRecorder())
+	}
+	requireUnauthorized := func(err error) {
+		var httpErr *base.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusUnauthorized, httpErr.Status)
+	}
+
+	// Encrypted cookies are verified without the session document
+	session, err := auth.CreateSession(user, time.Hour)
+	require.NoError(t, err)
+	cookie := auth.MakeSessionCookie(session, false, false)
+	assert.NotEqual(t, session.ID, cookie.Value)
+	require.NoError(t, dataStore.Delete(auth.DocIDForSession(session.ID)))
+	authUser, err := authenticate(cookie)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", authUser.Name())
+
+	// Cookies holding a session ID, created before encryption was enabled, still work
+	legacySession, err := auth.CreateSession(user, time.Hour)
+	require.NoError(t, err)
+	authUser, err = authenticate(&http.Cookie{Name: DefaultCookieName, Value: legacySession.ID})
+	require.NoError(t, err)
+	assert.Equal(t, "alice", authUser.Name())
+
+	// A deleted session's cookie is rejected
+	session, err = auth.CreateSession(user, time.Hour)
+	require.NoError(t, err)
+	cookie = auth.MakeSessionCookie(session, false, false)
+	require.NoError(t, auth.DeleteSession(session.ID))
+	_, err = authenticate(cookie)
+	requireUnauthorized(err)
+
+	// As is the cookie of a session deleted by logging out
+	session, err = auth.CreateSession(user, time.Hour)
+	require.NoError(t, err)
+	cookie = auth.MakeSessionCookie(session, false, false)
+	request, _ := http.NewRequest(http.MethodDelete, defaultEndpoint, nil)
+	request.AddCookie(cookie)
+	newCookie, err := auth.DeleteSessionForCookie(request)
+	require.NoError(t, err)
+	require.NotNil(t, newCookie)
+	_, err = authenticate(cookie)
+	requireUnauthorized(err)
+
+	// Other nodes pick up the denied session from the metadata store, where it expires along with the session
+	otherNodeEncryption, err := NewSessionCookieEncryption([]SessionCookieKey{{ID: "key1", Key: bytes.Repeat([]byte{1}, SessionCookieKeyLength)}}, dataStore, nil)
+	require.NoError(t, err)
+	denied, err := otherNodeEncryption.isDenied(ctx, session.ID)
+	require.NoError(t, err)
+	assert.True(t, denied)
+	expiry, err := dataStore.GetExpiry(ctx, base.DefaultMetadataKeys.SessionDeniedKey(session.ID))
+	require.NoError(t, err)
+	assert.NotZero(t, expiry)
+
+	// Sessions that haven't been revoked are cached as such until the cache interval has passed
+	session, err = auth.CreateSession(user, time.Hour)
+	require.NoError(t, err)
+	denied, err = otherNodeEncryption.isDenied(ctx, session.ID)
+	require.NoError(t, err)
+	assert.False(t, denied)
+	require.NoError(t, auth.DeleteSession(session.ID))
+	denied, err = otherNodeEncryption.isDenied(ctx, session.ID)
+	require.NoError(t, err)
+	assert.False(t, denied)
+	now := time.Now().Add(sessionDenyCacheInterval)
+	otherNodeEncryption.now = func() time.Time { return now }
+	denied, err = otherNodeEncryption.isDenied(ctx, session.ID)
+	require.NoError(t, err)
+	assert.True(t, denied)
+	assert.Len(t, otherNodeEncryption.denyCache, 1)
+
+	// An expired cookie is rejected
+	session, err = auth.CreateSession(user, time.Hour)
+	require.NoError(t, err)
+	session.Expiration = time.Now().Add(-time.Minute)
+	cookie = auth.MakeSessionCookie(session, false, false)
+	_, err = authenticate(cookie)
+	requireUnauthorized(err)
+
+	// As are cookies of sessions revoked by a password change, via the session UUID
+	session, err = auth.CreateSession(user, time.Hour)
+	require.NoError(t, err)
+	cookie = auth.MakeSessionCookie(session, false, false)
+	require.NoError(t, user.SetPassword("newpassword"))
+	require.NoError(t, auth.Save(user))
+	_, err = authenticate(cookie)
+	requireUnauthorized(err)
+}
+
+func TestEncryptedSessionCookieDenyError(t *testing.T) {
+	const defaultEndpoint = "http://localhost/"
+	ctx := base.TestCtx(t)
+	testBucket := base.GetTestBucket(t)
+	defer testBucket.Close(ctx)
+
+	session := &LoginSession{ID: "0123456789abcdef", Username: "alice", Expiration: time.Now().Add(time.Hour), Ttl: time.Hour}
+	leakyBucket := base.NewLeakyBucket(testBucket, base.LeakyBucketConfig{
+		ForceErrorSetRawKeys: []string{base.DefaultMetadataKeys.SessionDeniedKey(session.ID)},
+	})
+	dataStore := leakyBucket.DefaultDataStore()
+
+	encryption, err := NewSessionCookieEncryption([]SessionCookieKey{{ID: "key1", Key: bytes.Repeat([]byte{1}, SessionCookieKeyLength)}}, dataStore, nil)
+	require.NoError(t, err)
+	options := DefaultAuthenticatorOptions(ctx)
+	options.SessionCookieEncryption = encryption
+	auth := NewAuthenticator(dataStore, nil, options)
+
+	// Logging out fails if the session can't be denied, as its cookie would still be accepted
+	cookie := auth.MakeSessionCookie(session, false, false)
+	request, _ := http.NewRequest(http.MethodDelete, defaultEndpoint, nil)
+	request.AddCookie(cookie)
+	newCookie, err := auth.DeleteSessionForCookie(request)
+	require.Error(t, err)
+	assert.Nil(t, newCookie)
+	denied, err := encryption.isDenied(ctx, session.ID)
+	require.NoError(t, err)
+	assert.False(t, denied)
+}
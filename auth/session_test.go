@@ -183,7 +183,8 @@ func TestDeleteSessionForCookie(t *testing.T) {
 	}
 
 	request.AddCookie(cookie)
-	newCookie := auth.DeleteSessionForCookie(request)
+	newCookie, err := auth.DeleteSessionForCookie(request)
+	require.NoError(t, err)
 
 	assert.NotEmpty(t, newCookie.Name)
 	assert.Empty(t, newCookie.Value)
@@ -199,7 +200,8 @@ func TestDeleteSessionForCookie(t *testing.T) {
 	}
 
 	request.AddCookie(cookie)
-	newCookie = auth.DeleteSessionForCookie(request)
+	newCookie, err = auth.DeleteSessionForCookie(request)
+	require.NoError(t, err)
 	assert.Nil(t, newCookie)
 }
 
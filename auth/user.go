@@ -31,6 +31,10 @@ type userImpl struct {
 	auth  *Authenticator
 	roles []Role
 
+	// channelAllowlist restricts the channels visible to the user regardless of their grants, when non-nil. Only set
+	// for the guest user, from AuthenticatorOptions.GuestChannelAllowlist.
+	channelAllowlist base.Set
+
 	// warnChanThresholdOnce ensures that the check for channels
 	// per user threshold is only performed exactly once.
 	warnChanThresholdOnce sync.Once
@@ -584,7 +588,84 @@ func (user *userImpl) InitializeRoles() {
 	_ = user.GetRoles()
 }
 
+// channelAllowed returns false if the channel is excluded by the user's channel allowlist.
+func (user *userImpl) channelAllowed(channel string) bool {
+	return user.channelAllowlist == nil || user.channelAllowlist.Contains(channel)
+}
+
+// applyChannelAllowlist restricts a set of channels granted to the user to their channel allowlist. A grant of the
+// all-channel wildcard grants every channel in the allowlist.
+func (user *userImpl) applyChannelAllowlist(channels ch.TimedSet) ch.TimedSet {
+	if user.channelAllowlist == nil {
+		return channels
+	}
+	starSeq, hasStar := channels[ch.AllChannelWildcard]
+	filtered := make(ch.TimedSet, len(user.channelAllowlist))
+	for channel := range user.channelAllowlist {
+		if seq, ok := channels[channel]; ok {
+			filtered[channel] = seq
+		} else if hasStar {
+			filtered[channel] = starSeq
+		}
+	}
+	return filtered
+}
+
+// authorizeChannelAllowlist returns an error if none of the channels are permitted by the user's channel allowlist,
+// otherwise returns the channels that are permitted. Documents in no channels are never permitted by an allowlist.
+func (user *userImpl) authorizeChannelAllowlist(channels base.Set) (base.Set, error) {
+	if user.channelAllowlist == nil {
+		return channels, nil
+	}
+	allowed := make(base.Set, len(channels))
+	for channel := range channels {
+		if user.channelAllowlist.Contains(channel) {
+			allowed.Add(channel)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil, user.UnauthError("You are not allowed to see this")
+	}
+	return allowed, nil
+}
+
+// isSystemChannel returns true if the channel is one of the database's system channels, which aren't included in a
+// grant of the star channel.
+func (user *userImpl) isSystemChannel(channel string) bool {
+	return user.auth != nil && user.auth.SystemChannels.Contains(channel)
+}
+
+// systemChannelGranted returns true if a system channel has been explicitly granted to the user, either directly or
+// via a role. A grant of the star channel doesn't include system channels.
+func (user *userImpl) systemChannelGranted(scope, collection, channel string) bool {
+	return user.InheritedCollectionChannels(scope, collection).Contains(channel)
+}
+
+// authorizeSystemChannels returns an error if the channels are all system channels the user hasn't been explicitly
+// granted, otherwise returns the channels with those system channels removed.
+func (user *userImpl) authorizeSystemChannels(scope, collection string, channels base.Set) (base.Set, error) {
+	if user.auth == nil || len(user.auth.SystemChannels) == 0 || len(channels) == 0 {
+		return channels, nil
+	}
+	allowed := make(base.Set, len(channels))
+	for channel := range channels {
+		if !user.isSystemChannel(channel) || user.systemChannelGranted(scope, collection, channel) {
+			allowed.Add(channel)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil, user.UnauthError("You are not allowed to see this")
+	}
+	return allowed, nil
+}
+
 func (user *userImpl) canSeeChannel(channel string) bool {
+	if !user.channelAllowed(channel) {
+		return false
+	}
+	if user.isSystemChannel(channel) {
+		return user.systemChannelGranted(base.DefaultScope, base.DefaultCollection, channel)
+	}
 	if user.roleImpl.canSeeChannel(channel) {
 		return true
 	}
@@ -597,6 +678,12 @@ func (user *userImpl) canSeeChannel(channel string) bool {
 }
 
 func (user *userImpl) canSeeChannelSince(channel string) uint64 {
+	if !user.channelAllowed(channel) {
+		return 0
+	}
+	if user.isSystemChannel(channel) {
+		return user.InheritedCollectionChannels(base.DefaultScope, base.DefaultCollection)[channel].Sequence
+	}
 	minSeq := user.roleImpl.canSeeChannelSince(channel)
 	for _, role := range user.GetRoles() {
 		if seq := role.canSeeChannelSince(channel); seq > 0 && (seq < minSeq || minSeq == 0) {
@@ -611,6 +698,13 @@ func (user *userImpl) authorizeAllChannels(channels base.Set) error {
 }
 
 func (user *userImpl) authorizeAnyChannel(channels base.Set) error {
+	channels, err := user.authorizeChannelAllowlist(channels)
+	if err != nil {
+		return err
+	}
+	if channels, err = user.authorizeSystemChannels(base.DefaultScope, base.DefaultCollection, channels); err != nil {
+		return err
+	}
 	return authorizeAnyChannel(user, channels)
 }
 
@@ -620,6 +714,7 @@ func (user *userImpl) inheritedChannels() ch.TimedSet {
 		roleSince := user.RoleNames()[role.Name()]
 		channels.AddAtSequence(role.Channels(), roleSince.Sequence)
 	}
+	channels = user.applyChannelAllowlist(channels)
 
 	user.warnChanThresholdOnce.Do(func() {
 		if channelsPerUserThreshold := user.auth.ChannelsWarningThreshold; channelsPerUserThreshold != nil {
@@ -9,6 +9,8 @@
 package auth
 
 import (
+	"fmt"
+
 	"github.com/couchbase/sync_gateway/base"
 	ch "github.com/couchbase/sync_gateway/channels"
 )
@@ -38,6 +40,12 @@ func (user *userImpl) SetCollectionJWTChannels(scope, collection string, channel
 }
 
 func (user *userImpl) CanSeeCollectionChannel(scope, collection, channel string) bool {
+	if !user.channelAllowed(channel) {
+		return false
+	}
+	if user.isSystemChannel(channel) {
+		return user.systemChannelGranted(scope, collection, channel)
+	}
 	if user.roleImpl.CanSeeCollectionChannel(scope, collection, channel) {
 		return true
 	}
@@ -56,6 +64,7 @@ func (user *userImpl) InheritedCollectionChannels(scope, collection string) ch.T
 		roleSince := user.RoleNames()[role.Name()]
 		channels.AddAtSequence(role.CollectionChannels(scope, collection), roleSince.Sequence)
 	}
+	channels = user.applyChannelAllowlist(channels)
 
 	// Warning threshold is per-collection, as we lazily load per-collection channel information
 	user.warnChanThresholdOnce.Do(func() {
@@ -71,6 +80,20 @@ func (user *userImpl) InheritedCollectionChannels(scope, collection string) ch.T
 	return channels
 }
 
+// Checks for user access to all channels in the set, restricted to the user's channel allowlist
+func (user *userImpl) authorizeAllCollectionChannels(scope, collection string, channels base.Set) error {
+	var forbidden []string
+	for channel := range channels {
+		if !user.channelAllowed(channel) || (user.isSystemChannel(channel) && !user.systemChannelGranted(scope, collection, channel)) {
+			forbidden = append(forbidden, channel)
+		}
+	}
+	if forbidden != nil {
+		return user.UnauthError(fmt.Sprintf("You are not allowed to see channels %v", forbidden))
+	}
+	return user.roleImpl.authorizeAllCollectionChannels(scope, collection, channels)
+}
+
 // Checks for user access to any channel in the set, including access inherited via roles
 func (user *userImpl) AuthorizeAnyCollectionChannel(scope, collection string, channels base.Set) error {
 
@@ -78,6 +101,14 @@ func (user *userImpl) AuthorizeAnyCollectionChannel(scope, collection string, ch
 		return user.authorizeAnyChannel(channels)
 	}
 
+	channels, err := user.authorizeChannelAllowlist(channels)
+	if err != nil {
+		return err
+	}
+	if channels, err = user.authorizeSystemChannels(scope, collection, channels); err != nil {
+		return err
+	}
+
 	// User access
 	if ca, ok := user.getCollectionAccess(scope, collection); ok {
 		if len(channels) > 0 {
@@ -102,6 +133,12 @@ func (user *userImpl) AuthorizeAnyCollectionChannel(scope, collection string, ch
 }
 
 func (user *userImpl) canSeeCollectionChannelSince(scope, collection, channel string) uint64 {
+	if !user.channelAllowed(channel) {
+		return 0
+	}
+	if user.isSystemChannel(channel) {
+		return user.InheritedCollectionChannels(scope, collection)[channel].Sequence
+	}
 	minSeq := user.roleImpl.canSeeCollectionChannelSince(scope, collection, channel)
 	for _, role := range user.GetRoles() {
 		if seq := role.canSeeCollectionChannelSince(scope, collection, channel); seq > 0 && (seq < minSeq || minSeq == 0) {
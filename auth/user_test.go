@@ -310,6 +310,80 @@ func TestGetAddedChannels(t *testing.T) {
 	assert.Equal(t, expectedChannels, addedChannels)
 }
 
+func TestGuestChannelAllowlist(t *testing.T) {
+	ctx := base.TestCtx(t)
+	testBucket := base.GetTestBucket(t)
+	defer testBucket.Close(ctx)
+	dataStore := testBucket.GetSingleDataStore()
+	options := DefaultAuthenticatorOptions(ctx)
+	options.GuestChannelAllowlist = base.SetOf("public", "news")
+	auth := NewAuthenticator(dataStore, nil, options)
+
+	guest, err := auth.NewUser("", "", channels.BaseSetOf(t, "public", "private"))
+	require.NoError(t, err)
+	require.NoError(t, auth.Save(guest))
+	user, err := auth.NewUser("alice", "password", channels.BaseSetOf(t, "public", "private"))
+	require.NoError(t, err)
+	require.NoError(t, auth.Save(user))
+
+	guest, err = auth.GetUser("")
+	require.NoError(t, err)
+	assert.True(t, guest.CanSeeCollectionChannel(base.DefaultScope, base.DefaultCollection, "public"))
+	assert.False(t, guest.CanSeeCollectionChannel(base.DefaultScope, base.DefaultCollection, "private"))
+	assert.False(t, guest.CanSeeCollectionChannel(base.DefaultScope, base.DefaultCollection, "news"))
+	assert.Equal(t, uint64(0), guest.canSeeChannelSince("private"))
+	assert.Equal(t, channels.BaseSetOf(t, "public"), guest.InheritedCollectionChannels(base.DefaultScope, base.DefaultCollection).AsSet())
+	assert.NoError(t, guest.AuthorizeAnyCollectionChannel(base.DefaultScope, base.DefaultCollection, channels.BaseSetOf(t, "public", "private")))
+	assert.Error(t, guest.AuthorizeAnyCollectionChannel(base.DefaultScope, base.DefaultCollection, channels.BaseSetOf(t, "private")))
+	assert.Error(t, guest.AuthorizeAnyCollectionChannel(base.DefaultScope, base.DefaultCollection, nil))
+	assert.Error(t, guest.authorizeAllChannels(channels.BaseSetOf(t, "public", "private")))
+
+	// A star grant allows every channel in the allowlist, and nothing else
+	guest.setChannels(channels.AtSequence(channels.BaseSetOf(t, "*"), 1))
+	assert.True(t, guest.CanSeeCollectionChannel(base.DefaultScope, base.DefaultCollection, "news"))
+	assert.False(t, guest.CanSeeCollectionChannel(base.DefaultScope, base.DefaultCollection, "private"))
+	assert.Equal(t, channels.BaseSetOf(t, "public", "news"), guest.InheritedCollectionChannels(base.DefaultScope, base.DefaultCollection).AsSet())
+
+	// Allowlist only applies to the guest user
+	user, err = auth.GetUser("alice")
+	require.NoError(t, err)
+	assert.True(t, user.CanSeeCollectionChannel(base.DefaultScope, base.DefaultCollection, "private"))
+}
+
+func TestSystemChannels(t *testing.T) {
+	ctx := base.TestCtx(t)
+	testBucket := base.GetTestBucket(t)
+	defer testBucket.Close(ctx)
+	dataStore := testBucket.GetSingleDataStore()
+	options := DefaultAuthenticatorOptions(ctx)
+	options.SystemChannels = base.SetOf("!internal-audit")
+	auth := NewAuthenticator(dataStore, nil, options)
+
+	user, err := auth.NewUser("alice", "password", channels.BaseSetOf(t, "*"))
+	require.NoError(t, err)
+	require.NoError(t, auth.Save(user))
+	user, err = auth.GetUser("alice")
+	require.NoError(t, err)
+
+	// A star grant doesn't include system channels
+	assert.True(t, user.CanSeeCollectionChannel(base.DefaultScope, base.DefaultCollection, "public"))
+	assert.False(t, user.CanSeeCollectionChannel(base.DefaultScope, base.DefaultCollection, "!internal-audit"))
+	assert.Equal(t, uint64(0), user.canSeeChannelSince("!internal-audit"))
+	assert.Error(t, user.AuthorizeAnyCollectionChannel(base.DefaultScope, base.DefaultCollection, channels.BaseSetOf(t, "!internal-audit")))
+	assert.NoError(t, user.AuthorizeAnyCollectionChannel(base.DefaultScope, base.DefaultCollection, channels.BaseSetOf(t, "!internal-audit", "public")))
+	assert.NoError(t, user.AuthorizeAnyCollectionChannel(base.DefaultScope, base.DefaultCollection, nil))
+	assert.Error(t, user.authorizeAllChannels(channels.BaseSetOf(t, "!internal-audit", "public")))
+	filtered, removed := user.FilterToAvailableCollectionChannels(base.DefaultScope, base.DefaultCollection, channels.BaseSetOf(t, "!internal-audit"))
+	assert.Empty(t, filtered)
+	assert.Equal(t, []string{"!internal-audit"}, removed)
+
+	// An explicit grant of a system channel allows access
+	user.setChannels(channels.AtSequence(channels.BaseSetOf(t, "*", "!internal-audit"), 1))
+	assert.True(t, user.CanSeeCollectionChannel(base.DefaultScope, base.DefaultCollection, "!internal-audit"))
+	assert.Equal(t, uint64(1), user.canSeeChannelSince("!internal-audit"))
+	assert.NoError(t, user.AuthorizeAnyCollectionChannel(base.DefaultScope, base.DefaultCollection, channels.BaseSetOf(t, "!internal-audit")))
+}
+
 // Needless to say; must not authenticate with nil user reference;
 func TestUserAuthenticateWithNilUserReference(t *testing.T) {
 	var nouser *userImpl
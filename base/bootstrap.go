@@ -510,6 +510,30 @@ func (cc *CouchbaseCluster) Close() {
 	}
 }
 
+// Reconnect closes the cached cluster and bucket connections, so that subsequent operations open new connections and
+// re-resolve the server's connection string. Bucket connections that are in use are closed once released.
+func (cc *CouchbaseCluster) Reconnect(ctx context.Context) {
+	if cc.bucketConnectionMode != CachedClusterConnections {
+		return
+	}
+
+	cc.cachedBucketConnections.removeOutdatedBuckets(Set{})
+
+	cc.cachedConnectionLock.Lock()
+	defer cc.cachedConnectionLock.Unlock()
+	if cc.cachedClusterConnection != nil {
+		if err := cc.cachedClusterConnection.Close(nil); err != nil {
+			WarnfCtx(ctx, "Failed to close cluster connection: %v", err)
+		}
+		cc.cachedClusterConnection = nil
+	}
+}
+
+// Server returns the cluster's connection string.
+func (cc *CouchbaseCluster) Server() string {
+	return cc.server
+}
+
 func (cc *CouchbaseCluster) GetClusterN1QLStore(bucketName, scopeName, collectionName string) (*ClusterOnlyN1QLStore, error) {
 	gocbCluster, err := cc.getClusterConnection()
 	if err != nil {
@@ -121,16 +121,17 @@ type CouchbaseBucketType int
 type BucketSpec struct {
 	Server, BucketName, FeedType  string
 	Auth                          AuthHandler
-	Certpath, Keypath, CACertPath string         // X.509 auth parameters
-	TLSSkipVerify                 bool           // Use insecureSkipVerify when secure scheme (couchbases) is used and cacertpath is undefined
-	KvTLSPort                     int            // Port to use for memcached over TLS.  Required for cbdatasource auth when using TLS
-	UseXattrs                     bool           // Whether to use xattrs to store _sync metadata.  Used during view initialization
-	ViewQueryTimeoutSecs          *uint32        // the view query timeout in seconds (default: 75 seconds)
-	MaxConcurrentQueryOps         *int           // maximum number of concurrent query operations (default: DefaultMaxConcurrentQueryOps)
-	BucketOpTimeout               *time.Duration // How long bucket ops should block returning "operation timed out". If nil, uses GoCB default.  GoCB buckets only.
-	KvPoolSize                    int            // gocb kv_pool_size - number of pipelines per node. Initialized on GetGoCBConnString
-	KvBufferSize                  int            // gocb kv buffer size for number of pipelines made. Inititialised on the gocb connection string
-	DcpBuffer                     int            // gocb dcp buffer size inititialised on the gocb connection string
+	Certpath, Keypath, CACertPath string                 // X.509 auth parameters
+	TLSSkipVerify                 bool                   // Use insecureSkipVerify when secure scheme (couchbases) is used and cacertpath is undefined
+	KvTLSPort                     int                    // Port to use for memcached over TLS.  Required for cbdatasource auth when using TLS
+	UseXattrs                     bool                   // Whether to use xattrs to store _sync metadata.  Used during view initialization
+	ViewQueryTimeoutSecs          *uint32                // the view query timeout in seconds (default: 75 seconds)
+	MaxConcurrentQueryOps         *int                   // maximum number of concurrent query operations (default: DefaultMaxConcurrentQueryOps)
+	BucketOpTimeout               *time.Duration         // How long bucket ops should block returning "operation timed out". If nil, uses GoCB default.  GoCB buckets only.
+	KvPoolSize                    int                    // gocb kv_pool_size - number of pipelines per node. Initialized on GetGoCBConnString
+	KvBufferSize                  int                    // gocb kv buffer size for number of pipelines made. Inititialised on the gocb connection string
+	DcpBuffer                     int                    // gocb dcp buffer size inititialised on the gocb connection string
+	KvCircuitBreaker              *CircuitBreakerOptions // If set, KV ops on each collection have a timeout budget and are rejected while the collection's circuit breaker is open. GoCB buckets only.
 }
 
 const defaultNumRetries = 10
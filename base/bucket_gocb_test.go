@@ -1859,6 +1859,9 @@ func TestApplyViewQueryOptions(t *testing.T) {
 		t.Fatalf("Error calling applyViewQueryOptions: %v", err)
 	}
 
+	// The query is cancelled with ctx
+	assert.Equal(t, ctx, viewOpts.Context)
+
 	// "stale"
 	assert.Equal(t, gocb.ViewScanConsistencyRequestPlus, viewOpts.ScanConsistency)
 
@@ -0,0 +1,289 @@
+/*
+Copyright 2023-Present Couchbase, Inc.
+
+Use of this software is governed by the Business Source License included in
+the file licenses/BSL-Couchbase.txt.  As of the Change Date specified in that
+file, in accordance with the Business Source License, use of this software will
+be governed by the Apache License, Version 2.0, included in the file
+licenses/APL2.txt.
+*/
+
+package base
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// Defaults for CircuitBreakerOptions left unset.
+const (
+	DefaultCircuitBreakerErrorThresholdPercent = 50
+	DefaultCircuitBreakerVolumeThreshold       = 20
+	DefaultCircuitBreakerRollingWindow         = 10 * time.Second
+	DefaultCircuitBreakerSleepWindow           = 5 * time.Second
+)
+
+// CircuitBreakerState is the state of a CircuitBreaker. Values match those reported by the kv_circuit_breaker_state stat.
+type CircuitBreakerState int
+
+const (
+	CircuitBreakerClosed   CircuitBreakerState = iota // Operations are allowed
+	CircuitBreakerOpen                                // Operations are rejected until the sleep window has elapsed
+	CircuitBreakerHalfOpen                            // A single trial operation is allowed, to determine whether to close
+)
+
+func (s CircuitBreakerState) String() string {
+	switch s {
+	case CircuitBreakerClosed:
+		return "closed"
+	case CircuitBreakerOpen:
+		return "open"
+	case CircuitBreakerHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitBreakerState(%d)", int(s))
+	}
+}
+
+// CircuitBreakerOptions configures a CircuitBreaker.
+type CircuitBreakerOptions struct {
+	OpBudget              time.Duration // Maximum time an operation waits to start before being rejected. Zero waits indefinitely
+	ErrorThresholdPercent float64       // Percentage of failed operations within the rolling window that opens the breaker
+	VolumeThreshold       int           // Minimum number of operations within the rolling window before the breaker can open
+	RollingWindow         time.Duration // Period over which the error rate is measured
+	SleepWindow           time.Duration // How long the breaker stays open before allowing a trial operation
+}
+
+// CircuitBreakerStats are the stats updated by a CircuitBreaker. Any may be nil.
+type CircuitBreakerStats struct {
+	State    *SgwIntStat // Current CircuitBreakerState
+	Opens    *SgwIntStat // Number of times the breaker opened
+	Rejected *SgwIntStat // Number of operations rejected while open, or for exceeding the op budget
+}
+
+// RetryAfterError is returned when an operation is rejected to protect a backend that's failing or unresponsive, rather
+// than waiting on it. Handled as a 503 with a Retry-After header by the REST API.
+type RetryAfterError struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return e.Message
+}
+
+// CircuitBreaker rejects operations against a datastore once the rate of timeouts and temporary failures within a
+// rolling window exceeds a threshold, so that callers fail fast instead of queueing behind operations that are
+// unlikely to complete. After the sleep window a single trial operation is allowed, and its outcome determines whether
+// the breaker closes or stays open. Methods are safe to call on a nil CircuitBreaker, which allows all operations.
+type CircuitBreaker struct {
+	logCtx  context.Context
+	name    string
+	options CircuitBreakerOptions
+
+	lock          sync.Mutex
+	state         CircuitBreakerState
+	windowStart   time.Time // Start of the current rolling window
+	windowOps     int       // Operations completed in the current rolling window
+	windowErrors  int       // Failed operations in the current rolling window
+	openedAt      time.Time // When the breaker last opened
+	trialInFlight bool      // Whether the half-open trial operation has been allowed
+
+	statsLock sync.RWMutex
+	stats     CircuitBreakerStats
+}
+
+// NewCircuitBreaker returns a closed CircuitBreaker. name identifies the datastore in logging.
+func NewCircuitBreaker(logCtx context.Context, name string, options CircuitBreakerOptions) *CircuitBreaker {
+	if options.ErrorThresholdPercent <= 0 {
+		options.ErrorThresholdPercent = DefaultCircuitBreakerErrorThresholdPercent
+	}
+	if options.VolumeThreshold <= 0 {
+		options.VolumeThreshold = DefaultCircuitBreakerVolumeThreshold
+	}
+	if options.RollingWindow <= 0 {
+		options.RollingWindow = DefaultCircuitBreakerRollingWindow
+	}
+	if options.SleepWindow <= 0 {
+		options.SleepWindow = DefaultCircuitBreakerSleepWindow
+	}
+	return &CircuitBreaker{
+		logCtx:      logCtx,
+		name:        name,
+		options:     options,
+		windowStart: time.Now(),
+	}
+}
+
+// SetStats sets the stats updated by the breaker.
+func (cb *CircuitBreaker) SetStats(stats CircuitBreakerStats) {
+	if cb == nil {
+		return
+	}
+	cb.statsLock.Lock()
+	cb.stats = stats
+	cb.statsLock.Unlock()
+	if stats.State != nil {
+		stats.State.Set(int64(cb.State()))
+	}
+}
+
+// State returns the breaker's current state.
+func (cb *CircuitBreaker) State() CircuitBreakerState {
+	if cb == nil {
+		return CircuitBreakerClosed
+	}
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	return cb.state
+}
+
+// OpBudget returns the maximum time an operation waits to start.
+func (cb *CircuitBreaker) OpBudget() time.Duration {
+	if cb == nil {
+		return 0
+	}
+	return cb.options.OpBudget
+}
+
+// Allow returns a RetryAfterError if an operation should be rejected. Every allowed operation must be followed by a
+// call to Record with its outcome.
+func (cb *CircuitBreaker) Allow() error {
+	if cb == nil {
+		return nil
+	}
+	cb.lock.Lock()
+	now := time.Now()
+	switch cb.state {
+	case CircuitBreakerClosed:
+		cb.lock.Unlock()
+		return nil
+	case CircuitBreakerOpen:
+		if remaining := cb.options.SleepWindow - now.Sub(cb.openedAt); remaining > 0 {
+			cb.lock.Unlock()
+			return cb.rejectOpen(remaining)
+		}
+		cb.setState_(CircuitBreakerHalfOpen)
+		cb.trialInFlight = true
+		cb.lock.Unlock()
+		return nil
+	default:
+		if cb.trialInFlight {
+			cb.lock.Unlock()
+			return cb.rejectOpen(cb.options.SleepWindow)
+		}
+		cb.trialInFlight = true
+		cb.lock.Unlock()
+		return nil
+	}
+}
+
+// Record records the outcome of an operation allowed by Allow.
+func (cb *CircuitBreaker) Record(err error) {
+	if cb == nil {
+		return
+	}
+	failed := IsCircuitBreakerFailure(err)
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	now := time.Now()
+	switch cb.state {
+	case CircuitBreakerOpen:
+		// Operations started before the breaker opened don't affect it
+	case CircuitBreakerHalfOpen:
+		cb.trialInFlight = false
+		if failed {
+			cb.open_(now)
+		} else {
+			cb.resetWindow_(now)
+			cb.setState_(CircuitBreakerClosed)
+			InfofCtx(cb.logCtx, KeyAll, "Circuit breaker for %s closed", MD(cb.name))
+		}
+	default:
+		if now.Sub(cb.windowStart) >= cb.options.RollingWindow {
+			cb.resetWindow_(now)
+		}
+		cb.windowOps++
+		if failed {
+			cb.windowErrors++
+		}
+		if cb.windowOps >= cb.options.VolumeThreshold && float64(cb.windowErrors)*100 >= cb.options.ErrorThresholdPercent*float64(cb.windowOps) {
+			cb.open_(now)
+		}
+	}
+}
+
+// RejectOverBudget rejects an operation that wasn't able to start within the op budget, and counts it as a failure.
+func (cb *CircuitBreaker) RejectOverBudget() error {
+	if cb == nil {
+		return &RetryAfterError{Message: ErrKVOpBudgetExceeded.Error(), RetryAfter: time.Second}
+	}
+	cb.Record(ErrKVOpBudgetExceeded)
+	return cb.reject(fmt.Sprintf("Database is temporarily unavailable: operation on %s timed out waiting to start", MD(cb.name).Redact()), time.Second)
+}
+
+// reject counts a rejected operation, and returns the error for it.
+func (cb *CircuitBreaker) reject(message string, retryAfter time.Duration) error {
+	cb.statsLock.RLock()
+	if cb.stats.Rejected != nil {
+		cb.stats.Rejected.Add(1)
+	}
+	cb.statsLock.RUnlock()
+	return &RetryAfterError{Message: message, RetryAfter: retryAfter}
+}
+
+// rejectOpen rejects an operation while the breaker is open or a half-open trial is in progress.
+func (cb *CircuitBreaker) rejectOpen(retryAfter time.Duration) error {
+	return cb.reject(fmt.Sprintf("Database is temporarily unavailable: operations on %s are being rejected after repeated timeouts", MD(cb.name).Redact()), retryAfter)
+}
+
+// open_ opens the breaker. Requires the breaker lock.
+func (cb *CircuitBreaker) open_(now time.Time) {
+	WarnfCtx(cb.logCtx, "Circuit breaker for %s opened after %d of %d operations failed, rejecting operations for %v", MD(cb.name), cb.windowErrors, cb.windowOps, cb.options.SleepWindow)
+	cb.openedAt = now
+	cb.resetWindow_(now)
+	cb.setState_(CircuitBreakerOpen)
+	cb.statsLock.RLock()
+	if cb.stats.Opens != nil {
+		cb.stats.Opens.Add(1)
+	}
+	cb.statsLock.RUnlock()
+}
+
+// setState_ sets the breaker's state. Requires the breaker lock.
+func (cb *CircuitBreaker) setState_(state CircuitBreakerState) {
+	cb.state = state
+	cb.statsLock.RLock()
+	if cb.stats.State != nil {
+		cb.stats.State.Set(int64(state))
+	}
+	cb.statsLock.RUnlock()
+}
+
+// resetWindow_ starts a new rolling window. Requires the breaker lock.
+func (cb *CircuitBreaker) resetWindow_(now time.Time) {
+	cb.windowStart = now
+	cb.windowOps = 0
+	cb.windowErrors = 0
+}
+
+// ErrKVOpBudgetExceeded is recorded when a KV operation isn't able to start within its timeout budget.
+var ErrKVOpBudgetExceeded = errors.New("KV operation exceeded its timeout budget waiting to start")
+
+// IsCircuitBreakerFailure returns true if an operation's error indicates the datastore is failing or unresponsive.
+// Errors that are expected outcomes of an operation, such as a document not being found or a CAS mismatch, aren't
+// failures.
+func IsCircuitBreakerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, gocb.ErrTimeout) ||
+		errors.Is(err, gocb.ErrTemporaryFailure) ||
+		errors.Is(err, gocb.ErrOverload) ||
+		errors.Is(err, ErrKVOpBudgetExceeded)
+}
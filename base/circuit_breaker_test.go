@@ -0,0 +1,99 @@
+/*
+Copyright 2023-Present Couchbase, Inc.
+
+Use of this software is governed by the Business Source License included in
+the file licenses/BSL-Couchbase.txt.  As of the Change Date specified in that
+file, in accordance with the Business Source License, use of this software will
+be governed by the Apache License, Version 2.0, included in the file
+licenses/APL2.txt.
+*/
+
+package base
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := NewCircuitBreaker(TestCtx(t), "bucket.scope.collection", CircuitBreakerOptions{
+		ErrorThresholdPercent: 50,
+		VolumeThreshold:       4,
+		RollingWindow:         time.Minute,
+		SleepWindow:           50 * time.Millisecond,
+	})
+	stats := CircuitBreakerStats{State: &SgwIntStat{}, Opens: &SgwIntStat{}, Rejected: &SgwIntStat{}}
+	breaker.SetStats(stats)
+
+	// Errors that aren't failures don't open the breaker
+	for i := 0; i < 4; i++ {
+		require.NoError(t, breaker.Allow())
+		breaker.Record(ErrNotFound)
+	}
+	assert.Equal(t, CircuitBreakerClosed, breaker.State())
+
+	// Below the volume threshold, failures don't open the breaker
+	require.NoError(t, breaker.Allow())
+	breaker.Record(gocb.ErrTimeout)
+	assert.Equal(t, CircuitBreakerClosed, breaker.State())
+
+	// Failures reaching the threshold within the window open the breaker
+	for i := 0; i < 3; i++ {
+		require.NoError(t, breaker.Allow())
+		breaker.Record(gocb.ErrTimeout)
+	}
+	assert.Equal(t, CircuitBreakerOpen, breaker.State())
+	assert.Equal(t, int64(CircuitBreakerOpen), stats.State.Value())
+	assert.Equal(t, int64(1), stats.Opens.Value())
+
+	// Operations are rejected while open
+	err := breaker.Allow()
+	var retryAfterErr *RetryAfterError
+	require.ErrorAs(t, err, &retryAfterErr)
+	assert.Greater(t, retryAfterErr.RetryAfter, time.Duration(0))
+	assert.Equal(t, int64(1), stats.Rejected.Value())
+	status, _ := ErrorAsHTTPStatus(err)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+
+	// After the sleep window a single trial is allowed, and a failure reopens the breaker
+	time.Sleep(60 * time.Millisecond)
+	require.NoError(t, breaker.Allow())
+	assert.Equal(t, CircuitBreakerHalfOpen, breaker.State())
+	assert.Error(t, breaker.Allow())
+	breaker.Record(gocb.ErrOverload)
+	assert.Equal(t, CircuitBreakerOpen, breaker.State())
+	assert.Equal(t, int64(2), stats.Opens.Value())
+
+	// A successful trial closes the breaker
+	time.Sleep(60 * time.Millisecond)
+	require.NoError(t, breaker.Allow())
+	breaker.Record(nil)
+	assert.Equal(t, CircuitBreakerClosed, breaker.State())
+	assert.Equal(t, int64(CircuitBreakerClosed), stats.State.Value())
+	require.NoError(t, breaker.Allow())
+}
+
+func TestCircuitBreakerRejectOverBudget(t *testing.T) {
+	breaker := NewCircuitBreaker(TestCtx(t), "bucket.scope.collection", CircuitBreakerOptions{
+		OpBudget:        time.Millisecond,
+		VolumeThreshold: 2,
+	})
+	for i := 0; i < 2; i++ {
+		err := breaker.RejectOverBudget()
+		var retryAfterErr *RetryAfterError
+		require.True(t, errors.As(err, &retryAfterErr))
+	}
+	assert.Equal(t, CircuitBreakerOpen, breaker.State())
+
+	// A nil breaker allows all operations
+	var nilBreaker *CircuitBreaker
+	assert.NoError(t, nilBreaker.Allow())
+	nilBreaker.Record(gocb.ErrTimeout)
+	assert.Equal(t, CircuitBreakerClosed, nilBreaker.State())
+}
@@ -112,6 +112,7 @@ func (cl *ClusterOnlyN1QLStore) Query(ctx context.Context, statement string, par
 		ScanConsistency: gocb.QueryScanConsistency(consistency),
 		Adhoc:           adhoc,
 		NamedParameters: params,
+		Context:         ctx,
 	}
 
 	waitTime := 10 * time.Millisecond
@@ -126,6 +127,11 @@ func (cl *ClusterOnlyN1QLStore) Query(ctx context.Context, statement string, par
 			return resultsIterator, queryErr
 		}
 
+		// Cancelled by the caller - return the context's error
+		if ctx.Err() != nil {
+			return resultsIterator, ctx.Err()
+		}
+
 		// Timeout error - return named error
 		if errors.Is(queryErr, gocb.ErrTimeout) {
 			return resultsIterator, ErrViewTimeoutError
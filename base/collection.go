@@ -182,6 +182,9 @@ type GocbV2Bucket struct {
 	queryOps                                             chan struct{} // Manages max concurrent query ops
 	kvOps                                                chan struct{} // Manages max concurrent kv ops
 	clusterCompatMajorVersion, clusterCompatMinorVersion uint64        // E.g: 6 and 0 for 6.0.3
+
+	kvCircuitBreakers     map[string]*CircuitBreaker // KV op circuit breakers by collection, when Spec.KvCircuitBreaker is set
+	kvCircuitBreakersLock sync.Mutex
 }
 
 var (
@@ -494,8 +497,26 @@ func (b *GocbV2Bucket) mgmtRequest(ctx context.Context, method, uri, contentType
 }
 
 // This prevents Sync Gateway from overflowing gocb's pipeline
-func (b *GocbV2Bucket) waitForAvailKvOp() {
-	b.kvOps <- struct{}{}
+// waitForAvailKvOp waits for a KV op slot, returning false if one doesn't become available within the budget. A zero
+// budget waits indefinitely.
+func (b *GocbV2Bucket) waitForAvailKvOp(budget time.Duration) bool {
+	if budget <= 0 {
+		b.kvOps <- struct{}{}
+		return true
+	}
+	select {
+	case b.kvOps <- struct{}{}:
+		return true
+	default:
+	}
+	timer := time.NewTimer(budget)
+	defer timer.Stop()
+	select {
+	case b.kvOps <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
 }
 
 func (b *GocbV2Bucket) releaseKvOp() {
@@ -625,7 +646,28 @@ func (b *GocbV2Bucket) DefaultDataStore() sgbucket.DataStore {
 	return &Collection{
 		Bucket:     b,
 		Collection: b.bucket.DefaultCollection(),
+		breaker:    b.kvCircuitBreaker(DefaultScope, DefaultCollection),
+	}
+}
+
+// kvCircuitBreaker returns the circuit breaker shared by KV ops on the given collection, or nil if circuit breaking
+// isn't enabled for the bucket.
+func (b *GocbV2Bucket) kvCircuitBreaker(scopeName, collectionName string) *CircuitBreaker {
+	if b.Spec.KvCircuitBreaker == nil {
+		return nil
+	}
+	name := FullyQualifiedCollectionName(b.GetName(), scopeName, collectionName)
+	b.kvCircuitBreakersLock.Lock()
+	defer b.kvCircuitBreakersLock.Unlock()
+	if b.kvCircuitBreakers == nil {
+		b.kvCircuitBreakers = make(map[string]*CircuitBreaker)
+	}
+	breaker, ok := b.kvCircuitBreakers[name]
+	if !ok {
+		breaker = NewCircuitBreaker(bucketNameCtx(context.Background(), b.GetName()), name, *b.Spec.KvCircuitBreaker)
+		b.kvCircuitBreakers[name] = breaker
 	}
+	return breaker
 }
 
 // NamedDataStore returns a collection on a bucket within the given scope and collection.
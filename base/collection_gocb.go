@@ -39,7 +39,8 @@ const (
 type Collection struct {
 	Bucket         *GocbV2Bucket
 	Collection     *gocb.Collection
-	kvCollectionID uint32 // cached copy of KV's collection ID for this collection
+	kvCollectionID uint32          // cached copy of KV's collection ID for this collection
+	breaker        *CircuitBreaker // KV op circuit breaker for this collection, nil when not enabled
 }
 
 // Ensure that Collection implements sgbucket.DataStore/N1QLStore
@@ -61,7 +62,9 @@ func AsCollection(dataStore DataStore) (*Collection, error) {
 func NewCollection(bucket *GocbV2Bucket, collection *gocb.Collection) (*Collection, error) {
 	c := &Collection{
 		Bucket:     bucket,
-		Collection: collection}
+		Collection: collection,
+		breaker:    bucket.kvCircuitBreaker(collection.ScopeName(), collection.Name()),
+	}
 	err := c.setCollectionID()
 	if err != nil {
 		return nil, err
@@ -69,6 +72,30 @@ func NewCollection(bucket *GocbV2Bucket, collection *gocb.Collection) (*Collecti
 	return c, nil
 }
 
+// SetCircuitBreakerStats sets the stats updated by the collection's KV op circuit breaker, if enabled.
+func (c *Collection) SetCircuitBreakerStats(stats CircuitBreakerStats) {
+	c.breaker.SetStats(stats)
+}
+
+// startKvOp waits for a KV op slot on the bucket. When circuit breaking is enabled, fails fast with a RetryAfterError
+// while the collection's circuit breaker is open, or if a slot doesn't become available within the op budget.
+// Each successful call must be followed by finishKvOp.
+func (c *Collection) startKvOp() error {
+	if err := c.breaker.Allow(); err != nil {
+		return err
+	}
+	if !c.Bucket.waitForAvailKvOp(c.breaker.OpBudget()) {
+		return c.breaker.RejectOverBudget()
+	}
+	return nil
+}
+
+// finishKvOp releases the KV op slot taken by startKvOp, and records the op's outcome in the circuit breaker.
+func (c *Collection) finishKvOp(err error) {
+	c.Bucket.releaseKvOp()
+	c.breaker.Record(err)
+}
+
 // CollectionName returns the collection name
 func (c *Collection) CollectionName() string {
 	return c.Collection.Name()
@@ -92,8 +119,10 @@ func (c *Collection) GetName() string {
 
 func (c *Collection) Get(k string, rv interface{}) (cas uint64, err error) {
 
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+	if err = c.startKvOp(); err != nil {
+		return 0, err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	getOptions := &gocb.GetOptions{
 		Transcoder: NewSGJSONTranscoder(),
@@ -107,8 +136,10 @@ func (c *Collection) Get(k string, rv interface{}) (cas uint64, err error) {
 }
 
 func (c *Collection) GetRaw(k string) (rv []byte, cas uint64, err error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+	if err = c.startKvOp(); err != nil {
+		return nil, 0, err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	getOptions := &gocb.GetOptions{
 		Transcoder: NewSGRawTranscoder(),
@@ -123,8 +154,10 @@ func (c *Collection) GetRaw(k string) (rv []byte, cas uint64, err error) {
 }
 
 func (c *Collection) GetAndTouchRaw(k string, exp uint32) (rv []byte, cas uint64, err error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+	if err = c.startKvOp(); err != nil {
+		return nil, 0, err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	getAndTouchOptions := &gocb.GetAndTouchOptions{
 		Transcoder: NewSGRawTranscoder(),
@@ -139,8 +172,10 @@ func (c *Collection) GetAndTouchRaw(k string, exp uint32) (rv []byte, cas uint64
 }
 
 func (c *Collection) Touch(k string, exp uint32) (cas uint64, err error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+	if err = c.startKvOp(); err != nil {
+		return 0, err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	result, err := c.Collection.Touch(k, CbsExpiryToDuration(exp), nil)
 	if err != nil {
@@ -150,8 +185,10 @@ func (c *Collection) Touch(k string, exp uint32) (cas uint64, err error) {
 }
 
 func (c *Collection) Add(k string, exp uint32, v interface{}) (added bool, err error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+	if err = c.startKvOp(); err != nil {
+		return false, err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	opts := &gocb.InsertOptions{
 		Expiry:     CbsExpiryToDuration(exp),
@@ -169,8 +206,10 @@ func (c *Collection) Add(k string, exp uint32, v interface{}) (added bool, err e
 }
 
 func (c *Collection) AddRaw(k string, exp uint32, v []byte) (added bool, err error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+	if err = c.startKvOp(); err != nil {
+		return false, err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	opts := &gocb.InsertOptions{
 		Expiry:     CbsExpiryToDuration(exp),
@@ -187,9 +226,11 @@ func (c *Collection) AddRaw(k string, exp uint32, v []byte) (added bool, err err
 	return err == nil, err
 }
 
-func (c *Collection) Set(k string, exp uint32, opts *sgbucket.UpsertOptions, v interface{}) error {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+func (c *Collection) Set(k string, exp uint32, opts *sgbucket.UpsertOptions, v interface{}) (err error) {
+	if err = c.startKvOp(); err != nil {
+		return err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	goCBUpsertOptions := &gocb.UpsertOptions{
 		Expiry:     CbsExpiryToDuration(exp),
@@ -201,13 +242,15 @@ func (c *Collection) Set(k string, exp uint32, opts *sgbucket.UpsertOptions, v i
 		goCBUpsertOptions.Transcoder = gocb.NewRawJSONTranscoder()
 	}
 
-	_, err := c.Collection.Upsert(k, v, goCBUpsertOptions)
+	_, err = c.Collection.Upsert(k, v, goCBUpsertOptions)
 	return err
 }
 
-func (c *Collection) SetRaw(k string, exp uint32, opts *sgbucket.UpsertOptions, v []byte) error {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+func (c *Collection) SetRaw(k string, exp uint32, opts *sgbucket.UpsertOptions, v []byte) (err error) {
+	if err = c.startKvOp(); err != nil {
+		return err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	goCBUpsertOptions := &gocb.UpsertOptions{
 		Expiry:     CbsExpiryToDuration(exp),
@@ -215,13 +258,15 @@ func (c *Collection) SetRaw(k string, exp uint32, opts *sgbucket.UpsertOptions,
 	}
 	fillUpsertOptions(goCBUpsertOptions, opts)
 
-	_, err := c.Collection.Upsert(k, v, goCBUpsertOptions)
+	_, err = c.Collection.Upsert(k, v, goCBUpsertOptions)
 	return err
 }
 
 func (c *Collection) WriteCas(k string, flags int, exp uint32, cas uint64, v interface{}, opt sgbucket.WriteOptions) (casOut uint64, err error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+	if err = c.startKvOp(); err != nil {
+		return 0, err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	var result *gocb.MutationResult
 	if cas == 0 {
@@ -256,8 +301,10 @@ func (c *Collection) Delete(k string) error {
 }
 
 func (c *Collection) Remove(k string, cas uint64) (casOut uint64, err error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+	if err = c.startKvOp(); err != nil {
+		return 0, err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	result, errRemove := c.Collection.Remove(k, &gocb.RemoveOptions{Cas: gocb.Cas(cas)})
 	if errRemove == nil && result != nil {
@@ -279,9 +326,11 @@ func (c *Collection) Update(k string, exp uint32, callback sgbucket.UpdateFunc)
 
 		var cas uint64
 
-		c.Bucket.waitForAvailKvOp()
+		if err = c.startKvOp(); err != nil {
+			return 0, err
+		}
 		getResult, err := c.Collection.Get(k, getOptions)
-		c.Bucket.releaseKvOp()
+		c.finishKvOp(err)
 
 		if err != nil {
 			if !errors.Is(err, gocb.ErrDocumentNotFound) {
@@ -312,7 +361,9 @@ func (c *Collection) Update(k string, exp uint32, callback sgbucket.UpdateFunc)
 		var result *gocb.MutationResult
 		casRetry := false
 
-		c.Bucket.waitForAvailKvOp()
+		if err = c.startKvOp(); err != nil {
+			return 0, err
+		}
 		if cas == 0 {
 			// If the Get fails, the cas will be 0 and so call Insert().
 			// If we get an error on the insert, due to a race, this will
@@ -354,7 +405,7 @@ func (c *Collection) Update(k string, exp uint32, callback sgbucket.UpdateFunc)
 				}
 			}
 		}
-		c.Bucket.releaseKvOp()
+		c.finishKvOp(err)
 
 		if casRetry {
 			// retry on cas failure
@@ -365,9 +416,11 @@ func (c *Collection) Update(k string, exp uint32, callback sgbucket.UpdateFunc)
 	}
 }
 
-func (c *Collection) Incr(k string, amt, def uint64, exp uint32) (uint64, error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+func (c *Collection) Incr(k string, amt, def uint64, exp uint32) (value uint64, err error) {
+	if err = c.startKvOp(); err != nil {
+		return 0, err
+	}
+	defer func() { c.finishKvOp(err) }()
 	incrOptions := gocb.IncrementOptions{
 		Initial: int64(def),
 		Delta:   amt,
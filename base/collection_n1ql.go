@@ -88,6 +88,7 @@ func (c *Collection) Query(ctx context.Context, statement string, params map[str
 		ScanConsistency: gocb.QueryScanConsistency(consistency),
 		Adhoc:           adhoc,
 		NamedParameters: params,
+		Context:         ctx,
 	}
 
 	waitTime := 10 * time.Millisecond
@@ -102,6 +103,11 @@ func (c *Collection) Query(ctx context.Context, statement string, params map[str
 			return resultsIterator, queryErr
 		}
 
+		// Cancelled by the caller - return the context's error
+		if ctx.Err() != nil {
+			return resultsIterator, ctx.Err()
+		}
+
 		// Timeout error - return named error
 		if errors.Is(queryErr, gocb.ErrTimeout) {
 			return resultsIterator, ErrViewTimeoutError
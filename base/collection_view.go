@@ -266,6 +266,12 @@ func (c *Collection) executeViewQuery(ctx context.Context, ddoc, name string, pa
 	c.Bucket.waitForAvailQueryOp()
 	goCbViewResult, err := c.Collection.Bucket().ViewQuery(ddoc, name, viewOpts)
 
+	// On cancellation by the caller, return the context's error
+	if err != nil && ctx.Err() != nil {
+		c.Bucket.releaseQueryOp()
+		return nil, ctx.Err()
+	}
+
 	// On timeout, return an typed error
 	if isGoCBQueryTimeoutError(err) {
 		c.Bucket.releaseQueryOp()
@@ -281,7 +287,7 @@ func (c *Collection) executeViewQuery(ctx context.Context, ddoc, name string, pa
 // Applies the viewquery options as specified in the params map to the gocb.ViewOptions
 func createViewOptions(ctx context.Context, params map[string]interface{}) (viewOpts *gocb.ViewOptions, err error) {
 
-	viewOpts = &gocb.ViewOptions{}
+	viewOpts = &gocb.ViewOptions{Context: ctx}
 	for optionName, optionValue := range params {
 		switch optionName {
 		case ViewQueryParamStale:
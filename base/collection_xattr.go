@@ -93,8 +93,10 @@ func (c *Collection) DeleteXattrs(ctx context.Context, k string, xattrKeys ...st
 //   - gocb v2 returns subdoc errors at the op level, in the ContentAt response
 //   - 'successful' error codes, like SucDocSuccessDeleted, aren't returned, and instead just set the internal.Deleted property on the response
 func (c *Collection) SubdocGetXattr(ctx context.Context, k string, xattrKey string, xv interface{}) (casOut uint64, err error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+	if err = c.startKvOp(); err != nil {
+		return 0, err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	ops := []gocb.LookupInSpec{
 		gocb.GetSpec(xattrKey, GetSpecXattr),
@@ -118,8 +120,9 @@ func (c *Collection) SubdocGetXattr(ctx context.Context, k string, xattrKey stri
 }
 
 func (c *Collection) SubdocGetRaw(ctx context.Context, k string, subdocKey string) ([]byte, uint64, error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+	if err := c.startKvOp(); err != nil {
+		return nil, 0, err
+	}
 
 	var rawValue []byte
 
@@ -151,6 +154,7 @@ func (c *Collection) SubdocGetRaw(ctx context.Context, k string, subdocKey strin
 	}
 
 	err, casOut := RetryLoopCas(ctx, "SubdocGetRaw", worker, DefaultRetrySleeper())
+	c.finishKvOp(err)
 	if err != nil {
 		err = pkgerrors.Wrapf(err, "SubdocGetRaw with key %s and subdocKey %s", UD(k).Redact(), UD(subdocKey).Redact())
 	}
@@ -159,8 +163,9 @@ func (c *Collection) SubdocGetRaw(ctx context.Context, k string, subdocKey strin
 }
 
 func (c *Collection) SubdocWrite(ctx context.Context, k string, subdocKey string, cas uint64, value []byte) (uint64, error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+	if err := c.startKvOp(); err != nil {
+		return 0, err
+	}
 
 	worker := func() (shouldRetry bool, err error, casOut uint64) {
 		mutateOps := []gocb.MutateInSpec{
@@ -184,6 +189,7 @@ func (c *Collection) SubdocWrite(ctx context.Context, k string, subdocKey string
 	}
 
 	err, casOut := RetryLoopCas(ctx, "SubdocWrite", worker, DefaultRetrySleeper())
+	c.finishKvOp(err)
 	if err != nil {
 		err = pkgerrors.Wrapf(err, "SubdocWrite with key %s and subdocKey %s", UD(k).Redact(), UD(subdocKey).Redact())
 	}
@@ -195,8 +201,10 @@ func (c *Collection) SubdocWrite(ctx context.Context, k string, subdocKey string
 func (c *Collection) SubdocGetBodyAndXattr(ctx context.Context, k string, xattrKey string, userXattrKey string, rv interface{}, xv interface{}, uxv interface{}) (cas uint64, err error) {
 	worker := func() (shouldRetry bool, err error, value uint64) {
 
-		c.Bucket.waitForAvailKvOp()
-		defer c.Bucket.releaseKvOp()
+		if err = c.startKvOp(); err != nil {
+			return false, err, 0
+		}
+		defer func() { c.finishKvOp(err) }()
 
 		// First, attempt to get the document and xattr in one shot.
 		ops := []gocb.LookupInSpec{
@@ -283,8 +291,10 @@ func (c *Collection) SubdocGetBodyAndXattr(ctx context.Context, k string, xattrK
 // InsertXattr inserts a new server tombstone with an associated mobile xattr.  Writes cas and crc32c to the xattr using
 // macro expansion.
 func (c *Collection) InsertXattr(_ context.Context, k string, xattrKey string, exp uint32, cas uint64, xv interface{}, opts *sgbucket.MutateInOptions) (casOut uint64, err error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+	if err = c.startKvOp(); err != nil {
+		return 0, err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	supportsTombstoneCreation := c.IsSupported(sgbucket.BucketStoreFeatureCreateDeletedWithXattr)
 
@@ -315,8 +325,10 @@ func (c *Collection) InsertXattr(_ context.Context, k string, xattrKey string, e
 // InsertBodyAndXattr inserts a document and associated mobile xattr in a single mutateIn operation.  Writes cas and crc32c to the xattr using
 // macro expansion.
 func (c *Collection) InsertBodyAndXattr(_ context.Context, k string, xattrKey string, exp uint32, v interface{}, xv interface{}, opts *sgbucket.MutateInOptions) (casOut uint64, err error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+	if err = c.startKvOp(); err != nil {
+		return 0, err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	mutateOps := []gocb.MutateInSpec{
 		gocb.UpsertSpec(xattrKey, bytesToRawMessage(xv), UpsertSpecXattr),
@@ -336,9 +348,11 @@ func (c *Collection) InsertBodyAndXattr(_ context.Context, k string, xattrKey st
 }
 
 // SubdocInsert performs a subdoc insert operation to the specified path in the document body.
-func (c *Collection) SubdocInsert(_ context.Context, k string, fieldPath string, cas uint64, value interface{}) error {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+func (c *Collection) SubdocInsert(_ context.Context, k string, fieldPath string, cas uint64, value interface{}) (err error) {
+	if err = c.startKvOp(); err != nil {
+		return err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	mutateOps := []gocb.MutateInSpec{
 		gocb.InsertSpec(fieldPath, value, nil),
@@ -385,8 +399,10 @@ func (c *Collection) SubdocSetXattr(k string, xattrKey string, xv interface{}) (
 // UpdateXattr updates the xattr on an existing document. Writes cas and crc32c to the xattr using
 // macro expansion.
 func (c *Collection) UpdateXattr(_ context.Context, k string, xattrKey string, exp uint32, cas uint64, xv interface{}, opts *sgbucket.MutateInOptions) (casOut uint64, err error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+	if err = c.startKvOp(); err != nil {
+		return 0, err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	mutateOps := []gocb.MutateInSpec{
 		gocb.UpsertSpec(xattrKey, bytesToRawMessage(xv), UpsertSpecXattr),
@@ -410,8 +426,10 @@ func (c *Collection) UpdateXattr(_ context.Context, k string, xattrKey string, e
 // UpdateBodyAndXattr updates the document body and xattr of an existing document. Writes cas and crc32c to the xattr using
 // macro expansion.
 func (c *Collection) UpdateBodyAndXattr(_ context.Context, k string, xattrKey string, exp uint32, cas uint64, opts *sgbucket.MutateInOptions, v interface{}, xv interface{}) (casOut uint64, err error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+	if err = c.startKvOp(); err != nil {
+		return 0, err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	mutateOps := []gocb.MutateInSpec{
 		gocb.UpsertSpec(xattrKey, bytesToRawMessage(xv), UpsertSpecXattr),
@@ -435,8 +453,10 @@ func (c *Collection) UpdateBodyAndXattr(_ context.Context, k string, xattrKey st
 // UpdateXattrDeleteBody deletes the document body and updates the xattr of an existing document. Writes cas and crc32c to the xattr using
 // macro expansion.
 func (c *Collection) UpdateXattrDeleteBody(_ context.Context, k, xattrKey string, exp uint32, cas uint64, xv interface{}, opts *sgbucket.MutateInOptions) (casOut uint64, err error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+	if err = c.startKvOp(); err != nil {
+		return 0, err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	mutateOps := []gocb.MutateInSpec{
 		gocb.UpsertSpec(xattrKey, bytesToRawMessage(xv), UpsertSpecXattr),
@@ -457,8 +477,10 @@ func (c *Collection) UpdateXattrDeleteBody(_ context.Context, k, xattrKey string
 
 // SubdocDeleteXattr deletes an xattr of an existing document (or document tombstone)
 func (c *Collection) SubdocDeleteXattr(k string, xattrKey string, cas uint64) (err error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+	if err = c.startKvOp(); err != nil {
+		return err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	mutateOps := []gocb.MutateInSpec{
 		gocb.RemoveSpec(xattrKey, RemoveSpecXattr),
@@ -473,9 +495,11 @@ func (c *Collection) SubdocDeleteXattr(k string, xattrKey string, cas uint64) (e
 }
 
 // SubdocDeleteXattrs will delete the supplied xattr keys from a document. Not a cas safe operation.
-func (c *Collection) SubdocDeleteXattrs(k string, xattrKeys ...string) error {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+func (c *Collection) SubdocDeleteXattrs(k string, xattrKeys ...string) (err error) {
+	if err = c.startKvOp(); err != nil {
+		return err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	mutateOps := make([]gocb.MutateInSpec, 0, len(xattrKeys))
 	for _, xattrKey := range xattrKeys {
@@ -489,8 +513,10 @@ func (c *Collection) SubdocDeleteXattrs(k string, xattrKeys ...string) error {
 
 // SubdocDeleteXattr deletes the document body and associated xattr of an existing document.
 func (c *Collection) DeleteBodyAndXattr(_ context.Context, k string, xattrKey string) (err error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+	if err = c.startKvOp(); err != nil {
+		return err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	mutateOps := []gocb.MutateInSpec{
 		gocb.RemoveSpec(xattrKey, RemoveSpecXattr),
@@ -518,8 +544,10 @@ func (c *Collection) DeleteBodyAndXattr(_ context.Context, k string, xattrKey st
 
 // DeleteBody deletes the document body of an existing document, and updates cas and crc32c in the associated xattr.
 func (c *Collection) DeleteBody(_ context.Context, k string, xattrKey string, exp uint32, cas uint64, opts *sgbucket.MutateInOptions) (casOut uint64, err error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+	if err = c.startKvOp(); err != nil {
+		return 0, err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	mutateOps := []gocb.MutateInSpec{
 		gocb.RemoveSpec("", nil),
@@ -579,9 +607,11 @@ func bytesToRawMessage(v interface{}) interface{} {
 	}
 }
 
-func (c *Collection) WriteUserXattr(k string, xattrKey string, xattrVal interface{}) (uint64, error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+func (c *Collection) WriteUserXattr(k string, xattrKey string, xattrVal interface{}) (casOut uint64, err error) {
+	if err = c.startKvOp(); err != nil {
+		return 0, err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	mutateOps := []gocb.MutateInSpec{
 		gocb.UpsertSpec(xattrKey, bytesToRawMessage(xattrVal), UpsertSpecXattr),
@@ -597,9 +627,11 @@ func (c *Collection) WriteUserXattr(k string, xattrKey string, xattrVal interfac
 	return uint64(result.Cas()), nil
 }
 
-func (c *Collection) DeleteUserXattr(k string, xattrKey string) (uint64, error) {
-	c.Bucket.waitForAvailKvOp()
-	defer c.Bucket.releaseKvOp()
+func (c *Collection) DeleteUserXattr(k string, xattrKey string) (casOut uint64, err error) {
+	if err = c.startKvOp(); err != nil {
+		return 0, err
+	}
+	defer func() { c.finishKvOp(err) }()
 
 	mutateOps := []gocb.MutateInSpec{
 		gocb.RemoveSpec(xattrKey, RemoveSpecXattr),
@@ -39,6 +39,14 @@ const (
 	MetaKeyRolePrefix                                          // "role:"
 	MetaKeyUserEmailPrefix                                     // "useremail:"
 	MetaKeySessionPrefix                                       // "session:"
+	MetaKeyImportQuarantine                                    // "import_quarantine"
+	MetaKeyWebhookCursorPrefix                                 // "webhook_cursor:"
+	MetaKeyQuotaUsagePrefix                                    // "quota:"
+	MetaKeySeqFence                                            // "seqFence"
+	MetaKeySessionDeniedPrefix                                 // "session_denied:"
+	MetaKeyAccessSnapshotPrefix                                // "access_snapshot:"
+	MetaKeyStatsPrefix                                         // "stats:"
+	MetaKeyIdempotencyPrefix                                   // "idempotency:"
 )
 
 var metadataKeyNames = []string{
@@ -56,6 +64,14 @@ var metadataKeyNames = []string{
 	"role:",                         // stores a role
 	"useremail:",                    // stores a role
 	"session:",                      // stores a session
+	"import_quarantine",             // stores documents quarantined after repeatedly failing import
+	"webhook_cursor:",               // stores the last sequence delivered to an at-least-once webhook
+	"quota:",                        // counter documents storing a user's daily request quota usage
+	"seqFence",                      // stores the highest sequence allocated, to detect the sequence counter moving backwards
+	"session_denied:",               // marks a session revoked before its encrypted session cookie expires
+	"access_snapshot:",              // stores the history of a user's effective access, for auditing
+	"stats:",                        // stores a node's persisted counter stats, so they survive a restart
+	"idempotency:",                  // stores the response to a document write made with an idempotency key, to replay for retries
 
 }
 
@@ -110,6 +126,14 @@ type MetadataKeys struct {
 	rolePrefix                string
 	userEmailPrefix           string
 	sessionPrefix             string
+	importQuarantine          string
+	webhookCursorPrefix       string
+	quotaUsagePrefix          string
+	seqFence                  string
+	sessionDeniedPrefix       string
+	accessSnapshotPrefix      string
+	statsPrefix               string
+	idempotencyPrefix         string
 }
 
 // sha1HashLength is the number of characters in a sha1
@@ -133,6 +157,14 @@ var DefaultMetadataKeys = &MetadataKeys{
 	rolePrefix:                formatDefaultMetadataKey(MetaKeyRolePrefix),
 	userEmailPrefix:           formatDefaultMetadataKey(MetaKeyUserEmailPrefix),
 	sessionPrefix:             formatDefaultMetadataKey(MetaKeySessionPrefix),
+	importQuarantine:          formatDefaultMetadataKey(MetaKeyImportQuarantine),
+	webhookCursorPrefix:       formatDefaultMetadataKey(MetaKeyWebhookCursorPrefix),
+	quotaUsagePrefix:          formatDefaultMetadataKey(MetaKeyQuotaUsagePrefix),
+	seqFence:                  formatDefaultMetadataKey(MetaKeySeqFence),
+	sessionDeniedPrefix:       formatDefaultMetadataKey(MetaKeySessionDeniedPrefix),
+	accessSnapshotPrefix:      formatDefaultMetadataKey(MetaKeyAccessSnapshotPrefix),
+	statsPrefix:               formatDefaultMetadataKey(MetaKeyStatsPrefix),
+	idempotencyPrefix:         formatDefaultMetadataKey(MetaKeyIdempotencyPrefix),
 }
 
 // NewMetadataKeys returns MetadataKeys for the specified MetadataID  If metadataID is empty string, returns the default (legacy) metadata keys.
@@ -157,6 +189,14 @@ func NewMetadataKeys(metadataID string) *MetadataKeys {
 			rolePrefix:                formatInvertedMetadataKey(metadataID, MetaKeyRolePrefix),
 			userEmailPrefix:           formatInvertedMetadataKey(metadataID, MetaKeyUserEmailPrefix),
 			sessionPrefix:             formatInvertedMetadataKey(metadataID, MetaKeySessionPrefix),
+			importQuarantine:          formatMetadataKey(metadataID, MetaKeyImportQuarantine),
+			webhookCursorPrefix:       formatMetadataKey(metadataID, MetaKeyWebhookCursorPrefix),
+			quotaUsagePrefix:          formatMetadataKey(metadataID, MetaKeyQuotaUsagePrefix),
+			seqFence:                  formatMetadataKey(metadataID, MetaKeySeqFence),
+			sessionDeniedPrefix:       formatMetadataKey(metadataID, MetaKeySessionDeniedPrefix),
+			accessSnapshotPrefix:      formatMetadataKey(metadataID, MetaKeyAccessSnapshotPrefix),
+			statsPrefix:               formatMetadataKey(metadataID, MetaKeyStatsPrefix),
+			idempotencyPrefix:         formatMetadataKey(metadataID, MetaKeyIdempotencyPrefix),
 		}
 	}
 }
@@ -175,6 +215,14 @@ func (m *MetadataKeys) SyncSeqKey() string {
 	return m.syncSeq
 }
 
+// SeqFenceKey returns the key of the document recording the highest sequence allocated for a database, and the
+// current sequence epoch.
+//
+//	format: _sync:{m_$}:seqFence
+func (m *MetadataKeys) SeqFenceKey() string {
+	return m.seqFence
+}
+
 // UnusedSeqKey returns the key used to store an unused sequence document for sequence seq.
 // These documents are used to release sequences that are allocated but not used, so that they may be
 // accounted for by all SG nodes in the cluster.
@@ -324,6 +372,57 @@ func (m *MetadataKeys) BackgroundProcessStatusPrefix(processSuffix string) strin
 	return m.backgroundStatusPrefix + processSuffix
 }
 
+// ImportQuarantineKey returns the key of the document storing the documents quarantined after repeatedly failing import.
+//
+//	format: _sync:{m_$}:import_quarantine
+func (m *MetadataKeys) ImportQuarantineKey() string {
+	return m.importQuarantine
+}
+
+// WebhookCursorKey returns the key of the document storing the last sequence delivered to an at-least-once webhook.
+//
+//	format: _sync:{m_$}:webhook_cursor:{handlerID}
+func (m *MetadataKeys) WebhookCursorKey(handlerID string) string {
+	return m.webhookCursorPrefix + handlerID
+}
+
+// QuotaUsageKey returns the key of the counter document storing a user's usage of a request quota on a given day.
+//
+//	format: _sync:{m_$}:quota:{day}:{quota}:{username}
+func (m *MetadataKeys) QuotaUsageKey(day, quota, username string) string {
+	return m.quotaUsagePrefix + day + ":" + quota + ":" + m.serializeIfLonger(username)
+}
+
+// SessionDeniedKey returns the key of the document marking a session as revoked before its encrypted session cookie
+// expires.
+//
+//	format: _sync:{m_$}:session_denied:{sessionID}
+func (m *MetadataKeys) SessionDeniedKey(sessionID string) string {
+	return m.sessionDeniedPrefix + sessionID
+}
+
+// AccessSnapshotKey returns the key of the document storing the history of a user's effective access.
+//
+//	format: _sync:{m_$}:access_snapshot:{username}
+func (m *MetadataKeys) AccessSnapshotKey(username string) string {
+	return m.accessSnapshotPrefix + m.serializeIfLonger(username)
+}
+
+// StatsKey returns the key of the document storing a node's persisted counter stats.
+//
+//	format: _sync:{m_$}:stats:{nodeID}
+func (m *MetadataKeys) StatsKey(nodeID string) string {
+	return m.statsPrefix + m.serializeIfLonger(nodeID)
+}
+
+// IdempotencyKey returns the key of the document storing the response to a document write made with an idempotency
+// key, identified by a hash of the key.
+//
+//	format: _sync:{m_$}:idempotency:{keyHash}
+func (m *MetadataKeys) IdempotencyKey(keyHash string) string {
+	return m.idempotencyPrefix + keyHash
+}
+
 // formatMetadataKey formats key into the form _sync:m_[metadataID]:[metaKey]
 func formatMetadataKey(metadataPrefix string, metaKey metadataKey) string {
 	return SyncDocMetadataPrefix + metadataPrefix + ":" + metaKey.String()
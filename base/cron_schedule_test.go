@@ -0,0 +1,59 @@
+/*
+Copyright 2023-Present Couchbase, Inc.
+
+Use of this software is governed by the Business Source License included in
+the file licenses/BSL-Couchbase.txt.  As of the Change Date specified in that
+file, in accordance with the Business Source License, use of this software will
+be governed by the Apache License, Version 2.0, included in the file
+licenses/APL2.txt.
+*/
+
+package base
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronScheduleMatches(t *testing.T) {
+	// 2023-06-05 is a Monday
+	monday0200 := time.Date(2023, time.June, 5, 2, 0, 0, 0, time.UTC)
+	sunday0200 := time.Date(2023, time.June, 4, 2, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		spec    string
+		time    time.Time
+		matches bool
+	}{
+		{spec: "* * * * *", time: monday0200, matches: true},
+		{spec: "0 2 * * *", time: monday0200, matches: true},
+		{spec: "0 2 * * *", time: monday0200.Add(time.Minute), matches: false},
+		{spec: "*/15 2 * * *", time: monday0200.Add(45 * time.Minute), matches: true},
+		{spec: "*/15 2 * * *", time: monday0200.Add(50 * time.Minute), matches: false},
+		{spec: "0 1-3 * * 1-5", time: monday0200, matches: true},
+		{spec: "0 2 * * 1-5", time: sunday0200, matches: false},
+		{spec: "0 2 * * 0", time: sunday0200, matches: true},
+		{spec: "0 2 * * 7", time: sunday0200, matches: true},
+		{spec: "0 2 * 7,8 *", time: monday0200, matches: false},
+		// When day of month and day of week are both restricted, either can match
+		{spec: "0 2 1 * 1", time: monday0200, matches: true},
+		{spec: "0 2 5 * 0", time: monday0200, matches: true},
+		{spec: "0 2 1 * 0", time: monday0200, matches: false},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.spec, func(t *testing.T) {
+			schedule, err := ParseCronSchedule(testCase.spec)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.matches, schedule.Matches(testCase.time))
+		})
+	}
+}
+
+func TestParseCronScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		_, err := ParseCronSchedule(spec)
+		assert.Error(t, err, "expected error for %q", spec)
+	}
+}
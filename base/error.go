@@ -110,6 +110,11 @@ func ErrorAsHTTPStatus(err error) (int, string) {
 
 	unwrappedErr := pkgerrors.Cause(err)
 
+	var retryAfterErr *RetryAfterError
+	if errors.As(err, &retryAfterErr) {
+		return http.StatusServiceUnavailable, retryAfterErr.Message
+	}
+
 	// Check for SGErrors
 	switch unwrappedErr {
 	case gocb.ErrDocumentNotFound, ErrNotFound:
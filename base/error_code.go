@@ -0,0 +1,149 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package base
+
+import (
+	"errors"
+	"math"
+	"net/http"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/couchbase/gomemcached"
+	pkgerrors "github.com/pkg/errors"
+)
+
+// ErrorCode identifies a class of error in REST error responses.  Unlike the reason, which is a description that may
+// change between releases, error codes are stable so that clients can program against them.
+type ErrorCode string
+
+const (
+	ErrorCodeBadRequest           ErrorCode = "bad_request"
+	ErrorCodeUnauthorized         ErrorCode = "unauthorized"
+	ErrorCodeForbidden            ErrorCode = "forbidden"
+	ErrorCodeNotFound             ErrorCode = "not_found"
+	ErrorCodeMethodNotAllowed     ErrorCode = "method_not_allowed"
+	ErrorCodeNotAcceptable        ErrorCode = "not_acceptable"
+	ErrorCodeConflict             ErrorCode = "conflict"
+	ErrorCodePreconditionFailed   ErrorCode = "precondition_failed"
+	ErrorCodeTooLarge             ErrorCode = "too_large"
+	ErrorCodeUnsupportedMediaType ErrorCode = "unsupported_media_type"
+	ErrorCodeRangeNotSatisfiable  ErrorCode = "range_not_satisfiable"
+	ErrorCodeTooManyRequests      ErrorCode = "too_many_requests"
+	ErrorCodeInternal             ErrorCode = "internal_error"
+	ErrorCodeNotImplemented       ErrorCode = "not_implemented"
+	ErrorCodeBadGateway           ErrorCode = "bad_gateway"
+	ErrorCodeUnavailable          ErrorCode = "unavailable"
+	ErrorCodeTimeout              ErrorCode = "timeout"
+	ErrorCodeOverloaded           ErrorCode = "overloaded"
+	ErrorCodeDatabaseOffline      ErrorCode = "database_offline"
+	ErrorCodeDatabaseNotReady     ErrorCode = "database_not_ready"
+	ErrorCodeReplicationLimit     ErrorCode = "replication_limit_exceeded"
+	ErrorCodeIdempotencyKeyReused ErrorCode = "idempotency_key_reused"
+	ErrorCodeIdempotencyKeyInUse  ErrorCode = "idempotency_key_in_use"
+)
+
+// statusErrorCodes are the codes of errors that only have an HTTP status.  Any other 4xx status is a bad request,
+// and 5xx status an internal error.
+var statusErrorCodes = map[int]ErrorCode{
+	http.StatusBadRequest:                   ErrorCodeBadRequest,
+	http.StatusUnauthorized:                 ErrorCodeUnauthorized,
+	http.StatusForbidden:                    ErrorCodeForbidden,
+	http.StatusNotFound:                     ErrorCodeNotFound,
+	http.StatusMethodNotAllowed:             ErrorCodeMethodNotAllowed,
+	http.StatusNotAcceptable:                ErrorCodeNotAcceptable,
+	http.StatusConflict:                     ErrorCodeConflict,
+	http.StatusPreconditionFailed:           ErrorCodePreconditionFailed,
+	http.StatusRequestEntityTooLarge:        ErrorCodeTooLarge,
+	http.StatusUnsupportedMediaType:         ErrorCodeUnsupportedMediaType,
+	http.StatusRequestedRangeNotSatisfiable: ErrorCodeRangeNotSatisfiable,
+	http.StatusTooManyRequests:              ErrorCodeTooManyRequests,
+	http.StatusInternalServerError:          ErrorCodeInternal,
+	http.StatusNotImplemented:               ErrorCodeNotImplemented,
+	http.StatusBadGateway:                   ErrorCodeBadGateway,
+	http.StatusServiceUnavailable:           ErrorCodeUnavailable,
+	http.StatusGatewayTimeout:               ErrorCodeTimeout,
+}
+
+// Retryable returns true if a request that failed with the code may succeed if retried later, unchanged.
+func (code ErrorCode) Retryable() bool {
+	switch code {
+	case ErrorCodeTooManyRequests, ErrorCodeBadGateway, ErrorCodeUnavailable, ErrorCodeTimeout, ErrorCodeOverloaded,
+		ErrorCodeDatabaseNotReady, ErrorCodeReplicationLimit, ErrorCodeIdempotencyKeyInUse:
+		return true
+	default:
+		return false
+	}
+}
+
+// StatusErrorCode returns the code of an error that only has an HTTP status.
+func StatusErrorCode(status int) ErrorCode {
+	if code, ok := statusErrorCodes[status]; ok {
+		return code
+	}
+	if status >= 500 {
+		return ErrorCodeInternal
+	}
+	return ErrorCodeBadRequest
+}
+
+// CodedError is an error with a specific error code, and optionally details, to be returned in REST error responses.
+// The HTTP status and reason are those of the wrapped error.
+type CodedError struct {
+	Code    ErrorCode
+	Details map[string]interface{}
+	Err     error
+}
+
+// NewCodedError returns err with the given error code and details.
+func NewCodedError(code ErrorCode, err error, details map[string]interface{}) *CodedError {
+	return &CodedError{Code: code, Details: details, Err: err}
+}
+
+func (e *CodedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CodedError) Unwrap() error {
+	return e.Err
+}
+
+// Cause allows ErrorAsHTTPStatus to find the status of the wrapped error.
+func (e *CodedError) Cause() error {
+	return e.Err
+}
+
+// ErrorAsCode returns the error code and details of an error whose HTTP status is status, as returned by
+// ErrorAsHTTPStatus.
+func ErrorAsCode(err error, status int) (ErrorCode, map[string]interface{}) {
+	var codedErr *CodedError
+	if errors.As(err, &codedErr) {
+		return codedErr.Code, codedErr.Details
+	}
+	var retryAfterErr *RetryAfterError
+	if errors.As(err, &retryAfterErr) {
+		return ErrorCodeUnavailable, map[string]interface{}{"retry_after_secs": int(math.Ceil(retryAfterErr.RetryAfter.Seconds()))}
+	}
+
+	unwrappedErr := pkgerrors.Cause(err)
+	switch unwrappedErr {
+	case ErrTimeout, ErrViewTimeoutError:
+		return ErrorCodeTimeout, nil
+	case gocb.ErrOverload, gocb.ErrTemporaryFailure:
+		return ErrorCodeOverloaded, nil
+	case ErrReplicationLimitExceeded:
+		return ErrorCodeReplicationLimit, nil
+	}
+	if errors.Is(unwrappedErr, gocb.ErrTimeout) {
+		return ErrorCodeTimeout, nil
+	}
+	if mcErr, ok := unwrappedErr.(*gomemcached.MCResponse); ok && mcErr.Status == gomemcached.TMPFAIL {
+		return ErrorCodeOverloaded, nil
+	}
+	return StatusErrorCode(status), nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package base
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorAsCode(t *testing.T) {
+	testCases := []struct {
+		name      string
+		err       error
+		code      ErrorCode
+		retryable bool
+	}{
+		{name: "not found", err: ErrNotFound, code: ErrorCodeNotFound},
+		{name: "http error", err: HTTPErrorf(http.StatusForbidden, "forbidden"), code: ErrorCodeForbidden},
+		{name: "unknown client error", err: HTTPErrorf(http.StatusTeapot, "teapot"), code: ErrorCodeBadRequest},
+		{name: "unknown error", err: fmt.Errorf("unknown"), code: ErrorCodeInternal},
+		{name: "timeout", err: ErrTimeout, code: ErrorCodeTimeout, retryable: true},
+		{name: "replication limit", err: ErrReplicationLimitExceeded, code: ErrorCodeReplicationLimit, retryable: true},
+		{name: "retry after", err: &RetryAfterError{Message: "open", RetryAfter: 1500 * time.Millisecond}, code: ErrorCodeUnavailable, retryable: true},
+		{name: "coded", err: NewCodedError(ErrorCodeDatabaseOffline, HTTPErrorf(http.StatusServiceUnavailable, "offline"), nil), code: ErrorCodeDatabaseOffline},
+		{name: "wrapped coded", err: fmt.Errorf("wrapped: %w", NewCodedError(ErrorCodeDatabaseNotReady, ErrNotFound, nil)), code: ErrorCodeDatabaseNotReady, retryable: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, _ := ErrorAsHTTPStatus(tc.err)
+			code, _ := ErrorAsCode(tc.err, status)
+			assert.Equal(t, tc.code, code)
+			assert.Equal(t, tc.retryable, code.Retryable())
+		})
+	}
+
+	// The status and reason of a coded error are those of the error it wraps
+	status, message := ErrorAsHTTPStatus(NewCodedError(ErrorCodeDatabaseOffline, HTTPErrorf(http.StatusServiceUnavailable, "offline"), nil))
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "offline", message)
+
+	_, details := ErrorAsCode(&RetryAfterError{Message: "open", RetryAfter: 1500 * time.Millisecond}, http.StatusServiceUnavailable)
+	assert.Equal(t, map[string]interface{}{"retry_after_secs": 2}, details)
+}
@@ -13,6 +13,7 @@ package base
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"net"
 	"net/http"
@@ -33,12 +34,14 @@ const (
 )
 
 // This is like a combination of http.ListenAndServe and http.ListenAndServeTLS, which also
-// uses ThrottledListen to limit the number of open HTTP connections.
-func ListenAndServeHTTP(ctx context.Context, addr string, connLimit uint, certFile, keyFile string, handler http.Handler,
+// uses ThrottledListen to limit the number of open HTTP connections. When certReloader is non-nil,
+// TLS is enabled and certificates are served from it, allowing them to be rotated without a restart.
+// When clientCAs is also non-nil, client certificates signed by them are verified if presented.
+func ListenAndServeHTTP(ctx context.Context, addr string, connLimit uint, certReloader *CertificateReloader, handler http.Handler,
 	readTimeout, writeTimeout, readHeaderTimeout, idleTimeout time.Duration, http2Enabled bool,
-	tlsMinVersion uint16) (serveFn func() error, server *http.Server, err error) {
+	tlsMinVersion uint16, clientCAs *x509.CertPool) (serveFn func() error, server *http.Server, err error) {
 	var config *tls.Config
-	if certFile != "" {
+	if certReloader != nil {
 		config = &tls.Config{}
 		config.MinVersion = tlsMinVersion
 		protocolsEnabled := []string{"http/1.1"}
@@ -47,11 +50,11 @@ func ListenAndServeHTTP(ctx context.Context, addr string, connLimit uint, certFi
 		}
 		config.NextProtos = protocolsEnabled
 		InfofCtx(ctx, KeyHTTP, "Protocols enabled: %v on %v", config.NextProtos, SD(addr))
-		config.Certificates = make([]tls.Certificate, 1)
-		var err error
-		config.Certificates[0], err = tls.LoadX509KeyPair(certFile, keyFile)
-		if err != nil {
-			return nil, nil, err
+		config.GetCertificate = certReloader.GetCertificate
+		if clientCAs != nil {
+			// Client certificates are optional, so that clients can still authenticate by other means
+			config.ClientCAs = clientCAs
+			config.ClientAuth = tls.VerifyClientCertIfGiven
 		}
 	}
 
@@ -77,6 +80,9 @@ func ListenAndServeHTTP(ctx context.Context, addr string, connLimit uint, certFi
 		ReadHeaderTimeout: readHeaderTimeout,
 		IdleTimeout:       idleTimeout,
 		ConnState:         connStateFunc,
+		ConnContext: func(ctx context.Context, conn net.Conn) context.Context {
+			return context.WithValue(ctx, httpConnContextKey{}, conn)
+		},
 	}
 
 	serveFn = func() error {
@@ -93,6 +99,25 @@ func ListenAndServeHTTP(ctx context.Context, addr string, connLimit uint, certFi
 	return serveFn, server, nil
 }
 
+// httpConnContextKey keys the network connection an HTTP request was received on, in the request's context.
+type httpConnContextKey struct{}
+
+// HTTPConnFromContext returns the network connection an HTTP request was received on, or nil if it isn't known, as for
+// requests that weren't received by a server started by ListenAndServeHTTP.
+func HTTPConnFromContext(ctx context.Context) net.Conn {
+	conn, _ := ctx.Value(httpConnContextKey{}).(net.Conn)
+	return conn
+}
+
+// LoadClientCAs returns a pool of the CA certs in the PEM file at caCertPath, for verifying client certificates, or
+// nil if caCertPath is empty.
+func LoadClientCAs(ctx context.Context, caCertPath string) (*x509.CertPool, error) {
+	if caCertPath == "" {
+		return nil, nil
+	}
+	return getRootCAs(ctx, caCertPath)
+}
+
 type throttledListener struct {
 	net.Listener
 	active uint
@@ -0,0 +1,121 @@
+/*
+Copyright 2023-Present Couchbase, Inc.
+
+Use of this software is governed by the Business Source License included in
+the file licenses/BSL-Couchbase.txt.  As of the Change Date specified in that
+file, in accordance with the Business Source License, use of this software will
+be governed by the Apache License, Version 2.0, included in the file
+licenses/APL2.txt.
+*/
+
+package base
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LogFormat is the format of log lines written to all log outputs.
+type LogFormat string
+
+const (
+	LogFormatText LogFormat = "text" // Human-readable log lines, prefixed with timestamp, level, log key and context
+	LogFormatJSON LogFormat = "json" // A JSON object per log line, with context as separate fields
+)
+
+// logFormatJSON is set when log lines should be written as JSON, and can be changed at runtime.
+var logFormatJSON AtomicBool
+
+// SetLogFormat sets the format of log lines written to all log outputs. An empty format is treated as LogFormatText.
+func SetLogFormat(format LogFormat) error {
+	switch format {
+	case "", LogFormatText:
+		logFormatJSON.Set(false)
+	case LogFormatJSON:
+		logFormatJSON.Set(true)
+	default:
+		return fmt.Errorf("unrecognized log format: %q (valid options: %v)", format, []LogFormat{LogFormatText, LogFormatJSON})
+	}
+	return nil
+}
+
+// GetLogFormat returns the format of log lines written to all log outputs.
+func GetLogFormat() LogFormat {
+	if logFormatJSON.IsTrue() {
+		return LogFormatJSON
+	}
+	return LogFormatText
+}
+
+// UnmarshalText implements the TextUnmarshaler interface, and validates the format.
+func (f *LogFormat) UnmarshalText(text []byte) error {
+	switch format := LogFormat(text); format {
+	case LogFormatText, LogFormatJSON:
+		*f = format
+		return nil
+	default:
+		return fmt.Errorf("unrecognized log format: %q (valid options: %v)", format, []LogFormat{LogFormatText, LogFormatJSON})
+	}
+}
+
+// jsonLogEntry is a log line written when the log format is LogFormatJSON. Context fields are only populated when
+// present in the log's context.
+type jsonLogEntry struct {
+	Timestamp     string `json:"timestamp"`
+	Level         string `json:"level,omitempty"`
+	LogKey        string `json:"log_key,omitempty"`
+	Message       string `json:"msg"`
+	Database      string `json:"db,omitempty"`
+	Bucket        string `json:"bucket,omitempty"`
+	Scope         string `json:"scope,omitempty"`
+	Collection    string `json:"collection,omitempty"`
+	User          string `json:"user,omitempty"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+	ServerContext string `json:"server_context,omitempty"`
+	TestName      string `json:"test,omitempty"`
+	Caller        string `json:"caller,omitempty"`
+}
+
+// formatLog returns the format and args to write for a log line in the current log format. args must have already
+// been redacted. caller is the caller name/line number to append, if any.
+func formatLog(ctx context.Context, logLevel LogLevel, logKey LogKey, caller string, format string, args []interface{}) (string, []interface{}) {
+	if !logFormatJSON.IsTrue() {
+		format = addPrefixes(format, ctx, logLevel, logKey)
+		if caller != "" {
+			format += " -- " + caller
+		}
+		return format, args
+	}
+
+	entry := jsonLogEntry{
+		Timestamp: time.Now().Format(ISO8601Format),
+		Message:   strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"),
+		Caller:    caller,
+	}
+	if logLevel > LevelNone {
+		entry.Level = logLevel.String()
+	}
+	if logKey > KeyNone && logKey != KeyAll {
+		entry.LogKey = logKey.String()
+	}
+	if ctx != nil {
+		for _, k := range allLogContextKeys {
+			if logCtx, ok := ctx.Value(k).(ContextAdder); ok {
+				logCtx.addJSONFields(&entry)
+			}
+		}
+	}
+
+	var line bytes.Buffer
+	encoder := JSONEncoder(&line)
+	// Redaction tags aren't escaped, so they can be processed the same as in text logs
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(entry); err != nil {
+		// Fall back to the text format, rather than losing the log line
+		return addPrefixes(format, ctx, logLevel, logKey), args
+	}
+	return "%s", []interface{}{strings.TrimSuffix(line.String(), "\n")}
+}
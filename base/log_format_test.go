@@ -0,0 +1,81 @@
+/*
+Copyright 2023-Present Couchbase, Inc.
+
+Use of this software is governed by the Business Source License included in
+the file licenses/BSL-Couchbase.txt.  As of the Change Date specified in that
+file, in accordance with the Business Source License, use of this software will
+be governed by the Apache License, Version 2.0, included in the file
+licenses/APL2.txt.
+*/
+
+package base
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatLogJSON(t *testing.T) {
+	require.NoError(t, SetLogFormat(LogFormatJSON))
+	defer func() { require.NoError(t, SetLogFormat(LogFormatText)) }()
+	defer func() { RedactUserData = defaultRedactUserData }()
+	RedactUserData = true
+
+	ctx := LogContextWith(context.Background(), &LogContext{CorrelationID: "#001"})
+	ctx = DatabaseLogCtx(ctx, "db1", nil)
+	ctx = CollectionLogCtx(ctx, "sg_test_0")
+	ctx = UserLogCtx(ctx, "alice")
+	ctx = LogContextWith(ctx, &ServerLogContext{LogContextID: "server1"})
+
+	format, args := formatLog(ctx, LevelInfo, KeySync, "", "doc %q updated", []interface{}{"doc1"})
+	require.Equal(t, "%s", format)
+	require.Len(t, args, 1)
+
+	var entry map[string]interface{}
+	require.NoError(t, JSONUnmarshal([]byte(args[0].(string)), &entry))
+	assert.NotEmpty(t, entry["timestamp"])
+	delete(entry, "timestamp")
+	assert.Equal(t, map[string]interface{}{
+		"level":          "info",
+		"log_key":        "Sync",
+		"msg":            `doc "doc1" updated`,
+		"db":             "db1",
+		"collection":     "sg_test_0",
+		"user":           "<ud>alice</ud>",
+		"correlation_id": "#001",
+		"server_context": "server1",
+	}, entry)
+
+	// Keys without values, and the All log key, are omitted
+	_, args = formatLog(context.Background(), LevelWarn, KeyAll, "caller() at file.go:1", "message", nil)
+	entry = nil
+	require.NoError(t, JSONUnmarshal([]byte(args[0].(string)), &entry))
+	delete(entry, "timestamp")
+	assert.Equal(t, map[string]interface{}{"level": "warn", "msg": "message", "caller": "caller() at file.go:1"}, entry)
+}
+
+func TestFormatLogText(t *testing.T) {
+	require.Equal(t, LogFormatText, GetLogFormat())
+	ctx := UserLogCtx(DatabaseLogCtx(context.Background(), "db1", nil), "alice")
+	format, args := formatLog(ctx, LevelInfo, KeySync, "", "doc %q updated", []interface{}{"doc1"})
+	assert.Contains(t, format, "[INF] Sync: db:db1 doc %q updated")
+	assert.NotContains(t, format, "alice")
+	assert.Equal(t, []interface{}{"doc1"}, args)
+}
+
+func TestSetLogFormat(t *testing.T) {
+	defer func() { require.NoError(t, SetLogFormat(LogFormatText)) }()
+	require.NoError(t, SetLogFormat(LogFormatJSON))
+	assert.Equal(t, LogFormatJSON, GetLogFormat())
+	require.NoError(t, SetLogFormat(""))
+	assert.Equal(t, LogFormatText, GetLogFormat())
+	assert.Error(t, SetLogFormat("xml"))
+
+	var format LogFormat
+	require.NoError(t, JSONUnmarshal([]byte(`"json"`), &format))
+	assert.Equal(t, LogFormatJSON, format)
+	assert.Error(t, JSONUnmarshal([]byte(`"xml"`), &format))
+}
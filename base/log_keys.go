@@ -50,6 +50,7 @@ const (
 	KeyMigrate
 	KeyQuery
 	KeyReplicate
+	KeySlowChanges
 	KeySync
 	KeySyncMsg
 	KeyWebSocket
@@ -83,6 +84,7 @@ var (
 		KeyMigrate:        "Migrate",
 		KeyQuery:          "Query",
 		KeyReplicate:      "Replicate",
+		KeySlowChanges:    "SlowChanges",
 		KeySync:           "Sync",
 		KeySyncMsg:        "SyncMsg",
 		KeyWebSocket:      "WS",
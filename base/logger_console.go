@@ -18,7 +18,9 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/natefinch/lumberjack"
 )
@@ -34,6 +36,9 @@ type ConsoleLogger struct {
 	// isStderr is true when the console logger is enabled with no FileOutput
 	isStderr bool
 
+	// logKeyLevels overrides LogLevel and LogKeyMask for specific log keys, and can be replaced at runtime
+	logKeyLevels atomic.Value // map[LogKey]LogLevel
+
 	// ConsoleLoggerConfig stores the initial config used to instantiate ConsoleLogger
 	config ConsoleLoggerConfig
 }
@@ -45,6 +50,10 @@ type ConsoleLoggerConfig struct {
 	LogKeys      []string  `json:"log_keys,omitempty"`      // Log Keys for the console output
 	ColorEnabled *bool     `json:"color_enabled,omitempty"` // Log with color for the console output
 
+	// LogKeyLevels overrides the console log level for specific log keys, e.g. {"Sync": "debug", "HTTP": "warn"}.
+	// Logs for these keys are written at up to the given level, regardless of LogLevel and LogKeys.
+	LogKeyLevels map[string]LogLevel `json:"log_key_levels,omitempty"`
+
 	// FileOutput can be used to override the default stderr output, and write to the file specified instead.
 	FileOutput string `json:"file_output,omitempty"`
 }
@@ -76,6 +85,10 @@ func NewConsoleLogger(ctx context.Context, shouldLogLocation bool, config *Conso
 	}
 	logger.Enabled.Set(*config.Enabled)
 
+	if err := logger.SetLogKeyLevels(config.LogKeyLevels); err != nil {
+		return nil, err
+	}
+
 	// Only create the collateBuffer channel and worker if required.
 	if *config.CollationBufferSize > 1 {
 		logger.collateBuffer = make(chan string, *config.CollationBufferSize)
@@ -103,6 +116,7 @@ func NewConsoleLogger(ctx context.Context, shouldLogLocation bool, config *Conso
 }
 
 func (l *ConsoleLogger) logf(format string, args ...interface{}) {
+	format, args = l.redactUserData(format, args)
 	if l.collateBuffer != nil {
 		l.collateBufferWg.Add(1)
 		l.collateBuffer <- fmt.Sprintf(format, args...)
@@ -123,9 +137,52 @@ func (l *ConsoleLogger) shouldLog(ctx context.Context, logLevel LogLevel, logKey
 		return shouldLogDb
 	}
 
+	// log key level overrides console level/keys if set
+	if keyLevel, ok := l.logKeyLevel(logKey); ok {
+		return keyLevel.Enabled(logLevel)
+	}
+
 	return shouldLog(l.LogLevel, l.LogKeyMask, logLevel, logKey)
 }
 
+// logKeyLevel returns the level override for the given log key, if one is set.
+func (l *ConsoleLogger) logKeyLevel(logKey LogKey) (level LogLevel, ok bool) {
+	logKeyLevels, _ := l.logKeyLevels.Load().(map[LogKey]LogLevel)
+	level, ok = logKeyLevels[logKey]
+	return level, ok
+}
+
+// LogKeyLevels returns the console log level overrides for specific log keys, keyed by log key name.
+func (l *ConsoleLogger) LogKeyLevels() map[string]LogLevel {
+	logKeyLevels, _ := l.logKeyLevels.Load().(map[LogKey]LogLevel)
+	if len(logKeyLevels) == 0 {
+		return nil
+	}
+	levels := make(map[string]LogLevel, len(logKeyLevels))
+	for logKey, level := range logKeyLevels {
+		levels[logKey.String()] = level
+	}
+	return levels
+}
+
+// SetLogKeyLevels replaces the console log level overrides for specific log keys. Returns an error without changing
+// the existing overrides if any log key or level is invalid.
+func (l *ConsoleLogger) SetLogKeyLevels(levels map[string]LogLevel) error {
+	logKeyLevels := make(map[LogKey]LogLevel, len(levels))
+	for name, level := range levels {
+		logKey, ok := logKeyNamesInverse[strings.TrimSuffix(name, "+")]
+		if !ok {
+			return fmt.Errorf("invalid log key in log_key_levels: %q", name)
+		}
+		if level >= levelCount {
+			return fmt.Errorf("invalid log level for log key %q: %v", name, level)
+		}
+		logKeyLevels[logKey] = level
+	}
+	l.logKeyLevels.Store(logKeyLevels)
+	return nil
+}
+
 // shouldLogConsoleDatabase extracts the database's log settings from the context (if set) to determine whether to log
 func shouldLogConsoleDatabase(ctx context.Context, logLevel LogLevel, logKey LogKey) (willLog, ok bool) {
 	if ctx == nil {
@@ -171,6 +228,7 @@ func (l *ConsoleLogger) getConsoleLoggerConfig() *ConsoleLoggerConfig {
 	c.FileLoggerConfig = *l.getFileLoggerConfig()
 	c.LogLevel = l.LogLevel
 	c.LogKeys = l.LogKeyMask.EnabledLogKeys()
+	c.LogKeyLevels = l.LogKeyLevels()
 
 	return &c
 }
@@ -185,6 +243,10 @@ func (lcc *ConsoleLoggerConfig) init() error {
 		return err
 	}
 
+	if err := lcc.validateRedactionProfile("console"); err != nil {
+		return err
+	}
+
 	// Default to os.Stderr if alternative output is not set
 	if lcc.Output == nil && lcc.FileOutput == "" {
 		lcc.Output = os.Stderr
@@ -203,7 +265,7 @@ func (lcc *ConsoleLoggerConfig) init() error {
 
 	// Default to disabled only when a log key or log level has not been specified
 	if lcc.Enabled == nil {
-		if lcc.LogLevel != nil || len(lcc.LogKeys) > 0 {
+		if lcc.LogLevel != nil || len(lcc.LogKeys) > 0 || len(lcc.LogKeyLevels) > 0 {
 			lcc.Enabled = BoolPtr(true)
 		} else {
 			lcc.Enabled = BoolPtr(false)
@@ -215,6 +277,7 @@ func (lcc *ConsoleLoggerConfig) init() error {
 		newLevel := LevelNone
 		lcc.LogLevel = &newLevel
 		lcc.LogKeys = []string{}
+		lcc.LogKeyLevels = nil
 	}
 
 	// Default log level
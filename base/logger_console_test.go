@@ -16,6 +16,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var consoleShouldLogTests = []struct {
@@ -286,3 +287,38 @@ func TestConsoleLogDefaults(t *testing.T) {
 		})
 	}
 }
+
+// TestConsoleShouldLogWithLogKeyLevels ensures that log key level overrides take precedence over console level and keys.
+func TestConsoleShouldLogWithLogKeyLevels(t *testing.T) {
+	l, err := NewConsoleLogger(TestCtx(t), false, &ConsoleLoggerConfig{
+		LogLevel:     logLevelPtr(LevelInfo),
+		LogKeys:      []string{"HTTP", "CRUD"},
+		LogKeyLevels: map[string]LogLevel{"Sync": LevelDebug, "HTTP": LevelWarn},
+		FileLoggerConfig: FileLoggerConfig{
+			Enabled: BoolPtr(true),
+			Output:  io.Discard,
+		}})
+	require.NoError(t, err)
+	ctx := TestCtx(t)
+
+	assert.True(t, l.shouldLog(ctx, LevelDebug, KeySync))
+	assert.False(t, l.shouldLog(ctx, LevelTrace, KeySync))
+	assert.False(t, l.shouldLog(ctx, LevelInfo, KeyHTTP))
+	assert.True(t, l.shouldLog(ctx, LevelWarn, KeyHTTP))
+	assert.True(t, l.shouldLog(ctx, LevelInfo, KeyCRUD))
+	assert.False(t, l.shouldLog(ctx, LevelDebug, KeyCRUD))
+	assert.Equal(t, map[string]LogLevel{"Sync": LevelDebug, "HTTP": LevelWarn}, l.LogKeyLevels())
+
+	// Overrides can be replaced at runtime, and invalid overrides leave the existing ones in place
+	require.NoError(t, l.SetLogKeyLevels(map[string]LogLevel{"CRUD": LevelTrace}))
+	assert.True(t, l.shouldLog(ctx, LevelTrace, KeyCRUD))
+	assert.False(t, l.shouldLog(ctx, LevelDebug, KeySync))
+	assert.True(t, l.shouldLog(ctx, LevelInfo, KeyHTTP))
+
+	assert.Error(t, l.SetLogKeyLevels(map[string]LogLevel{"NotALogKey": LevelDebug}))
+	assert.Equal(t, map[string]LogLevel{"CRUD": LevelTrace}, l.LogKeyLevels())
+
+	require.NoError(t, l.SetLogKeyLevels(nil))
+	assert.Nil(t, l.LogKeyLevels())
+	assert.False(t, l.shouldLog(ctx, LevelDebug, KeyCRUD))
+}
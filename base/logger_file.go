@@ -66,6 +66,9 @@ type FileLoggerConfig struct {
 
 	CollationBufferSize *int      `json:"collation_buffer_size,omitempty"` // The size of the log collation buffer.
 	Output              io.Writer `json:"-"`                               // Logger output. Defaults to os.Stderr. Can be overridden for testing purposes.
+
+	// RedactionProfile is how user data is redacted in this output's log lines. Defaults to RedactionProfileTag.
+	RedactionProfile RedactionProfile `json:"redaction_profile,omitempty"`
 }
 
 type logRotationConfig struct {
@@ -146,6 +149,7 @@ func (l *FileLogger) String() string {
 // logf will put the given message into the collation buffer if it exists,
 // otherwise will log the message directly.
 func (l *FileLogger) logf(format string, args ...interface{}) {
+	format, args = l.redactUserData(format, args)
 	if l.collateBuffer != nil {
 		l.collateBufferWg.Add(1)
 		l.collateBuffer <- fmt.Sprintf(format, args...)
@@ -154,6 +158,15 @@ func (l *FileLogger) logf(format string, args ...interface{}) {
 	}
 }
 
+// redactUserData returns the format and args for a log line with user data redacted according to the logger's
+// RedactionProfile. Hashing is applied to tagged user data, so the log line is formatted up front.
+func (l *FileLogger) redactUserData(format string, args []interface{}) (string, []interface{}) {
+	if l.config.RedactionProfile != RedactionProfileHash {
+		return format, args
+	}
+	return "%s", []interface{}{hashUserDataTags(fmt.Sprintf(format, args...))}
+}
+
 // shouldLog returns true if we can log.
 func (l *FileLogger) shouldLog(logLevel LogLevel) bool {
 	return l != nil && l.logger != nil &&
@@ -188,6 +201,10 @@ func (lfc *FileLoggerConfig) init(ctx context.Context, level LogLevel, name stri
 		return err
 	}
 
+	if err := lfc.validateRedactionProfile(name); err != nil {
+		return err
+	}
+
 	if lfc.Output == nil {
 		lfc.Output = newLumberjackOutput(
 			filepath.Join(filepath.FromSlash(logFilePath), logFilePrefix+name+".log"),
@@ -255,6 +272,22 @@ func (lfc *FileLoggerConfig) initRotationConfig(name string, defaultMaxSize, min
 	return nil
 }
 
+// validateRedactionProfile returns an error if the redaction profile is invalid. Hashing is applied to tagged user
+// data, so requires user data redaction to be enabled.
+func (lfc *FileLoggerConfig) validateRedactionProfile(name string) error {
+	switch lfc.RedactionProfile {
+	case "", RedactionProfileTag:
+		return nil
+	case RedactionProfileHash:
+		if !RedactUserData {
+			return fmt.Errorf("redaction_profile %q for %v requires a redaction_level of partial or full", lfc.RedactionProfile, name)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid redaction_profile for %v: %q", name, lfc.RedactionProfile)
+	}
+}
+
 func newLumberjackOutput(filename string, maxSize, maxAge int) *lumberjack.Logger {
 	return &lumberjack.Logger{
 		Filename: filename,
@@ -11,6 +11,7 @@ licenses/APL2.txt.
 package base
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"log"
@@ -108,6 +109,32 @@ func BenchmarkFileShouldLog(b *testing.B) {
 	}
 }
 
+func TestFileLoggerRedactionProfile(t *testing.T) {
+	defer func() { RedactUserData = defaultRedactUserData }()
+	defer func() { userDataHashSalt = "" }()
+	require.NoError(t, SetUserDataHashSalt("salt"))
+	RedactUserData = true
+
+	buf := bytes.Buffer{}
+	l, err := NewFileLogger(TestCtx(t), &FileLoggerConfig{
+		Enabled:             BoolPtr(true),
+		Output:              &buf,
+		CollationBufferSize: IntPtr(0),
+		RedactionProfile:    RedactionProfileHash,
+	}, LevelInfo, "info", t.TempDir(), infoMinAge, nil)
+	require.NoError(t, err)
+
+	l.logf("user %s updated %q", UD("alice").Redact(), "doc1")
+	assert.Equal(t, "user "+Sha1HashString("alice", "salt")+" updated \"doc1\"\n", buf.String())
+
+	// Hashing requires user data to be tagged
+	RedactUserData = false
+	_, err = NewFileLogger(TestCtx(t), &FileLoggerConfig{Output: &buf, RedactionProfile: RedactionProfileHash}, LevelInfo, "info", t.TempDir(), infoMinAge, nil)
+	assert.Error(t, err)
+	_, err = NewFileLogger(TestCtx(t), &FileLoggerConfig{Output: &buf, RedactionProfile: "rot13"}, LevelInfo, "info", t.TempDir(), infoMinAge, nil)
+	assert.Error(t, err)
+}
+
 func TestRotatedLogDeletion(t *testing.T) {
 	var dirContents []os.DirEntry
 
@@ -214,21 +214,22 @@ func logTo(ctx context.Context, logLevel LogLevel, logKey LogKey, format string,
 		return
 	}
 
-	// Prepend timestamp, level, log key.
-	format = addPrefixes(format, ctx, logLevel, logKey)
-
-	// Error, warn and trace logs also append caller name/line numbers.
+	// Error, warn and trace logs also include caller name/line numbers.
+	var caller string
 	if logLevel <= LevelWarn || logLevel == LevelTrace {
 		stackDepth := 2
 		if logKey == KeyWalrus {
 			stackDepth++ // walrus logs go through another layer of fn call
 		}
-		format += " -- " + GetCallersName(stackDepth, true)
+		caller = GetCallersName(stackDepth, true)
 	}
 
 	// Perform log redaction, if necessary.
 	args = redact(args)
 
+	// Prepend timestamp, level, log key, or format as JSON.
+	format, args = formatLog(ctx, logLevel, logKey, caller, format, args)
+
 	// If either global console or db console wants to log, allow it
 	if shouldLogConsole {
 		consoleLogger.logf(color(format, logLevel), args...)
@@ -259,8 +260,11 @@ func ConsolefCtx(ctx context.Context, logLevel LogLevel, logKey LogKey, format s
 
 	// If the above logTo didn't already log to stderr, do it directly here
 	if !consoleLogger.isStderr || !consoleLogger.shouldLog(ctx, logLevel, logKey) {
-		format = color(addPrefixes(format, ctx, logLevel, logKey), logLevel)
-		_, _ = fmt.Fprintf(consoleFOutput, format+"\n", args...)
+		format, args = formatLog(ctx, logLevel, logKey, "", format, args)
+		if consoleLogger != nil {
+			format, args = consoleLogger.redactUserData(format, args)
+		}
+		_, _ = fmt.Fprintf(consoleFOutput, color(format, logLevel)+"\n", args...)
 	}
 }
 
@@ -272,21 +276,21 @@ func LogSyncGatewayVersion(ctx context.Context) {
 	ConsolefCtx(ctx, LevelNone, KeyNone, msg)
 
 	// Log the startup indicator to ALL log files too.
-	msg = addPrefixes(msg, ctx, LevelNone, KeyNone)
+	format, args := formatLog(ctx, LevelNone, KeyNone, "", msg, nil)
 	if errorLogger.shouldLog(LevelNone) {
-		errorLogger.logger.Printf(msg)
+		errorLogger.logger.Printf(format, args...)
 	}
 	if warnLogger.shouldLog(LevelNone) {
-		warnLogger.logger.Printf(msg)
+		warnLogger.logger.Printf(format, args...)
 	}
 	if infoLogger.shouldLog(LevelNone) {
-		infoLogger.logger.Printf(msg)
+		infoLogger.logger.Printf(format, args...)
 	}
 	if debugLogger.shouldLog(LevelNone) {
-		debugLogger.logger.Printf(msg)
+		debugLogger.logger.Printf(format, args...)
 	}
 	if traceLogger.shouldLog(LevelNone) {
-		traceLogger.logger.Printf(msg)
+		traceLogger.logger.Printf(format, args...)
 	}
 }
 
@@ -352,9 +356,9 @@ func color(str string, logLevel LogLevel) string {
 }
 
 // colorEnabled returns true if the console logger has color enabled,
-// and the environment supports ANSI color escape sequences.
+// and the environment supports ANSI color escape sequences. JSON logs are never colored.
 func colorEnabled() bool {
-	return consoleLogger.ColorEnabled && envColorCapable
+	return consoleLogger.ColorEnabled && envColorCapable && !logFormatJSON.IsTrue()
 }
 
 // ConsoleLogLevel returns the console log level.
@@ -367,6 +371,20 @@ func ConsoleLogKey() *LogKeyMask {
 	return consoleLogger.LogKeyMask
 }
 
+// ConsoleLogKeyLevels returns the console log level overrides for specific log keys.
+func ConsoleLogKeyLevels() map[string]LogLevel {
+	return consoleLogger.LogKeyLevels()
+}
+
+// SetConsoleLogKeyLevels replaces the console log level overrides for specific log keys.
+func SetConsoleLogKeyLevels(ctx context.Context, levels map[string]LogLevel) error {
+	if err := consoleLogger.SetLogKeyLevels(levels); err != nil {
+		return err
+	}
+	InfofCtx(ctx, KeyAll, "Setting console log key levels to: %v", levels)
+	return nil
+}
+
 // LogInfoEnabled returns true if either the console should log at info level,
 // or if the infoLogger is enabled.
 func LogInfoEnabled(ctx context.Context, logKey LogKey) bool {
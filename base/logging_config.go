@@ -232,6 +232,8 @@ func StatsLoggerIsEnabled() bool {
 type LoggingConfig struct {
 	LogFilePath    string               `json:"log_file_path,omitempty"   help:"Absolute or relative path on the filesystem to the log file directory. A relative path is from the directory that contains the Sync Gateway executable file"`
 	RedactionLevel RedactionLevel       `json:"redaction_level,omitempty" help:"Redaction level to apply to log output"`
+	Format         LogFormat            `json:"format,omitempty"          help:"Format of log lines written to all log outputs. Options: text, json"`
+	RedactionSalt  string               `json:"redaction_salt,omitempty"  help:"Salt used to hash user data for log outputs with a redaction_profile of hash. Random if not set"`
 	Console        *ConsoleLoggerConfig `json:"console,omitempty"`
 	Error          *FileLoggerConfig    `json:"error,omitempty"`
 	Warn           *FileLoggerConfig    `json:"warn,omitempty"`
@@ -245,6 +247,7 @@ func BuildLoggingConfigFromLoggers(redactionLevel RedactionLevel, LogFilePath st
 	config := LoggingConfig{
 		RedactionLevel: redactionLevel,
 		LogFilePath:    LogFilePath,
+		Format:         GetLogFormat(),
 	}
 
 	config.Console = consoleLogger.getConsoleLoggerConfig()
@@ -41,6 +41,9 @@ type LogContext struct {
 	// Collection is the name of the collection (see KeyspaceLogCtx)
 	Collection string
 
+	// Username is the name of the authenticated user (see UserLogCtx). Only included in JSON logs, as user data.
+	Username string
+
 	// TestName can be a unit test name (see TestCtx)
 	TestName string
 }
@@ -103,10 +106,27 @@ func (lc *LogContext) getCopy() LogContext {
 		Bucket:             lc.Bucket,
 		Scope:              lc.Scope,
 		Collection:         lc.Collection,
+		Username:           lc.Username,
 		TestName:           lc.TestName,
 	}
 }
 
+// addJSONFields sets the fields of a JSON log entry from the log context.
+func (lc *LogContext) addJSONFields(entry *jsonLogEntry) {
+	if lc == nil {
+		return
+	}
+	entry.CorrelationID = lc.CorrelationID
+	entry.Database = lc.Database
+	entry.Bucket = lc.Bucket
+	entry.Scope = lc.Scope
+	entry.Collection = lc.Collection
+	if lc.Username != "" {
+		entry.User = UD(lc.Username).Redact()
+	}
+	entry.TestName = lc.TestName
+}
+
 func FormatBlipContextID(contextID string) string {
 	return "[" + contextID + "]"
 }
@@ -157,6 +177,13 @@ func CorrelationIDLogCtx(parent context.Context, correlationID string) context.C
 	return LogContextWith(parent, &newCtx)
 }
 
+// UserLogCtx extends the parent context with the name of the authenticated user.
+func UserLogCtx(parent context.Context, username string) context.Context {
+	newCtx := getLogCtx(parent)
+	newCtx.Username = username
+	return LogContextWith(parent, &newCtx)
+}
+
 // DatabaseLogCtx extends the parent context with a database name.
 func DatabaseLogCtx(parent context.Context, databaseName string, config *DbConsoleLogConfig) context.Context {
 	newCtx := getLogCtx(parent)
@@ -184,10 +211,11 @@ const (
 
 // ContextAdder interface should be implemented by all custom contexts.
 // The custom context should provide its own key to be used with go contexts,
-// and be able to add its custom info to the log format msg.
+// and be able to add its custom info to the log format msg, or to JSON log entries.
 type ContextAdder interface {
 	getContextKey() LogContextKey
 	addContext(format string) string
+	addJSONFields(entry *jsonLogEntry)
 }
 
 // allLogContextKeys contains the keys of all custom contexts,
@@ -215,3 +243,9 @@ func (c *ServerLogContext) addContext(format string) string {
 	}
 	return format
 }
+
+func (c *ServerLogContext) addJSONFields(entry *jsonLogEntry) {
+	if c != nil {
+		entry.ServerContext = c.LogContextID
+	}
+}
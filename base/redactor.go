@@ -122,6 +122,25 @@ func buildRedactorFuncSlice(valueOf reflect.Value, function func(interface{}) Re
 	return retVal
 }
 
+// RedactionProfile is how user data is redacted in a log output.
+type RedactionProfile string
+
+const (
+	RedactionProfileTag  RedactionProfile = "tag"  // User data is wrapped in tags, as set by the redaction level
+	RedactionProfileHash RedactionProfile = "hash" // User data is replaced by a salted hash, so it can't be recovered from logs
+)
+
+// UnmarshalText unmarshals text to a RedactionProfile.
+func (p *RedactionProfile) UnmarshalText(text []byte) error {
+	switch profile := RedactionProfile(strings.ToLower(string(text))); profile {
+	case RedactionProfileTag, RedactionProfileHash:
+		*p = profile
+	default:
+		return fmt.Errorf("unrecognized redaction profile: %q (valid options: %v)", text, []RedactionProfile{RedactionProfileTag, RedactionProfileHash})
+	}
+	return nil
+}
+
 type RedactionLevel int
 
 const DefaultRedactionLevel = RedactPartial
@@ -13,6 +13,7 @@ package base
 import (
 	"fmt"
 	"reflect"
+	"strings"
 )
 
 const (
@@ -25,6 +26,44 @@ const defaultRedactUserData = true
 // RedactUserData is a global toggle for user data redaction.
 var RedactUserData = defaultRedactUserData
 
+// userDataHashSalt is the salt used to hash user data for log outputs with RedactionProfileHash (see SetUserDataHashSalt).
+var userDataHashSalt string
+
+// SetUserDataHashSalt sets the salt used to hash user data in logs. If salt is empty a random salt is used, so the
+// hashes can't be correlated with logs from other Sync Gateway nodes or restarts.
+func SetUserDataHashSalt(salt string) error {
+	if salt == "" {
+		var err error
+		if salt, err = GenerateRandomSecret(); err != nil {
+			return err
+		}
+	}
+	userDataHashSalt = salt
+	return nil
+}
+
+// hashUserDataTags replaces user data wrapped in tags with a salted hash of the user data.
+func hashUserDataTags(s string) string {
+	start := strings.Index(s, UserDataPrefix)
+	if start < 0 {
+		return s
+	}
+	var b strings.Builder
+	for start >= 0 {
+		end := strings.Index(s[start+len(UserDataPrefix):], UserDataSuffix)
+		if end < 0 {
+			break
+		}
+		end += start + len(UserDataPrefix)
+		b.WriteString(s[:start])
+		b.WriteString(Sha1HashString(s[start+len(UserDataPrefix):end], userDataHashSalt))
+		s = s[end+len(UserDataSuffix):]
+		start = strings.Index(s, UserDataPrefix)
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
 // UserData is a type which implements the Redactor interface for logging purposes of user data.
 //
 //	User data is data that is stored into Couchbase by the application user account:
@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestUserDataRedact(t *testing.T) {
@@ -31,6 +32,24 @@ func TestUserDataRedact(t *testing.T) {
 	assert.Equal(t, username, userdata.Redact())
 }
 
+func TestHashUserDataTags(t *testing.T) {
+	defer func() { userDataHashSalt = "" }()
+	require.NoError(t, SetUserDataHashSalt("salt"))
+
+	aliceHash := Sha1HashString("alice", "salt")
+	docHash := Sha1HashString("doc1", "salt")
+	assert.Equal(t, "no user data", hashUserDataTags("no user data"))
+	assert.Equal(t, "user "+aliceHash+" updated "+docHash, hashUserDataTags("user <ud>alice</ud> updated <ud>doc1</ud>"))
+	assert.Equal(t, "[ "+aliceHash+" "+aliceHash+" ]", hashUserDataTags("[ <ud>alice</ud> <ud>alice</ud> ]"))
+	// Unterminated tags are left as-is
+	assert.Equal(t, aliceHash+" <ud>doc1", hashUserDataTags("<ud>alice</ud> <ud>doc1"))
+
+	// A random salt is used when not set
+	require.NoError(t, SetUserDataHashSalt(""))
+	assert.NotEqual(t, "salt", userDataHashSalt)
+	assert.NotEqual(t, aliceHash, hashUserDataTags("<ud>alice</ud>"))
+}
+
 func TestUD(t *testing.T) {
 	RedactUserData = true
 	defer func() { RedactUserData = defaultRedactUserData }()
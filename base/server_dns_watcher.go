@@ -0,0 +1,234 @@
+/*
+Copyright 2023-Present Couchbase, Inc.
+
+Use of this software is governed by the Business Source License included in
+the file licenses/BSL-Couchbase.txt.  As of the Change Date specified in that
+file, in accordance with the Business Source License, use of this software will
+be governed by the Apache License, Version 2.0, included in the file
+licenses/APL2.txt.
+*/
+
+package base
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbaselabs/gocbconnstr"
+)
+
+// HostResolver performs the DNS lookups used to resolve a Couchbase Server connection string. Satisfied by
+// *net.Resolver.
+type HostResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (cname string, addrs []*net.SRV, err error)
+	LookupHost(ctx context.Context, host string) (addrs []string, err error)
+}
+
+// ServerDNSWatcher periodically re-resolves the hosts in a Couchbase Server connection string, and notifies when
+// they change. gocb only resolves the connection string when a connection is opened, so long-lived connections don't
+// notice DNS changes, such as an SRV record being moved to a different cluster during a migration.
+type ServerDNSWatcher struct {
+	server   string
+	resolver HostResolver
+	onChange func(ctx context.Context, previous, current []string)
+	stats    *ServerConnectionStat
+
+	lock      sync.Mutex // Serializes resolution
+	addresses []string   // Addresses resolved by the last successful resolution, sorted
+
+	terminator chan struct{}
+	doneChan   chan struct{}
+}
+
+// NewServerDNSWatcher resolves the given connection string, and returns a ServerDNSWatcher that calls onChange
+// when the resolved addresses later change. stats is optional.
+func NewServerDNSWatcher(ctx context.Context, server string, resolver HostResolver, stats *ServerConnectionStat, onChange func(ctx context.Context, previous, current []string)) (*ServerDNSWatcher, error) {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	w := &ServerDNSWatcher{
+		server:   server,
+		resolver: resolver,
+		onChange: onChange,
+		stats:    stats,
+	}
+	addresses, err := ResolveServerAddresses(ctx, resolver, server)
+	if err != nil {
+		return nil, err
+	}
+	w.addresses = addresses
+	return w, nil
+}
+
+// Addresses returns the addresses resolved by the last successful resolution.
+func (w *ServerDNSWatcher) Addresses() []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return append([]string(nil), w.addresses...)
+}
+
+// Refresh re-resolves the connection string, and calls onChange if the resolved addresses have changed. A failed
+// resolution leaves the previously resolved addresses in place, as the cluster is most likely still reachable through
+// the existing connections.
+func (w *ServerDNSWatcher) Refresh(ctx context.Context) (changed bool, err error) {
+	w.lock.Lock()
+	addresses, err := ResolveServerAddresses(ctx, w.resolver, w.server)
+	if err != nil {
+		w.lock.Unlock()
+		if w.stats != nil {
+			w.stats.DNSResolutionErrors.Add(1)
+		}
+		WarnfCtx(ctx, "Unable to re-resolve Couchbase Server addresses for %s, continuing to use existing connections: %v", MD(w.server), err)
+		return false, err
+	}
+	previous := w.addresses
+	changed = !stringSlicesEqual(previous, addresses)
+	w.addresses = addresses
+	w.lock.Unlock()
+
+	if !changed {
+		DebugfCtx(ctx, KeyAll, "Couchbase Server addresses for %s unchanged: %v", MD(w.server), MD(addresses))
+		return false, nil
+	}
+
+	failover := !stringSlicesOverlap(previous, addresses)
+	if w.stats != nil {
+		w.stats.Reconnects.Add(1)
+		if failover {
+			w.stats.Failovers.Add(1)
+		}
+	}
+	if failover {
+		WarnfCtx(ctx, "Couchbase Server addresses for %s moved from %v to %v, reconnecting", MD(w.server), MD(previous), MD(addresses))
+	} else {
+		InfofCtx(ctx, KeyAll, "Couchbase Server addresses for %s changed from %v to %v, reconnecting", MD(w.server), MD(previous), MD(addresses))
+	}
+	if w.onChange != nil {
+		w.onChange(ctx, previous, addresses)
+	}
+	return true, nil
+}
+
+// StartWatching re-resolves the connection string at the given interval. Must be stopped with StopWatching.
+func (w *ServerDNSWatcher) StartWatching(ctx context.Context, interval time.Duration) {
+	w.terminator = make(chan struct{})
+	w.doneChan = make(chan struct{})
+	go func() {
+		defer close(w.doneChan)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_, _ = w.Refresh(ctx)
+			case <-w.terminator:
+				DebugfCtx(ctx, KeyAll, "Stopping Couchbase Server address watcher")
+				return
+			}
+		}
+	}()
+	InfofCtx(ctx, KeyAll, "Re-resolving Couchbase Server addresses for %s every %v", MD(w.server), interval)
+}
+
+// StopWatching stops the goroutine started by StartWatching, if running.
+func (w *ServerDNSWatcher) StopWatching(timeout time.Duration) error {
+	return TerminateAndWaitForClose(w.terminator, w.doneChan, timeout)
+}
+
+// ResolveServerAddresses resolves the hosts in a Couchbase Server connection string, returning the sorted addresses
+// gocb would connect to. As in gocb, a couchbase:// or couchbases:// connection string with a single host and no port
+// is first looked up as an SRV record, falling back to the host itself when there isn't one. Hosts are resolved to
+// their IP addresses, so that a DNS change behind an unchanged hostname is detected.
+func ResolveServerAddresses(ctx context.Context, resolver HostResolver, server string) ([]string, error) {
+	connSpec, err := gocbconnstr.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	type hostPort struct {
+		host string
+		port string
+	}
+	hosts := make([]hostPort, 0, len(connSpec.Addresses))
+	if srvName, ok := connSpecSRVName(connSpec); ok {
+		_, records, err := resolver.LookupSRV(ctx, connSpec.Scheme, "tcp", srvName)
+		if err == nil && len(records) > 0 {
+			for _, record := range records {
+				hosts = append(hosts, hostPort{host: strings.TrimSuffix(record.Target, "."), port: strconv.Itoa(int(record.Port))})
+			}
+		} else if err != nil {
+			DebugfCtx(ctx, KeyAll, "No SRV record for %s, resolving as a host: %v", MD(srvName), err)
+		}
+	}
+	if len(hosts) == 0 {
+		for _, address := range connSpec.Addresses {
+			port := ""
+			if address.Port > 0 {
+				port = strconv.Itoa(address.Port)
+			}
+			hosts = append(hosts, hostPort{host: address.Host, port: port})
+		}
+	}
+
+	addressSet := make(Set, len(hosts))
+	for _, host := range hosts {
+		ips := []string{host.host}
+		if net.ParseIP(host.host) == nil {
+			ips, err = resolver.LookupHost(ctx, host.host)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, ip := range ips {
+			if host.port == "" {
+				addressSet.Add(ip)
+			} else {
+				addressSet.Add(net.JoinHostPort(ip, host.port))
+			}
+		}
+	}
+	addresses := addressSet.ToArray()
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
+// connSpecSRVName returns the name to look up as an SRV record for the connection string, and false if the connection
+// string doesn't support SRV records.
+func connSpecSRVName(connSpec gocbconnstr.ConnSpec) (string, bool) {
+	if connSpec.Scheme != "couchbase" && connSpec.Scheme != "couchbases" {
+		return "", false
+	}
+	if len(connSpec.Addresses) != 1 || connSpec.Addresses[0].Port > 0 {
+		return "", false
+	}
+	return connSpec.Addresses[0].Host, true
+}
+
+// stringSlicesEqual returns true if the sorted slices contain the same elements.
+func stringSlicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// stringSlicesOverlap returns true if the slices have any element in common.
+func stringSlicesOverlap(a, b []string) bool {
+	set := SetFromArray(a)
+	for _, s := range b {
+		if set.Contains(s) {
+			return true
+		}
+	}
+	return false
+}
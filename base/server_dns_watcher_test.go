@@ -0,0 +1,150 @@
+/*
+Copyright 2023-Present Couchbase, Inc.
+
+Use of this software is governed by the Business Source License included in
+the file licenses/BSL-Couchbase.txt.  As of the Change Date specified in that
+file, in accordance with the Business Source License, use of this software will
+be governed by the Apache License, Version 2.0, included in the file
+licenses/APL2.txt.
+*/
+
+package base
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testHostResolver is a HostResolver that serves lookups from maps, which can be changed between lookups.
+type testHostResolver struct {
+	lock  sync.Mutex
+	srv   map[string][]*net.SRV
+	hosts map[string][]string
+	err   error
+}
+
+func (r *testHostResolver) LookupSRV(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.err != nil {
+		return "", nil, r.err
+	}
+	records, ok := r.srv["_"+service+"._"+proto+"."+name]
+	if !ok {
+		return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return name, records, nil
+}
+
+func (r *testHostResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	addrs, ok := r.hosts[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+func (r *testHostResolver) set(srv map[string][]*net.SRV, hosts map[string][]string, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.srv, r.hosts, r.err = srv, hosts, err
+}
+
+func TestResolveServerAddresses(t *testing.T) {
+	resolver := &testHostResolver{
+		srv: map[string][]*net.SRV{
+			"_couchbase._tcp.cluster.example.com":  {{Target: "node2.example.com.", Port: 11210}, {Target: "node1.example.com.", Port: 11210}},
+			"_couchbases._tcp.cluster.example.com": {{Target: "node1.example.com.", Port: 11207}},
+		},
+		hosts: map[string][]string{
+			"node1.example.com":   {"10.0.0.1"},
+			"node2.example.com":   {"10.0.0.2"},
+			"cluster.example.com": {"10.0.0.10"},
+		},
+	}
+	testCases := []struct {
+		server    string
+		addresses []string
+	}{
+		{server: "couchbase://cluster.example.com", addresses: []string{"10.0.0.1:11210", "10.0.0.2:11210"}},
+		{server: "couchbases://cluster.example.com", addresses: []string{"10.0.0.1:11207"}},
+		// SRV records aren't used when a port is given, or there are multiple hosts
+		{server: "couchbase://cluster.example.com:11210", addresses: []string{"10.0.0.10:11210"}},
+		{server: "couchbase://node1.example.com,node2.example.com", addresses: []string{"10.0.0.1", "10.0.0.2"}},
+		// Hosts without SRV records are resolved directly
+		{server: "couchbase://node1.example.com", addresses: []string{"10.0.0.1"}},
+		{server: "http://cluster.example.com:8091", addresses: []string{"10.0.0.10:8091"}},
+		{server: "couchbase://127.0.0.1", addresses: []string{"127.0.0.1"}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.server, func(t *testing.T) {
+			addresses, err := ResolveServerAddresses(TestCtx(t), resolver, testCase.server)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.addresses, addresses)
+		})
+	}
+
+	_, err := ResolveServerAddresses(TestCtx(t), resolver, "couchbase://unknown.example.com")
+	assert.Error(t, err)
+}
+
+func TestServerDNSWatcherRefresh(t *testing.T) {
+	ctx := TestCtx(t)
+	srv := map[string][]*net.SRV{
+		"_couchbase._tcp.cluster.example.com": {{Target: "node1.example.com", Port: 11210}, {Target: "node2.example.com", Port: 11210}},
+	}
+	resolver := &testHostResolver{}
+	resolver.set(srv, map[string][]string{"node1.example.com": {"10.0.0.1"}, "node2.example.com": {"10.0.0.2"}}, nil)
+
+	stats := &ServerConnectionStat{Reconnects: &SgwIntStat{}, Failovers: &SgwIntStat{}, DNSResolutionErrors: &SgwIntStat{}}
+	var changes [][]string
+	watcher, err := NewServerDNSWatcher(ctx, "couchbase://cluster.example.com", resolver, stats, func(_ context.Context, _, current []string) {
+		changes = append(changes, current)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:11210", "10.0.0.2:11210"}, watcher.Addresses())
+
+	// Unchanged records don't reconnect
+	changed, err := watcher.Refresh(ctx)
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Empty(t, changes)
+
+	// A node's address changing reconnects, but isn't a failover
+	resolver.set(srv, map[string][]string{"node1.example.com": {"10.0.0.1"}, "node2.example.com": {"10.0.0.3"}}, nil)
+	changed, err = watcher.Refresh(ctx)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, [][]string{{"10.0.0.1:11210", "10.0.0.3:11210"}}, changes)
+	assert.Equal(t, int64(1), stats.Reconnects.Value())
+	assert.Equal(t, int64(0), stats.Failovers.Value())
+
+	// Failed resolution retains the existing addresses
+	resolver.set(nil, nil, errors.New("resolver unavailable"))
+	_, err = watcher.Refresh(ctx)
+	require.Error(t, err)
+	assert.Equal(t, int64(1), stats.DNSResolutionErrors.Value())
+	assert.Equal(t, []string{"10.0.0.1:11210", "10.0.0.3:11210"}, watcher.Addresses())
+
+	// The SRV record moving to a different cluster is a failover
+	resolver.set(map[string][]*net.SRV{
+		"_couchbase._tcp.cluster.example.com": {{Target: "node4.example.com", Port: 11210}},
+	}, map[string][]string{"node4.example.com": {"10.1.0.4"}}, nil)
+	changed, err = watcher.Refresh(ctx)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []string{"10.1.0.4:11210"}, changes[1])
+	assert.Equal(t, int64(2), stats.Reconnects.Value())
+	assert.Equal(t, int64(1), stats.Failovers.Value())
+}
@@ -31,6 +31,7 @@ const (
 	NamespaceKey                 = "sgw"
 	ResourceUtilizationSubsystem = "resource_utilization"
 	ConfigSubsystem              = "config"
+	ServerConnectionSubsystem    = "server_connection"
 
 	SubsystemCacheKey           = "cache"
 	SubsystemDatabaseKey        = "database"
@@ -46,6 +47,7 @@ const (
 	DatabaseLabelKey    = "database"
 	ReplicationLabelKey = "replication"
 	CollectionLabelKey  = "collection"
+	OutcomeLabelKey     = "outcome"
 )
 
 const (
@@ -63,8 +65,9 @@ const (
 const StatsGroupKeySyncGateway = "syncgateway"
 
 const (
-	PrometheusValueTypeGauge   = "gauge"
-	PrometheusValueTypeCounter = "counter"
+	PrometheusValueTypeGauge     = "gauge"
+	PrometheusValueTypeCounter   = "counter"
+	PrometheusValueTypeHistogram = "histogram"
 
 	StatUnitNoUnits       = ""
 	StatUnitPercent       = "percent"
@@ -73,10 +76,11 @@ const (
 	StatUnitSeconds       = "seconds"
 	StatUnitUnixTimestamp = "unix timestamp"
 
-	StatFormatInt      = "int"
-	StatFormatFloat    = "float"
-	StatFormatDuration = "duration"
-	StatFormatBool     = "bool"
+	StatFormatInt       = "int"
+	StatFormatFloat     = "float"
+	StatFormatDuration  = "duration"
+	StatFormatBool      = "bool"
+	StatFormatHistogram = "histogram"
 
 	StatAddedVersion3dot0dot0 = "3.0.0"
 	StatAddedVersion3dot1dot0 = "3.1.0"
@@ -159,8 +163,9 @@ func (s *SgwStats) String() string {
 }
 
 type GlobalStat struct {
-	ResourceUtilization *ResourceUtilization `json:"resource_utilization"`
-	ConfigStat          *ConfigStat          `json:"config"`
+	ResourceUtilization *ResourceUtilization  `json:"resource_utilization"`
+	ConfigStat          *ConfigStat           `json:"config"`
+	ServerConnection    *ServerConnectionStat `json:"server_connection"`
 }
 
 func newGlobalStat() (*GlobalStat, error) {
@@ -173,6 +178,10 @@ func newGlobalStat() (*GlobalStat, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = g.initServerConnectionStats()
+	if err != nil {
+		return nil, err
+	}
 	return g, nil
 }
 
@@ -187,6 +196,25 @@ func (g *GlobalStat) initConfigStats() error {
 	return nil
 }
 
+func (g *GlobalStat) initServerConnectionStats() error {
+	serverConnectionStat := &ServerConnectionStat{}
+	var err error
+	serverConnectionStat.Reconnects, err = NewIntStat(ServerConnectionSubsystem, "reconnects", StatUnitNoUnits, ServerConnectionReconnectsDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityCommitted, nil, nil, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	serverConnectionStat.Failovers, err = NewIntStat(ServerConnectionSubsystem, "failovers", StatUnitNoUnits, ServerConnectionFailoversDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityCommitted, nil, nil, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	serverConnectionStat.DNSResolutionErrors, err = NewIntStat(ServerConnectionSubsystem, "dns_resolution_errors", StatUnitNoUnits, ServerConnectionDNSResolutionErrorsDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityCommitted, nil, nil, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	g.ServerConnection = serverConnectionStat
+	return nil
+}
+
 func (g *GlobalStat) initResourceUtilizationStats() error {
 	var err error
 	resUtil := &ResourceUtilization{}
@@ -199,6 +227,14 @@ func (g *GlobalStat) initResourceUtilizationStats() error {
 	if err != nil {
 		return err
 	}
+	resUtil.BulkMemoryInFlight, err = NewIntStat(ResourceUtilizationSubsystem, "bulk_memory_in_flight", StatUnitBytes, BulkMemoryInFlightDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityCommitted, nil, nil, prometheus.GaugeValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.BulkMemoryRejectedCount, err = NewIntStat(ResourceUtilizationSubsystem, "bulk_memory_rejected_count", StatUnitNoUnits, BulkMemoryRejectedCountDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityCommitted, nil, nil, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
 	resUtil.ErrorCount, err = NewIntStat(ResourceUtilizationSubsystem, "error_count", StatUnitNoUnits, ErrorCountDesc, StatAddedVersion3dot0dot0, StatDeprecatedVersionNotDeprecated, StatStabilityCommitted, nil, nil, prometheus.CounterValue, 0)
 	if err != nil {
 		return err
@@ -255,6 +291,10 @@ func (g *GlobalStat) initResourceUtilizationStats() error {
 	if err != nil {
 		return err
 	}
+	resUtil.RateLimitedRequestCount, err = NewIntStat(ResourceUtilizationSubsystem, "rate_limited_request_count", StatUnitNoUnits, RateLimitedRequestCountDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityCommitted, nil, nil, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
 	resUtil.SystemMemoryTotal, err = NewIntStat(ResourceUtilizationSubsystem, "system_memory_total", StatUnitBytes, SystemMemoryTotalDesc, StatAddedVersion3dot0dot0, StatDeprecatedVersionNotDeprecated, StatStabilityCommitted, nil, nil, prometheus.GaugeValue, 0)
 	if err != nil {
 		return err
@@ -297,6 +337,10 @@ type ResourceUtilization struct {
 	AdminNetworkInterfaceBytesReceived *SgwIntStat `json:"admin_net_bytes_recv"`
 	// The total number of bytes sent (since node start-up) on the network interface to which the Sync Gateway api.admin_interface is bound.
 	AdminNetworkInterfaceBytesSent *SgwIntStat `json:"admin_net_bytes_sent"`
+	// The estimated memory in bytes used by in-flight _bulk_docs and _bulk_get requests.
+	BulkMemoryInFlight *SgwIntStat `json:"bulk_memory_in_flight"`
+	// The total number of _bulk_docs and _bulk_get requests, or documents in _bulk_get responses, rejected for exceeding the bulk request memory limits.
+	BulkMemoryRejectedCount *SgwIntStat `json:"bulk_memory_rejected_count"`
 	// The total number of errors logged.
 	ErrorCount             *SgwIntStat `json:"error_count"`
 	GoMemstatsHeapAlloc    *SgwIntStat `json:"go_memstats_heapalloc"`
@@ -322,6 +366,8 @@ type ResourceUtilization struct {
 	PublicNetworkInterfaceBytesReceived *SgwIntStat `json:"pub_net_bytes_recv"`
 	// The total number of bytes sent (since node start-up) on the network interface to which Sync Gateway api.public_interface is bound.
 	PublicNetworkInterfaceBytesSent *SgwIntStat `json:"pub_net_bytes_sent"`
+	// The total number of public API requests rejected for exceeding the per-client rate limits.
+	RateLimitedRequestCount *SgwIntStat `json:"rate_limited_request_count"`
 	// The total memory available on the system in bytes.
 	SystemMemoryTotal *SgwIntStat `json:"system_memory_total"`
 	// The total number of warnings logged.
@@ -335,11 +381,22 @@ type ConfigStat struct {
 	DatabaseBucketMismatches *SgwIntStat `json:"database_config_bucket_mismatches"`
 }
 
+type ServerConnectionStat struct {
+	// The number of times Sync Gateway reconnected to Couchbase Server because the server's DNS records changed.
+	Reconnects *SgwIntStat `json:"reconnects"`
+	// The number of reconnects where none of the previously resolved Couchbase Server addresses remained.
+	Failovers *SgwIntStat `json:"failovers"`
+	// The number of times re-resolving the Couchbase Server DNS records failed.
+	DNSResolutionErrors *SgwIntStat `json:"dns_resolution_errors"`
+}
+
 type DbStats struct {
 	dbName                  string
 	CacheStats              *CacheStats                   `json:"cache,omitempty"`
 	CBLReplicationPullStats *CBLReplicationPullStats      `json:"cbl_replication_pull,omitempty"`
 	CBLReplicationPushStats *CBLReplicationPushStats      `json:"cbl_replication_push,omitempty"`
+	CBLReplicationErrStats  *CBLReplicationErrorStats     `json:"cbl_replication_errors,omitempty"`
+	CBLReplicationMsgStats  *CBLReplicationMessageStats   `json:"cbl_replication_messages,omitempty"`
 	DatabaseStats           *DatabaseStats                `json:"database,omitempty"`
 	DeltaSyncStats          *DeltaSyncStats               `json:"delta_sync,omitempty"`
 	QueryStats              *QueryStats                   `json:"gsi_views,omitempty"`
@@ -347,6 +404,7 @@ type DbStats struct {
 	SecurityStats           *SecurityStats                `json:"security,omitempty"`
 	SharedBucketImportStats *SharedBucketImportStats      `json:"shared_bucket_import,omitempty"`
 	CollectionStats         map[string]*CollectionStats   `json:"per_collection,omitempty"`
+	ChannelSizeStats        *ChannelSizeStats             `json:"-"` // Per-channel sizes are only exported to prometheus, to avoid unbounded expvars
 }
 
 type CacheStats struct {
@@ -354,6 +412,8 @@ type CacheStats struct {
 	AbandonedSeqs *SgwIntStat `json:"abandoned_seqs"`
 	// The total number of active revisions in the channel cache.
 	ChannelCacheRevsActive *SgwIntStat `json:"chan_cache_active_revs"`
+	// The total number of channel backfill queries cancelled because every changes request waiting for them was closed.
+	ChannelCacheBackfillsCancelled *SgwIntStat `json:"chan_cache_backfills_cancelled"`
 	// The total number of transient bypass channel caches created to serve requests when the channel cache was at capacity.
 	ChannelCacheBypassCount *SgwIntStat `json:"chan_cache_bypass_count"`
 	// The total number of channel caches added.
@@ -382,6 +442,16 @@ type CacheStats struct {
 	ChannelCacheRevsRemoval *SgwIntStat `json:"chan_cache_removal_revs"`
 	// The total number of tombstone revisions in the channel cache.
 	ChannelCacheRevsTombstone *SgwIntStat `json:"chan_cache_tombstone_revs"`
+	// The estimated total size in bytes of the deltas in the delta cache.
+	DeltaCacheMemoryBytes *SgwIntStat `json:"delta_cache_memory_bytes"`
+	// The total number of deltas evicted from the delta cache to stay within its memory limit.
+	DeltaCacheMemoryEvictions *SgwIntStat `json:"delta_cache_memory_evictions"`
+	// The current number of mutation feed events buffered for the change cache.
+	FeedBufferDepth *SgwIntStat `json:"feed_buffer_depth"`
+	// The total number of times the mutation feed was paused because the change cache's feed buffer was full.
+	FeedBufferPauseCount *SgwIntStat `json:"feed_buffer_pause_count"`
+	// The total time the mutation feed was paused because the change cache's feed buffer was full.
+	FeedBufferPauseTime *SgwIntStat `json:"feed_buffer_pause_time"`
 	// The highest sequence number cached.
 	//
 	// There may be skipped sequences lower than high_seq_cached.
@@ -401,6 +471,10 @@ type CacheStats struct {
 	RevisionCacheHits *SgwIntStat `json:"rev_cache_hits"`
 	// The total number of revision cache misses.
 	RevisionCacheMisses *SgwIntStat `json:"rev_cache_misses"`
+	// The estimated total size in bytes of the revisions in the revision cache.
+	RevisionCacheMemoryBytes *SgwIntStat `json:"rev_cache_memory_bytes"`
+	// The total number of revisions evicted from the revision cache to stay within its memory limit.
+	RevisionCacheMemoryEvictions *SgwIntStat `json:"rev_cache_memory_evictions"`
 	// The current length of the pending skipped sequence queue.
 	SkippedSeqLen *SgwIntStat `json:"skipped_seq_len"`
 	// The total view_queries.
@@ -462,6 +536,14 @@ type CBLReplicationPushStats struct {
 	ProposeChangeTime *SgwIntStat `json:"propose_change_time"`
 	// Total time spent processing writes. Measures complete request-to-response time for a write.
 	WriteProcessingTime *SgwIntStat `json:"write_processing_time"`
+	// The total number of pushed revisions that were delayed because the connection had too many revisions pending.
+	RevThrottledCount *SgwIntStat `json:"rev_throttled_count"`
+	// The total time pushed revisions spent delayed because the connection had too many revisions pending.
+	RevThrottledTime *SgwIntStat `json:"rev_throttled_time"`
+	// The current number of documents proposed by clients as new, that haven't yet been pushed.
+	PendingInsertions *SgwIntStat `json:"pending_insertions"`
+	// The total number of documents proposed as new that weren't tracked because the connection had too many pending insertions.
+	PendingInsertionsOverflowCount *SgwIntStat `json:"pending_insertions_overflow_count"`
 }
 
 // CollectionStats are stats that are tracked on a per-collection basis.
@@ -476,6 +558,12 @@ type CollectionStats struct {
 	SyncFunctionRejectAccessCount *SgwIntStat `json:"sync_function_reject_access_count"`
 	// The total number of times the sync function encountered an exception for this collection.
 	SyncFunctionExceptionCount *SgwIntStat `json:"sync_function_exception_count"`
+	// The total number of times the sync function timed out for this collection.
+	SyncFunctionTimeoutCount *SgwIntStat `json:"sync_function_timeout_count"`
+	// The total number of writes to this collection that used the staged sync function.
+	StagedSyncFunctionCount *SgwIntStat `json:"staged_sync_function_count"`
+	// The total number of writes to this collection where the staged and current sync functions produced different results.
+	StagedSyncFunctionDivergenceCount *SgwIntStat `json:"staged_sync_function_divergence_count"`
 
 	// The total number of documents imported to this collection since Sync Gateway node startup.
 	ImportCount *SgwIntStat `json:"import_count"`
@@ -489,6 +577,16 @@ type CollectionStats struct {
 	NumDocWrites *SgwIntStat `json:"num_doc_writes"`
 	// The total number of bytes written to this collection as part of document writes since Sync Gateway node startup.
 	DocWritesBytes *SgwIntStat `json:"doc_writes_bytes"`
+
+	// The estimated total size in bytes of this collection's revisions in the revision cache.
+	RevisionCacheMemoryBytes *SgwIntStat `json:"rev_cache_memory_bytes"`
+
+	// The state of the circuit breaker for KV operations on this collection: 0 closed, 1 open, 2 half-open.
+	KvCircuitBreakerState *SgwIntStat `json:"kv_circuit_breaker_state"`
+	// The total number of times the circuit breaker for KV operations on this collection opened.
+	KvCircuitBreakerOpens *SgwIntStat `json:"kv_circuit_breaker_opens"`
+	// The total number of KV operations on this collection rejected by the circuit breaker or for exceeding their timeout budget.
+	KvOpsRejected *SgwIntStat `json:"kv_ops_rejected"`
 }
 
 type DatabaseStats struct {
@@ -498,6 +596,12 @@ type DatabaseStats struct {
 	CompactionAttachmentStartTime *SgwIntStat `json:"compaction_attachment_start_time"`
 	// The compaction_tombstone_start_time.
 	CompactionTombstoneStartTime *SgwIntStat `json:"compaction_tombstone_start_time"`
+	// The total number of channel history entries removed or merged from document sync metadata by the channel history retention policy.
+	ChannelHistoryEntriesPruned *SgwIntStat `json:"channel_history_entries_pruned"`
+	// The total number of continuous _changes feeds disconnected because the client was too slow to read them.
+	ChangesSlowConsumerDisconnectCount *SgwIntStat `json:"changes_slow_consumer_disconnect_count"`
+	// The total number of continuous _changes feed entries that took longer than the slow write threshold to write.
+	ChangesSlowWriteCount *SgwIntStat `json:"changes_slow_write_count"`
 	// The total number of writes that left the document in a conflicted state. Includes new conflicts, and mutations that don’t resolve existing conflicts.
 	ConflictWriteCount *SgwIntStat `json:"conflict_write_count"`
 	// The total number of instances during import when the document cas had changed, but the document was not imported because the document body had not changed.
@@ -522,6 +626,16 @@ type DatabaseStats struct {
 	HighSeqFeed *SgwIntStat `json:"high_seq_feed"`
 	// The number of attachments compacted
 	NumAttachmentsCompacted *SgwIntStat `json:"num_attachments_compacted"`
+	// The total number of attachments checked by the attachment integrity scanner.
+	NumAttachmentsVerified *SgwIntStat `json:"num_attachments_verified"`
+	// The total number of attachments found by the attachment integrity scanner not to match their digest.
+	NumAttachmentsCorrupt *SgwIntStat `json:"num_attachments_corrupt"`
+	// The total number of corrupt attachments quarantined by the attachment integrity scanner.
+	NumAttachmentsQuarantined *SgwIntStat `json:"num_attachments_quarantined"`
+	// The total number of attachments rejected on write because they exceed the maximum size allowed by the attachment policy.
+	NumAttachmentsRejectedSize *SgwIntStat `json:"num_attachments_rejected_size"`
+	// The total number of attachments rejected on write because their content type is not allowed by the attachment policy.
+	NumAttachmentsRejectedContentType *SgwIntStat `json:"num_attachments_rejected_content_type"`
 	// The total number of documents read via Couchbase Lite 2.x replication since Sync Gateway node startup.
 	NumDocReadsBlip *SgwIntStat `json:"num_doc_reads_blip"`
 	// The total number of documents read via the REST API since Sync Gateway node startup. Includes Couchbase Lite 1.x replication.
@@ -535,6 +649,12 @@ type DatabaseStats struct {
 	// The total number of replications created since Sync Gateway node startup.
 	NumReplicationsTotal   *SgwIntStat `json:"num_replications_total"`
 	NumTombstonesCompacted *SgwIntStat `json:"num_tombstones_compacted"`
+	// The total number of old revision bodies backed up for delta sync and in-flight replications.
+	OldRevBackupCount *SgwIntStat `json:"old_rev_backup_count"`
+	// The total number of bytes in old revision bodies backed up for delta sync and in-flight replications.
+	OldRevBackupBytes *SgwIntStat `json:"old_rev_backup_bytes"`
+	// The total number of old revision body lookups that found no backup, because it had expired or been removed.
+	OldRevBackupMissCount *SgwIntStat `json:"old_rev_backup_miss_count"`
 	// Number of bytes written over public interface for REST api
 	PublicRestBytesWritten *SgwIntStat `json:"public_rest_bytes_written"`
 	// The total amount of bytes read over the public REST api
@@ -549,6 +669,32 @@ type DatabaseStats struct {
 	SequenceReleasedCount *SgwIntStat `json:"sequence_released_count"`
 	// The total number of sequences reserved by Sync Gateway.
 	SequenceReservedCount *SgwIntStat `json:"sequence_reserved_count"`
+	// The total time spent incrementing the sequence counter document. Divide by sequence_incr_count for the average latency.
+	SequenceIncrTime *SgwIntStat `json:"sequence_incr_time"`
+	// The total number of sequence batch reservations where another Sync Gateway node had incremented the sequence counter document since this node last reserved sequences.
+	SequenceIncrConflictCount *SgwIntStat `json:"sequence_incr_conflict_count"`
+	// The total time spent waiting to acquire the sequence allocator lock when assigning sequences.
+	SequenceAllocatorLockWaitTime *SgwIntStat `json:"sequence_allocator_lock_wait_time"`
+	// The current number of sequences reserved per increment of the sequence counter document.
+	SequenceBatchSize *SgwIntStat `json:"sequence_batch_size"`
+	// The current number of sequences reserved by this node that haven't yet been assigned or released.
+	SequenceReleasePending *SgwIntStat `json:"sequence_release_pending"`
+	// The current time waited after a sequence reservation before releasing the reserved sequences left unused.
+	SequenceReleaseWait *SgwIntStat `json:"sequence_release_wait"`
+	// The total number of times the sequence counter document was found to be lower than sequences already allocated, for example after the bucket was flushed or restored from a backup.
+	SequenceRegressionCount *SgwIntStat `json:"sequence_regression_count"`
+	// The number of mismatches between the sequence counter and the bucket found by the startup consistency check when the database was last started.
+	SequenceConsistencyAnomalies *SgwIntStat `json:"sequence_consistency_anomalies"`
+	// The total number of document writes not made again because they were retries with the idempotency key of an earlier write.
+	NumIdempotentReplays *SgwIntStat `json:"num_idempotent_replays"`
+	// The total number of times two replicators were detected concurrently writing the checkpoint of the same client ID.
+	DuplicateCheckpointWriterCount *SgwIntStat `json:"duplicate_checkpoint_writer_count"`
+	// The total number of checkpoint writes rejected because another replicator was writing the checkpoint of the same client ID.
+	DuplicateCheckpointFencedCount *SgwIntStat `json:"duplicate_checkpoint_fenced_count"`
+	// The total number of revisions rejected because the user had reached their daily read or write quota.
+	QuotaRejectedCount *SgwIntStat `json:"quota_rejected_count"`
+	// The total number of revisions delayed because the user had reached their daily read or write quota.
+	QuotaThrottledCount *SgwIntStat `json:"quota_throttled_count"`
 	// The total number of warnings relating to the channel name size.
 	WarnChannelNameSizeCount *SgwIntStat `json:"warn_channel_name_size_count"`
 	// The total number of warnings relating to the channel count exceeding the channel count threshold.
@@ -566,12 +712,26 @@ type DatabaseStats struct {
 	TotalSyncTime *SgwIntStat `json:"total_sync_time"`
 	// The total number of times that a sync function encountered an exception (across all collections).
 	SyncFunctionExceptionCount *SgwIntStat `json:"sync_function_exception_count"`
+	// The total number of times that a sync function timed out (across all collections).
+	SyncFunctionTimeoutCount *SgwIntStat `json:"sync_function_timeout_count"`
+	// The distribution of sync function execution times (across all collections), by outcome: accepted, rejected or exception.
+	SyncFunctionDuration *SgwHistogramStat `json:"sync_function_duration"`
 	// The total number of times a replication connection is rejected due ot it being over the threshold
 	NumReplicationsRejectedLimit *SgwIntStat `json:"num_replications_rejected_limit"`
+	// The number of active replication connections that negotiated version 2 of the replication protocol.
+	NumReplicationsActiveProtocolV2 *SgwIntStat `json:"num_replications_active_protocol_v2"`
+	// The number of active replication connections that negotiated version 3 of the replication protocol.
+	NumReplicationsActiveProtocolV3 *SgwIntStat `json:"num_replications_active_protocol_v3"`
+	// The number of active replication connections that negotiated delta sync.
+	NumReplicationsActiveDeltas *SgwIntStat `json:"num_replications_active_deltas"`
+	// The number of active replication connections from clients that negotiated collections support using getCollections.
+	NumReplicationsActiveCollectionsAware *SgwIntStat `json:"num_replications_active_collections_aware"`
 	// Represents the compute unit for import processes on the database
 	ImportProcessCompute *SgwIntStat `json:"import_process_compute"`
 	// SyncProcessCompute the compute unit for syncing with clients
 	SyncProcessCompute *SgwIntStat `json:"sync_process_compute"`
+	// Set to 1 once counters have been restored from the values persisted before a restart.
+	StatsRestarted *SgwIntStat `json:"stats_restarted"`
 
 	// These can be cleaned up in future versions of SGW, implemented as maps to reduce amount of potential risk
 	// prior to Hydrogen release. These are not exported as part of prometheus and only exposed through expvars
@@ -759,6 +919,12 @@ type SharedBucketImportStats struct {
 	ImportHighSeq *SgwIntStat `json:"import_high_seq"`
 	// The total number of import partitions.
 	ImportPartitions *SgwIntStat `json:"import_partitions"`
+	// The number of documents currently quarantined after repeatedly failing import.
+	ImportQuarantineCount *SgwIntStat `json:"import_quarantine_count"`
+	// The total number of mutations not imported because the document was quarantined.
+	ImportQuarantineSkippedCount *SgwIntStat `json:"import_quarantine_skipped_count"`
+	// The total number of docs imported by import backfill runs.
+	ImportBackfillCount *SgwIntStat `json:"import_backfill_count"`
 }
 
 type SgwStatWrapper interface {
@@ -1055,6 +1221,115 @@ func (s *SgwDurStat) String() string {
 	return strconv.Itoa(int(time.Since(s.StartTime).Nanoseconds()))
 }
 
+// SgwHistogramStat is a wrapper around SgwStat for reporting the distribution of observed values, such as latencies, as
+// a Prometheus histogram. Observations are broken out by the value of a single variable label, such as an outcome.
+type SgwHistogramStat struct {
+	SgwStat
+	variableLabel string
+	histogram     *prometheus.HistogramVec
+	totalsLock    sync.Mutex
+	totals        map[string]histogramTotals // Count and sum of observations per label value, for expvars
+}
+
+// histogramTotals is the expvar representation of the observations for a single label value of a histogram.
+type histogramTotals struct {
+	Count int64   `json:"count"`
+	Sum   float64 `json:"sum"`
+}
+
+// SyncFunctionDurationBuckets are the upper bounds, in seconds, of the buckets used for sync function duration histograms.
+var SyncFunctionDurationBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// NewHistogramStat creates a new histogram stat with the given buckets, broken out by variableLabel, and registers it
+// with Prometheus.
+func NewHistogramStat(subsystem, key, unit, description, addedVersion, deprecatedVersion, stability string, labelKeys, labelVals []string, variableLabel string, buckets []float64) (*SgwHistogramStat, error) {
+	stat, err := newSGWStat(subsystem, key, unit, description, addedVersion, deprecatedVersion, stability, labelKeys, labelVals, prometheus.UntypedValue)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedStat := &SgwHistogramStat{
+		SgwStat:       *stat,
+		variableLabel: variableLabel,
+		histogram: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   NamespaceKey,
+			Subsystem:   subsystem,
+			Name:        key,
+			Help:        description,
+			ConstLabels: stat.labels,
+			Buckets:     buckets,
+		}, []string{variableLabel}),
+		totals: make(map[string]histogramTotals),
+	}
+
+	if !SkipPrometheusStatsRegistration {
+		err := prometheus.Register(wrappedStat)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return wrappedStat, nil
+}
+
+func (s *SgwHistogramStat) FormatString() string {
+	return StatFormatHistogram
+}
+
+func (s *SgwHistogramStat) ValueTypeString() string {
+	return PrometheusValueTypeHistogram
+}
+
+// LabelKeys returns the constant label keys of the stat, along with the variable label.
+func (s *SgwHistogramStat) LabelKeys() []string {
+	return append(s.SgwStat.LabelKeys(), s.variableLabel)
+}
+
+func (s *SgwHistogramStat) Describe(ch chan<- *prometheus.Desc) {
+	s.histogram.Describe(ch)
+}
+
+func (s *SgwHistogramStat) Collect(ch chan<- prometheus.Metric) {
+	s.histogram.Collect(ch)
+}
+
+// Observe records value against the given value of the variable label.
+func (s *SgwHistogramStat) Observe(labelValue string, value float64) {
+	s.histogram.WithLabelValues(labelValue).Observe(value)
+
+	s.totalsLock.Lock()
+	defer s.totalsLock.Unlock()
+	totals := s.totals[labelValue]
+	totals.Count++
+	totals.Sum += value
+	s.totals[labelValue] = totals
+}
+
+// ObserveDuration records duration in seconds against the given value of the variable label.
+func (s *SgwHistogramStat) ObserveDuration(labelValue string, duration time.Duration) {
+	s.Observe(labelValue, duration.Seconds())
+}
+
+// Totals returns the number and sum of observations for the given value of the variable label.
+func (s *SgwHistogramStat) Totals(labelValue string) (count int64, sum float64) {
+	s.totalsLock.Lock()
+	defer s.totalsLock.Unlock()
+	totals := s.totals[labelValue]
+	return totals.Count, totals.Sum
+}
+
+// MarshalJSON returns the count and sum of observations for each value of the variable label.
+func (s *SgwHistogramStat) MarshalJSON() ([]byte, error) {
+	s.totalsLock.Lock()
+	defer s.totalsLock.Unlock()
+	return JSONMarshal(s.totals)
+}
+
+func (s *SgwHistogramStat) String() string {
+	data, _ := s.MarshalJSON()
+	return string(data)
+}
+
 type QueryStat struct {
 	QueryCount      *SgwIntStat
 	QueryErrorCount *SgwIntStat
@@ -1095,6 +1370,21 @@ func (s *SgwStats) NewDBStats(name string, deltaSyncEnabled bool, importEnabled
 		return nil, err
 	}
 
+	err = dbStats.initChannelSizeStats()
+	if err != nil {
+		return nil, err
+	}
+
+	err = dbStats.initCBLReplicationErrorStats()
+	if err != nil {
+		return nil, err
+	}
+
+	err = dbStats.initCBLReplicationMessageStats()
+	if err != nil {
+		return nil, err
+	}
+
 	if deltaSyncEnabled {
 		err = dbStats.InitDeltaSyncStats()
 		if err != nil {
@@ -1158,6 +1448,9 @@ func (s *SgwStats) ClearDBStats(name string) {
 	}
 
 	s.DbStats[name].unregisterQueryStats()
+	s.DbStats[name].unregisterChannelSizeStats()
+	s.DbStats[name].unregisterCBLReplicationErrorStats()
+	s.DbStats[name].unregisterCBLReplicationMessageStats()
 
 	delete(s.DbStats, name)
 
@@ -1185,6 +1478,10 @@ func (d *DbStats) initCacheStats() error {
 	if err != nil {
 		return err
 	}
+	resUtil.ChannelCacheBackfillsCancelled, err = NewIntStat(SubsystemCacheKey, "chan_cache_backfills_cancelled", StatUnitNoUnits, ChanCacheBackfillsCancelledDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
 	resUtil.ChannelCacheBypassCount, err = NewIntStat(SubsystemCacheKey, "chan_cache_bypass_count", StatUnitNoUnits, ChanCacheBypassCountDesc, StatAddedVersion3dot0dot0, StatDeprecatedVersionNotDeprecated, StatStabilityCommitted, labelKeys, labelVals, prometheus.CounterValue, 0)
 	if err != nil {
 		return err
@@ -1237,6 +1534,26 @@ func (d *DbStats) initCacheStats() error {
 	if err != nil {
 		return err
 	}
+	resUtil.DeltaCacheMemoryBytes, err = NewIntStat(SubsystemCacheKey, "delta_cache_memory_bytes", StatUnitBytes, DeltaCacheMemoryBytesDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.GaugeValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.DeltaCacheMemoryEvictions, err = NewIntStat(SubsystemCacheKey, "delta_cache_memory_evictions", StatUnitNoUnits, DeltaCacheMemoryEvictionsDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.FeedBufferDepth, err = NewIntStat(SubsystemCacheKey, "feed_buffer_depth", StatUnitNoUnits, FeedBufferDepthDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.GaugeValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.FeedBufferPauseCount, err = NewIntStat(SubsystemCacheKey, "feed_buffer_pause_count", StatUnitNoUnits, FeedBufferPauseCountDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.FeedBufferPauseTime, err = NewIntStat(SubsystemCacheKey, "feed_buffer_pause_time", StatUnitNanoseconds, FeedBufferPauseTimeDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
 	resUtil.HighSeqCached, err = NewIntStat(SubsystemCacheKey, "high_seq_cached", StatUnitNoUnits, HighSeqCachedDesc, StatAddedVersion3dot0dot0, StatDeprecatedVersionNotDeprecated, StatStabilityCommitted, labelKeys, labelVals, prometheus.CounterValue, 0)
 	if err != nil {
 		return err
@@ -1273,6 +1590,14 @@ func (d *DbStats) initCacheStats() error {
 	if err != nil {
 		return err
 	}
+	resUtil.RevisionCacheMemoryBytes, err = NewIntStat(SubsystemCacheKey, "rev_cache_memory_bytes", StatUnitBytes, RevCacheMemoryBytesDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.GaugeValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.RevisionCacheMemoryEvictions, err = NewIntStat(SubsystemCacheKey, "rev_cache_memory_evictions", StatUnitNoUnits, RevCacheMemoryEvictionsDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
 	resUtil.SkippedSeqLen, err = NewIntStat(SubsystemCacheKey, "skipped_seq_len", StatUnitNoUnits, SkippedSeqLengthDesc, StatAddedVersion3dot0dot0, StatDeprecatedVersionNotDeprecated, StatStabilityCommitted, labelKeys, labelVals, prometheus.GaugeValue, 0)
 	if err != nil {
 		return err
@@ -1289,6 +1614,7 @@ func (d *DbStats) initCacheStats() error {
 func (d *DbStats) unregisterCacheStats() {
 	prometheus.Unregister(d.CacheStats.AbandonedSeqs)
 	prometheus.Unregister(d.CacheStats.ChannelCacheRevsActive)
+	prometheus.Unregister(d.CacheStats.ChannelCacheBackfillsCancelled)
 	prometheus.Unregister(d.CacheStats.ChannelCacheBypassCount)
 	prometheus.Unregister(d.CacheStats.ChannelCacheChannelsAdded)
 	prometheus.Unregister(d.CacheStats.ChannelCacheChannelsEvictedInactive)
@@ -1302,6 +1628,11 @@ func (d *DbStats) unregisterCacheStats() {
 	prometheus.Unregister(d.CacheStats.ChannelCachePendingQueries)
 	prometheus.Unregister(d.CacheStats.ChannelCacheRevsRemoval)
 	prometheus.Unregister(d.CacheStats.ChannelCacheRevsTombstone)
+	prometheus.Unregister(d.CacheStats.DeltaCacheMemoryBytes)
+	prometheus.Unregister(d.CacheStats.DeltaCacheMemoryEvictions)
+	prometheus.Unregister(d.CacheStats.FeedBufferDepth)
+	prometheus.Unregister(d.CacheStats.FeedBufferPauseCount)
+	prometheus.Unregister(d.CacheStats.FeedBufferPauseTime)
 	prometheus.Unregister(d.CacheStats.HighSeqCached)
 	prometheus.Unregister(d.CacheStats.HighSeqStable)
 	prometheus.Unregister(d.CacheStats.NonMobileIgnoredCount)
@@ -1311,6 +1642,8 @@ func (d *DbStats) unregisterCacheStats() {
 	prometheus.Unregister(d.CacheStats.RevisionCacheBypass)
 	prometheus.Unregister(d.CacheStats.RevisionCacheHits)
 	prometheus.Unregister(d.CacheStats.RevisionCacheMisses)
+	prometheus.Unregister(d.CacheStats.RevisionCacheMemoryBytes)
+	prometheus.Unregister(d.CacheStats.RevisionCacheMemoryEvictions)
 	prometheus.Unregister(d.CacheStats.SkippedSeqLen)
 	prometheus.Unregister(d.CacheStats.ViewQueries)
 }
@@ -1456,6 +1789,22 @@ func (d *DbStats) initCBLReplicationPushStats() error {
 	if err != nil {
 		return err
 	}
+	resUtil.RevThrottledCount, err = NewIntStat(SubsystemReplicationPush, "rev_throttled_count", StatUnitNoUnits, RevThrottledCountDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.RevThrottledTime, err = NewIntStat(SubsystemReplicationPush, "rev_throttled_time", StatUnitNanoseconds, RevThrottledTimeDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.PendingInsertions, err = NewIntStat(SubsystemReplicationPush, "pending_insertions", StatUnitNoUnits, PendingInsertionsDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.GaugeValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.PendingInsertionsOverflowCount, err = NewIntStat(SubsystemReplicationPush, "pending_insertions_overflow_count", StatUnitNoUnits, PendingInsertionsOverflowCountDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
 
 	d.CBLReplicationPushStats = resUtil
 	return nil
@@ -1469,6 +1818,10 @@ func (d *DbStats) unregisterCBLReplicationPushStats() {
 	prometheus.Unregister(d.CBLReplicationPushStats.ProposeChangeCount)
 	prometheus.Unregister(d.CBLReplicationPushStats.ProposeChangeTime)
 	prometheus.Unregister(d.CBLReplicationPushStats.WriteProcessingTime)
+	prometheus.Unregister(d.CBLReplicationPushStats.RevThrottledCount)
+	prometheus.Unregister(d.CBLReplicationPushStats.RevThrottledTime)
+	prometheus.Unregister(d.CBLReplicationPushStats.PendingInsertions)
+	prometheus.Unregister(d.CBLReplicationPushStats.PendingInsertionsOverflowCount)
 }
 
 func (d *DbStats) CBLReplicationPush() *CBLReplicationPushStats {
@@ -1497,6 +1850,18 @@ func (d *DbStats) initDatabaseStats() error {
 	if err != nil {
 		return err
 	}
+	resUtil.ChannelHistoryEntriesPruned, err = NewIntStat(SubsystemDatabaseKey, "channel_history_entries_pruned", StatUnitNoUnits, ChannelHistoryEntriesPrunedDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.ChangesSlowConsumerDisconnectCount, err = NewIntStat(SubsystemDatabaseKey, "changes_slow_consumer_disconnect_count", StatUnitNoUnits, ChangesSlowConsumerDisconnectCountDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.ChangesSlowWriteCount, err = NewIntStat(SubsystemDatabaseKey, "changes_slow_write_count", StatUnitNoUnits, ChangesSlowWriteCountDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
 	resUtil.ConflictWriteCount, err = NewIntStat(SubsystemDatabaseKey, "conflict_write_count", StatUnitNoUnits, ConflictWriteCountDesc, StatAddedVersion3dot0dot0, StatDeprecatedVersionNotDeprecated, StatStabilityCommitted, labelKeys, labelVals, prometheus.CounterValue, 0)
 	if err != nil {
 		return err
@@ -1545,6 +1910,26 @@ func (d *DbStats) initDatabaseStats() error {
 	if err != nil {
 		return err
 	}
+	resUtil.NumAttachmentsVerified, err = NewIntStat(SubsystemDatabaseKey, "num_attachments_verified", StatUnitNoUnits, NumAttachmentsVerifiedDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.NumAttachmentsCorrupt, err = NewIntStat(SubsystemDatabaseKey, "num_attachments_corrupt", StatUnitNoUnits, NumAttachmentsCorruptDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.NumAttachmentsQuarantined, err = NewIntStat(SubsystemDatabaseKey, "num_attachments_quarantined", StatUnitNoUnits, NumAttachmentsQuarantinedDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.NumAttachmentsRejectedSize, err = NewIntStat(SubsystemDatabaseKey, "num_attachments_rejected_size", StatUnitNoUnits, NumAttachmentsRejectedSizeDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.NumAttachmentsRejectedContentType, err = NewIntStat(SubsystemDatabaseKey, "num_attachments_rejected_content_type", StatUnitNoUnits, NumAttachmentsRejectedContentTypeDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
 	resUtil.DocWritesBytesBlip, err = NewIntStat(SubsystemDatabaseKey, "doc_writes_bytes_blip", StatUnitBytes, DocWritesBytesBlipDesc, StatAddedVersion3dot0dot0, StatDeprecatedVersionNotDeprecated, StatStabilityCommitted, labelKeys, labelVals, prometheus.CounterValue, 0)
 	if err != nil {
 		return err
@@ -1573,6 +1958,18 @@ func (d *DbStats) initDatabaseStats() error {
 	if err != nil {
 		return err
 	}
+	resUtil.OldRevBackupCount, err = NewIntStat(SubsystemDatabaseKey, "old_rev_backup_count", StatUnitNoUnits, OldRevBackupCountDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.OldRevBackupBytes, err = NewIntStat(SubsystemDatabaseKey, "old_rev_backup_bytes", StatUnitBytes, OldRevBackupBytesDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.OldRevBackupMissCount, err = NewIntStat(SubsystemDatabaseKey, "old_rev_backup_miss_count", StatUnitNoUnits, OldRevBackupMissCountDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
 	resUtil.PublicRestBytesRead, err = NewIntStat(SubsystemDatabaseKey, "public_rest_bytes_read", StatUnitBytes, PublicRestBytesReadDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
 	if err != nil {
 		return err
@@ -1597,6 +1994,58 @@ func (d *DbStats) initDatabaseStats() error {
 	if err != nil {
 		return err
 	}
+	resUtil.SequenceIncrTime, err = NewIntStat(SubsystemDatabaseKey, "sequence_incr_time", StatUnitNanoseconds, SequenceIncrTimeDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.SequenceIncrConflictCount, err = NewIntStat(SubsystemDatabaseKey, "sequence_incr_conflict_count", StatUnitNoUnits, SequenceIncrConflictCountDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.SequenceAllocatorLockWaitTime, err = NewIntStat(SubsystemDatabaseKey, "sequence_allocator_lock_wait_time", StatUnitNanoseconds, SequenceAllocatorLockWaitTimeDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.SequenceBatchSize, err = NewIntStat(SubsystemDatabaseKey, "sequence_batch_size", StatUnitNoUnits, SequenceBatchSizeDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.GaugeValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.SequenceReleasePending, err = NewIntStat(SubsystemDatabaseKey, "sequence_release_pending", StatUnitNoUnits, SequenceReleasePendingDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.GaugeValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.SequenceReleaseWait, err = NewIntStat(SubsystemDatabaseKey, "sequence_release_wait", StatUnitNanoseconds, SequenceReleaseWaitDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.GaugeValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.SequenceRegressionCount, err = NewIntStat(SubsystemDatabaseKey, "sequence_regression_count", StatUnitNoUnits, SequenceRegressionCountDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.SequenceConsistencyAnomalies, err = NewIntStat(SubsystemDatabaseKey, "sequence_consistency_anomalies", StatUnitNoUnits, SequenceConsistencyAnomaliesDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.GaugeValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.NumIdempotentReplays, err = NewIntStat(SubsystemDatabaseKey, "num_idempotent_replays", StatUnitNoUnits, NumIdempotentReplaysDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.DuplicateCheckpointWriterCount, err = NewIntStat(SubsystemDatabaseKey, "duplicate_checkpoint_writer_count", StatUnitNoUnits, DuplicateCheckpointWriterCountDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.DuplicateCheckpointFencedCount, err = NewIntStat(SubsystemDatabaseKey, "duplicate_checkpoint_fenced_count", StatUnitNoUnits, DuplicateCheckpointFencedCountDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.QuotaRejectedCount, err = NewIntStat(SubsystemDatabaseKey, "quota_rejected_count", StatUnitNoUnits, QuotaRejectedCountDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.QuotaThrottledCount, err = NewIntStat(SubsystemDatabaseKey, "quota_throttled_count", StatUnitNoUnits, QuotaThrottledCountDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
 	resUtil.WarnChannelNameSizeCount, err = NewIntStat(SubsystemDatabaseKey, "warn_channel_name_size_count", StatUnitNoUnits, WarnChannelNameSizeCountDesc, StatAddedVersion3dot0dot0, StatDeprecatedVersionNotDeprecated, StatStabilityCommitted, labelKeys, labelVals, prometheus.CounterValue, 0)
 	if err != nil {
 		return err
@@ -1625,10 +2074,34 @@ func (d *DbStats) initDatabaseStats() error {
 	if err != nil {
 		return err
 	}
+	resUtil.SyncFunctionTimeoutCount, err = NewIntStat(SubsystemDatabaseKey, "sync_function_timeout_count", StatUnitNoUnits, SyncFunctionTimeoutCountDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.SyncFunctionDuration, err = NewHistogramStat(SubsystemDatabaseKey, "sync_function_duration", StatUnitSeconds, SyncFunctionDurationDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, OutcomeLabelKey, SyncFunctionDurationBuckets)
+	if err != nil {
+		return err
+	}
 	resUtil.NumReplicationsRejectedLimit, err = NewIntStat(SubsystemDatabaseKey, "num_replications_rejected_limit", StatUnitNoUnits, NumReplicationsRejectedLimitDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
 	if err != nil {
 		return err
 	}
+	resUtil.NumReplicationsActiveProtocolV2, err = NewIntStat(SubsystemDatabaseKey, "num_replications_active_protocol_v2", StatUnitNoUnits, NumReplicationsActiveProtocolV2Desc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.GaugeValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.NumReplicationsActiveProtocolV3, err = NewIntStat(SubsystemDatabaseKey, "num_replications_active_protocol_v3", StatUnitNoUnits, NumReplicationsActiveProtocolV3Desc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.GaugeValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.NumReplicationsActiveDeltas, err = NewIntStat(SubsystemDatabaseKey, "num_replications_active_deltas", StatUnitNoUnits, NumReplicationsActiveDeltasDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.GaugeValue, 0)
+	if err != nil {
+		return err
+	}
+	resUtil.NumReplicationsActiveCollectionsAware, err = NewIntStat(SubsystemDatabaseKey, "num_replications_active_collections_aware", StatUnitNoUnits, NumReplicationsActiveCollectionsAwareDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.GaugeValue, 0)
+	if err != nil {
+		return err
+	}
 	resUtil.NumPublicRestRequests, err = NewIntStat(SubsystemDatabaseKey, "num_public_rest_requests", StatUnitNoUnits, NumPublicRestRequestsDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
 	if err != nil {
 		return err
@@ -1645,6 +2118,10 @@ func (d *DbStats) initDatabaseStats() error {
 	if err != nil {
 		return err
 	}
+	resUtil.StatsRestarted, err = NewIntStat(SubsystemDatabaseKey, "stats_restarted", StatUnitNoUnits, StatsRestartedDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.GaugeValue, 0)
+	if err != nil {
+		return err
+	}
 	resUtil.ImportFeedMapStats = &ExpVarMapWrapper{new(expvar.Map).Init()}
 
 	resUtil.CacheFeedMapStats = &ExpVarMapWrapper{new(expvar.Map).Init()}
@@ -1658,6 +2135,9 @@ func (d *DbStats) unregisterDatabaseStats() {
 	prometheus.Unregister(d.DatabaseStats.ReplicationBytesSent)
 	prometheus.Unregister(d.DatabaseStats.CompactionAttachmentStartTime)
 	prometheus.Unregister(d.DatabaseStats.CompactionTombstoneStartTime)
+	prometheus.Unregister(d.DatabaseStats.ChannelHistoryEntriesPruned)
+	prometheus.Unregister(d.DatabaseStats.ChangesSlowConsumerDisconnectCount)
+	prometheus.Unregister(d.DatabaseStats.ChangesSlowWriteCount)
 	prometheus.Unregister(d.DatabaseStats.ConflictWriteCount)
 	prometheus.Unregister(d.DatabaseStats.Crc32MatchCount)
 	prometheus.Unregister(d.DatabaseStats.DCPCachingCount)
@@ -1670,18 +2150,39 @@ func (d *DbStats) unregisterDatabaseStats() {
 	prometheus.Unregister(d.DatabaseStats.HighSeqFeed)
 	prometheus.Unregister(d.DatabaseStats.DocWritesBytesBlip)
 	prometheus.Unregister(d.DatabaseStats.NumAttachmentsCompacted)
+	prometheus.Unregister(d.DatabaseStats.NumAttachmentsVerified)
+	prometheus.Unregister(d.DatabaseStats.NumAttachmentsCorrupt)
+	prometheus.Unregister(d.DatabaseStats.NumAttachmentsQuarantined)
+	prometheus.Unregister(d.DatabaseStats.NumAttachmentsRejectedSize)
+	prometheus.Unregister(d.DatabaseStats.NumAttachmentsRejectedContentType)
 	prometheus.Unregister(d.DatabaseStats.NumDocReadsBlip)
 	prometheus.Unregister(d.DatabaseStats.NumDocReadsRest)
 	prometheus.Unregister(d.DatabaseStats.NumDocWrites)
 	prometheus.Unregister(d.DatabaseStats.NumReplicationsActive)
 	prometheus.Unregister(d.DatabaseStats.NumReplicationsTotal)
 	prometheus.Unregister(d.DatabaseStats.NumTombstonesCompacted)
+	prometheus.Unregister(d.DatabaseStats.OldRevBackupCount)
+	prometheus.Unregister(d.DatabaseStats.OldRevBackupBytes)
+	prometheus.Unregister(d.DatabaseStats.OldRevBackupMissCount)
 	prometheus.Unregister(d.DatabaseStats.PublicRestBytesWritten)
 	prometheus.Unregister(d.DatabaseStats.SequenceAssignedCount)
 	prometheus.Unregister(d.DatabaseStats.SequenceGetCount)
 	prometheus.Unregister(d.DatabaseStats.SequenceIncrCount)
 	prometheus.Unregister(d.DatabaseStats.SequenceReleasedCount)
 	prometheus.Unregister(d.DatabaseStats.SequenceReservedCount)
+	prometheus.Unregister(d.DatabaseStats.SequenceIncrTime)
+	prometheus.Unregister(d.DatabaseStats.SequenceIncrConflictCount)
+	prometheus.Unregister(d.DatabaseStats.SequenceAllocatorLockWaitTime)
+	prometheus.Unregister(d.DatabaseStats.SequenceBatchSize)
+	prometheus.Unregister(d.DatabaseStats.SequenceReleasePending)
+	prometheus.Unregister(d.DatabaseStats.SequenceReleaseWait)
+	prometheus.Unregister(d.DatabaseStats.SequenceRegressionCount)
+	prometheus.Unregister(d.DatabaseStats.SequenceConsistencyAnomalies)
+	prometheus.Unregister(d.DatabaseStats.NumIdempotentReplays)
+	prometheus.Unregister(d.DatabaseStats.DuplicateCheckpointWriterCount)
+	prometheus.Unregister(d.DatabaseStats.DuplicateCheckpointFencedCount)
+	prometheus.Unregister(d.DatabaseStats.QuotaRejectedCount)
+	prometheus.Unregister(d.DatabaseStats.QuotaThrottledCount)
 	prometheus.Unregister(d.DatabaseStats.WarnChannelNameSizeCount)
 	prometheus.Unregister(d.DatabaseStats.WarnChannelsPerDocCount)
 	prometheus.Unregister(d.DatabaseStats.WarnGrantsPerDocCount)
@@ -1689,12 +2190,19 @@ func (d *DbStats) unregisterDatabaseStats() {
 	prometheus.Unregister(d.DatabaseStats.SyncFunctionCount)
 	prometheus.Unregister(d.DatabaseStats.SyncFunctionTime)
 	prometheus.Unregister(d.DatabaseStats.SyncFunctionExceptionCount)
+	prometheus.Unregister(d.DatabaseStats.SyncFunctionTimeoutCount)
+	prometheus.Unregister(d.DatabaseStats.SyncFunctionDuration)
 	prometheus.Unregister(d.DatabaseStats.NumReplicationsRejectedLimit)
+	prometheus.Unregister(d.DatabaseStats.NumReplicationsActiveProtocolV2)
+	prometheus.Unregister(d.DatabaseStats.NumReplicationsActiveProtocolV3)
+	prometheus.Unregister(d.DatabaseStats.NumReplicationsActiveDeltas)
+	prometheus.Unregister(d.DatabaseStats.NumReplicationsActiveCollectionsAware)
 	prometheus.Unregister(d.DatabaseStats.NumPublicRestRequests)
 	prometheus.Unregister(d.DatabaseStats.TotalSyncTime)
 	prometheus.Unregister(d.DatabaseStats.ImportProcessCompute)
 	prometheus.Unregister(d.DatabaseStats.PublicRestBytesRead)
 	prometheus.Unregister(d.DatabaseStats.SyncProcessCompute)
+	prometheus.Unregister(d.DatabaseStats.StatsRestarted)
 }
 
 func (d *DbStats) CollectionStat(scopeName, collectionName string) (*CollectionStats, error) {
@@ -1836,6 +2344,9 @@ func (d *DbStats) unregisterCollectionStats(scopeAndCollectionName string) {
 	prometheus.Unregister(d.CollectionStats[scopeAndCollectionName].SyncFunctionRejectCount)
 	prometheus.Unregister(d.CollectionStats[scopeAndCollectionName].SyncFunctionRejectAccessCount)
 	prometheus.Unregister(d.CollectionStats[scopeAndCollectionName].SyncFunctionExceptionCount)
+	prometheus.Unregister(d.CollectionStats[scopeAndCollectionName].SyncFunctionTimeoutCount)
+	prometheus.Unregister(d.CollectionStats[scopeAndCollectionName].StagedSyncFunctionCount)
+	prometheus.Unregister(d.CollectionStats[scopeAndCollectionName].StagedSyncFunctionDivergenceCount)
 
 	prometheus.Unregister(d.CollectionStats[scopeAndCollectionName].ImportCount)
 
@@ -1844,6 +2355,12 @@ func (d *DbStats) unregisterCollectionStats(scopeAndCollectionName string) {
 
 	prometheus.Unregister(d.CollectionStats[scopeAndCollectionName].NumDocWrites)
 	prometheus.Unregister(d.CollectionStats[scopeAndCollectionName].DocWritesBytes)
+
+	prometheus.Unregister(d.CollectionStats[scopeAndCollectionName].RevisionCacheMemoryBytes)
+
+	prometheus.Unregister(d.CollectionStats[scopeAndCollectionName].KvCircuitBreakerState)
+	prometheus.Unregister(d.CollectionStats[scopeAndCollectionName].KvCircuitBreakerOpens)
+	prometheus.Unregister(d.CollectionStats[scopeAndCollectionName].KvOpsRejected)
 }
 
 func (d *DbStats) unregisterSecurityStats() {
@@ -1880,6 +2397,18 @@ func NewCollectionStats(dbName, scopeAndCollectionName string) (stats *Collectio
 	if err != nil {
 		return nil, err
 	}
+	stats.SyncFunctionTimeoutCount, err = NewIntStat(SubsystemCollection, "sync_function_timeout_count", StatUnitNoUnits, SyncFunctionTimeoutCountCollDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return nil, err
+	}
+	stats.StagedSyncFunctionCount, err = NewIntStat(SubsystemCollection, "staged_sync_function_count", StatUnitNoUnits, StagedSyncFunctionCountCollDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return nil, err
+	}
+	stats.StagedSyncFunctionDivergenceCount, err = NewIntStat(SubsystemCollection, "staged_sync_function_divergence_count", StatUnitNoUnits, StagedSyncFunctionDivergenceCountCollDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return nil, err
+	}
 
 	stats.ImportCount, err = NewIntStat(SubsystemCollection, "import_count", StatUnitNoUnits, ImportCountCollDesc, StatAddedVersion3dot1dot0, StatDeprecatedVersionNotDeprecated, StatStabilityCommitted, labelKeys, labelVals, prometheus.CounterValue, 0)
 	if err != nil {
@@ -1904,6 +2433,24 @@ func NewCollectionStats(dbName, scopeAndCollectionName string) (stats *Collectio
 		return nil, err
 	}
 
+	stats.RevisionCacheMemoryBytes, err = NewIntStat(SubsystemCollection, "rev_cache_memory_bytes", StatUnitBytes, RevCacheMemoryBytesCollDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.GaugeValue, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.KvCircuitBreakerState, err = NewIntStat(SubsystemCollection, "kv_circuit_breaker_state", StatUnitNoUnits, KvCircuitBreakerStateCollDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.GaugeValue, 0)
+	if err != nil {
+		return nil, err
+	}
+	stats.KvCircuitBreakerOpens, err = NewIntStat(SubsystemCollection, "kv_circuit_breaker_opens", StatUnitNoUnits, KvCircuitBreakerOpensCollDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return nil, err
+	}
+	stats.KvOpsRejected, err = NewIntStat(SubsystemCollection, "kv_ops_rejected", StatUnitNoUnits, KvOpsRejectedCollDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+	if err != nil {
+		return nil, err
+	}
+
 	return stats, nil
 }
 
@@ -2119,6 +2666,18 @@ func (d *DbStats) InitSharedBucketImportStats() error {
 		if err != nil {
 			return err
 		}
+		resUtil.ImportQuarantineCount, err = NewIntStat(SubsystemSharedBucketImport, "import_quarantine_count", StatUnitNoUnits, ImportQuarantineCountDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.GaugeValue, 0)
+		if err != nil {
+			return err
+		}
+		resUtil.ImportQuarantineSkippedCount, err = NewIntStat(SubsystemSharedBucketImport, "import_quarantine_skipped_count", StatUnitNoUnits, ImportQuarantineSkippedCountDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+		if err != nil {
+			return err
+		}
+		resUtil.ImportBackfillCount, err = NewIntStat(SubsystemSharedBucketImport, "import_backfill_count", StatUnitNoUnits, ImportBackfillCountDesc, StatAddedVersion3dot2dot0, StatDeprecatedVersionNotDeprecated, StatStabilityVolatile, labelKeys, labelVals, prometheus.CounterValue, 0)
+		if err != nil {
+			return err
+		}
 
 		d.SharedBucketImportStats = resUtil
 	}
@@ -2132,6 +2691,9 @@ func (d *DbStats) unregisterSharedBucketImportStats() {
 	prometheus.Unregister(d.SharedBucketImportStats.ImportProcessingTime)
 	prometheus.Unregister(d.SharedBucketImportStats.ImportHighSeq)
 	prometheus.Unregister(d.SharedBucketImportStats.ImportPartitions)
+	prometheus.Unregister(d.SharedBucketImportStats.ImportQuarantineCount)
+	prometheus.Unregister(d.SharedBucketImportStats.ImportQuarantineSkippedCount)
+	prometheus.Unregister(d.SharedBucketImportStats.ImportBackfillCount)
 }
 
 func (d *DbStats) SharedBucketImport() *SharedBucketImportStats {
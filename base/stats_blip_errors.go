@@ -0,0 +1,182 @@
+/*
+Copyright 2023-Present Couchbase, Inc.
+
+Use of this software is governed by the Business Source License included in
+the file licenses/BSL-Couchbase.txt.  As of the Change Date specified in that
+file, in accordance with the Business Source License, use of this software will
+be governed by the Apache License, Version 2.0, included in the file
+licenses/APL2.txt.
+*/
+
+package base
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	BLIPDirectionLabelKey = "direction"
+	BLIPProfileLabelKey   = "profile"
+	StatusClassLabelKey   = "status_class"
+)
+
+// BLIPErrorDirection is whether a BLIP error response was sent by Sync Gateway or received from a client.
+type BLIPErrorDirection string
+
+const (
+	BLIPErrorSent     BLIPErrorDirection = "sent"     // Sync Gateway returned an error for a client's request
+	BLIPErrorReceived BLIPErrorDirection = "received" // A client returned an error for Sync Gateway's request
+)
+
+// maxBLIPErrorReasons is the number of distinct error reasons tracked per database. Once reached, errors with new
+// reasons are only counted by profile and status class.
+const maxBLIPErrorReasons = 1000
+
+// maxBLIPErrorReasonLength is the length error reasons are truncated to, so that long messages don't inflate memory use.
+const maxBLIPErrorReasonLength = 256
+
+// BLIPErrorStatusClass returns the class of a BLIP error response's code, e.g. "4xx" for an HTTP 404. Errors outside
+// the HTTP domain, such as BLIP protocol errors, are classed by their domain.
+func BLIPErrorStatusClass(domain string, code int) string {
+	if domain != "" && domain != "HTTP" {
+		return domain
+	}
+	if code < 100 || code > 599 {
+		return "other"
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// blipErrorKey identifies the errors counted together by CBLReplicationErrorStats.
+type blipErrorKey struct {
+	direction   BLIPErrorDirection
+	profile     string
+	statusClass string
+}
+
+// BLIPErrorReason is the number of BLIP errors returned with the same profile, status and reason.
+type BLIPErrorReason struct {
+	Direction BLIPErrorDirection `json:"direction"`
+	Profile   string             `json:"profile"`
+	Status    int                `json:"status"`
+	Reason    string             `json:"reason"`
+	Count     int64              `json:"count"`
+}
+
+// blipErrorReasonKey identifies the errors counted together as a BLIPErrorReason.
+type blipErrorReasonKey struct {
+	direction BLIPErrorDirection
+	profile   string
+	status    int
+	reason    string
+}
+
+// CBLReplicationErrorStats counts the BLIP error responses sent and received by a database's replications, by
+// message profile and status class, and tracks the most frequent error reasons.
+type CBLReplicationErrorStats struct {
+	errorCountDesc *prometheus.Desc
+
+	lock    sync.RWMutex
+	counts  map[blipErrorKey]int64
+	reasons map[blipErrorReasonKey]int64
+}
+
+func (d *DbStats) initCBLReplicationErrorStats() error {
+	constLabels := prometheus.Labels{DatabaseLabelKey: d.dbName}
+	variableLabels := []string{BLIPDirectionLabelKey, BLIPProfileLabelKey, StatusClassLabelKey}
+	d.CBLReplicationErrStats = &CBLReplicationErrorStats{
+		errorCountDesc: prometheus.NewDesc(prometheus.BuildFQName(NamespaceKey, SubsystemReplication, "blip_error_count"), BLIPErrorCountDesc, variableLabels, constLabels),
+		counts:         make(map[blipErrorKey]int64),
+		reasons:        make(map[blipErrorReasonKey]int64),
+	}
+	if SkipPrometheusStatsRegistration {
+		return nil
+	}
+	return prometheus.Register(d.CBLReplicationErrStats)
+}
+
+func (d *DbStats) unregisterCBLReplicationErrorStats() {
+	prometheus.Unregister(d.CBLReplicationErrStats)
+}
+
+func (d *DbStats) CBLReplicationErrors() *CBLReplicationErrorStats {
+	return d.CBLReplicationErrStats
+}
+
+// Add counts a BLIP error response.
+func (s *CBLReplicationErrorStats) Add(direction BLIPErrorDirection, profile, domain string, status int, reason string) {
+	if len(reason) > maxBLIPErrorReasonLength {
+		reason = reason[:maxBLIPErrorReasonLength]
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.counts[blipErrorKey{direction: direction, profile: profile, statusClass: BLIPErrorStatusClass(domain, status)}]++
+	reasonKey := blipErrorReasonKey{direction: direction, profile: profile, status: status, reason: reason}
+	if _, ok := s.reasons[reasonKey]; ok || len(s.reasons) < maxBLIPErrorReasons {
+		s.reasons[reasonKey]++
+	}
+}
+
+// TopReasons returns up to limit error reasons, most frequent first.
+func (s *CBLReplicationErrorStats) TopReasons(limit int) []BLIPErrorReason {
+	s.lock.RLock()
+	reasons := make([]BLIPErrorReason, 0, len(s.reasons))
+	for key, count := range s.reasons {
+		reasons = append(reasons, BLIPErrorReason{
+			Direction: key.direction,
+			Profile:   key.profile,
+			Status:    key.status,
+			Reason:    key.reason,
+			Count:     count,
+		})
+	}
+	s.lock.RUnlock()
+
+	sort.Slice(reasons, func(i, j int) bool {
+		if reasons[i].Count != reasons[j].Count {
+			return reasons[i].Count > reasons[j].Count
+		}
+		return reasons[i].Reason < reasons[j].Reason
+	})
+	if limit > 0 && len(reasons) > limit {
+		reasons = reasons[:limit]
+	}
+	return reasons
+}
+
+// Counts returns the number of errors by direction, then profile, then status class.
+func (s *CBLReplicationErrorStats) Counts() map[BLIPErrorDirection]map[string]map[string]int64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	counts := make(map[BLIPErrorDirection]map[string]map[string]int64)
+	for key, count := range s.counts {
+		if counts[key.direction] == nil {
+			counts[key.direction] = make(map[string]map[string]int64)
+		}
+		if counts[key.direction][key.profile] == nil {
+			counts[key.direction][key.profile] = make(map[string]int64)
+		}
+		counts[key.direction][key.profile][key.statusClass] = count
+	}
+	return counts
+}
+
+func (s *CBLReplicationErrorStats) MarshalJSON() ([]byte, error) {
+	return JSONMarshal(s.Counts())
+}
+
+func (s *CBLReplicationErrorStats) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.errorCountDesc
+}
+
+func (s *CBLReplicationErrorStats) Collect(ch chan<- prometheus.Metric) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for key, count := range s.counts {
+		ch <- prometheus.MustNewConstMetric(s.errorCountDesc, prometheus.CounterValue, float64(count), string(key.direction), key.profile, key.statusClass)
+	}
+}
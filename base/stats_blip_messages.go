@@ -0,0 +1,113 @@
+/*
+Copyright 2023-Present Couchbase, Inc.
+
+Use of this software is governed by the Business Source License included in
+the file licenses/BSL-Couchbase.txt.  As of the Change Date specified in that
+file, in accordance with the Business Source License, use of this software will
+be governed by the Apache License, Version 2.0, included in the file
+licenses/APL2.txt.
+*/
+
+package base
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// BLIPMessageDirection is whether a BLIP message was sent or received by Sync Gateway.
+type BLIPMessageDirection string
+
+const (
+	BLIPMessageSent     BLIPMessageDirection = "sent"
+	BLIPMessageReceived BLIPMessageDirection = "received"
+)
+
+// BLIPMessageUsage is the number of BLIP messages of a profile sent or received, and the total size of their bodies
+// before compression.
+type BLIPMessageUsage struct {
+	Count     int64 `json:"count"`
+	BodyBytes int64 `json:"body_bytes"`
+}
+
+// blipMessageKey identifies the messages counted together by CBLReplicationMessageStats.
+type blipMessageKey struct {
+	direction BLIPMessageDirection
+	profile   string
+}
+
+// CBLReplicationMessageStats counts the BLIP requests and responses sent and received by a database's replications,
+// by message profile.
+type CBLReplicationMessageStats struct {
+	messageCountDesc     *prometheus.Desc
+	messageBodyBytesDesc *prometheus.Desc
+
+	lock  sync.RWMutex
+	usage map[blipMessageKey]BLIPMessageUsage
+}
+
+func (d *DbStats) initCBLReplicationMessageStats() error {
+	constLabels := prometheus.Labels{DatabaseLabelKey: d.dbName}
+	variableLabels := []string{BLIPDirectionLabelKey, BLIPProfileLabelKey}
+	d.CBLReplicationMsgStats = &CBLReplicationMessageStats{
+		messageCountDesc:     prometheus.NewDesc(prometheus.BuildFQName(NamespaceKey, SubsystemReplication, "blip_message_count"), BLIPMessageCountDesc, variableLabels, constLabels),
+		messageBodyBytesDesc: prometheus.NewDesc(prometheus.BuildFQName(NamespaceKey, SubsystemReplication, "blip_message_body_bytes"), BLIPMessageBodyBytesDesc, variableLabels, constLabels),
+		usage:                make(map[blipMessageKey]BLIPMessageUsage),
+	}
+	if SkipPrometheusStatsRegistration {
+		return nil
+	}
+	return prometheus.Register(d.CBLReplicationMsgStats)
+}
+
+func (d *DbStats) unregisterCBLReplicationMessageStats() {
+	prometheus.Unregister(d.CBLReplicationMsgStats)
+}
+
+func (d *DbStats) CBLReplicationMessages() *CBLReplicationMessageStats {
+	return d.CBLReplicationMsgStats
+}
+
+// Add counts a BLIP message with a body of bodyBytes before compression.
+func (s *CBLReplicationMessageStats) Add(direction BLIPMessageDirection, profile string, bodyBytes int) {
+	key := blipMessageKey{direction: direction, profile: profile}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	usage := s.usage[key]
+	usage.Count++
+	usage.BodyBytes += int64(bodyBytes)
+	s.usage[key] = usage
+}
+
+// Usage returns the messages counted by direction, then profile.
+func (s *CBLReplicationMessageStats) Usage() map[BLIPMessageDirection]map[string]BLIPMessageUsage {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	usage := make(map[BLIPMessageDirection]map[string]BLIPMessageUsage)
+	for key, profileUsage := range s.usage {
+		if usage[key.direction] == nil {
+			usage[key.direction] = make(map[string]BLIPMessageUsage)
+		}
+		usage[key.direction][key.profile] = profileUsage
+	}
+	return usage
+}
+
+func (s *CBLReplicationMessageStats) MarshalJSON() ([]byte, error) {
+	return JSONMarshal(s.Usage())
+}
+
+func (s *CBLReplicationMessageStats) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.messageCountDesc
+	ch <- s.messageBodyBytesDesc
+}
+
+func (s *CBLReplicationMessageStats) Collect(ch chan<- prometheus.Metric) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for key, usage := range s.usage {
+		ch <- prometheus.MustNewConstMetric(s.messageCountDesc, prometheus.CounterValue, float64(usage.Count), string(key.direction), key.profile)
+		ch <- prometheus.MustNewConstMetric(s.messageBodyBytesDesc, prometheus.CounterValue, float64(usage.BodyBytes), string(key.direction), key.profile)
+	}
+}
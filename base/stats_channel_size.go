@@ -0,0 +1,88 @@
+/*
+Copyright 2023-Present Couchbase, Inc.
+
+Use of this software is governed by the Business Source License included in
+the file licenses/BSL-Couchbase.txt.  As of the Change Date specified in that
+file, in accordance with the Business Source License, use of this software will
+be governed by the Apache License, Version 2.0, included in the file
+licenses/APL2.txt.
+*/
+
+package base
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const ChannelLabelKey = "channel"
+
+// ChannelSize is the estimated size of a single channel.
+type ChannelSize struct {
+	DocCount  int64 `json:"doc_count"`
+	BodyBytes int64 `json:"body_bytes"`
+}
+
+// ChannelSizeStats exports the most recent channel size report for each of a database's collections to prometheus,
+// as gauges labelled by collection and channel. The set of channels isn't known upfront, so unlike SgwIntStat the
+// metrics are generated on collection.
+type ChannelSizeStats struct {
+	docCountDesc  *prometheus.Desc
+	bodyBytesDesc *prometheus.Desc
+
+	lock  sync.RWMutex
+	sizes map[string]map[string]ChannelSize // Channel sizes keyed by collection, then channel name
+}
+
+func (d *DbStats) initChannelSizeStats() error {
+	constLabels := prometheus.Labels{DatabaseLabelKey: d.dbName}
+	variableLabels := []string{CollectionLabelKey, ChannelLabelKey}
+	d.ChannelSizeStats = &ChannelSizeStats{
+		docCountDesc:  prometheus.NewDesc(prometheus.BuildFQName(NamespaceKey, SubsystemDatabaseKey, "channel_size_doc_count"), ChannelSizeDocCountDesc, variableLabels, constLabels),
+		bodyBytesDesc: prometheus.NewDesc(prometheus.BuildFQName(NamespaceKey, SubsystemDatabaseKey, "channel_size_body_bytes"), ChannelSizeBodyBytesDesc, variableLabels, constLabels),
+		sizes:         make(map[string]map[string]ChannelSize),
+	}
+	if SkipPrometheusStatsRegistration {
+		return nil
+	}
+	return prometheus.Register(d.ChannelSizeStats)
+}
+
+func (d *DbStats) unregisterChannelSizeStats() {
+	prometheus.Unregister(d.ChannelSizeStats)
+}
+
+func (d *DbStats) ChannelSizes() *ChannelSizeStats {
+	return d.ChannelSizeStats
+}
+
+// Set replaces the channel sizes for the given collection.
+func (s *ChannelSizeStats) Set(collection string, sizes map[string]ChannelSize) {
+	s.lock.Lock()
+	s.sizes[collection] = sizes
+	s.lock.Unlock()
+}
+
+// Get returns the channel sizes for the given collection, or nil if none have been set.
+func (s *ChannelSizeStats) Get(collection string) map[string]ChannelSize {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.sizes[collection]
+}
+
+func (s *ChannelSizeStats) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.docCountDesc
+	ch <- s.bodyBytesDesc
+}
+
+func (s *ChannelSizeStats) Collect(ch chan<- prometheus.Metric) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for collection, channels := range s.sizes {
+		for channel, size := range channels {
+			ch <- prometheus.MustNewConstMetric(s.docCountDesc, prometheus.GaugeValue, float64(size.DocCount), collection, channel)
+			ch <- prometheus.MustNewConstMetric(s.bodyBytesDesc, prometheus.GaugeValue, float64(size.BodyBytes), collection, channel)
+		}
+	}
+}
@@ -18,6 +18,10 @@ const (
 	AdminNetBytesSentDesc = "The total number of bytes sent (since node start-up) on the network interface to which the Sync Gateway api.admin_interface is bound." +
 		"By default, that is the number of bytes sent on 127.0.0.1:4985 since node start-up."
 
+	BulkMemoryInFlightDesc = "The estimated memory in bytes used by in-flight _bulk_docs and _bulk_get requests, which is limited by api.max_bulk_memory."
+
+	BulkMemoryRejectedCountDesc = "The total number of _bulk_docs and _bulk_get requests, or documents in _bulk_get responses, rejected for exceeding api.max_bulk_request_memory or api.max_bulk_memory."
+
 	ErrorCountDesc = "The total number of errors logged."
 
 	GoMemHeapAllocDesc = "HeapAlloc is bytes of allocated heap objects. \"Allocated\" heap objects include all reachable objects, as well as unreachable objects that the garbage collector has " +
@@ -60,6 +64,8 @@ const (
 
 	PublicNetBytesSentDesc = "The total number of bytes sent (since node start-up) on the network interface to which Sync Gateway api.public_interface is bound. By default, that is the number of bytes sent on 127.0.0.1:4984 since node start-up."
 
+	RateLimitedRequestCountDesc = "The total number of public API requests rejected with a 429 response for exceeding the per-client rate limits set by api.rate_limit."
+
 	SystemMemoryTotalDesc = "The total memory available on the system in bytes."
 
 	WarnCountDesc = "The total number of warnings logged."
@@ -72,12 +78,24 @@ const (
 	DatabaseBucketMismatchesDesc = "The total number of times a database config is polled from a bucket that doesn't match the bucket specified in the database config."
 )
 
+// server connection stats descriptions
+const (
+	ServerConnectionReconnectsDesc = "The total number of times Sync Gateway reconnected to Couchbase Server because the addresses resolved from the server's DNS or SRV records changed."
+
+	ServerConnectionFailoversDesc = "The total number of reconnects to Couchbase Server where none of the previously resolved addresses remained, such as when the server's DNS records are moved to a different cluster."
+
+	ServerConnectionDNSResolutionErrorsDesc = "The total number of times re-resolving the Couchbase Server DNS or SRV records failed."
+)
+
 // cache stats descriptions
 const (
 	AbandonedSequencesDesc = "The total number of skipped sequences that were not found after 60 minutes and were abandoned."
 
 	ChanCacheActiveRevsDesc = "The total number of active revisions in the channel cache."
 
+	ChanCacheBackfillsCancelledDesc = "The total number of channel backfill queries cancelled before completing, because every changes request waiting for the results was closed, " +
+		"for example by the client disconnecting."
+
 	ChanCacheBypassCountDesc = "The total number of transient bypass channel caches created to serve requests when the channel cache was at capacity."
 
 	ChanCacheChannelsAddedDesc = "The total number of channel caches added. The metric doesn't decrease when a channel is removed. That is, it is similar to chan_cache_num_channels but doesn't track removals."
@@ -124,6 +142,21 @@ const (
 	RevCacheMissesDesc = "The total number of revision cache misses. This metric can be used to calculate the ratio of revision cache misses: " +
 		"Rev Cache Miss Ratio = rev_cache_misses / (rev_cache_hits + rev_cache_misses)"
 
+	RevCacheMemoryBytesDesc = "The estimated total size in bytes of the revisions in the revision cache, across all collections. " +
+		"This is based on the size of each revision's raw body, history and channels, so doesn't include per-entry overhead."
+
+	DeltaCacheMemoryBytesDesc = "The estimated total size in bytes of the deltas in the delta cache, across all collections. " +
+		"This is based on the size of each delta and its revision history and channels, so doesn't include per-entry overhead."
+
+	DeltaCacheMemoryEvictionsDesc = "The total number of deltas evicted from the delta cache to stay within the configured cache.delta_cache.max_memory_bytes."
+
+	FeedBufferDepthDesc      = "The current number of mutation feed events buffered for the change cache, up to the configured cache.channel_cache.max_feed_buffer."
+	FeedBufferPauseCountDesc = "The total number of times the mutation feed was paused because the change cache's feed buffer was full."
+	FeedBufferPauseTimeDesc  = "The total time the mutation feed was paused because the change cache's feed buffer was full. " +
+		"While paused, feed events aren't acknowledged, so the server stops sending them."
+
+	RevCacheMemoryEvictionsDesc = "The total number of revisions evicted from the revision cache to stay within the configured cache.rev_cache.max_memory_bytes."
+
 	SkippedSeqLengthDesc = "The current length of the pending skipped sequence queue."
 
 	ViewQueriesDesc = "The total view_queries."
@@ -182,6 +215,12 @@ const (
 
 	CompactionTombstoneStartTimeDesc = "The compaction_tombstone_start_time."
 
+	ChannelHistoryEntriesPrunedDesc = "The total number of channel history entries removed or merged from document sync metadata by the channel history retention policy."
+
+	ChangesSlowConsumerDisconnectCountDesc = "The total number of continuous _changes feeds disconnected because the client was too slow to read them, either because writing an entry went over replicator.changes_write_timeout, or too many consecutive entries went over replicator.changes_slow_write_threshold."
+
+	ChangesSlowWriteCountDesc = "The total number of continuous _changes feed entries that took longer than replicator.changes_slow_write_threshold to write and flush to the client."
+
 	AttachmentPushCountDesc = "The total number of attachments pushed."
 
 	ConflictWriteCountDesc = "The total number of writes that left the document in a conflicted state. Includes new conflicts, and mutations that don't resolve existing conflicts."
@@ -204,6 +243,14 @@ const (
 		"(b). Assessing the benefit of adding additional Sync Gateway nodes, as it can point to Sync Gateway being a bottleneck (c). Troubleshooting slow push replication, in which case it ought to be considered in conjunction with sync_function_time"
 
 	DocPushErrorCountDesc = "The total number of documents that failed to push."
+
+	RevThrottledCountDesc = "The total number of pushed revisions that were delayed because the connection had reached max_pending_revs or max_pending_rev_bytes."
+
+	RevThrottledTimeDesc = "The total time pushed revisions spent delayed because the connection had reached max_pending_revs or max_pending_rev_bytes. A high value indicates clients are pushing faster than the sync function can process revisions."
+
+	PendingInsertionsDesc = "The current number of documents proposed as new by clients in proposeChanges messages, that haven't yet been pushed in rev messages. Stays high for clients that propose changes but never send the revisions."
+
+	PendingInsertionsOverflowCountDesc = "The total number of documents proposed as new that weren't tracked as pending insertions because the connection already had the maximum number pending. The revisions of these documents are checked for conflicts when pushed."
 )
 
 // Database specific stats descriptions
@@ -237,6 +284,16 @@ const (
 
 	NumAttachmentsCompactedDesc = "The number of attachments compacted import_feed"
 
+	NumAttachmentsVerifiedDesc = "The total number of attachments checked by the attachment integrity scanner."
+
+	NumAttachmentsCorruptDesc = "The total number of attachments found by the attachment integrity scanner not to match their digest."
+
+	NumAttachmentsQuarantinedDesc = "The total number of corrupt attachments quarantined by the attachment integrity scanner."
+
+	NumAttachmentsRejectedSizeDesc = "The total number of attachments rejected on write because they exceed the maximum size allowed by the attachment policy."
+
+	NumAttachmentsRejectedContentTypeDesc = "The total number of attachments rejected on write because their content type is not allowed by the attachment policy."
+
 	ImportFeedDesc = "Contains low level dcp stats: (a). dcp_backfill_expected - the expected number of sequences in backfill (b). dcp_backfill_completed - the number of backfill items processed (c). dcp_rollback_count - the number of DCP rollbacks"
 
 	NumDocsReadsBlipDesc = "The total number of documents read via Couchbase Lite 2.x replication since Sync Gateway node startup."
@@ -249,6 +306,12 @@ const (
 
 	NumReplicationsTotalDesc = "The total number of replications created since Sync Gateway node startup."
 
+	OldRevBackupCountDesc = "The total number of old revision bodies backed up for delta sync and in-flight replications. Backups expire after old_rev_expiry_seconds, or delta_sync.rev_max_age_seconds when delta sync is enabled."
+
+	OldRevBackupBytesDesc = "The total number of bytes in old revision bodies backed up for delta sync and in-flight replications."
+
+	OldRevBackupMissCountDesc = "The total number of old revision body lookups that found no backup, because it had expired or been removed. Deltas from these revisions fall back to sending the full revision body."
+
 	SequenceAssignedCountDesc = "The total number of sequence numbers assigned."
 
 	SequenceGetCountDesc = "The total number of high sequence lookups."
@@ -259,6 +322,41 @@ const (
 
 	SequenceReservedCountDesc = "The total number of sequences reserved by Sync Gateway."
 
+	SequenceIncrTimeDesc = "The total time spent incrementing the sequence counter document. Divide by sequence_incr_count for the average latency."
+
+	SequenceIncrConflictCountDesc = "The total number of sequence batch reservations where another Sync Gateway node had incremented the sequence counter document since this node last reserved sequences."
+
+	SequenceAllocatorLockWaitTimeDesc = "The total time spent waiting to acquire the sequence allocator lock when assigning sequences."
+
+	SequenceBatchSizeDesc = "The current number of sequences reserved per increment of the sequence counter document."
+
+	SequenceReleasePendingDesc = "The current number of sequences reserved by this node that haven't yet been assigned or released. Changes feeds on other nodes may wait on these sequences until they're released."
+
+	SequenceReleaseWaitDesc = "The current time waited after a sequence reservation before releasing the reserved sequences left unused. Shortened as the proportion of assigned sequences released unused by failed writes rises."
+
+	SequenceRegressionCountDesc = "The total number of times the sequence counter document was found to be lower than sequences already allocated, for example after the bucket was flushed or restored from a backup."
+
+	SequenceConsistencyAnomaliesDesc = "The number of mismatches between the sequence counter and the bucket found by the startup consistency check when the database was last started."
+
+	NumIdempotentReplaysDesc = "The total number of document writes not made again because they were retries with the Idempotency-Key of an earlier write, and were given the earlier write's response instead."
+
+	DuplicateCheckpointWriterCountDesc = "The total number of times two replicators were detected concurrently writing the checkpoint of the same client ID, for example two devices sharing a client ID. Their checkpoint writes repeatedly conflict, and each overwrites the other's progress."
+
+	DuplicateCheckpointFencedCountDesc = "The total number of checkpoint writes rejected because another replicator was detected writing the checkpoint of the same client ID, when fencing of duplicate checkpoint writers is enabled."
+
+	QuotaRejectedCountDesc = "The total number of revisions rejected because the user had reached their daily read or write quota."
+
+	QuotaThrottledCountDesc = "The total number of revisions delayed because the user had reached their daily read or write quota."
+
+	ChannelSizeDocCountDesc = "The estimated number of documents in the channel, as of the most recent channel size report for the collection."
+
+	BLIPErrorCountDesc = "The total number of BLIP error responses sent by Sync Gateway or received from clients, by message profile and status class."
+
+	BLIPMessageCountDesc     = "The total number of BLIP requests and responses sent or received by Sync Gateway for Couchbase Lite replications, by message profile."
+	BLIPMessageBodyBytesDesc = "The total size in bytes of the bodies of BLIP requests and responses sent or received by Sync Gateway for Couchbase Lite replications, by message profile, before compression."
+
+	ChannelSizeBodyBytesDesc = "The estimated total size in bytes of the documents in the channel, as of the most recent channel size report for the collection."
+
 	WarnChannelNameSizeCountDesc = "The total number of warnings relating to the channel name size."
 
 	WarnChannelsPerDocCountDesc = "The total number of warnings relating to the channel count exceeding the channel count threshold."
@@ -275,8 +373,21 @@ const (
 
 	SyncFunctionExceptionCountDesc = "The total number of times that a sync function encountered an exception (across all collections)."
 
+	SyncFunctionTimeoutCountDesc = "The total number of times that a sync function exceeded javascript_timeout_secs (across all collections). Timeouts are not included in sync_function_exception_count."
+
+	SyncFunctionDurationDesc = "The distribution of sync function execution times in seconds (across all collections), labelled by outcome: accepted, rejected or exception. Timeouts are recorded as exceptions. " +
+		"Useful for catching sync function changes that slow down document writes."
+
 	NumReplicationsRejectedLimitDesc = "The total number of times a replication connection is rejected due to it being over the threshold."
 
+	NumReplicationsActiveProtocolV2Desc = "The number of active replication connections that negotiated version 2 of the replication protocol."
+
+	NumReplicationsActiveProtocolV3Desc = "The number of active replication connections that negotiated version 3 of the replication protocol."
+
+	NumReplicationsActiveDeltasDesc = "The number of active replication connections that negotiated delta sync."
+
+	NumReplicationsActiveCollectionsAwareDesc = "The number of active replication connections from clients that negotiated collections support using getCollections."
+
 	NumPublicRestRequestsDesc = "The total number of requests sent over the public REST api."
 
 	TotalSyncTimeDesc = "The total total sync time is a proxy for websocket connections. Tracking long lived and potentially idle connections. " +
@@ -289,6 +400,8 @@ const (
 	PublicRestBytesReadDesc = "The total amount of bytes read over the public REST api"
 
 	SyncProcessComputeDesc = "The compute unit for syncing with clients measured through cpu time and memory used for sync"
+
+	StatsRestartedDesc = "Set to 1 once this node has restored the database's counters from the values it persisted before restarting, so counters continue from their values before the restart. 0 if stats persistence is disabled, or there were no persisted values."
 )
 
 // Delta Sync stats descriptions
@@ -341,6 +454,12 @@ const (
 
 	ImportPartitionsDesc = "The total number of import partitions."
 
+	ImportQuarantineCountDesc = "The number of documents currently quarantined after repeatedly failing import."
+
+	ImportQuarantineSkippedCountDesc = "The total number of mutations not imported because the document was quarantined."
+
+	ImportBackfillCountDesc = "The total number of docs imported by import backfill runs, which import the documents already in the database's collections."
+
 	ImportProcessingTimeDesc = "The total time taken to process a document import."
 )
 
@@ -417,6 +536,12 @@ const (
 
 	SyncFunctionExceptionCountCollDesc = "The total number of times the sync function encountered an exception for this collection."
 
+	SyncFunctionTimeoutCountCollDesc = "The total number of times the sync function exceeded javascript_timeout_secs for this collection."
+
+	StagedSyncFunctionCountCollDesc = "The total number of writes to this collection that used the staged sync function rather than the current one, either because the document was tagged with the staged_sync doc_property or was sampled by the staged_sync percentage."
+
+	StagedSyncFunctionDivergenceCountCollDesc = "The total number of writes to this collection where the staged sync function assigned different channels or access grants, or made a different decision to reject the write, than the current sync function."
+
 	ImportCountCollDesc = "The total number of documents imported to this collection since Sync Gateway node startup."
 
 	NumDocReadsCollDesc = "The total number of documents read from this collection since Sync Gateway node startup (i.e. sending to a client)"
@@ -426,4 +551,12 @@ const (
 	NumDocWritesCollDesc = "The total number of documents written to this collection since Sync Gateway node startup (i.e. receiving from a client)"
 
 	DocWritesBytesCollDesc = "The total number of bytes written to this collection as part of document writes since Sync Gateway node startup."
+
+	RevCacheMemoryBytesCollDesc = "The estimated total size in bytes of this collection's revisions in the revision cache."
+
+	KvCircuitBreakerStateCollDesc = "The state of the circuit breaker for KV operations on this collection: 0 when closed, 1 when open and operations are rejected, and 2 when half-open and a trial operation is in progress."
+
+	KvCircuitBreakerOpensCollDesc = "The total number of times the circuit breaker for KV operations on this collection opened, due to the rate of timeouts and temporary failures."
+
+	KvOpsRejectedCollDesc = "The total number of KV operations on this collection rejected because the circuit breaker was open, or because no operation slot became available within the operation timeout budget."
 )
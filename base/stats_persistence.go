@@ -0,0 +1,152 @@
+/*
+Copyright 2023-Present Couchbase, Inc.
+
+Use of this software is governed by the Business Source License included in
+the file licenses/BSL-Couchbase.txt.  As of the Change Date specified in that
+file, in accordance with the Business Source License, use of this software will
+be governed by the Apache License, Version 2.0, included in the file
+licenses/APL2.txt.
+*/
+
+package base
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PersistedCounters are the values of a database's cumulative counter stats, keyed by stat name and labels, as
+// persisted so that counters survive a restart.
+type PersistedCounters struct {
+	Ints   map[string]int64   `json:"ints,omitempty"`
+	Floats map[string]float64 `json:"floats,omitempty"`
+}
+
+// Merge raises each counter to its value in other, if higher.  Used when persisting counters, so that a persisted
+// counter never decreases.
+func (c *PersistedCounters) Merge(other PersistedCounters) {
+	for key, value := range other.Ints {
+		if c.Ints == nil {
+			c.Ints = make(map[string]int64)
+		}
+		if current, ok := c.Ints[key]; !ok || value > current {
+			c.Ints[key] = value
+		}
+	}
+	for key, value := range other.Floats {
+		if c.Floats == nil {
+			c.Floats = make(map[string]float64)
+		}
+		if current, ok := c.Floats[key]; !ok || value > current {
+			c.Floats[key] = value
+		}
+	}
+}
+
+// PersistableCounters returns the current values of the database's cumulative counter stats.  Gauges, histograms and
+// per-replication stats aren't included.
+func (d *DbStats) PersistableCounters() PersistedCounters {
+	counters := PersistedCounters{
+		Ints:   make(map[string]int64),
+		Floats: make(map[string]float64),
+	}
+	d.forEachCounter(func(key string, intStat *SgwIntStat, floatStat *SgwFloatStat) {
+		if intStat != nil {
+			counters.Ints[key] = intStat.Value()
+		} else {
+			counters.Floats[key] = floatStat.Value()
+		}
+	})
+	return counters
+}
+
+// RestoreCounters adds the persisted values to the database's counters.  Counters start from zero on startup, so
+// adding rather than setting the persisted values keeps any increments made before the restore, and each counter
+// continues from at least its persisted value.
+func (d *DbStats) RestoreCounters(persisted PersistedCounters) {
+	d.forEachCounter(func(key string, intStat *SgwIntStat, floatStat *SgwFloatStat) {
+		if intStat != nil {
+			if value, ok := persisted.Ints[key]; ok && value > 0 {
+				intStat.Add(value)
+			}
+		} else if value, ok := persisted.Floats[key]; ok && value > 0 {
+			floatStat.Add(value)
+		}
+	})
+	d.Database().StatsRestarted.Set(1)
+}
+
+// forEachCounter invokes the callback for each cumulative counter stat of the database, with either intStat or
+// floatStat set.
+func (d *DbStats) forEachCounter(callback func(key string, intStat *SgwIntStat, floatStat *SgwFloatStat)) {
+	forEachCounterStat(reflect.ValueOf(d), callback)
+}
+
+var (
+	sgwIntStatType   = reflect.TypeOf(&SgwIntStat{})
+	sgwFloatStatType = reflect.TypeOf(&SgwFloatStat{})
+)
+
+// forEachCounterStat walks the exported fields of stats structs, and maps of stats structs, invoking the callback for
+// each counter stat found.
+func forEachCounterStat(value reflect.Value, callback func(key string, intStat *SgwIntStat, floatStat *SgwFloatStat)) {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return
+		}
+		switch value.Type() {
+		case sgwIntStatType:
+			stat := value.Interface().(*SgwIntStat)
+			if stat.statValueType == prometheus.CounterValue {
+				callback(stat.persistenceKey(), stat, nil)
+			}
+		case sgwFloatStatType:
+			stat := value.Interface().(*SgwFloatStat)
+			if stat.statValueType == prometheus.CounterValue {
+				callback(stat.persistenceKey(), nil, stat)
+			}
+		default:
+			forEachCounterStat(value.Elem(), callback)
+		}
+	case reflect.Struct:
+		valueType := value.Type()
+		for i := 0; i < value.NumField(); i++ {
+			field := valueType.Field(i)
+			// Replications come and go, so their stats aren't persisted
+			if !field.IsExported() || field.Type == reflect.TypeOf(map[string]*DbReplicatorStats{}) {
+				continue
+			}
+			forEachCounterStat(value.Field(i), callback)
+		}
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return
+		}
+		keys := value.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, key := range keys {
+			forEachCounterStat(value.MapIndex(key), callback)
+		}
+	}
+}
+
+// persistenceKey identifies the stat in PersistedCounters, by its name and its labels other than the database.
+func (s *SgwStat) persistenceKey() string {
+	labelKeys := make([]string, 0, len(s.labels))
+	for labelKey := range s.labels {
+		if labelKey != DatabaseLabelKey {
+			labelKeys = append(labelKeys, labelKey)
+		}
+	}
+	sort.Strings(labelKeys)
+	var key strings.Builder
+	key.WriteString(s.statFQN)
+	for _, labelKey := range labelKeys {
+		key.WriteString("," + labelKey + "=" + s.labels[labelKey])
+	}
+	return key.String()
+}
@@ -170,3 +170,30 @@ func initExpvarBaseEquivalent() *expvar.Map {
 
 	return expvarMap
 }
+
+func TestCBLReplicationErrorStats(t *testing.T) {
+	assert.Equal(t, "4xx", BLIPErrorStatusClass("HTTP", 404))
+	assert.Equal(t, "5xx", BLIPErrorStatusClass("", 503))
+	assert.Equal(t, "other", BLIPErrorStatusClass("HTTP", 0))
+	assert.Equal(t, "BLIP", BLIPErrorStatusClass("BLIP", 404))
+
+	errorStats := &CBLReplicationErrorStats{
+		counts:  make(map[blipErrorKey]int64),
+		reasons: make(map[blipErrorReasonKey]int64),
+	}
+	errorStats.Add(BLIPErrorSent, "rev", "HTTP", 403, "forbidden")
+	errorStats.Add(BLIPErrorSent, "rev", "HTTP", 403, "forbidden")
+	errorStats.Add(BLIPErrorSent, "rev", "HTTP", 409, "conflict")
+	errorStats.Add(BLIPErrorReceived, "changes", "HTTP", 500, "crash")
+
+	assert.Equal(t, map[BLIPErrorDirection]map[string]map[string]int64{
+		BLIPErrorSent:     {"rev": {"4xx": 3}},
+		BLIPErrorReceived: {"changes": {"5xx": 1}},
+	}, errorStats.Counts())
+
+	topReasons := errorStats.TopReasons(2)
+	require.Len(t, topReasons, 2)
+	assert.Equal(t, BLIPErrorReason{Direction: BLIPErrorSent, Profile: "rev", Status: 403, Reason: "forbidden", Count: 2}, topReasons[0])
+	assert.Equal(t, "conflict", topReasons[1].Reason)
+	assert.Len(t, errorStats.TopReasons(0), 3)
+}
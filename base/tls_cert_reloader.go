@@ -0,0 +1,148 @@
+/*
+Copyright 2023-Present Couchbase, Inc.
+
+Use of this software is governed by the Business Source License included in
+the file licenses/BSL-Couchbase.txt.  As of the Change Date specified in that
+file, in accordance with the Business Source License, use of this software will
+be governed by the Apache License, Version 2.0, included in the file
+licenses/APL2.txt.
+*/
+
+package base
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CertificateReloader holds the TLS certificate served by the REST APIs, and allows it to be swapped
+// atomically while the server is running. New TLS handshakes pick up the reloaded certificate via
+// GetCertificate, existing connections are unaffected.
+type CertificateReloader struct {
+	certPath string
+	keyPath  string
+
+	cert atomic.Value // *tls.Certificate
+
+	lock        sync.Mutex // Serializes reloads
+	certModTime time.Time  // Modification time of certPath at last successful load
+	keyModTime  time.Time  // Modification time of keyPath at last successful load
+
+	terminator chan struct{}
+	doneChan   chan struct{}
+}
+
+// NewCertificateReloader loads the given cert/key pair and returns a CertificateReloader serving it.
+func NewCertificateReloader(certPath, keyPath string) (*CertificateReloader, error) {
+	r := &CertificateReloader{
+		certPath: certPath,
+		keyPath:  keyPath,
+	}
+	if _, err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, and is intended to be used as tls.Config.GetCertificate.
+func (r *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load().(*tls.Certificate), nil
+}
+
+// Certificate returns the parsed leaf of the current certificate.
+func (r *CertificateReloader) Certificate() *x509.Certificate {
+	return r.cert.Load().(*tls.Certificate).Leaf
+}
+
+// Reload unconditionally reloads the cert/key pair from disk. On error, the previously loaded certificate
+// continues to be served.
+func (r *CertificateReloader) Reload(ctx context.Context) error {
+	if _, err := r.load(); err != nil {
+		WarnfCtx(ctx, "Unable to reload TLS certificate from %s and %s, continuing to use existing certificate: %v", MD(r.certPath), MD(r.keyPath), err)
+		return err
+	}
+	InfofCtx(ctx, KeyHTTP, "Reloaded TLS certificate from %s (expires %v)", MD(r.certPath), r.Certificate().NotAfter)
+	return nil
+}
+
+// reloadIfModified reloads the cert/key pair if either file has been modified since the last load.
+func (r *CertificateReloader) reloadIfModified(ctx context.Context) {
+	certModTime, keyModTime, err := r.modTimes()
+	if err != nil {
+		DebugfCtx(ctx, KeyHTTP, "Unable to stat TLS certificate files: %v", err)
+		return
+	}
+	r.lock.Lock()
+	modified := !certModTime.Equal(r.certModTime) || !keyModTime.Equal(r.keyModTime)
+	r.lock.Unlock()
+	if modified {
+		_ = r.Reload(ctx)
+	}
+}
+
+// load reads and parses the cert/key pair, and stores it as the current certificate.
+func (r *CertificateReloader) load() (*tls.Certificate, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	certModTime, keyModTime, err := r.modTimes()
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certPath, r.keyPath)
+	if err != nil {
+		return nil, err
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	r.cert.Store(&cert)
+	r.certModTime = certModTime
+	r.keyModTime = keyModTime
+	return &cert, nil
+}
+
+func (r *CertificateReloader) modTimes() (certModTime, keyModTime time.Time, err error) {
+	certInfo, err := os.Stat(r.certPath)
+	if err != nil {
+		return certModTime, keyModTime, err
+	}
+	keyInfo, err := os.Stat(r.keyPath)
+	if err != nil {
+		return certModTime, keyModTime, err
+	}
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
+
+// StartWatching polls the cert/key files for modifications at the given interval, reloading the
+// certificate when they change. Must be stopped with StopWatching.
+func (r *CertificateReloader) StartWatching(ctx context.Context, interval time.Duration) {
+	r.terminator = make(chan struct{})
+	r.doneChan = make(chan struct{})
+	go func() {
+		defer close(r.doneChan)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.reloadIfModified(ctx)
+			case <-r.terminator:
+				DebugfCtx(ctx, KeyHTTP, "Stopping TLS certificate watcher")
+				return
+			}
+		}
+	}()
+	InfofCtx(ctx, KeyHTTP, "Watching TLS certificate %s for changes every %v", MD(r.certPath), interval)
+}
+
+// StopWatching stops the goroutine started by StartWatching, if running.
+func (r *CertificateReloader) StopWatching(timeout time.Duration) error {
+	return TerminateAndWaitForClose(r.terminator, r.doneChan, timeout)
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2023-Present Couchbase, Inc.
+
+Use of this software is governed by the Business Source License included in
+the file licenses/BSL-Couchbase.txt.  As of the Change Date specified in that
+file, in accordance with the Business Source License, use of this software will
+be governed by the Apache License, Version 2.0, included in the file
+licenses/APL2.txt.
+*/
+
+package base
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func copyFile(t *testing.T, src, dst string) {
+	data, err := os.ReadFile(src)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(dst, data, 0600))
+}
+
+func TestCertificateReloader(t *testing.T) {
+	clientCertPath, clientKeyPath, rootCertPath, rootKeyPath := mockCertificatesAndKeys(t)
+
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "sg.pem")
+	keyPath := filepath.Join(dir, "sg.key")
+	copyFile(t, clientCertPath, certPath)
+	copyFile(t, clientKeyPath, keyPath)
+
+	ctx := TestCtx(t)
+	reloader, err := NewCertificateReloader(certPath, keyPath)
+	require.NoError(t, err)
+	assert.Equal(t, "client_auth_test_cert", reloader.Certificate().Subject.CommonName)
+
+	cert, err := reloader.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, reloader.Certificate(), cert.Leaf)
+
+	// A mismatched cert/key pair should fail to reload, and the existing certificate should still be served
+	copyFile(t, rootCertPath, certPath)
+	require.Error(t, reloader.Reload(ctx))
+	assert.Equal(t, "client_auth_test_cert", reloader.Certificate().Subject.CommonName)
+
+	copyFile(t, rootKeyPath, keyPath)
+	require.NoError(t, reloader.Reload(ctx))
+	assert.Equal(t, "Root CA", reloader.Certificate().Subject.CommonName)
+
+	_, err = NewCertificateReloader(filepath.Join(dir, "missing.pem"), keyPath)
+	assert.Error(t, err)
+}
+
+func TestCertificateReloaderWatch(t *testing.T) {
+	clientCertPath, clientKeyPath, rootCertPath, rootKeyPath := mockCertificatesAndKeys(t)
+
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "sg.pem")
+	keyPath := filepath.Join(dir, "sg.key")
+	copyFile(t, clientCertPath, certPath)
+	copyFile(t, clientKeyPath, keyPath)
+
+	reloader, err := NewCertificateReloader(certPath, keyPath)
+	require.NoError(t, err)
+	reloader.StartWatching(TestCtx(t), 10*time.Millisecond)
+	defer func() { assert.NoError(t, reloader.StopWatching(time.Second)) }()
+
+	// Ensure modification times differ from the initial load on filesystems with coarse timestamps
+	future := time.Now().Add(time.Minute)
+	copyFile(t, rootKeyPath, keyPath)
+	copyFile(t, rootCertPath, certPath)
+	require.NoError(t, os.Chtimes(keyPath, future, future))
+	require.NoError(t, os.Chtimes(certPath, future, future))
+
+	require.Eventually(t, func() bool {
+		return reloader.Certificate().Subject.CommonName == "Root CA"
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -874,6 +874,11 @@ func IntPtr(i int) *int {
 	return &i
 }
 
+// Int64Ptr returns a pointer to the given int64 literal.
+func Int64Ptr(i int64) *int64 {
+	return &i
+}
+
 // BoolPtr returns a pointer to the given bool literal.
 func BoolPtr(b bool) *bool {
 	return &b
@@ -887,6 +892,14 @@ func BoolDefault(b *bool, ifNil bool) bool {
 	return ifNil
 }
 
+// IntDefault returns ifNil if i is nil, or else returns dereferenced value of i
+func IntDefault(i *int, ifNil int) int {
+	if i != nil {
+		return *i
+	}
+	return ifNil
+}
+
 func Float32Ptr(f float32) *float32 {
 	return &f
 }
@@ -21,11 +21,12 @@ import (
 
 /** Result of running a channel-mapper function. */
 type ChannelMapperOutput struct {
-	Channels  base.Set  // channels assigned to the document via channel() callback
-	Roles     AccessMap // roles granted to users via role() callback
-	Access    AccessMap // channels granted to users via access() callback
-	Rejection error     // Error associated with failed validate (require callbacks, etc)
-	Expiry    *uint32   // Expiry value specified by expiry() callback.  Standard CBS expiry format: seconds if less than 30 days, epoch time otherwise
+	Channels     base.Set        // channels assigned to the document via channel() callback
+	Roles        AccessMap       // roles granted to users via role() callback
+	Access       AccessMap       // channels granted to users via access() callback
+	AccessExpiry AccessExpiryMap // unix expiry time of time-boxed channel grants made via access() callback
+	Rejection    error           // Error associated with failed validate (require callbacks, etc)
+	Expiry       *uint32         // Expiry value specified by expiry() callback.  Standard CBS expiry format: seconds if less than 30 days, epoch time otherwise
 }
 
 type ChannelMapper struct {
@@ -35,6 +36,44 @@ type ChannelMapper struct {
 // Maps user names (or role names prefixed with "role:") to arrays of channel or role names
 type AccessMap map[string]base.Set
 
+// Maps user names (or role names prefixed with "role:") to the unix time (seconds) at which each of their time-boxed
+// channel grants expires.  Channels granted without an expiry aren't included.
+type AccessExpiryMap map[string]map[string]uint32
+
+// add records a grant of channel to name expiring at expiry, or not expiring if expiry is zero.  When the same channel is
+// granted more than once, the grant expires at the latest expiry, or not at all if any grant doesn't expire.
+func (m AccessExpiryMap) add(name, channel string, expiry uint32) {
+	channelExpiry, ok := m[name]
+	if !ok {
+		channelExpiry = make(map[string]uint32)
+		m[name] = channelExpiry
+	}
+	if existing, exists := channelExpiry[channel]; exists && (existing == 0 || (expiry != 0 && existing > expiry)) {
+		return
+	}
+	channelExpiry[channel] = expiry
+}
+
+// compile returns the map without unexpiring grants, or nil if no grants expire.
+func (m AccessExpiryMap) compile() AccessExpiryMap {
+	var result AccessExpiryMap
+	for name, channelExpiry := range m {
+		for channel, expiry := range channelExpiry {
+			if expiry == 0 {
+				continue
+			}
+			if result == nil {
+				result = make(AccessExpiryMap)
+			}
+			if result[name] == nil {
+				result[name] = make(map[string]uint32)
+			}
+			result[name][channel] = expiry
+		}
+	}
+	return result
+}
+
 // Number of SyncRunner tasks (and Otto contexts) to cache
 // Should be larger than sequence_allocator.maxBatchSize, to avoid pool overflow under some load scenarios (CBG-436)
 const kTaskCacheSize = 16
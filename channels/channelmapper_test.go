@@ -11,6 +11,7 @@ package channels
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/couchbase/sync_gateway/base"
 	"github.com/robertkrimen/otto"
@@ -77,6 +78,35 @@ func TestAccessFunction(t *testing.T) {
 	assert.Equal(t, AccessMap{"foo": BaseSetOf(t, "bar", "baz")}, res.Access)
 }
 
+// Verify that an expiry passed to access() is returned as an absolute unix time, and that an unexpiring grant of the
+// same channel takes precedence.
+func TestAccessFunctionWithExpiry(t *testing.T) {
+	ctx := base.TestCtx(t)
+	mapper := NewChannelMapper(ctx, `function(doc) {
+		access("foo", ["bar", "baz"], {expiry: "2105-01-01T00:00:00.000+00:00"});
+		access("foo", "baz");
+		access("foo", "qux", {expiry: 3600});
+		access("foo", "qux", {expiry: 60});
+		access(["foo", "fred"], "quux", {expiry: null});
+	}`, 0)
+	startTime := time.Now().Unix()
+	res, err := mapper.MapToChannelsAndAccess(ctx, parse(`{}`), `{}`, emptyMetaMap(), noUser)
+	require.NoError(t, err, "MapToChannelsAndAccess failed")
+	assert.Equal(t, AccessMap{"foo": BaseSetOf(t, "bar", "baz", "qux", "quux"), "fred": BaseSetOf(t, "quux")}, res.Access)
+
+	require.Len(t, res.AccessExpiry, 1)
+	require.Len(t, res.AccessExpiry["foo"], 2)
+	assert.Equal(t, uint32(4260211200), res.AccessExpiry["foo"]["bar"])
+	assert.InDelta(t, startTime+3600, int64(res.AccessExpiry["foo"]["qux"]), 5)
+
+	// Invalid expiry values log a warning, and the grant doesn't expire
+	mapper = NewChannelMapper(ctx, `function(doc) {access("foo", "bar", {expiry: "invalid"});}`, 0)
+	res, err = mapper.MapToChannelsAndAccess(ctx, parse(`{}`), `{}`, emptyMetaMap(), noUser)
+	require.NoError(t, err)
+	assert.Equal(t, AccessMap{"foo": BaseSetOf(t, "bar")}, res.Access)
+	assert.Nil(t, res.AccessExpiry)
+}
+
 // Just verify that the calls to the channel() fn show up in the output channel list.
 func TestSyncFunctionTakesArray(t *testing.T) {
 	ctx := base.TestCtx(t)
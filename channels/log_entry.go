@@ -14,6 +14,7 @@ package channels
 
 import (
 	"fmt"
+	"sort"
 	"time"
 )
 
@@ -54,6 +55,8 @@ type LogEntry struct {
 	PrevSequence uint64       // Sequence of previous active revision
 	IsPrincipal  bool         // Whether the log-entry is a tracking entry for a principal doc
 	CollectionID uint32       // Collection ID
+	SystemOnly   bool         // Whether the document is only in system channels, so isn't sent to users on star channel feeds
+	DocChannels  []string     // The document's current channels, retained when changes feeds can include channel membership
 }
 
 func (l LogEntry) String() string {
@@ -87,6 +90,18 @@ func (channelMap ChannelMap) ChannelsRemovedAtSequence(seq uint64) (ChannelMap,
 	return channelsRemoved, revIdRemoved
 }
 
+// ActiveKeys returns the sorted names of the channels the document is in, excluding those it was removed from.
+func (channelMap ChannelMap) ActiveKeys() []string {
+	result := make([]string, 0, len(channelMap))
+	for key, removal := range channelMap {
+		if removal == nil {
+			result = append(result, key)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
 func (channelMap ChannelMap) KeySet() []string {
 	result := make([]string, len(channelMap))
 	i := 0
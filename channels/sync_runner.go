@@ -112,6 +112,7 @@ type SyncRunner struct {
 	output            *ChannelMapperOutput // Results being accumulated while the JS fn runs
 	channels          []string
 	access            map[string][]string // channels granted to users via access() callback
+	accessExpiry      AccessExpiryMap     // expiry of time-boxed channel grants made via access() callback
 	roles             map[string][]string // roles granted to users via role() callback
 	expiry            *uint32             // document expiry (in seconds) specified via expiry() callback
 }
@@ -138,6 +139,7 @@ func NewSyncRunner(ctx context.Context, funcSource string, timeout time.Duration
 
 	// Implementation of the 'access()' callback:
 	runner.DefineNativeFunction("access", func(call otto.FunctionCall) otto.Value {
+		runner.addAccessExpiry(ctx, call.Argument(0), call.Argument(1), call.Argument(2))
 		return runner.addValueForUser(ctx, call.Argument(0), call.Argument(1), runner.access)
 	})
 
@@ -189,6 +191,7 @@ func NewSyncRunner(ctx context.Context, funcSource string, timeout time.Duration
 		runner.output = &ChannelMapperOutput{}
 		runner.channels = []string{}
 		runner.access = map[string][]string{}
+		runner.accessExpiry = AccessExpiryMap{}
 		runner.roles = map[string][]string{}
 		runner.expiry = nil
 	}
@@ -199,6 +202,9 @@ func NewSyncRunner(ctx context.Context, funcSource string, timeout time.Duration
 			output.Channels, err = SetFromArray(runner.channels, ExpandStar)
 			if err == nil {
 				output.Access, err = compileAccessMap(runner.access, "")
+				if err == nil {
+					output.AccessExpiry = runner.accessExpiry.compile()
+				}
 				if err == nil {
 					output.Roles, err = compileAccessMap(runner.roles, RoleAccessPrefix)
 				}
@@ -228,6 +234,40 @@ func (runner *SyncRunner) addValueForUser(ctx context.Context, user otto.Value,
 	return otto.UndefinedValue()
 }
 
+// Records the expiry of channels granted by an 'access()' call.  The optional options argument is an object with an
+// 'expiry' property, in the same formats accepted by 'expiry()'.  Grants made without an expiry are recorded with
+// expiry zero, so that they take precedence over any time-boxed grant of the same channel.
+func (runner *SyncRunner) addAccessExpiry(ctx context.Context, user otto.Value, value otto.Value, options otto.Value) {
+	var expiry uint32
+	if options.IsObject() {
+		rawExpiry, err := options.Object().Get("expiry")
+		if err != nil {
+			base.WarnfCtx(ctx, "SyncRunner: Unable to read expiry option passed to access(): %v", err)
+			return
+		}
+		if !rawExpiry.IsNull() && !rawExpiry.IsUndefined() {
+			exported, err := rawExpiry.Export()
+			if err != nil {
+				base.WarnfCtx(ctx, "SyncRunner: Unable to export expiry option passed to access(): %v Error: %s", rawExpiry, err)
+				return
+			}
+			cbsExpiry, err := base.ReflectExpiry(exported)
+			if err != nil || cbsExpiry == nil {
+				base.WarnfCtx(ctx, "SyncRunner: Invalid expiry option passed to access().  Value:%+v ", rawExpiry)
+				return
+			}
+			if *cbsExpiry != 0 {
+				expiry = uint32(base.CbsExpiryToTime(*cbsExpiry).Unix())
+			}
+		}
+	}
+	for _, name := range ottoValueToStringArray(ctx, user) {
+		for _, channel := range ottoValueToStringArray(ctx, value) {
+			runner.accessExpiry.add(name, channel, expiry)
+		}
+	}
+}
+
 func compileAccessMap(input map[string][]string, prefix string) (AccessMap, error) {
 	access := make(AccessMap, len(input))
 	for name, values := range input {
@@ -21,6 +21,7 @@ import (
 type VbSequence struct {
 	VbNo     *uint16 `json:"vb,omitempty"`
 	Sequence uint64  `json:"seq"`
+	Expiry   uint32  `json:"exp,omitempty"` // Unix time (seconds) at which a time-boxed access grant expires, 0 if the grant doesn't expire
 }
 
 func NewVbSequence(vbNo uint16, sequence uint64) VbSequence {
@@ -40,12 +41,20 @@ func (vbs VbSequence) String() string {
 }
 
 func (vbs VbSequence) Copy() VbSequence {
+	var result VbSequence
 	if vbs.VbNo == nil {
-		return NewVbSimpleSequence(vbs.Sequence)
+		result = NewVbSimpleSequence(vbs.Sequence)
 	} else {
 		vbInt := *vbs.VbNo
-		return NewVbSequence(vbInt, vbs.Sequence)
+		result = NewVbSequence(vbInt, vbs.Sequence)
 	}
+	result.Expiry = vbs.Expiry
+	return result
+}
+
+// Expired returns true if the entry has an expiry at or before the given unix time.
+func (vbs VbSequence) Expired(now uint32) bool {
+	return vbs.Expiry != 0 && vbs.Expiry <= now
 }
 
 func (vbs VbSequence) Equals(other VbSequence) bool {
@@ -157,13 +166,38 @@ func (set TimedSet) Equals(other base.Set) bool {
 }
 
 func (set TimedSet) AddChannel(channelName string, atSequence uint64) bool {
-	if atSequence > 0 {
-		if oldSequence := set[channelName]; oldSequence.Sequence == 0 || atSequence < oldSequence.Sequence {
-			set[channelName] = NewVbSimpleSequence(atSequence)
-			return true
-		}
+	return set.addChannelWithExpiry(channelName, atSequence, 0)
+}
+
+// addChannelWithExpiry adds the channel at the given sequence. When the channel is already present, the earliest
+// sequence wins and the resulting grant expires at the latest of the two expiries, or not at all if either of them
+// doesn't expire.
+func (set TimedSet) addChannelWithExpiry(channelName string, atSequence uint64, expiry uint32) bool {
+	if atSequence == 0 {
+		return false
+	}
+	oldSequence, exists := set[channelName]
+	if !exists || oldSequence.Sequence == 0 {
+		newSequence := NewVbSimpleSequence(atSequence)
+		newSequence.Expiry = expiry
+		set[channelName] = newSequence
+		return true
 	}
-	return false
+	changed := false
+	newSequence := oldSequence
+	if atSequence < oldSequence.Sequence {
+		newSequence = NewVbSimpleSequence(atSequence)
+		newSequence.Expiry = oldSequence.Expiry
+		changed = true
+	}
+	if newSequence.Expiry != 0 && (expiry == 0 || expiry > newSequence.Expiry) {
+		newSequence.Expiry = expiry
+		changed = true
+	}
+	if changed {
+		set[channelName] = newSequence
+	}
+	return changed
 }
 
 // Merges the other set into the receiver. In case of collisions the earliest sequence wins.
@@ -171,6 +205,42 @@ func (set TimedSet) Add(other TimedSet) bool {
 	return set.AddAtSequence(other, 0)
 }
 
+// SetExpiry sets the expiry of the channels present in both the set and expiries, and clears the expiry of all other
+// channels in the set.  Returns true if any expiry changed.
+func (set TimedSet) SetExpiry(expiries map[string]uint32) bool {
+	changed := false
+	for ch, vbSeq := range set {
+		if expiry := expiries[ch]; vbSeq.Expiry != expiry {
+			vbSeq.Expiry = expiry
+			set[ch] = vbSeq
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Unexpired returns a copy of the set without any entries that have expired as of the given unix time.
+func (set TimedSet) Unexpired(now uint32) TimedSet {
+	result := make(TimedSet, len(set))
+	for ch, vbSeq := range set {
+		if !vbSeq.Expired(now) {
+			result[ch] = vbSeq.Copy()
+		}
+	}
+	return result
+}
+
+// NextExpiry returns the earliest expiry of any entry in the set, or false if no entries expire.
+func (set TimedSet) NextExpiry() (expiry uint32, ok bool) {
+	for _, vbSeq := range set {
+		if vbSeq.Expiry != 0 && (!ok || vbSeq.Expiry < expiry) {
+			expiry = vbSeq.Expiry
+			ok = true
+		}
+	}
+	return expiry, ok
+}
+
 // Merges the other set into the receiver at a given sequence. */
 func (set TimedSet) AddAtSequence(other TimedSet, atSequence uint64) bool {
 	changed := false
@@ -183,7 +253,7 @@ func (set TimedSet) AddAtSequence(other TimedSet, atSequence uint64) bool {
 			if vbSeq.Sequence < atSequence {
 				vbSeq.Sequence = atSequence
 			}
-			if set.AddChannel(ch, vbSeq.Sequence) {
+			if set.addChannelWithExpiry(ch, vbSeq.Sequence, vbSeq.Expiry) {
 				changed = true
 			}
 		}
@@ -279,18 +349,20 @@ func (setPtr *TimedSet) UnmarshalJSON(data []byte) error {
 
 func (set TimedSet) MarshalJSON() ([]byte, error) {
 
-	// If no vbuckets are defined, marshal as SequenceOnlySet for backwards compatibility.  Otherwise marshal with vbuckets
-	hasVbucket := false
+	// If no vbuckets or expiries are defined, marshal as SequenceOnlySet for backwards compatibility.  Otherwise marshal
+	// in normal form
+	hasVbucketOrExpiry := false
 	for _, vbSeq := range set {
-		if vbSeq.VbNo != nil {
-			hasVbucket = true
+		if vbSeq.VbNo != nil || vbSeq.Expiry != 0 {
+			hasVbucketOrExpiry = true
 			break
 		}
 	}
-	if hasVbucket {
+	if hasVbucketOrExpiry {
 		// Normal form - unmarshal as map[string]VbSequence.  Need to convert back to simple map[string]VbSequence to avoid
 		// having json.Marshal just call back into this function.
-		// Marshals entries as "ABC":{"vb":5,"seq":1} or "CBS":{"seq":1}, depending on whether VbSequence.VbNo is nil
+		// Marshals entries as "ABC":{"vb":5,"seq":1} or "CBS":{"seq":1}, depending on whether VbSequence.VbNo is nil.
+		// Expiring entries are marshalled as "ABC":{"seq":1,"exp":1700000000}
 		var plainMap map[string]VbSequence
 		plainMap = set
 		return base.JSONMarshal(plainMap)
@@ -15,6 +15,7 @@ import (
 
 	"github.com/couchbase/sync_gateway/base"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTimedSetMarshal(t *testing.T) {
@@ -73,6 +74,36 @@ func TestTimedSetUnmarshal(t *testing.T) {
 	assert.Equal(t, fmt.Sprintf("%s", TimedSet{"a": NewVbSequence(21, 17), "b": NewVbSequence(25, 23)}), fmt.Sprintf("%s", str.Channels))
 }
 
+func TestTimedSetExpiry(t *testing.T) {
+	set := AtSequence(BaseSetOf(t, "a", "b"), 17)
+	_, ok := set.NextExpiry()
+	assert.False(t, ok)
+
+	assert.True(t, set.SetExpiry(map[string]uint32{"a": 1000}))
+	assert.False(t, set.SetExpiry(map[string]uint32{"a": 1000}))
+	expiry, ok := set.NextExpiry()
+	assert.True(t, ok)
+	assert.Equal(t, uint32(1000), expiry)
+
+	// Expiring entries are marshalled in normal form, and survive a round trip
+	bytes, err := base.JSONMarshal(set)
+	require.NoError(t, err)
+	assert.Contains(t, string(bytes), `"a":{"seq":17,"exp":1000}`)
+	assert.Contains(t, string(bytes), `"b":{"seq":17}`)
+	var unmarshalled TimedSet
+	require.NoError(t, base.JSONUnmarshal(bytes, &unmarshalled))
+	assert.Equal(t, set, unmarshalled)
+
+	assert.Equal(t, TimedSet{"a": {Sequence: 17, Expiry: 1000}, "b": NewVbSimpleSequence(17)}, set.Unexpired(999))
+	assert.Equal(t, TimedSet{"b": NewVbSimpleSequence(17)}, set.Unexpired(1000))
+
+	// Merging keeps the earliest sequence and the latest expiry, with unexpiring grants taking precedence
+	merged := TimedSet{}
+	merged.Add(TimedSet{"a": {Sequence: 20, Expiry: 2000}, "b": {Sequence: 5, Expiry: 500}})
+	merged.Add(set)
+	assert.Equal(t, TimedSet{"a": {Sequence: 17, Expiry: 2000}, "b": NewVbSimpleSequence(5)}, merged)
+}
+
 func TestEncodeSequenceID(t *testing.T) {
 	set := TimedSet{"ABC": NewVbSimpleSequence(17), "CBS": NewVbSimpleSequence(23), "BBC": NewVbSimpleSequence(1)}
 	encoded := set.String()
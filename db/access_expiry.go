@@ -0,0 +1,118 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// accessExpiryKey identifies the channels of a user or role in a collection.
+type accessExpiryKey struct {
+	name       string
+	isUser     bool
+	scope      string
+	collection string
+}
+
+// accessExpiryTimer is a pending invalidation of a principal's channels.
+type accessExpiryTimer struct {
+	expiry uint32
+	timer  *time.Timer
+}
+
+// accessExpiryScheduler invalidates the channels of users and roles when their time-boxed channel grants expire, so
+// that the expired grants are revoked from active changes feeds without waiting for the principal to be reloaded.
+type accessExpiryScheduler struct {
+	lock    sync.Mutex
+	timers  map[accessExpiryKey]*accessExpiryTimer
+	stopped bool
+}
+
+func newAccessExpiryScheduler() *accessExpiryScheduler {
+	return &accessExpiryScheduler{
+		timers: make(map[accessExpiryKey]*accessExpiryTimer),
+	}
+}
+
+// schedule runs invalidate once expiry (unix time in seconds) has passed.  If an invalidation is already pending for
+// the key, only the earlier of the two is kept.
+func (s *accessExpiryScheduler) schedule(key accessExpiryKey, expiry uint32, invalidate func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.stopped {
+		return
+	}
+	if existing, ok := s.timers[key]; ok {
+		if existing.expiry <= expiry {
+			return
+		}
+		existing.timer.Stop()
+	}
+	// Wait until the second after expiry, as grants are only treated as expired once their expiry is in the past
+	delay := time.Until(time.Unix(int64(expiry)+1, 0))
+	pending := &accessExpiryTimer{expiry: expiry}
+	pending.timer = time.AfterFunc(delay, func() {
+		s.lock.Lock()
+		if s.timers[key] != pending {
+			s.lock.Unlock()
+			return
+		}
+		delete(s.timers, key)
+		s.lock.Unlock()
+		invalidate()
+	})
+	s.timers[key] = pending
+}
+
+// stop cancels all pending invalidations.
+func (s *accessExpiryScheduler) stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stopped = true
+	for key, pending := range s.timers {
+		pending.timer.Stop()
+		delete(s.timers, key)
+	}
+}
+
+// AccessExpirySequence returns the sequence at which expired channel grants are revoked.
+// This is part of the auth.AccessExpiryHandler interface.
+func (context *DatabaseContext) AccessExpirySequence(ctx context.Context) (uint64, error) {
+	return context.LastSequence(ctx)
+}
+
+// ScheduleAccessExpiry invalidates the channels of a user or role in scope.collection once expiry has passed.
+// This is part of the auth.AccessExpiryHandler interface.
+func (context *DatabaseContext) ScheduleAccessExpiry(ctx context.Context, name string, isUser bool, scope string, collection string, expiry uint32) {
+	key := accessExpiryKey{name: name, isUser: isUser, scope: scope, collection: collection}
+	// The caller's context may be request scoped, so invalidate using a context that won't have been cancelled
+	ctx = base.NewNonCancelCtxForDatabase(context.Name, context.Options.LoggingConfig.Console).Ctx
+	context.accessExpiry.schedule(key, expiry, func() {
+		dbCollection, err := context.GetDatabaseCollection(scope, collection)
+		if err != nil {
+			base.WarnfCtx(ctx, "Unable to revoke expired channel access of %q: %v", base.UD(name), err)
+			return
+		}
+		invalSeq, err := context.AccessExpirySequence(ctx)
+		if err != nil {
+			base.WarnfCtx(ctx, "Unable to revoke expired channel access of %q: %v", base.UD(name), err)
+			return
+		}
+		base.InfofCtx(ctx, base.KeyAccess, "Revoking expired channel access of %q (%s.%s) at sequence %d", base.UD(name), base.MD(scope), base.MD(collection), invalSeq)
+		if isUser {
+			dbCollection.invalUserChannels(ctx, name, invalSeq)
+		} else {
+			dbCollection.invalRoleChannels(ctx, name, invalSeq)
+		}
+	})
+}
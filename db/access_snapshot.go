@@ -0,0 +1,203 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/couchbase/sync_gateway/auth"
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// DefaultAccessSnapshotInterval is how often users' access is snapshotted if snapshots are enabled without an interval.
+const DefaultAccessSnapshotInterval = 24 * time.Hour
+
+// AccessSnapshotOptions enables periodic snapshots of each user's effective access, so the channels a user could read
+// at a point in time can be audited later.
+type AccessSnapshotOptions struct {
+	Interval  time.Duration                // How often users' access is snapshotted. 0 disables snapshots
+	Retention time.Duration                // How long superseded snapshots are kept for. 0 keeps them indefinitely
+	Keyspace  *base.ScopeAndCollectionName // Collection in the database's bucket storing snapshots. If nil, they're stored in the metadata store
+}
+
+// AccessSnapshot is a user's effective access, as first recorded at Time.
+type AccessSnapshot struct {
+	Time     time.Time           `json:"time"`
+	Disabled bool                `json:"disabled,omitempty"`
+	Roles    []string            `json:"roles"`    // Roles the user belonged to, including those granted by the sync function
+	Channels map[string][]string `json:"channels"` // Channels the user could read, including via roles, keyed by scope.collection
+}
+
+// sameAccess returns true if the snapshots record the same access, regardless of when they were taken.
+func (s *AccessSnapshot) sameAccess(other *AccessSnapshot) bool {
+	return s.Disabled == other.Disabled && reflect.DeepEqual(s.Roles, other.Roles) && reflect.DeepEqual(s.Channels, other.Channels)
+}
+
+// accessSnapshotDoc stores a user's access history.  A new snapshot is only appended when the user's access changes,
+// so each snapshot is the user's access from its Time until the next snapshot's Time.
+type accessSnapshotDoc struct {
+	Snapshots    []AccessSnapshot `json:"snapshots"`
+	LastVerified time.Time        `json:"last_verified"` // When the latest snapshot was last confirmed to be the user's access
+}
+
+// UserAccessSnapshot is the access a user had at a point in time, as recorded by access snapshots.
+type UserAccessSnapshot struct {
+	Name          string              `json:"name"`
+	Since         time.Time           `json:"since"`          // When this access was first recorded
+	VerifiedUntil time.Time           `json:"verified_until"` // When this access was last recorded, or superseded
+	Disabled      bool                `json:"disabled,omitempty"`
+	Roles         []string            `json:"roles"`
+	Channels      map[string][]string `json:"channels"`
+}
+
+// errAccessSnapshotStopped stops snapshotting users' access when the database is closed.
+var errAccessSnapshotStopped = errors.New("access snapshot stopped")
+
+// accessSnapshotDataStore returns the data store that snapshots are stored in.
+func (db *DatabaseContext) accessSnapshotDataStore() base.DataStore {
+	if db.accessSnapshotStore != nil {
+		return db.accessSnapshotStore
+	}
+	return db.MetadataStore
+}
+
+// userAccessSnapshot returns the user's current effective access.
+func (db *DatabaseContext) userAccessSnapshot(user auth.User, now time.Time) *AccessSnapshot {
+	snapshot := &AccessSnapshot{
+		Time:     now,
+		Disabled: user.Disabled(),
+		Roles:    user.RoleNames().AllKeys(),
+		Channels: make(map[string][]string, len(db.CollectionByID)),
+	}
+	sort.Strings(snapshot.Roles)
+	for _, collection := range db.CollectionByID {
+		channels := user.InheritedCollectionChannels(collection.ScopeName, collection.Name).AllKeys()
+		sort.Strings(channels)
+		snapshot.Channels[collection.ScopeName+base.ScopeCollectionSeparator+collection.Name] = channels
+	}
+	return snapshot
+}
+
+// SnapshotAccess records the current effective access of every user, appending a snapshot for users whose access has
+// changed since their last snapshot.  Users whose access was recorded less than half an interval ago, for example by
+// another node, are skipped.
+func (db *DatabaseContext) SnapshotAccess(ctx context.Context, terminator *base.SafeTerminator) error {
+	users, _, err := db.AllPrincipalIDs(ctx)
+	if err != nil {
+		return err
+	}
+	authenticator := db.Authenticator(ctx)
+	now := time.Now().UTC()
+	snapshotted := 0
+	for _, username := range users {
+		select {
+		case <-terminator.Done():
+			return errAccessSnapshotStopped
+		default:
+		}
+		user, err := authenticator.GetUser(username)
+		if err != nil {
+			base.WarnfCtx(ctx, "Access Snapshot: Unable to load user %s: %v", base.UD(username), err)
+			continue
+		}
+		if user == nil {
+			continue
+		}
+		changed, err := db.snapshotUserAccess(ctx, user, now)
+		if err != nil {
+			base.WarnfCtx(ctx, "Access Snapshot: Unable to snapshot access of user %s: %v", base.UD(username), err)
+			continue
+		}
+		if changed {
+			snapshotted++
+		}
+	}
+	base.InfofCtx(ctx, base.KeyAll, "Access Snapshot: Recorded changed access for %d of %d users", snapshotted, len(users))
+	return nil
+}
+
+// snapshotUserAccess records the user's current access as of now.  Returns true if a new snapshot was appended.
+func (db *DatabaseContext) snapshotUserAccess(ctx context.Context, user auth.User, now time.Time) (changed bool, err error) {
+	snapshot := db.userAccessSnapshot(user, now)
+	options := db.Options.AccessSnapshotOptions
+	_, err = db.accessSnapshotDataStore().Update(db.MetadataKeys.AccessSnapshotKey(user.Name()), 0, func(current []byte) (updated []byte, expiry *uint32, delete bool, err error) {
+		var doc accessSnapshotDoc
+		if len(current) > 0 {
+			if err := base.JSONUnmarshal(current, &doc); err != nil {
+				return nil, nil, false, err
+			}
+		}
+		if now.Sub(doc.LastVerified) < options.Interval/2 {
+			return nil, nil, false, base.ErrUpdateCancel
+		}
+		changed = len(doc.Snapshots) == 0 || !doc.Snapshots[len(doc.Snapshots)-1].sameAccess(snapshot)
+		if changed {
+			doc.Snapshots = append(doc.Snapshots, *snapshot)
+		}
+		doc.LastVerified = now
+		doc.pruneSnapshots(options.Retention, now)
+		updated, err = base.JSONMarshal(doc)
+		return updated, nil, false, err
+	})
+	if errors.Is(err, base.ErrUpdateCancel) {
+		return false, nil
+	}
+	return changed, err
+}
+
+// pruneSnapshots removes snapshots superseded longer ago than the retention period.  The snapshot in effect at the
+// start of the retention period is kept, so access can still be determined for the whole period.
+func (doc *accessSnapshotDoc) pruneSnapshots(retention time.Duration, now time.Time) {
+	if retention == 0 {
+		return
+	}
+	cutoff := now.Add(-retention)
+	pruned := 0
+	for pruned < len(doc.Snapshots)-1 && !doc.Snapshots[pruned+1].Time.After(cutoff) {
+		pruned++
+	}
+	doc.Snapshots = doc.Snapshots[pruned:]
+}
+
+// GetUserAccessSnapshot returns the access the user had at the given time, according to their access snapshots.
+// Returns a not found error if there's no snapshot of the user's access at or before that time.
+func (db *DatabaseContext) GetUserAccessSnapshot(ctx context.Context, username string, at time.Time) (*UserAccessSnapshot, error) {
+	var doc accessSnapshotDoc
+	_, err := db.accessSnapshotDataStore().Get(db.MetadataKeys.AccessSnapshotKey(username), &doc)
+	if base.IsDocNotFoundError(err) {
+		return nil, base.HTTPErrorf(http.StatusNotFound, "No access snapshots found for user")
+	} else if err != nil {
+		return nil, err
+	}
+
+	i := sort.Search(len(doc.Snapshots), func(i int) bool {
+		return doc.Snapshots[i].Time.After(at)
+	}) - 1
+	if i < 0 {
+		return nil, base.HTTPErrorf(http.StatusNotFound, "No access snapshot found for user at or before %s", at.Format(time.RFC3339))
+	}
+	snapshot := doc.Snapshots[i]
+	verifiedUntil := doc.LastVerified
+	if i+1 < len(doc.Snapshots) {
+		verifiedUntil = doc.Snapshots[i+1].Time
+	}
+	return &UserAccessSnapshot{
+		Name:          username,
+		Since:         snapshot.Time,
+		VerifiedUntil: verifiedUntil,
+		Disabled:      snapshot.Disabled,
+		Roles:         snapshot.Roles,
+		Channels:      snapshot.Channels,
+	}, nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/couchbase/sync_gateway/auth"
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessSnapshots(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	db.Options.AccessSnapshotOptions = AccessSnapshotOptions{Interval: time.Hour, Retention: 48 * time.Hour}
+	collection := GetSingleDatabaseCollection(t, db.DatabaseContext)
+	keyspace := collection.ScopeName + "." + collection.Name
+
+	authenticator := db.Authenticator(ctx)
+	user, err := authenticator.NewUser("alice", "password", nil)
+	require.NoError(t, err)
+	user.SetCollectionExplicitChannels(collection.ScopeName, collection.Name, channels.AtSequence(base.SetOf("A"), 1), 1)
+	require.NoError(t, authenticator.Save(user))
+	// Reloading the user rebuilds their channels and roles
+	reloadUser := func() auth.User {
+		user, err := authenticator.GetUser("alice")
+		require.NoError(t, err)
+		return user
+	}
+	user = reloadUser()
+
+	requireNotFound := func(err error) {
+		var httpErr *base.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusNotFound, httpErr.Status)
+	}
+	_, err = db.GetUserAccessSnapshot(ctx, "alice", time.Now())
+	requireNotFound(err)
+
+	start := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	changed, err := db.snapshotUserAccess(ctx, user, start)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	// Snapshots taken less than half an interval after the last one are skipped, and unchanged access isn't stored again
+	changed, err = db.snapshotUserAccess(ctx, user, start.Add(time.Minute))
+	require.NoError(t, err)
+	assert.False(t, changed)
+	changed, err = db.snapshotUserAccess(ctx, user, start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	user.SetCollectionExplicitChannels(collection.ScopeName, collection.Name, channels.AtSequence(base.SetOf("B"), 2), 2)
+	user.SetExplicitRoles(channels.TimedSet{"editor": channels.NewVbSimpleSequence(2)}, 2)
+	require.NoError(t, authenticator.Save(user))
+	user = reloadUser()
+	changed, err = db.snapshotUserAccess(ctx, user, start.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	// Access at a point in time is the snapshot in effect at that time
+	snapshot, err := db.GetUserAccessSnapshot(ctx, "alice", start.Add(90*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, start, snapshot.Since)
+	assert.Equal(t, start.Add(2*time.Hour), snapshot.VerifiedUntil)
+	assert.Equal(t, []string{"!", "A"}, snapshot.Channels[keyspace])
+	assert.Empty(t, snapshot.Roles)
+	snapshot, err = db.GetUserAccessSnapshot(ctx, "alice", start.Add(24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, start.Add(2*time.Hour), snapshot.Since)
+	assert.Equal(t, []string{"!", "B"}, snapshot.Channels[keyspace])
+	assert.Equal(t, []string{"editor"}, snapshot.Roles)
+	_, err = db.GetUserAccessSnapshot(ctx, "alice", start.Add(-time.Second))
+	requireNotFound(err)
+
+	// Superseded snapshots are pruned once the retention period has passed, but the snapshot in effect at the start of
+	// the retention period is kept
+	_, err = db.snapshotUserAccess(ctx, user, start.Add(51*time.Hour))
+	require.NoError(t, err)
+	_, err = db.GetUserAccessSnapshot(ctx, "alice", start.Add(90*time.Minute))
+	requireNotFound(err)
+	snapshot, err = db.GetUserAccessSnapshot(ctx, "alice", start.Add(51*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, start.Add(2*time.Hour), snapshot.Since)
+	assert.Equal(t, start.Add(51*time.Hour), snapshot.VerifiedUntil)
+}
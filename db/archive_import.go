@@ -0,0 +1,216 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// DefaultArchiveImportMaxDocsPerSec is the default rate at which documents are written when importing an archive.
+const DefaultArchiveImportMaxDocsPerSec = 500
+
+// ArchiveImportOptions control how an archive is imported by ImportArchive.
+type ArchiveImportOptions struct {
+	DryRun        bool // If true, documents are validated but not written
+	MaxDocsPerSec int  // Maximum rate at which documents are written, to limit the impact on the live workload. Zero is unlimited
+}
+
+// ArchiveImportResult is the outcome of importing a single document from an archive.
+type ArchiveImportResult struct {
+	DocID        string `json:"id"`
+	RevID        string `json:"rev,omitempty"`
+	RevPreserved bool   `json:"rev_preserved,omitempty"` // True if the document was written with its archived revision ID
+	Status       int    `json:"status"`
+	Error        string `json:"error,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// ArchiveImportSummary is the outcome of importing an archive.
+type ArchiveImportSummary struct {
+	Imported int  `json:"imported"`
+	Errors   int  `json:"errors"`
+	DryRun   bool `json:"dry_run"`
+}
+
+// ImportArchive restores the documents in a newline-delimited JSON archive, as written by ExportChannel, through the
+// normal write path. Each line is either an export entry with a "doc" property, or a document body. Lines that
+// contain neither (such as the export summary, or entries for documents that couldn't be exported) are ignored.
+//
+// Documents are written with their archived revision ID when that doesn't conflict with the document's existing
+// revisions, otherwise as a new revision of the current document. Documents without a revision ID are written as new
+// revisions. onResult is called with the outcome of each document; an error returned by onResult stops the import.
+func (db *DatabaseCollectionWithUser) ImportArchive(ctx context.Context, r io.Reader, options ArchiveImportOptions, onResult func(ArchiveImportResult) error) (*ArchiveImportSummary, error) {
+	summary := &ArchiveImportSummary{DryRun: options.DryRun}
+	throttle := newArchiveImportThrottle(options.MaxDocsPerSec)
+
+	reader := bufio.NewReader(r)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, readErr
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			body, err := archiveLineBody(line)
+			if err != nil {
+				return nil, base.HTTPErrorf(http.StatusBadRequest, "Invalid archive line: %v", err)
+			}
+			if body != nil {
+				if !options.DryRun {
+					if err := throttle.wait(ctx); err != nil {
+						return nil, err
+					}
+				}
+				result := db.importArchiveDoc(ctx, body, options.DryRun)
+				if result.Error != "" {
+					base.InfofCtx(ctx, base.KeyCRUD, "Archive import: Doc %q --> %d %s", base.UD(result.DocID), result.Status, result.Reason)
+					summary.Errors++
+				} else {
+					summary.Imported++
+				}
+				if err := onResult(result); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	base.InfofCtx(ctx, base.KeyCRUD, "Archive import complete: %d docs imported, %d errors (dry run: %t)", summary.Imported, summary.Errors, summary.DryRun)
+	return summary, nil
+}
+
+// archiveLineBody returns the document body for a line of an archive, or nil if the line doesn't contain a document.
+func archiveLineBody(line []byte) (Body, error) {
+	var body Body
+	if err := body.Unmarshal(line); err != nil {
+		return nil, err
+	}
+	if doc, ok := body["doc"].(map[string]interface{}); ok {
+		return doc, nil
+	}
+	if _, ok := body[BodyId]; ok {
+		return body, nil
+	}
+	return nil, nil
+}
+
+// importArchiveDoc writes (or when dryRun is set, validates) a single archived document.
+func (db *DatabaseCollectionWithUser) importArchiveDoc(ctx context.Context, body Body, dryRun bool) ArchiveImportResult {
+	docID, _ := body[BodyId].(string)
+	revID, _ := body[BodyRev].(string)
+	result := ArchiveImportResult{DocID: docID, RevID: revID}
+
+	err := db.writeArchiveDoc(ctx, body, dryRun, &result)
+	if err != nil {
+		result.Status, result.Reason = base.ErrorAsHTTPStatus(err)
+		result.Error = base.CouchHTTPErrorName(result.Status)
+	} else if dryRun {
+		result.Status = http.StatusOK
+	} else {
+		result.Status = http.StatusCreated
+	}
+	return result
+}
+
+func (db *DatabaseCollectionWithUser) writeArchiveDoc(ctx context.Context, body Body, dryRun bool, result *ArchiveImportResult) error {
+	if result.DocID == "" {
+		return base.HTTPErrorf(http.StatusBadRequest, "Missing %s", BodyId)
+	}
+	if err := validateAPIDocUpdate(body); err != nil {
+		return err
+	}
+
+	var history []string
+	if result.RevID != "" {
+		if history = ParseRevisions(ctx, body); history == nil {
+			return base.HTTPErrorf(http.StatusBadRequest, "Invalid %s", BodyRev)
+		}
+	}
+	if dryRun {
+		result.RevPreserved = history != nil
+		return nil
+	}
+
+	if history == nil {
+		newRevID, _, err := db.Put(ctx, result.DocID, body)
+		result.RevID = newRevID
+		return err
+	}
+
+	written, _, err := db.PutExistingRevWithBody(ctx, result.DocID, body.ShallowCopy(), history, true)
+	if err == nil && written != nil {
+		result.RevPreserved = true
+		return nil
+	}
+	if err != nil {
+		if status, _ := base.ErrorAsHTTPStatus(err); status != http.StatusConflict {
+			return err
+		}
+	}
+
+	// The archived revision conflicts with the document's existing revisions, or has since been replaced by a later
+	// revision, so write it as a new revision of the current document instead.
+	doc, err := db.GetDocument(ctx, result.DocID, DocUnmarshalSync)
+	if err != nil {
+		return err
+	}
+	if doc.CurrentRev == result.RevID {
+		result.RevPreserved = true
+		return nil
+	}
+	delete(body, BodyRevisions)
+	body[BodyRev] = doc.CurrentRev
+	newRevID, _, err := db.Put(ctx, result.DocID, body)
+	result.RevID = newRevID
+	return err
+}
+
+// archiveImportThrottle limits the rate at which archived documents are written.
+type archiveImportThrottle struct {
+	interval time.Duration
+	next     time.Time
+}
+
+func newArchiveImportThrottle(maxPerSec int) *archiveImportThrottle {
+	throttle := &archiveImportThrottle{}
+	if maxPerSec > 0 {
+		throttle.interval = time.Second / time.Duration(maxPerSec)
+	}
+	return throttle
+}
+
+// wait blocks until the next write is permitted, or the context is done.
+func (t *archiveImportThrottle) wait(ctx context.Context) error {
+	if t.interval == 0 {
+		return nil
+	}
+	now := time.Now()
+	if delay := t.next.Sub(now); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		now = t.next
+	}
+	t.next = now.Add(t.interval)
+	return nil
+}
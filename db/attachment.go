@@ -58,7 +58,11 @@ type DocAttachment struct {
 // ErrAttachmentTooLarge is returned when an attempt to attach an oversize attachment is made.
 var ErrAttachmentTooLarge = errors.New("attachment too large")
 
-const maxAttachmentSizeBytes = 20 * 1024 * 1024
+// MaxAttachmentSizeBytes is the maximum size of an attachment.
+const MaxAttachmentSizeBytes = 20 * 1024 * 1024
+
+// MaxDocumentSizeBytes is the maximum size of a document, as stored in the bucket.
+const MaxDocumentSizeBytes = 20 * 1024 * 1024
 
 // Given Attachments Meta to be stored in the database, storeAttachments goes through the map, finds attachments with
 // inline bodies, copies the bodies into the Couchbase db, and replaces the bodies with the 'digest' attributes which
@@ -83,6 +87,13 @@ func (db *DatabaseCollectionWithUser) storeAttachments(ctx context.Context, doc
 			if err != nil {
 				return nil, err
 			}
+			attachmentLength := int64(len(attachment))
+			if length, ok := meta["length"].(float64); ok && meta["encoding"] != nil {
+				attachmentLength = int64(length)
+			}
+			if err := db.checkAttachmentPolicy(name, meta, attachmentLength); err != nil {
+				return nil, err
+			}
 			digest := Sha1DigestKey(attachment)
 			key := MakeAttachmentKey(AttVersion2, doc.ID, digest)
 			newAttachmentData[key] = attachment
@@ -247,7 +258,7 @@ func (db *DatabaseCollectionWithUser) setAttachment(ctx context.Context, key str
 func (db *DatabaseCollectionWithUser) setAttachments(ctx context.Context, attachments AttachmentData) error {
 	for key, data := range attachments {
 		attachmentSize := int64(len(data))
-		if attachmentSize > int64(maxAttachmentSizeBytes) {
+		if attachmentSize > int64(MaxAttachmentSizeBytes) {
 			return ErrAttachmentTooLarge
 		}
 		_, err := db.dataStore.AddRaw(key, 0, data)
@@ -0,0 +1,102 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// AttachmentPolicy restricts the size and content type of attachments that can be written.  Policies are only
+// enforced when attachment data is written, existing attachments aren't affected.
+type AttachmentPolicy struct {
+	MaxSizeBytes        int64    // Maximum attachment size in bytes, 0 for no limit other than the maximum document size
+	AllowedContentTypes []string // If set, only attachments with a matching content type can be written
+	BlockedContentTypes []string // Attachments with a matching content type can't be written
+}
+
+// validateAttachment returns a 413 error if an attachment of the given length exceeds the maximum size, or a 415 error
+// if its content type isn't allowed.  Content types can be matched exactly, or by wildcard subtype (e.g. "image/*").
+// Attachments without a content type are treated as "application/octet-stream".
+func (p *AttachmentPolicy) validateAttachment(name string, contentType string, length int64) error {
+	if p == nil {
+		return nil
+	}
+	if p.MaxSizeBytes > 0 && length > p.MaxSizeBytes {
+		return base.HTTPErrorf(http.StatusRequestEntityTooLarge, "Attachment %q is %d bytes, which exceeds the maximum attachment size of %d bytes", base.UD(name), length, p.MaxSizeBytes)
+	}
+	if len(p.AllowedContentTypes) == 0 && len(p.BlockedContentTypes) == 0 {
+		return nil
+	}
+	mediaType := normalizeContentType(contentType)
+	if len(p.AllowedContentTypes) > 0 && !contentTypeMatches(mediaType, p.AllowedContentTypes) {
+		return base.HTTPErrorf(http.StatusUnsupportedMediaType, "Attachment %q has content type %q, which is not an allowed attachment content type", base.UD(name), mediaType)
+	}
+	if contentTypeMatches(mediaType, p.BlockedContentTypes) {
+		return base.HTTPErrorf(http.StatusUnsupportedMediaType, "Attachment %q has content type %q, which is a blocked attachment content type", base.UD(name), mediaType)
+	}
+	return nil
+}
+
+// normalizeContentType returns the lower case media type of a content type, without any parameters.
+func normalizeContentType(contentType string) string {
+	if contentType == "" {
+		return "application/octet-stream"
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, _, _ = strings.Cut(contentType, ";")
+	}
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+// contentTypeMatches returns true if mediaType matches any of the patterns.
+func contentTypeMatches(mediaType string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "*/*" || pattern == mediaType {
+			return true
+		}
+		if strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// AttachmentPolicy returns the attachment policy for the collection, or the database's attachment policy if the
+// collection doesn't have one.  Returns nil if there's no policy.
+func (c *DatabaseCollection) AttachmentPolicy() *AttachmentPolicy {
+	if c.attachmentPolicy != nil {
+		return c.attachmentPolicy
+	}
+	return c.dbCtx.Options.AttachmentPolicy
+}
+
+// checkAttachmentPolicy validates an attachment being written against the collection's attachment policy, updating
+// the rejection stats when it isn't allowed.
+func (c *DatabaseCollection) checkAttachmentPolicy(name string, meta map[string]interface{}, length int64) error {
+	policy := c.AttachmentPolicy()
+	if policy == nil {
+		return nil
+	}
+	contentType, _ := meta["content_type"].(string)
+	err := policy.validateAttachment(name, contentType, length)
+	if err != nil {
+		if status, _ := base.ErrorAsHTTPStatus(err); status == http.StatusRequestEntityTooLarge {
+			c.dbStats().Database().NumAttachmentsRejectedSize.Add(1)
+		} else {
+			c.dbStats().Database().NumAttachmentsRejectedContentType.Add(1)
+		}
+	}
+	return err
+}
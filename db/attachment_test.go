@@ -1546,3 +1546,41 @@ func TestLargeAttachments(t *testing.T) {
 	require.ErrorAs(t, err, &httpErr, "Created doc with huge attachment")
 	require.Equal(t, http.StatusRequestEntityTooLarge, httpErr.Status)
 }
+
+func TestAttachmentPolicyValidate(t *testing.T) {
+	policy := &AttachmentPolicy{
+		MaxSizeBytes:        100,
+		AllowedContentTypes: []string{"image/*", "Text/Plain"},
+		BlockedContentTypes: []string{"image/svg+xml"},
+	}
+
+	testCases := []struct {
+		name           string
+		contentType    string
+		length         int64
+		expectedStatus int
+	}{
+		{name: "allowed wildcard", contentType: "image/png", length: 10},
+		{name: "allowed with parameters", contentType: "text/plain; charset=utf-8", length: 10},
+		{name: "maximum size", contentType: "image/png", length: 100},
+		{name: "too large", contentType: "image/png", length: 101, expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "not allowed", contentType: "application/pdf", length: 10, expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "no content type", contentType: "", length: 10, expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "blocked", contentType: "image/svg+xml", length: 10, expectedStatus: http.StatusUnsupportedMediaType},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			err := policy.validateAttachment("att", test.contentType, test.length)
+			if test.expectedStatus == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			status, _ := base.ErrorAsHTTPStatus(err)
+			assert.Equal(t, test.expectedStatus, status)
+		})
+	}
+
+	var nilPolicy *AttachmentPolicy
+	assert.NoError(t, nilPolicy.validateAttachment("att", "application/pdf", 1000))
+}
@@ -266,6 +266,37 @@ func (b *BackgroundManager) GetStatus(ctx context.Context) ([]byte, error) {
 	return status, err
 }
 
+// BackgroundTaskStatus is the status of a background manager in a form common to all background managers, as reported
+// by the background tasks endpoint.
+type BackgroundTaskStatus struct {
+	Name      string                 `json:"name"`
+	State     BackgroundProcessState `json:"status"`
+	Phase     string                 `json:"phase,omitempty"`
+	StartTime time.Time              `json:"start_time"`
+	LastError string                 `json:"last_error,omitempty"`
+	Progress  map[string]interface{} `json:"progress,omitempty"` // Process specific properties, such as counts of the documents processed
+}
+
+// GetTaskStatus returns the background manager's status, with its process specific properties as progress.
+func (b *BackgroundManager) GetTaskStatus(ctx context.Context) (*BackgroundTaskStatus, error) {
+	statusJSON, err := b.GetStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var status BackgroundTaskStatus
+	if err := base.JSONUnmarshal(statusJSON, &status); err != nil {
+		return nil, err
+	}
+	if err := base.JSONUnmarshal(statusJSON, &status.Progress); err != nil {
+		return nil, err
+	}
+	for _, property := range []string{"status", "phase", "start_time", "last_error"} {
+		delete(status.Progress, property)
+	}
+	status.Name = b.GetName()
+	return &status, nil
+}
+
 func (b *BackgroundManager) getStatusLocal() ([]byte, []byte, error) {
 	b.lock.Lock()
 	defer b.lock.Unlock()
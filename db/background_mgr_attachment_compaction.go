@@ -147,6 +147,9 @@ func (a *AttachmentCompactionManager) Run(ctx context.Context, options map[strin
 	var err error
 	switch a.Phase {
 	case "mark", "":
+		if !database.MaintenanceSchedule.WaitForWindow(ctx, "attachment compaction mark phase", terminator) {
+			return nil
+		}
 		a.SetPhase("mark")
 		worker := func() (shouldRetry bool, err error, value interface{}) {
 			persistClusterStatus()
@@ -167,6 +170,9 @@ func (a *AttachmentCompactionManager) Run(ctx context.Context, options map[strin
 		}
 		fallthrough
 	case "sweep":
+		if !database.MaintenanceSchedule.WaitForWindow(ctx, "attachment compaction sweep phase", terminator) {
+			return nil
+		}
 		a.SetPhase("sweep")
 		persistClusterStatus()
 		_, err := attachmentCompactSweepPhase(ctx, dataStore, collectionID, database, a.CompactID, a.VBUUIDs, a.dryRun, terminator, &a.PurgedAttachments)
@@ -175,6 +181,9 @@ func (a *AttachmentCompactionManager) Run(ctx context.Context, options map[strin
 		}
 		fallthrough
 	case "cleanup":
+		if !database.MaintenanceSchedule.WaitForWindow(ctx, "attachment compaction cleanup phase", terminator) {
+			return nil
+		}
 		a.SetPhase("cleanup")
 		worker := func() (shouldRetry bool, err error, value interface{}) {
 			persistClusterStatus()
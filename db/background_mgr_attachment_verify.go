@@ -0,0 +1,267 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	sgbucket "github.com/couchbase/sg-bucket"
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/google/uuid"
+)
+
+// =====================================================================
+// Attachment Integrity Verification Implementation of Background Manager Process
+// =====================================================================
+
+// attachmentQuarantinePrefix is the key prefix corrupt attachments are moved to when quarantined, so that they're no
+// longer served to clients but can still be inspected.
+const attachmentQuarantinePrefix = base.SyncDocPrefix + "attq:"
+
+// maxCorruptAttachmentsReported is the number of corrupt attachments listed in the verification status.  Any more
+// are only counted.
+const maxCorruptAttachmentsReported = 1000
+
+// CorruptAttachment is an attachment whose data doesn't match the digest it's stored under.
+type CorruptAttachment struct {
+	Key            string `json:"key"`
+	Collection     string `json:"collection"`
+	ExpectedDigest string `json:"expected_digest"`
+	ActualDigest   string `json:"actual_digest"`
+	Quarantined    bool   `json:"quarantined"`
+}
+
+type AttachmentVerifyManager struct {
+	VerifiedAttachments    base.AtomicInt
+	CorruptCount           base.AtomicInt
+	QuarantinedAttachments base.AtomicInt
+	VerifyID               string
+	quarantine             bool
+	corrupt                []CorruptAttachment
+	lock                   sync.Mutex
+}
+
+var _ BackgroundManagerProcessI = &AttachmentVerifyManager{}
+
+func NewAttachmentVerifyManager() *BackgroundManager {
+	return &BackgroundManager{
+		name:       "attachment_verify",
+		Process:    &AttachmentVerifyManager{},
+		terminator: base.NewSafeTerminator(),
+	}
+}
+
+func (a *AttachmentVerifyManager) Init(ctx context.Context, options map[string]interface{}, clusterStatus []byte) error {
+	verifyID, err := uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.VerifyID = verifyID.String()
+	a.quarantine, _ = options["quarantine"].(bool)
+	base.InfofCtx(ctx, base.KeyAll, "Attachment Verify: Starting new verification run with verify ID: %q, quarantine: %t", a.VerifyID, a.quarantine)
+	return nil
+}
+
+func (a *AttachmentVerifyManager) Run(ctx context.Context, options map[string]interface{}, persistClusterStatusCallback updateStatusCallbackFunc, terminator *base.SafeTerminator) error {
+	database := options["database"].(*Database)
+	verifyLoggingID := "Attachment Verify: " + a.VerifyID
+
+	// v1 attachments are stored in the default collection, v2 attachments alongside the documents referencing them.
+	// The DCP feed runs on the database's bucket, so collections stored in federated buckets are skipped.
+	dataStores := map[uint32]base.DataStore{base.DefaultCollectionID: database.Bucket.DefaultDataStore()}
+	collectionNames := map[uint32]string{base.DefaultCollectionID: base.DefaultScope + "." + base.DefaultCollection}
+	collectionIDs := []uint32{base.DefaultCollectionID}
+	for collectionID, collection := range database.CollectionByID {
+		if collectionID == base.DefaultCollectionID || collection.IsFederated() {
+			continue
+		}
+		dataStores[collectionID] = collection.dataStore
+		collectionNames[collectionID] = collection.ScopeName + "." + collection.Name
+		collectionIDs = append(collectionIDs, collectionID)
+	}
+
+	callback := func(event sgbucket.FeedEvent) bool {
+		key := string(event.Key)
+		if !strings.HasPrefix(key, base.AttPrefix) && !strings.HasPrefix(key, base.Att2Prefix) {
+			return true
+		}
+		if event.Opcode != sgbucket.FeedOpMutation {
+			return true
+		}
+
+		data := event.Value
+		if event.DataType&base.MemcachedDataTypeXattr != 0 {
+			var err error
+			data, _, _, err = parseXattrStreamData(base.AttachmentCompactionXattrName, "", event.Value)
+			if err != nil {
+				base.WarnfCtx(ctx, "[%s] Unable to parse attachment %s: %v", verifyLoggingID, base.UD(key), err)
+				return true
+			}
+		}
+
+		a.VerifiedAttachments.Add(1)
+		database.DbStats.Database().NumAttachmentsVerified.Add(1)
+		expectedDigest, actualDigest, ok := verifyAttachmentData(key, data)
+		if ok {
+			return true
+		}
+
+		base.WarnfCtx(ctx, "[%s] Attachment %s has digest %s, expected %s", verifyLoggingID, base.UD(key), actualDigest, expectedDigest)
+		a.CorruptCount.Add(1)
+		database.DbStats.Database().NumAttachmentsCorrupt.Add(1)
+		corrupt := CorruptAttachment{
+			Key:            key,
+			Collection:     collectionNames[event.CollectionID],
+			ExpectedDigest: expectedDigest,
+			ActualDigest:   actualDigest,
+		}
+		if a.quarantine {
+			if dataStore, ok := dataStores[event.CollectionID]; ok {
+				if err := quarantineAttachment(dataStore, key, data, event.Cas); err != nil {
+					base.WarnfCtx(ctx, "[%s] Unable to quarantine attachment %s: %v", verifyLoggingID, base.UD(key), err)
+				} else {
+					corrupt.Quarantined = true
+					a.QuarantinedAttachments.Add(1)
+					database.DbStats.Database().NumAttachmentsQuarantined.Add(1)
+				}
+			}
+		}
+		a.addCorrupt(corrupt)
+		return true
+	}
+
+	// The DCP feed can't be paused part way through, so defer starting it until the maintenance window is open
+	if !database.MaintenanceSchedule.WaitForWindow(ctx, verifyLoggingID, terminator) {
+		return nil
+	}
+
+	bucket, err := base.AsGocbV2Bucket(database.Bucket)
+	if err != nil {
+		return err
+	}
+
+	clientOptions := base.DCPClientOptions{
+		OneShot:           true,
+		MetadataStoreType: base.DCPMetadataStoreInMemory,
+		GroupID:           database.Options.GroupID,
+		CollectionIDs:     collectionIDs,
+	}
+	dcpFeedKey := GenerateAttachmentVerifyDCPStreamName(a.VerifyID)
+	dcpClient, err := base.NewDCPClient(ctx, dcpFeedKey, callback, clientOptions, bucket)
+	if err != nil {
+		base.WarnfCtx(ctx, "[%s] Failed to create attachment verify DCP client! %v", verifyLoggingID, err)
+		return err
+	}
+
+	base.InfofCtx(ctx, base.KeyAll, "[%s] Starting DCP feed %q for attachment verification", verifyLoggingID, dcpFeedKey)
+	doneChan, err := dcpClient.Start()
+	if err != nil {
+		base.WarnfCtx(ctx, "[%s] Failed to start attachment verify DCP feed! %v", verifyLoggingID, err)
+		_ = dcpClient.Close()
+		return err
+	}
+
+	select {
+	case <-doneChan:
+		base.InfofCtx(ctx, base.KeyAll, "[%s] Finished verifying attachments. %d/%d attachments corrupt", verifyLoggingID, a.CorruptCount.Value(), a.VerifiedAttachments.Value())
+		err = dcpClient.Close()
+	case <-terminator.Done():
+		base.DebugfCtx(ctx, base.KeyAll, "[%s] Terminator closed. Ending attachment verification.", verifyLoggingID)
+		err = dcpClient.Close()
+		if err != nil {
+			base.WarnfCtx(ctx, "[%s] Failed to close attachment verify DCP client! %v", verifyLoggingID, err)
+			return err
+		}
+		err = <-doneChan
+		base.InfofCtx(ctx, base.KeyAll, "[%s] Attachment verification was terminated. %d/%d attachments corrupt", verifyLoggingID, a.CorruptCount.Value(), a.VerifiedAttachments.Value())
+	}
+	return err
+}
+
+func (a *AttachmentVerifyManager) addCorrupt(corrupt CorruptAttachment) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if len(a.corrupt) < maxCorruptAttachmentsReported {
+		a.corrupt = append(a.corrupt, corrupt)
+	}
+}
+
+type AttachmentVerifyManagerResponse struct {
+	BackgroundManagerStatus
+	VerifyID               string              `json:"verify_id"`
+	Quarantine             bool                `json:"quarantine"`
+	AttachmentsVerified    int64               `json:"attachments_verified"`
+	AttachmentsCorrupt     int64               `json:"attachments_corrupt"`
+	AttachmentsQuarantined int64               `json:"attachments_quarantined"`
+	CorruptAttachments     []CorruptAttachment `json:"corrupt_attachments,omitempty"`
+}
+
+func (a *AttachmentVerifyManager) GetProcessStatus(backgroundManagerStatus BackgroundManagerStatus) ([]byte, []byte, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	retStatus := AttachmentVerifyManagerResponse{
+		BackgroundManagerStatus: backgroundManagerStatus,
+		VerifyID:                a.VerifyID,
+		Quarantine:              a.quarantine,
+		AttachmentsVerified:     a.VerifiedAttachments.Value(),
+		AttachmentsCorrupt:      a.CorruptCount.Value(),
+		AttachmentsQuarantined:  a.QuarantinedAttachments.Value(),
+		CorruptAttachments:      a.corrupt,
+	}
+
+	statusJSON, err := base.JSONMarshal(retStatus)
+	return statusJSON, nil, err
+}
+
+func (a *AttachmentVerifyManager) ResetStatus() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.VerifiedAttachments.Set(0)
+	a.CorruptCount.Set(0)
+	a.QuarantinedAttachments.Set(0)
+	a.corrupt = nil
+}
+
+// verifyAttachmentData recomputes the digest of an attachment's data, and returns whether it matches the digest the
+// attachment is stored under.  Attachments with a digest type that can't be recomputed are treated as matching.
+func verifyAttachmentData(key string, data []byte) (expectedDigest, actualDigest string, ok bool) {
+	expectedDigest = key[strings.LastIndex(key, ":")+1:]
+	if !strings.HasPrefix(expectedDigest, "sha1-") {
+		return expectedDigest, "", true
+	}
+	actualDigest = Sha1DigestKey(data)
+	return expectedDigest, actualDigest, actualDigest == expectedDigest
+}
+
+// quarantineAttachment moves a corrupt attachment's data under attachmentQuarantinePrefix.  The attachment is only
+// removed if it hasn't been rewritten since it was verified.
+func quarantineAttachment(dataStore base.DataStore, key string, data []byte, cas uint64) error {
+	if err := dataStore.SetRaw(attachmentQuarantineKey(key), 0, nil, data); err != nil {
+		return err
+	}
+	_, err := dataStore.Remove(key, cas)
+	return err
+}
+
+func attachmentQuarantineKey(key string) string {
+	return attachmentQuarantinePrefix + strings.TrimPrefix(key, base.SyncDocPrefix)
+}
+
+func GenerateAttachmentVerifyDCPStreamName(verifyID string) string {
+	return fmt.Sprintf(
+		"sg-%v:att_verify:%v",
+		base.ProductAPIVersion,
+		verifyID,
+	)
+}
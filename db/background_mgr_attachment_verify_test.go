@@ -0,0 +1,62 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"testing"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyAttachmentData(t *testing.T) {
+	data := []byte("hello world")
+	digest := Sha1DigestKey(data)
+
+	for _, key := range []string{MakeAttachmentKey(AttVersion1, "doc1", digest), MakeAttachmentKey(AttVersion2, "doc1", digest)} {
+		expected, actual, ok := verifyAttachmentData(key, data)
+		assert.True(t, ok, "expected %s to verify", key)
+		assert.Equal(t, digest, expected)
+		assert.Equal(t, digest, actual)
+
+		expected, actual, ok = verifyAttachmentData(key, []byte("hello wor"))
+		assert.False(t, ok, "expected %s to be corrupt", key)
+		assert.Equal(t, digest, expected)
+		assert.Equal(t, Sha1DigestKey([]byte("hello wor")), actual)
+	}
+
+	// Digests that can't be recomputed aren't reported as corrupt
+	_, _, ok := verifyAttachmentData(MakeAttachmentKey(AttVersion2, "doc1", "md5-abc"), data)
+	assert.True(t, ok)
+}
+
+func TestQuarantineAttachment(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	dataStore := GetSingleDatabaseCollection(t, db.DatabaseContext).dataStore
+
+	data := []byte("corrupt")
+	key := MakeAttachmentKey(AttVersion2, "doc1", Sha1DigestKey([]byte("original")))
+	require.NoError(t, dataStore.SetRaw(key, 0, nil, data))
+	_, cas, err := dataStore.GetRaw(key)
+	require.NoError(t, err)
+
+	// An attachment rewritten since it was verified isn't removed
+	assert.Error(t, quarantineAttachment(dataStore, key, data, cas+1))
+	_, _, err = dataStore.GetRaw(key)
+	require.NoError(t, err)
+
+	require.NoError(t, quarantineAttachment(dataStore, key, data, cas))
+	_, _, err = dataStore.GetRaw(key)
+	assert.True(t, base.IsDocNotFoundError(err))
+	quarantined, _, err := dataStore.GetRaw(attachmentQuarantineKey(key))
+	require.NoError(t, err)
+	assert.Equal(t, data, quarantined)
+}
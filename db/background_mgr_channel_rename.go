@@ -0,0 +1,354 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/couchbase/sync_gateway/auth"
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+)
+
+// =====================================================================
+// Channel Rename Implementation of Background Manager Process
+// =====================================================================
+
+// ChannelRenamePhase is the current phase of a channel rename.
+type ChannelRenamePhase string
+
+const (
+	ChannelRenamePhaseGrant  ChannelRenamePhase = "grant"  // Granting the new channel to principals granted the old channel
+	ChannelRenamePhaseDocs   ChannelRenamePhase = "docs"   // Moving documents from the old channel to the new channel
+	ChannelRenamePhaseRevoke ChannelRenamePhase = "revoke" // Revoking the old channel from principals
+)
+
+// channelRenameBatchSize is the number of documents in the old channel queried at a time.
+const channelRenameBatchSize = 1000
+
+// errChannelRenameStopped stops iterating over documents or principals when the rename is stopped.
+var errChannelRenameStopped = errors.New("channel rename stopped")
+
+// ChannelRenameManager renames a channel across the database.  Documents are moved to the new channel by rewriting
+// their "channels" property as a new revision, so the change reaches clients through the changes feed, and the
+// channel's history is kept.  The new channel is granted to principals explicitly granted the old channel before
+// documents are moved, and the old channel is revoked once they have been, so access isn't lost part way through.  If
+// any document isn't renamed, the run fails without revoking the old channel, so that it can be run again once the
+// document has been updated.
+//
+// Documents assigned to the old channel by the sync function other than through their "channels" property, and
+// grants made by the sync function, can't be renamed, and need the sync function to be updated.
+type ChannelRenameManager struct {
+	From              string
+	To                string
+	DocsProcessed     base.AtomicInt
+	DocsRenamed       base.AtomicInt
+	DocsSkipped       base.AtomicInt
+	DocsFailed        base.AtomicInt
+	PrincipalsUpdated base.AtomicInt
+	phase             ChannelRenamePhase
+	lock              sync.Mutex
+}
+
+var _ BackgroundManagerProcessI = &ChannelRenameManager{}
+
+func NewChannelRenameManager() *BackgroundManager {
+	return &BackgroundManager{
+		name:       "channel_rename",
+		Process:    &ChannelRenameManager{},
+		terminator: base.NewSafeTerminator(),
+	}
+}
+
+// ValidateChannelRename returns an error if a channel can't be renamed from one name to another.
+func ValidateChannelRename(from, to string) error {
+	if from == "" || to == "" {
+		return errors.New("both the channel to rename and its new name are required")
+	}
+	if from == to {
+		return errors.New("the channel's new name must be different from its current name")
+	}
+	for _, name := range []string{from, to} {
+		if name == channels.UserStarChannel || name == channels.DocumentStarChannel {
+			return fmt.Errorf("channel %q can't be renamed", name)
+		}
+		if !channels.IsValidChannel(name) {
+			return fmt.Errorf("invalid channel name %q", name)
+		}
+	}
+	return nil
+}
+
+func (r *ChannelRenameManager) Init(ctx context.Context, options map[string]interface{}, clusterStatus []byte) error {
+	from, _ := options["from"].(string)
+	to, _ := options["to"].(string)
+	if err := ValidateChannelRename(from, to); err != nil {
+		return base.HTTPErrorf(http.StatusBadRequest, "%v", err)
+	}
+	r.From, r.To = from, to
+	r.setPhase(ChannelRenamePhaseGrant)
+	base.InfofCtx(ctx, base.KeyAll, "Channel Rename: Starting rename of channel %s to %s", base.UD(from), base.UD(to))
+	return nil
+}
+
+func (r *ChannelRenameManager) Run(ctx context.Context, options map[string]interface{}, persistClusterStatusCallback updateStatusCallbackFunc, terminator *base.SafeTerminator) error {
+	database := options["database"].(*Database)
+
+	err := r.updatePrincipalGrants(ctx, database, false, terminator)
+	if err == nil {
+		r.setPhase(ChannelRenamePhaseDocs)
+		err = r.renameDocs(ctx, database, terminator)
+	}
+	if err == nil {
+		r.setPhase(ChannelRenamePhaseRevoke)
+		err = r.updatePrincipalGrants(ctx, database, true, terminator)
+	}
+	if errors.Is(err, errChannelRenameStopped) {
+		base.InfofCtx(ctx, base.KeyAll, "Channel Rename: Stopped in %s phase. %d/%d docs renamed, %d principals updated", r.getPhase(), r.DocsRenamed.Value(), r.DocsProcessed.Value(), r.PrincipalsUpdated.Value())
+		return nil
+	} else if err != nil {
+		return err
+	}
+	base.InfofCtx(ctx, base.KeyAll, "Channel Rename: Finished. %d/%d docs renamed, %d skipped, %d failed, %d principals updated", r.DocsRenamed.Value(), r.DocsProcessed.Value(), r.DocsSkipped.Value(), r.DocsFailed.Value(), r.PrincipalsUpdated.Value())
+	return nil
+}
+
+// updatePrincipalGrants grants the new channel to every user and role explicitly granted the old channel, or when
+// revoking, revokes the old channel.
+func (r *ChannelRenameManager) updatePrincipalGrants(ctx context.Context, database *Database, revoke bool, terminator *base.SafeTerminator) error {
+	users, roles, err := database.AllPrincipalIDs(ctx)
+	if err != nil {
+		return err
+	}
+	authenticator := database.Authenticator(ctx)
+	for _, isUser := range []bool{true, false} {
+		names := roles
+		if isUser {
+			names = users
+		}
+		for _, name := range names {
+			select {
+			case <-terminator.Done():
+				return errChannelRenameStopped
+			default:
+			}
+			var princ auth.Principal
+			if isUser {
+				princ, err = authenticator.GetUser(name)
+			} else {
+				princ, err = authenticator.GetRole(name)
+			}
+			if err != nil {
+				return err
+			}
+			if princ == nil || princ.IsDeleted() {
+				continue
+			}
+			updates := r.principalGrantUpdates(database, princ, revoke)
+			if updates == nil {
+				continue
+			}
+			if _, err := database.UpdatePrincipal(ctx, updates, isUser, true); err != nil {
+				return fmt.Errorf("unable to update channels of %s: %w", base.UD(name).Redact(), err)
+			}
+			if !revoke {
+				r.PrincipalsUpdated.Add(1)
+			}
+		}
+	}
+	return nil
+}
+
+// principalGrantUpdates returns the updates to the principal's explicit channels granting the new channel wherever
+// the old channel is granted, or when revoking, revoking the old channel.  Returns nil if there's nothing to update.
+func (r *ChannelRenameManager) principalGrantUpdates(database *Database, princ auth.Principal, revoke bool) *auth.PrincipalConfig {
+	var updates *auth.PrincipalConfig
+	for _, collection := range database.CollectionByID {
+		explicitChannels := princ.CollectionExplicitChannels(collection.ScopeName, collection.Name)
+		if !explicitChannels.Contains(r.From) {
+			continue
+		}
+		updatedChannels := explicitChannels.AsSet()
+		if revoke {
+			delete(updatedChannels, r.From)
+		} else if updatedChannels.Contains(r.To) {
+			continue
+		} else {
+			updatedChannels.Add(r.To)
+		}
+		if updates == nil {
+			updates = &auth.PrincipalConfig{Name: base.StringPtr(princ.Name())}
+		}
+		if collection.IsDefaultCollection() {
+			updates.ExplicitChannels = updatedChannels
+		} else {
+			updates.SetExplicitChannels(collection.ScopeName, collection.Name, updatedChannels.ToArray()...)
+		}
+	}
+	return updates
+}
+
+// renameDocs moves the documents in the old channel of each collection to the new channel.  Only documents in the
+// channel when the phase started are moved.  Returns an error if any document wasn't renamed.
+func (r *ChannelRenameManager) renameDocs(ctx context.Context, database *Database, terminator *base.SafeTerminator) error {
+	endSeq, err := database.LastSequence(ctx)
+	if err != nil {
+		return err
+	}
+	skippedCollections := 0
+	for _, collection := range database.CollectionByID {
+		// Documents are found by query, which isn't available for collections stored in federated buckets
+		if collection.IsFederated() {
+			base.WarnfCtx(ctx, "Channel Rename: Documents in collection %s can't be renamed as it's stored in a federated bucket", base.MD(collection.Name))
+			skippedCollections++
+			continue
+		}
+		collectionCtx := base.CollectionLogCtx(ctx, collection.Name)
+		collectionWithUser := &DatabaseCollectionWithUser{DatabaseCollection: collection}
+		startSeq := uint64(0)
+		for {
+			entries, err := collection.getChangesInChannelFromQuery(collectionCtx, r.From, startSeq, endSeq, channelRenameBatchSize, true)
+			if err != nil {
+				return err
+			}
+			for _, entry := range entries {
+				select {
+				case <-terminator.Done():
+					return errChannelRenameStopped
+				default:
+				}
+				startSeq = entry.Sequence + 1
+				if !entry.IsActive() {
+					continue
+				}
+				r.renameDoc(collectionCtx, collectionWithUser, entry.DocID)
+			}
+			if len(entries) < channelRenameBatchSize {
+				break
+			}
+		}
+	}
+	// Revoking the old channel would remove access to documents still in it
+	if failed, skipped := r.DocsFailed.Value(), r.DocsSkipped.Value(); failed > 0 || skipped > 0 || skippedCollections > 0 {
+		return fmt.Errorf("channel %s has not been revoked, as not every document was renamed: %d docs failed, %d docs skipped, %d collections skipped",
+			base.UD(r.From).Redact(), failed, skipped, skippedCollections)
+	}
+	return nil
+}
+
+// renameDoc moves the document to the new channel, by writing a new revision with the old channel replaced in its
+// "channels" property.
+func (r *ChannelRenameManager) renameDoc(ctx context.Context, collection *DatabaseCollectionWithUser, docID string) {
+	r.DocsProcessed.Add(1)
+	body, err := collection.Get1xRevBodyWithHistory(ctx, docID, "", 0, nil, nil, true)
+	if err != nil {
+		base.WarnfCtx(ctx, "Channel Rename: Unable to get doc %q: %v", base.UD(docID), err)
+		r.DocsFailed.Add(1)
+		return
+	}
+	renamed, ok := renameChannelProperty(body["channels"], r.From, r.To)
+	if !ok {
+		base.InfofCtx(ctx, base.KeyAll, "Channel Rename: Skipping doc %q, as it isn't assigned to channel %s by its channels property", base.UD(docID), base.UD(r.From))
+		r.DocsSkipped.Add(1)
+		return
+	}
+	body["channels"] = renamed
+	if _, _, err := collection.Put(ctx, docID, body); err != nil {
+		// Documents updated concurrently are left to be renamed when the rename is run again
+		base.WarnfCtx(ctx, "Channel Rename: Unable to update doc %q: %v", base.UD(docID), err)
+		r.DocsFailed.Add(1)
+		return
+	}
+	r.DocsRenamed.Add(1)
+}
+
+// renameChannelProperty returns the value of a document's "channels" property, either a channel name or an array of
+// channel names, with the old channel replaced by the new channel.  Returns false if the old channel isn't found.
+func renameChannelProperty(value interface{}, from, to string) (interface{}, bool) {
+	switch channelNames := value.(type) {
+	case string:
+		if channelNames != from {
+			return nil, false
+		}
+		return to, true
+	case []interface{}:
+		renamed := make([]interface{}, 0, len(channelNames))
+		found, hasTo := false, false
+		for _, name := range channelNames {
+			if name == from {
+				found = true
+				continue
+			}
+			hasTo = hasTo || name == to
+			renamed = append(renamed, name)
+		}
+		if !found {
+			return nil, false
+		}
+		if !hasTo {
+			renamed = append(renamed, to)
+		}
+		return renamed, true
+	}
+	return nil, false
+}
+
+func (r *ChannelRenameManager) setPhase(phase ChannelRenamePhase) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.phase = phase
+}
+
+func (r *ChannelRenameManager) getPhase() ChannelRenamePhase {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.phase
+}
+
+type ChannelRenameManagerResponse struct {
+	BackgroundManagerStatus
+	From              string             `json:"from"`
+	To                string             `json:"to"`
+	Phase             ChannelRenamePhase `json:"phase,omitempty"`
+	DocsProcessed     int64              `json:"docs_processed"`
+	DocsRenamed       int64              `json:"docs_renamed"`
+	DocsSkipped       int64              `json:"docs_skipped"`
+	DocsFailed        int64              `json:"docs_failed"`
+	PrincipalsUpdated int64              `json:"principals_updated"`
+}
+
+func (r *ChannelRenameManager) GetProcessStatus(backgroundManagerStatus BackgroundManagerStatus) ([]byte, []byte, error) {
+	retStatus := ChannelRenameManagerResponse{
+		BackgroundManagerStatus: backgroundManagerStatus,
+		From:                    r.From,
+		To:                      r.To,
+		Phase:                   r.getPhase(),
+		DocsProcessed:           r.DocsProcessed.Value(),
+		DocsRenamed:             r.DocsRenamed.Value(),
+		DocsSkipped:             r.DocsSkipped.Value(),
+		DocsFailed:              r.DocsFailed.Value(),
+		PrincipalsUpdated:       r.PrincipalsUpdated.Value(),
+	}
+
+	statusJSON, err := base.JSONMarshal(retStatus)
+	return statusJSON, nil, err
+}
+
+func (r *ChannelRenameManager) ResetStatus() {
+	r.DocsProcessed.Set(0)
+	r.DocsRenamed.Set(0)
+	r.DocsSkipped.Set(0)
+	r.DocsFailed.Set(0)
+	r.PrincipalsUpdated.Set(0)
+	r.setPhase("")
+}
@@ -0,0 +1,148 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenameChannelProperty(t *testing.T) {
+	renamed, ok := renameChannelProperty("A", "A", "B")
+	require.True(t, ok)
+	assert.Equal(t, "B", renamed)
+
+	renamed, ok = renameChannelProperty([]interface{}{"A", "C"}, "A", "B")
+	require.True(t, ok)
+	assert.Equal(t, []interface{}{"C", "B"}, renamed)
+
+	// The new channel isn't added twice
+	renamed, ok = renameChannelProperty([]interface{}{"A", "B"}, "A", "B")
+	require.True(t, ok)
+	assert.Equal(t, []interface{}{"B"}, renamed)
+
+	for _, value := range []interface{}{nil, "C", []interface{}{"C"}, map[string]interface{}{"A": true}} {
+		_, ok = renameChannelProperty(value, "A", "B")
+		assert.False(t, ok, "value %v shouldn't be renamed", value)
+	}
+}
+
+func TestValidateChannelRename(t *testing.T) {
+	require.NoError(t, ValidateChannelRename("A", "B"))
+	require.Error(t, ValidateChannelRename("", "B"))
+	require.Error(t, ValidateChannelRename("A", ""))
+	require.Error(t, ValidateChannelRename("A", "A"))
+	require.Error(t, ValidateChannelRename("*", "B"))
+	require.Error(t, ValidateChannelRename("A", "!"))
+}
+
+func TestChannelRename(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	db.Options.QueryPaginationLimit = 100
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+	collection.ChannelMapper = channels.NewChannelMapper(ctx, channels.DocChannelsSyncFunction, db.Options.JavascriptTimeout)
+
+	authenticator := db.Authenticator(ctx)
+	user, err := authenticator.NewUser("alice", "password", nil)
+	require.NoError(t, err)
+	user.SetCollectionExplicitChannels(collection.ScopeName, collection.Name, channels.AtSequence(base.SetOf("A", "C"), 1), 1)
+	require.NoError(t, authenticator.Save(user))
+	role, err := authenticator.NewRole("readers", nil)
+	require.NoError(t, err)
+	role.SetCollectionExplicitChannels(collection.ScopeName, collection.Name, channels.AtSequence(base.SetOf("C"), 1), 1)
+	require.NoError(t, authenticator.Save(role))
+
+	revID, _, err := collection.Put(ctx, "doc1", Body{"channels": []interface{}{"A", "C"}, "n": 1})
+	require.NoError(t, err)
+	_, _, err = collection.Put(ctx, "doc2", Body{"channels": "C"})
+	require.NoError(t, err)
+
+	manager := &ChannelRenameManager{From: "A", To: "B"}
+	terminator := base.NewSafeTerminator()
+	defer terminator.Close()
+	explicitChannels := func() base.Set {
+		user, err := authenticator.GetUser("alice")
+		require.NoError(t, err)
+		return user.CollectionExplicitChannels(collection.ScopeName, collection.Name).AsSet()
+	}
+
+	// The new channel is granted alongside the old channel while documents are moved
+	require.NoError(t, manager.updatePrincipalGrants(ctx, db, false, terminator))
+	assert.Equal(t, base.SetOf("A", "B", "C"), explicitChannels())
+	assert.Equal(t, int64(1), manager.PrincipalsUpdated.Value())
+
+	// Documents are moved by a new revision
+	manager.renameDoc(ctx, collection, "doc1")
+	manager.renameDoc(ctx, collection, "doc2")
+	doc, err := collection.GetDocument(ctx, "doc1", DocUnmarshalAll)
+	require.NoError(t, err)
+	assert.NotEqual(t, revID, doc.CurrentRev)
+	assert.Equal(t, []interface{}{"C", "B"}, doc.Body(ctx)["channels"])
+	assert.Equal(t, json.Number("1"), doc.Body(ctx)["n"])
+	require.Contains(t, doc.Channels, "B")
+	assert.Nil(t, doc.Channels["B"])
+	require.Contains(t, doc.Channels, "A")
+	assert.NotNil(t, doc.Channels["A"], "old channel should be recorded as removed")
+	assert.Equal(t, int64(2), manager.DocsProcessed.Value())
+	assert.Equal(t, int64(1), manager.DocsRenamed.Value())
+	assert.Equal(t, int64(1), manager.DocsSkipped.Value())
+
+	// Then the old channel is revoked
+	require.NoError(t, manager.updatePrincipalGrants(ctx, db, true, terminator))
+	assert.Equal(t, base.SetOf("B", "C"), explicitChannels())
+	readers, err := authenticator.GetRole("readers")
+	require.NoError(t, err)
+	assert.Equal(t, base.SetOf("C"), readers.CollectionExplicitChannels(collection.ScopeName, collection.Name).AsSet())
+}
+
+func TestChannelRenameIncomplete(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	db.Options.QueryPaginationLimit = 100
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+	collection.ChannelMapper = channels.NewChannelMapper(ctx, `function(doc, oldDoc) {
+		if (oldDoc && oldDoc.locked) {
+			throw({forbidden: "locked"});
+		}
+		channel(doc.channels);
+	}`, db.Options.JavascriptTimeout)
+
+	authenticator := db.Authenticator(ctx)
+	user, err := authenticator.NewUser("alice", "password", nil)
+	require.NoError(t, err)
+	user.SetCollectionExplicitChannels(collection.ScopeName, collection.Name, channels.AtSequence(base.SetOf("A"), 1), 1)
+	require.NoError(t, authenticator.Save(user))
+
+	_, _, err = collection.Put(ctx, "doc1", Body{"channels": "A"})
+	require.NoError(t, err)
+	_, _, err = collection.Put(ctx, "doc2", Body{"channels": "A", "locked": true})
+	require.NoError(t, err)
+
+	manager := &ChannelRenameManager{From: "A", To: "B"}
+	terminator := base.NewSafeTerminator()
+	defer terminator.Close()
+
+	// A document failing to be renamed fails the run, leaving the old channel granted
+	err = manager.Run(ctx, map[string]interface{}{"database": db}, nil, terminator)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 docs failed")
+	assert.Equal(t, ChannelRenamePhaseDocs, manager.getPhase())
+	assert.Equal(t, int64(2), manager.DocsProcessed.Value())
+	assert.Equal(t, int64(1), manager.DocsRenamed.Value())
+	assert.Equal(t, int64(1), manager.DocsFailed.Value())
+	user, err = authenticator.GetUser("alice")
+	require.NoError(t, err)
+	assert.Equal(t, base.SetOf("A", "B"), user.CollectionExplicitChannels(collection.ScopeName, collection.Name).AsSet())
+}
@@ -0,0 +1,208 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	sgbucket "github.com/couchbase/sg-bucket"
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/google/uuid"
+)
+
+// =====================================================================
+// Import Backfill Implementation of Background Manager Process
+// =====================================================================
+
+// ImportBackfillManager imports the documents already in the database's collections when it's run, such as those
+// written by SDK clients before import was enabled.  Documents already imported, or written by Sync Gateway, are
+// skipped.
+type ImportBackfillManager struct {
+	DocsProcessed base.AtomicInt
+	DocsImported  base.AtomicInt
+	DocsFailed    base.AtomicInt
+	BackfillID    string
+}
+
+var _ BackgroundManagerProcessI = &ImportBackfillManager{}
+
+func NewImportBackfillManager() *BackgroundManager {
+	return &BackgroundManager{
+		name:       "import_backfill",
+		Process:    &ImportBackfillManager{},
+		terminator: base.NewSafeTerminator(),
+	}
+}
+
+func (i *ImportBackfillManager) Init(ctx context.Context, options map[string]interface{}, clusterStatus []byte) error {
+	backfillID, err := uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+	i.BackfillID = backfillID.String()
+	base.InfofCtx(ctx, base.KeyAll, "Import Backfill: Starting new import backfill run with backfill ID: %q", i.BackfillID)
+	return nil
+}
+
+func (i *ImportBackfillManager) Run(ctx context.Context, options map[string]interface{}, persistClusterStatusCallback updateStatusCallbackFunc, terminator *base.SafeTerminator) error {
+	database := options["database"].(*Database)
+	backfillLoggingID := "Import Backfill: " + i.BackfillID
+
+	callback := func(event sgbucket.FeedEvent) bool {
+		docID := string(event.Key)
+		if strings.HasPrefix(docID, base.SyncDocPrefix) {
+			return true
+		}
+		// Only documents that currently exist are imported. Deletions of documents that were never imported have
+		// nothing to tombstone.
+		if event.Opcode != sgbucket.FeedOpMutation {
+			return true
+		}
+		collection, ok := database.CollectionByID[event.CollectionID]
+		if !ok {
+			return true
+		}
+		if i.importDocument(ctx, backfillLoggingID, &DatabaseCollectionWithUser{DatabaseCollection: collection}, docID, event) {
+			database.DbStats.SharedBucketImport().ImportBackfillCount.Add(1)
+		}
+		return true
+	}
+
+	// The DCP feed can't be paused part way through, so defer starting it until the maintenance window is open
+	if !database.MaintenanceSchedule.WaitForWindow(ctx, backfillLoggingID, terminator) {
+		return nil
+	}
+
+	bucket, err := base.AsGocbV2Bucket(database.Bucket)
+	if err != nil {
+		return err
+	}
+
+	// The DCP feed runs on the database's bucket, so collections stored in federated buckets are skipped
+	collectionIDs := make([]uint32, 0, len(database.CollectionByID))
+	for collectionID := range database.CollectionByID {
+		collectionIDs = append(collectionIDs, collectionID)
+	}
+	collectionIDs = database.filterFederatedCollectionIDs(ctx, collectionIDs)
+
+	clientOptions := base.DCPClientOptions{
+		OneShot:           true,
+		MetadataStoreType: base.DCPMetadataStoreInMemory,
+		GroupID:           database.Options.GroupID,
+		CollectionIDs:     collectionIDs,
+	}
+	dcpFeedKey := GenerateImportBackfillDCPStreamName(i.BackfillID)
+	dcpClient, err := base.NewDCPClient(ctx, dcpFeedKey, callback, clientOptions, bucket)
+	if err != nil {
+		base.WarnfCtx(ctx, "[%s] Failed to create import backfill DCP client! %v", backfillLoggingID, err)
+		return err
+	}
+
+	base.InfofCtx(ctx, base.KeyAll, "[%s] Starting DCP feed %q for import backfill", backfillLoggingID, dcpFeedKey)
+	doneChan, err := dcpClient.Start()
+	if err != nil {
+		base.WarnfCtx(ctx, "[%s] Failed to start import backfill DCP feed! %v", backfillLoggingID, err)
+		_ = dcpClient.Close()
+		return err
+	}
+
+	select {
+	case <-doneChan:
+		base.InfofCtx(ctx, base.KeyAll, "[%s] Finished import backfill. %d/%d docs imported, %d failed", backfillLoggingID, i.DocsImported.Value(), i.DocsProcessed.Value(), i.DocsFailed.Value())
+		err = dcpClient.Close()
+	case <-terminator.Done():
+		base.DebugfCtx(ctx, base.KeyAll, "[%s] Terminator closed. Ending import backfill.", backfillLoggingID)
+		err = dcpClient.Close()
+		if err != nil {
+			base.WarnfCtx(ctx, "[%s] Failed to close import backfill DCP client! %v", backfillLoggingID, err)
+			return err
+		}
+		err = <-doneChan
+		base.InfofCtx(ctx, base.KeyAll, "[%s] Import backfill was terminated. %d/%d docs imported, %d failed", backfillLoggingID, i.DocsImported.Value(), i.DocsProcessed.Value(), i.DocsFailed.Value())
+	}
+	return err
+}
+
+// importDocument imports the document if it hasn't been imported or written by Sync Gateway, returning true if it was
+// imported.  Documents updated since the feed event was sent are left to be imported by the import feed.
+func (i *ImportBackfillManager) importDocument(ctx context.Context, backfillLoggingID string, collection *DatabaseCollectionWithUser, docID string, event sgbucket.FeedEvent) bool {
+	i.DocsProcessed.Add(1)
+	var syncData *SyncData
+	var rawBody, rawXattr, rawUserXattr []byte
+	var err error
+	if event.DataType == base.MemcachedDataTypeRaw {
+		// Binary documents don't have sync metadata, and are only imported when enabled
+		if !collection.importBinaryDocs() {
+			return false
+		}
+		rawBody = event.Value
+	} else {
+		syncData, rawBody, rawXattr, rawUserXattr, err = UnmarshalDocumentSyncDataFromFeed(event.Value, event.DataType, collection.userXattrKey(), false)
+		if err != nil {
+			base.WarnfCtx(ctx, "[%s] Unable to read sync metadata of doc %q: %v", backfillLoggingID, base.UD(docID), err)
+			i.DocsFailed.Add(1)
+			return false
+		}
+	}
+	if syncData != nil {
+		if isSGWrite, _, _ := syncData.IsSGWrite(event.Cas, rawBody, rawUserXattr); isSGWrite {
+			return false
+		}
+	}
+
+	err = importDocRawWithRecovery(ctx, collection, docID, event, rawBody, rawXattr, rawUserXattr, false)
+	switch err {
+	case nil:
+		i.DocsImported.Add(1)
+		return true
+	case base.ErrImportCasFailure, base.ErrImportCancelledFilter:
+		return false
+	default:
+		base.DebugfCtx(ctx, base.KeyImport, "[%s] Did not import doc %q: %v", backfillLoggingID, base.UD(docID), err)
+		i.DocsFailed.Add(1)
+		return false
+	}
+}
+
+type ImportBackfillManagerResponse struct {
+	BackgroundManagerStatus
+	BackfillID    string `json:"backfill_id"`
+	DocsProcessed int64  `json:"docs_processed"`
+	DocsImported  int64  `json:"docs_imported"`
+	DocsFailed    int64  `json:"docs_failed"`
+}
+
+func (i *ImportBackfillManager) GetProcessStatus(backgroundManagerStatus BackgroundManagerStatus) ([]byte, []byte, error) {
+	retStatus := ImportBackfillManagerResponse{
+		BackgroundManagerStatus: backgroundManagerStatus,
+		BackfillID:              i.BackfillID,
+		DocsProcessed:           i.DocsProcessed.Value(),
+		DocsImported:            i.DocsImported.Value(),
+		DocsFailed:              i.DocsFailed.Value(),
+	}
+
+	statusJSON, err := base.JSONMarshal(retStatus)
+	return statusJSON, nil, err
+}
+
+func (i *ImportBackfillManager) ResetStatus() {
+	i.DocsProcessed.Set(0)
+	i.DocsImported.Set(0)
+	i.DocsFailed.Set(0)
+}
+
+func GenerateImportBackfillDCPStreamName(backfillID string) string {
+	return fmt.Sprintf(
+		"sg-%v:import_backfill:%v",
+		base.ProductAPIVersion,
+		backfillID,
+	)
+}
@@ -0,0 +1,54 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"testing"
+
+	sgbucket "github.com/couchbase/sg-bucket"
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportBackfillImportDocument(t *testing.T) {
+	if !base.TestUseXattrs() {
+		t.Skip("This test only works with XATTRS enabled")
+	}
+
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+
+	// Written by an SDK client before import was enabled
+	body := []byte(`{"foo": "bar"}`)
+	cas, err := collection.dataStore.WriteCas("sdkDoc", 0, 0, 0, body, sgbucket.Raw)
+	require.NoError(t, err)
+	event := sgbucket.FeedEvent{
+		Opcode:       sgbucket.FeedOpMutation,
+		Key:          []byte("sdkDoc"),
+		Value:        body,
+		DataType:     base.MemcachedDataTypeJSON,
+		Cas:          cas,
+		CollectionID: collection.GetCollectionID(),
+	}
+
+	manager := &ImportBackfillManager{}
+	assert.True(t, manager.importDocument(ctx, t.Name(), collection, "sdkDoc", event))
+	doc, err := collection.GetDocument(ctx, "sdkDoc", DocUnmarshalAll)
+	require.NoError(t, err)
+	assert.Equal(t, "bar", doc.Body(ctx)["foo"])
+
+	// The document has since been imported, so isn't imported again
+	assert.False(t, manager.importDocument(ctx, t.Name(), collection, "sdkDoc", event))
+
+	assert.Equal(t, int64(2), manager.DocsProcessed.Value())
+	assert.Equal(t, int64(1), manager.DocsImported.Value())
+	assert.Equal(t, int64(0), manager.DocsFailed.Value())
+}
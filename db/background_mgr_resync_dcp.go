@@ -141,6 +141,11 @@ func (r *ResyncManagerDCP) Run(ctx context.Context, options map[string]interface
 		return true
 	}
 
+	// The DCP feed can't be paused part way through, so defer starting it until the maintenance window is open
+	if !db.MaintenanceSchedule.WaitForWindow(ctx, resyncLoggingID, terminator) {
+		return nil
+	}
+
 	bucket, err := base.AsGocbV2Bucket(db.Bucket)
 	if err != nil {
 		return err
@@ -151,6 +156,8 @@ func (r *ResyncManagerDCP) Run(ctx context.Context, options map[string]interface
 	if err != nil {
 		return err
 	}
+	// The resync DCP feed runs on the database's bucket, so collections stored in federated buckets are skipped
+	collectionIDs = db.filterFederatedCollectionIDs(ctx, collectionIDs)
 	if hasAllCollections {
 		base.InfofCtx(ctx, base.KeyAll, "[%s] running resync against all collections", resyncLoggingID)
 	} else {
@@ -0,0 +1,130 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// =====================================================================
+// Sync Metadata Compaction Implementation of Background Manager Process
+// =====================================================================
+
+// errSyncMetadataCompactionStopped stops iterating over a collection's documents when the compaction is stopped.
+var errSyncMetadataCompactionStopped = errors.New("sync metadata compaction stopped")
+
+// MetadataCompactionManager relocates the cold portions of oversized sync metadata, such as old channel history and
+// the channels of non-leaf revisions, to side documents.  Documents with large sync metadata are slow to read and
+// can reach the maximum size of an xattr.
+type MetadataCompactionManager struct {
+	DocsProcessed  int64
+	DocsCompacted  int64
+	BytesRelocated int64
+	threshold      int64
+}
+
+var _ BackgroundManagerProcessI = &MetadataCompactionManager{}
+
+func NewMetadataCompactionManager() *BackgroundManager {
+	return &BackgroundManager{
+		name:       "sync_metadata_compaction",
+		Process:    &MetadataCompactionManager{},
+		terminator: base.NewSafeTerminator(),
+	}
+}
+
+func (m *MetadataCompactionManager) Init(ctx context.Context, options map[string]interface{}, clusterStatus []byte) error {
+	threshold, _ := options["threshold"].(int)
+	if threshold <= 0 {
+		threshold = DefaultSyncMetadataCompactionThreshold
+	}
+	atomic.StoreInt64(&m.threshold, int64(threshold))
+	base.InfofCtx(ctx, base.KeyAll, "Sync Metadata Compaction: Starting, relocating sync metadata of documents over %d bytes", threshold)
+	return nil
+}
+
+func (m *MetadataCompactionManager) Run(ctx context.Context, options map[string]interface{}, persistClusterStatusCallback updateStatusCallbackFunc, terminator *base.SafeTerminator) error {
+	database := options["database"].(*Database)
+	threshold := int(atomic.LoadInt64(&m.threshold))
+
+	// Documents are found by query, which isn't available for collections stored in federated buckets
+	for _, collection := range database.CollectionByID {
+		if collection.IsFederated() {
+			continue
+		}
+		if !database.MaintenanceSchedule.WaitForWindow(ctx, "sync metadata compaction", terminator) {
+			return nil
+		}
+		collectionCtx := base.CollectionLogCtx(ctx, collection.Name)
+		results, err := collection.QueryAllDocs(collectionCtx, "", "")
+		if err != nil {
+			return err
+		}
+		err = collection.processForEachDocIDResults(collectionCtx, func(id IDRevAndSequence, _ []string) (bool, error) {
+			select {
+			case <-terminator.Done():
+				return false, errSyncMetadataCompactionStopped
+			default:
+			}
+			atomic.AddInt64(&m.DocsProcessed, 1)
+			relocatedBytes, err := collection.compactSyncMetadata(collectionCtx, id.DocID, threshold)
+			if err != nil {
+				base.WarnfCtx(collectionCtx, "Sync Metadata Compaction: Unable to compact sync metadata of doc %q: %v", base.UD(id.DocID), err)
+				return true, nil
+			}
+			if relocatedBytes > 0 {
+				atomic.AddInt64(&m.DocsCompacted, 1)
+				atomic.AddInt64(&m.BytesRelocated, int64(relocatedBytes))
+			}
+			return true, nil
+		}, 0, results)
+		if closeErr := results.Close(); err == nil {
+			err = closeErr
+		}
+		if errors.Is(err, errSyncMetadataCompactionStopped) {
+			base.InfofCtx(ctx, base.KeyAll, "Sync Metadata Compaction: Stopped. %d/%d docs compacted", atomic.LoadInt64(&m.DocsCompacted), atomic.LoadInt64(&m.DocsProcessed))
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	base.InfofCtx(ctx, base.KeyAll, "Sync Metadata Compaction: Finished. %d/%d docs compacted, %d bytes relocated", atomic.LoadInt64(&m.DocsCompacted), atomic.LoadInt64(&m.DocsProcessed), atomic.LoadInt64(&m.BytesRelocated))
+	return nil
+}
+
+type MetadataCompactionManagerResponse struct {
+	BackgroundManagerStatus
+	Threshold      int64 `json:"threshold_bytes"`
+	DocsProcessed  int64 `json:"docs_processed"`
+	DocsCompacted  int64 `json:"docs_compacted"`
+	BytesRelocated int64 `json:"bytes_relocated"`
+}
+
+func (m *MetadataCompactionManager) GetProcessStatus(backgroundManagerStatus BackgroundManagerStatus) ([]byte, []byte, error) {
+	retStatus := MetadataCompactionManagerResponse{
+		BackgroundManagerStatus: backgroundManagerStatus,
+		Threshold:               atomic.LoadInt64(&m.threshold),
+		DocsProcessed:           atomic.LoadInt64(&m.DocsProcessed),
+		DocsCompacted:           atomic.LoadInt64(&m.DocsCompacted),
+		BytesRelocated:          atomic.LoadInt64(&m.BytesRelocated),
+	}
+
+	statusJSON, err := base.JSONMarshal(retStatus)
+	return statusJSON, nil, err
+}
+
+func (m *MetadataCompactionManager) ResetStatus() {
+	atomic.StoreInt64(&m.DocsProcessed, 0)
+	atomic.StoreInt64(&m.DocsCompacted, 0)
+	atomic.StoreInt64(&m.BytesRelocated, 0)
+}
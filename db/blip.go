@@ -42,12 +42,17 @@ var (
 	badFilenames = regexp.MustCompile(`(?i)\.(zip|t?gz|rar|7z|jpe?g|png|gif|svgz|mp3|m4a|ogg|wav|aiff|mp4|mov|avi|theora)$`)
 )
 
-// NewSGBlipContext returns a go-blip context with the given ID, initialized for use in Sync Gateway.
-func NewSGBlipContext(ctx context.Context, id string) (bc *blip.Context, err error) {
+// SupportedBlipProtocols returns the BLIP AppProtocolIds supported by Sync Gateway, in order of preference.
+func SupportedBlipProtocols() []string {
 	// V3 is first here as it is the preferred communication method
 	// In the host case this means SGW can accept both V3 and V2 clients
 	// In the client case this means we prefer V3 but can fallback to V2
-	return NewSGBlipContextWithProtocols(ctx, id, BlipCBMobileReplicationV3, BlipCBMobileReplicationV2)
+	return []string{BlipCBMobileReplicationV3, BlipCBMobileReplicationV2}
+}
+
+// NewSGBlipContext returns a go-blip context with the given ID, initialized for use in Sync Gateway.
+func NewSGBlipContext(ctx context.Context, id string) (bc *blip.Context, err error) {
+	return NewSGBlipContextWithProtocols(ctx, id, SupportedBlipProtocols()...)
 }
 
 func NewSGBlipContextWithProtocols(ctx context.Context, id string, protocol ...string) (bc *blip.Context, err error) {
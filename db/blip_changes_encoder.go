@@ -0,0 +1,104 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// changesRow is a row of a changes message sent to the client, encoded as [seq, docID, revID, deleted].  deleted is
+// omitted when the revision isn't a deletion, removal or revocation.
+type changesRow struct {
+	seq          SequenceID
+	docID        string
+	revID        string
+	deletedFlags changesDeletedFlag
+	boolDeleted  bool // Whether deleted is encoded as a boolean, for clients using a protocol earlier than V3
+}
+
+// changesBodyPool holds the buffers changes message bodies are encoded into.
+var changesBodyPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// encodeChangesRows returns the JSON body of a changes message for rows.  Rows are written directly into a pooled
+// buffer rather than marshalled by reflection, which avoids allocating for each row.  A nil rows (sent once the
+// client is caught up) is encoded as null, as by json.Marshal.
+func encodeChangesRows(rows []changesRow) []byte {
+	if rows == nil {
+		return []byte("null")
+	}
+
+	buf := changesBodyPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer changesBodyPool.Put(buf)
+
+	buf.WriteByte('[')
+	for i := range rows {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		rows[i].writeJSON(buf)
+	}
+	buf.WriteByte(']')
+
+	// The message retains its body until sent, so the pooled buffer can't be handed over
+	body := make([]byte, buf.Len())
+	copy(body, buf.Bytes())
+	return body
+}
+
+func (r *changesRow) writeJSON(buf *bytes.Buffer) {
+	var scratch [20]byte
+	buf.WriteByte('[')
+	if r.seq.TriggeredBy > 0 || r.seq.LowSeq > 0 {
+		writeJSONString(buf, r.seq.String())
+	} else {
+		buf.Write(strconv.AppendUint(scratch[:0], r.seq.Seq, 10))
+	}
+	buf.WriteByte(',')
+	writeJSONString(buf, r.docID)
+	buf.WriteByte(',')
+	writeJSONString(buf, r.revID)
+	if r.deletedFlags != 0 {
+		buf.WriteByte(',')
+		if r.boolDeleted {
+			buf.WriteString("true")
+		} else {
+			buf.Write(strconv.AppendUint(scratch[:0], uint64(r.deletedFlags), 10))
+		}
+	}
+	buf.WriteByte(']')
+}
+
+// writeJSONString writes s as a JSON string.  Strings of printable ASCII that don't need escaping are written
+// directly, anything else is marshalled.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c == '"' || c == '\\' || c >= utf8.RuneSelf {
+			encoded, err := base.JSONMarshal(s)
+			if err != nil {
+				// Marshalling a string can't fail, but fall back to an empty string rather than writing invalid JSON
+				encoded = []byte(`""`)
+			}
+			buf.Write(encoded)
+			return
+		}
+	}
+	buf.WriteByte('"')
+	buf.WriteString(s)
+	buf.WriteByte('"')
+}
@@ -0,0 +1,90 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// interfaceChangesRow returns row as the []interface{} previously marshalled for each changes row.
+func interfaceChangesRow(row changesRow) []interface{} {
+	if row.deletedFlags == 0 {
+		return []interface{}{row.seq, row.docID, row.revID}
+	}
+	if row.boolDeleted {
+		return []interface{}{row.seq, row.docID, row.revID, true}
+	}
+	return []interface{}{row.seq, row.docID, row.revID, row.deletedFlags}
+}
+
+func TestEncodeChangesRows(t *testing.T) {
+	rows := []changesRow{
+		{seq: SequenceID{Seq: 1}, docID: "doc1", revID: "1-abc"},
+		{seq: SequenceID{Seq: 2}, docID: "doc2", revID: "2-def", deletedFlags: changesDeletedFlagDeleted},
+		{seq: SequenceID{Seq: 3}, docID: "doc3", revID: "2-def", deletedFlags: changesDeletedFlagDeleted, boolDeleted: true},
+		{seq: SequenceID{Seq: 4}, docID: "doc4", revID: "1-abc", deletedFlags: changesDeletedFlagRevoked | changesDeletedFlagRemoved},
+		{seq: SequenceID{Seq: 10, TriggeredBy: 5}, docID: "doc5", revID: "1-abc"},
+		{seq: SequenceID{Seq: 12, LowSeq: 8}, docID: "doc6", revID: "1-abc"},
+		{seq: SequenceID{Seq: 13}, docID: "quote\"back\\slash\ttab", revID: "1-abc"},
+		{seq: SequenceID{Seq: 14}, docID: "unicode-文档-<&>", revID: "1-abc"},
+	}
+
+	expectedRows := make([][]interface{}, 0, len(rows))
+	for _, row := range rows {
+		expectedRows = append(expectedRows, interfaceChangesRow(row))
+	}
+	expected, err := json.Marshal(expectedRows)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(encodeChangesRows(rows)))
+
+	assert.Equal(t, "[]", string(encodeChangesRows([]changesRow{})))
+	assert.Equal(t, "null", string(encodeChangesRows(nil)))
+
+	// Each body is independent of the pooled buffer it was encoded into
+	first := encodeChangesRows(rows[:1])
+	_ = encodeChangesRows(rows[1:])
+	assert.Equal(t, `[[1,"doc1","1-abc"]]`, string(first))
+}
+
+func BenchmarkSendBatchOfChangesEncoding(b *testing.B) {
+	for _, batchSize := range []int{1, 200} {
+		rows := make([]changesRow, 0, batchSize)
+		for i := 0; i < batchSize; i++ {
+			row := changesRow{seq: SequenceID{Seq: uint64(i + 1)}, docID: fmt.Sprintf("doc%d", i), revID: "1-abcdef0123456789"}
+			if i%10 == 0 {
+				row.deletedFlags = changesDeletedFlagDeleted
+			}
+			rows = append(rows, row)
+		}
+
+		b.Run(fmt.Sprintf("JSONMarshal-%d", batchSize), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				interfaceRows := make([][]interface{}, 0, len(rows))
+				for _, row := range rows {
+					interfaceRows = append(interfaceRows, interfaceChangesRow(row))
+				}
+				if _, err := json.Marshal(interfaceRows); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("Encoder-%d", batchSize), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = encodeChangesRows(rows)
+			}
+		})
+	}
+}
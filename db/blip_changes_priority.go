@@ -0,0 +1,211 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"sort"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// defaultChannelPriority is the priority of channels not listed in a subChanges request's priorities.
+const defaultChannelPriority = 1
+
+// priorityGroup is the channels sharing a priority during a prioritized catch-up, and how far their changes have
+// been read.
+type priorityGroup struct {
+	channels base.Set
+	priority int
+	since    SequenceID
+	caughtUp bool // Whether the last read returned fewer changes than the batch size
+}
+
+// prioritizedCatchUp delivers the changes a subChanges request needs to catch up on, interleaving batches read from
+// each priority group so that each round reads up to `priority` batches from a group.  Changes in higher priority
+// channels are delivered ahead of the strict sequence order used by the changes feed.
+//
+// Since changes are sent out of sequence order, each is sent with a LowSeq no later than the earliest sequence not
+// yet read by every group, so a client checkpointing during catch-up resumes from a sequence with no gaps before it.
+type prioritizedCatchUp struct {
+	collection *DatabaseCollectionWithUser
+	channels   base.Set
+	options    ChangesOptions
+	batchSize  int
+	groups     []*priorityGroup        // Highest priority first
+	sent       map[SequenceID]struct{} // Sequences sent later than the low watermark, to avoid sending them twice
+	lowSeq     uint64                  // Sequences up to and including lowSeq have been read by every group
+}
+
+// newPrioritizedCatchUp groups the requested channels by priority.  Returns nil if all channels have the same
+// priority, in which case the changes feed's sequence order is used.
+func newPrioritizedCatchUp(collection *DatabaseCollectionWithUser, channelSet base.Set, priorities map[string]int, options ChangesOptions, batchSize int) *prioritizedCatchUp {
+	groupsByPriority := make(map[int]*priorityGroup)
+	for channel := range channelSet {
+		priority, ok := priorities[channel]
+		if !ok {
+			priority = defaultChannelPriority
+		}
+		group := groupsByPriority[priority]
+		if group == nil {
+			group = &priorityGroup{channels: base.Set{}, priority: priority, since: options.Since}
+			groupsByPriority[priority] = group
+		}
+		group.channels.Add(channel)
+	}
+	if len(groupsByPriority) < 2 {
+		return nil
+	}
+
+	catchUp := &prioritizedCatchUp{
+		collection: collection,
+		channels:   channelSet,
+		options:    options,
+		batchSize:  batchSize,
+		sent:       make(map[SequenceID]struct{}),
+		lowSeq:     options.Since.SafeSequence(),
+	}
+	for _, group := range groupsByPriority {
+		catchUp.groups = append(catchUp.groups, group)
+	}
+	sort.Slice(catchUp.groups, func(i, j int) bool {
+		return catchUp.groups[i].priority > catchUp.groups[j].priority
+	})
+	return catchUp
+}
+
+// run sends changes until every group has caught up in the same round, and returns the sequence the changes feed
+// should continue from.  Changes sent later than that sequence are skipped by alreadySent.
+func (c *prioritizedCatchUp) run(ctx context.Context, send func(*ChangeEntry) error) (resumeSince SequenceID, err error) {
+	// A LowSeq of zero can't be sent to the client, so the earliest change is sent in sequence order to set the low
+	// watermark before any are sent out of order
+	if c.lowSeq == 0 {
+		first, err := c.readChanges(ctx, c.channels, c.options.Since, 1)
+		if err != nil || len(first) == 0 {
+			return c.options.Since, err
+		}
+		if err := send(first[0]); err != nil {
+			return SequenceID{}, err
+		}
+		for _, group := range c.groups {
+			group.since = first[0].Seq
+		}
+		c.lowSeq = first[0].Seq.SafeSequence()
+	}
+
+	for {
+		allCaughtUp := true
+		for _, group := range c.groups {
+			// Groups that have caught up are still read once a round, to pick up changes made since
+			batches := group.priority
+			if group.caughtUp {
+				batches = 1
+			}
+			for i := 0; i < batches; i++ {
+				if err := c.sendBatch(ctx, group, send); err != nil {
+					return SequenceID{}, err
+				}
+				if group.caughtUp {
+					break
+				}
+			}
+			allCaughtUp = allCaughtUp && group.caughtUp
+		}
+		if c.options.ChangesCtx != nil && c.options.ChangesCtx.Err() != nil {
+			return SequenceID{}, c.options.ChangesCtx.Err()
+		}
+		if allCaughtUp {
+			base.DebugfCtx(ctx, base.KeySync, "Prioritized catch-up complete, continuing changes from #%d", c.lowSeq)
+			return SequenceID{Seq: c.lowSeq}, nil
+		}
+	}
+}
+
+// readChanges returns up to limit changes in channels since the given sequence, without waiting for new changes.
+func (c *prioritizedCatchUp) readChanges(ctx context.Context, channels base.Set, since SequenceID, limit int) ([]*ChangeEntry, error) {
+	options := c.options
+	options.Since = since
+	options.Limit = limit
+	options.Continuous = false
+	options.Wait = false
+	feed, err := c.collection.MultiChangesFeed(ctx, channels, options)
+	if err != nil || feed == nil {
+		return nil, err
+	}
+	var changes []*ChangeEntry
+	for entry := range feed {
+		if entry.Err != nil {
+			err = entry.Err
+		}
+		changes = append(changes, entry)
+	}
+	return changes, err
+}
+
+// sendBatch reads and sends the next batch of changes for a group.
+func (c *prioritizedCatchUp) sendBatch(ctx context.Context, group *priorityGroup, send func(*ChangeEntry) error) error {
+	changes, err := c.readChanges(ctx, group.channels, group.since, c.batchSize)
+	if err != nil {
+		return err
+	}
+	group.caughtUp = len(changes) < c.batchSize
+	if len(changes) > 0 {
+		// Taken before the LowSeq of the changes is set for sending, which would read them again
+		group.since = changes[len(changes)-1].Seq
+	}
+	for _, change := range changes {
+		// A document in channels of more than one group is read by each of them
+		key := SequenceID{Seq: change.Seq.Seq, TriggeredBy: change.Seq.TriggeredBy}
+		if _, ok := c.sent[key]; ok {
+			continue
+		}
+		c.sent[key] = struct{}{}
+		if change.Seq.LowSeq == 0 || c.lowSeq < change.Seq.LowSeq {
+			change.Seq.LowSeq = c.lowSeq
+		}
+		if err := send(change); err != nil {
+			return err
+		}
+	}
+	c.updateLowSeq()
+	return nil
+}
+
+// updateLowSeq advances the low watermark to the earliest sequence read by every group, and forgets the sent
+// sequences up to it, which can no longer be read again.
+func (c *prioritizedCatchUp) updateLowSeq() {
+	lowSeq := uint64(0)
+	for i, group := range c.groups {
+		if seq := group.since.SafeSequence(); i == 0 || seq < lowSeq {
+			lowSeq = seq
+		}
+	}
+	if lowSeq <= c.lowSeq {
+		return
+	}
+	c.lowSeq = lowSeq
+	for key := range c.sent {
+		if key.Seq <= lowSeq {
+			delete(c.sent, key)
+		}
+	}
+}
+
+// alreadySent returns true if a change read by the changes feed after the catch-up was sent during the catch-up.
+func (c *prioritizedCatchUp) alreadySent(change *ChangeEntry) bool {
+	if c == nil {
+		return false
+	}
+	key := SequenceID{Seq: change.Seq.Seq, TriggeredBy: change.Seq.TriggeredBy}
+	if _, ok := c.sent[key]; !ok {
+		return false
+	}
+	delete(c.sent, key)
+	return true
+}
@@ -0,0 +1,73 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"testing"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrioritizedCatchUp(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+	collection.ChannelMapper = channels.NewChannelMapper(ctx, channels.DocChannelsSyncFunction, db.Options.JavascriptTimeout)
+
+	docChannels := []struct {
+		docID    string
+		channels []string
+	}{
+		{"a1", []string{"A"}}, {"a2", []string{"A"}}, {"a3", []string{"A"}}, {"a4", []string{"A"}},
+		{"b1", []string{"B"}}, {"b2", []string{"B"}}, {"b3", []string{"B"}}, {"b4", []string{"B"}},
+		{"ab", []string{"A", "B"}},
+	}
+	for _, doc := range docChannels {
+		_, _, err := collection.Put(ctx, doc.docID, Body{"channels": doc.channels})
+		require.NoError(t, err)
+	}
+	require.NoError(t, collection.WaitForPendingChanges(ctx))
+
+	// Channels with the same priority use the changes feed's order
+	assert.Nil(t, newPrioritizedCatchUp(collection, base.SetOf("A", "B"), nil, getChangesOptionsWithZeroSeq(t), 2))
+	assert.Nil(t, newPrioritizedCatchUp(collection, base.SetOf("A", "B"), map[string]int{"A": 3, "B": 3}, getChangesOptionsWithZeroSeq(t), 2))
+
+	catchUp := newPrioritizedCatchUp(collection, base.SetOf("A", "B"), map[string]int{"B": 2}, getChangesOptionsWithZeroSeq(t), 2)
+	require.NotNil(t, catchUp)
+
+	var sent []*ChangeEntry
+	resumeSince, err := catchUp.run(ctx, func(change *ChangeEntry) error {
+		sent = append(sent, change)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// The earliest change sets the low watermark, then each round reads two batches of B for each batch of A.  ab is
+	// read by both groups in the last round, but only sent once, by B.
+	var docIDs []string
+	for _, change := range sent {
+		docIDs = append(docIDs, change.ID)
+	}
+	assert.Equal(t, []string{"a1", "b1", "b2", "b3", "b4", "a2", "a3", "ab", "a4"}, docIDs)
+
+	// Changes sent ahead of the low watermark checkpoint at it, so no changes are missed on resume
+	for _, change := range sent[1:5] {
+		assert.Equal(t, sent[0].Seq.Seq, change.Seq.LowSeq, "unexpected LowSeq for %s", change.ID)
+	}
+	assert.Equal(t, sent[6].Seq.Seq, sent[7].Seq.LowSeq)
+	assert.Equal(t, SequenceID{Seq: sent[7].Seq.Seq}, resumeSince)
+
+	// Every change sent is at or before the resume sequence, so none need to be skipped by the changes feed
+	for _, change := range sent {
+		assert.False(t, catchUp.alreadySent(change))
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"sync"
+	"time"
+
+	"github.com/couchbase/go-blip"
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// maxClientAppLength is the maximum length of the client app metadata recorded for a connection.
+const maxClientAppLength = 256
+
+// BlipClientCapabilities describes the protocol features negotiated by a replication connection.
+type BlipClientCapabilities struct {
+	ContextID        string    `json:"context_id"`                 // BLIP context ID, matching the connection's log context
+	User             string    `json:"user,omitempty"`             // Name of the authenticated user, empty for guest
+	ClientType       string    `json:"client_type"`                // cbl2 or sgr2
+	ProtocolVersion  string    `json:"protocol_version,omitempty"` // Negotiated subprotocol, e.g. CBMobile_3. Empty until the first request is handled.
+	Deltas           bool      `json:"deltas"`                     // Whether delta sync has been enabled for the connection
+	CollectionsAware bool      `json:"collections_aware"`          // Whether the client has called getCollections
+	ClientApp        string    `json:"client_app,omitempty"`       // Client app metadata sent by the client in the clientApp property
+	ConnectedAt      time.Time `json:"connected_at"`
+}
+
+// blipClientCapabilities tracks the capabilities negotiated by a connection, and updates the database's stats as they
+// are negotiated.  Stats are only updated once TrackClientCapabilities has been called.
+type blipClientCapabilities struct {
+	lock             sync.Mutex
+	stats            *base.DatabaseStats // nil if the connection's capabilities aren't counted in stats
+	connectedAt      time.Time
+	protocolVersion  string
+	clientApp        string
+	deltas           bool
+	collectionsAware bool
+	closed           bool
+}
+
+// TrackClientCapabilities records the connection's negotiated capabilities in the given database stats until the
+// connection is closed.  Used for connections from clients, not for connections made by active replicators.
+func (bsc *BlipSyncContext) TrackClientCapabilities(stats *base.DatabaseStats) {
+	bsc.capabilities.lock.Lock()
+	defer bsc.capabilities.lock.Unlock()
+	bsc.capabilities.stats = stats
+}
+
+// ClientCapabilities returns the capabilities negotiated by the connection so far.
+func (bsc *BlipSyncContext) ClientCapabilities() BlipClientCapabilities {
+	bsc.capabilities.lock.Lock()
+	defer bsc.capabilities.lock.Unlock()
+	return BlipClientCapabilities{
+		ContextID:        bsc.blipContext.ID,
+		User:             bsc.userName,
+		ClientType:       string(bsc.clientType),
+		ProtocolVersion:  bsc.capabilities.protocolVersion,
+		Deltas:           bsc.capabilities.deltas,
+		CollectionsAware: bsc.capabilities.collectionsAware,
+		ClientApp:        bsc.capabilities.clientApp,
+		ConnectedAt:      bsc.capabilities.connectedAt,
+	}
+}
+
+// DatabaseContext returns the database the connection is replicating with.
+func (bsc *BlipSyncContext) DatabaseContext() *DatabaseContext {
+	return bsc.blipContextDb.DatabaseContext
+}
+
+// recordRequestCapabilities records the negotiated protocol version when the first request is handled, and the
+// client app metadata from the first request that sets it.
+func (bsc *BlipSyncContext) recordRequestCapabilities(rq *blip.Message) {
+	clientApp := rq.Properties[BlipClientApp]
+	bsc.capabilities.lock.Lock()
+	defer bsc.capabilities.lock.Unlock()
+	if bsc.capabilities.closed {
+		return
+	}
+	if bsc.capabilities.protocolVersion == "" {
+		bsc.capabilities.protocolVersion = bsc.blipContext.ActiveSubprotocol()
+		bsc.capabilities._updateProtocolStat(1)
+		base.InfofCtx(bsc.loggingCtx, base.KeySync, "Negotiated replication protocol %s with %s client", bsc.capabilities.protocolVersion, bsc.clientType)
+	}
+	if clientApp != "" && bsc.capabilities.clientApp == "" {
+		if len(clientApp) > maxClientAppLength {
+			clientApp = clientApp[:maxClientAppLength]
+		}
+		bsc.capabilities.clientApp = clientApp
+		base.InfofCtx(bsc.loggingCtx, base.KeySync, "Client identified itself as %q", base.UD(clientApp))
+	}
+}
+
+// recordDeltasEnabled records that delta sync has been negotiated for the connection.
+func (bsc *BlipSyncContext) recordDeltasEnabled() {
+	bsc.capabilities.lock.Lock()
+	defer bsc.capabilities.lock.Unlock()
+	if bsc.capabilities.closed || bsc.capabilities.deltas {
+		return
+	}
+	bsc.capabilities.deltas = true
+	if bsc.capabilities.stats != nil {
+		bsc.capabilities.stats.NumReplicationsActiveDeltas.Add(1)
+	}
+}
+
+// recordCollectionsAware records that the client has negotiated collections using getCollections.
+func (bsc *BlipSyncContext) recordCollectionsAware() {
+	bsc.capabilities.lock.Lock()
+	defer bsc.capabilities.lock.Unlock()
+	if bsc.capabilities.closed || bsc.capabilities.collectionsAware {
+		return
+	}
+	bsc.capabilities.collectionsAware = true
+	base.InfofCtx(bsc.loggingCtx, base.KeySync, "Client negotiated collections support")
+	if bsc.capabilities.stats != nil {
+		bsc.capabilities.stats.NumReplicationsActiveCollectionsAware.Add(1)
+	}
+}
+
+// releaseCapabilities removes the connection's capabilities from the stats when the connection is closed.
+func (bsc *BlipSyncContext) releaseCapabilities() {
+	bsc.capabilities.lock.Lock()
+	defer bsc.capabilities.lock.Unlock()
+	if bsc.capabilities.closed {
+		return
+	}
+	bsc.capabilities.closed = true
+	bsc.capabilities._updateProtocolStat(-1)
+	if bsc.capabilities.stats == nil {
+		return
+	}
+	if bsc.capabilities.deltas {
+		bsc.capabilities.stats.NumReplicationsActiveDeltas.Add(-1)
+	}
+	if bsc.capabilities.collectionsAware {
+		bsc.capabilities.stats.NumReplicationsActiveCollectionsAware.Add(-1)
+	}
+}
+
+// _updateProtocolStat adds delta to the active replication count for the negotiated protocol version.  Requires the
+// lock to be held.
+func (c *blipClientCapabilities) _updateProtocolStat(delta int64) {
+	if c.stats == nil {
+		return
+	}
+	switch c.protocolVersion {
+	case BlipCBMobileReplicationV2:
+		c.stats.NumReplicationsActiveProtocolV2.Add(delta)
+	case BlipCBMobileReplicationV3:
+		c.stats.NumReplicationsActiveProtocolV3.Add(delta)
+	}
+}
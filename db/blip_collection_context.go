@@ -14,6 +14,7 @@ import (
 	"sync"
 
 	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
 )
 
 // blipSyncCollectionContext stores information about a single collection for a BlipSyncContext
@@ -21,10 +22,18 @@ type blipSyncCollectionContext struct {
 	dbCollection          *DatabaseCollection
 	activeSubChanges      base.AtomicBool // Flag for whether there is a subChanges subscription currently active.  Atomic access
 	changesCtxLock        sync.Mutex
-	changesCtx            context.Context    // Used for the unsub changes Blip message to check if the subChanges feed should stop
-	changesCtxCancel      context.CancelFunc // Cancel function for changesCtx to cancel subChanges being sent
+	changesCtx            context.Context      // Used for the unsub changes Blip message to check if the subChanges feed should stop
+	changesCtxCancel      context.CancelFunc   // Cancel function for changesCtx to cancel subChanges being sent
+	changesSubscription   *changesSubscription // Filters of the active continuous subChanges, updated by updateSubChanges. Protected by changesCtxLock
 	pendingInsertionsLock sync.Mutex
-	pendingInsertions     base.Set // DocIDs from handleProposeChanges that aren't in the db
+	pendingInsertions     base.Set        // DocIDs from handleProposeChanges that aren't in the db
+	pendingOverflowCount  int64           // Number of docIDs not added to pendingInsertions because it was full. Protected by pendingInsertionsLock
+	maxHistory            base.AtomicInt  // Max rev message history length requested by the client on subChanges, 0 if not requested. Atomic access
+	idsOnly               base.AtomicBool // Whether the client asked on subChanges to be sent changes without revisions. Atomic access
+	deferAttachments      base.AtomicBool // Whether the client asked on subChanges to get attachments after acknowledging their revisions. Atomic access
+	channelMapperLock     sync.Mutex
+	channelMapper         *channels.ChannelMapper // The collection's sync function, resolved when the context is created and whenever the collection's sync function is reloaded
+	channelMapperGen      uint64                  // The collection's sync function generation that channelMapper was resolved from
 
 	sgr2PullAddExpectedSeqsCallback  func(expectedSeqs map[IDAndRev]SequenceID)     // sgr2PullAddExpectedSeqsCallback is called after successfully handling an incoming changes message
 	sgr2PullProcessedSeqCallback     func(remoteSeq *SequenceID, idAndRev IDAndRev) // sgr2PullProcessedSeqCallback is called after successfully handling an incoming rev message
@@ -54,17 +63,35 @@ func newBlipSyncCollectionContext(ctx context.Context, dbCollection *DatabaseCol
 		dbCollection:      dbCollection,
 		pendingInsertions: base.Set{},
 	}
+	c.channelMapper, c.channelMapperGen = dbCollection.getChannelMapper()
 	c.changesCtx, c.changesCtxCancel = context.WithCancel(base.KeyspaceLogCtx(ctx, dbCollection.bucketName(), dbCollection.ScopeName, dbCollection.Name))
 	return c
 }
 
+// getChannelMapper returns the cached sync function for this collection, re-resolving it from the collection if the
+// collection's sync function has been reloaded since it was cached.
+func (bsc *blipSyncCollectionContext) getChannelMapper() *channels.ChannelMapper {
+	bsc.channelMapperLock.Lock()
+	defer bsc.channelMapperLock.Unlock()
+	if bsc.channelMapperGen != bsc.dbCollection.syncFnGeneration.Load() {
+		bsc.channelMapper, bsc.channelMapperGen = bsc.dbCollection.getChannelMapper()
+	}
+	return bsc.channelMapper
+}
+
 // Remembers a docID that doesn't exist in the collection at the time handleProposeChanges ran.
 func (bsc *blipSyncCollectionContext) notePendingInsertion(docID string) {
 	bsc.pendingInsertionsLock.Lock()
 	defer bsc.pendingInsertionsLock.Unlock()
+	pushStats := bsc.dbCollection.dbStats().CBLReplicationPush()
 	if len(bsc.pendingInsertions) < kMaxPendingInsertions {
-		bsc.pendingInsertions.Add(docID)
+		if !bsc.pendingInsertions.Contains(docID) {
+			bsc.pendingInsertions.Add(docID)
+			pushStats.PendingInsertions.Add(1)
+		}
 	} else {
+		bsc.pendingOverflowCount++
+		pushStats.PendingInsertionsOverflowCount.Add(1)
 		base.WarnfCtx(bsc.changesCtx, "Sync client has more than %d pending doc insertions in collection %q", kMaxPendingInsertions, base.UD(bsc.dbCollection.Name))
 	}
 }
@@ -76,10 +103,49 @@ func (bsc *blipSyncCollectionContext) checkPendingInsertion(docID string) (found
 	defer bsc.pendingInsertionsLock.Unlock()
 	if found = bsc.pendingInsertions.Contains(docID); found {
 		delete(bsc.pendingInsertions, docID)
+		bsc.dbCollection.dbStats().CBLReplicationPush().PendingInsertions.Add(-1)
 	}
 	return
 }
 
+// BlipPendingInsertions counts the documents a connection's client has proposed as new in proposeChanges messages,
+// but not yet pushed.  A count that stays high identifies a client that proposes changes but never sends the revisions.
+type BlipPendingInsertions struct {
+	Count     int   `json:"count"`
+	Overflows int64 `json:"overflows"` // Documents proposed as new that weren't tracked because the connection had too many pending
+}
+
+// PendingInsertions returns the connection's pending insertions, across all its collections.
+func (bsc *BlipSyncContext) PendingInsertions() BlipPendingInsertions {
+	var pendingInsertions BlipPendingInsertions
+	for _, collection := range bsc.collections.getAll() {
+		if collection == nil {
+			continue
+		}
+		pending, overflows := collection.pendingInsertionCounts()
+		pendingInsertions.Count += pending
+		pendingInsertions.Overflows += overflows
+	}
+	return pendingInsertions
+}
+
+// pendingInsertionCounts returns the number of docIDs in pendingInsertions, and the number not added because it was
+// full.
+func (bsc *blipSyncCollectionContext) pendingInsertionCounts() (pending int, overflows int64) {
+	bsc.pendingInsertionsLock.Lock()
+	defer bsc.pendingInsertionsLock.Unlock()
+	return len(bsc.pendingInsertions), bsc.pendingOverflowCount
+}
+
+// clearPendingInsertions forgets all pending insertions when the connection is closed, removing them from the
+// pending_insertions stat.
+func (bsc *blipSyncCollectionContext) clearPendingInsertions() {
+	bsc.pendingInsertionsLock.Lock()
+	defer bsc.pendingInsertionsLock.Unlock()
+	bsc.dbCollection.dbStats().CBLReplicationPush().PendingInsertions.Add(-int64(len(bsc.pendingInsertions)))
+	bsc.pendingInsertions = base.Set{}
+}
+
 // setNonCollectionAware adds a single collection matching _default._default collection, to be refered to if no Collection property is set on a blip message.
 func (b *blipCollections) setNonCollectionAware(collectionCtx *blipSyncCollectionContext) {
 	b.Lock()
@@ -23,12 +23,14 @@ import (
 
 //////// GETREV:
 
-// Handles a Connected-Client "getRev" request.
+// Handles a "getRev" request, made by Connected Clients and by clients replicating ids only to get the revisions they
+// want.  Gets the current revision, unless a revision is given.
 func (bh *blipHandler) handleGetRev(rq *blip.Message) error {
 	docID := rq.Properties[GetRevMessageId]
+	revID := rq.Properties[GetRevRevId]
 	ifNotRev := rq.Properties[GetRevIfNotRev]
 
-	rev, err := bh.collection.GetRev(bh.loggingCtx, docID, "", false, nil)
+	rev, err := bh.collection.GetRev(bh.loggingCtx, docID, revID, false, nil)
 	if err != nil {
 		status, reason := base.ErrorAsHTTPStatus(err)
 		return &base.HTTPError{Status: status, Message: reason}
@@ -45,7 +47,7 @@ func (bh *blipHandler) handleGetRev(rq *blip.Message) error {
 		return base.HTTPErrorf(http.StatusInternalServerError, "Couldn't read document body: %s", err)
 	}
 
-	bh.logEndpointEntry(rq.Profile(), fmt.Sprintf("doc: %s, ifNotRev: %s", docID, ifNotRev))
+	bh.logEndpointEntry(rq.Profile(), fmt.Sprintf("doc: %s, rev: %s, ifNotRev: %s", docID, revID, ifNotRev))
 
 	// Still need to stamp _attachments into BLIP messages
 	if len(rev.Attachments) > 0 {
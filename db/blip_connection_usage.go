@@ -0,0 +1,104 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"sync"
+
+	"github.com/couchbase/go-blip"
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// BlipConnectionUsage is the network usage of a replication connection, for chargeback.  Bytes sent and received are
+// measured on the wire, after compression, while body bytes are the total size of message bodies before compression.
+type BlipConnectionUsage struct {
+	BytesSent         uint64                           `json:"bytes_sent"`
+	BytesReceived     uint64                           `json:"bytes_received"`
+	BodyBytesSent     int64                            `json:"body_bytes_sent"`
+	BodyBytesReceived int64                            `json:"body_bytes_received"`
+	MessagesSent      map[string]base.BLIPMessageUsage `json:"messages_sent,omitempty"`     // Requests and responses sent, by profile
+	MessagesReceived  map[string]base.BLIPMessageUsage `json:"messages_received,omitempty"` // Requests and responses received, by profile
+}
+
+// blipConnectionUsage counts the messages sent and received by a connection, by profile.
+type blipConnectionUsage struct {
+	lock     sync.Mutex
+	sent     map[string]base.BLIPMessageUsage
+	received map[string]base.BLIPMessageUsage
+}
+
+// addMessageUsage counts a message in usage, allocating the map on first use.
+func addMessageUsage(usage *map[string]base.BLIPMessageUsage, profile string, bodyBytes int) {
+	if *usage == nil {
+		*usage = make(map[string]base.BLIPMessageUsage)
+	}
+	profileUsage := (*usage)[profile]
+	profileUsage.Count++
+	profileUsage.BodyBytes += int64(bodyBytes)
+	(*usage)[profile] = profileUsage
+}
+
+// recordMessageSent counts a request or response sent to the client, for the connection and the database.
+func (bsc *BlipSyncContext) recordMessageSent(profile string, msg *blip.Message) {
+	bsc.recordMessage(base.BLIPMessageSent, profile, msg)
+}
+
+// recordMessageReceived counts a request or response received from the client, for the connection and the database.
+func (bsc *BlipSyncContext) recordMessageReceived(profile string, msg *blip.Message) {
+	bsc.recordMessage(base.BLIPMessageReceived, profile, msg)
+}
+
+func (bsc *BlipSyncContext) recordMessage(direction base.BLIPMessageDirection, profile string, msg *blip.Message) {
+	if msg == nil {
+		return
+	}
+	body, err := msg.Body()
+	if err != nil {
+		return
+	}
+	bsc.usage.lock.Lock()
+	if direction == base.BLIPMessageSent {
+		addMessageUsage(&bsc.usage.sent, profile, len(body))
+	} else {
+		addMessageUsage(&bsc.usage.received, profile, len(body))
+	}
+	bsc.usage.lock.Unlock()
+
+	if bsc.blipContextDb == nil {
+		return
+	}
+	bsc.blipContextDb.FlightRecorder.record(bsc.userName, direction, profile, msg, body)
+	if bsc.blipContextDb.DbStats == nil {
+		return
+	}
+	if messageStats := bsc.blipContextDb.DbStats.CBLReplicationMessages(); messageStats != nil {
+		messageStats.Add(direction, profile, len(body))
+	}
+}
+
+// ConnectionUsage returns the network usage of the connection so far.
+func (bsc *BlipSyncContext) ConnectionUsage() BlipConnectionUsage {
+	usage := BlipConnectionUsage{
+		BytesSent:     bsc.blipContext.GetBytesSent(),
+		BytesReceived: bsc.blipContext.GetBytesReceived(),
+	}
+	bsc.usage.lock.Lock()
+	defer bsc.usage.lock.Unlock()
+	usage.MessagesSent = make(map[string]base.BLIPMessageUsage, len(bsc.usage.sent))
+	for profile, profileUsage := range bsc.usage.sent {
+		usage.MessagesSent[profile] = profileUsage
+		usage.BodyBytesSent += profileUsage.BodyBytes
+	}
+	usage.MessagesReceived = make(map[string]base.BLIPMessageUsage, len(bsc.usage.received))
+	for profile, profileUsage := range bsc.usage.received {
+		usage.MessagesReceived[profile] = profileUsage
+		usage.BodyBytesReceived += profileUsage.BodyBytes
+	}
+	return usage
+}
@@ -0,0 +1,322 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/couchbase/go-blip"
+	"github.com/couchbase/sync_gateway/base"
+)
+
+const (
+	DefaultFlightRecorderDuration   = 10 * time.Minute
+	MaxFlightRecorderDuration       = time.Hour
+	DefaultFlightRecorderMaxRecords = 1000
+	MaxFlightRecorderMaxRecords     = 10000
+)
+
+// FlightRecorderBodies is how much of each message body a flight recorder captures.
+type FlightRecorderBodies string
+
+const (
+	FlightRecorderBodiesNone     FlightRecorderBodies = ""         // Only the size of bodies is captured
+	FlightRecorderBodiesRedacted FlightRecorderBodies = "redacted" // JSON bodies are captured with their values redacted, keeping their structure
+	FlightRecorderBodiesFull     FlightRecorderBodies = "full"     // Bodies are captured as sent
+)
+
+// flightRecorderRedacted replaces each redacted value in a captured body.
+const flightRecorderRedacted = "<redacted>"
+
+// FlightRecorderOptions selects the BLIP messages captured by a flight recorder.  At least one of User and DocID must
+// be set, and a message is captured only if it matches all of the ones that are.
+type FlightRecorderOptions struct {
+	User       string               // Captures messages on the user's replications
+	DocID      string               // Captures messages about the document, and the responses to them
+	Duration   time.Duration        // How long to capture messages for, defaults to DefaultFlightRecorderDuration
+	Bodies     FlightRecorderBodies // How much of each message body to capture
+	MaxRecords int                  // The number of most recent messages kept, defaults to DefaultFlightRecorderMaxRecords
+}
+
+// FlightRecord is a BLIP message captured by a flight recorder.
+type FlightRecord struct {
+	Time         time.Time                 `json:"time"`
+	Direction    base.BLIPMessageDirection `json:"direction"` // Whether the message was sent to or received from the client
+	Type         string                    `json:"type"`      // MSG for requests, RPY for responses and ERR for errors
+	Profile      string                    `json:"profile"`
+	SerialNumber uint64                    `json:"serial_number"` // Pairs a response with its request
+	User         string                    `json:"user,omitempty"`
+	Properties   map[string]string         `json:"properties,omitempty"`
+	BodyBytes    int                       `json:"body_bytes"`
+	Body         json.RawMessage           `json:"body,omitempty"`
+}
+
+// FlightRecorderStatus is the state of a flight recorder's capture, with the messages captured so far.
+type FlightRecorderStatus struct {
+	Active       bool                 `json:"active"`
+	User         string               `json:"user,omitempty"`
+	DocID        string               `json:"doc_id,omitempty"`
+	Bodies       FlightRecorderBodies `json:"bodies,omitempty"`
+	MaxRecords   int                  `json:"max_records,omitempty"`
+	StartedAt    *time.Time           `json:"started_at,omitempty"`
+	ExpiresAt    *time.Time           `json:"expires_at,omitempty"`
+	DroppedCount int                  `json:"dropped_count"` // Messages captured but since overwritten by more recent ones
+	Records      []FlightRecord       `json:"records"`
+}
+
+// flightRecordKey identifies a request whose response should be captured.
+type flightRecordKey struct {
+	direction    base.BLIPMessageDirection
+	serialNumber blip.MessageNumber
+}
+
+// FlightRecorder captures the BLIP messages of a specific user or document for a limited time into a ring buffer, to
+// reproduce client bugs.  Only one capture runs at a time for a database.
+type FlightRecorder struct {
+	active    atomic.Bool // Fast path for connections when there's no capture running
+	lock      sync.Mutex
+	options   FlightRecorderOptions
+	startedAt time.Time
+	expiresAt time.Time
+	records   []FlightRecord                // Ring buffer of captured messages
+	next      int                           // Index in records of the next message captured
+	count     int                           // Total number of messages captured
+	pending   map[flightRecordKey]time.Time // Captured requests awaiting a response
+	now       func() time.Time              // Testing seam
+}
+
+func NewFlightRecorder() *FlightRecorder {
+	return &FlightRecorder{now: time.Now}
+}
+
+// Start starts a capture with the given options, discarding the messages of any previous capture.
+func (fr *FlightRecorder) Start(options FlightRecorderOptions) error {
+	if options.User == "" && options.DocID == "" {
+		return errors.New("a user or doc_id is required")
+	}
+	if options.Duration <= 0 {
+		options.Duration = DefaultFlightRecorderDuration
+	} else if options.Duration > MaxFlightRecorderDuration {
+		return errors.New("duration cannot be more than an hour")
+	}
+	if options.MaxRecords <= 0 {
+		options.MaxRecords = DefaultFlightRecorderMaxRecords
+	} else if options.MaxRecords > MaxFlightRecorderMaxRecords {
+		return errors.New("max_records cannot be more than 10000")
+	}
+	switch options.Bodies {
+	case FlightRecorderBodiesNone, FlightRecorderBodiesRedacted, FlightRecorderBodiesFull:
+	default:
+		return errors.New(`bodies must be "redacted", "full" or empty`)
+	}
+
+	fr.lock.Lock()
+	defer fr.lock.Unlock()
+	fr.options = options
+	fr.startedAt = fr.now()
+	fr.expiresAt = fr.startedAt.Add(options.Duration)
+	fr.records = make([]FlightRecord, 0, options.MaxRecords)
+	fr.next = 0
+	fr.count = 0
+	fr.pending = make(map[flightRecordKey]time.Time)
+	fr.active.Store(true)
+	return nil
+}
+
+// Stop stops the capture and discards the messages captured.
+func (fr *FlightRecorder) Stop() {
+	fr.lock.Lock()
+	defer fr.lock.Unlock()
+	fr.active.Store(false)
+	fr.options = FlightRecorderOptions{}
+	fr.startedAt = time.Time{}
+	fr.expiresAt = time.Time{}
+	fr.records = nil
+	fr.next = 0
+	fr.count = 0
+	fr.pending = nil
+}
+
+// Status returns the state of the capture and the messages captured, oldest first.  Messages captured by a capture
+// that has expired are kept until it's stopped or another is started.
+func (fr *FlightRecorder) Status() FlightRecorderStatus {
+	fr.lock.Lock()
+	defer fr.lock.Unlock()
+	status := FlightRecorderStatus{
+		Active:     fr.active.Load() && fr.now().Before(fr.expiresAt),
+		User:       fr.options.User,
+		DocID:      fr.options.DocID,
+		Bodies:     fr.options.Bodies,
+		MaxRecords: fr.options.MaxRecords,
+		Records:    make([]FlightRecord, 0, len(fr.records)),
+	}
+	if !fr.startedAt.IsZero() {
+		startedAt, expiresAt := fr.startedAt.UTC(), fr.expiresAt.UTC()
+		status.StartedAt = &startedAt
+		status.ExpiresAt = &expiresAt
+	}
+	// Once the buffer is full, the oldest message is the next to be overwritten
+	status.DroppedCount = fr.count - len(fr.records)
+	status.Records = append(status.Records, fr.records[fr.next:]...)
+	status.Records = append(status.Records, fr.records[:fr.next]...)
+	return status
+}
+
+// record captures a message if a capture is running and the message matches it.  A response is captured if its
+// request was.
+func (fr *FlightRecorder) record(userName string, direction base.BLIPMessageDirection, profile string, msg *blip.Message, body []byte) {
+	if fr == nil || !fr.active.Load() {
+		return
+	}
+
+	fr.lock.Lock()
+	defer fr.lock.Unlock()
+	now := fr.now()
+	if !fr.active.Load() {
+		return
+	}
+	if !now.Before(fr.expiresAt) {
+		fr.active.Store(false)
+		fr.pending = nil
+		return
+	}
+	if fr.options.User != "" && fr.options.User != userName {
+		return
+	}
+
+	msgType := msg.Type()
+	if msgType == blip.RequestType {
+		if !fr.matchesDoc(msg, body) {
+			return
+		}
+		// Responses are sent in the opposite direction to their request, with the same serial number
+		responseKey := flightRecordKey{direction: base.BLIPMessageReceived, serialNumber: msg.SerialNumber()}
+		if direction == base.BLIPMessageReceived {
+			responseKey.direction = base.BLIPMessageSent
+		}
+		if len(fr.pending) < fr.options.MaxRecords && !msg.NoReply() {
+			fr.pending[responseKey] = now
+		}
+	} else {
+		responseKey := flightRecordKey{direction: direction, serialNumber: msg.SerialNumber()}
+		if _, ok := fr.pending[responseKey]; !ok {
+			return
+		}
+		delete(fr.pending, responseKey)
+	}
+
+	record := FlightRecord{
+		Time:         now.UTC(),
+		Direction:    direction,
+		Type:         flightRecordType(msgType),
+		Profile:      profile,
+		SerialNumber: uint64(msg.SerialNumber()),
+		User:         userName,
+		BodyBytes:    len(body),
+	}
+	if len(msg.Properties) > 0 {
+		record.Properties = make(map[string]string, len(msg.Properties))
+		for key, value := range msg.Properties {
+			record.Properties[key] = value
+		}
+	}
+	switch fr.options.Bodies {
+	case FlightRecorderBodiesRedacted:
+		record.Body = redactFlightRecordBody(body)
+	case FlightRecorderBodiesFull:
+		if json.Valid(body) {
+			record.Body = append(json.RawMessage(nil), body...)
+		} else if len(body) > 0 {
+			// Non-JSON bodies, such as attachment data, are captured as a JSON string
+			record.Body = json.RawMessage(base.ConvertToJSONString(string(body)))
+		}
+	}
+
+	if len(fr.records) < fr.options.MaxRecords {
+		fr.records = append(fr.records, record)
+	} else {
+		fr.records[fr.next] = record
+	}
+	fr.next = (fr.next + 1) % fr.options.MaxRecords
+	fr.count++
+}
+
+// matchesDoc returns true if no document is being captured, or the request is about the document: the document ID is
+// one of its properties, or is in its body, as for changes and proposeChanges.  Requires the lock to be held.
+func (fr *FlightRecorder) matchesDoc(msg *blip.Message, body []byte) bool {
+	docID := fr.options.DocID
+	if docID == "" {
+		return true
+	}
+	for _, value := range msg.Properties {
+		if value == docID {
+			return true
+		}
+	}
+	return bytes.Contains(body, []byte(base.ConvertToJSONString(docID)))
+}
+
+func flightRecordType(msgType blip.MessageType) string {
+	switch msgType {
+	case blip.RequestType:
+		return "MSG"
+	case blip.ResponseType:
+		return "RPY"
+	case blip.ErrorType:
+		return "ERR"
+	default:
+		return "?"
+	}
+}
+
+// redactFlightRecordBody returns a JSON body with its values redacted, keeping its structure and the values of
+// special properties such as _id, _rev and _deleted.  Bodies that aren't JSON aren't captured.
+func redactFlightRecordBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	var value interface{}
+	decoder := base.JSONDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil
+	}
+	redacted, err := base.JSONMarshal(redactFlightRecordValue(value))
+	if err != nil {
+		return nil
+	}
+	return redacted
+}
+
+func redactFlightRecordValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, propertyValue := range v {
+			if !strings.HasPrefix(key, "_") {
+				v[key] = redactFlightRecordValue(propertyValue)
+			}
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactFlightRecordValue(item)
+		}
+		return v
+	case nil:
+		return nil
+	default:
+		return flightRecorderRedacted
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/couchbase/go-blip"
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlightRecorder(t *testing.T) {
+	fr := NewFlightRecorder()
+	now := time.Now()
+	fr.now = func() time.Time { return now }
+
+	// Nothing is captured until a capture is started
+	body := []byte(`{"name":"alice","_deleted":true}`)
+	revRequest := blip.NewParsedIncomingMessage(nil, blip.RequestType, blip.Properties{RevMessageID: "doc1", RevMessageRev: "1-abc"}, body)
+	fr.record("alice", base.BLIPMessageReceived, MessageRev, revRequest, body)
+	assert.Empty(t, fr.Status().Records)
+
+	require.Error(t, fr.Start(FlightRecorderOptions{}))
+	require.Error(t, fr.Start(FlightRecorderOptions{DocID: "doc1", Bodies: "some"}))
+	require.Error(t, fr.Start(FlightRecorderOptions{DocID: "doc1", Duration: 2 * time.Hour}))
+	require.NoError(t, fr.Start(FlightRecorderOptions{DocID: "doc1", Bodies: FlightRecorderBodiesRedacted, MaxRecords: 3}))
+
+	// Requests about the document are captured along with their responses, whoever sends them
+	fr.record("alice", base.BLIPMessageReceived, MessageRev, revRequest, body)
+	revResponse := blip.NewParsedIncomingMessage(nil, blip.ResponseType, nil, nil)
+	fr.record("alice", base.BLIPMessageSent, MessageRev, revResponse, nil)
+	changesBody := []byte(`[[5,"doc2","1-def"],[6,"doc1","2-abc"]]`)
+	changesRequest := blip.NewParsedIncomingMessage(nil, blip.RequestType, nil, changesBody)
+	fr.record("bob", base.BLIPMessageSent, MessageChanges, changesRequest, changesBody)
+
+	// Other documents' requests, and responses to requests that weren't captured, aren't
+	otherRequest := blip.NewParsedIncomingMessage(nil, blip.RequestType, blip.Properties{RevMessageID: "doc2"}, nil)
+	fr.record("alice", base.BLIPMessageReceived, MessageRev, otherRequest, nil)
+	fr.record("alice", base.BLIPMessageSent, MessageRev, revResponse, nil)
+
+	status := fr.Status()
+	assert.True(t, status.Active)
+	assert.Equal(t, "doc1", status.DocID)
+	require.Len(t, status.Records, 3)
+	assert.Equal(t, "MSG", status.Records[0].Type)
+	assert.Equal(t, base.BLIPMessageReceived, status.Records[0].Direction)
+	assert.Equal(t, "doc1", status.Records[0].Properties[RevMessageID])
+	assert.Equal(t, len(body), status.Records[0].BodyBytes)
+	assert.JSONEq(t, `{"name":"<redacted>","_deleted":true}`, string(status.Records[0].Body))
+	assert.Equal(t, "RPY", status.Records[1].Type)
+	assert.Equal(t, base.BLIPMessageSent, status.Records[1].Direction)
+	assert.Equal(t, "bob", status.Records[2].User)
+	assert.JSONEq(t, `[["<redacted>","<redacted>","<redacted>"],["<redacted>","<redacted>","<redacted>"]]`, string(status.Records[2].Body))
+
+	// The oldest messages are overwritten once the buffer is full
+	fr.record("alice", base.BLIPMessageReceived, MessageRev, revRequest, body)
+	status = fr.Status()
+	require.Len(t, status.Records, 3)
+	assert.Equal(t, 1, status.DroppedCount)
+	assert.Equal(t, "RPY", status.Records[0].Type)
+	assert.Equal(t, MessageRev, status.Records[2].Profile)
+
+	// Nothing is captured once the capture expires, but the messages are kept
+	now = now.Add(DefaultFlightRecorderDuration)
+	fr.record("alice", base.BLIPMessageReceived, MessageRev, revRequest, body)
+	status = fr.Status()
+	assert.False(t, status.Active)
+	assert.Len(t, status.Records, 3)
+
+	// A user capture captures all of the user's messages, with full bodies
+	require.NoError(t, fr.Start(FlightRecorderOptions{User: "alice", Bodies: FlightRecorderBodiesFull}))
+	fr.record("alice", base.BLIPMessageReceived, MessageRev, otherRequest, nil)
+	fr.record("alice", base.BLIPMessageReceived, MessageRev, revRequest, body)
+	fr.record("bob", base.BLIPMessageSent, MessageChanges, changesRequest, changesBody)
+	status = fr.Status()
+	require.Len(t, status.Records, 2)
+	assert.JSONEq(t, string(body), string(status.Records[1].Body))
+
+	fr.Stop()
+	status = fr.Status()
+	assert.False(t, status.Active)
+	assert.Empty(t, status.Records)
+	assert.Nil(t, status.StartedAt)
+}
@@ -30,18 +30,20 @@ import (
 
 // handlersByProfile defines the routes for each message profile (verb) of an incoming request to the function that handles it.
 var handlersByProfile = map[string]blipHandlerFunc{
-	MessageGetCheckpoint:   collectionBlipHandler((*blipHandler).handleGetCheckpoint),
-	MessageSetCheckpoint:   collectionBlipHandler((*blipHandler).handleSetCheckpoint),
-	MessageSubChanges:      userBlipHandler(collectionBlipHandler((*blipHandler).handleSubChanges)),
-	MessageUnsubChanges:    userBlipHandler(collectionBlipHandler((*blipHandler).handleUnsubChanges)),
-	MessageChanges:         userBlipHandler(collectionBlipHandler((*blipHandler).handleChanges)),
-	MessageRev:             userBlipHandler(collectionBlipHandler((*blipHandler).handleRev)),
-	MessageNoRev:           collectionBlipHandler((*blipHandler).handleNoRev),
-	MessageGetAttachment:   userBlipHandler(collectionBlipHandler((*blipHandler).handleGetAttachment)),
-	MessageProveAttachment: userBlipHandler(collectionBlipHandler((*blipHandler).handleProveAttachment)),
-	MessageProposeChanges:  collectionBlipHandler((*blipHandler).handleProposeChanges),
-	MessageGetRev:          userBlipHandler(collectionBlipHandler((*blipHandler).handleGetRev)),
-	MessagePutRev:          userBlipHandler(collectionBlipHandler((*blipHandler).handlePutRev)),
+	MessageGetCheckpoint:    collectionBlipHandler((*blipHandler).handleGetCheckpoint),
+	MessageSetCheckpoint:    collectionBlipHandler((*blipHandler).handleSetCheckpoint),
+	MessageSubChanges:       userBlipHandler(collectionBlipHandler((*blipHandler).handleSubChanges)),
+	MessageUnsubChanges:     userBlipHandler(collectionBlipHandler((*blipHandler).handleUnsubChanges)),
+	MessageUpdateSubChanges: userBlipHandler(collectionBlipHandler((*blipHandler).handleUpdateSubChanges)),
+	MessageChanges:          pushBlipHandler(userBlipHandler(collectionBlipHandler((*blipHandler).handleChanges))),
+	MessageRev:              pushBlipHandler(userBlipHandler(collectionBlipHandler((*blipHandler).handleRev))),
+	MessageNoRev:            collectionBlipHandler((*blipHandler).handleNoRev),
+	MessageGetAttachment:    userBlipHandler(collectionBlipHandler((*blipHandler).handleGetAttachment)),
+	MessageProveAttachment:  userBlipHandler(collectionBlipHandler((*blipHandler).handleProveAttachment)),
+	MessageProposeChanges:   pushBlipHandler(collectionBlipHandler((*blipHandler).handleProposeChanges)),
+	MessageGetRev:           userBlipHandler(collectionBlipHandler((*blipHandler).handleGetRev)),
+	MessagePutRev:           pushBlipHandler(userBlipHandler(collectionBlipHandler((*blipHandler).handlePutRev))),
+	MessageGetDocAccess:     userBlipHandler(collectionBlipHandler((*blipHandler).handleGetDocAccess)),
 
 	MessageGetCollections: userBlipHandler((*blipHandler).handleGetCollections),
 }
@@ -95,6 +97,10 @@ var (
 	// HTTP 503 tells the client to reconnect and try again.
 	ErrDatabaseWentAway = base.HTTPErrorf(http.StatusServiceUnavailable, "Sync Gateway database went away - asking client to reconnect")
 
+	// ErrDatabasePaused is returned for pushed changes while the database is paused.  Unlike a 503, which asks the
+	// client to reconnect, the client retries the push later on the same connection.
+	ErrDatabasePaused = base.HTTPErrorf(http.StatusTooManyRequests, "Sync Gateway database is paused - retry later")
+
 	// ErrAttachmentNotFound is returned when the attachment that is asked by one of the peers does
 	// not exist in another to prove that it has the attachment during Inter-Sync Gateway Replication.
 	ErrAttachmentNotFound = base.HTTPErrorf(http.StatusNotFound, "attachment not found")
@@ -117,6 +123,16 @@ func userBlipHandler(next blipHandlerFunc) blipHandlerFunc {
 	}
 }
 
+// pushBlipHandler wraps a blip handler for pushed changes, rejecting them while the database is paused.
+func pushBlipHandler(next blipHandlerFunc) blipHandlerFunc {
+	return func(bh *blipHandler, bm *blip.Message) error {
+		if bh.db.IsPaused() {
+			return ErrDatabasePaused
+		}
+		return next(bh, bm)
+	}
+}
+
 func (bh *blipHandler) refreshUser() error {
 
 	bc := bh.BlipSyncContext
@@ -142,6 +158,7 @@ func (bh *blipHandler) refreshUser() error {
 				bh.collection = &DatabaseCollectionWithUser{
 					DatabaseCollection: bh.collection.DatabaseCollection,
 					user:               bh.db.User(),
+					channelMapper:      bh.collection.channelMapper,
 				}
 			}
 		}
@@ -189,6 +206,7 @@ func collectionBlipHandler(next blipHandlerFunc) blipHandlerFunc {
 		bh.collection = &DatabaseCollectionWithUser{
 			DatabaseCollection: bh.collectionCtx.dbCollection,
 			user:               bh.db.user,
+			channelMapper:      bh.collectionCtx.getChannelMapper(),
 		}
 		bh.loggingCtx = base.CollectionLogCtx(bh.BlipSyncContext.loggingCtx, bh.collection.Name)
 		// Call down to the underlying handler and return it's value
@@ -210,6 +228,10 @@ func (bh *blipHandler) handleGetCheckpoint(rq *blip.Message) error {
 	}
 
 	value, err := bh.collection.GetSpecial(DocTypeLocal, CheckpointDocIDPrefix+client)
+	if err != nil && !base.IsDocNotFoundError(err) {
+		return err
+	}
+	value, err = bh.collection.resolveMirroredCheckpoint(bh.loggingCtx, client, value)
 	if err != nil {
 		return err
 	}
@@ -237,10 +259,15 @@ func (bh *blipHandler) handleSetCheckpoint(rq *blip.Message) error {
 	if revID := checkpointMessage.rev(); revID != "" {
 		checkpoint[BodyRev] = revID
 	}
+	if err := bh.collection.checkCheckpointWriter(bh.loggingCtx, checkpointMessage.client(), bh.blipContext.ID); err != nil {
+		return err
+	}
 	revID, err := bh.collection.PutSpecial(DocTypeLocal, CheckpointDocIDPrefix+checkpointMessage.client(), checkpoint)
+	bh.collection.recordCheckpointWrite(bh.loggingCtx, checkpointMessage.client(), bh.blipContext.ID, err)
 	if err != nil {
 		return err
 	}
+	bh.collection.mirrorCheckpoint(bh.loggingCtx, checkpointMessage.client(), checkpoint)
 
 	checkpointResponse := SetCheckpointResponse{checkpointMessage.Response()}
 	checkpointResponse.setRev(revID)
@@ -257,11 +284,20 @@ func (bh *blipHandler) handleSubChanges(rq *blip.Message) error {
 		seq, err := bh.collection.LastSequence(bh.loggingCtx)
 		return SequenceID{Seq: seq}, err
 	}
+	if bh.IsDraining() {
+		return base.HTTPErrorf(http.StatusServiceUnavailable, "Sync Gateway is shutting down")
+	}
+
 	subChangesParams, err := NewSubChangesParams(bh.loggingCtx, rq, defaultSince, latestSeq, ParseJSONSequenceID)
 	if err != nil {
 		return base.HTTPErrorf(http.StatusBadRequest, "Invalid subChanges parameters")
 	}
 
+	// Changes rows for each leaf revision are only understood by clients using protocol V3
+	if subChangesParams.allDocs() && bh.blipContext.ActiveSubprotocol() != BlipCBMobileReplicationV3 {
+		return base.HTTPErrorf(http.StatusBadRequest, "%s=%s requires protocol %s", SubChangesStyle, SubChangesStyleAllDocs, BlipCBMobileReplicationV3)
+	}
+
 	// Ensure that only _one_ subChanges subscription can be open on this blip connection at any given time.  SG #3222.
 	collectionCtx := bh.collectionCtx
 	collectionCtx.changesCtxLock.Lock()
@@ -279,13 +315,26 @@ func (bh *blipHandler) handleSubChanges(rq *blip.Message) error {
 		collectionCtx.changesCtx, collectionCtx.changesCtxCancel = context.WithCancel(bh.loggingCtx)
 	}
 
-	if len(subChangesParams.docIDs()) > 0 && subChangesParams.continuous() {
-		return base.HTTPErrorf(http.StatusBadRequest, "DocIDs filter not supported for continuous subChanges")
+	bh.logEndpointEntry(rq.Profile(), subChangesParams.String())
+
+	// A checkpoint ahead of the sequence counter predates the bucket being flushed or restored from a backup, so
+	// would skip revisions given sequences since.  Replicate again from the start instead.  Checkpoints from before
+	// the counter moved backwards that it has since passed are from an earlier sequence epoch, so are reset by the
+	// changes feed.
+	since := subChangesParams.Since()
+	if ahead, err := bh.db.sequenceAheadOfCounter(bh.loggingCtx, since.Seq); err != nil {
+		base.WarnfCtx(bh.loggingCtx, "Unable to check since value %s against the sequence counter: %v", since, err)
+	} else if ahead {
+		base.WarnfCtx(bh.loggingCtx, "Since value %s is ahead of the sequence counter, so the client's checkpoint predates the bucket being flushed or restored from a backup. Replicating from the start.", since)
+		since = CreateZeroSinceValue()
 	}
 
-	bh.logEndpointEntry(rq.Profile(), subChangesParams.String())
+	collectionCtx.maxHistory.Set(int64(subChangesParams.maxHistory()))
+	collectionCtx.idsOnly.Set(subChangesParams.idsOnly())
+	collectionCtx.deferAttachments.Set(subChangesParams.deferAttachments())
 
 	var channels base.Set
+	var namedFilter *ReplicationFilter
 	if filter := subChangesParams.filter(); filter == base.ByChannelFilter {
 		var err error
 
@@ -296,8 +345,11 @@ func (bh *blipHandler) handleSubChanges(rq *blip.Message) error {
 			return base.HTTPErrorf(http.StatusBadRequest, "Empty channel list")
 
 		}
+	} else if namedFilter = bh.db.Options.ReplicationFilters[filter]; namedFilter != nil {
+		// Named filters define their own channels, any channels on the request are ignored
+		channels = namedFilter.Channels
 	} else if filter != "" {
-		return base.HTTPErrorf(http.StatusBadRequest, "Unknown filter; try sync_gateway/bychannel")
+		return base.HTTPErrorf(http.StatusBadRequest, "Unknown filter; try sync_gateway/bychannel or a filter defined in replication_filters")
 	}
 
 	clientType := clientTypeCBL2
@@ -307,6 +359,18 @@ func (bh *blipHandler) handleSubChanges(rq *blip.Message) error {
 
 	continuous := subChangesParams.continuous()
 
+	// Continuous subChanges can have their channel and docID filters updated by updateSubChanges while active
+	var subscription *changesSubscription
+	if continuous {
+		subscriptionChannels := channels
+		if namedFilter != nil {
+			// The channels of a named filter are defined by the config, so can't be updated by the client
+			subscriptionChannels = nil
+		}
+		subscription = newChangesSubscription(subscriptionChannels, subChangesParams.docIDs())
+	}
+	collectionCtx.changesSubscription = subscription
+
 	requestPlusSeq := uint64(0)
 	// If non-continuous, check whether requestPlus handling is set for request or via database config
 	if continuous == false {
@@ -341,9 +405,10 @@ func (bh *blipHandler) handleSubChanges(rq *blip.Message) error {
 		startTime := time.Now()
 		_ = bh.sendChanges(rq.Sender, &sendChangesOptions{
 			docIDs:            subChangesParams.docIDs(),
-			since:             subChangesParams.Since(),
+			since:             since,
 			continuous:        continuous,
 			activeOnly:        subChangesParams.activeOnly(),
+			allDocs:           subChangesParams.allDocs(),
 			batchSize:         subChangesParams.batchSize(),
 			channels:          channels,
 			revocations:       subChangesParams.revocations(),
@@ -351,6 +416,9 @@ func (bh *blipHandler) handleSubChanges(rq *blip.Message) error {
 			ignoreNoConflicts: clientType == clientTypeSGR2, // force this side to accept a "changes" message, even in no conflicts mode for SGR2.
 			changesCtx:        collectionCtx.changesCtx,
 			requestPlusSeq:    requestPlusSeq,
+			subscription:      subscription,
+			namedFilter:       namedFilter,
+			priorities:        subChangesParams.priorities(),
 		})
 		base.DebugfCtx(bh.loggingCtx, base.KeySyncMsg, "#%d: Type:%s   --> Time:%v", bh.serialNumber, rq.Profile(), time.Since(startTime))
 	}()
@@ -366,6 +434,39 @@ func (bh *blipHandler) handleUnsubChanges(rq *blip.Message) error {
 	return nil
 }
 
+// Received an "updateSubChanges" request to add or remove channels and docIDs from the active continuous subChanges
+func (bh *blipHandler) handleUpdateSubChanges(rq *blip.Message) error {
+	var body UpdateSubChangesBody
+	if err := rq.ReadJSONBody(&body); err != nil {
+		return base.HTTPErrorf(http.StatusBadRequest, "Invalid updateSubChanges body: %v", err)
+	}
+
+	bh.logEndpointEntry(rq.Profile(), body.String())
+
+	collectionCtx := bh.collectionCtx
+	collectionCtx.changesCtxLock.Lock()
+	subscription := collectionCtx.changesSubscription
+	collectionCtx.changesCtxLock.Unlock()
+	if subscription == nil || !collectionCtx.activeSubChanges.IsTrue() {
+		return base.HTTPErrorf(http.StatusBadRequest, "No active continuous subChanges to update")
+	}
+
+	if _, err := channels.SetFromArray(body.AddChannels, channels.KeepStar); err != nil {
+		return base.HTTPErrorf(http.StatusBadRequest, "%s", err)
+	}
+	if err := subscription.update(body.AddChannels, body.RemoveChannels, body.AddDocIDs, body.RemoveDocIDs); err != nil {
+		return base.HTTPErrorf(http.StatusBadRequest, "%s", err)
+	}
+
+	// Wake the changes feed so that channel updates are applied without waiting for the next change
+	user := ""
+	if bh.db.User() != nil {
+		user = bh.db.User().Name()
+	}
+	bh.db.DatabaseContext.NotifyTerminatedChanges(bh.loggingCtx, user)
+	return nil
+}
+
 type clientType uint8
 
 const (
@@ -378,6 +479,7 @@ type sendChangesOptions struct {
 	since             SequenceID
 	continuous        bool
 	activeOnly        bool
+	allDocs           bool // Send a changes row for each leaf revision of conflicted documents, winning revision first
 	batchSize         int
 	channels          base.Set
 	clientType        clientType
@@ -385,6 +487,9 @@ type sendChangesOptions struct {
 	ignoreNoConflicts bool
 	changesCtx        context.Context
 	requestPlusSeq    uint64
+	subscription      *changesSubscription // Updatable filters for a continuous feed, nil otherwise
+	namedFilter       *ReplicationFilter   // Named filter from the database config, nil if not requested
+	priorities        map[string]int       // Relative priority of channels during catch-up, nil if not requested
 }
 
 type changesDeletedFlag uint
@@ -413,13 +518,21 @@ func (bh *blipHandler) sendChanges(sender *blip.Sender, opts *sendChangesOptions
 
 	options := ChangesOptions{
 		Since:          opts.since,
-		Conflicts:      false, // CBL 2.0/BLIP don't support branched rev trees (LiteCore #437)
+		Conflicts:      opts.allDocs, // Only when requested, CBL 2.0/BLIP don't support branched rev trees (LiteCore #437)
 		Continuous:     opts.continuous,
 		ActiveOnly:     opts.activeOnly,
 		Revocations:    opts.revocations,
 		clientType:     opts.clientType,
 		ChangesCtx:     opts.changesCtx,
 		RequestPlusSeq: opts.requestPlusSeq,
+		subscription:   opts.subscription,
+	}
+
+	// The docIDs of a continuous feed can be updated while it's running, so are filtered as changes are sent rather
+	// than by the feed itself
+	docIDFilter := opts.docIDs
+	if opts.subscription != nil {
+		docIDFilter = nil
 	}
 
 	channelSet := opts.channels
@@ -428,13 +541,13 @@ func (bh *blipHandler) sendChanges(sender *blip.Sender, opts *sendChangesOptions
 	}
 
 	caughtUp := false
-	pendingChanges := make([][]interface{}, 0, opts.batchSize)
+	pendingChanges := make([]changesRow, 0, opts.batchSize)
 	sendPendingChangesAt := func(minChanges int) error {
 		if len(pendingChanges) >= minChanges {
 			if err := bh.sendBatchOfChanges(sender, pendingChanges, opts.ignoreNoConflicts); err != nil {
 				return err
 			}
-			pendingChanges = make([][]interface{}, 0, opts.batchSize)
+			pendingChanges = make([]changesRow, 0, opts.batchSize)
 		}
 		return nil
 	}
@@ -447,44 +560,79 @@ func (bh *blipHandler) sendChanges(sender *blip.Sender, opts *sendChangesOptions
 		return false
 
 	}
-	_, forceClose := generateBlipSyncChanges(bh.loggingCtx, changesDb, channelSet, options, opts.docIDs, func(changes []*ChangeEntry) error {
+
+	// queueChange adds the rows for a change to the pending batch, sending the batch once it's full
+	queueChange := func(change *ChangeEntry) error {
+		if strings.HasPrefix(change.ID, "_") || !opts.subscription.includesDocID(change.ID) || !opts.namedFilter.includesChange(bh.loggingCtx, changesDb, change) {
+			return nil
+		}
+		// If change is a removal and we're running with protocol V3 and change change is not a tombstone
+		// fall into 3.0 removal handling.
+		// Changes with change.Revoked=true have already evaluated UserHasDocAccess in changes.go, don't check again.
+		if change.allRemoved && bh.blipContext.ActiveSubprotocol() == BlipCBMobileReplicationV3 && !change.Deleted && !change.Revoked {
+			// If client doesn't want removals / revocations, don't send change
+			if !opts.revocations {
+				return nil
+			}
+
+			// If the user has access to the doc through another channel don't send change
+			userHasAccessToDoc, err := UserHasDocAccess(bh.loggingCtx, changesDb, change.ID)
+			if err == nil && userHasAccessToDoc {
+				return nil
+			}
+
+			// If we can't determine user access due to an error, log error and fall through to send change anyway.
+			// In the event of an error we should be cautious and send a revocation anyway, even if the user
+			// may actually have an alternate access method. This is the safer approach security-wise and
+			// also allows for a recovery if the user notices they are missing a doc they should have access
+			// to. A recovery option would be to trigger a mutation of the document for it to be sent in a
+			// subsequent changes request. If we were to avoid sending a removal there is no recovery
+			// option to then trigger that removal later on.
+			if err != nil {
+				base.WarnfCtx(bh.loggingCtx, "Unable to determine whether user has access to %s, will send removal: %v", base.UD(change.ID), err)
+			}
+
+		}
+		for _, item := range change.Changes {
+			changeRow := bh.buildChangesRow(change, item["rev"])
+			pendingChanges = append(pendingChanges, changeRow)
+			if err := sendPendingChangesAt(opts.batchSize); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// With channel priorities, catch up on changes in higher priority channels first, then continue with the changes
+	// feed from the earliest sequence not yet sent for every channel.  Not used with a docID filter, which is
+	// applied by the changes feed.
+	var catchUp *prioritizedCatchUp
+	if len(docIDFilter) == 0 {
+		catchUp = newPrioritizedCatchUp(changesDb, channelSet, opts.priorities, options, opts.batchSize)
+	}
+	if catchUp != nil {
+		base.InfofCtx(bh.loggingCtx, base.KeySync, "Sending changes since %v by channel priority", opts.since)
+		options.Since, err = catchUp.run(bh.loggingCtx, queueChange)
+		if err == nil {
+			err = sendPendingChangesAt(1)
+		}
+		if err != nil {
+			if opts.changesCtx != nil && opts.changesCtx.Err() != nil {
+				return true
+			}
+			base.WarnfCtx(bh.loggingCtx, "[%s] error sending changes by channel priority: %v", bh.blipContext.ID, err)
+			return false
+		}
+	}
+
+	_, forceClose := generateBlipSyncChanges(bh.loggingCtx, changesDb, channelSet, options, docIDFilter, func(changes []*ChangeEntry) error {
 		base.DebugfCtx(bh.loggingCtx, base.KeySync, "    Sending %d changes", len(changes))
 		for _, change := range changes {
-			if !strings.HasPrefix(change.ID, "_") {
-				// If change is a removal and we're running with protocol V3 and change change is not a tombstone
-				// fall into 3.0 removal handling.
-				// Changes with change.Revoked=true have already evaluated UserHasDocAccess in changes.go, don't check again.
-				if change.allRemoved && bh.blipContext.ActiveSubprotocol() == BlipCBMobileReplicationV3 && !change.Deleted && !change.Revoked {
-					// If client doesn't want removals / revocations, don't send change
-					if !opts.revocations {
-						continue
-					}
-
-					// If the user has access to the doc through another channel don't send change
-					userHasAccessToDoc, err := UserHasDocAccess(bh.loggingCtx, changesDb, change.ID)
-					if err == nil && userHasAccessToDoc {
-						continue
-					}
-
-					// If we can't determine user access due to an error, log error and fall through to send change anyway.
-					// In the event of an error we should be cautious and send a revocation anyway, even if the user
-					// may actually have an alternate access method. This is the safer approach security-wise and
-					// also allows for a recovery if the user notices they are missing a doc they should have access
-					// to. A recovery option would be to trigger a mutation of the document for it to be sent in a
-					// subsequent changes request. If we were to avoid sending a removal there is no recovery
-					// option to then trigger that removal later on.
-					if err != nil {
-						base.WarnfCtx(bh.loggingCtx, "Unable to determine whether user has access to %s, will send removal: %v", base.UD(change.ID), err)
-					}
-
-				}
-				for _, item := range change.Changes {
-					changeRow := bh.buildChangesRow(change, item["rev"])
-					pendingChanges = append(pendingChanges, changeRow)
-					if err := sendPendingChangesAt(opts.batchSize); err != nil {
-						return err
-					}
-				}
+			if catchUp.alreadySent(change) {
+				continue
+			}
+			if err := queueChange(change); err != nil {
+				return err
 			}
 		}
 		if caughtUp || len(changes) == 0 {
@@ -514,49 +662,48 @@ func (bh *blipHandler) sendChanges(sender *blip.Sender, opts *sendChangesOptions
 	return !forceClose
 }
 
-func (bh *blipHandler) buildChangesRow(change *ChangeEntry, revID string) []interface{} {
-	var changeRow []interface{}
+func (bh *blipHandler) buildChangesRow(change *ChangeEntry, revID string) changesRow {
+	changeRow := changesRow{seq: change.Seq, docID: change.ID, revID: revID}
 
 	if bh.blipContext.ActiveSubprotocol() == BlipCBMobileReplicationV3 {
-		deletedFlags := changesDeletedFlag(0)
 		if change.Deleted {
-			deletedFlags |= changesDeletedFlagDeleted
+			changeRow.deletedFlags |= changesDeletedFlagDeleted
 		}
 		if change.Revoked {
-			deletedFlags |= changesDeletedFlagRevoked
+			changeRow.deletedFlags |= changesDeletedFlagRevoked
 		}
 		if change.allRemoved {
-			deletedFlags |= changesDeletedFlagRemoved
+			changeRow.deletedFlags |= changesDeletedFlagRemoved
 		}
-
-		changeRow = []interface{}{change.Seq, change.ID, revID, deletedFlags}
-		if deletedFlags == 0 {
-			changeRow = changeRow[0:3]
-		}
-
 	} else {
-		changeRow = []interface{}{change.Seq, change.ID, revID, change.Deleted}
-		if !change.Deleted {
-			changeRow = changeRow[0:3]
+		changeRow.boolDeleted = true
+		if change.Deleted {
+			changeRow.deletedFlags = changesDeletedFlagDeleted
 		}
 	}
 
 	return changeRow
 }
 
-func (bh *blipHandler) sendBatchOfChanges(sender *blip.Sender, changeArray [][]interface{}, ignoreNoConflicts bool) error {
+func (bh *blipHandler) sendBatchOfChanges(sender *blip.Sender, changeArray []changesRow, ignoreNoConflicts bool) error {
 	startTime := time.Now()
 	outrq := blip.NewRequest()
 	outrq.SetProfile("changes")
 	if ignoreNoConflicts {
 		outrq.Properties[ChangesMessageIgnoreNoConflicts] = trueProperty
 	}
+	// Changes pushed by an active replicator aren't sent for a subChanges request, so don't have a collection context
+	if bh.collectionCtx != nil && bh.collectionCtx.idsOnly.IsTrue() {
+		outrq.Properties[ChangesMessageIdsOnly] = trueProperty
+	}
 	if bh.collectionIdx != nil {
 		outrq.Properties[BlipCollection] = strconv.Itoa(*bh.collectionIdx)
 	}
-	err := outrq.SetJSONBody(changeArray)
-	if err != nil {
-		base.InfofCtx(bh.loggingCtx, base.KeyAll, "Error setting changes: %v", err)
+	outrq.SetJSONBodyAsBytes(encodeChangesRows(changeArray))
+
+	// Changes aren't sent while the database is paused
+	if !bh.db.waitWhilePaused(bh.terminator) {
+		return ErrDatabaseWentAway
 	}
 
 	if len(changeArray) > 0 {
@@ -581,7 +728,7 @@ func (bh *blipHandler) sendBatchOfChanges(sender *blip.Sender, changeArray [][]i
 
 		bh.replicationStats.SendChangesCount.Add(int64(len(changeArray)))
 		// Spawn a goroutine to await the client's response:
-		go func(bh *blipHandler, sender *blip.Sender, response *blip.Message, changeArray [][]interface{}, sendTime time.Time, dbCollection *DatabaseCollectionWithUser) {
+		go func(bh *blipHandler, sender *blip.Sender, response *blip.Message, changeArray []changesRow, sendTime time.Time, dbCollection *DatabaseCollectionWithUser) {
 			if err := bh.handleChangesResponse(sender, response, changeArray, sendTime, dbCollection, bh.collectionIdx); err != nil {
 				base.WarnfCtx(bh.loggingCtx, "Error from bh.handleChangesResponse: %v", err)
 				if bh.fatalErrorCallback != nil {
@@ -606,8 +753,7 @@ func (bh *blipHandler) sendBatchOfChanges(sender *blip.Sender, changeArray [][]i
 	}
 
 	if len(changeArray) > 0 {
-		sequence := changeArray[0][0].(SequenceID)
-		base.InfofCtx(bh.loggingCtx, base.KeySync, "Sent %d changes to client, from seq %s", len(changeArray), sequence.String())
+		base.InfofCtx(bh.loggingCtx, base.KeySync, "Sent %d changes to client, from seq %s", len(changeArray), changeArray[0].seq.String())
 	} else {
 		base.InfofCtx(bh.loggingCtx, base.KeySync, "Sent all changes to client")
 	}
@@ -632,6 +778,8 @@ func (bh *blipHandler) handleChanges(rq *blip.Message) error {
 		return err
 	}
 
+	bh.negotiateCumulativeAcks(rq)
+
 	collectionCtx := bh.collectionCtx
 	bh.logEndpointEntry(rq.Profile(), fmt.Sprintf("#Changes:%d", len(changeList)))
 	if len(changeList) == 0 {
@@ -768,10 +916,22 @@ func (bh *blipHandler) handleProposeChanges(rq *blip.Message) error {
 	if err := rq.ReadJSONBody(&changeList); err != nil {
 		return err
 	}
+	bh.negotiateCumulativeAcks(rq)
 	bh.logEndpointEntry(rq.Profile(), fmt.Sprintf("#Changes: %d", len(changeList)))
+
+	// A client reconnecting after a push can present the resume token of its last batch, and skip reproposing it if
+	// none of its documents have changed since
+	if token := rq.Properties[ProposeChangesResumeToken]; token != "" {
+		rq.Response().Properties[ProposeChangesResponseResumeTokenValid] = strconv.FormatBool(bh.isPushResumeTokenValid(bh.loggingCtx, token))
+	}
 	if len(changeList) == 0 {
 		return nil
 	}
+
+	var resumeRevs map[string]string
+	if rq.Properties[ProposeChangesRequestResumeToken] == trueProperty {
+		resumeRevs = make(map[string]string, len(changeList))
+	}
 	output := bytes.NewBuffer(make([]byte, 0, 5*len(changeList)))
 	output.Write([]byte("["))
 	nWritten := 0
@@ -791,6 +951,9 @@ func (bh *blipHandler) handleProposeChanges(rq *blip.Message) error {
 			parentRevID = change[2].(string)
 		}
 		status, currentRev := bh.collection.CheckProposedRev(bh.loggingCtx, docID, revID, parentRevID)
+		if resumeRevs != nil && (status == ProposedRev_OK || status == ProposedRev_OK_IsNew || status == ProposedRev_Exists) {
+			resumeRevs[docID] = revID
+		}
 		if status == ProposedRev_OK_IsNew {
 			// Remember that the doc doesn't exist locally, in order to optimize the upcoming Put:
 			bh.collectionCtx.notePendingInsertion(docID)
@@ -824,11 +987,29 @@ func (bh *blipHandler) handleProposeChanges(rq *blip.Message) error {
 		base.DebugfCtx(bh.loggingCtx, base.KeyAll, "Setting deltas=true property on proposeChanges response")
 		response.Properties[ChangesResponseDeltas] = trueProperty
 	}
+	if len(resumeRevs) > 0 {
+		if token := bh.issuePushResumeToken(resumeRevs); token != "" {
+			response.Properties[ProposeChangesResponseResumeToken] = token
+		}
+	}
 	response.SetCompressed(true)
 	response.SetBody(output.Bytes())
 	return nil
 }
 
+// negotiateCumulativeAcks enables cumulative acknowledgement of pushed revs when requested on a changes or
+// proposeChanges message.  Once enabled, revs sent with noreply are acknowledged in ranges by periodic revAcks
+// messages, and the client can still send a rev without noreply to get an individual response.
+func (bh *blipHandler) negotiateCumulativeAcks(rq *blip.Message) {
+	if rq.Properties[ChangesMessageCumulativeAcks] != trueProperty || rq.Sender == nil {
+		return
+	}
+	bh.enableCumulativeAcks(rq.Sender)
+	if response := rq.Response(); response != nil {
+		response.Properties[ChangesResponseCumulativeAcks] = trueProperty
+	}
+}
+
 // ////// DOCUMENTS:
 
 func (bsc *BlipSyncContext) sendRevAsDelta(sender *blip.Sender, docID, revID string, deltaSrcRevID string, seq SequenceID, knownRevs map[string]bool, maxHistory int, handleChangesResponseCollection *DatabaseCollectionWithUser, collectionIdx *int) error {
@@ -1214,7 +1395,41 @@ func (bh *blipHandler) handleRev(rq *blip.Message) (err error) {
 		processingTime:  bh.replicationStats.HandleRevProcessingTime,
 		docsPurgedCount: bh.replicationStats.HandleRevDocsPurgedCount,
 	}
-	return bh.processRev(rq, &stats)
+
+	// Revs pushed with noreply by a client that negotiated cumulative acks are acknowledged in the next revAcks message
+	if rq.NoReply() {
+		if revAcks := bh.revAcks.Load(); revAcks != nil {
+			defer func() {
+				revAcks.add(rq.SerialNumber(), rq.Properties[RevMessageID], rq.Properties[RevMessageRev], err)
+			}()
+		}
+	}
+
+	// Block while too many revs are pending, which stops reading further messages from the client
+	bodyBytes, err := rq.Body()
+	if err != nil {
+		return err
+	}
+	revSize := int64(len(bodyBytes))
+	throttled, ok := bh.pendingRevs.acquire(revSize, bh.terminator)
+	if throttled > 0 {
+		bh.replicationStats.HandleRevThrottledCount.Add(1)
+		bh.replicationStats.HandleRevThrottledTime.Add(throttled.Nanoseconds())
+	}
+	if !ok {
+		return ErrClosedBLIPSender
+	}
+	defer bh.pendingRevs.release(revSize)
+
+	quotaUsername := quotaUsername(bh.db.User())
+	if err := bh.db.requestQuotas.check(bh.loggingCtx, quotaUsername, QuotaWrites); err != nil {
+		return err
+	}
+	if err := bh.processRev(rq, &stats); err != nil {
+		return err
+	}
+	bh.db.requestQuotas.record(quotaUsername, QuotaWrites)
+	return nil
 }
 
 // ////// ATTACHMENTS:
@@ -1303,6 +1518,31 @@ func (bh *blipHandler) handleGetAttachment(rq *blip.Message) error {
 	return nil
 }
 
+// Received a "getDocAccess" request, for a client troubleshooting why it does or doesn't receive a document.  Only
+// the document's channels and grants visible to the user are returned.
+func (bh *blipHandler) handleGetDocAccess(rq *blip.Message) error {
+	docID := rq.Properties[GetDocAccessID]
+	if docID == "" {
+		return base.HTTPErrorf(http.StatusBadRequest, "Missing 'id'")
+	}
+	bh.logEndpointEntry(rq.Profile(), fmt.Sprintf("doc: %s", base.UD(docID)))
+
+	user := bh.collection.user
+	access, err := bh.collection.GetDocumentAccess(bh.loggingCtx, docID, user)
+	if err != nil {
+		return err
+	}
+	if user != nil {
+		if !access.User.CanSee {
+			return base.HTTPErrorf(http.StatusForbidden, "User doesn't have access to any of the document's channels")
+		}
+		access.filterForUser(user, bh.collection.ScopeName, bh.collection.Name)
+	}
+
+	response := rq.Response()
+	return response.SetJSONBody(access)
+}
+
 var errNoBlipHandler = fmt.Errorf("404 - No handler for BLIP request")
 
 // sendGetAttachment requests the full attachment from the peer.
@@ -1337,8 +1577,10 @@ func (bh *blipHandler) sendGetAttachment(sender *blip.Sender, docID string, name
 	if err != nil {
 		return nil, err
 	}
+	bh.recordMessageReceived(MessageGetAttachment, resp)
 
 	if resp.Properties[BlipErrorCode] != "" {
+		bh.recordReceivedError(MessageGetAttachment, resp, respBody)
 		return nil, fmt.Errorf("error %s from getAttachment: %s", resp.Properties[BlipErrorCode], respBody)
 	}
 	lNum, metaLengthOK := meta["length"]
@@ -1400,6 +1642,11 @@ func (bh *blipHandler) sendProveAttachment(sender *blip.Sender, docID, name, dig
 		base.WarnfCtx(bh.loggingCtx, "Error returned for proveAttachment message for doc %s (digest %s).  Error: %v", base.UD(docID), digest, err)
 		return err
 	}
+	bh.recordMessageReceived(MessageProveAttachment, resp)
+
+	if resp.Type() == blip.ErrorType {
+		bh.recordReceivedError(MessageProveAttachment, resp, body)
+	}
 
 	if resp.Type() == blip.ErrorType &&
 		resp.Properties[BlipErrorDomain] == blip.BLIPErrorDomain &&
@@ -1429,8 +1676,12 @@ func (bh *blipHandler) sendProveAttachment(sender *blip.Sender, docID, name, dig
 func (bh *blipHandler) downloadOrVerifyAttachments(sender *blip.Sender, body Body, minRevpos int, docID string, currentDigests map[string]string) error {
 	return bh.collection.ForEachStubAttachment(body, minRevpos, docID, currentDigests,
 		func(name string, digest string, knownData []byte, meta map[string]interface{}) ([]byte, error) {
-			// Request attachment if we don't have it
+			// Request attachment if we don't have it, unless it isn't allowed by the attachment policy
 			if knownData == nil {
+				length, _ := base.ToInt64(meta["length"])
+				if err := bh.collection.checkAttachmentPolicy(name, meta, length); err != nil {
+					return nil, err
+				}
 				return bh.sendGetAttachment(sender, docID, name, digest, meta)
 			}
 
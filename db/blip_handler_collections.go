@@ -93,6 +93,7 @@ func (bh *blipHandler) handleGetCollections(rq *blip.Message) error {
 		collectionContexts[i] = newBlipSyncCollectionContext(bh.loggingCtx, collection)
 	}
 	bh.collections.set(collectionContexts)
+	bh.recordCollectionsAware()
 	response := rq.Response()
 	if response == nil {
 		return fmt.Errorf("Internal go-blip error generating request response")
@@ -0,0 +1,102 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxPendingRevs is the default maximum number of incoming revs processed concurrently per replication connection
+	DefaultMaxPendingRevs = 1000
+	// DefaultMaxPendingRevBytes is the default maximum total body size of incoming revs processed concurrently per replication connection
+	DefaultMaxPendingRevBytes = 64 * 1024 * 1024
+)
+
+// pendingRevQueue bounds the number and total body size of incoming revs being processed for a replication
+// connection. Once a bound is reached, rev handlers block until earlier revs finish processing, which stops the
+// connection reading further messages until the sync function catches up.
+type pendingRevQueue struct {
+	lock     sync.Mutex
+	maxCount int           // Maximum number of pending revs, 0 for no limit
+	maxBytes int64         // Maximum total body size of pending revs, 0 for no limit
+	count    int           // Number of revs currently pending
+	bytes    int64         // Total body size of revs currently pending
+	released chan struct{} // Closed and replaced whenever a rev is released, to wake waiting handlers
+}
+
+// newPendingRevQueue returns a pendingRevQueue with the given bounds, or nil if neither bound is set.
+func newPendingRevQueue(maxCount int, maxBytes int64) *pendingRevQueue {
+	if maxCount <= 0 && maxBytes <= 0 {
+		return nil
+	}
+	return &pendingRevQueue{
+		maxCount: maxCount,
+		maxBytes: maxBytes,
+		released: make(chan struct{}),
+	}
+}
+
+// acquire adds a rev of the given size to the queue, blocking until there is room for it or terminator is closed.
+// A rev larger than maxBytes is admitted once the queue is empty. Returns the time spent blocked, and false if the
+// queue was terminated before the rev was added.
+func (q *pendingRevQueue) acquire(size int64, terminator chan bool) (throttled time.Duration, ok bool) {
+	if q == nil {
+		return 0, true
+	}
+	var waitStart time.Time
+	for {
+		q.lock.Lock()
+		if q.hasRoom(size) {
+			q.count++
+			q.bytes += size
+			q.lock.Unlock()
+			if !waitStart.IsZero() {
+				throttled = time.Since(waitStart)
+			}
+			return throttled, true
+		}
+		released := q.released
+		q.lock.Unlock()
+
+		if waitStart.IsZero() {
+			waitStart = time.Now()
+		}
+		select {
+		case <-released:
+		case <-terminator:
+			return time.Since(waitStart), false
+		}
+	}
+}
+
+// release removes a rev previously added with acquire from the queue.
+func (q *pendingRevQueue) release(size int64) {
+	if q == nil {
+		return
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.count--
+	q.bytes -= size
+	close(q.released)
+	q.released = make(chan struct{})
+}
+
+// hasRoom returns true if a rev of the given size can be added to the queue. Requires q.lock.
+func (q *pendingRevQueue) hasRoom(size int64) bool {
+	if q.count == 0 {
+		return true
+	}
+	if q.maxCount > 0 && q.count >= q.maxCount {
+		return false
+	}
+	return q.maxBytes <= 0 || q.bytes+size <= q.maxBytes
+}
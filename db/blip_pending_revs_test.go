@@ -0,0 +1,80 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPendingRevQueue(t *testing.T) {
+	assert.Nil(t, newPendingRevQueue(0, 0))
+
+	// A nil queue never blocks
+	var disabled *pendingRevQueue
+	throttled, ok := disabled.acquire(100, nil)
+	assert.True(t, ok)
+	assert.Zero(t, throttled)
+	disabled.release(100)
+
+	testCases := []struct {
+		name     string
+		maxCount int
+		maxBytes int64
+		sizes    []int64 // Sizes of revs that fit in the queue, the next rev of size 10 should block
+	}{
+		{name: "count", maxCount: 2, sizes: []int64{10, 10}},
+		{name: "bytes", maxBytes: 25, sizes: []int64{10, 10}},
+		{name: "oversized", maxBytes: 5, sizes: []int64{10}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			queue := newPendingRevQueue(tc.maxCount, tc.maxBytes)
+			terminator := make(chan bool)
+			for _, size := range tc.sizes {
+				_, ok := queue.acquire(size, terminator)
+				require.True(t, ok)
+			}
+
+			acquired := make(chan time.Duration)
+			go func() {
+				throttled, ok := queue.acquire(10, terminator)
+				assert.True(t, ok)
+				acquired <- throttled
+			}()
+
+			select {
+			case <-acquired:
+				require.FailNow(t, "rev acquired while queue was full")
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			queue.release(tc.sizes[0])
+			select {
+			case throttled := <-acquired:
+				assert.Greater(t, throttled, time.Duration(0))
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "rev not acquired after release")
+			}
+		})
+	}
+
+	t.Run("terminated", func(t *testing.T) {
+		queue := newPendingRevQueue(1, 0)
+		terminator := make(chan bool)
+		_, ok := queue.acquire(10, terminator)
+		require.True(t, ok)
+		close(terminator)
+		_, ok = queue.acquire(10, terminator)
+		assert.False(t, ok)
+	})
+}
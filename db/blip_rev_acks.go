@@ -0,0 +1,134 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/couchbase/go-blip"
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// revAckFlushInterval is how often pending acknowledgements of pushed revs are sent to the client.
+var revAckFlushInterval = 250 * time.Millisecond
+
+// maxPendingRevAcks is the number of pending acknowledgements that triggers a revAcks message before the flush
+// interval has elapsed.
+const maxPendingRevAcks = 1000
+
+// RevAcksBody is the body of a revAcks message, acknowledging the rev messages pushed by the client with noreply set
+// since the previous revAcks message.
+type RevAcksBody struct {
+	Acked  [][2]blip.MessageNumber `json:"acked,omitempty"`  // Inclusive ranges of the message numbers of revs that were saved
+	Errors []RevAckError           `json:"errors,omitempty"` // Revs that failed, which the client can resend to get an individual response
+}
+
+// RevAckError reports a pushed rev that couldn't be saved.
+type RevAckError struct {
+	Message blip.MessageNumber `json:"msg"`
+	DocID   string             `json:"id"`
+	RevID   string             `json:"rev"`
+	Status  int                `json:"status"`
+	Error   string             `json:"error"`
+}
+
+// revAckBatcher collects the outcome of revs pushed with noreply set by a client that has negotiated cumulative acks,
+// and periodically sends them to the client in a single revAcks message, rather than a response per rev.
+type revAckBatcher struct {
+	bsc    *BlipSyncContext
+	sender *blip.Sender
+	lock   sync.Mutex
+	acked  []blip.MessageNumber
+	errors []RevAckError
+}
+
+// enableCumulativeAcks starts acknowledging noreply revs with revAcks messages sent to sender, if not already started.
+func (bsc *BlipSyncContext) enableCumulativeAcks(sender *blip.Sender) {
+	batcher := &revAckBatcher{bsc: bsc, sender: sender}
+	if !bsc.revAcks.CompareAndSwap(nil, batcher) {
+		return
+	}
+	base.DebugfCtx(bsc.loggingCtx, base.KeySync, "Enabled cumulative acknowledgement of pushed revs")
+	go batcher.run()
+}
+
+// add records the outcome of processing a rev message.
+func (b *revAckBatcher) add(number blip.MessageNumber, docID, revID string, err error) {
+	b.lock.Lock()
+	if err == nil {
+		b.acked = append(b.acked, number)
+	} else {
+		status, msg := base.ErrorAsHTTPStatus(err)
+		b.errors = append(b.errors, RevAckError{Message: number, DocID: docID, RevID: revID, Status: status, Error: msg})
+	}
+	full := len(b.acked)+len(b.errors) >= maxPendingRevAcks
+	b.lock.Unlock()
+	if full {
+		b.flush()
+	}
+}
+
+// run sends pending acknowledgements every revAckFlushInterval until the connection is closed.
+func (b *revAckBatcher) run() {
+	ticker := time.NewTicker(revAckFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.flush()
+		case <-b.bsc.terminator:
+			b.flush()
+			return
+		}
+	}
+}
+
+// takePending returns the pending acknowledgements and resets them, or nil if there are none.  Acknowledged message
+// numbers are merged into ranges, since revs can finish processing out of order.
+func (b *revAckBatcher) takePending() *RevAcksBody {
+	b.lock.Lock()
+	acked, errors := b.acked, b.errors
+	b.acked, b.errors = nil, nil
+	b.lock.Unlock()
+	if len(acked) == 0 && len(errors) == 0 {
+		return nil
+	}
+
+	body := &RevAcksBody{Errors: errors}
+	sort.Slice(acked, func(i, j int) bool { return acked[i] < acked[j] })
+	for _, number := range acked {
+		if last := len(body.Acked) - 1; last >= 0 && body.Acked[last][1]+1 == number {
+			body.Acked[last][1] = number
+		} else {
+			body.Acked = append(body.Acked, [2]blip.MessageNumber{number, number})
+		}
+	}
+	sort.Slice(body.Errors, func(i, j int) bool { return body.Errors[i].Message < body.Errors[j].Message })
+	return body
+}
+
+// flush sends a revAcks message with the pending acknowledgements, if any.
+func (b *revAckBatcher) flush() {
+	body := b.takePending()
+	if body == nil {
+		return
+	}
+	msg := blip.NewRequest()
+	msg.SetProfile(MessageRevAcks)
+	msg.SetNoReply(true)
+	if err := msg.SetJSONBody(body); err != nil {
+		base.WarnfCtx(b.bsc.loggingCtx, "Unable to marshal revAcks message: %v", err)
+		return
+	}
+	if !b.bsc.sendBLIPMessage(b.sender, msg) {
+		base.DebugfCtx(b.bsc.loggingCtx, base.KeySync, "Unable to send revAcks message, connection already closed")
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/couchbase/go-blip"
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevAckBatcherTakePending(t *testing.T) {
+	batcher := &revAckBatcher{}
+	assert.Nil(t, batcher.takePending())
+
+	// Revs finishing out of order are merged into contiguous ranges, split by the failed rev
+	for _, number := range []blip.MessageNumber{3, 1, 2, 5, 7, 6} {
+		batcher.add(number, "doc", "1-a", nil)
+	}
+	batcher.add(4, "doc4", "2-b", base.HTTPErrorf(http.StatusConflict, "Document revision conflict"))
+
+	body := batcher.takePending()
+	require.NotNil(t, body)
+	assert.Equal(t, [][2]blip.MessageNumber{{1, 3}, {5, 7}}, body.Acked)
+	assert.Equal(t, []RevAckError{{Message: 4, DocID: "doc4", RevID: "2-b", Status: http.StatusConflict, Error: "Document revision conflict"}}, body.Errors)
+
+	// Acknowledgements are only sent once
+	assert.Nil(t, batcher.takePending())
+}
@@ -30,6 +30,10 @@ const (
 	// Blip default vals
 	BlipDefaultBatchSize = uint64(200)
 	BlipMinimumBatchSize = uint64(10) // Not in the replication spec - is this required?
+
+	// DefaultDeferredAttachmentWindow is how long a client that asked to defer attachments can get a revision's
+	// attachments for after acknowledging it, if the database doesn't set a DeferredAttachmentWindow.
+	DefaultDeferredAttachmentWindow = 10 * time.Minute
 )
 
 var ErrClosedBLIPSender = errors.New("use of closed BLIP sender")
@@ -44,12 +48,14 @@ func NewBlipSyncContext(ctx context.Context, bc *blip.Context, db *Database, con
 		sgCanUseDeltas:          db.DeltaSyncEnabled(),
 		replicationStats:        replicationStats,
 		inFlightChangesThrottle: make(chan struct{}, maxInFlightChangesBatches),
+		pendingRevs:             newPendingRevQueue(db.Options.MaxPendingRevs, db.Options.MaxPendingRevBytes),
 		collections:             &blipCollections{},
 	}
 	if bsc.replicationStats == nil {
 		bsc.replicationStats = NewBlipSyncStats()
 	}
 	bsc.stats.lastReportTime.Store(time.Now().UnixMilli())
+	bsc.capabilities.connectedAt = time.Now()
 
 	if u := db.User(); u != nil {
 		bsc.userName = u.Name()
@@ -108,6 +114,10 @@ type BlipSyncContext struct {
 	// without making Sync Gateway buffer a bunch of stuff in memory too far in advance of the client being able to receive the revs.
 	inFlightChangesThrottle chan struct{}
 
+	// pendingRevs bounds the number and size of incoming revs being processed, to stop a client pushing revs faster
+	// than the sync function can process them from growing memory without bound.
+	pendingRevs *pendingRevQueue
+
 	// fatalErrorCallback is called by the replicator code when the replicator using this blipSyncContext should be
 	// stopped
 	fatalErrorCallback func(err error)
@@ -118,6 +128,18 @@ type BlipSyncContext struct {
 	collections *blipCollections // all collections handled by blipSyncContext, implicit or via GetCollections
 
 	stats blipSyncStats // internal structure to store stats
+
+	sender           atomic.Pointer[blip.Sender] // Sender for the connection, used to send unsolicited messages such as goAway
+	inFlightHandlers atomic.Int64                // Number of BLIP request handlers currently running
+	draining         atomic.Bool                 // Set once goAway has been sent, new subChanges requests are rejected
+
+	capabilities blipClientCapabilities // Protocol features negotiated by the client
+
+	revAcks atomic.Pointer[revAckBatcher] // Set once the client has negotiated cumulative acknowledgement of pushed revs
+
+	usage blipConnectionUsage // Messages sent and received by the connection, by profile
+
+	correlationID string // If set, sent as the BlipCorrelationID property of messages sent to the client
 }
 
 // blipSyncStats has support structures to support reporting stats at regular interval
@@ -136,6 +158,13 @@ type AllowedAttachment struct {
 	docID   string // docID, used for BlipCBMobileReplicationV2 retrieval of V2 attachments
 }
 
+// SetCorrelationID sets the correlation ID of the request that opened the connection, which is sent as a property of
+// messages sent to the client so they can be correlated with Sync Gateway's logs. Must be set before any messages are
+// handled.
+func (bsc *BlipSyncContext) SetCorrelationID(correlationID string) {
+	bsc.correlationID = correlationID
+}
+
 func (bsc *BlipSyncContext) SetClientType(clientType BLIPSyncContextClientType) {
 	bsc.clientType = clientType
 }
@@ -148,6 +177,14 @@ func (bsc *BlipSyncContext) register(profile string, handlerFn func(*blipHandler
 	handlerFnWrapper := func(rq *blip.Message) {
 		startTime := time.Now()
 
+		if rq.Sender != nil {
+			bsc.sender.CompareAndSwap(nil, rq.Sender)
+		}
+		bsc.inFlightHandlers.Add(1)
+		defer bsc.inFlightHandlers.Add(-1)
+		bsc.recordRequestCapabilities(rq)
+		bsc.recordMessageReceived(profile, rq)
+
 		// Recover to log panic from handlers and repanic for go-blip response handling
 		defer func() {
 			if err := recover(); err != nil {
@@ -158,6 +195,7 @@ func (bsc *BlipSyncContext) register(profile string, handlerFn func(*blipHandler
 					base.InfofCtx(bsc.loggingCtx, base.KeySync, "Database bucket closed underneath request %v - asking client to reconnect", rq)
 					// HTTP 503 asks CBL to disconnect and retry.
 					rq.Response().SetError("HTTP", ErrDatabaseWentAway.Status, ErrDatabaseWentAway.Message)
+					bsc.recordSentError(profile, ErrDatabaseWentAway.Status, ErrDatabaseWentAway.Message)
 					return
 				}
 
@@ -187,12 +225,15 @@ func (bsc *BlipSyncContext) register(profile string, handlerFn func(*blipHandler
 				base.DebugfCtx(bsc.loggingCtx, base.KeySyncMsg, "#%d: Type:%s   --> no existing checkpoint for client Time:%v", handler.serialNumber, profile, time.Since(startTime))
 			} else {
 				base.InfofCtx(bsc.loggingCtx, base.KeySyncMsg, "#%d: Type:%s   --> %d %s Time:%v", handler.serialNumber, profile, status, msg, time.Since(startTime))
+				bsc.recordSentError(profile, status, msg)
 			}
 		} else if profile != MessageSubChanges {
 			// Log the fact that the handler has finished, except for the "subChanges" special case which does it's own termination related logging
 			base.DebugfCtx(bsc.loggingCtx, base.KeySyncMsg, "#%d: Type:%s   --> OK Time:%v", handler.serialNumber, profile, time.Since(startTime))
 		}
 
+		bsc.recordMessageSent(profile, rq.Response())
+
 		// Trace log the full response body and properties
 		if base.LogTraceEnabled(bsc.loggingCtx, base.KeySyncMsg) {
 			resp := rq.Response()
@@ -210,6 +251,21 @@ func (bsc *BlipSyncContext) register(profile string, handlerFn func(*blipHandler
 
 }
 
+// recordSentError counts an error response returned for a client's request.
+func (bsc *BlipSyncContext) recordSentError(profile string, status int, reason string) {
+	if errorStats := bsc.blipContextDb.DbStats.CBLReplicationErrors(); errorStats != nil {
+		errorStats.Add(base.BLIPErrorSent, profile, "HTTP", status, reason)
+	}
+}
+
+// recordReceivedError counts an error response returned by the client for one of Sync Gateway's requests.
+func (bsc *BlipSyncContext) recordReceivedError(profile string, response *blip.Message, body []byte) {
+	if errorStats := bsc.blipContextDb.DbStats.CBLReplicationErrors(); errorStats != nil {
+		status, _ := strconv.Atoi(response.Properties[BlipErrorCode])
+		errorStats.Add(base.BLIPErrorReceived, profile, response.Properties[BlipErrorDomain], status, string(body))
+	}
+}
+
 func (bsc *BlipSyncContext) Close() {
 	bsc.terminatorOnce.Do(func() {
 		for _, collection := range bsc.collections.getAll() {
@@ -222,12 +278,70 @@ func (bsc *BlipSyncContext) Close() {
 			defer collection.changesCtxLock.Unlock()
 
 			collection.changesCtxCancel()
+			collection.clearPendingInsertions()
 		}
 		bsc.reportStats(true)
+		bsc.releaseCapabilities()
 		close(bsc.terminator)
 	})
 }
 
+// drainPollInterval is how often Drain checks whether in-flight work has completed.
+const drainPollInterval = 50 * time.Millisecond
+
+// Drain prepares the connection for shutdown. It stops sending new changes to the client, sends a goAway
+// message asking the client to disconnect and reconnect after reconnectAfter, then waits until in-flight
+// handlers and pending changes batches have completed or ctx is done. Returns false if work was still in
+// flight when ctx was done.
+func (bsc *BlipSyncContext) Drain(ctx context.Context, reconnectAfter time.Duration) bool {
+	if !bsc.draining.CompareAndSwap(false, true) {
+		return bsc.waitForInFlight(ctx)
+	}
+
+	for _, collection := range bsc.collections.getAll() {
+		if collection == nil {
+			continue
+		}
+		collection.changesCtxLock.Lock()
+		collection.changesCtxCancel()
+		collection.changesCtxLock.Unlock()
+	}
+
+	if sender := bsc.sender.Load(); sender != nil {
+		goAwayRq := NewGoAwayMessage(reconnectAfter)
+		if !bsc.sendBLIPMessage(sender, goAwayRq.Message) {
+			base.DebugfCtx(bsc.loggingCtx, base.KeySync, "Unable to send goAway message, connection already closed")
+		}
+	}
+
+	return bsc.waitForInFlight(ctx)
+}
+
+// waitForInFlight blocks until no handlers or changes batches are in flight, or ctx is done.
+func (bsc *BlipSyncContext) waitForInFlight(ctx context.Context) bool {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		if bsc.inFlightHandlers.Load() == 0 && atomic.LoadInt64(&bsc.changesPendingResponseCount) == 0 {
+			return true
+		}
+		select {
+		case <-ticker.C:
+		case <-bsc.terminator:
+			return true
+		case <-ctx.Done():
+			base.InfofCtx(bsc.loggingCtx, base.KeySync, "Drain deadline reached with %d handlers and %d changes batches in flight",
+				bsc.inFlightHandlers.Load(), atomic.LoadInt64(&bsc.changesPendingResponseCount))
+			return false
+		}
+	}
+}
+
+// IsDraining returns true once Drain has been called on the connection.
+func (bsc *BlipSyncContext) IsDraining() bool {
+	return bsc.draining.Load()
+}
+
 // NotFoundHandler is used for unknown requests
 func (bsc *BlipSyncContext) NotFoundHandler(rq *blip.Message) {
 	base.InfofCtx(bsc.loggingCtx, base.KeySync, "%s Type:%q", rq, rq.Profile())
@@ -250,7 +364,7 @@ func (bsc *BlipSyncContext) copyDatabaseCollectionWithUser(collectionIdx *int) (
 	if err != nil {
 		return nil, err
 	}
-	return &DatabaseCollectionWithUser{DatabaseCollection: collectionCtx.dbCollection, user: user}, nil
+	return &DatabaseCollectionWithUser{DatabaseCollection: collectionCtx.dbCollection, user: user, channelMapper: collectionCtx.getChannelMapper()}, nil
 }
 
 func (bsc *BlipSyncContext) _copyContextDatabase() *Database {
@@ -259,7 +373,7 @@ func (bsc *BlipSyncContext) _copyContextDatabase() *Database {
 }
 
 // Handles the response to a pushed "changes" message, i.e. the list of revisions the client wants
-func (bsc *BlipSyncContext) handleChangesResponse(sender *blip.Sender, response *blip.Message, changeArray [][]interface{}, requestSent time.Time, handleChangesResponseDbCollection *DatabaseCollectionWithUser, collectionIdx *int) error {
+func (bsc *BlipSyncContext) handleChangesResponse(sender *blip.Sender, response *blip.Message, changeArray []changesRow, requestSent time.Time, handleChangesResponseDbCollection *DatabaseCollectionWithUser, collectionIdx *int) error {
 	defer func() {
 		if panicked := recover(); panicked != nil {
 			bsc.replicationStats.NumHandlersPanicked.Add(1)
@@ -272,8 +386,10 @@ func (bsc *BlipSyncContext) handleChangesResponse(sender *blip.Sender, response
 		base.ErrorfCtx(bsc.loggingCtx, "Couldn't get body for 'changes' response message: %s -- %s", response, err)
 		return err
 	}
+	bsc.recordMessageReceived(MessageChanges, response)
 
 	if response.Type() == blip.ErrorType {
+		bsc.recordReceivedError(MessageChanges, response, respBody)
 		return fmt.Errorf("Client returned error in changesResponse: %s", respBody)
 	}
 
@@ -294,7 +410,11 @@ func (bsc *BlipSyncContext) handleChangesResponse(sender *blip.Sender, response
 	maxHistory := 0
 	if max, err := strconv.ParseUint(response.Properties[ChangesResponseMaxHistory], 10, 64); err == nil {
 		maxHistory = int(max)
+	} else if collectionCtx, err := bsc.collections.get(collectionIdx); err == nil {
+		// Fall back to the max history requested by the client on subChanges
+		maxHistory = int(collectionCtx.maxHistory.Value())
 	}
+	maxHistory = bsc.effectiveMaxHistory(maxHistory)
 
 	// Set useDeltas if the client has delta support and has it enabled
 	if clientDeltasStr, ok := response.Properties[ChangesResponseDeltas]; ok {
@@ -318,10 +438,16 @@ func (bsc *BlipSyncContext) handleChangesResponse(sender *blip.Sender, response
 		return err
 	}
 
+	// A client replicating ids only gets the revisions it wants with getRev, so isn't sent any in response
+	if collectionCtx.idsOnly.IsTrue() {
+		base.DebugfCtx(bsc.loggingCtx, base.KeySync, "Not sending revisions for %d changes to client replicating ids only", len(answer))
+		answer = nil
+	}
+
 	for i, knownRevsArrayInterface := range answer {
-		seq := changeArray[i][0].(SequenceID)
-		docID := changeArray[i][1].(string)
-		revID := changeArray[i][2].(string)
+		seq := changeArray[i].seq
+		docID := changeArray[i].docID
+		revID := changeArray[i].revID
 
 		if knownRevsArray, ok := knownRevsArrayInterface.([]interface{}); ok {
 			deltaSrcRevID := ""
@@ -348,10 +474,19 @@ func (bsc *BlipSyncContext) handleChangesResponse(sender *blip.Sender, response
 			}
 
 			var err error
-			if deltaSrcRevID != "" {
-				err = bsc.sendRevAsDelta(sender, docID, revID, deltaSrcRevID, seq, knownRevs, maxHistory, handleChangesResponseDbCollection, collectionIdx)
+			quotaUsername := quotaUsername(handleChangesResponseDbCollection.user)
+			if quotaErr := bsc.blipContextDb.requestQuotas.check(bsc.loggingCtx, quotaUsername, QuotaReads); quotaErr != nil {
+				err = bsc.sendNoRev(sender, docID, revID, collectionIdx, seq, quotaErr)
 			} else {
-				err = bsc.sendRevision(sender, docID, revID, seq, knownRevs, maxHistory, handleChangesResponseDbCollection, collectionIdx)
+				if deltaSrcRevID != "" {
+					err = bsc.sendRevAsDelta(sender, docID, revID, deltaSrcRevID, seq, knownRevs, maxHistory, handleChangesResponseDbCollection, collectionIdx)
+				} else {
+					err = bsc.sendRevision(sender, docID, revID, seq, knownRevs, maxHistory, handleChangesResponseDbCollection, collectionIdx)
+				}
+				// Only revisions actually sent count towards the quota
+				if err == nil {
+					bsc.blipContextDb.requestQuotas.record(quotaUsername, QuotaReads)
+				}
 			}
 			if err != nil {
 				return err
@@ -452,11 +587,13 @@ func (bsc *BlipSyncContext) sendRevisionWithProperties(sender *blip.Sender, docI
 			if err != nil {
 				base.WarnfCtx(bsc.loggingCtx, "couldn't get response body for rev: %v", err)
 			}
+			bsc.recordMessageReceived(MessageRev, resp)
 
 			base.TracefCtx(bsc.loggingCtx, base.KeySync, "Received response for sendRevisionWithProperties rev message %s/%s", base.UD(docID), revID)
 
 			if resp.Type() == blip.ErrorType {
 				bsc.replicationStats.SendRevErrorTotal.Add(1)
+				bsc.recordReceivedError(MessageRev, resp, respBody)
 				base.InfofCtx(bsc.loggingCtx, base.KeySync, "error %s in response to rev: %s", resp.Properties["Error-Code"], respBody)
 
 				if errorDomainIsHTTP(resp) {
@@ -486,7 +623,14 @@ func (bsc *BlipSyncContext) sendRevisionWithProperties(sender *blip.Sender, docI
 				bsc.replicationStats.SendRevCount.Add(1)
 			}
 
-			bsc.removeAllowedAttachments(docID, attMeta, activeSubprotocol)
+			if collectionCtx.deferAttachments.IsTrue() && len(attMeta) > 0 {
+				// Client is pulling attachments on demand, so keeps being allowed them for a while after the rev
+				time.AfterFunc(bsc.deferredAttachmentWindow(), func() {
+					bsc.removeAllowedAttachments(docID, attMeta, activeSubprotocol)
+				})
+			} else {
+				bsc.removeAllowedAttachments(docID, attMeta, activeSubprotocol)
+			}
 
 			if collectionCtx.sgr2PushProcessedSeqCallback != nil {
 				collectionCtx.sgr2PushProcessedSeqCallback(seq)
@@ -520,6 +664,7 @@ func (bsc *BlipSyncContext) setUseDeltas(clientCanUseDeltas bool) {
 		base.DebugfCtx(bsc.loggingCtx, base.KeySync, "Enabling deltas for this replication")
 		bsc.replicationStats.DeltaEnabledPullReplicationCount.Add(1)
 		bsc.useDeltas = true
+		bsc.recordDeltasEnabled()
 		return
 	}
 
@@ -552,7 +697,13 @@ func (bsc *BlipSyncContext) sendDelta(sender *blip.Sender, docID string, collect
 
 // sendBLIPMessage is a simple wrapper around all sent BLIP messages
 func (bsc *BlipSyncContext) sendBLIPMessage(sender *blip.Sender, msg *blip.Message) bool {
+	if bsc.correlationID != "" {
+		msg.Properties[BlipCorrelationID] = bsc.correlationID
+	}
 	ok := sender.Send(msg)
+	if ok {
+		bsc.recordMessageSent(msg.Profile(), msg)
+	}
 	if base.LogTraceEnabled(bsc.loggingCtx, base.KeySyncMsg) {
 		rqBody, _ := msg.Body()
 		base.TracefCtx(bsc.loggingCtx, base.KeySyncMsg, "Sent Req %s: Body: '%s' Properties: %v", msg, base.UD(rqBody), base.UD(msg.Properties))
@@ -656,6 +807,25 @@ func digests(meta []AttachmentStorageMeta) []string {
 	return digests
 }
 
+// deferredAttachmentWindow returns how long a client deferring attachments can get a revision's attachments for
+// after acknowledging it.
+func (bsc *BlipSyncContext) deferredAttachmentWindow() time.Duration {
+	if window := bsc.blipContextDb.Options.DeferredAttachmentWindow; window > 0 {
+		return window
+	}
+	return DefaultDeferredAttachmentWindow
+}
+
+// effectiveMaxHistory returns the max history length to send in rev messages, given the max history requested by the
+// client (0 if none was requested), capped by the database's MaxRevMessageHistory.
+func (bsc *BlipSyncContext) effectiveMaxHistory(requested int) int {
+	limit := int(bsc.blipContextDb.Options.MaxRevMessageHistory)
+	if limit > 0 && (requested == 0 || requested > limit) {
+		return limit
+	}
+	return requested
+}
+
 func toHistory(revisions Revisions, knownRevs map[string]bool, maxHistory int) []string {
 	// Get the revision's history as a descending array of ancestor revIDs:
 	history := revisions.ParseRevisions()[1:]
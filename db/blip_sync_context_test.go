@@ -101,3 +101,28 @@ func BenchmarkBlipSyncContextSetUseDeltas(b *testing.B) {
 		})
 	}
 }
+
+// TestBlipSyncContextEffectiveMaxHistory verifies the client requested max history is capped by MaxRevMessageHistory.
+func TestBlipSyncContextEffectiveMaxHistory(t *testing.T) {
+	tests := []struct {
+		name               string
+		serverLimit        uint32
+		requested          int
+		expectedMaxHistory int
+	}{
+		{"no limit, not requested", 0, 0, 0},
+		{"no limit, requested", 0, 20, 20},
+		{"limit, not requested", 50, 0, 50},
+		{"limit, requested below limit", 50, 20, 20},
+		{"limit, requested above limit", 50, 100, 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &BlipSyncContext{
+				blipContextDb: &Database{DatabaseContext: &DatabaseContext{Options: DatabaseContextOptions{MaxRevMessageHistory: tt.serverLimit}}},
+			}
+			assert.Equal(t, tt.expectedMaxHistory, ctx.effectiveMaxHistory(tt.requested))
+		})
+	}
+}
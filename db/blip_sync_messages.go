@@ -17,6 +17,7 @@ import (
 	"math"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/couchbase/go-blip"
 	"github.com/couchbase/sync_gateway/base"
@@ -25,16 +26,20 @@ import (
 
 // Message types
 const (
-	MessageSetCheckpoint   = "setCheckpoint"
-	MessageGetCheckpoint   = "getCheckpoint"
-	MessageSubChanges      = "subChanges"
-	MessageChanges         = "changes"
-	MessageRev             = "rev"
-	MessageNoRev           = "norev"
-	MessageGetAttachment   = "getAttachment"
-	MessageProposeChanges  = "proposeChanges"
-	MessageProveAttachment = "proveAttachment"
-	MessageGetCollections  = "getCollections"
+	MessageSetCheckpoint    = "setCheckpoint"
+	MessageGetCheckpoint    = "getCheckpoint"
+	MessageSubChanges       = "subChanges"
+	MessageChanges          = "changes"
+	MessageRev              = "rev"
+	MessageNoRev            = "norev"
+	MessageGetAttachment    = "getAttachment"
+	MessageProposeChanges   = "proposeChanges"
+	MessageProveAttachment  = "proveAttachment"
+	MessageGetCollections   = "getCollections"
+	MessageGoAway           = "goAway"
+	MessageUpdateSubChanges = "updateSubChanges"
+	MessageRevAcks          = "revAcks"
+	MessageGetDocAccess     = "getDocAccess"
 
 	MessageGetRev       = "getRev"       // Connected Client API
 	MessagePutRev       = "putRev"       // Connected Client API
@@ -51,6 +56,12 @@ const (
 	BlipCompress = "compress"
 	BlipProfile  = "Profile"
 
+	// Optional client app metadata, e.g. the app name and version.  Recorded from the first request that sets it.
+	BlipClientApp = "clientApp"
+
+	// Correlation ID of the request that opened the connection, set on messages sent by Sync Gateway.
+	BlipCorrelationID = "correlationID"
+
 	// setCheckpoint message properties
 	SetCheckpointRev         = "rev"
 	SetCheckpointClient      = "client"
@@ -70,6 +81,14 @@ const (
 	SubChangesRevocations = "revocations"
 	SubChangesRequestPlus = "requestPlus"
 	SubChangesFuture      = "future"
+	SubChangesStyle       = "style"      // Set to SubChangesStyleAllDocs to send all leaf revisions of conflicted documents. Requires protocol V3.
+	SubChangesMaxHistory  = "maxHistory" // Maximum number of ancestor revIDs the client wants in the history of rev messages. Capped by the server.
+	SubChangesIdsOnly     = "idsOnly"    // Set to true to be sent changes without being sent revisions. The client gets revisions it wants with getRev.
+
+	SubChangesDeferAttachments = "deferAttachments" // Set to true to be allowed to get a sent revision's attachments with getAttachment after acknowledging the rev.
+
+	// subChanges style property values
+	SubChangesStyleAllDocs = "all_docs"
 
 	// rev message properties
 	RevMessageID          = "id"
@@ -88,23 +107,33 @@ const (
 	NorevMessageError    = "error"
 	NorevMessageReason   = "reason"
 
-	// getRev (Connected Client) message properties
+	// getRev message properties
 	GetRevMessageId = "id"
-	GetRevRevId     = "rev"
+	GetRevRevId     = "rev" // On the request, an optional revision to get rather than the current revision
 	GetRevIfNotRev  = "ifNotRev"
 
 	// changes message properties
 	ChangesMessageIgnoreNoConflicts = "ignoreNoConflicts"
+	ChangesMessageIdsOnly           = "idsOnly"        // Set when revisions won't be sent for the changes, as requested on subChanges
+	ChangesMessageCumulativeAcks    = "cumulativeAcks" // Set on changes or proposeChanges to request cumulative acknowledgement of noreply revs
 
 	// changes response properties
-	ChangesResponseMaxHistory = "maxHistory"
-	ChangesResponseDeltas     = "deltas"
+	ChangesResponseMaxHistory     = "maxHistory"
+	ChangesResponseDeltas         = "deltas"
+	ChangesResponseCumulativeAcks = "cumulativeAcks" // Set when noreply revs will be acknowledged with revAcks messages
 
 	// proposeChanges message properties
 	ProposeChangesConflictsIncludeRev = "conflictIncludesRev"
+	ProposeChangesRequestResumeToken  = "requestResumeToken" // Set to request a resumeToken for the proposed changes
+	ProposeChangesResumeToken         = "resumeToken"        // Token from an earlier response, to check whether its changes need reproposing
 
 	// proposeChanges response message properties
-	ProposeChangesResponseDeltas = "deltas"
+	ProposeChangesResponseDeltas           = "deltas"
+	ProposeChangesResponseResumeToken      = "resumeToken"      // Token identifying the proposed changes that weren't rejected
+	ProposeChangesResponseResumeTokenValid = "resumeTokenValid" // Whether every change of the presented resumeToken is still current
+
+	// getDocAccess message properties
+	GetDocAccessID = "id"
 
 	// getAttachment message properties
 	GetAttachmentID     = "docID"
@@ -125,6 +154,9 @@ const (
 	SGShowHandler = "sgShowHandler" // Used to request a response with sgHandler
 	SGHandler     = "sgHandler"     // Used to show which handler processed the message
 
+	// goAway message properties
+	GoAwayReconnectAfter = "reconnectAfter" // Number of seconds the client should wait before reconnecting
+
 	// collection specification
 	BlipCollection = "collection"
 
@@ -146,13 +178,30 @@ type LatestSequenceFunc func() (SequenceID, error)
 
 // SubChangesParams is a helper for handling BLIP subChanges requests.  Supports Stringer() interface to log aspects of the request.
 type SubChangesParams struct {
-	rq      *blip.Message // The underlying BLIP message
-	_since  SequenceID    // Since value on the incoming request
-	_docIDs []string      // Document ID filter specified on the incoming request
+	rq          *blip.Message  // The underlying BLIP message
+	_since      SequenceID     // Since value on the incoming request
+	_docIDs     []string       // Document ID filter specified on the incoming request
+	_priorities map[string]int // Channel priorities specified on the incoming request
 }
 
 type SubChangesBody struct {
-	DocIDs []string `json:"docIDs"`
+	DocIDs     []string       `json:"docIDs"`
+	Priorities map[string]int `json:"priorities,omitempty"` // Relative priority of channels during catch-up, higher first. Unlisted channels have priority 1.
+}
+
+// UpdateSubChangesBody is the body of an updateSubChanges message, which modifies the filters of an active continuous
+// subChanges.
+type UpdateSubChangesBody struct {
+	AddDocIDs      []string `json:"addDocIDs,omitempty"`
+	RemoveDocIDs   []string `json:"removeDocIDs,omitempty"`
+	AddChannels    []string `json:"addChannels,omitempty"`
+	RemoveChannels []string `json:"removeChannels,omitempty"`
+}
+
+// Satisfy fmt.Stringer interface for dumping attributes of this updateSubChanges request to logs
+func (b UpdateSubChangesBody) String() string {
+	return fmt.Sprintf("AddDocIDs:%v RemoveDocIDs:%v AddChannels:%v RemoveChannels:%v",
+		base.UD(b.AddDocIDs), base.UD(b.RemoveDocIDs), base.UD(b.AddChannels), base.UD(b.RemoveChannels))
 }
 
 // Create a new subChanges helper
@@ -178,12 +227,18 @@ func NewSubChangesParams(logCtx context.Context, rq *blip.Message, zeroSeq Seque
 	params._since = sinceSequenceId
 
 	// rq.BodyReader() returns an EOF for a non-existent body, so using rq.Body() here
-	docIDs, err := readDocIDsFromRequest(rq)
+	body, err := readSubChangesBody(rq)
 	if err != nil {
-		base.InfofCtx(logCtx, base.KeySync, "%s: Error reading doc IDs on subChanges request: %s", rq, err)
+		base.InfofCtx(logCtx, base.KeySync, "%s: Error reading body of subChanges request: %s", rq, err)
 		return params, err
 	}
-	params._docIDs = docIDs
+	for channel, priority := range body.Priorities {
+		if priority < 1 {
+			return params, fmt.Errorf("priority of channel %q must be at least 1", channel)
+		}
+	}
+	params._docIDs = body.DocIDs
+	params._priorities = body.Priorities
 
 	return params, nil
 }
@@ -196,23 +251,26 @@ func (s *SubChangesParams) docIDs() []string {
 	return s._docIDs
 }
 
-func readDocIDsFromRequest(rq *blip.Message) (docIDs []string, err error) {
+// priorities returns the relative priority of channels during catch-up requested by the client, or nil if none were.
+func (s *SubChangesParams) priorities() map[string]int {
+	return s._priorities
+}
+
+func readSubChangesBody(rq *blip.Message) (body SubChangesBody, err error) {
 	// Get Body from request.  Not using BodyReader(), to avoid EOF on empty body
 	rawBody, err := rq.Body()
 	if err != nil {
-		return nil, err
+		return body, err
 	}
 
-	// If there's a non-empty body, unmarshal to get the docIDs
+	// If there's a non-empty body, unmarshal to get the docIDs and priorities
 	if len(rawBody) > 0 {
-		var body SubChangesBody
 		unmarshalErr := base.JSONUnmarshal(rawBody, &body)
 		if unmarshalErr != nil {
-			return nil, err
+			return SubChangesBody{}, err
 		}
-		docIDs = body.DocIDs
 	}
-	return docIDs, err
+	return body, err
 
 }
 
@@ -244,6 +302,31 @@ func (s *SubChangesParams) requestPlus(defaultValue bool) (value bool) {
 	return propertyValue == trueProperty
 }
 
+// allDocs returns true if changes should include all leaf revisions of conflicted documents, matching the REST
+// _changes style=all_docs.
+func (s *SubChangesParams) allDocs() bool {
+	return s.rq.Properties[SubChangesStyle] == SubChangesStyleAllDocs
+}
+
+// idsOnly returns true if the client asked to be sent changes without revisions.
+func (s *SubChangesParams) idsOnly() bool {
+	return s.rq.Properties[SubChangesIdsOnly] == trueProperty
+}
+
+// deferAttachments returns true if the client asked to get attachments after acknowledging their revisions.
+func (s *SubChangesParams) deferAttachments() bool {
+	return s.rq.Properties[SubChangesDeferAttachments] == trueProperty
+}
+
+// maxHistory returns the maximum history length requested by the client, or 0 if the client didn't request one.
+func (s *SubChangesParams) maxHistory() int {
+	maxHistory, err := strconv.ParseUint(s.rq.Properties[SubChangesMaxHistory], 10, 32)
+	if err != nil {
+		return 0
+	}
+	return int(maxHistory)
+}
+
 func (s *SubChangesParams) filter() string {
 	return s.rq.Properties[SubChangesFilter]
 }
@@ -291,6 +374,10 @@ func (s *SubChangesParams) String() string {
 		}
 	}
 
+	if maxHistory := s.maxHistory(); maxHistory > 0 {
+		buffer.WriteString(fmt.Sprintf("MaxHistory:%v ", maxHistory))
+	}
+
 	batchSize := s.batchSize()
 	if batchSize != int(BlipDefaultBatchSize) {
 		buffer.WriteString(fmt.Sprintf("BatchSize:%v ", s.batchSize()))
@@ -299,6 +386,10 @@ func (s *SubChangesParams) String() string {
 	if len(s.docIDs()) > 0 {
 		buffer.WriteString(fmt.Sprintf("DocIDs:%v ", s.docIDs()))
 	}
+
+	if s.allDocs() {
+		buffer.WriteString(fmt.Sprintf("Style:%v ", SubChangesStyleAllDocs))
+	}
 	return buffer.String()
 
 }
@@ -473,6 +564,20 @@ type noRevMessage struct {
 	*blip.Message
 }
 
+type goAwayMessage struct {
+	*blip.Message
+}
+
+// NewGoAwayMessage creates a goAway message, which asks the client to close the connection and not
+// reconnect until reconnectAfter has elapsed.
+func NewGoAwayMessage(reconnectAfter time.Duration) *goAwayMessage {
+	gam := &goAwayMessage{blip.NewRequest()}
+	gam.SetProfile(MessageGoAway)
+	gam.SetNoReply(true)
+	gam.Properties[GoAwayReconnectAfter] = strconv.FormatInt(int64(reconnectAfter.Seconds()), 10)
+	return gam
+}
+
 func NewNoRevMessage() *noRevMessage {
 	nrm := &noRevMessage{blip.NewRequest()}
 	nrm.SetProfile(MessageNoRev)
@@ -24,6 +24,8 @@ type BlipSyncStats struct {
 	HandleRevBytes                   *base.SgwIntStat
 	HandleRevProcessingTime          *base.SgwIntStat
 	HandleRevDocsPurgedCount         *base.SgwIntStat
+	HandleRevThrottledCount          *base.SgwIntStat
+	HandleRevThrottledTime           *base.SgwIntStat
 	HandleGetRevCount                *base.SgwIntStat // Connected Client API
 	HandlePutRevCount                *base.SgwIntStat // Connected Client API
 	HandlePutRevErrorCount           *base.SgwIntStat // Connected Client API
@@ -72,6 +74,8 @@ func NewBlipSyncStats() *BlipSyncStats {
 		HandleRevBytes:                   &base.SgwIntStat{},
 		HandleRevProcessingTime:          &base.SgwIntStat{},
 		HandleRevDocsPurgedCount:         &base.SgwIntStat{},
+		HandleRevThrottledCount:          &base.SgwIntStat{},
+		HandleRevThrottledTime:           &base.SgwIntStat{},
 		HandleGetRevCount:                &base.SgwIntStat{},
 		HandlePutRevCount:                &base.SgwIntStat{},
 		HandlePutRevErrorCount:           &base.SgwIntStat{},
@@ -136,6 +140,8 @@ func BlipSyncStatsForCBL(dbStats *base.DbStats) *BlipSyncStats {
 
 	blipStats.HandleRevCount = dbStats.CBLReplicationPush().DocPushCount
 	blipStats.HandleRevErrorCount = dbStats.CBLReplicationPush().DocPushErrorCount
+	blipStats.HandleRevThrottledCount = dbStats.CBLReplicationPush().RevThrottledCount
+	blipStats.HandleRevThrottledTime = dbStats.CBLReplicationPush().RevThrottledTime
 
 	blipStats.HandleGetAttachment = dbStats.CBLReplicationPull().AttachmentPullCount
 	blipStats.HandleGetAttachmentBytes = dbStats.CBLReplicationPull().AttachmentPullBytes
@@ -71,6 +71,9 @@ type changeCache struct {
 	cfgEventCallback   base.CfgEventNotifyFunc             // Callback for Cfg updates recieved over the caching feed
 	sgCfgPrefix        string                              // Prefix for SG Cfg doc keys
 	metaKeys           *base.MetadataKeys                  // Metadata key formatter
+	shards             map[uint32]*changeCacheShard        // Shards applying entries to the channel cache, by collection ID
+	notifyBatch        notifyBatcher                       // Defers notifications while bulk writes are in progress
+	feedBuffer         *changeFeedBuffer                   // Bounded buffer between the mutation feed and DocChanged, nil if not configured
 }
 
 type changeCacheStats struct {
@@ -135,6 +138,7 @@ type CacheOptions struct {
 	CachePendingSeqMaxWait time.Duration // Max wait for pending sequence before skipping
 	CachePendingSeqMaxNum  int           // Max number of pending sequences before skipping
 	CacheSkippedSeqMaxWait time.Duration // Max wait for skipped sequence before abandoning
+	FeedBufferSize         int           // Max number of mutation feed events buffered for the cache before pausing the feed.  Zero disables buffering
 }
 
 func DefaultCacheOptions() CacheOptions {
@@ -150,6 +154,7 @@ func DefaultCacheOptions() CacheOptions {
 			CompactHighWatermarkPercent: DefaultCompactHighWatermarkPercent,
 			CompactLowWatermarkPercent:  DefaultCompactLowWatermarkPercent,
 			ChannelQueryLimit:           DefaultQueryPaginationLimit,
+			MaxBackfillConcurrency:      DefaultChannelBackfillConcurrency,
 		},
 	}
 }
@@ -184,6 +189,10 @@ func (c *changeCache) Init(ctx context.Context, dbContext *DatabaseContext, chan
 
 	c.channelCache = channelCache
 
+	if c.options.FeedBufferSize > 0 {
+		c.feedBuffer = newChangeFeedBuffer(c.DocChangedBatch, c.options.FeedBufferSize, dbContext.DbStats.Cache(), c.terminator)
+	}
+
 	base.InfofCtx(ctx, base.KeyCache, "Initializing changes cache for %s with options %+v", base.UD(c.db.Name), c.options)
 
 	heap.Init(&c.pendingLogs)
@@ -289,11 +298,9 @@ func (c *changeCache) InsertPendingEntries(ctx context.Context) error {
 
 	// Trigger _addPendingLogs to process any entries that have been pending too long:
 	c.lock.Lock()
-	changedChannels := c._addPendingLogs(ctx)
-	if c.notifyChange != nil && len(changedChannels) > 0 {
-		c.notifyChange(ctx, changedChannels)
-	}
+	batches := c._startApplying(c._addPendingLogs(ctx))
 	c.lock.Unlock()
+	c.notify(ctx, c.applyBatches(ctx, batches))
 
 	return nil
 }
@@ -320,12 +327,61 @@ func (c *changeCache) CleanSkippedSequenceQueue(ctx context.Context) error {
 	return nil
 }
 
+// SequenceRange is an inclusive range of sequences.
+type SequenceRange struct {
+	Start uint64 `json:"start"`
+	End   uint64 `json:"end"`
+}
+
+func (r SequenceRange) String() string {
+	if r.Start == r.End {
+		return strconv.FormatUint(r.Start, 10)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// ReleaseSkippedSequences removes skipped sequences within the given ranges from the skipped sequence queue, abandoning
+// them immediately rather than waiting for the skipped sequence max wait.  Used when a sequence is known to never
+// arrive.  Returns the released sequences.
+func (context *DatabaseContext) ReleaseSkippedSequences(ctx context.Context, ranges []SequenceRange) []uint64 {
+	return context.changeCache.releaseSkippedSequences(ctx, ranges)
+}
+
+func (c *changeCache) releaseSkippedSequences(ctx context.Context, ranges []SequenceRange) []uint64 {
+	released := c.skippedSeqs.removeRanges(ranges)
+	c.db.DbStats.Cache().SkippedSeqLen.Set(int64(c.skippedSeqs.skippedList.Len()))
+	c.db.DbStats.Cache().AbandonedSeqs.Add(int64(len(released)))
+	base.InfofCtx(ctx, base.KeyCache, "Released %d skipped sequences in ranges %v for database %s", len(released), ranges, base.MD(c.db.Name))
+	return released
+}
+
 // ////// ADDING CHANGES:
 
 // Note that DocChanged may be executed concurrently for multiple events (in the DCP case, DCP events
 // originating from multiple vbuckets).  Only processEntry is locking - all other functionality needs to support
 // concurrent processing.
 func (c *changeCache) DocChanged(event sgbucket.FeedEvent) {
+	c.docChanged(event, c.processEntry)
+}
+
+// DocChangedBatch handles a batch of feed events drained from the feed buffer. The entries for the whole batch are
+// passed to processEntries together, and change listeners are notified once for the batch.
+func (c *changeCache) DocChangedBatch(events []sgbucket.FeedEvent) {
+	var entries []*LogEntry
+	collectEntry := func(_ context.Context, change *LogEntry) channels.Set {
+		entries = append(entries, change)
+		return nil
+	}
+	for _, event := range events {
+		c.docChanged(event, collectEntry)
+	}
+	if len(entries) > 0 {
+		c.notify(c.logCtx, c.processEntries(c.logCtx, entries))
+	}
+}
+
+// docChanged handles a feed event, passing any resulting entries for documents to processEntry.
+func (c *changeCache) docChanged(event sgbucket.FeedEvent, processEntry func(context.Context, *LogEntry) channels.Set) {
 	ctx := c.logCtx
 	docID := string(event.Key)
 	docJSON := event.Value
@@ -456,7 +512,7 @@ func (c *changeCache) DocChanged(event sgbucket.FeedEvent) {
 			TimeReceived: event.TimeReceived,
 			CollectionID: event.CollectionID,
 		}
-		changedChannels := c.processEntry(ctx, change)
+		changedChannels := processEntry(ctx, change)
 		changedChannelsCombined = changedChannelsCombined.Update(changedChannels)
 	}
 
@@ -489,7 +545,7 @@ func (c *changeCache) DocChanged(event sgbucket.FeedEvent) {
 					change.Channels = channelRemovals
 				}
 
-				changedChannels := c.processEntry(ctx, change)
+				changedChannels := processEntry(ctx, change)
 				changedChannelsCombined = changedChannelsCombined.Update(changedChannels)
 			}
 		}
@@ -499,6 +555,8 @@ func (c *changeCache) DocChanged(event sgbucket.FeedEvent) {
 	if len(rawUserXattr) > 0 {
 		collection.revisionCache.Remove(docID, syncData.CurrentRev)
 	}
+	// Deltas to earlier revisions of the doc won't be requested again, and a pinned doc should be refreshed
+	collection.revisionCache.RefreshCurrentRev(docID, syncData.CurrentRev)
 	change := &LogEntry{
 		Sequence:     syncData.Sequence,
 		DocID:        docID,
@@ -508,6 +566,10 @@ func (c *changeCache) DocChanged(event sgbucket.FeedEvent) {
 		TimeSaved:    syncData.TimeSaved,
 		Channels:     syncData.Channels,
 		CollectionID: event.CollectionID,
+		SystemOnly:   c.db.inSystemChannelsOnly(syncData.Channels),
+	}
+	if c.db.Options.ChangesIncludeChannels {
+		change.DocChannels = syncData.Channels.ActiveKeys()
 	}
 
 	millisecondLatency := int(feedLatency / time.Millisecond)
@@ -519,13 +581,11 @@ func (c *changeCache) DocChanged(event sgbucket.FeedEvent) {
 		base.DebugfCtx(ctx, base.KeyChanges, "Received #%d after %3dms (%q / %q)", change.Sequence, millisecondLatency, base.UD(change.DocID), change.RevID)
 	}
 
-	changedChannels := c.processEntry(ctx, change)
+	changedChannels := processEntry(ctx, change)
 	changedChannelsCombined = changedChannelsCombined.Update(changedChannels)
 
 	// Notify change listeners for all of the changed channels
-	if c.notifyChange != nil && len(changedChannelsCombined) > 0 {
-		c.notifyChange(ctx, changedChannelsCombined)
-	}
+	c.notify(ctx, changedChannelsCombined)
 
 }
 
@@ -575,9 +635,7 @@ func (c *changeCache) releaseUnusedSequence(ctx context.Context, sequence uint64
 		changedChannels.Add(unusedSeq)
 	}
 	c.channelCache.AddUnusedSequence(change)
-	if c.notifyChange != nil && len(changedChannels) > 0 {
-		c.notifyChange(ctx, changedChannels)
-	}
+	c.notify(ctx, changedChannels)
 }
 
 // releaseUnusedSequenceRange calls processEntry for each sequence in the range, but only issues a single notify.
@@ -600,9 +658,7 @@ func (c *changeCache) releaseUnusedSequenceRange(ctx context.Context, fromSequen
 		c.channelCache.AddUnusedSequence(change)
 	}
 
-	if c.notifyChange != nil {
-		c.notifyChange(ctx, allChangedChannels)
-	}
+	c.notify(ctx, allChangedChannels)
 }
 
 // Process unused sequence notification.  Extracts sequence from docID and sends to cache for buffering
@@ -659,17 +715,64 @@ func (c *changeCache) processPrincipalDoc(ctx context.Context, docID string, doc
 	base.InfofCtx(ctx, base.KeyChanges, "Received #%d (%q)", change.Sequence, base.UD(change.DocID))
 
 	changedChannels := c.processEntry(ctx, change)
-	if c.notifyChange != nil && len(changedChannels) > 0 {
-		c.notifyChange(ctx, changedChannels)
-	}
+	c.notify(ctx, changedChannels)
 }
 
-// Handles a newly-arrived LogEntry.
+// Handles a newly-arrived LogEntry. Sequence buffering is performed under the change cache lock, then any entries
+// ready to be cached are applied to the channel cache by their collection's shard.
 func (c *changeCache) processEntry(ctx context.Context, change *LogEntry) channels.Set {
 	c.lock.Lock()
-	defer c.lock.Unlock()
+	ready, lateSequence := c._processEntry(ctx, change)
+	batches := c._startApplying(ready)
+	c.lock.Unlock()
+
+	changedChannels := c.applyBatches(ctx, batches)
+	if lateSequence {
+		// Add to cache before removing from skipped, to ensure lowSequence doesn't get incremented until results are available
+		// in cache
+		err := c.RemoveSkipped(change.Sequence)
+		if err != nil {
+			base.DebugfCtx(ctx, base.KeyCache, "Error removing skipped sequence: #%d from cache: %v", change.Sequence, err)
+		}
+	}
+	return changedChannels
+}
+
+// processEntries handles a batch of newly-arrived LogEntries. Sequence buffering for the whole batch is performed under
+// a single acquisition of the change cache lock, and entries that are ready to be cached are added to each channel's
+// cache under a single acquisition of its lock.
+func (c *changeCache) processEntries(ctx context.Context, changes []*LogEntry) channels.Set {
+	var ready []*LogEntry
+	var lateSequences []uint64
+	c.lock.Lock()
+	for _, change := range changes {
+		entryReady, lateSequence := c._processEntry(ctx, change)
+		ready = append(ready, entryReady...)
+		if lateSequence {
+			lateSequences = append(lateSequences, change.Sequence)
+		}
+	}
+	batches := c._startApplying(ready)
+	c.lock.Unlock()
+
+	changedChannels := c.applyBatches(ctx, batches)
+	// Add to cache before removing from skipped, to ensure lowSequence doesn't get incremented until results are available
+	// in cache
+	for _, sequence := range lateSequences {
+		err := c.RemoveSkipped(sequence)
+		if err != nil {
+			base.DebugfCtx(ctx, base.KeyCache, "Error removing skipped sequence: #%d from cache: %v", sequence, err)
+		}
+	}
+	return changedChannels
+}
+
+// _processEntry performs sequence buffering for a newly-arrived LogEntry, returning the entries that are ready to be
+// added to the channel cache in sequence order, and whether the entry is a previously skipped sequence arriving late.
+// Requires the change cache lock.
+func (c *changeCache) _processEntry(ctx context.Context, change *LogEntry) (ready []*LogEntry, lateSequence bool) {
 	if c.logsDisabled {
-		return nil
+		return nil, false
 	}
 
 	sequence := change.Sequence
@@ -684,22 +787,21 @@ func (c *changeCache) processEntry(ctx context.Context, change *LogEntry) channe
 	// Check if this is a duplicate of an already processed sequence
 	if sequence < c.nextSequence && !c.WasSkipped(sequence) {
 		base.DebugfCtx(ctx, base.KeyCache, "  Ignoring duplicate of #%d", sequence)
-		return nil
+		return nil, false
 	}
 
 	// Check if this is a duplicate of a pending sequence
 	if _, found := c.receivedSeqs[sequence]; found {
 		base.DebugfCtx(ctx, base.KeyCache, "  Ignoring duplicate of #%d", sequence)
-		return nil
+		return nil, false
 	}
 	c.receivedSeqs[sequence] = struct{}{}
 
-	var changedChannels channels.Set
 	if sequence == c.nextSequence || c.nextSequence == 0 {
 		// This is the expected next sequence so we can add it now:
-		changedChannels = channels.SetFromArrayNoValidate(c._addToCache(ctx, change))
+		ready = append(ready, c._releaseSequence(change))
 		// Also add any pending sequences that are now contiguous:
-		changedChannels = changedChannels.Update(c._addPendingLogs(ctx))
+		ready = append(ready, c._addPendingLogs(ctx)...)
 	} else if sequence > c.nextSequence {
 		// There's a missing sequence (or several), so put this one on ice until it arrives:
 		heap.Push(&c.pendingLogs, change)
@@ -716,7 +818,7 @@ func (c *changeCache) processEntry(ctx context.Context, change *LogEntry) channe
 
 		if numPending > c.options.CachePendingSeqMaxNum {
 			// Too many pending; add the oldest one:
-			changedChannels = c._addPendingLogs(ctx)
+			ready = c._addPendingLogs(ctx)
 		}
 	} else if sequence > c.initialSequence {
 		// Out-of-order sequence received!
@@ -727,29 +829,29 @@ func (c *changeCache) processEntry(ctx context.Context, change *LogEntry) channe
 		} else {
 			base.InfofCtx(ctx, base.KeyCache, "  Received previously skipped out-of-order change (seq %d, expecting %d) doc %q / %q ", sequence, c.nextSequence, base.UD(change.DocID), change.RevID)
 			change.Skipped = true
+			lateSequence = true
 		}
 
-		changedChannels = changedChannels.UpdateWithSlice(c._addToCache(ctx, change))
-		// Add to cache before removing from skipped, to ensure lowSequence doesn't get incremented until results are available
-		// in cache
-		err := c.RemoveSkipped(sequence)
-		if err != nil {
-			base.DebugfCtx(ctx, base.KeyCache, "Error removing skipped sequence: #%d from cache: %v", sequence, err)
-		}
+		ready = append(ready, c._releaseSequence(change))
 	}
-	return changedChannels
+	return ready, lateSequence
 }
 
-// Adds an entry to the appropriate channels' caches, returning the affected channels.  lateSequence
-// flag indicates whether it was a change arriving out of sequence
-func (c *changeCache) _addToCache(ctx context.Context, change *LogEntry) []channels.ID {
-
+// _releaseSequence updates the sequence buffering state for an entry that is ready to be added to the channel cache.
+// Requires the change cache lock.
+func (c *changeCache) _releaseSequence(change *LogEntry) *LogEntry {
 	if change.Sequence >= c.nextSequence {
 		c.nextSequence = change.Sequence + 1
 	}
 	delete(c.receivedSeqs, change.Sequence)
+	return change
+}
+
+// Adds an entry to the appropriate channels' caches, returning the affected channels.  Must be called by the
+// entry's collection shard, in sequence order.
+func (c *changeCache) addToCache(ctx context.Context, change *LogEntry) []channels.ID {
 
-	// If unused sequence or principal, we're done after updating sequence
+	// If unused sequence or principal, there's nothing to add to the channel caches
 	if change.DocID == "" {
 		return nil
 	}
@@ -774,18 +876,35 @@ func (c *changeCache) _addToCache(ctx context.Context, change *LogEntry) []chann
 	return updatedChannels
 }
 
-// Add the first change(s) from pendingLogs if they're the next sequence.  If not, and we've been
+// addBatchToCache adds a run of document entries to the appropriate channels' caches, returning the affected channels.
+// Must be called by the entries' collection shard, in sequence order.
+func (c *changeCache) addBatchToCache(ctx context.Context, changes []*LogEntry) []channels.ID {
+	updatedChannels := c.channelCache.AddBatchToCache(ctx, changes)
+	if base.LogDebugEnabled(ctx, base.KeyChanges) {
+		base.DebugfCtx(ctx, base.KeyChanges, " #%d...#%d ==> channels %v", changes[0].Sequence, changes[len(changes)-1].Sequence, base.UD(updatedChannels))
+	}
+
+	for _, change := range changes {
+		if !change.TimeReceived.IsZero() {
+			c.db.DbStats.Database().DCPCachingCount.Add(1)
+			c.db.DbStats.Database().DCPCachingTime.Add(time.Since(change.TimeReceived).Nanoseconds())
+		}
+	}
+	return updatedChannels
+}
+
+// Release the first change(s) from pendingLogs if they're the next sequence.  If not, and we've been
 // waiting too long for nextSequence, move nextSequence to skipped queue.
-// Returns the channels that changed.
-func (c *changeCache) _addPendingLogs(ctx context.Context) channels.Set {
-	var changedChannels channels.Set
+// Returns the entries that are ready to be added to the channel cache, in sequence order.
+func (c *changeCache) _addPendingLogs(ctx context.Context) []*LogEntry {
+	var ready []*LogEntry
 
 	for len(c.pendingLogs) > 0 {
 		change := c.pendingLogs[0]
 		isNext := change.Sequence == c.nextSequence
 		if isNext {
 			heap.Pop(&c.pendingLogs)
-			changedChannels = changedChannels.UpdateWithSlice(c._addToCache(ctx, change))
+			ready = append(ready, c._releaseSequence(change))
 		} else if len(c.pendingLogs) > c.options.CachePendingSeqMaxNum || time.Since(c.pendingLogs[0].TimeReceived) >= c.options.CachePendingSeqMaxWait {
 			c.db.DbStats.Cache().NumSkippedSeqs.Add(1)
 			c.PushSkipped(ctx, c.nextSequence)
@@ -798,7 +917,7 @@ func (c *changeCache) _addPendingLogs(ctx context.Context) channels.Set {
 	c.internalStats.pendingSeqLen = len(c.pendingLogs)
 
 	atomic.StoreInt64(&c.lastAddPendingTime, time.Now().UnixNano())
-	return changedChannels
+	return ready
 }
 
 func (c *changeCache) GetStableSequence(docID string) SequenceID {
@@ -822,9 +941,9 @@ func (c *changeCache) GetChanges(ctx context.Context, channel channels.ID, optio
 
 // Returns the sequence number the cache is up-to-date with.
 func (c *changeCache) LastSequence() uint64 {
-
-	lastSequence := c.getNextSequence() - 1
-	return lastSequence
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c._getMaxAppliedSequence()
 }
 
 func (c *changeCache) getOldestSkippedSequence(ctx context.Context) uint64 {
@@ -951,11 +1070,11 @@ func (c *changeCache) waitForSequenceNotSkipped(ctx context.Context, sequence ui
 }
 
 func (c *changeCache) _getMaxStableCached(ctx context.Context) uint64 {
-	oldestSkipped := c.getOldestSkippedSequence(ctx)
-	if oldestSkipped > 0 {
-		return oldestSkipped - 1
+	maxStable := c._getMaxAppliedSequence()
+	if oldestSkipped := c.getOldestSkippedSequence(ctx); oldestSkipped > 0 && oldestSkipped-1 < maxStable {
+		maxStable = oldestSkipped - 1
 	}
-	return c.nextSequence - 1
+	return maxStable
 }
 
 // SkippedSequenceList stores the set of skipped sequences as an ordered list of *SkippedSequence with an associated map
@@ -1017,6 +1136,26 @@ func (l *SkippedSequenceList) _remove(x uint64) error {
 	}
 }
 
+// removeRanges removes all entries within the given ranges from the list, returning the removed sequences in order.
+func (l *SkippedSequenceList) removeRanges(ranges []SequenceRange) (removed []uint64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for e := l.skippedList.Front(); e != nil; {
+		next := e.Next()
+		seq := e.Value.(*SkippedSequence).seq
+		for _, r := range ranges {
+			if seq >= r.Start && seq <= r.End {
+				l.skippedList.Remove(e)
+				delete(l.skippedMap, seq)
+				removed = append(removed, seq)
+				break
+			}
+		}
+		e = next
+	}
+	return removed
+}
+
 // Contains does a simple search to detect presence
 func (l *SkippedSequenceList) Contains(x uint64) bool {
 	l.lock.RLock()
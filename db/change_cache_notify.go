@@ -0,0 +1,120 @@
+/*
+Copyright 2023-Present Couchbase, Inc.
+
+Use of this software is governed by the Business Source License included in
+the file licenses/BSL-Couchbase.txt.  As of the Change Date specified in that
+file, in accordance with the Business Source License, use of this software will
+be governed by the Apache License, Version 2.0, included in the file
+licenses/APL2.txt.
+*/
+
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+)
+
+// NotifyBatchMaxWait is the maximum time a notification batch waits for the change cache to receive the sequences
+// written by its bulk request, before notifying waiting changes feeds regardless.
+var NotifyBatchMaxWait = 5 * time.Second
+
+// notifyBatchPollInterval is how often a notification batch checks whether the change cache has caught up.
+const notifyBatchPollInterval = 10 * time.Millisecond
+
+// notifyBatcher coalesces change cache notifications while bulk writes are in progress, so that waiting changes feeds
+// are woken once per bulk request with the union of the changed channels, rather than once per document.
+type notifyBatcher struct {
+	lock            sync.Mutex
+	activeBatches   int          // Number of bulk writes currently deferring notifications
+	changedChannels channels.Set // Union of the channels changed while notifications were deferred
+}
+
+// deferNotify adds the changed channels to the pending notification when a batch is active. Returns false if no batch is
+// active, in which case the caller should notify immediately.
+func (n *notifyBatcher) deferNotify(changedChannels channels.Set) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.activeBatches == 0 {
+		return false
+	}
+	if n.changedChannels == nil {
+		n.changedChannels = make(channels.Set, len(changedChannels))
+	}
+	// Copied rather than Update, which can return changedChannels itself
+	for channel := range changedChannels {
+		n.changedChannels.Add(channel)
+	}
+	return true
+}
+
+func (n *notifyBatcher) start() {
+	n.lock.Lock()
+	n.activeBatches++
+	n.lock.Unlock()
+}
+
+// end completes a batch, returning the deferred channels to notify once the last active batch has completed.
+func (n *notifyBatcher) end() channels.Set {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.activeBatches--
+	if n.activeBatches > 0 {
+		return nil
+	}
+	changedChannels := n.changedChannels
+	n.changedChannels = nil
+	return changedChannels
+}
+
+// notify notifies change listeners of the changed channels, or defers the notification while a bulk write is in
+// progress.
+func (c *changeCache) notify(ctx context.Context, changedChannels channels.Set) {
+	if c.notifyChange == nil || len(changedChannels) == 0 {
+		return
+	}
+	if c.notifyBatch.deferNotify(changedChannels) {
+		return
+	}
+	c.notifyChange(ctx, changedChannels)
+}
+
+// startNotifyBatch defers change notifications until the matching call to endNotifyBatch.
+func (c *changeCache) startNotifyBatch() {
+	c.notifyBatch.start()
+}
+
+// endNotifyBatch waits until the change cache has received maxSequence (up to maxWait), then issues a single
+// notification for the channels changed while the batch was active. Blocks for the duration of the wait.
+func (c *changeCache) endNotifyBatch(ctx context.Context, maxSequence uint64, maxWait time.Duration) {
+	if maxSequence > 0 {
+		deadline := time.Now().Add(maxWait)
+		for c.getNextSequence() <= maxSequence && !c.stopped.IsTrue() {
+			if time.Now().After(deadline) {
+				base.DebugfCtx(ctx, base.KeyCache, "Timed out waiting for sequence %d before notifying for bulk write", maxSequence)
+				break
+			}
+			time.Sleep(notifyBatchPollInterval)
+		}
+	}
+	if changedChannels := c.notifyBatch.end(); c.notifyChange != nil && len(changedChannels) > 0 {
+		c.notifyChange(ctx, changedChannels)
+	}
+}
+
+// StartChangeNotificationBatch defers notifications to waiting changes feeds while a bulk write is in progress. Each
+// call must be followed by a call to EndChangeNotificationBatch.
+func (context *DatabaseContext) StartChangeNotificationBatch() {
+	context.changeCache.startNotifyBatch()
+}
+
+// EndChangeNotificationBatch completes a bulk write that has written sequences up to maxSequence. Once the change
+// cache has received those sequences, waiting changes feeds are notified once for all the channels changed. Doesn't
+// block the caller.
+func (context *DatabaseContext) EndChangeNotificationBatch(ctx context.Context, maxSequence uint64) {
+	go context.changeCache.endNotifyBatch(ctx, maxSequence, NotifyBatchMaxWait)
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2023-Present Couchbase, Inc.
+
+Use of this software is governed by the Business Source License included in
+the file licenses/BSL-Couchbase.txt.  As of the Change Date specified in that
+file, in accordance with the Business Source License, use of this software will
+be governed by the Apache License, Version 2.0, included in the file
+licenses/APL2.txt.
+*/
+
+package db
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/couchbase/sync_gateway/channels"
+)
+
+// changeCacheShard applies entries for a single collection to the channel cache.
+//
+// Sequences are allocated across all the collections in a database, so sequence buffering (pending and skipped
+// sequences) is performed across all collections under the change cache lock. Once entries are ready to be cached,
+// they're handed to their collection's shard, and applied to the channel cache under the shard's lock rather than the
+// change cache lock. Entries for different collections are applied concurrently, while shard locks are only acquired
+// while holding the change cache lock, which preserves sequence order within each collection.
+type changeCacheShard struct {
+	lock         sync.Mutex // Held while applying entries to the channel cache
+	inFlightLock sync.Mutex // Mutex for inFlight
+	inFlight     []uint64   // Sequences released by sequence buffering that haven't been applied yet, in the order they'll be applied
+}
+
+// changeCacheShardBatch is a set of entries to be applied to the channel cache by a shard, in sequence order.
+type changeCacheShardBatch struct {
+	shard   *changeCacheShard
+	entries []*LogEntry
+}
+
+// oldestInFlight returns the lowest sequence released to the shard that hasn't been applied yet, or zero if there are
+// none. Late arriving skipped sequences mean in-flight sequences aren't necessarily in order.
+func (s *changeCacheShard) oldestInFlight() uint64 {
+	s.inFlightLock.Lock()
+	defer s.inFlightLock.Unlock()
+	var oldest uint64
+	for _, sequence := range s.inFlight {
+		if oldest == 0 || sequence < oldest {
+			oldest = sequence
+		}
+	}
+	return oldest
+}
+
+func (s *changeCacheShard) addInFlight(entries []*LogEntry) {
+	s.inFlightLock.Lock()
+	for _, entry := range entries {
+		s.inFlight = append(s.inFlight, entry.Sequence)
+	}
+	s.inFlightLock.Unlock()
+}
+
+// removeInFlight removes the first count in-flight sequences, once they've been applied. Batches are applied in the
+// order their sequences were added, as both happen while holding the change cache lock.
+func (s *changeCacheShard) removeInFlight(count int) {
+	s.inFlightLock.Lock()
+	s.inFlight = s.inFlight[count:]
+	if len(s.inFlight) == 0 {
+		s.inFlight = nil
+	}
+	s.inFlightLock.Unlock()
+}
+
+// _getShard returns the shard for the collection, creating it if needed. Requires the change cache write lock.
+func (c *changeCache) _getShard(collectionID uint32) *changeCacheShard {
+	shard, ok := c.shards[collectionID]
+	if !ok {
+		if c.shards == nil {
+			c.shards = make(map[uint32]*changeCacheShard)
+		}
+		shard = &changeCacheShard{}
+		c.shards[collectionID] = shard
+	}
+	return shard
+}
+
+// _startApplying groups entries released by sequence buffering into a batch per collection, marks them as in flight,
+// and acquires the lock of each batch's shard. Shard locks are acquired in collection ID order, and only while holding
+// the change cache write lock, so they can't deadlock. The locks are released by applyBatches.
+func (c *changeCache) _startApplying(ready []*LogEntry) []*changeCacheShardBatch {
+	if len(ready) == 0 {
+		return nil
+	}
+
+	batchesByCollection := make(map[uint32]*changeCacheShardBatch, 1)
+	collectionIDs := make([]uint32, 0, 1)
+	for _, entry := range ready {
+		batch, ok := batchesByCollection[entry.CollectionID]
+		if !ok {
+			batch = &changeCacheShardBatch{shard: c._getShard(entry.CollectionID)}
+			batchesByCollection[entry.CollectionID] = batch
+			collectionIDs = append(collectionIDs, entry.CollectionID)
+		}
+		batch.entries = append(batch.entries, entry)
+	}
+	sort.Slice(collectionIDs, func(i, j int) bool { return collectionIDs[i] < collectionIDs[j] })
+
+	batches := make([]*changeCacheShardBatch, 0, len(collectionIDs))
+	for _, collectionID := range collectionIDs {
+		batch := batchesByCollection[collectionID]
+		batch.shard.addInFlight(batch.entries)
+		batch.shard.lock.Lock()
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// applyBatches applies each batch to the channel cache, releasing the shard locks acquired by _startApplying, and
+// returns the changed channels. Batches for different collections are applied concurrently.
+func (c *changeCache) applyBatches(ctx context.Context, batches []*changeCacheShardBatch) channels.Set {
+	switch len(batches) {
+	case 0:
+		return nil
+	case 1:
+		return channels.SetFromArrayNoValidate(c.applyBatch(ctx, batches[0]))
+	}
+
+	batchChannels := make([][]channels.ID, len(batches))
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		wg.Add(1)
+		go func(i int, batch *changeCacheShardBatch) {
+			defer wg.Done()
+			batchChannels[i] = c.applyBatch(ctx, batch)
+		}(i, batch)
+	}
+	wg.Wait()
+
+	var changedChannels channels.Set
+	for _, updatedChannels := range batchChannels {
+		changedChannels = changedChannels.UpdateWithSlice(updatedChannels)
+	}
+	return changedChannels
+}
+
+// applyBatch adds a batch's entries to the channel cache in sequence order. Consecutive document entries are added
+// as a run, so that each channel's cache is locked once per run rather than once per entry.
+func (c *changeCache) applyBatch(ctx context.Context, batch *changeCacheShardBatch) []channels.ID {
+	defer batch.shard.lock.Unlock()
+	var updatedChannels []channels.ID
+	var run []*LogEntry
+	flushRun := func() {
+		switch len(run) {
+		case 0:
+		case 1:
+			updatedChannels = append(updatedChannels, c.addToCache(ctx, run[0])...)
+		default:
+			updatedChannels = append(updatedChannels, c.addBatchToCache(ctx, run)...)
+		}
+		run = nil
+	}
+	for _, entry := range batch.entries {
+		if entry.DocID == "" {
+			// Unused sequence, nothing to add to the channel caches
+			continue
+		}
+		if entry.IsPrincipal || entry.Skipped {
+			// Principals and late sequences aren't added as part of a run
+			flushRun()
+			updatedChannels = append(updatedChannels, c.addToCache(ctx, entry)...)
+			continue
+		}
+		run = append(run, entry)
+	}
+	flushRun()
+	batch.shard.removeInFlight(len(batch.entries))
+	return updatedChannels
+}
+
+// _getMaxAppliedSequence returns the highest sequence for which all sequences have either been applied to the channel
+// cache or skipped. This is merged across shards, as shards may still be applying sequences released by sequence
+// buffering. Requires the change cache read lock.
+func (c *changeCache) _getMaxAppliedSequence() uint64 {
+	maxApplied := c.nextSequence - 1
+	for _, shard := range c.shards {
+		if oldest := shard.oldestInFlight(); oldest > 0 && oldest-1 < maxApplied {
+			maxApplied = oldest - 1
+		}
+	}
+	return maxApplied
+}
+
+// getCollectionHighCacheSequence returns the highest sequence for which all of the collection's entries have been
+// added to the channel cache, used for changes synchronization.
+func (c *changeCache) getCollectionHighCacheSequence(collectionID uint32) uint64 {
+	c.lock.RLock()
+	highSeq := c.nextSequence - 1
+	shard := c.shards[collectionID]
+	c.lock.RUnlock()
+
+	if shard != nil {
+		if oldest := shard.oldestInFlight(); oldest > 0 && oldest-1 < highSeq {
+			highSeq = oldest - 1
+		}
+	}
+	return highSeq
+}
@@ -16,6 +16,7 @@ import (
 	"log"
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -1019,6 +1020,10 @@ func TestChannelQueryCancellation(t *testing.T) {
 	var changesWg sync.WaitGroup
 
 	postQueryCallback := func(ddoc, viewName string, params map[string]interface{}) {
+		// Only block the changes requests' queries, not the all-channels queries made when the database is started
+		if startKey, ok := params[QueryParamStartKey].([]interface{}); !ok || startKey[0] != "ABC" {
+			return
+		}
 		close(queryBlocked) // Notifies that a query is blocked, trigger to initiate second changes request
 		queryWg.Wait()      // Waits until second changes request attempts to make a view query
 	}
@@ -1835,6 +1840,54 @@ func TestChangeCache_InsertPendingEntries(t *testing.T) {
 
 }
 
+func TestProcessEntries(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+
+	collectionID := GetSingleDatabaseCollection(t, db.DatabaseContext).GetCollectionID()
+
+	changeCache := &changeCache{}
+	require.NoError(t, changeCache.Init(ctx, db.DatabaseContext, db.channelCache, nil, nil, db.MetadataKeys))
+	require.NoError(t, changeCache.Start(0))
+	defer changeCache.Stop(ctx)
+
+	// Activate the channel caches, so that the batch is added to them
+	channelA := channels.NewID("A", collectionID)
+	channelB := channels.NewID("B", collectionID)
+	for _, channel := range []channels.ID{channelA, channelB} {
+		_, err := changeCache.GetChanges(ctx, channel, getChangesOptionsWithZeroSeq(t))
+		require.NoError(t, err)
+	}
+
+	// Entries arriving out of order are buffered and added to the channel caches in sequence order
+	removal := logEntry(5, "doc1", "2-a", []string{"A"}, collectionID)
+	removal.Channels["B"] = &channels.ChannelRemoval{Seq: 5, RevID: "2-a"}
+	changedChannels := changeCache.processEntries(ctx, []*LogEntry{
+		logEntry(3, "doc3", "1-a", []string{"A"}, collectionID),
+		logEntry(1, "doc1", "1-a", []string{"A", "B"}, collectionID),
+		logEntry(2, "doc2", "1-a", []string{"B"}, collectionID),
+		{Sequence: 4, CollectionID: collectionID},
+		removal,
+		logEntry(1, "doc1", "1-a", []string{"A", "B"}, collectionID), // duplicate
+	})
+	assert.Equal(t, channels.SetOfNoValidate(channelA, channelB, channels.NewID(channels.UserStarChannel, collectionID)), changedChannels)
+	assert.Equal(t, uint64(6), changeCache.getNextSequence())
+
+	entriesA, err := changeCache.GetChanges(ctx, channelA, getChangesOptionsWithZeroSeq(t))
+	require.NoError(t, err)
+	require.Len(t, entriesA, 2)
+	assert.Equal(t, "doc3", entriesA[0].DocID)
+	assert.Equal(t, uint64(5), entriesA[1].Sequence)
+	assert.Zero(t, entriesA[1].Flags&channels.Removed)
+
+	entriesB, err := changeCache.GetChanges(ctx, channelB, getChangesOptionsWithZeroSeq(t))
+	require.NoError(t, err)
+	require.Len(t, entriesB, 2)
+	assert.Equal(t, "doc2", entriesB[0].DocID)
+	assert.Equal(t, uint64(5), entriesB[1].Sequence)
+	assert.NotZero(t, entriesB[1].Flags&channels.Removed)
+}
+
 // Generator for processEntry
 type testProcessEntryFeed struct {
 	nextSeq     uint64
@@ -1905,36 +1958,79 @@ func BenchmarkProcessEntry(b *testing.B) {
 		name           string
 		feed           *testProcessEntryFeed
 		warmCacheCount int
+		batchSize      int // When greater than 1, entries are passed to processEntries in batches of this size
 	}{
 		{
 			"SingleThread_OrderedFeed_NoActiveChannels",
 			NewTestProcessEntryFeed(100, 1),
 			0,
+			1,
 		},
 		{
 			"SingleThread_OrderedFeed_ActiveChannels",
 			NewTestProcessEntryFeed(100, 1),
 			100,
+			1,
 		},
 		{
 			"SingleThread_OrderedFeed_ManyActiveChannels",
 			NewTestProcessEntryFeed(35000, 1),
 			35000,
+			1,
 		},
 		{
 			"SingleThread_NonOrderedFeed_NoActiveChannels",
 			NewTestProcessEntryFeed(100, 10),
 			0,
+			1,
 		},
 		{
 			"SingleThread_NonOrderedFeed_ActiveChannels",
 			NewTestProcessEntryFeed(100, 10),
 			100,
+			1,
 		},
 		{
 			"SingleThread_NonOrderedFeed_ManyActiveChannels",
 			NewTestProcessEntryFeed(35000, 10),
 			35000,
+			1,
+		},
+		{
+			"SingleThread_Batch100_OrderedFeed_NoActiveChannels",
+			NewTestProcessEntryFeed(100, 1),
+			0,
+			100,
+		},
+		{
+			"SingleThread_Batch100_OrderedFeed_ActiveChannels",
+			NewTestProcessEntryFeed(100, 1),
+			100,
+			100,
+		},
+		{
+			"SingleThread_Batch100_OrderedFeed_ManyActiveChannels",
+			NewTestProcessEntryFeed(35000, 1),
+			35000,
+			100,
+		},
+		{
+			"SingleThread_Batch100_NonOrderedFeed_NoActiveChannels",
+			NewTestProcessEntryFeed(100, 10),
+			0,
+			100,
+		},
+		{
+			"SingleThread_Batch100_NonOrderedFeed_ActiveChannels",
+			NewTestProcessEntryFeed(100, 10),
+			100,
+			100,
+		},
+		{
+			"SingleThread_Batch100_NonOrderedFeed_ManyActiveChannels",
+			NewTestProcessEntryFeed(35000, 10),
+			35000,
+			100,
 		},
 	}
 
@@ -1979,6 +2075,17 @@ func BenchmarkProcessEntry(b *testing.B) {
 			bm.feed.reset()
 
 			b.ResetTimer()
+			if bm.batchSize > 1 {
+				batch := make([]*LogEntry, 0, bm.batchSize)
+				for i := 0; i < b.N; i++ {
+					batch = append(batch, bm.feed.Next())
+					if len(batch) == bm.batchSize || i == b.N-1 {
+						_ = changeCache.processEntries(ctx, batch)
+						batch = batch[:0]
+					}
+				}
+				return
+			}
 			for i := 0; i < b.N; i++ {
 				entry := bm.feed.Next()
 				_ = changeCache.processEntry(ctx, entry)
@@ -1987,6 +2094,43 @@ func BenchmarkProcessEntry(b *testing.B) {
 	}
 }
 
+// BenchmarkProcessEntryCollections measures concurrent processEntry throughput when entries are spread across
+// collections, which are added to the channel cache by independent shards.
+func BenchmarkProcessEntryCollections(b *testing.B) {
+	base.SetUpBenchmarkLogging(b, base.LevelError, base.KeyCache, base.KeyChanges)
+	for _, numCollections := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("Collections_%d", numCollections), func(b *testing.B) {
+			ctx := base.TestCtx(b)
+			context, err := NewDatabaseContext(ctx, "db", base.GetTestBucket(b), false, DatabaseContextOptions{})
+			require.NoError(b, err)
+			defer context.Close(ctx)
+			require.NoError(b, context.StartOnlineProcesses(ctx))
+
+			ctx = context.AddDatabaseLogContext(ctx)
+			changeCache := &changeCache{}
+			require.NoError(b, changeCache.Init(ctx, context, context.channelCache, nil, nil, context.MetadataKeys))
+			require.NoError(b, changeCache.Start(0))
+			defer changeCache.Stop(ctx)
+
+			var lastSeq uint64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					seq := atomic.AddUint64(&lastSeq, 1)
+					_ = changeCache.processEntry(ctx, &LogEntry{
+						Sequence:     seq,
+						DocID:        fmt.Sprintf("doc_%d", seq),
+						RevID:        "1-a",
+						TimeReceived: time.Now(),
+						CollectionID: uint32(seq % uint64(numCollections)),
+						Channels:     channels.ChannelMap{fmt.Sprintf("channel_%d", seq%100): nil},
+					})
+				}
+			})
+		})
+	}
+}
+
 type testDocChangedFeed struct {
 	nextSeq      uint64
 	channelNames []string
@@ -2187,6 +2331,112 @@ func BenchmarkDocChanged(b *testing.B) {
 	}
 }
 
+// newNotifyCountingChangeCache returns a started change cache that counts the notifications it issues, and the
+// channels notified, along with a function returning the next doc mutation from a feed for the database's collection.
+func newNotifyCountingChangeCache(tb testing.TB) (ctx context.Context, cache *changeCache, nextEvent func() sgbucket.FeedEvent, notifyCount *int64, notified channels.Set) {
+	ctx = base.TestCtx(tb)
+	bucket := base.GetTestBucket(tb)
+	dbContext, err := NewDatabaseContext(ctx, "db", bucket, false, DatabaseContextOptions{Scopes: GetScopesOptions(tb, bucket, 1)})
+	require.NoError(tb, err)
+	tb.Cleanup(func() { dbContext.Close(ctx) })
+	require.NoError(tb, dbContext.StartOnlineProcesses(ctx))
+
+	ctx = dbContext.AddDatabaseLogContext(ctx)
+	notifyCount = new(int64)
+	notified = channels.Set{}
+	var notifiedLock sync.Mutex
+	notifyChange := func(_ context.Context, changedChannels channels.Set) {
+		notifiedLock.Lock()
+		defer notifiedLock.Unlock()
+		*notifyCount++
+		for channel := range changedChannels {
+			notified.Add(channel)
+		}
+	}
+	cache = &changeCache{}
+	require.NoError(tb, cache.Init(ctx, dbContext, dbContext.channelCache, notifyChange, nil, dbContext.MetadataKeys))
+	require.NoError(tb, cache.Start(0))
+	tb.Cleanup(func() { cache.Stop(ctx) })
+
+	collectionID := GetSingleDatabaseCollection(tb, dbContext).GetCollectionID()
+	feed := NewTestDocChangedFeed(10, 1)
+	nextEvent = func() sgbucket.FeedEvent {
+		event := feed.Next()
+		event.CollectionID = collectionID
+		return event
+	}
+	return ctx, cache, nextEvent, notifyCount, notified
+}
+
+// TestChangeCacheNotifyBatch ensures docs arriving during a notification batch result in a single notification for
+// the union of their channels.
+func TestChangeCacheNotifyBatch(t *testing.T) {
+	ctx, cache, nextEvent, notifyCount, notified := newNotifyCountingChangeCache(t)
+
+	cache.DocChanged(nextEvent())
+	assert.Equal(t, int64(1), *notifyCount)
+
+	cache.startNotifyBatch()
+	for i := 0; i < 20; i++ {
+		cache.DocChanged(nextEvent())
+	}
+	assert.Equal(t, int64(1), *notifyCount)
+	cache.endNotifyBatch(ctx, 21, time.Second)
+	assert.Equal(t, int64(2), *notifyCount)
+	assert.NotEmpty(t, notified)
+
+	// Notifications resume once the batch has ended
+	cache.DocChanged(nextEvent())
+	assert.Equal(t, int64(3), *notifyCount)
+
+	// Nested batches notify once the last batch ends
+	cache.startNotifyBatch()
+	cache.startNotifyBatch()
+	cache.DocChanged(nextEvent())
+	cache.endNotifyBatch(ctx, 0, time.Second)
+	assert.Equal(t, int64(3), *notifyCount)
+	cache.endNotifyBatch(ctx, 0, time.Second)
+	assert.Equal(t, int64(4), *notifyCount)
+
+	// A batch waiting on a sequence that never arrives notifies after the max wait
+	cache.startNotifyBatch()
+	cache.DocChanged(nextEvent())
+	cache.endNotifyBatch(ctx, 1000, 50*time.Millisecond)
+	assert.Equal(t, int64(5), *notifyCount)
+}
+
+// BenchmarkDocChangedNotify compares the number of change notifications (and so changes feed wake-ups) issued for
+// bulk writes of 100 docs, with and without notification batching.
+func BenchmarkDocChangedNotify(b *testing.B) {
+	base.SetUpBenchmarkLogging(b, base.LevelError, base.KeyCache, base.KeyChanges)
+	const docsPerBulkWrite = 100
+	for _, batched := range []bool{false, true} {
+		name := "PerDocNotify"
+		if batched {
+			name = "BatchedNotify"
+		}
+		b.Run(name, func(b *testing.B) {
+			ctx, cache, nextEvent, notifyCount, _ := newNotifyCountingChangeCache(b)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if batched {
+					cache.startNotifyBatch()
+				}
+				for j := 0; j < docsPerBulkWrite; j++ {
+					cache.DocChanged(nextEvent())
+				}
+				if batched {
+					// DocChanged is synchronous, so there's no need to wait for the written sequences
+					cache.endNotifyBatch(ctx, 0, 0)
+				}
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(*notifyCount)/float64(b.N), "notifies/op")
+		})
+	}
+}
+
 func TestInvalidXattrStream(t *testing.T) {
 
 	body, xattr, userXattr, err := parseXattrStreamData(base.SyncXattrName, "", []byte("abcde"))
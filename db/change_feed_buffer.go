@@ -0,0 +1,108 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"time"
+
+	sgbucket "github.com/couchbase/sg-bucket"
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// changeFeedBufferWorkers is the number of goroutines passing buffered feed events to the change cache.  Events are
+// assigned to a worker by vbucket, so events from the same vbucket are processed in the order received.
+const changeFeedBufferWorkers = 16
+
+// changeFeedBufferMaxBatch is the maximum number of pending events a worker drains from its queue to pass to the change
+// cache as a single batch.
+const changeFeedBufferMaxBatch = 100
+
+// changeFeedBuffer is a bounded buffer between the mutation feed and the change cache.  When the buffer for a vbucket's
+// worker is full, the feed callback blocks until there's room, which pauses acknowledgement of the feed's events so
+// that the server stops sending more, rather than the change cache's pending buffers growing without bound.
+type changeFeedBuffer struct {
+	docChanged func([]sgbucket.FeedEvent)
+	queues     []chan sgbucket.FeedEvent
+	stats      *base.CacheStats
+	terminator chan bool
+}
+
+// newChangeFeedBuffer starts workers passing up to size buffered events to docChanged in batches, until terminator is
+// closed.
+func newChangeFeedBuffer(docChanged func([]sgbucket.FeedEvent), size int, stats *base.CacheStats, terminator chan bool) *changeFeedBuffer {
+	queueSize := size / changeFeedBufferWorkers
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	b := &changeFeedBuffer{
+		docChanged: docChanged,
+		queues:     make([]chan sgbucket.FeedEvent, changeFeedBufferWorkers),
+		stats:      stats,
+		terminator: terminator,
+	}
+	for i := range b.queues {
+		b.queues[i] = make(chan sgbucket.FeedEvent, queueSize)
+		go b.run(b.queues[i])
+	}
+	return b
+}
+
+// DocChanged buffers a feed event for the change cache, blocking while the buffer is full.
+func (b *changeFeedBuffer) DocChanged(event sgbucket.FeedEvent) {
+	queue := b.queues[int(event.VbNo)%len(b.queues)]
+	select {
+	case queue <- event:
+		b.stats.FeedBufferDepth.Add(1)
+		return
+	default:
+	}
+
+	pauseStart := time.Now()
+	select {
+	case queue <- event:
+		b.stats.FeedBufferDepth.Add(1)
+	case <-b.terminator:
+	}
+	b.stats.FeedBufferPauseCount.Add(1)
+	b.stats.FeedBufferPauseTime.Add(time.Since(pauseStart).Nanoseconds())
+}
+
+// run passes the events from a queue to the change cache until the cache is stopped.  Events that are already pending
+// when the worker takes an event are drained with it, up to changeFeedBufferMaxBatch, and passed on as one batch.
+func (b *changeFeedBuffer) run(queue chan sgbucket.FeedEvent) {
+	batch := make([]sgbucket.FeedEvent, 0, changeFeedBufferMaxBatch)
+	for {
+		select {
+		case event := <-queue:
+			batch = append(batch[:0], event)
+		drain:
+			for len(batch) < changeFeedBufferMaxBatch {
+				select {
+				case event := <-queue:
+					batch = append(batch, event)
+				default:
+					break drain
+				}
+			}
+			b.stats.FeedBufferDepth.Add(-int64(len(batch)))
+			b.docChanged(batch)
+		case <-b.terminator:
+			return
+		}
+	}
+}
+
+// feedCallback returns the callback for the mutation feed to pass events to the change cache, through the feed
+// buffer if one is configured.
+func (c *changeCache) feedCallback() DocChangedFunc {
+	if c.feedBuffer != nil {
+		return c.feedBuffer.DocChanged
+	}
+	return c.DocChanged
+}
@@ -0,0 +1,113 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	sgbucket "github.com/couchbase/sg-bucket"
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeFeedBufferBackpressure(t *testing.T) {
+	stats, err := base.NewSyncGatewayStats()
+	require.NoError(t, err)
+	dbstats, err := stats.NewDBStats("", false, false, false, nil, nil)
+	require.NoError(t, err)
+	cacheStats := dbstats.Cache()
+
+	terminator := make(chan bool)
+	defer close(terminator)
+
+	// Block the change cache until released, to fill the buffer
+	release := make(chan struct{})
+	var lock sync.Mutex
+	var received []string
+	docChanged := func(events []sgbucket.FeedEvent) {
+		<-release
+		lock.Lock()
+		for _, event := range events {
+			received = append(received, string(event.Key))
+		}
+		lock.Unlock()
+	}
+	buffer := newChangeFeedBuffer(docChanged, changeFeedBufferWorkers, cacheStats, terminator)
+
+	// The worker for vbucket 0 holds one event while blocked, and its queue holds one more
+	buffer.DocChanged(sgbucket.FeedEvent{VbNo: 0, Key: []byte("doc1")})
+	require.Eventually(t, func() bool { return cacheStats.FeedBufferDepth.Value() == 0 }, 10*time.Second, time.Millisecond)
+	buffer.DocChanged(sgbucket.FeedEvent{VbNo: 0, Key: []byte("doc2")})
+	assert.Equal(t, int64(1), cacheStats.FeedBufferDepth.Value())
+	assert.Equal(t, int64(0), cacheStats.FeedBufferPauseCount.Value())
+
+	// A full buffer pauses the feed until the change cache catches up
+	paused := make(chan struct{})
+	go func() {
+		buffer.DocChanged(sgbucket.FeedEvent{VbNo: 0, Key: []byte("doc3")})
+		close(paused)
+	}()
+	select {
+	case <-paused:
+		t.Fatal("Expected feed to be paused while the buffer is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-paused
+
+	require.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(received) == 3
+	}, 10*time.Second, time.Millisecond)
+
+	// Events from the same vbucket are passed on in order
+	assert.Equal(t, []string{"doc1", "doc2", "doc3"}, received)
+	assert.Equal(t, int64(0), cacheStats.FeedBufferDepth.Value())
+	assert.Equal(t, int64(1), cacheStats.FeedBufferPauseCount.Value())
+	assert.Greater(t, cacheStats.FeedBufferPauseTime.Value(), int64(0))
+}
+
+func TestChangeFeedBufferBatches(t *testing.T) {
+	stats, err := base.NewSyncGatewayStats()
+	require.NoError(t, err)
+	dbstats, err := stats.NewDBStats("", false, false, false, nil, nil)
+	require.NoError(t, err)
+	cacheStats := dbstats.Cache()
+
+	terminator := make(chan bool)
+	defer close(terminator)
+
+	release := make(chan struct{})
+	batches := make(chan []string, 10)
+	docChanged := func(events []sgbucket.FeedEvent) {
+		<-release
+		var docIDs []string
+		for _, event := range events {
+			docIDs = append(docIDs, string(event.Key))
+		}
+		batches <- docIDs
+	}
+	buffer := newChangeFeedBuffer(docChanged, 10*changeFeedBufferWorkers, cacheStats, terminator)
+
+	// Events queued while the change cache is busy are drained together as a single batch
+	buffer.DocChanged(sgbucket.FeedEvent{VbNo: 0, Key: []byte("doc1")})
+	require.Eventually(t, func() bool { return cacheStats.FeedBufferDepth.Value() == 0 }, 10*time.Second, time.Millisecond)
+	for _, docID := range []string{"doc2", "doc3", "doc4"} {
+		buffer.DocChanged(sgbucket.FeedEvent{VbNo: 0, Key: []byte(docID)})
+	}
+	close(release)
+
+	assert.Equal(t, []string{"doc1"}, <-batches)
+	assert.Equal(t, []string{"doc2", "doc3", "doc4"}, <-batches)
+	assert.Equal(t, int64(0), cacheStats.FeedBufferDepth.Value())
+}
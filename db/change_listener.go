@@ -76,31 +76,32 @@ func (listener *changeListener) Start(ctx context.Context, bucket base.Bucket, d
 	if len(scopes) > 0 {
 		// build the set of collections to be requested
 
-		// Add the metadata collection first
-		metadataStoreName, ok := base.AsDataStoreName(metadataStore)
-		if !ok {
-			return fmt.Errorf("changeListener started with collections, but unable to retrieve metadata store name for %T", metadataStore)
-		}
-
-		metadataStoreFoundInScopes := false
 		scopeArgs := make(map[string][]string)
 		for scopeName, scope := range scopes {
+			if len(scope.Collections) == 0 {
+				continue
+			}
 			collections := make([]string, 0)
 			for collectionName, _ := range scope.Collections {
 				collections = append(collections, collectionName)
-				if scopeName == metadataStoreName.ScopeName() && collectionName == metadataStoreName.CollectionName() {
-					metadataStoreFoundInScopes = true
-				}
 			}
 			scopeArgs[scopeName] = collections
 		}
 
-		// If the metadataStore's collection isn't already present in the list of scopes, add it to the DCP scopes
-		if !metadataStoreFoundInScopes {
-			_, ok = scopeArgs[metadataStoreName.ScopeName()]
+		// Add the metadata collection, if not already present in the list of scopes. The feed for a federated bucket
+		// doesn't have a metadata store, as all metadata is stored in the database's bucket.
+		if metadataStore != nil {
+			metadataStoreName, ok := base.AsDataStoreName(metadataStore)
 			if !ok {
-				scopeArgs[metadataStoreName.ScopeName()] = []string{metadataStoreName.CollectionName()}
-			} else {
+				return fmt.Errorf("changeListener started with collections, but unable to retrieve metadata store name for %T", metadataStore)
+			}
+			metadataStoreFoundInScopes := false
+			for _, collectionName := range scopeArgs[metadataStoreName.ScopeName()] {
+				if collectionName == metadataStoreName.CollectionName() {
+					metadataStoreFoundInScopes = true
+				}
+			}
+			if !metadataStoreFoundInScopes {
 				scopeArgs[metadataStoreName.ScopeName()] = append(scopeArgs[metadataStoreName.ScopeName()], metadataStoreName.CollectionName())
 			}
 		}
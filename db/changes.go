@@ -26,19 +26,23 @@ import (
 // Options for changes-feeds.  ChangesOptions must not contain any mutable pointer references, as
 // changes processing currently assumes a deep copy when doing chanOpts := changesOptions.
 type ChangesOptions struct {
-	Since          SequenceID      // sequence # to start _after_
-	Limit          int             // Max number of changes to return, if nonzero
-	Conflicts      bool            // Show all conflicting revision IDs, not just winning one?
-	IncludeDocs    bool            // Include doc body of each change?
-	Wait           bool            // Wait for results, instead of immediately returning empty result?
-	Continuous     bool            // Run continuously until terminated?
-	RequestPlusSeq uint64          // Do not stop changes before cached sequence catches up with requestPlusSeq
-	HeartbeatMs    uint64          // How often to send a heartbeat to the client
-	TimeoutMs      uint64          // After this amount of time, close the longpoll connection
-	ActiveOnly     bool            // If true, only return information on non-deleted, non-removed revisions
-	Revocations    bool            // Specifies whether revocation messages should be sent on the changes feed
-	clientType     clientType      // Can be used to determine if the replication is being started from a CBL 2.x or SGR2 client
-	ChangesCtx     context.Context // Used for cancelling checking the changes feed should stop
+	Since          SequenceID           // sequence # to start _after_
+	Limit          int                  // Max number of changes to return, if nonzero
+	Conflicts      bool                 // Show all conflicting revision IDs, not just winning one?
+	IncludeDocs    bool                 // Include doc body of each change?
+	Wait           bool                 // Wait for results, instead of immediately returning empty result?
+	Continuous     bool                 // Run continuously until terminated?
+	RequestPlusSeq uint64               // Do not stop changes before cached sequence catches up with requestPlusSeq
+	HeartbeatMs    uint64               // How often to send a heartbeat to the client
+	TimeoutMs      uint64               // After this amount of time, close the longpoll connection
+	HeartbeatStyle string               // How heartbeats are written to HTTP feeds: "newline" or "comment"
+	ActiveOnly     bool                 // If true, only return information on non-deleted, non-removed revisions
+	Revocations    bool                 // Specifies whether revocation messages should be sent on the changes feed
+	ShowChannels   bool                 // Include the document's current channels in each change? Requires ChangesIncludeChannels
+	clientType     clientType           // Can be used to determine if the replication is being started from a CBL 2.x or SGR2 client
+	ChangesCtx     context.Context      // Used for cancelling checking the changes feed should stop
+	trace          *changesTrace        // Records cache and query work for slow changes logging. Nil when slow changes logging is disabled
+	subscription   *changesSubscription // Channel and docID filters that can be updated on an active continuous feed. Nil when the feed can't be updated
 }
 
 // A changes entry; Database.GetChanges returns an array of these.
@@ -56,7 +60,9 @@ type ChangeEntry struct {
 	backfill     backfillFlag // Flag used to identify non-client entries used for backfill synchronization (di only)
 	principalDoc bool         // Used to indicate _user/_role docs
 	Revoked      bool         `json:"revoked,omitempty"`
+	Channels     []string     `json:"channels,omitempty"` // The document's current channels, when requested by an admin feed
 	collectionID uint32
+	docChannels  []string // The document's current channels retained by the channel cache, if any
 }
 
 const (
@@ -145,6 +151,25 @@ func (db *DatabaseCollectionWithUser) AddDocToChangeEntryUsingRevCache(ctx conte
 	return err
 }
 
+// addChannelsToChangeEntry adds the document's current channels to a ChangeEntry, using the channels retained by the
+// channel cache.  When they weren't retained, such as for entries cached before ChangesIncludeChannels was enabled, the
+// channels are read from the document's sync metadata.
+func (db *DatabaseCollectionWithUser) addChannelsToChangeEntry(ctx context.Context, entry *ChangeEntry) {
+	if entry.principalDoc {
+		return
+	}
+	if entry.docChannels != nil {
+		entry.Channels = entry.docChannels
+		return
+	}
+	syncData, err := db.GetDocSyncData(ctx, entry.ID)
+	if err != nil {
+		base.WarnfCtx(ctx, "Changes feed: error getting channels of doc %q: %v", base.UD(entry.ID), err)
+		return
+	}
+	entry.Channels = syncData.Channels.ActiveKeys()
+}
+
 // Adds a document body and/or its conflicts to a ChangeEntry
 func (db *DatabaseCollectionWithUser) AddDocInstanceToChangeEntry(ctx context.Context, entry *ChangeEntry, doc *Document, options ChangesOptions) {
 
@@ -230,7 +255,12 @@ func (db *DatabaseCollectionWithUser) buildRevokedFeed(ctx context.Context, ch c
 			base.TracefCtx(ctx, base.KeyChanges, "Querying channel %q for revocation with options: %+v", base.UD(singleChannelCache.ChannelID().Name), paginationOptions)
 			changes, err := singleChannelCache.GetChanges(ctx, paginationOptions)
 			if err != nil {
-				base.WarnfCtx(ctx, "Error retrieving changes for channel %q: %v", base.UD(singleChannelCache.ChannelID()), err)
+				// A query cancelled by the changes request being closed isn't a failure worth warning about
+				if options.ChangesCtx.Err() != nil {
+					base.DebugfCtx(ctx, base.KeyChanges, "Revocation feed %s cancelled while retrieving changes: %v", base.UD(to), err)
+				} else {
+					base.WarnfCtx(ctx, "Error retrieving changes for channel %q: %v", base.UD(singleChannelCache.ChannelID()), err)
+				}
 				change := ChangeEntry{
 					Err: base.ErrChannelFeed,
 				}
@@ -333,7 +363,7 @@ func UserHasDocAccess(ctx context.Context, collection *DatabaseCollectionWithUse
 		return true, nil
 	}
 
-	authErr := collection.user.AuthorizeAnyCollectionChannel(collection.ScopeName, collection.Name, currentRev.Channels)
+	authErr := collection.authorizeReadChannels(ctx, currentRev.Channels)
 	return authErr == nil, nil
 }
 
@@ -345,6 +375,10 @@ func (col *DatabaseCollectionWithUser) wasDocInChannelPriorToRevocation(ctx cont
 		return false, err
 	}
 
+	if err := col.loadExternalSyncMetadata(ctx, docID, &syncData); err != nil {
+		return false, err
+	}
+
 	// Iterate over the channel history information on the document and find any periods where the doc was in the
 	// channel and the channel was accessible by the user
 	isStarChan := chanName == channels.UserStarChannel
@@ -379,6 +413,24 @@ func (col *DatabaseCollectionWithUser) wasDocInChannelPriorToRevocation(ctx cont
 	return false, nil
 }
 
+// inSystemChannelsOnly returns true if a document's active channels are all system channels.
+func (context *DatabaseContext) inSystemChannelsOnly(docChannels channels.ChannelMap) bool {
+	if len(context.Options.SystemChannels) == 0 {
+		return false
+	}
+	inSystemChannel := false
+	for channel, removal := range docChannels {
+		if removal != nil {
+			continue
+		}
+		if !context.Options.SystemChannels.Contains(channel) {
+			return false
+		}
+		inSystemChannel = true
+	}
+	return inSystemChannel
+}
+
 // Creates a Go-channel of all the changes made on a channel.
 // Does NOT handle the Wait option. Does NOT check authorization.
 func (db *DatabaseCollectionWithUser) changesFeed(ctx context.Context, singleChannelCache SingleChannelCache, options ChangesOptions, to string) <-chan *ChangeEntry {
@@ -395,6 +447,9 @@ func (db *DatabaseCollectionWithUser) changesFeed(ctx context.Context, singleCha
 	paginationOptions.Since.Seq = options.Since.SafeSequence()
 	paginationOptions.Since.LowSeq = 0
 
+	filterSystemChannels := db.user != nil && len(db.dbCtx.Options.SystemChannels) > 0 &&
+		singleChannelCache.ChannelID().Name == channels.UserStarChannel
+
 	go func() {
 		defer base.FatalPanicHandler()
 		defer close(feed)
@@ -416,7 +471,12 @@ func (db *DatabaseCollectionWithUser) changesFeed(ctx context.Context, singleCha
 			base.TracefCtx(ctx, base.KeyChanges, "Querying channel %q with options: %+v", base.UD(singleChannelCache.ChannelID().Name), paginationOptions)
 			changes, err := singleChannelCache.GetChanges(ctx, paginationOptions)
 			if err != nil {
-				base.WarnfCtx(ctx, "Error retrieving changes for channel %q: %v", base.UD(singleChannelCache.ChannelID().Name), err)
+				// A query cancelled by the changes request being closed isn't a failure worth warning about
+				if options.ChangesCtx.Err() != nil {
+					base.DebugfCtx(ctx, base.KeyChanges, "Channel feed %s cancelled while retrieving changes: %v", base.UD(to), err)
+				} else {
+					base.WarnfCtx(ctx, "Error retrieving changes for channel %q: %v", base.UD(singleChannelCache.ChannelID().Name), err)
+				}
 				change := ChangeEntry{
 					Err: base.ErrChannelFeed,
 				}
@@ -439,6 +499,11 @@ func (db *DatabaseCollectionWithUser) changesFeed(ctx context.Context, singleCha
 				change := makeChangeEntry(logEntry, seqID, singleChannelCache.ChannelID())
 				lastSeq = logEntry.Sequence
 
+				// Users only see documents in system channels when explicitly granted them, not through the star channel
+				if filterSystemChannels && logEntry.SystemOnly {
+					continue
+				}
+
 				// Don't include deletes or removals during initial channel backfill
 				if options.Since.TriggeredBy > 0 && (change.Deleted || len(change.Removed) > 0) {
 					continue
@@ -480,6 +545,7 @@ func makeChangeEntry(logEntry *LogEntry, seqID SequenceID, channel channels.ID)
 		branched:     (logEntry.Flags & channels.Branched) != 0,
 		principalDoc: logEntry.IsPrincipal,
 		collectionID: logEntry.CollectionID,
+		docChannels:  logEntry.DocChannels,
 	}
 	if logEntry.Flags&channels.Removed != 0 {
 		change.Removed = base.SetOf(channel.Name)
@@ -611,6 +677,10 @@ func (col *DatabaseCollectionWithUser) SimpleMultiChangesFeed(ctx context.Contex
 		to = fmt.Sprintf("  (to %s)", col.user.Name())
 	}
 
+	// Sequences sent are in the current epoch, so a since value from an earlier epoch starts from zero
+	options.Since = col.dbCtx.SinceForCurrentEpoch(ctx, options.Since)
+	feedEpoch := options.Since.Epoch
+
 	base.InfofCtx(ctx, base.KeyChanges, "MultiChangesFeed(channels: %s, options: %s) ... %s", base.UD(chans), options, base.UD(to))
 	output := make(chan *ChangeEntry, 50)
 
@@ -637,7 +707,19 @@ func (col *DatabaseCollectionWithUser) SimpleMultiChangesFeed(ctx context.Contex
 		var deferredBackfill bool                // Whether there's a backfill identified in the user doc that's deferred while the SG cache catches up
 
 		// Retrieve the current max cached sequence - ensures there isn't a race between the subsequent channel cache queries
-		currentCachedSequence = col.changeCache().getChannelCache().GetHighCacheSequence()
+		currentCachedSequence = col.changeCache().getCollectionHighCacheSequence(col.GetCollectionID())
+
+		// Revocations aren't sent while an initial active only replication is catching up, but documents sent during
+		// catch up may be in channels revoked before it completes.  Once caught up, revocations since the start of the
+		// replication are sent.  A replication from zero hasn't been sent anything from channels revoked before now.
+		var revocationsSince *SequenceID
+		if options.ActiveOnly && options.Revocations && options.Continuous && options.clientType == clientTypeCBL2 {
+			since := options.Since
+			if since.Seq == 0 {
+				since = SequenceID{Seq: currentCachedSequence}
+			}
+			revocationsSince = &since
+		}
 
 		// If changes feed requires more than one ChangesLoop iteration, initialize changeWaiter
 		if options.Wait || options.RequestPlusSeq > currentCachedSequence {
@@ -668,7 +750,7 @@ func (col *DatabaseCollectionWithUser) SimpleMultiChangesFeed(ctx context.Contex
 		var channelsSince channels.TimedSet
 		if col.user != nil {
 			var channelsRemoved []string
-			channelsSince, channelsRemoved = col.user.FilterToAvailableCollectionChannels(col.ScopeName, col.Name, chans)
+			channelsSince, channelsRemoved = col.filterToAvailableChannels(ctx, chans)
 			if len(channelsRemoved) > 0 {
 				base.InfofCtx(ctx, base.KeyChanges, "Channels %s request without access by user %s", base.UD(channelsRemoved), base.UD(col.user.Name()))
 			}
@@ -697,6 +779,15 @@ func (col *DatabaseCollectionWithUser) SimpleMultiChangesFeed(ctx context.Contex
 		// This loop is used to re-run the fetch after every database change, in Wait mode
 	outer:
 		for {
+			// Trace the work done by this iteration, to log if the iteration is slow
+			options.trace = nil
+			if col.slowChangesThreshold() > 0 {
+				options.trace = &changesTrace{}
+			}
+			iterationStart := time.Now()
+			iterationSince := options.Since
+			rowsReturned := 0
+
 			// Updates the ChangeWaiter to the current set of available channels
 			if changeWaiter != nil {
 				changeWaiter.UpdateChannels(col.GetCollectionID(), channelsSince)
@@ -822,9 +913,14 @@ func (col *DatabaseCollectionWithUser) SimpleMultiChangesFeed(ctx context.Contex
 			}
 
 			if options.Revocations && col.user != nil && !options.ActiveOnly {
-				channelsToRevoke := col.user.RevokedCollectionChannels(col.ScopeName, col.Name, options.Since.Seq, options.Since.LowSeq, options.Since.TriggeredBy)
+				revocationOptions := options
+				if revocationsSince != nil {
+					revocationOptions.Since = *revocationsSince
+					revocationsSince = nil
+				}
+				channelsToRevoke := col.user.RevokedCollectionChannels(col.ScopeName, col.Name, revocationOptions.Since.Seq, revocationOptions.Since.LowSeq, revocationOptions.Since.TriggeredBy)
 				for channel, revokedSeq := range channelsToRevoke {
-					revocationSinceSeq := options.Since.SafeSequence()
+					revocationSinceSeq := revocationOptions.Since.SafeSequence()
 					revokeFrom := uint64(0)
 
 					// If we have a triggeredBy sequence:
@@ -834,18 +930,18 @@ func (col *DatabaseCollectionWithUser) SimpleMultiChangesFeed(ctx context.Contex
 					// 0 when finding docs to revoke.
 					// If channel access was after the triggeredBy then we can just use the triggeredBy and need to
 					// check for docs to revoke since 0.
-					if options.Since.TriggeredBy != 0 {
-						if revokedSeq == options.Since.TriggeredBy {
-							revocationSinceSeq = options.Since.TriggeredBy - 1
-							revokeFrom = options.Since.Seq
+					if revocationOptions.Since.TriggeredBy != 0 {
+						if revokedSeq == revocationOptions.Since.TriggeredBy {
+							revocationSinceSeq = revocationOptions.Since.TriggeredBy - 1
+							revokeFrom = revocationOptions.Since.Seq
 						}
-						if revokedSeq > options.Since.TriggeredBy {
-							revocationSinceSeq = options.Since.TriggeredBy
+						if revokedSeq > revocationOptions.Since.TriggeredBy {
+							revocationSinceSeq = revocationOptions.Since.TriggeredBy
 							revokeFrom = 0
 						}
 					}
 
-					feed := col.buildRevokedFeed(ctx, channels.NewID(channel, collectionID), options, revokedSeq, revocationSinceSeq, revokeFrom, to)
+					feed := col.buildRevokedFeed(ctx, channels.NewID(channel, collectionID), revocationOptions, revokedSeq, revocationSinceSeq, revokeFrom, to)
 					feeds = append(feeds, feed)
 				}
 			}
@@ -940,10 +1036,14 @@ func (col *DatabaseCollectionWithUser) SimpleMultiChangesFeed(ctx context.Contex
 				if options.IncludeDocs || options.Conflicts {
 					col.addDocToChangeEntry(ctx, minEntry, options)
 				}
+				if options.ShowChannels {
+					col.addChannelsToChangeEntry(ctx, minEntry)
+				}
 
 				// Update the low sequence on the entry we're going to send
 				// NOTE: if 0, the low seq part of compound sequence gets removed
 				minEntry.Seq.LowSeq = lowSequence
+				minEntry.Seq.Epoch = feedEpoch
 				lastSentLowSeq = lowSequence
 
 				// Send the entry, and repeat the loop:
@@ -955,15 +1055,18 @@ func (col *DatabaseCollectionWithUser) SimpleMultiChangesFeed(ctx context.Contex
 				case output <- minEntry:
 				}
 				sentSomething = true
+				rowsReturned++
 
 				// Stop when we hit the limit (if any):
 				if options.Limit > 0 {
 					options.Limit--
 					if options.Limit == 0 {
+						col.logSlowChanges(ctx, options.trace, chans, iterationSince, iterationStart, rowsReturned)
 						break outer
 					}
 				}
 			}
+			col.logSlowChanges(ctx, options.trace, chans, iterationSince, iterationStart, rowsReturned)
 
 			// Check whether non-continuous changes feeds that aren't waiting to reach requestPlus sequence can exit
 			if !options.Continuous && currentCachedSequence >= options.RequestPlusSeq {
@@ -986,6 +1089,7 @@ func (col *DatabaseCollectionWithUser) SimpleMultiChangesFeed(ctx context.Contex
 			output <- nil
 
 			// If this is an initial replication using CBL 2.x (active only), flip activeOnly now the client has caught up.
+			// From here on tombstones, removals and revocations are sent.
 			if options.clientType == clientTypeCBL2 && options.ActiveOnly {
 				base.DebugfCtx(ctx, base.KeyChanges, "%v MultiChangesFeed initial replication caught up - setting ActiveOnly to false... %s", options.Since, base.UD(to))
 				options.ActiveOnly = false
@@ -1009,6 +1113,12 @@ func (col *DatabaseCollectionWithUser) SimpleMultiChangesFeed(ctx context.Contex
 				waitResponse := changeWaiter.Wait(ctx)
 				col.dbStats().CBLReplicationPull().NumPullReplCaughtUp.Add(-1)
 
+				// A new sequence epoch reinitializes the change cache, so end the feed for the client to start again
+				if col.dbCtx.sequenceIDEpoch() != feedEpoch {
+					base.InfofCtx(ctx, base.KeyChanges, "Sequence epoch changed - terminating changes feed %s", base.UD(to))
+					return
+				}
+
 				if waitResponse == WaiterClosed {
 					break outer
 				} else if waitResponse == WaiterHasChanges {
@@ -1025,10 +1135,14 @@ func (col *DatabaseCollectionWithUser) SimpleMultiChangesFeed(ctx context.Contex
 						return
 					default:
 					}
+					// Run another iteration to pick up channels added to or removed from the subscription
+					if options.subscription.hasPendingUpdate() {
+						break waitForChanges
+					}
 				}
 			}
 			// Update the current max cached sequence for the next changes iteration
-			currentCachedSequence = col.changeCache().getChannelCache().GetHighCacheSequence()
+			currentCachedSequence = col.changeCache().getCollectionHighCacheSequence(col.GetCollectionID())
 
 			// Check whether user channel access has changed while waiting:
 			var err error
@@ -1040,7 +1154,7 @@ func (col *DatabaseCollectionWithUser) SimpleMultiChangesFeed(ctx context.Contex
 				return
 			}
 			if userChanged && col.user != nil {
-				newChannelsSince, _ := col.user.FilterToAvailableCollectionChannels(col.ScopeName, col.Name, chans)
+				newChannelsSince, _ := col.filterToAvailableChannels(ctx, chans)
 				changedChannels = newChannelsSince.CompareKeys(channelsSince)
 
 				if len(changedChannels) > 0 {
@@ -1049,6 +1163,29 @@ func (col *DatabaseCollectionWithUser) SimpleMultiChangesFeed(ctx context.Contex
 				channelsSince = newChannelsSince
 			}
 
+			// Apply any updates made to the subscription's channel filter while waiting
+			if subscriptionChannels, updated := options.subscription.updatedChannels(); updated {
+				chans = subscriptionChannels
+				var newChannelsSince channels.TimedSet
+				if col.user != nil {
+					newChannelsSince, _ = col.filterToAvailableChannels(ctx, chans)
+				} else {
+					newChannelsSince = channels.AtSequence(chans, 0)
+				}
+				subscriptionChangedChannels := newChannelsSince.CompareKeys(channelsSince)
+				if len(subscriptionChangedChannels) > 0 {
+					col.activeChannels().UpdateChanged(ctx, collectionID, subscriptionChangedChannels)
+					if changedChannels == nil {
+						changedChannels = make(channels.ChangedKeys, len(subscriptionChangedChannels))
+					}
+					for chanName, added := range subscriptionChangedChannels {
+						changedChannels[chanName] = added
+					}
+				}
+				base.InfofCtx(ctx, base.KeyChanges, "MultiChangesFeed subscription updated to channels %s %s", base.UD(chans), base.UD(to))
+				channelsSince = newChannelsSince
+			}
+
 			// Clean up inactive lateSequenceFeeds (because user has lost access to the channel)
 			for channel, lateFeed := range lateSequenceFeeds {
 				if !lateFeed.active {
@@ -1073,7 +1210,7 @@ func (col *DatabaseCollectionWithUser) waitForCacheUpdate(ctx context.Context, c
 		case <-ctx.Done():
 			return true
 		case <-ticker.C:
-			if col.changeCache().getChannelCache().GetHighCacheSequence() != currentCachedSequence {
+			if col.changeCache().getCollectionHighCacheSequence(col.GetCollectionID()) != currentCachedSequence {
 				return false
 			}
 		}
@@ -1229,6 +1366,8 @@ func (db *DatabaseCollectionWithUser) closeLateFeeds(ctx context.Context, feeds
 // results. Only supports non-continuous changes, closes buffered channel before returning.
 func (db *DatabaseCollectionWithUser) DocIDChangesFeed(ctx context.Context, userChannels base.Set, explicitDocIds []string, options ChangesOptions) (<-chan *ChangeEntry, error) {
 
+	options.Since = db.dbCtx.SinceForCurrentEpoch(ctx, options.Since)
+
 	// Subroutine that creates a response row for a document:
 	output := make(chan *ChangeEntry, len(explicitDocIds))
 	rowMap := make(map[uint64]*ChangeEntry)
@@ -1238,6 +1377,7 @@ func (db *DatabaseCollectionWithUser) DocIDChangesFeed(ctx context.Context, user
 	for _, docID := range explicitDocIds {
 		row := createChangesEntry(ctx, docID, db, options)
 		if row != nil {
+			row.Seq.Epoch = options.Since.Epoch
 			rowMap[row.Seq.Seq] = row
 			sequences = append(sequences, row.Seq.Seq)
 		}
@@ -1314,6 +1454,9 @@ func createChangesEntry(ctx context.Context, docid string, db *DatabaseCollectio
 	if options.IncludeDocs || options.Conflicts {
 		db.AddDocInstanceToChangeEntry(ctx, row, populatedDoc, options)
 	}
+	if options.ShowChannels {
+		row.Channels = populatedDoc.Channels.ActiveKeys()
+	}
 
 	return row
 }
@@ -0,0 +1,119 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+)
+
+// changesSubscription holds the channel and docID filters of a running continuous changes feed, which can be updated
+// while the feed is active. Channel updates are applied by the feed on its next iteration, and docID updates apply to
+// the next change sent. A nil *changesSubscription represents a feed that can't be updated.
+type changesSubscription struct {
+	lock            sync.Mutex
+	channels        base.Set // Channels the feed is filtered to, or nil for all channels
+	docIDs          base.Set // DocIDs the feed is filtered to, or nil for all docs
+	channelsUpdated bool     // Whether channels has been updated since the feed last read them
+}
+
+// newChangesSubscription returns a subscription for a feed filtered to the given channels and docIDs.
+func newChangesSubscription(chans base.Set, docIDs []string) *changesSubscription {
+	s := &changesSubscription{}
+	if len(chans) > 0 && !chans.Contains(channels.AllChannelWildcard) {
+		s.channels = updatedSet(chans, nil, nil)
+	}
+	if len(docIDs) > 0 {
+		s.docIDs = base.SetFromArray(docIDs)
+	}
+	return s
+}
+
+// update adds and removes channels and docIDs from the subscription. Only subscriptions that are already filtered by
+// channel or docID can have channels or docIDs modified, respectively, and at least one channel must remain.
+func (s *changesSubscription) update(addChannels, removeChannels, addDocIDs, removeDocIDs []string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(addChannels) > 0 || len(removeChannels) > 0 {
+		if s.channels == nil {
+			return errors.New("channels can only be updated for a subChanges filtered by channel")
+		}
+		updatedChannels := updatedSet(s.channels, addChannels, removeChannels)
+		if len(updatedChannels) == 0 {
+			return errors.New("at least one channel must remain in the subChanges channel filter")
+		}
+		if updatedChannels.Contains(channels.AllChannelWildcard) {
+			return errors.New("the * channel can't be added to a subChanges channel filter")
+		}
+		s.channels = updatedChannels
+		s.channelsUpdated = true
+	}
+
+	if len(addDocIDs) > 0 || len(removeDocIDs) > 0 {
+		if s.docIDs == nil {
+			return errors.New("docIDs can only be updated for a subChanges filtered by docID")
+		}
+		s.docIDs = updatedSet(s.docIDs, addDocIDs, removeDocIDs)
+	}
+	return nil
+}
+
+// updatedChannels returns the subscription's channels if they've been updated since this was last called.
+func (s *changesSubscription) updatedChannels() (chans base.Set, updated bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.channelsUpdated {
+		return nil, false
+	}
+	s.channelsUpdated = false
+	return updatedSet(s.channels, nil, nil), true
+}
+
+// hasPendingUpdate returns true if the subscription has been updated in a way that requires the feed to run another
+// iteration.
+func (s *changesSubscription) hasPendingUpdate() bool {
+	if s == nil {
+		return false
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.channelsUpdated
+}
+
+// includesDocID returns true if changes for docID should be sent on the feed.
+func (s *changesSubscription) includesDocID(docID string) bool {
+	if s == nil {
+		return true
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.docIDs == nil || s.docIDs.Contains(docID)
+}
+
+// updatedSet returns a copy of set with the given values added and removed.
+func updatedSet(set base.Set, add, remove []string) base.Set {
+	result := make(base.Set, len(set)+len(add))
+	for value := range set {
+		result.Add(value)
+	}
+	for _, value := range add {
+		result.Add(value)
+	}
+	for _, value := range remove {
+		delete(result, value)
+	}
+	return result
+}
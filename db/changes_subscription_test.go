@@ -0,0 +1,63 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"testing"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangesSubscriptionUpdate(t *testing.T) {
+	t.Run("docIDs", func(t *testing.T) {
+		subscription := newChangesSubscription(nil, []string{"doc1", "doc2"})
+		assert.True(t, subscription.includesDocID("doc1"))
+		assert.False(t, subscription.includesDocID("doc3"))
+
+		require.NoError(t, subscription.update(nil, nil, []string{"doc3"}, []string{"doc1"}))
+		assert.False(t, subscription.includesDocID("doc1"))
+		assert.True(t, subscription.includesDocID("doc2"))
+		assert.True(t, subscription.includesDocID("doc3"))
+		assert.False(t, subscription.hasPendingUpdate())
+
+		// Channels can't be updated when the feed isn't filtered by channel
+		assert.Error(t, subscription.update([]string{"ABC"}, nil, nil, nil))
+	})
+
+	t.Run("channels", func(t *testing.T) {
+		subscription := newChangesSubscription(base.SetOf("ABC", "DEF"), nil)
+		assert.True(t, subscription.includesDocID("doc1"))
+		_, updated := subscription.updatedChannels()
+		assert.False(t, updated)
+
+		require.NoError(t, subscription.update([]string{"GHI"}, []string{"ABC"}, nil, nil))
+		assert.True(t, subscription.hasPendingUpdate())
+		chans, updated := subscription.updatedChannels()
+		assert.True(t, updated)
+		assert.Equal(t, base.SetOf("DEF", "GHI"), chans)
+		assert.False(t, subscription.hasPendingUpdate())
+
+		// The channel filter can't be emptied or widened to all channels
+		assert.Error(t, subscription.update(nil, []string{"DEF", "GHI"}, nil, nil))
+		assert.Error(t, subscription.update([]string{"*"}, nil, nil, nil))
+		// DocIDs can't be updated when the feed isn't filtered by docID
+		assert.Error(t, subscription.update(nil, nil, []string{"doc1"}, nil))
+		assert.False(t, subscription.hasPendingUpdate())
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var subscription *changesSubscription
+		assert.True(t, subscription.includesDocID("doc1"))
+		assert.False(t, subscription.hasPendingUpdate())
+		_, updated := subscription.updatedChannels()
+		assert.False(t, updated)
+	})
+}
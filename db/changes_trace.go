@@ -0,0 +1,70 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// changesTrace accumulates the channel cache and query work done by a single iteration of a changes feed, so that
+// slow changes requests can be logged along with the cause of their slowness. Channel feeds for an iteration run
+// concurrently, so counters are updated atomically. A nil *changesTrace ignores all updates.
+type changesTrace struct {
+	cacheHits       atomic.Int64 // Channel cache requests served entirely from the cache
+	cacheMisses     atomic.Int64 // Channel cache requests that required a backfill query
+	backfillQueries atomic.Int64 // Channel queries issued, including for channels that bypass the cache
+	queryRows       atomic.Int64 // Rows returned by channel queries
+}
+
+func (t *changesTrace) cacheHit() {
+	if t != nil {
+		t.cacheHits.Add(1)
+	}
+}
+
+func (t *changesTrace) cacheMiss() {
+	if t != nil {
+		t.cacheMisses.Add(1)
+	}
+}
+
+func (t *changesTrace) backfillQuery(rows int) {
+	if t != nil {
+		t.backfillQueries.Add(1)
+		t.queryRows.Add(int64(rows))
+	}
+}
+
+// slowChangesThreshold is the duration of a changes feed iteration to log as slow, or zero when slow changes logging
+// is disabled. This is controlled at a database level.
+func (c *DatabaseCollection) slowChangesThreshold() time.Duration {
+	return c.dbCtx.Options.SlowChangesThreshold
+}
+
+// logSlowChanges logs a record of a changes feed iteration under the SlowChanges log key, if the iteration took longer
+// than the slow changes threshold.
+func (col *DatabaseCollectionWithUser) logSlowChanges(ctx context.Context, trace *changesTrace, chans base.Set, since SequenceID, startTime time.Time, rowsReturned int) {
+	if trace == nil {
+		return
+	}
+	elapsed := time.Since(startTime)
+	if elapsed < col.slowChangesThreshold() {
+		return
+	}
+	userName := ""
+	if col.user != nil {
+		userName = col.user.Name()
+	}
+	base.InfofCtx(ctx, base.KeySlowChanges, "Slow changes request took %s: user=%q channels=%s since=%s cache_hits=%d cache_misses=%d backfill_queries=%d query_rows=%d rows_returned=%d",
+		elapsed, base.UD(userName), base.UD(chans), since, trace.cacheHits.Load(), trace.cacheMisses.Load(), trace.backfillQueries.Load(), trace.queryRows.Load(), rowsReturned)
+}
@@ -18,6 +18,7 @@ import (
 
 	sgbucket "github.com/couchbase/sg-bucket"
 	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
 )
 
 // One "changes" row in a channelsViewResult
@@ -108,13 +109,16 @@ func (c *DatabaseCollection) getChangesInChannelFromQuery(ctx context.Context, c
 		// Query the view or index
 		queryResults, err := c.QueryChannels(ctx, channelName, startSeq, endSeq, limit, activeOnly)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, c.backfillCancelled(ctx, channelName)
+			}
 			return nil, err
 		}
 		queryRowCount := 0
 
 		// Convert the output to LogEntries.  Channel query and view result rows have different structure, so need to unmarshal independently.
 		highSeq := uint64(0)
-		for {
+		for ctx.Err() == nil {
 			var entry *LogEntry
 			var found bool
 			if usingViews {
@@ -129,6 +133,19 @@ func (c *DatabaseCollection) getChangesInChannelFromQuery(ctx context.Context, c
 
 			queryRowCount++
 
+			// Query results don't include the document's channels, which are needed to exclude documents only in
+			// system channels from star channel feeds, and to include channels in changes feeds when retained
+			excludeSystemOnly := channelName == channels.UserStarChannel && len(c.dbCtx.Options.SystemChannels) > 0
+			if excludeSystemOnly || c.dbCtx.Options.ChangesIncludeChannels {
+				syncData, err := c.GetDocSyncData(ctx, entry.DocID)
+				if err == nil {
+					entry.SystemOnly = excludeSystemOnly && c.dbCtx.inSystemChannelsOnly(syncData.Channels)
+					if c.dbCtx.Options.ChangesIncludeChannels && syncData.CurrentRev == entry.RevID {
+						entry.DocChannels = syncData.Channels.ActiveKeys()
+					}
+				}
+			}
+
 			// If active-only, track the number of non-removal, non-deleted revisions we've seen in the view results
 			// for limit calculation below.
 			if activeOnly {
@@ -142,6 +159,9 @@ func (c *DatabaseCollection) getChangesInChannelFromQuery(ctx context.Context, c
 
 		// Close query results
 		closeErr := queryResults.Close()
+		if ctx.Err() != nil {
+			return nil, c.backfillCancelled(ctx, channelName)
+		}
 		if closeErr != nil {
 			return nil, closeErr
 		}
@@ -186,3 +206,11 @@ func (c *DatabaseCollection) getChangesInChannelFromQuery(ctx context.Context, c
 	c.dbStats().Cache().ViewQueries.Add(1)
 	return entries, nil
 }
+
+// backfillCancelled records that a channel query was cancelled, because the changes requests it was made for were
+// closed, and returns the error to return from it.
+func (c *DatabaseCollection) backfillCancelled(ctx context.Context, channelName string) error {
+	base.DebugfCtx(ctx, base.KeyCache, "    Query for channel %q cancelled: %v", base.UD(channelName), ctx.Err())
+	c.dbStats().Cache().ChannelCacheBackfillsCancelled.Add(1)
+	return ctx.Err()
+}
@@ -31,6 +31,7 @@ var (
 	DefaultChannelCacheMaxNumber       = 50000            // Default of 50k channel caches
 	DefaultCompactHighWatermarkPercent = 80               // Default compaction high watermark (percent of MaxNumber)
 	DefaultCompactLowWatermarkPercent  = 60               // Default compaction low watermark (percent of MaxNumber)
+	DefaultChannelBackfillConcurrency  = 10               // Default maximum number of concurrent channel backfill queries
 )
 
 type ChannelCache interface {
@@ -41,6 +42,9 @@ type ChannelCache interface {
 	// Adds an entry to the cache, returns set of channels it was added to
 	AddToCache(ctx context.Context, change *LogEntry) []channels.ID
 
+	// Adds entries for a single collection to the cache in order, returns set of channels they were added to
+	AddBatchToCache(ctx context.Context, changes []*LogEntry) []channels.ID
+
 	// Notifies the cache of a principal update.  Updates the cache's high sequence
 	AddPrincipal(change *LogEntry)
 
@@ -97,22 +101,27 @@ type channelCacheImpl struct {
 	options              ChannelCacheOptions           // Channel cache options
 	lateSeqLock          sync.RWMutex                  // Coordinates access to late sequence caches
 	highCacheSequence    uint64                        // The highest sequence that has been cached.  Used to initialize validFrom for new singleChannelCaches
-	seqLock              sync.RWMutex                  // Mutex for highCacheSequence
+	collectionHighSeqs   map[uint32]uint64             // The highest sequence that has been cached for each collection, since initialization
+	initialSequence      uint64                        // The sequence the cache was initialized at
+	seqLock              sync.RWMutex                  // Mutex for highCacheSequence, collectionHighSeqs and initialSequence
 	maxChannels          int                           // Maximum number of channels in the cache
 	compactHighWatermark int                           // High Watermark for cache compaction
 	compactLowWatermark  int                           // Low Watermark for cache compaction
 	compactRunning       base.AtomicBool               // Whether compact is currently running
 	activeChannels       *channels.ActiveChannels      // Active channel handler
 	cacheStats           *base.CacheStats              // Map used for cache stats
-	validFromLock        sync.RWMutex                  // Mutex used to avoid race between AddToCache and addChannelCache.  See CBG-520 for more details
+	validFromLocks       map[uint32]*sync.RWMutex      // Per-collection mutexes used to avoid race between AddToCache and addChannelCache.  See CBG-520 for more details
+	validFromLocksLock   sync.Mutex                    // Mutex for validFromLocks
+	backfillPool         *channelBackfillPool          // Runs backfill queries for cache misses, with bounded concurrency
+	maintenanceSchedule  *MaintenanceSchedule          // Aged items are only cleaned while the maintenance window is open
 }
 
 func NewChannelCacheForContext(ctx context.Context, options ChannelCacheOptions, context *DatabaseContext) (*channelCacheImpl, error) {
-	return newChannelCache(ctx, context.Name, options, context.getQueryHandlerForCollection, context.activeChannels, context.DbStats.Cache())
+	return newChannelCache(ctx, context.Name, options, context.getQueryHandlerForCollection, context.activeChannels, context.DbStats.Cache(), context.MaintenanceSchedule)
 }
 
 func newChannelCache(ctx context.Context, dbName string, options ChannelCacheOptions, queryHandlerFactory ChannelQueryHandlerFactory,
-	activeChannels *channels.ActiveChannels, cacheStats *base.CacheStats) (*channelCacheImpl, error) {
+	activeChannels *channels.ActiveChannels, cacheStats *base.CacheStats, maintenanceSchedule *MaintenanceSchedule) (*channelCacheImpl, error) {
 
 	channelCache := &channelCacheImpl{
 		queryHandlerFactory:  queryHandlerFactory,
@@ -125,6 +134,8 @@ func newChannelCache(ctx context.Context, dbName string, options ChannelCacheOpt
 		compactLowWatermark:  int(math.Round(float64(options.CompactLowWatermarkPercent) / 100 * float64(options.MaxNumChannels))),
 		activeChannels:       activeChannels,
 		cacheStats:           cacheStats,
+		backfillPool:         newChannelBackfillPool(options.MaxBackfillConcurrency),
+		maintenanceSchedule:  maintenanceSchedule,
 	}
 	bgt, err := NewBackgroundTask(ctx, "CleanAgedItems", channelCache.cleanAgedItems, options.ChannelCacheAge, channelCache.terminator)
 	if err != nil {
@@ -154,6 +165,8 @@ func (c *channelCacheImpl) Stop(ctx context.Context) {
 func (c *channelCacheImpl) Init(initialSequence uint64) {
 	c.seqLock.Lock()
 	c.highCacheSequence = initialSequence
+	c.initialSequence = initialSequence
+	c.collectionHighSeqs = nil
 	c.seqLock.Unlock()
 }
 
@@ -164,17 +177,50 @@ func (c *channelCacheImpl) GetHighCacheSequence() uint64 {
 	return highSeq
 }
 
-// Updates the high cache sequence if the incoming sequence is higher than current
+// getCollectionHighCacheSequence returns the highest sequence that has been cached for the collection.
+func (c *channelCacheImpl) getCollectionHighCacheSequence(collectionID uint32) uint64 {
+	c.seqLock.RLock()
+	defer c.seqLock.RUnlock()
+	if highSeq, ok := c.collectionHighSeqs[collectionID]; ok {
+		return highSeq
+	}
+	// Nothing has been cached for the collection since the cache was initialized
+	return c.initialSequence
+}
+
+// Updates the high cache sequence for the collection, and overall, if the incoming sequence is higher than current
 // Note: doesn't require compareAndSet on update, as cache expects AddToCache to only be called from
-// a single goroutine
-func (c *channelCacheImpl) updateHighCacheSequence(sequence uint64) {
+// a single goroutine per collection
+func (c *channelCacheImpl) updateHighCacheSequence(collectionID uint32, sequence uint64) {
 	c.seqLock.Lock()
 	if sequence > c.highCacheSequence {
 		c.highCacheSequence = sequence
 	}
+	if sequence > c.collectionHighSeqs[collectionID] && sequence > c.initialSequence {
+		if c.collectionHighSeqs == nil {
+			c.collectionHighSeqs = make(map[uint32]uint64)
+		}
+		c.collectionHighSeqs[collectionID] = sequence
+	}
 	c.seqLock.Unlock()
 }
 
+// getValidFromLock returns the mutex used to avoid races between AddToCache and addChannelCache for the collection.
+// Entries for different collections are added to the cache concurrently, so each collection has its own mutex.
+func (c *channelCacheImpl) getValidFromLock(collectionID uint32) *sync.RWMutex {
+	c.validFromLocksLock.Lock()
+	defer c.validFromLocksLock.Unlock()
+	lock, ok := c.validFromLocks[collectionID]
+	if !ok {
+		if c.validFromLocks == nil {
+			c.validFromLocks = make(map[uint32]*sync.RWMutex)
+		}
+		lock = &sync.RWMutex{}
+		c.validFromLocks[collectionID] = lock
+	}
+	return lock
+}
+
 // GetSingleChannelCache will create the cache for the channel if it doesn't exist.  If the cache is at
 // capacity, will return a bypass channel cache.
 func (c *channelCacheImpl) getSingleChannelCache(ctx context.Context, ch channels.ID) (SingleChannelCache, error) {
@@ -183,12 +229,12 @@ func (c *channelCacheImpl) getSingleChannelCache(ctx context.Context, ch channel
 }
 
 func (c *channelCacheImpl) AddPrincipal(change *LogEntry) {
-	c.updateHighCacheSequence(change.Sequence)
+	c.updateHighCacheSequence(change.CollectionID, change.Sequence)
 }
 
 // Add unused Sequence notifies the cache of an unused sequence update. Updates the cache's high sequence
 func (c *channelCacheImpl) AddUnusedSequence(change *LogEntry) {
-	c.updateHighCacheSequence(change.Sequence)
+	c.updateHighCacheSequence(change.CollectionID, change.Sequence)
 }
 
 // Adds an entry to the appropriate channels' caches, returning the affected channels.  lateSequence
@@ -211,11 +257,12 @@ func (c *channelCacheImpl) AddToCache(ctx context.Context, change *LogEntry) (up
 		defer c.lateSeqLock.Unlock()
 	}
 
-	// Need to acquire the validFromLock prior to checking for active channel caches, to ensure that
+	// Need to acquire the collection's validFromLock prior to checking for active channel caches, to ensure that
 	// any new caches that are added between the check for c.GetActiveChannelCache and the update of
 	// c.highCacheSequence are initialized with the correct validFrom.
 	var explicitStarChannel bool
-	c.validFromLock.Lock()
+	validFromLock := c.getValidFromLock(change.CollectionID)
+	validFromLock.Lock()
 	for channelName, removal := range ch {
 		if removal == nil || removal.Seq == change.Sequence {
 			// If the document has been explicitly added to the star channel by the sync function, don't need to recheck below
@@ -245,8 +292,80 @@ func (c *channelCacheImpl) AddToCache(ctx context.Context, change *LogEntry) (up
 		updatedChannels = append(updatedChannels, channels.NewID(channels.UserStarChannel, change.CollectionID))
 	}
 
-	c.updateHighCacheSequence(change.Sequence)
-	c.validFromLock.Unlock()
+	c.updateHighCacheSequence(change.CollectionID, change.Sequence)
+	validFromLock.Unlock()
+	return updatedChannels
+}
+
+// channelCacheBatch is the entries of a batch being added to a single channel's cache.
+type channelCacheBatch struct {
+	entries  []*LogEntry
+	removals []bool // Whether each entry is a removal from the channel
+}
+
+// AddBatchToCache adds entries for a single collection to the appropriate channels' caches, in the order given, and
+// returns the affected channels.  Entries are grouped by channel, so that each channel's cache is locked once for the
+// batch rather than once per entry.  Doesn't support late sequences, which need adding to the late sequence queues
+// with AddToCache.
+func (c *channelCacheImpl) AddBatchToCache(ctx context.Context, changes []*LogEntry) (updatedChannels []channels.ID) {
+	if len(changes) == 0 {
+		return nil
+	}
+	collectionID := changes[0].CollectionID
+
+	batches := make(map[string]*channelCacheBatch)
+	var channelNames []string
+	addToBatch := func(channelName string, change *LogEntry, isRemoval bool) {
+		batch, ok := batches[channelName]
+		if !ok {
+			batch = &channelCacheBatch{}
+			batches[channelName] = batch
+			channelNames = append(channelNames, channelName)
+		}
+		batch.entries = append(batch.entries, change)
+		batch.removals = append(batch.removals, isRemoval)
+	}
+
+	var highSequence uint64
+	for _, change := range changes {
+		ch := change.Channels
+		change.Channels = nil // not needed anymore, so free some memory
+
+		var explicitStarChannel bool
+		for channelName, removal := range ch {
+			if removal == nil || removal.Seq == change.Sequence {
+				if channelName == channels.UserStarChannel {
+					explicitStarChannel = true
+				}
+				addToBatch(channelName, change, removal != nil)
+			}
+		}
+		if EnableStarChannelLog && !explicitStarChannel {
+			addToBatch(channels.UserStarChannel, change, false)
+		}
+		if change.Sequence > highSequence {
+			highSequence = change.Sequence
+		}
+	}
+
+	// As for AddToCache, the validFromLock is held from checking for active channel caches until the high cache
+	// sequence has been updated
+	validFromLock := c.getValidFromLock(collectionID)
+	validFromLock.Lock()
+	defer validFromLock.Unlock()
+
+	updatedChannels = make([]channels.ID, 0, len(channelNames))
+	for _, channelName := range channelNames {
+		channelID := channels.NewID(channelName, collectionID)
+		if channelCache, ok := c.getActiveChannelCache(ctx, channelID); ok {
+			batch := batches[channelName]
+			channelCache.addBatchToCache(ctx, batch.entries, batch.removals)
+		}
+		// Need to notify even if channel isn't active, for case where number of connected changes channels exceeds cache capacity
+		updatedChannels = append(updatedChannels, channelID)
+	}
+
+	c.updateHighCacheSequence(collectionID, highSequence)
 	return updatedChannels
 }
 
@@ -296,6 +415,10 @@ func (c *channelCacheImpl) GetCachedChanges(ctx context.Context, channel channel
 
 // CleanAgedItems prunes the caches based on age of items. Error returned to fulfill BackgroundTaskFunc signature.
 func (c *channelCacheImpl) cleanAgedItems(ctx context.Context) error {
+	// Deferred to the next run while outside the maintenance window
+	if !c.maintenanceSchedule.InWindow() {
+		return nil
+	}
 
 	callback := func(v interface{}) bool {
 		channelCache := AsSingleChannelCache(ctx, v)
@@ -331,6 +454,7 @@ func (c *channelCacheImpl) getChannelCache(ctx context.Context, channel channels
 	bypassChannelCache := &bypassChannelCache{
 		channel:      channel,
 		queryHandler: queryHandler,
+		backfillPool: c.backfillPool,
 	}
 	c.cacheStats.ChannelCacheBypassCount.Add(1)
 	return bypassChannelCache, nil
@@ -345,6 +469,7 @@ func (c *channelCacheImpl) getBypassChannelCache(ch channels.ID) (SingleChannelC
 	bypassChannelCache := &bypassChannelCache{
 		channel:      ch,
 		queryHandler: queryHandler,
+		backfillPool: c.backfillPool,
 	}
 	return bypassChannelCache, nil
 }
@@ -360,13 +485,13 @@ func AsSingleChannelCache(ctx context.Context, cacheValue interface{}) *singleCh
 	return singleChannelCache
 }
 
-// Adds a new channel to the channel cache.  Locking seqLock is required here to prevent missed data in the following scenario:
+// Adds a new channel to the channel cache.  Locking the collection's validFromLock is required here to prevent missed data in the following scenario:
 //
 //	//     1. addChannelCache issued for channel A
 //	//     2. addChannelCache obtains stable sequence, seq=10
 //	//     3. addToCache (from another goroutine) receives sequence 11 in channel A, but detects that it's inactive (not in c.channelCaches)
 //	//     4. addChannelCache initializes cache with validFrom=10 and adds to c.channelCaches
-//	//  This scenario would result in sequence 11 missing from the cache.  Locking seqLock ensures that
+//	//  This scenario would result in sequence 11 missing from the cache.  Locking validFromLock ensures that
 //	//  step 3 blocks until step 4 is complete (and so sees the channel as active)
 func (c *channelCacheImpl) addChannelCache(ctx context.Context, channel channels.ID) (*singleChannelCacheImpl, bool) {
 
@@ -381,15 +506,17 @@ func (c *channelCacheImpl) addChannelCache(ctx context.Context, channel channels
 		return nil, false
 	}
 
-	c.validFromLock.Lock()
+	validFromLock := c.getValidFromLock(channel.CollectionID)
+	validFromLock.Lock()
 
-	// Everything after the current high sequence will be added to the cache via the feed
-	validFrom := c.GetHighCacheSequence() + 1
+	// Everything after the collection's current high sequence will be added to the cache via the feed
+	validFrom := c.getCollectionHighCacheSequence(channel.CollectionID) + 1
 
 	singleChannelCache :=
 		newChannelCacheWithOptions(ctx, queryHandler, channel, validFrom, c.options, c.cacheStats)
+	singleChannelCache.backfillPool = c.backfillPool
 	cacheValue, created, cacheSize := c.channelCaches.GetOrInsert(channel, singleChannelCache)
-	c.validFromLock.Unlock()
+	validFromLock.Unlock()
 
 	singleChannelCache = AsSingleChannelCache(ctx, cacheValue)
 
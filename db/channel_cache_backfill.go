@@ -0,0 +1,192 @@
+/*
+Copyright 2023-Present Couchbase, Inc.
+
+Use of this software is governed by the Business Source License included in
+the file licenses/BSL-Couchbase.txt.  As of the Change Date specified in that
+file, in accordance with the Business Source License, use of this software will
+be governed by the Apache License, Version 2.0, included in the file
+licenses/APL2.txt.
+*/
+
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+)
+
+// channelBackfillKey identifies a channel backfill query. Concurrent backfills with the same key share a single query.
+type channelBackfillKey struct {
+	channel    channels.ID
+	startSeq   uint64
+	endSeq     uint64
+	limit      int
+	activeOnly bool
+}
+
+// channelBackfillCall is a backfill query in progress. done is closed once results and err are set.
+type channelBackfillCall struct {
+	done    chan struct{}
+	results LogEntries
+	err     error
+	waiters int                // Number of callers waiting for the results. Guarded by the pool's lock
+	cancel  context.CancelFunc // Cancels the query, once no callers are waiting for the results
+}
+
+// channelBackfillPool runs channel cache backfill queries for a database. A changes request spanning many cold
+// channels issues a backfill query per channel, which run concurrently up to the pool's parallelism, with the remainder
+// waiting for a free slot. Concurrent backfills for the same channel and sequence range are deduplicated, so that only
+// one query is issued and its results are shared by all callers.
+type channelBackfillPool struct {
+	slots    chan struct{}                               // Semaphore limiting concurrent queries. Nil when unbounded
+	lock     sync.Mutex                                  // Mutex for inFlight
+	inFlight map[channelBackfillKey]*channelBackfillCall // Queries in progress, by key
+}
+
+// newChannelBackfillPool returns a pool that runs at most maxConcurrent backfill queries at a time. Zero or less is
+// unbounded.
+func newChannelBackfillPool(maxConcurrent int) *channelBackfillPool {
+	pool := &channelBackfillPool{
+		inFlight: make(map[channelBackfillKey]*channelBackfillCall),
+	}
+	if maxConcurrent > 0 {
+		pool.slots = make(chan struct{}, maxConcurrent)
+	}
+	return pool
+}
+
+// query returns the results of runQuery for the given key. If a query for the same key is already in progress the
+// caller waits for, and shares, its results rather than issuing another. Otherwise the query is run once a slot is
+// available. The query is cancelled if every caller waiting for it is cancelled, so that disconnected clients don't
+// leave queries running. The returned LogEntries may be shared between callers, and must not be modified in place.
+func (p *channelBackfillPool) query(ctx context.Context, key channelBackfillKey, runQuery func(context.Context) (LogEntries, error)) (LogEntries, error) {
+	p.lock.Lock()
+	call, ok := p.inFlight[key]
+	if !ok {
+		// The query is shared, so is only cancelled once no callers are waiting for it, rather than with ctx
+		queryCtx, cancel := context.WithCancel(detachedContext{ctx})
+		call = &channelBackfillCall{done: make(chan struct{}), cancel: cancel}
+		p.inFlight[key] = call
+		go p.run(queryCtx, key, call, runQuery)
+	}
+	call.waiters++
+	p.lock.Unlock()
+
+	select {
+	case <-call.done:
+		return sharedLogEntries(call.results), call.err
+	case <-ctx.Done():
+		p.lock.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			// Later callers for the same key start a new query, rather than sharing the cancelled one
+			if p.inFlight[key] == call {
+				delete(p.inFlight, key)
+			}
+		}
+		p.lock.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// run runs a query for the given call once a slot is available, then releases its waiters.
+func (p *channelBackfillPool) run(ctx context.Context, key channelBackfillKey, call *channelBackfillCall, runQuery func(context.Context) (LogEntries, error)) {
+	defer base.FatalPanicHandler()
+	defer func() {
+		p.lock.Lock()
+		if p.inFlight[key] == call {
+			delete(p.inFlight, key)
+		}
+		p.lock.Unlock()
+		call.cancel()
+		close(call.done)
+	}()
+
+	if p.slots != nil {
+		select {
+		case p.slots <- struct{}{}:
+			defer func() { <-p.slots }()
+		case <-ctx.Done():
+			call.err = ctx.Err()
+			return
+		}
+	}
+
+	call.results, call.err = runQuery(ctx)
+}
+
+// sharedLogEntries limits the capacity of shared results to their length, so that appending to them doesn't modify
+// the results seen by other callers.
+func sharedLogEntries(entries LogEntries) LogEntries {
+	if entries == nil {
+		return nil
+	}
+	return entries[:len(entries):len(entries)]
+}
+
+// getChangesInChannel runs a channel query through the pool. Safe to call on a nil pool, in which case the query is
+// run directly.
+func (p *channelBackfillPool) getChangesInChannel(ctx context.Context, queryHandler ChannelQueryHandler, channel channels.ID, startSeq, endSeq uint64, limit int, activeOnly bool) (LogEntries, error) {
+	runQuery := func(queryCtx context.Context) (LogEntries, error) {
+		return queryHandler.getChangesInChannelFromQuery(queryCtx, channel.Name, startSeq, endSeq, limit, activeOnly)
+	}
+	if p == nil {
+		return runQuery(ctx)
+	}
+	key := channelBackfillKey{channel: channel, startSeq: startSeq, endSeq: endSeq, limit: limit, activeOnly: activeOnly}
+	return p.query(ctx, key, runQuery)
+}
+
+// changesQueryContext returns a context for a query made for a changes request, which has the values of ctx but is
+// cancelled with changesCtx, so that the query is cancelled if the changes request is closed. Returns ctx if
+// changesCtx is nil.
+func changesQueryContext(ctx, changesCtx context.Context) context.Context {
+	if changesCtx == nil {
+		return ctx
+	}
+	return changesCancelContext{Context: ctx, changesCtx: changesCtx}
+}
+
+// changesCancelContext is a context with the values of the embedded context, cancelled with changesCtx.
+type changesCancelContext struct {
+	context.Context
+	changesCtx context.Context
+}
+
+func (c changesCancelContext) Deadline() (time.Time, bool) {
+	return c.changesCtx.Deadline()
+}
+
+func (c changesCancelContext) Done() <-chan struct{} {
+	return c.changesCtx.Done()
+}
+
+func (c changesCancelContext) Err() error {
+	return c.changesCtx.Err()
+}
+
+// detachedContext is a context with the values of its parent, for logging, that is never cancelled.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
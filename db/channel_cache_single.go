@@ -115,6 +115,7 @@ type singleChannelCacheImpl struct {
 	cachedDocIDs     map[string]struct{}  // Set of keys present in the cache.  Used for efficient check for previous revisions on append
 	recentlyUsed     base.AtomicBool      // Atomic recently used flag, used by cache compaction.
 	cacheStats       *base.CacheStats     // Map used for cache stats
+	backfillPool     *channelBackfillPool // Optional pool used to run backfill queries.  When nil, queries are run directly
 }
 
 func newSingleChannelCache(queryHandler ChannelQueryHandler, channel channels.ID, validFrom uint64, cacheStats *base.CacheStats) *singleChannelCacheImpl {
@@ -168,6 +169,7 @@ type ChannelCacheOptions struct {
 	CompactHighWatermarkPercent int           // Compact HWM (as percent of MaxNumChannels)
 	CompactLowWatermarkPercent  int           // Compact LWM (as percent of MaxNumChannels)
 	ChannelQueryLimit           int           // Query limit
+	MaxBackfillConcurrency      int           // Maximum number of concurrent backfill queries across all channels.  Zero is unbounded
 }
 
 func (c *singleChannelCacheImpl) ChannelID() channels.ID {
@@ -203,6 +205,29 @@ func (c *singleChannelCacheImpl) addToCache(ctx context.Context, change *LogEntr
 	c._pruneCacheLength(ctx)
 }
 
+// addBatchToCache adds entries to the channel's cache in order under a single acquisition of the cache lock, pruning
+// the cache once the batch has been added.  removals holds whether each entry is a removal from the channel.
+func (c *singleChannelCacheImpl) addBatchToCache(ctx context.Context, changes []*LogEntry, removals []bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for i, change := range changes {
+		if c.wouldBeImmediatelyPruned(change) {
+			base.InfofCtx(ctx, base.KeyCache, "Not adding change #%d doc %q / %q ==> channel %q, since it will be immediately pruned",
+				change.Sequence, base.UD(change.DocID), change.RevID, base.UD(c.channelID))
+			continue
+		}
+		if !removals[i] {
+			c._appendChange(ctx, change)
+		} else {
+			removalChange := *change
+			removalChange.Flags |= channels.Removed
+			c._appendChange(ctx, &removalChange)
+		}
+	}
+	c._pruneCacheLength(ctx)
+}
+
 // If certain conditions are met, it's possible that this change will be added and then
 // immediately pruned, which causes the issues described in https://github.com/couchbase/sync_gateway/issues/2662
 func (c *singleChannelCacheImpl) wouldBeImmediatelyPruned(change *LogEntry) bool {
@@ -381,6 +406,7 @@ func (c *singleChannelCacheImpl) GetChanges(ctx context.Context, options Changes
 	startSeq := options.Since.SafeSequence() + 1
 	if cacheValidFrom <= startSeq {
 		c.cacheStats.ChannelCacheHits.Add(1)
+		options.trace.cacheHit()
 		return resultFromCache, nil
 	}
 
@@ -401,6 +427,7 @@ func (c *singleChannelCacheImpl) GetChanges(ctx context.Context, options Changes
 	}
 	if cacheValidFrom <= startSeq {
 		c.cacheStats.ChannelCacheHits.Add(1)
+		options.trace.cacheHit()
 		return resultFromCache, nil
 	}
 
@@ -413,11 +440,14 @@ func (c *singleChannelCacheImpl) GetChanges(ctx context.Context, options Changes
 	// Now query the view. We set the max sequence equal to cacheValidFrom, so we'll get one
 	// overlap, which helps confirm that we've got everything.
 	c.cacheStats.ChannelCacheMisses.Add(1)
+	options.trace.cacheMiss()
 	endSeq := cacheValidFrom
-	resultFromQuery, err := c.queryHandler.getChangesInChannelFromQuery(ctx, c.channelID.Name, startSeq, endSeq, options.Limit, options.ActiveOnly)
+	queryCtx := changesQueryContext(ctx, options.ChangesCtx)
+	resultFromQuery, err := c.backfillPool.getChangesInChannel(queryCtx, c.queryHandler, c.channelID, startSeq, endSeq, options.Limit, options.ActiveOnly)
 	if err != nil {
 		return nil, err
 	}
+	options.trace.backfillQuery(len(resultFromQuery))
 
 	// Cache some of the query results, if there's room in the cache.  If query hit the limit,
 	// the query results are only valid for the range of sequences in the result set.
@@ -829,6 +859,7 @@ func (c *singleChannelCacheImpl) _mostRecentLateLog() *lateLogEntry {
 type bypassChannelCache struct {
 	channel      channels.ID
 	queryHandler ChannelQueryHandler
+	backfillPool *channelBackfillPool // Optional pool used to run queries.  When nil, queries are run directly
 }
 
 // Get Changes uses high sequence value (math.MaxUint64) as the upper bound.  Relies on changes processing
@@ -836,7 +867,13 @@ type bypassChannelCache struct {
 func (b *bypassChannelCache) GetChanges(ctx context.Context, options ChangesOptions) ([]*LogEntry, error) {
 	startSeq := options.Since.SafeSequence() + 1
 	endSeq := uint64(math.MaxUint64)
-	return b.queryHandler.getChangesInChannelFromQuery(ctx, b.channel.Name, startSeq, endSeq, options.Limit, options.ActiveOnly)
+	queryCtx := changesQueryContext(ctx, options.ChangesCtx)
+	changes, err := b.backfillPool.getChangesInChannel(queryCtx, b.queryHandler, b.channel, startSeq, endSeq, options.Limit, options.ActiveOnly)
+	if err != nil {
+		return nil, err
+	}
+	options.trace.backfillQuery(len(changes))
+	return changes, nil
 }
 
 // No cached changes for bypassChannelCache
@@ -956,3 +956,42 @@ func writeEntries(entries []*LogEntry) {
 		log.Printf("%d:seq=%d, docID=%s, revID=%s", index, entry.Sequence, entry.DocID, entry.RevID)
 	}
 }
+
+func TestChannelCacheChangesTrace(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+
+	collection := GetSingleDatabaseCollection(t, db.DatabaseContext)
+	cache := newSingleChannelCache(collection, channels.NewID("Test1", collection.GetCollectionID()), 0, db.DbStats.Cache())
+	cache.addToCache(ctx, testLogEntry(1, "doc1", "1-a"), false)
+	cache.addToCache(ctx, testLogEntry(2, "doc2", "1-a"), false)
+
+	trace := &changesTrace{}
+	options := getChangesOptionsWithZeroSeq(t)
+	options.trace = trace
+	entries, err := cache.GetChanges(ctx, options)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, int64(1), trace.cacheHits.Load())
+	assert.Equal(t, int64(0), trace.cacheMisses.Load())
+	assert.Equal(t, int64(0), trace.backfillQueries.Load())
+
+	// channels that bypass the cache are traced as queries
+	queryHandler := &testQueryHandler{}
+	queryHandler.seedEntries(LogEntries{testLogEntryForChannels(1, []string{"chan_1"}), testLogEntryForChannels(2, []string{"chan_1"})})
+	bypassCache := &bypassChannelCache{
+		channel:      channels.NewID("chan_1", base.DefaultCollectionID),
+		queryHandler: queryHandler,
+	}
+	entries, err = bypassCache.GetChanges(ctx, options)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, int64(1), trace.cacheHits.Load())
+	assert.Equal(t, int64(1), trace.backfillQueries.Load())
+	assert.Equal(t, int64(2), trace.queryRows.Load())
+
+	// a nil trace is ignored
+	options.trace = nil
+	_, err = cache.GetChanges(ctx, options)
+	require.NoError(t, err)
+}
@@ -14,6 +14,7 @@ import (
 	"log"
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -85,7 +86,7 @@ func TestChannelCacheSimpleCompact(t *testing.T) {
 	activeChannelStat := &base.SgwIntStat{}
 	activeChannels := channels.NewActiveChannels(activeChannelStat)
 	ctx := base.TestCtx(t)
-	cache, err := newChannelCache(base.TestCtx(t), "testDb", options, testQueryHandlerFactory, activeChannels, testStats)
+	cache, err := newChannelCache(base.TestCtx(t), "testDb", options, testQueryHandlerFactory, activeChannels, testStats, nil)
 	require.NoError(t, err, "Background task error whilst creating channel cache")
 	defer cache.Stop(ctx)
 
@@ -128,7 +129,7 @@ func TestChannelCacheCompactInactiveChannels(t *testing.T) {
 	activeChannels := channels.NewActiveChannels(activeChannelStat)
 
 	ctx := base.TestCtx(t)
-	cache, err := newChannelCache(base.TestCtx(t), "testDb", options, testQueryHandlerFactory, activeChannels, testStats)
+	cache, err := newChannelCache(base.TestCtx(t), "testDb", options, testQueryHandlerFactory, activeChannels, testStats, nil)
 	require.NoError(t, err, "Background task error whilst creating channel cache")
 	defer cache.Stop(ctx)
 
@@ -187,7 +188,7 @@ func TestChannelCacheCompactNRU(t *testing.T) {
 	activeChannelStat := &base.SgwIntStat{}
 	activeChannels := channels.NewActiveChannels(activeChannelStat)
 	ctx := base.TestCtx(t)
-	cache, err := newChannelCache(base.TestCtx(t), "testDb", options, testQueryHandlerFactory, activeChannels, testStats)
+	cache, err := newChannelCache(base.TestCtx(t), "testDb", options, testQueryHandlerFactory, activeChannels, testStats, nil)
 	require.NoError(t, err, "Background task error whilst creating channel cache")
 	defer cache.Stop(ctx)
 
@@ -285,7 +286,7 @@ func TestChannelCacheHighLoadCacheHit(t *testing.T) {
 	activeChannelStat := &base.SgwIntStat{}
 	activeChannels := channels.NewActiveChannels(activeChannelStat)
 	ctx := base.TestCtx(t)
-	cache, err := newChannelCache(ctx, "testDb", options, queryHandler.asFactory, activeChannels, testStats)
+	cache, err := newChannelCache(ctx, "testDb", options, queryHandler.asFactory, activeChannels, testStats, nil)
 	require.NoError(t, err, "Background task error whilst creating channel cache")
 	defer cache.Stop(ctx)
 
@@ -360,7 +361,7 @@ func TestChannelCacheHighLoadCacheMiss(t *testing.T) {
 	activeChannelStat := &base.SgwIntStat{}
 	activeChannels := channels.NewActiveChannels(activeChannelStat)
 	ctx := base.TestCtx(t)
-	cache, err := newChannelCache(ctx, "testDb", options, queryHandler.asFactory, activeChannels, testStats)
+	cache, err := newChannelCache(ctx, "testDb", options, queryHandler.asFactory, activeChannels, testStats, nil)
 	require.NoError(t, err, "Background task error whilst creating channel cache")
 	defer cache.Stop(ctx)
 
@@ -430,7 +431,7 @@ func TestChannelCacheBypass(t *testing.T) {
 	activeChannelStat := &base.SgwIntStat{}
 	activeChannels := channels.NewActiveChannels(activeChannelStat)
 	ctx := base.TestCtx(t)
-	cache, err := newChannelCache(ctx, "testDb", options, queryHandler.asFactory, activeChannels, testStats)
+	cache, err := newChannelCache(ctx, "testDb", options, queryHandler.asFactory, activeChannels, testStats, nil)
 	require.NoError(t, err, "Background task error whilst creating channel cache")
 	defer cache.Stop(ctx)
 
@@ -465,6 +466,182 @@ func TestChannelCacheBypass(t *testing.T) {
 	assert.Equal(t, 80, int(bypassCountStat.Value()))
 }
 
+// TestChannelBackfillPoolConcurrency validates that backfill queries for different channels run concurrently, up to the
+// pool's limit.
+func TestChannelBackfillPoolConcurrency(t *testing.T) {
+	ctx := base.TestCtx(t)
+	const maxConcurrent = 3
+	pool := newChannelBackfillPool(maxConcurrent)
+
+	var active, maxActive int64
+	release := make(chan struct{})
+	runQuery := func(context.Context) (LogEntries, error) {
+		current := atomic.AddInt64(&active, 1)
+		defer atomic.AddInt64(&active, -1)
+		for {
+			previous := atomic.LoadInt64(&maxActive)
+			if current <= previous || atomic.CompareAndSwapInt64(&maxActive, previous, current) {
+				break
+			}
+		}
+		<-release
+		return LogEntries{testLogEntry(1, "doc1", "1-a")}, nil
+	}
+
+	channelCount := 10
+	var wg sync.WaitGroup
+	for i := 0; i < channelCount; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := channelBackfillKey{channel: channels.NewID(fmt.Sprintf("chan_%d", i), base.DefaultCollectionID), endSeq: 10}
+			results, err := pool.query(ctx, key, runQuery)
+			assert.NoError(t, err)
+			assert.Len(t, results, 1)
+		}(i)
+	}
+
+	// Queries beyond the limit wait for a free slot
+	require.Eventually(t, func() bool { return atomic.LoadInt64(&active) == maxConcurrent }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int64(maxConcurrent), atomic.LoadInt64(&active))
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int64(maxConcurrent), atomic.LoadInt64(&maxActive))
+}
+
+// TestChannelBackfillPoolDedupe validates that concurrent backfills of the same channel and sequence range share a
+// single query.
+func TestChannelBackfillPoolDedupe(t *testing.T) {
+	ctx := base.TestCtx(t)
+	pool := newChannelBackfillPool(DefaultChannelBackfillConcurrency)
+	key := channelBackfillKey{channel: channels.NewID("ABC", base.DefaultCollectionID), startSeq: 1, endSeq: 10}
+
+	var queryCount int64
+	started := make(chan struct{})
+	release := make(chan struct{})
+	runQuery := func(context.Context) (LogEntries, error) {
+		if atomic.AddInt64(&queryCount, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return LogEntries{testLogEntry(1, "doc1", "1-a"), testLogEntry(2, "doc2", "1-a")}, nil
+	}
+
+	callerCount := 5
+	var wg sync.WaitGroup
+	query := func() {
+		defer wg.Done()
+		results, err := pool.query(ctx, key, runQuery)
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+		// Appending to shared results mustn't modify the results seen by other callers
+		_ = append(results, testLogEntry(3, "doc3", "1-a"))
+	}
+	wg.Add(1)
+	go query()
+	<-started
+	for i := 1; i < callerCount; i++ {
+		wg.Add(1)
+		go query()
+	}
+
+	// Give the remaining callers time to join the in-flight query before releasing it
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int64(1), atomic.LoadInt64(&queryCount))
+
+	// Once the query has completed, a subsequent backfill for the same key issues a new query
+	results, err := pool.query(ctx, key, runQuery)
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&queryCount))
+}
+
+// TestChannelBackfillPoolCancel validates that a shared backfill query is only cancelled once every caller waiting for
+// it has been cancelled.
+func TestChannelBackfillPoolCancel(t *testing.T) {
+	ctx := base.TestCtx(t)
+	pool := newChannelBackfillPool(DefaultChannelBackfillConcurrency)
+	key := channelBackfillKey{channel: channels.NewID("ABC", base.DefaultCollectionID), startSeq: 1, endSeq: 10}
+
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	runQuery := func(queryCtx context.Context) (LogEntries, error) {
+		close(started)
+		<-queryCtx.Done()
+		close(cancelled)
+		return nil, queryCtx.Err()
+	}
+
+	callerCount := 3
+	cancels := make([]context.CancelFunc, callerCount)
+	errs := make(chan error, callerCount)
+	for i := 0; i < callerCount; i++ {
+		callerCtx, cancel := context.WithCancel(ctx)
+		cancels[i] = cancel
+		go func() {
+			_, err := pool.query(callerCtx, key, runQuery)
+			errs <- err
+		}()
+		if i == 0 {
+			<-started
+		}
+	}
+	require.Eventually(t, func() bool {
+		pool.lock.Lock()
+		defer pool.lock.Unlock()
+		return pool.inFlight[key] != nil && pool.inFlight[key].waiters == callerCount
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The query keeps running while any caller is waiting for it
+	for i := 0; i < callerCount-1; i++ {
+		cancels[i]()
+		assert.ErrorIs(t, <-errs, context.Canceled)
+	}
+	select {
+	case <-cancelled:
+		require.Fail(t, "Query cancelled while a caller was waiting for it")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancels[callerCount-1]()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "Query wasn't cancelled once no callers were waiting for it")
+	}
+
+	// A later backfill for the same key doesn't share the cancelled query
+	pool.lock.Lock()
+	_, ok := pool.inFlight[key]
+	pool.lock.Unlock()
+	assert.False(t, ok)
+}
+
+// TestChangesQueryContext validates that the context of a changes request's query is cancelled with the request, but
+// keeps the values of the context it was created from.
+func TestChangesQueryContext(t *testing.T) {
+	type testKey struct{}
+	ctx := context.WithValue(base.TestCtx(t), testKey{}, "value")
+	assert.Equal(t, ctx, changesQueryContext(ctx, nil))
+
+	changesCtx, cancel := context.WithCancel(context.Background())
+	queryCtx := changesQueryContext(ctx, changesCtx)
+	assert.Equal(t, "value", queryCtx.Value(testKey{}))
+	assert.NoError(t, queryCtx.Err())
+
+	// A detached context isn't cancelled with the context it was created from
+	detachedCtx := detachedContext{queryCtx}
+	cancel()
+	assert.ErrorIs(t, queryCtx.Err(), context.Canceled)
+	assert.NoError(t, detachedCtx.Err())
+	assert.Equal(t, "value", detachedCtx.Value(testKey{}))
+}
+
 func waitForCompaction(cache *channelCacheImpl) (compactionComplete bool) {
 	for i := 0; i <= 10; i++ {
 		if cache.compactRunning.IsTrue() {
@@ -537,7 +714,7 @@ func TestChannelCacheBackgroundTaskWithIllegalTimeInterval(t *testing.T) {
 	activeChannelStat := &base.SgwIntStat{}
 	activeChannels := channels.NewActiveChannels(activeChannelStat)
 
-	cache, err := newChannelCache(base.TestCtx(t), "testDb", options, queryHandler.asFactory, activeChannels, testStats)
+	cache, err := newChannelCache(base.TestCtx(t), "testDb", options, queryHandler.asFactory, activeChannels, testStats, nil)
 	assert.Error(t, err, "Background task error whilst creating channel cache")
 	assert.Nil(t, cache)
 
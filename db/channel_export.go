@@ -0,0 +1,100 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// ChannelExportEntry is a single line of a channel export. Either Doc is set to the exported revision body, or
+// Status, Error and Reason describe why the document's revision could not be exported.
+type ChannelExportEntry struct {
+	DocID    string `json:"id"`
+	RevID    string `json:"rev,omitempty"`
+	Sequence uint64 `json:"seq,omitempty"`
+	Doc      Body   `json:"doc,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// ChannelExportSummary is written as the final line of a channel export, so that truncated exports can be detected.
+type ChannelExportSummary struct {
+	Channel     string `json:"channel"`
+	SnapshotSeq uint64 `json:"snapshot_seq"`
+	Exported    int    `json:"exported"`
+	Errors      int    `json:"errors"`
+}
+
+// ExportChannel writes the current winning revision of every document in the channel to w as newline-delimited JSON,
+// followed by a ChannelExportSummary line. The export is consistent as of the collection's sequence at the start of
+// the export: each document is exported at the revision it had at that sequence, and documents written after it are
+// reported as errors rather than exported. When includeAttachments is set attachment bodies are inlined, otherwise
+// attachments are exported as stubs.
+func (db *DatabaseCollectionWithUser) ExportChannel(ctx context.Context, channel string, includeAttachments bool, w io.Writer) (*ChannelExportSummary, error) {
+	snapshotSeq, err := db.LastSequence(ctx)
+	if err != nil {
+		return nil, err
+	}
+	summary := &ChannelExportSummary{Channel: channel, SnapshotSeq: snapshotSeq}
+
+	var attachmentsSince []string
+	if includeAttachments {
+		attachmentsSince = []string{}
+	}
+
+	writeEntry := func(entry interface{}) error {
+		entryBytes, err := base.JSONMarshal(entry)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(entryBytes, '\n'))
+		return err
+	}
+
+	err = db.ForEachDocID(ctx, func(doc IDRevAndSequence, channels []string) (bool, error) {
+		if !base.StringSliceContains(channels, channel) {
+			return true, nil
+		}
+		entry := ChannelExportEntry{DocID: doc.DocID, RevID: doc.RevID, Sequence: doc.Sequence}
+		if doc.Sequence > snapshotSeq {
+			entry.Status = http.StatusConflict
+			entry.Error = base.CouchHTTPErrorName(http.StatusConflict)
+			entry.Reason = "document was modified after the export snapshot"
+		} else if body, err := db.Get1xRevBody(ctx, doc.DocID, doc.RevID, false, attachmentsSince); err != nil {
+			entry.Status, entry.Reason = base.ErrorAsHTTPStatus(err)
+			entry.Error = base.CouchHTTPErrorName(entry.Status)
+		} else {
+			entry.Doc = body
+		}
+		if entry.Doc == nil {
+			base.InfofCtx(ctx, base.KeyAll, "Channel export of %q: doc %q / %q --> %d %s", base.UD(channel), base.UD(doc.DocID), doc.RevID, entry.Status, entry.Reason)
+			summary.Errors++
+		} else {
+			summary.Exported++
+		}
+		if err := writeEntry(entry); err != nil {
+			return false, err
+		}
+		return true, nil
+	}, ForEachDocIDOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeEntry(summary); err != nil {
+		return nil, err
+	}
+	base.InfofCtx(ctx, base.KeyAll, "Exported %d docs from channel %q as of sequence %d (%d errors)", summary.Exported, base.UD(channel), snapshotSeq, summary.Errors)
+	return summary, nil
+}
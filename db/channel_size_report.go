@@ -0,0 +1,135 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+const (
+	// DefaultChannelSizeSampleSize is the default number of documents read to estimate channel body sizes.
+	DefaultChannelSizeSampleSize = 1000
+	// MaxChannelSizeSampleSize is the maximum number of documents that can be read to estimate channel body sizes.
+	MaxChannelSizeSampleSize = 100000
+	// DefaultChannelSizeReportTTL is how long a channel size report is served from cache before being regenerated.
+	DefaultChannelSizeReportTTL = 5 * time.Minute
+)
+
+// ChannelSizeReport is an estimate of the number of documents in, and total document body size of, each channel in a
+// collection. Document counts are exact as of GeneratedAt, body sizes are extrapolated from a random sample of
+// documents.
+type ChannelSizeReport struct {
+	GeneratedAt time.Time                   `json:"generated_at"`
+	TotalDocs   int64                       `json:"total_docs"`
+	SampledDocs int64                       `json:"sampled_docs"`
+	Channels    map[string]base.ChannelSize `json:"channels"`
+}
+
+// channelSizeReportCache holds the most recently generated channel size report for a collection.
+type channelSizeReportCache struct {
+	lock   sync.Mutex // Held while generating a report, so concurrent requests don't each scan the collection
+	report *ChannelSizeReport
+}
+
+// channelSizeSample is a sampled document and the channels it was in at the time of the scan.
+type channelSizeSample struct {
+	docID    string
+	channels []string
+}
+
+// GetChannelSizeReport returns the channel size report for the collection, generating a new one if the cached report
+// is older than maxAge, or refresh is set. Up to sampleSize documents are read to estimate body sizes.
+func (c *DatabaseCollection) GetChannelSizeReport(ctx context.Context, sampleSize int, maxAge time.Duration, refresh bool) (*ChannelSizeReport, error) {
+	c.channelSizes.lock.Lock()
+	defer c.channelSizes.lock.Unlock()
+
+	if !refresh && c.channelSizes.report != nil && time.Since(c.channelSizes.report.GeneratedAt) < maxAge {
+		return c.channelSizes.report, nil
+	}
+
+	report, err := c.generateChannelSizeReport(ctx, sampleSize)
+	if err != nil {
+		return nil, err
+	}
+	c.channelSizes.report = report
+	c.dbStats().ChannelSizes().Set(c.ScopeName+"."+c.Name, report.Channels)
+	return report, nil
+}
+
+// generateChannelSizeReport counts the documents in each channel by iterating over all docs, while reservoir sampling
+// sampleSize of them. The sampled documents are then read to find the mean body size for each channel. Channels with
+// no sampled documents use the mean body size across all sampled documents.
+func (c *DatabaseCollection) generateChannelSizeReport(ctx context.Context, sampleSize int) (*ChannelSizeReport, error) {
+	startTime := time.Now()
+	report := &ChannelSizeReport{
+		GeneratedAt: startTime,
+		Channels:    make(map[string]base.ChannelSize),
+	}
+
+	samples := make([]channelSizeSample, 0, sampleSize)
+	err := c.ForEachDocID(ctx, func(doc IDRevAndSequence, channels []string) (bool, error) {
+		report.TotalDocs++
+		for _, channel := range channels {
+			size := report.Channels[channel]
+			size.DocCount++
+			report.Channels[channel] = size
+		}
+		sample := channelSizeSample{docID: doc.DocID, channels: channels}
+		if len(samples) < sampleSize {
+			samples = append(samples, sample)
+		} else if i := rand.Int63n(report.TotalDocs); i < int64(sampleSize) {
+			samples[i] = sample
+		}
+		return true, nil
+	}, ForEachDocIDOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var sampledBytes int64
+	channelSampleBytes := make(map[string]int64)
+	channelSampleCount := make(map[string]int64)
+	for _, sample := range samples {
+		body, _, err := c.dataStore.GetRaw(sample.docID)
+		if err != nil {
+			if base.IsDocNotFoundError(err) {
+				// Deleted since the scan
+				continue
+			}
+			return nil, err
+		}
+		report.SampledDocs++
+		sampledBytes += int64(len(body))
+		for _, channel := range sample.channels {
+			channelSampleBytes[channel] += int64(len(body))
+			channelSampleCount[channel]++
+		}
+	}
+
+	var meanBytes float64
+	if report.SampledDocs > 0 {
+		meanBytes = float64(sampledBytes) / float64(report.SampledDocs)
+	}
+	for channel, size := range report.Channels {
+		channelMeanBytes := meanBytes
+		if count := channelSampleCount[channel]; count > 0 {
+			channelMeanBytes = float64(channelSampleBytes[channel]) / float64(count)
+		}
+		size.BodyBytes = int64(channelMeanBytes * float64(size.DocCount))
+		report.Channels[channel] = size
+	}
+
+	base.InfofCtx(ctx, base.KeyAll, "Generated channel size report for %d docs in %d channels (%d sampled) in %v", report.TotalDocs, len(report.Channels), report.SampledDocs, time.Since(startTime))
+	return report, nil
+}
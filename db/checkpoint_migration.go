@@ -0,0 +1,67 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// Outcomes of migrating a single client checkpoint with MigrateCheckpoint.
+const (
+	CheckpointMigrationMigrated = "migrated" // The checkpoint was copied to the collection
+	CheckpointMigrationMissing  = "missing"  // No pre-collections checkpoint exists for the client
+	CheckpointMigrationExists   = "exists"   // The collection already has a checkpoint, and overwrite wasn't requested
+)
+
+// MigrateCheckpoint copies a BLIP client checkpoint written by a pre-collections client, which is stored in the
+// bucket's default collection, to this collection under checkpointID. This lets a client that has become collection
+// aware resume replicating from where it left off instead of starting over. The checkpoint is copied as-is, so its
+// revision is preserved and the client's next setCheckpoint for it will succeed.
+func (c *DatabaseCollection) MigrateCheckpoint(ctx context.Context, legacyClientID, checkpointID string, overwrite bool) (outcome string, err error) {
+	if legacyClientID == "" || checkpointID == "" {
+		return "", base.HTTPErrorf(http.StatusBadRequest, "Checkpoint client IDs must not be empty")
+	}
+	if c.IsDefaultCollection() && legacyClientID == checkpointID {
+		return "", base.HTTPErrorf(http.StatusBadRequest, "Checkpoint %q can not be migrated to itself", base.UD(checkpointID))
+	}
+
+	legacyKey := RealSpecialDocID(DocTypeLocal, CheckpointDocIDPrefix+legacyClientID)
+	legacyCheckpoint, _, err := c.dbCtx.Bucket.DefaultDataStore().GetRaw(legacyKey)
+	if err != nil {
+		if base.IsDocNotFoundError(err) {
+			return CheckpointMigrationMissing, nil
+		}
+		return "", err
+	}
+
+	var expiry uint32
+	if c.localDocExpirySecs() > 0 {
+		expiry = base.SecondsToCbsExpiry(int(c.localDocExpirySecs()))
+	}
+	outcome = CheckpointMigrationMigrated
+	key := RealSpecialDocID(DocTypeLocal, CheckpointDocIDPrefix+checkpointID)
+	_, err = c.dataStore.Update(key, expiry, func(current []byte) ([]byte, *uint32, bool, error) {
+		if len(current) > 0 && !overwrite {
+			outcome = CheckpointMigrationExists
+			return nil, nil, false, base.ErrUpdateCancel
+		}
+		return legacyCheckpoint, nil, false, nil
+	})
+	if err == base.ErrUpdateCancel {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	base.DebugfCtx(ctx, base.KeySync, "Checkpoint migration for client %q to %q in %s.%s: %s", base.UD(legacyClientID), base.UD(checkpointID), base.MD(c.ScopeName), base.MD(c.Name), outcome)
+	return outcome, nil
+}
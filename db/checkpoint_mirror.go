@@ -0,0 +1,112 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// CheckpointMirrorDocPrefix is the key prefix of the mirrored copies of client checkpoints.  It's outside the _sync:
+// namespace, so that XDCR replications filtering out Sync Gateway metadata still replicate the mirrors to sister
+// clusters.
+const CheckpointMirrorDocPrefix = "_sgmirror:checkpoint:"
+
+// checkpointRemoteProperty is the property of a client checkpoint holding the last sequence pulled from Sync Gateway.
+// Sequences are allocated independently by each cluster, so it isn't adopted from another cluster's checkpoint.
+const checkpointRemoteProperty = "remote"
+
+// checkpointMirror is a client checkpoint mirrored for sister clusters, along with the cluster that last wrote it.
+type checkpointMirror struct {
+	ClusterID  string `json:"cluster_id"`
+	Checkpoint Body   `json:"checkpoint"`
+}
+
+func checkpointMirrorKey(client string) string {
+	return CheckpointMirrorDocPrefix + client
+}
+
+// checkpointMirrorClusterID returns the ID identifying this cluster's mirrored checkpoints, or empty if checkpoint
+// mirroring isn't enabled.
+func (c *DatabaseCollection) checkpointMirrorClusterID() string {
+	return c.dbCtx.Options.CheckpointMirrorClusterID
+}
+
+// mirrorCheckpoint writes a copy of a client checkpoint for sister clusters to pick up.  Failures are logged rather
+// than returned, as the checkpoint itself has been saved.
+func (c *DatabaseCollection) mirrorCheckpoint(ctx context.Context, client string, checkpoint Body) {
+	clusterID := c.checkpointMirrorClusterID()
+	if clusterID == "" {
+		return
+	}
+	checkpoint, _ = stripAllSpecialProperties(checkpoint)
+	var expiry uint32
+	if c.localDocExpirySecs() > 0 {
+		expiry = base.SecondsToCbsExpiry(int(c.localDocExpirySecs()))
+	}
+	err := c.dataStore.Set(checkpointMirrorKey(client), expiry, nil, checkpointMirror{ClusterID: clusterID, Checkpoint: checkpoint})
+	if err != nil {
+		base.WarnfCtx(ctx, "Unable to mirror checkpoint for client %s: %v", base.MD(client), err)
+	}
+}
+
+// resolveMirroredCheckpoint returns the checkpoint a client should resume from, given the checkpoint stored by this
+// cluster (nil if there isn't one).  When the mirror was last written by a sister cluster, the client has replicated
+// with that cluster since it last checkpointed here, so the mirrored checkpoint is adopted.  This cluster's own pull
+// sequence is retained, as the sister cluster's sequences don't apply here.
+func (c *DatabaseCollection) resolveMirroredCheckpoint(ctx context.Context, client string, checkpoint Body) (Body, error) {
+	clusterID := c.checkpointMirrorClusterID()
+	if clusterID == "" {
+		return checkpoint, nil
+	}
+
+	raw, _, err := c.dataStore.GetRaw(checkpointMirrorKey(client))
+	if err != nil {
+		if !base.IsDocNotFoundError(err) {
+			base.WarnfCtx(ctx, "Unable to read mirrored checkpoint for client %s: %v", base.MD(client), err)
+		}
+		return checkpoint, nil
+	}
+	// Numbers are decoded the same way as the checkpoint's, so that the two can be compared
+	var mirror checkpointMirror
+	decoder := base.JSONDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&mirror); err != nil {
+		base.WarnfCtx(ctx, "Unable to read mirrored checkpoint for client %s: %v", base.MD(client), err)
+		return checkpoint, nil
+	}
+	if mirror.ClusterID == clusterID || mirror.Checkpoint == nil {
+		return checkpoint, nil
+	}
+
+	adopted := mirror.Checkpoint
+	delete(adopted, checkpointRemoteProperty)
+	var matchRev string
+	if checkpoint != nil {
+		if remote, ok := checkpoint[checkpointRemoteProperty]; ok {
+			adopted[checkpointRemoteProperty] = remote
+		}
+		matchRev, _ = checkpoint[BodyRev].(string)
+		current, _ := stripAllSpecialProperties(checkpoint)
+		if reflect.DeepEqual(current, adopted) {
+			return checkpoint, nil
+		}
+	}
+
+	base.InfofCtx(ctx, base.KeySync, "Adopting checkpoint for client %s mirrored from cluster %s", base.MD(client), base.MD(mirror.ClusterID))
+	revID, err := c.putSpecial(DocTypeLocal, CheckpointDocIDPrefix+client, matchRev, adopted)
+	if err != nil {
+		return nil, err
+	}
+	adopted[BodyRev] = revID
+	return adopted, nil
+}
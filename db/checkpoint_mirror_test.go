@@ -0,0 +1,66 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointMirror(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	collection := GetSingleDatabaseCollection(t, db.DatabaseContext)
+	db.Options.CheckpointMirrorClusterID = "east"
+
+	// A checkpoint written by this cluster is mirrored, and isn't adopted back
+	checkpoint := Body{"local": 5.0, "remote": 10.0}
+	revID, err := collection.PutSpecial(DocTypeLocal, CheckpointDocIDPrefix+"client1", checkpoint)
+	require.NoError(t, err)
+	collection.mirrorCheckpoint(ctx, "client1", checkpoint)
+
+	var mirror checkpointMirror
+	_, err = collection.dataStore.Get(checkpointMirrorKey("client1"), &mirror)
+	require.NoError(t, err)
+	assert.Equal(t, checkpointMirror{ClusterID: "east", Checkpoint: Body{"local": 5.0, "remote": 10.0}}, mirror)
+
+	local, err := collection.GetSpecial(DocTypeLocal, CheckpointDocIDPrefix+"client1")
+	require.NoError(t, err)
+	resolved, err := collection.resolveMirroredCheckpoint(ctx, "client1", local)
+	require.NoError(t, err)
+	assert.Equal(t, revID, resolved[BodyRev])
+
+	// Once the client has checkpointed with a sister cluster, its push progress is adopted but this cluster's pull
+	// progress is retained
+	require.NoError(t, collection.dataStore.Set(checkpointMirrorKey("client1"), 0, nil, checkpointMirror{ClusterID: "west", Checkpoint: Body{"local": 8.0, "remote": 3.0}}))
+	resolved, err = collection.resolveMirroredCheckpoint(ctx, "client1", local)
+	require.NoError(t, err)
+	assert.NotEqual(t, revID, resolved[BodyRev])
+	assert.Equal(t, json.Number("8"), resolved["local"])
+	assert.Equal(t, json.Number("10"), resolved["remote"])
+
+	local, err = collection.GetSpecial(DocTypeLocal, CheckpointDocIDPrefix+"client1")
+	require.NoError(t, err)
+	assert.Equal(t, resolved, local)
+
+	// A client new to this cluster has no pull progress
+	require.NoError(t, collection.dataStore.Set(checkpointMirrorKey("client2"), 0, nil, checkpointMirror{ClusterID: "west", Checkpoint: Body{"local": 4.0, "remote": 3.0}}))
+	resolved, err = collection.resolveMirroredCheckpoint(ctx, "client2", nil)
+	require.NoError(t, err)
+	assert.Equal(t, Body{"local": json.Number("4"), BodyRev: "0-1"}, resolved)
+
+	// Without mirroring enabled, the mirror is ignored
+	db.Options.CheckpointMirrorClusterID = ""
+	resolved, err = collection.resolveMirroredCheckpoint(ctx, "client3", nil)
+	require.NoError(t, err)
+	assert.Nil(t, resolved)
+}
@@ -0,0 +1,175 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+)
+
+// Reasons for a discrepancy reported by VerifyCheckpoint.
+const (
+	CheckpointDiscrepancyMissing    = "missing"     // The client doesn't have a document its changes feed includes
+	CheckpointDiscrepancyOutdated   = "outdated"    // The client has an earlier revision than the one its changes feed includes
+	CheckpointDiscrepancyUnknownRev = "unknown_rev" // The client has a revision the server doesn't, such as one that hasn't been pushed yet
+	CheckpointDiscrepancyRemoved    = "removed"     // The client has a document that its changes feed removed, revoked or deleted
+)
+
+// checkpointSequenceProperties are the checkpoint properties that hold the remote sequence, as written by Couchbase
+// Lite ("remote") and Inter-Sync Gateway Replication ("last_sequence").
+var checkpointSequenceProperties = []string{"remote", checkpointBodyLastSeq}
+
+// CheckpointVerificationOptions control how a client checkpoint is verified by VerifyCheckpoint.
+type CheckpointVerificationOptions struct {
+	Username string            // User whose changes feed is replayed. Empty replays the feed for all documents
+	Channels base.Set          // Channels the client replicates. Nil for all channels the user has access to
+	Docs     map[string]string // Current revision ID of each document the client has, by doc ID
+	Limit    int               // Maximum number of changes to replay. Zero is unlimited
+}
+
+// CheckpointDiscrepancy is a difference between the changes feed replayed from a client's checkpoint and the
+// documents the client has.
+type CheckpointDiscrepancy struct {
+	DocID     string `json:"id"`
+	ServerRev string `json:"server_rev,omitempty"`
+	ClientRev string `json:"client_rev,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+// CheckpointVerification is the outcome of VerifyCheckpoint.
+type CheckpointVerification struct {
+	CheckpointID  string                  `json:"checkpoint_id"`
+	Since         SequenceID              `json:"since"`    // Sequence the changes feed was replayed from
+	LastSeq       SequenceID              `json:"last_seq"` // Sequence of the last change replayed
+	Changes       int                     `json:"changes"`  // Number of changes replayed
+	Discrepancies []CheckpointDiscrepancy `json:"discrepancies"`
+}
+
+// VerifyCheckpoint replays the changes feed a client would see when resuming from its checkpoint, and compares it to
+// the documents the client reports having. Documents the client has that don't appear in the feed are assumed to be
+// unchanged since the checkpoint, so aren't reported.
+func (c *DatabaseCollection) VerifyCheckpoint(ctx context.Context, checkpointID string, options CheckpointVerificationOptions) (*CheckpointVerification, error) {
+	since, err := c.getCheckpointSequence(checkpointID)
+	if err != nil {
+		return nil, err
+	}
+
+	collection := &DatabaseCollectionWithUser{DatabaseCollection: c}
+	if options.Username != "" {
+		user, err := c.Authenticator(ctx).GetUser(options.Username)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, base.HTTPErrorf(http.StatusNotFound, "User %q not found", base.UD(options.Username))
+		}
+		collection.user = user
+	}
+	chans := options.Channels
+	if chans == nil {
+		chans = base.SetOf(channels.UserStarChannel)
+	}
+
+	changesCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	feed, err := collection.MultiChangesFeed(ctx, chans, ChangesOptions{
+		Since:       since,
+		Limit:       options.Limit,
+		Revocations: true,
+		ChangesCtx:  changesCtx,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CheckpointVerification{
+		CheckpointID:  checkpointID,
+		Since:         since,
+		LastSeq:       since,
+		Discrepancies: []CheckpointDiscrepancy{},
+	}
+	for entry := range feed {
+		if entry.Err != nil {
+			return nil, entry.Err
+		}
+		if entry.principalDoc || len(entry.Changes) == 0 {
+			continue
+		}
+		result.Changes++
+		result.LastSeq = entry.Seq
+		if discrepancy := c.checkChangeEntry(ctx, entry, options.Docs); discrepancy != nil {
+			result.Discrepancies = append(result.Discrepancies, *discrepancy)
+		}
+	}
+
+	base.InfofCtx(ctx, base.KeySync, "Checkpoint %q verified from %s to %s: %d changes, %d discrepancies", base.UD(checkpointID), since, result.LastSeq, result.Changes, len(result.Discrepancies))
+	return result, nil
+}
+
+// checkChangeEntry compares a change from the replayed feed to the revision the client has, returning nil if they're
+// consistent.
+func (c *DatabaseCollection) checkChangeEntry(ctx context.Context, entry *ChangeEntry, clientDocs map[string]string) *CheckpointDiscrepancy {
+	serverRev := entry.Changes[0]["rev"]
+	clientRev, clientHasDoc := clientDocs[entry.ID]
+	discrepancy := &CheckpointDiscrepancy{DocID: entry.ID, ServerRev: serverRev, ClientRev: clientRev}
+
+	if entry.Deleted || entry.Revoked || len(entry.Removed) > 0 {
+		if !clientHasDoc {
+			return nil
+		}
+		discrepancy.Reason = CheckpointDiscrepancyRemoved
+		return discrepancy
+	}
+	if !clientHasDoc {
+		discrepancy.Reason = CheckpointDiscrepancyMissing
+		return discrepancy
+	}
+	if clientRev == serverRev {
+		return nil
+	}
+
+	discrepancy.Reason = CheckpointDiscrepancyOutdated
+	doc, err := c.GetDocument(ctx, entry.ID, DocUnmarshalSync)
+	if err != nil {
+		base.DebugfCtx(ctx, base.KeySync, "Unable to get doc %q to verify checkpoint: %v", base.UD(entry.ID), err)
+	} else if !doc.History.contains(clientRev) {
+		discrepancy.Reason = CheckpointDiscrepancyUnknownRev
+	}
+	return discrepancy
+}
+
+// getCheckpointSequence returns the remote sequence stored in a client's checkpoint.
+func (c *DatabaseCollection) getCheckpointSequence(checkpointID string) (SequenceID, error) {
+	checkpoint, err := c.GetSpecial(DocTypeLocal, CheckpointDocIDPrefix+checkpointID)
+	if err != nil {
+		if base.IsDocNotFoundError(err) {
+			return SequenceID{}, base.HTTPErrorf(http.StatusNotFound, "Checkpoint %q not found", base.UD(checkpointID))
+		}
+		return SequenceID{}, err
+	}
+	for _, property := range checkpointSequenceProperties {
+		value, ok := checkpoint[property]
+		if !ok {
+			continue
+		}
+		valueBytes, err := base.JSONMarshal(value)
+		if err != nil {
+			return SequenceID{}, err
+		}
+		since, err := ParseJSONSequenceID(string(valueBytes))
+		if err != nil {
+			return SequenceID{}, base.HTTPErrorf(http.StatusBadRequest, "Checkpoint %q has an invalid sequence: %v", base.UD(checkpointID), err)
+		}
+		return since, nil
+	}
+	return SequenceID{}, base.HTTPErrorf(http.StatusBadRequest, "Checkpoint %q doesn't contain a sequence", base.UD(checkpointID))
+}
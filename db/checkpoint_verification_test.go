@@ -0,0 +1,125 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyCheckpoint(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+	collection.ChannelMapper = channels.NewChannelMapper(ctx, channels.DocChannelsSyncFunction, db.Options.JavascriptTimeout)
+
+	// Written before the client's checkpoint
+	doc0Rev, _, err := collection.Put(ctx, "doc0", Body{"channels": "ABC"})
+	require.NoError(t, err)
+	doc2Rev1, _, err := collection.Put(ctx, "doc2", Body{"channels": "ABC"})
+	require.NoError(t, err)
+	checkpointSeq, err := collection.LastSequence(ctx)
+	require.NoError(t, err)
+	_, err = collection.PutSpecial(DocTypeLocal, CheckpointDocIDPrefix+"client1", Body{"local": 5, "remote": checkpointSeq})
+	require.NoError(t, err)
+
+	// Written after the client's checkpoint
+	doc1Rev, _, err := collection.Put(ctx, "doc1", Body{"channels": "ABC"})
+	require.NoError(t, err)
+	doc2Rev2, _, err := collection.Put(ctx, "doc2", Body{"channels": "ABC", BodyRev: doc2Rev1})
+	require.NoError(t, err)
+	_, _, err = collection.Put(ctx, "doc3", Body{"channels": "PBS"})
+	require.NoError(t, err)
+	doc4Rev, _, err := collection.Put(ctx, "doc4", Body{"channels": "ABC"})
+	require.NoError(t, err)
+	doc4Tombstone, _, err := collection.DeleteDoc(ctx, "doc4", doc4Rev)
+	require.NoError(t, err)
+	doc5Rev, _, err := collection.Put(ctx, "doc5", Body{"channels": "ABC"})
+	require.NoError(t, err)
+	require.NoError(t, collection.WaitForPendingChanges(ctx))
+
+	result, err := collection.VerifyCheckpoint(ctx, "client1", CheckpointVerificationOptions{
+		Channels: base.SetOf("ABC"),
+		Docs: map[string]string{
+			"doc0": doc0Rev,
+			"doc2": doc2Rev1,
+			"doc4": doc4Rev,
+			"doc5": "2-unpushed",
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, checkpointSeq, result.Since.Seq)
+	assert.Equal(t, 4, result.Changes)
+
+	discrepancies := make(map[string]CheckpointDiscrepancy, len(result.Discrepancies))
+	for _, discrepancy := range result.Discrepancies {
+		discrepancies[discrepancy.DocID] = discrepancy
+	}
+	assert.Equal(t, map[string]CheckpointDiscrepancy{
+		"doc1": {DocID: "doc1", ServerRev: doc1Rev, Reason: CheckpointDiscrepancyMissing},
+		"doc2": {DocID: "doc2", ServerRev: doc2Rev2, ClientRev: doc2Rev1, Reason: CheckpointDiscrepancyOutdated},
+		"doc4": {DocID: "doc4", ServerRev: doc4Tombstone, ClientRev: doc4Rev, Reason: CheckpointDiscrepancyRemoved},
+		"doc5": {DocID: "doc5", ServerRev: doc5Rev, ClientRev: "2-unpushed", Reason: CheckpointDiscrepancyUnknownRev},
+	}, discrepancies)
+
+	// A client that's up to date has no discrepancies
+	result, err = collection.VerifyCheckpoint(ctx, "client1", CheckpointVerificationOptions{
+		Channels: base.SetOf("ABC"),
+		Docs:     map[string]string{"doc1": doc1Rev, "doc2": doc2Rev2, "doc5": doc5Rev},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, result.Discrepancies)
+
+	// The replayed feed can be limited
+	result, err = collection.VerifyCheckpoint(ctx, "client1", CheckpointVerificationOptions{Channels: base.SetOf("ABC"), Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Changes)
+	require.Len(t, result.Discrepancies, 1)
+	assert.Equal(t, "doc1", result.Discrepancies[0].DocID)
+}
+
+func TestVerifyCheckpointErrors(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+
+	_, err := collection.PutSpecial(DocTypeLocal, CheckpointDocIDPrefix+"noSequence", Body{"local": 5})
+	require.NoError(t, err)
+	_, err = collection.PutSpecial(DocTypeLocal, CheckpointDocIDPrefix+"sgr", Body{"last_sequence": "0"})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		checkpointID string
+		username     string
+		status       int
+	}{
+		{name: "missing checkpoint", checkpointID: "unknown", status: http.StatusNotFound},
+		{name: "no sequence", checkpointID: "noSequence", status: http.StatusBadRequest},
+		{name: "unknown user", checkpointID: "sgr", username: "unknown", status: http.StatusNotFound},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := collection.VerifyCheckpoint(ctx, testCase.checkpointID, CheckpointVerificationOptions{Username: testCase.username})
+			require.Error(t, err)
+			status, _ := base.ErrorAsHTTPStatus(err)
+			assert.Equal(t, testCase.status, status)
+		})
+	}
+
+	// ISGR checkpoints are supported
+	result, err := collection.VerifyCheckpoint(ctx, "sgr", CheckpointVerificationOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), result.Since.Seq)
+}
@@ -0,0 +1,160 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// StatusDuplicateCheckpointWriter is the error code of a setCheckpoint rejected because another replicator is writing
+// the checkpoint of the same client ID, distinguishing it from an ordinary conflict that the client resolves by
+// getting the checkpoint again.
+const StatusDuplicateCheckpointWriter = http.StatusLocked
+
+const (
+	// duplicateCheckpointWindow is how recently another replicator must have written a client's checkpoint for a
+	// conflicting write to be counted towards detecting duplicate writers, and how long a writer stays fenced after
+	// the other replicator stops writing.
+	duplicateCheckpointWindow = time.Minute
+
+	// duplicateCheckpointConflicts is the number of conflicting writes within the window, each following a write by
+	// another replicator, at which two replicators are considered to be sharing a client ID.  A single conflict is
+	// expected when a client reconnects before its previous connection has closed.
+	duplicateCheckpointConflicts = 3
+
+	// maxTrackedCheckpointWriters is the number of client IDs tracked before writers that are no longer active are
+	// discarded.
+	maxTrackedCheckpointWriters = 10000
+)
+
+// checkpointWriters tracks the replicators writing the checkpoints of a collection's clients, identified by their BLIP
+// context IDs, to detect two replicators sharing a client ID.  Their setCheckpoint writes alternate and conflict, as
+// each overwrites the checkpoint the other last read.
+type checkpointWriters struct {
+	lock    sync.Mutex
+	clients map[string]*checkpointWriterState // Keyed by client ID
+}
+
+type checkpointWriterState struct {
+	lastWriter    string    // Replicator that last wrote the checkpoint successfully
+	lastWrite     time.Time // Time of the last successful write
+	conflicts     int       // Conflicting writes by replicators other than lastWriter since firstConflict
+	firstConflict time.Time
+	owner         string // Once duplicates are detected, the replicator allowed to keep writing when fencing
+}
+
+// active returns true if the checkpoint has been written or conflicted on within the window.
+func (s *checkpointWriterState) active(now time.Time) bool {
+	return now.Sub(s.lastWrite) < duplicateCheckpointWindow || now.Sub(s.firstConflict) < duplicateCheckpointWindow
+}
+
+// state returns the client's tracked state, discarding it once the client's checkpoint is no longer being written.
+// Requires lock to be held.
+func (w *checkpointWriters) state(client string, now time.Time) *checkpointWriterState {
+	if w.clients == nil {
+		w.clients = make(map[string]*checkpointWriterState)
+	}
+	state, ok := w.clients[client]
+	if ok && state.active(now) {
+		return state
+	}
+	if len(w.clients) >= maxTrackedCheckpointWriters {
+		for id, s := range w.clients {
+			if !s.active(now) {
+				delete(w.clients, id)
+			}
+		}
+	}
+	state = &checkpointWriterState{}
+	w.clients[client] = state
+	return state
+}
+
+// recordWrite records a successful checkpoint write by the replicator.
+func (w *checkpointWriters) recordWrite(client, writer string, now time.Time) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	state := w.state(client, now)
+	state.lastWriter = writer
+	state.lastWrite = now
+}
+
+// recordConflict records a conflicting checkpoint write by the replicator.  When the conflict completes the detection
+// of duplicate writers, returns true along with the replicator that last wrote the checkpoint.
+func (w *checkpointWriters) recordConflict(client, writer string, now time.Time) (detected bool, otherWriter string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	state := w.state(client, now)
+	if state.lastWriter == "" || state.lastWriter == writer || now.Sub(state.lastWrite) >= duplicateCheckpointWindow {
+		return false, ""
+	}
+	if now.Sub(state.firstConflict) >= duplicateCheckpointWindow {
+		state.conflicts = 0
+		state.firstConflict = now
+	}
+	state.conflicts++
+	if state.conflicts < duplicateCheckpointConflicts || state.owner != "" {
+		return false, ""
+	}
+	state.owner = state.lastWriter
+	return true, state.lastWriter
+}
+
+// fenced returns true if duplicate writers have been detected for the client and the replicator isn't the one
+// allowed to keep writing.  The fence is lifted once the owner stops writing.
+func (w *checkpointWriters) fenced(client, writer string, now time.Time) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	state, ok := w.clients[client]
+	if !ok || state.owner == "" || state.owner == writer {
+		return false
+	}
+	if state.lastWriter != state.owner || now.Sub(state.lastWrite) >= duplicateCheckpointWindow {
+		state.owner = ""
+		return false
+	}
+	return true
+}
+
+// checkCheckpointWriter returns an error if duplicate writers of the client's checkpoint have been detected, the
+// writer isn't the one allowed to keep writing, and fencing is enabled.
+func (c *DatabaseCollection) checkCheckpointWriter(ctx context.Context, client, writer string) error {
+	if !c.dbCtx.Options.FenceDuplicateCheckpoints || !c.checkpointWriters.fenced(client, writer, time.Now()) {
+		return nil
+	}
+	c.dbCtx.DbStats.Database().DuplicateCheckpointFencedCount.Add(1)
+	base.DebugfCtx(ctx, base.KeySync, "Rejecting checkpoint write by %s for client %s written by another replicator", writer, base.MD(client))
+	return base.HTTPErrorf(StatusDuplicateCheckpointWriter, "Checkpoint for client %s is being written by another replicator with the same client ID", base.MD(client))
+}
+
+// recordCheckpointWrite records the result of a checkpoint write, and warns when it completes the detection of two
+// replicators writing the checkpoint of the same client ID.
+func (c *DatabaseCollection) recordCheckpointWrite(ctx context.Context, client, writer string, err error) {
+	now := time.Now()
+	if err == nil {
+		c.checkpointWriters.recordWrite(client, writer, now)
+		return
+	}
+	if status, _ := base.ErrorAsHTTPStatus(err); status != http.StatusConflict {
+		return
+	}
+	detected, otherWriter := c.checkpointWriters.recordConflict(client, writer, now)
+	if !detected {
+		return
+	}
+	c.dbCtx.DbStats.Database().DuplicateCheckpointWriterCount.Add(1)
+	base.WarnfCtx(ctx, "Duplicate checkpoint writers detected: client_id=%s keyspace=%s.%s writer=%s other_writer=%s conflicts=%d window=%s fenced=%t. "+
+		"Two replicators are using the same client ID, for example two devices restored from the same backup, and are overwriting each other's checkpoint.",
+		base.MD(client), base.MD(c.ScopeName), base.MD(c.Name), writer, otherWriter, duplicateCheckpointConflicts, duplicateCheckpointWindow, c.dbCtx.Options.FenceDuplicateCheckpoints)
+}
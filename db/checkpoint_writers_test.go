@@ -0,0 +1,80 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointWritersDetection(t *testing.T) {
+	var writers checkpointWriters
+	now := time.Now()
+
+	// A client reconnecting conflicts with its previous connection's write once, which isn't a duplicate
+	writers.recordWrite("client1", "ctx1", now)
+	detected, _ := writers.recordConflict("client1", "ctx2", now)
+	assert.False(t, detected)
+	writers.recordWrite("client1", "ctx2", now)
+
+	// Repeated alternating conflicts are
+	writers.recordWrite("client1", "ctx1", now)
+	detected, _ = writers.recordConflict("client1", "ctx2", now)
+	assert.False(t, detected)
+	writers.recordWrite("client1", "ctx2", now)
+	detected, other := writers.recordConflict("client1", "ctx1", now)
+	require.True(t, detected)
+	assert.Equal(t, "ctx2", other)
+
+	// Only the replicator that wrote last can keep writing
+	assert.True(t, writers.fenced("client1", "ctx1", now))
+	assert.False(t, writers.fenced("client1", "ctx2", now))
+	assert.False(t, writers.fenced("client2", "ctx1", now))
+
+	// Detection isn't repeated while the fence is in place
+	detected, _ = writers.recordConflict("client1", "ctx1", now)
+	assert.False(t, detected)
+
+	// The fence is lifted once the other replicator stops writing
+	assert.False(t, writers.fenced("client1", "ctx1", now.Add(duplicateCheckpointWindow)))
+
+	// Conflicts spread beyond the window aren't duplicates
+	for i := 0; i < duplicateCheckpointConflicts; i++ {
+		now = now.Add(duplicateCheckpointWindow)
+		writers.recordWrite("client3", "ctx1", now)
+		detected, _ = writers.recordConflict("client3", "ctx2", now)
+		assert.False(t, detected)
+	}
+}
+
+func TestDuplicateCheckpointWriterFencing(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	db.Options.FenceDuplicateCheckpoints = true
+	collection := GetSingleDatabaseCollection(t, db.DatabaseContext)
+	conflict := base.HTTPErrorf(http.StatusConflict, "Document update conflict")
+
+	for i := 0; i < duplicateCheckpointConflicts; i++ {
+		require.NoError(t, collection.checkCheckpointWriter(ctx, "client1", "ctx1"))
+		collection.recordCheckpointWrite(ctx, "client1", "ctx1", nil)
+		require.NoError(t, collection.checkCheckpointWriter(ctx, "client1", "ctx2"))
+		collection.recordCheckpointWrite(ctx, "client1", "ctx2", conflict)
+	}
+	assert.Equal(t, int64(1), db.DbStats.Database().DuplicateCheckpointWriterCount.Value())
+
+	err := collection.checkCheckpointWriter(ctx, "client1", "ctx2")
+	assert.Equal(t, StatusDuplicateCheckpointWriter, err.(*base.HTTPError).Status)
+	assert.Equal(t, int64(1), db.DbStats.Database().DuplicateCheckpointFencedCount.Value())
+	assert.NoError(t, collection.checkCheckpointWriter(ctx, "client1", "ctx1"))
+}
@@ -348,7 +348,7 @@ func (db *DatabaseCollectionWithUser) getRev(ctx context.Context, docid, revid s
 		_, requestedHistory = trimEncodedRevisionsToAncestor(ctx, requestedHistory, historyFrom, maxHistory)
 	}
 
-	isAuthorized, redactedRev := db.authorizeUserForChannels(docid, revision.RevID, revision.Channels, revision.Deleted, requestedHistory)
+	isAuthorized, redactedRev := db.authorizeUserForChannels(ctx, docid, revision.RevID, revision.Channels, revision.Deleted, requestedHistory)
 	if !isAuthorized {
 		if revid == "" {
 			return DocumentRevision{}, ErrForbidden
@@ -404,7 +404,7 @@ func (db *DatabaseCollectionWithUser) GetDelta(ctx context.Context, docID, fromR
 	if fromRevision.Delta != nil {
 		if fromRevision.Delta.ToRevID == toRevID {
 
-			isAuthorized, redactedBody := db.authorizeUserForChannels(docID, toRevID, fromRevision.Delta.ToChannels, fromRevision.Delta.ToDeleted, encodeRevisions(ctx, docID, fromRevision.Delta.RevisionHistory))
+			isAuthorized, redactedBody := db.authorizeUserForChannels(ctx, docID, toRevID, fromRevision.Delta.ToChannels, fromRevision.Delta.ToDeleted, encodeRevisions(ctx, docID, fromRevision.Delta.RevisionHistory))
 			if !isAuthorized {
 				return nil, &redactedBody, nil
 			}
@@ -430,7 +430,7 @@ func (db *DatabaseCollectionWithUser) GetDelta(ctx context.Context, docID, fromR
 		}
 
 		deleted := toRevision.Deleted
-		isAuthorized, redactedBody := db.authorizeUserForChannels(docID, toRevID, toRevision.Channels, deleted, toRevision.History)
+		isAuthorized, redactedBody := db.authorizeUserForChannels(ctx, docID, toRevID, toRevision.Channels, deleted, toRevision.History)
 		if !isAuthorized {
 			return nil, &redactedBody, nil
 		}
@@ -489,10 +489,10 @@ func (db *DatabaseCollectionWithUser) GetDelta(ctx context.Context, docID, fromR
 	return nil, nil, nil
 }
 
-func (col *DatabaseCollectionWithUser) authorizeUserForChannels(docID, revID string, channels base.Set, isDeleted bool, history Revisions) (isAuthorized bool, redactedRev DocumentRevision) {
+func (col *DatabaseCollectionWithUser) authorizeUserForChannels(ctx context.Context, docID, revID string, channels base.Set, isDeleted bool, history Revisions) (isAuthorized bool, redactedRev DocumentRevision) {
 
 	if col.user != nil {
-		if err := col.user.AuthorizeAnyCollectionChannel(col.ScopeName, col.Name, channels); err != nil {
+		if err := col.authorizeReadChannels(ctx, channels); err != nil {
 			// On access failure, return (only) the doc history and deletion/removal
 			// status instead of returning an error. For justification see the comment in
 			// the getRevFromDoc method, below
@@ -541,7 +541,7 @@ func (db *DatabaseCollectionWithUser) Get1xRevAndChannels(ctx context.Context, d
 }
 
 // Returns an HTTP 403 error if the User is not allowed to access any of this revision's channels.
-func (col *DatabaseCollectionWithUser) authorizeDoc(doc *Document, revid string) error {
+func (col *DatabaseCollectionWithUser) authorizeDoc(ctx context.Context, doc *Document, revid string) error {
 	user := col.user
 	if doc == nil || user == nil {
 		return nil // A nil User means access control is disabled
@@ -551,7 +551,7 @@ func (col *DatabaseCollectionWithUser) authorizeDoc(doc *Document, revid string)
 	}
 	if rev := doc.History[revid]; rev != nil {
 		// Authenticate against specific revision:
-		return col.user.AuthorizeAnyCollectionChannel(col.ScopeName, col.Name, rev.Channels)
+		return col.authorizeReadChannels(ctx, rev.Channels)
 	} else {
 		// No such revision; let the caller proceed and return a 404
 		return nil
@@ -693,7 +693,12 @@ func (db *DatabaseCollectionWithUser) getAncestorJSON(ctx context.Context, doc *
 // instead returns a minimal deletion or removal revision to let them know it's gone.
 func (db *DatabaseCollectionWithUser) get1xRevFromDoc(ctx context.Context, doc *Document, revid string, listRevisions bool) (bodyBytes []byte, removed bool, err error) {
 	var attachments AttachmentsMeta
-	if err := db.authorizeDoc(doc, revid); err != nil {
+	if doc.needsExternalRevChannels(revid) {
+		if err := db.loadExternalSyncMetadata(ctx, doc.ID, &doc.SyncData); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := db.authorizeDoc(ctx, doc, revid); err != nil {
 		// As a special case, you don't need channel access to see a deletion revision,
 		// otherwise the client's replicator can't process the deletion (since deletions
 		// usually aren't on any channels at all!) But don't show the full body. (See #59)
@@ -813,7 +818,9 @@ func (db *DatabaseCollectionWithUser) backupAncestorRevs(ctx context.Context, do
 	for {
 		if ancestorRevId = doc.History.getParent(ancestorRevId); ancestorRevId == "" {
 			// No ancestors with JSON found.  Check if we need to back up current rev for delta sync, then return
-			db.backupRevisionJSON(ctx, doc.ID, newDoc.RevID, "", newBodyBytes, nil, doc.Attachments)
+			if backedUpRevId := db.backupRevisionJSON(ctx, doc.ID, newDoc.RevID, "", newBodyBytes, nil, doc.Attachments); backedUpRevId != "" {
+				db.pruneOldRevisionBackups(ctx, doc, backedUpRevId)
+			}
 			return
 		} else if json = doc.getRevisionBodyJSON(ctx, ancestorRevId, db.RevisionBodyLoader); json != nil {
 			break
@@ -821,7 +828,9 @@ func (db *DatabaseCollectionWithUser) backupAncestorRevs(ctx context.Context, do
 	}
 
 	// Back up the revision JSON as a separate doc in the bucket:
-	db.backupRevisionJSON(ctx, doc.ID, newDoc.RevID, ancestorRevId, newBodyBytes, json, doc.Attachments)
+	if backedUpRevId := db.backupRevisionJSON(ctx, doc.ID, newDoc.RevID, ancestorRevId, newBodyBytes, json, doc.Attachments); backedUpRevId != "" {
+		db.pruneOldRevisionBackups(ctx, doc, backedUpRevId)
+	}
 
 	// Nil out the ancestor rev's body in the document struct:
 	if ancestorRevId == doc.CurrentRev {
@@ -984,7 +993,7 @@ func (db *DatabaseCollectionWithUser) Put(ctx context.Context, docid string, bod
 					return nil, nil, false, nil, ErrForbidden
 				}
 
-				_, _, _, _, _, err = db.runSyncFn(ctx, doc, mutableBody, metaMap, newRevID)
+				_, _, _, _, _, _, err = db.runSyncFn(ctx, doc, mutableBody, metaMap, newRevID)
 				if err != nil {
 					base.DebugfCtx(ctx, base.KeyCRUD, "Could not modify doc %q due to %s and sync func rejection: %v", base.UD(doc.ID), conflictErr, err)
 					return nil, nil, false, nil, ErrForbidden
@@ -1532,16 +1541,19 @@ func (db *DatabaseCollectionWithUser) prepareSyncFn(doc *Document, newDoc *Docum
 
 // Run the sync function on the given document and body. Need to inject the document ID and rev ID temporarily to run
 // the sync function.
-func (db *DatabaseCollectionWithUser) runSyncFn(ctx context.Context, doc *Document, body Body, metaMap map[string]interface{}, newRevId string) (*uint32, string, base.Set, channels.AccessMap, channels.AccessMap, error) {
-	channelSet, access, roles, syncExpiry, oldBody, err := db.getChannelsAndAccess(ctx, doc, body, metaMap, newRevId)
+func (db *DatabaseCollectionWithUser) runSyncFn(ctx context.Context, doc *Document, body Body, metaMap map[string]interface{}, newRevId string) (*uint32, string, base.Set, channels.AccessMap, channels.AccessExpiryMap, channels.AccessMap, error) {
+	channelSet, access, accessExpiry, roles, syncExpiry, oldBody, err := db.getChannelsAndAccess(ctx, doc, body, metaMap, newRevId)
 	if err != nil {
-		return nil, ``, nil, nil, nil, err
+		return nil, ``, nil, nil, nil, nil, err
+	}
+	if err := db.authorizeWriteExternally(ctx, doc.ID, channelSet); err != nil {
+		return nil, ``, nil, nil, nil, nil, err
 	}
 	db.checkDocChannelsAndGrantsLimits(ctx, doc.ID, channelSet, access, roles)
-	return syncExpiry, oldBody, channelSet, access, roles, nil
+	return syncExpiry, oldBody, channelSet, access, accessExpiry, roles, nil
 }
 
-func (db *DatabaseCollectionWithUser) recalculateSyncFnForActiveRev(ctx context.Context, doc *Document, metaMap map[string]interface{}, newRevID string) (channelSet base.Set, access, roles channels.AccessMap, syncExpiry *uint32, oldBodyJSON string, err error) {
+func (db *DatabaseCollectionWithUser) recalculateSyncFnForActiveRev(ctx context.Context, doc *Document, metaMap map[string]interface{}, newRevID string) (channelSet base.Set, access channels.AccessMap, accessExpiry channels.AccessExpiryMap, roles channels.AccessMap, syncExpiry *uint32, oldBodyJSON string, err error) {
 	// In some cases an older revision might become the current one. If so, get its
 	// channels & access, for purposes of updating the doc:
 	curBodyBytes, err := db.getAvailable1xRev(ctx, doc, doc.CurrentRev)
@@ -1558,7 +1570,7 @@ func (db *DatabaseCollectionWithUser) recalculateSyncFnForActiveRev(ctx context.
 	if curBody != nil {
 		base.DebugfCtx(ctx, base.KeyCRUD, "updateDoc(%q): Rev %q causes %q to become current again",
 			base.UD(doc.ID), newRevID, doc.CurrentRev)
-		channelSet, access, roles, syncExpiry, oldBodyJSON, err = db.getChannelsAndAccess(ctx, doc, curBody, metaMap, doc.CurrentRev)
+		channelSet, access, accessExpiry, roles, syncExpiry, oldBodyJSON, err = db.getChannelsAndAccess(ctx, doc, curBody, metaMap, doc.CurrentRev)
 		if err != nil {
 			return
 		}
@@ -1762,7 +1774,7 @@ func (col *DatabaseCollectionWithUser) documentUpdateFunc(ctx context.Context, d
 	newDocHasAttachments := len(newAttachments) > 0
 	col.storeOldBodyInRevTreeAndUpdateCurrent(ctx, doc, prevCurrentRev, newRevID, newDoc, newDocHasAttachments)
 
-	syncExpiry, oldBodyJSON, channelSet, access, roles, err := col.runSyncFn(ctx, doc, mutableBody, metaMap, newRevID)
+	syncExpiry, oldBodyJSON, channelSet, access, accessExpiry, roles, err := col.runSyncFn(ctx, doc, mutableBody, metaMap, newRevID)
 	if err != nil {
 		if col.ForceAPIForbiddenErrors() {
 			base.InfofCtx(ctx, base.KeyCRUD, "Sync function rejected update to %s %s due to %v",
@@ -1794,7 +1806,7 @@ func (col *DatabaseCollectionWithUser) documentUpdateFunc(ctx context.Context, d
 		// need to update the doc's top-level Channels and Access properties to correspond
 		// to the current rev's state.
 		if newRevID != doc.CurrentRev {
-			channelSet, access, roles, syncExpiry, oldBodyJSON, err = col.recalculateSyncFnForActiveRev(ctx, doc, metaMap, newRevID)
+			channelSet, access, accessExpiry, roles, syncExpiry, oldBodyJSON, err = col.recalculateSyncFnForActiveRev(ctx, doc, metaMap, newRevID)
 			if err != nil {
 				return
 			}
@@ -1803,8 +1815,8 @@ func (col *DatabaseCollectionWithUser) documentUpdateFunc(ctx context.Context, d
 		if err != nil {
 			return
 		}
-		changedAccessPrincipals = doc.Access.updateAccess(ctx, doc, access)
-		changedRoleAccessUsers = doc.RoleAccess.updateAccess(ctx, doc, roles)
+		changedAccessPrincipals = doc.Access.updateAccess(ctx, doc, access, accessExpiry)
+		changedRoleAccessUsers = doc.RoleAccess.updateAccess(ctx, doc, roles, nil)
 	} else {
 
 		base.DebugfCtx(ctx, base.KeyCRUD, "updateDoc(%q): Rev %q leaves %q still current",
@@ -1815,6 +1827,7 @@ func (col *DatabaseCollectionWithUser) documentUpdateFunc(ctx context.Context, d
 	if pruned := doc.pruneRevisions(ctx, col.revsLimit(), doc.CurrentRev); pruned > 0 {
 		base.DebugfCtx(ctx, base.KeyCRUD, "updateDoc(%q): Pruned %d old revisions", base.UD(doc.ID), pruned)
 	}
+	col.pruneChannelSetHistory(ctx, doc)
 
 	updatedExpiry = doc.updateExpiry(syncExpiry, updatedExpiry, expiry)
 	err = doc.persistModifiedRevisionBodies(col.dataStore)
@@ -2038,7 +2051,7 @@ func (db *DatabaseCollectionWithUser) updateAndReturnDoc(ctx context.Context, do
 				base.WarnfCtx(ctx, "Error marshalling doc with id %s and revid %s for webhook post: %v", base.UD(docid), base.UD(newRevID), err)
 			} else {
 				winningRevChange := prevCurrentRev != doc.CurrentRev
-				err = db.eventMgr().RaiseDocumentChangeEvent(ctx, webhookJSON, docid, oldBodyJSON, revChannels, winningRevChange)
+				err = db.eventMgr().RaiseDocumentChangeEvent(ctx, webhookJSON, docid, oldBodyJSON, revChannels, winningRevChange, doc.Sequence, db.ScopeName+"."+db.Name)
 				if err != nil {
 					base.DebugfCtx(ctx, base.KeyCRUD, "Error raising document change event: %v", err)
 				}
@@ -2239,11 +2252,15 @@ func (db *DatabaseCollectionWithUser) Post(ctx context.Context, body Body) (doci
 	return docid, rev, doc, err
 }
 
-// Deletes a document, by adding a new revision whose _deleted property is true.
-func (db *DatabaseCollectionWithUser) DeleteDoc(ctx context.Context, docid string, revid string) (string, error) {
+// Deletes a document, by adding a new revision whose _deleted property is true.  Returns the new revision ID and its
+// sequence.
+func (db *DatabaseCollectionWithUser) DeleteDoc(ctx context.Context, docid string, revid string) (newRevID string, sequence uint64, err error) {
 	body := Body{BodyDeleted: true, BodyRev: revid}
-	newRevID, _, err := db.Put(ctx, docid, body)
-	return newRevID, err
+	newRevID, doc, err := db.Put(ctx, docid, body)
+	if err != nil {
+		return "", 0, err
+	}
+	return newRevID, doc.Sequence, nil
 }
 
 // Purges a document from the bucket (no tombstone)
@@ -2258,6 +2275,10 @@ func (db *DatabaseCollectionWithUser) Purge(ctx context.Context, key string) err
 		return err
 	}
 
+	if doc.ExternalMetadata != nil {
+		db.deleteExternalSyncMetadata(ctx, doc.ID, doc.ExternalMetadata)
+	}
+
 	for attachmentID := range attachments {
 		err = db.dataStore.Delete(attachmentID)
 		if err != nil {
@@ -2279,6 +2300,7 @@ func (db *DatabaseCollectionWithUser) Purge(ctx context.Context, key string) err
 func (col *DatabaseCollectionWithUser) getChannelsAndAccess(ctx context.Context, doc *Document, body Body, metaMap map[string]interface{}, revID string) (
 	result base.Set,
 	access channels.AccessMap,
+	accessExpiry channels.AccessExpiryMap,
 	roles channels.AccessMap,
 	expiry *uint32,
 	oldJson string,
@@ -2287,7 +2309,7 @@ func (col *DatabaseCollectionWithUser) getChannelsAndAccess(ctx context.Context,
 
 	// Low-level protection against writes for read-only guest.  Handles write pathways that don't fail-fast
 	if col.user != nil && col.user.Name() == "" && col.isGuestReadOnly() {
-		return result, access, roles, expiry, oldJson, base.HTTPErrorf(403, auth.GuestUserReadOnly)
+		return result, access, accessExpiry, roles, expiry, oldJson, base.HTTPErrorf(403, auth.GuestUserReadOnly)
 	}
 
 	// Get the parent revision, to pass to the sync function:
@@ -2297,7 +2319,22 @@ func (col *DatabaseCollectionWithUser) getChannelsAndAccess(ctx context.Context,
 	}
 	oldJson = string(oldJsonBytes)
 
-	if col.ChannelMapper != nil {
+	channelMapper := col.channelMapper
+	if channelMapper == nil {
+		channelMapper, _ = col.getChannelMapper()
+	}
+	// With a staged sync function, the write uses whichever function it's selected for, and is evaluated by the other
+	// to detect divergence.
+	var compareMapper *channels.ChannelMapper
+	if staged := col.stagedSyncFunction; staged != nil && channelMapper != nil {
+		if staged.appliesTo(doc.ID, body) {
+			col.collectionStats.StagedSyncFunctionCount.Add(1)
+			channelMapper, compareMapper = staged.mapper, channelMapper
+		} else {
+			compareMapper = staged.mapper
+		}
+	}
+	if channelMapper != nil {
 		// Call the ChannelMapper:
 		col.dbStats().Database().SyncFunctionCount.Add(1)
 		col.collectionStats.SyncFunctionCount.Add(1)
@@ -2305,16 +2342,22 @@ func (col *DatabaseCollectionWithUser) getChannelsAndAccess(ctx context.Context,
 		var output *channels.ChannelMapperOutput
 
 		startTime := time.Now()
-		output, err = col.ChannelMapper.MapToChannelsAndAccess(ctx, body, oldJson, metaMap,
+		output, err = channelMapper.MapToChannelsAndAccess(ctx, body, oldJson, metaMap,
 			MakeUserCtx(col.user, col.ScopeName, col.Name))
-		syncFunctionTimeNano := time.Since(startTime).Nanoseconds()
+		syncFunctionTime := time.Since(startTime)
+
+		col.dbStats().Database().SyncFunctionTime.Add(syncFunctionTime.Nanoseconds())
+		col.collectionStats.SyncFunctionTime.Add(syncFunctionTime.Nanoseconds())
+		col.dbStats().Database().SyncFunctionDuration.ObserveDuration(syncFunctionOutcome(output, err), syncFunctionTime)
 
-		col.dbStats().Database().SyncFunctionTime.Add(syncFunctionTimeNano)
-		col.collectionStats.SyncFunctionTime.Add(syncFunctionTimeNano)
+		if compareMapper != nil {
+			col.compareStagedSyncFunction(ctx, compareMapper, doc.ID, body, oldJson, metaMap, output, err)
+		}
 
 		if err == nil {
 			result = output.Channels
 			access = output.Access
+			accessExpiry = output.AccessExpiry
 			roles = output.Roles
 			expiry = output.Expiry
 			err = output.Rejection
@@ -2335,6 +2378,8 @@ func (col *DatabaseCollectionWithUser) getChannelsAndAccess(ctx context.Context,
 			base.WarnfCtx(ctx, "Sync fn exception: %+v; doc %q / %q", err, base.UD(doc.ID), base.MD(doc.CurrentRev))
 			if errors.Is(err, sgbucket.ErrJSTimeout) {
 				err = base.HTTPErrorf(500, "JS sync function timed out")
+				col.collectionStats.SyncFunctionTimeoutCount.Add(1)
+				col.dbStats().Database().SyncFunctionTimeoutCount.Add(1)
 			} else {
 				err = base.HTTPErrorf(500, "Exception in JS sync function")
 				col.collectionStats.SyncFunctionExceptionCount.Add(1)
@@ -2357,7 +2402,24 @@ func (col *DatabaseCollectionWithUser) getChannelsAndAccess(ctx context.Context,
 			result = base.SetOf(col.Name)
 		}
 	}
-	return result, access, roles, expiry, oldJson, err
+	return result, access, accessExpiry, roles, expiry, oldJson, err
+}
+
+// Outcomes of a sync function invocation, used to label sync function duration stats
+const (
+	syncFunctionOutcomeAccepted  = "accepted"
+	syncFunctionOutcomeRejected  = "rejected"
+	syncFunctionOutcomeException = "exception"
+)
+
+// syncFunctionOutcome returns the outcome of a sync function invocation that returned output and err.
+func syncFunctionOutcome(output *channels.ChannelMapperOutput, err error) string {
+	if err != nil {
+		return syncFunctionOutcomeException
+	} else if output.Rejection != nil {
+		return syncFunctionOutcomeRejected
+	}
+	return syncFunctionOutcomeAccepted
 }
 
 // Creates a userCtx object to be passed to the sync function
@@ -2422,10 +2484,12 @@ func (context *DatabaseContext) ComputeChannelsForPrincipal(ctx context.Context,
 		return nil, err
 	}
 
+	// Time-boxed grants that have expired are excluded
+	now := uint32(time.Now().Unix())
 	var accessRow QueryAccessRow
 	channelSet := channels.TimedSet{}
 	for results.Next(ctx, &accessRow) {
-		channelSet.Add(accessRow.Value)
+		channelSet.Add(accessRow.Value.Unexpired(now))
 	}
 
 	closeErr := results.Close()
@@ -13,6 +13,7 @@ package db
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"testing"
 	"time"
@@ -1267,7 +1268,7 @@ func TestGet1xRevAndChannels(t *testing.T) {
 	assert.Equal(t, []interface{}{"a"}, revisions[RevisionsIds])
 
 	// Delete the document, creating tombstone revision rev3
-	rev3, err := collection.DeleteDoc(ctx, docId, rev2)
+	rev3, _, err := collection.DeleteDoc(ctx, docId, rev2)
 	require.NoError(t, err)
 	bodyBytes, removed, err = collection.get1xRevFromDoc(ctx, doc2, rev3, true)
 	assert.False(t, removed)
@@ -1586,7 +1587,7 @@ func TestGetChannelsAndAccess(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			body := Body{}
 			require.NoError(t, body.Unmarshal([]byte(test.body)))
-			result, access, roles, expiry, oldJson, err := collection.getChannelsAndAccess(base.TestCtx(t), doc, body, nil, "")
+			result, access, _, roles, expiry, oldJson, err := collection.getChannelsAndAccess(base.TestCtx(t), doc, body, nil, "")
 			require.NoError(t, err)
 			require.Equal(t, "", oldJson)
 			require.Nil(t, expiry)
@@ -1670,3 +1671,50 @@ func TestAssignSequenceReleaseLoop(t *testing.T) {
 	releasedSequenceCount := db.DbStats.Database().SequenceReleasedCount.Value() - startReleasedSequenceCount
 	assert.Equal(t, int64(expectedReleasedSequenceCount), releasedSequenceCount)
 }
+
+func TestOldRevisionBackupPolicy(t *testing.T) {
+	putRevs := func(t *testing.T, collection *DatabaseCollectionWithUser, ctx context.Context, numRevs int) []string {
+		var history []string
+		for i := 1; i <= numRevs; i++ {
+			history = append([]string{fmt.Sprintf("%d-a", i)}, history...)
+			_, _, err := collection.PutExistingRevWithBody(ctx, "doc1", Body{"version": i}, history, false)
+			require.NoError(t, err)
+		}
+		return history
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		db, ctx := SetupTestDBForDataStoreWithOptions(t, base.GetTestBucket(t), DatabaseContextOptions{
+			OldRevBackupOptions: OldRevBackupOptions{Disabled: true},
+		})
+		defer db.Close(ctx)
+		collection := GetSingleDatabaseCollectionWithUser(t, db)
+
+		putRevs(t, collection, ctx, 2)
+		_, err := collection.getOldRevisionJSON(ctx, "doc1", "1-a")
+		assert.Equal(t, ErrMissing, err)
+		assert.Equal(t, int64(0), db.DbStats.Database().OldRevBackupCount.Value())
+		assert.Equal(t, int64(1), db.DbStats.Database().OldRevBackupMissCount.Value())
+	})
+
+	t.Run("max per doc", func(t *testing.T) {
+		db, ctx := SetupTestDBForDataStoreWithOptions(t, base.GetTestBucket(t), DatabaseContextOptions{
+			OldRevBackupOptions: OldRevBackupOptions{MaxPerDoc: 2},
+		})
+		defer db.Close(ctx)
+		collection := GetSingleDatabaseCollectionWithUser(t, db)
+
+		// Each update backs up the previous revision, and removes the backup of the revision two generations older
+		putRevs(t, collection, ctx, 4)
+		_, err := collection.getOldRevisionJSON(ctx, "doc1", "1-a")
+		assert.Equal(t, ErrMissing, err)
+		for _, revID := range []string{"2-a", "3-a"} {
+			body, err := collection.getOldRevisionJSON(ctx, "doc1", revID)
+			require.NoError(t, err)
+			assert.Contains(t, string(body), `"version":`+revID[:1])
+		}
+		assert.Equal(t, int64(3), db.DbStats.Database().OldRevBackupCount.Value())
+		assert.Greater(t, db.DbStats.Database().OldRevBackupBytes.Value(), int64(0))
+		assert.Equal(t, int64(1), db.DbStats.Database().OldRevBackupMissCount.Value())
+	})
+}
@@ -33,6 +33,7 @@ const (
 	DBOnline
 	DBStopping
 	DBResyncing
+	DBPaused
 )
 
 var RunStateString = []string{
@@ -41,6 +42,7 @@ var RunStateString = []string{
 	DBOnline:    "Online",
 	DBStopping:  "Stopping",
 	DBResyncing: "Resyncing",
+	DBPaused:    "Paused",
 }
 
 const (
@@ -91,7 +93,9 @@ type DatabaseContext struct {
 	BucketLock                  sync.RWMutex       // Control Access to the underlying bucket object
 	mutationListener            changeListener     // Caching feed listener
 	ImportListener              *importListener    // Import feed listener
+	importQuarantine            *importQuarantine  // Documents quarantined after repeatedly failing import, set when importing
 	sequences                   *sequenceAllocator // Source of new sequence numbers
+	sequenceEpoch               uint64             // Current sequence epoch from the sequence fence, accessed atomically
 	StartTime                   time.Time          // Timestamp when context was instantiated
 	RevsLimit                   uint32             // Max depth a document's revision tree can grow to
 	autoImport                  bool               // Add sync data to new untracked couchbase server docs?  (Xattr mode specific)
@@ -105,6 +109,11 @@ type DatabaseContext struct {
 	ResyncManager               *BackgroundManager
 	TombstoneCompactionManager  *BackgroundManager
 	AttachmentCompactionManager *BackgroundManager
+	AttachmentVerifyManager     *BackgroundManager
+	ImportBackfillManager       *BackgroundManager
+	MetadataCompactionManager   *BackgroundManager
+	ChannelRenameManager        *BackgroundManager
+	MaintenanceSchedule         *MaintenanceSchedule // When heavy background tasks are allowed to run
 	ExitChanges                 chan struct{}        // Active _changes feeds on the DB will close when this channel is closed
 	OIDCProviders               auth.OIDCProviderMap // OIDC clients
 	LocalJWTProviders           auth.LocalJWTProviderMap
@@ -129,6 +138,16 @@ type DatabaseContext struct {
 	MetadataKeys                 *base.MetadataKeys             // Factory to generate metadata document keys
 	RequireResync                base.ScopeAndCollectionNames   // Collections requiring resync before database can go online
 	CORS                         *auth.CORSConfig               // CORS configuration
+	federatedBuckets             map[string]*federatedBucket    // Additional buckets storing collections, keyed by bucket name
+	accessExpiry                 *accessExpiryScheduler         // Revokes time-boxed channel grants when they expire
+	pushResumeTokens             *pushResumeTokens              // Batches of proposed changes clients can skip reproposing on reconnect
+	requestQuotas                *requestQuotas                 // Counts users' reads and writes against the database's daily quotas
+	FlightRecorder               *FlightRecorder                // Captures the BLIP messages of a specific user or document on request
+	sessionCookieEncryption      *auth.SessionCookieEncryption  // Encrypts session cookies, if session cookie keys are configured
+	accessSnapshotStore          base.DataStore                 // Stores access snapshots, if configured to be stored outside the metadata store
+	statsRestored                bool                           // Set once this node's persisted counters have been restored, so they can be persisted
+	pauseLock                    sync.Mutex                     // Synchronises pausing and resuming the database
+	resumed                      chan struct{}                  // Closed when a paused database is resumed. Nil when not paused
 }
 
 type Scope struct {
@@ -155,26 +174,49 @@ type DatabaseContextOptions struct {
 	AllowConflicts                *bool            // False forbids creating conflicts
 	SendWWWAuthenticateHeader     *bool            // False disables setting of 'WWW-Authenticate' header
 	DisablePasswordAuthentication bool             // True enforces OIDC/guest only
+	GuestChannelAllowlist         base.Set         // If non-nil, the guest user can only see these channels, regardless of their grants
+	SystemChannels                base.Set         // Channels excluded from the star channel, which are only visible to users with an explicit grant
 	UseViews                      bool             // Force use of views
 	DeltaSyncOptions              DeltaSyncOptions // Delta Sync Options
 	CompactInterval               uint32           // Interval in seconds between compaction is automatically ran - 0 means don't run
 	SGReplicateOptions            SGReplicateOptions
+	OldRevBackupOptions           OldRevBackupOptions
+	SessionCookieKeys             []auth.SessionCookieKey // If set, session cookies are encrypted with these keys, the first of which encrypts new cookies
 	SlowQueryWarningThreshold     time.Duration
-	QueryPaginationLimit          int    // Limit used for pagination of queries. If not set defaults to DefaultQueryPaginationLimit
-	UserXattrKey                  string // Key of user xattr that will be accessible from the Sync Function. If empty the feature will be disabled.
+	SlowChangesThreshold          time.Duration // Changes feed iterations taking longer than this are logged under the SlowChanges log key. Zero disables
+	MaxRevMessageHistory          uint32        // Caps the history length sent in rev messages, regardless of the length requested by the client. Zero disables
+	MaxPendingRevs                int           // Maximum number of incoming revs processed concurrently per replication connection. Zero disables
+	MaxPendingRevBytes            int64         // Maximum total body size of incoming revs processed concurrently per replication connection. Zero disables
+	DeferredAttachmentWindow      time.Duration // How long clients deferring attachments can get a revision's attachments for after acknowledging it. Zero uses DefaultDeferredAttachmentWindow
+	IdempotencyKeyTTL             time.Duration // How long the responses to document writes with idempotency keys are replayed for retries. Zero uses DefaultIdempotencyKeyTTL
+	QueryPaginationLimit          int           // Limit used for pagination of queries. If not set defaults to DefaultQueryPaginationLimit
+	UserXattrKey                  string        // Key of user xattr that will be accessible from the Sync Function. If empty the feature will be disabled.
 	ClientPartitionWindow         time.Duration
 	BcryptCost                    int
 	GroupID                       string
 	JavascriptTimeout             time.Duration // Max time the JS functions run for (ie. sync fn, import filter)
 	Serverless                    bool          // If running in serverless mode
 	Scopes                        ScopesOptions
-	MetadataStore                 base.DataStore // If set, use this location/connection for SG metadata storage - if not set, metadata is stored using the same location/connection as the bucket used for data storage.
-	MetadataID                    string         // MetadataID used for metadata storage
-	BlipStatsReportingInterval    int64          // interval to report blip stats in milliseconds
-	ChangesRequestPlus            bool           // Sets the default value for request_plus, for non-continuous changes feeds
+	MetadataStore                 base.DataStore                // If set, use this location/connection for SG metadata storage - if not set, metadata is stored using the same location/connection as the bucket used for data storage.
+	MetadataID                    string                        // MetadataID used for metadata storage
+	BlipStatsReportingInterval    int64                         // interval to report blip stats in milliseconds
+	ChangesRequestPlus            bool                          // Sets the default value for request_plus, for non-continuous changes feeds
+	ChangesIncludeChannels        bool                          // Retains documents' channels in the channel cache, so admin changes feeds can include them
+	AttachmentPolicy              *AttachmentPolicy             // Restricts the size and content type of attachments written to the database
+	ChannelHistoryOptions         ChannelHistoryOptions         // Retention policy for the channel history stored in document sync metadata
+	ReplicationFilters            map[string]*ReplicationFilter // Named filters clients can reference in subChanges, keyed by name
 	ConfigPrincipals              *ConfigPrincipals
-	PurgeInterval                 *time.Duration // Add a custom purge interval, as a testing seam. If nil, this parameter is filled in by Couchbase Server, with a fallback to a default value SG has.
-	LoggingConfig                 DbLogConfig    // Per-database log configuration
+	PurgeInterval                 *time.Duration             // Add a custom purge interval, as a testing seam. If nil, this parameter is filled in by Couchbase Server, with a fallback to a default value SG has.
+	LoggingConfig                 DbLogConfig                // Per-database log configuration
+	FederatedBuckets              map[string]base.Bucket     // Additional buckets storing collections, keyed by bucket name. Closed along with the database.
+	MaintenanceWindows            []*MaintenanceWindow       // Windows in which heavy background tasks run. If empty, they can always run
+	CheckpointMirrorClusterID     string                     // If set, client checkpoints are mirrored for sister clusters, identified as written by this cluster
+	FenceDuplicateCheckpoints     bool                       // Reject checkpoint writes by a second replicator detected writing the checkpoint of the same client ID
+	RequestQuotaOptions           RequestQuotaOptions        // Daily limits on the revisions each user can be sent and push
+	AccessSnapshotOptions         AccessSnapshotOptions      // Periodic snapshots of each user's effective access, for auditing
+	ExternalAuthorizerOptions     *ExternalAuthorizerOptions // If set, an external authorization service restricts users' reads and writes
+	StatsPersistenceOptions       StatsPersistenceOptions    // Periodic persistence of counter stats, so they survive a restart
+	SequenceCheckOptions          SequenceCheckOptions       // Consistency check of the sequence counter against the bucket when the database is started
 }
 
 // DbLogConfig can be used to customise the logging for logs associated with this database.
@@ -195,8 +237,13 @@ type ScopeOptions struct {
 }
 
 type CollectionOptions struct {
-	Sync         *string               // Collection sync function
-	ImportFilter *ImportFilterFunction // Opt-in filter for document import
+	Sync             *string               // Collection sync function
+	ImportFilter     *ImportFilterFunction // Opt-in filter for document import
+	Bucket           string                // Name of the bucket in FederatedBuckets storing the collection. Empty for the database's bucket.
+	AttachmentPolicy *AttachmentPolicy     // Attachment policy for the collection, overriding the database's policy when set
+	StagedSync       *StagedSyncFunction   // Sync function being rolled out to a subset of the collection's writes
+	AllowConflicts   *bool                 // Whether the collection allows conflicts, overriding the database's setting when set
+	RevsLimit        *uint32               // Max depth of the collection's revision trees, overriding the database's revs limit when set
 }
 
 type SGReplicateOptions struct {
@@ -217,6 +264,12 @@ type DeltaSyncOptions struct {
 	RevMaxAgeSeconds uint32 // The number of seconds deltas for old revs are available for
 }
 
+// OldRevBackupOptions controls the backups of old revision bodies kept for delta sync and in-flight replications.
+type OldRevBackupOptions struct {
+	Disabled  bool   // Whether old revision bodies are not backed up
+	MaxPerDoc uint32 // The maximum number of revision backups kept for each document. Zero means no limit
+}
+
 type APIEndpoints struct {
 
 	// This setting is only needed for testing purposes.  In the Couchbase Lite unit tests that run in "integration mode"
@@ -252,8 +305,10 @@ type WarningThresholds struct {
 
 // Options associated with the import of documents not written by Sync Gateway
 type ImportOptions struct {
-	BackupOldRev     bool   // Create temporary backup of old revision body when available
-	ImportPartitions uint16 // Number of partitions for import
+	BackupOldRev        bool   // Create temporary backup of old revision body when available
+	ImportPartitions    uint16 // Number of partitions for import
+	QuarantineThreshold uint   // Number of consecutive import failures for a document before it is quarantined, 0 to disable
+	BinaryDocs          bool   // Import binary (non-JSON) documents as a JSON document holding the binary content as an attachment
 }
 
 // Represents a simulated CouchDB database. A new instance is created for each HTTP request,
@@ -428,13 +483,31 @@ func NewDatabaseContext(ctx context.Context, dbName string, bucket base.Bucket,
 	}
 
 	dbContext.terminator = make(chan bool)
+	dbContext.accessExpiry = newAccessExpiryScheduler()
+	dbContext.pushResumeTokens = newPushResumeTokens()
+	dbContext.requestQuotas = newRequestQuotas(dbContext)
+	dbContext.FlightRecorder = NewFlightRecorder()
+	if len(options.SessionCookieKeys) > 0 {
+		dbContext.sessionCookieEncryption, err = auth.NewSessionCookieEncryption(options.SessionCookieKeys, metadataStore, metaKeys)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if options.AccessSnapshotOptions.Keyspace != nil {
+		dbContext.accessSnapshotStore, err = bucket.NamedDataStore(*options.AccessSnapshotOptions.Keyspace)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open access snapshot keyspace %s: %w", base.MD(options.AccessSnapshotOptions.Keyspace.String()), err)
+		}
+	}
 
 	dbContext.EventMgr = NewEventManager(dbContext.terminator)
+	dbContext.MaintenanceSchedule = NewMaintenanceSchedule(options.MaintenanceWindows)
 
 	dbContext.sequences, err = newSequenceAllocator(ctx, metadataStore, dbContext.DbStats.Database(), metaKeys)
 	if err != nil {
 		return nil, err
 	}
+	dbContext.sequences.regressionCallback = dbContext.handleSequenceRegression
 
 	cleanupFunctions = append(cleanupFunctions, func() {
 		dbContext.sequences.Stop(ctx)
@@ -491,7 +564,14 @@ func NewDatabaseContext(ctx context.Context, dbName string, bucket base.Bucket,
 			if !base.IsDefaultCollection(scopeName, collName) {
 				ctx = base.CollectionLogCtx(ctx, collName)
 			}
-			dataStore, err := bucket.NamedDataStore(base.ScopeAndCollectionName{Scope: scopeName, Collection: collName})
+			collectionBucket := bucket
+			if collOpts.Bucket != "" {
+				collectionBucket = options.FederatedBuckets[collOpts.Bucket]
+				if collectionBucket == nil {
+					return nil, fmt.Errorf("bucket %s for collection %s.%s has not been opened", base.MD(collOpts.Bucket), base.MD(scopeName), base.MD(collName))
+				}
+			}
+			dataStore, err := collectionBucket.NamedDataStore(base.ScopeAndCollectionName{Scope: scopeName, Collection: collName})
 			if err != nil {
 				return nil, err
 			}
@@ -503,6 +583,9 @@ func NewDatabaseContext(ctx context.Context, dbName string, bucket base.Bucket,
 			if err != nil {
 				return nil, err
 			}
+			if collOpts.Bucket != "" {
+				dbContext.addFederatedCollection(ctx, collectionBucket, dbCollection)
+			}
 			if collOpts.Sync != nil {
 				fnChanged, err := dbCollection.UpdateSyncFun(ctx, *collOpts.Sync)
 				if err != nil {
@@ -520,16 +603,29 @@ func NewDatabaseContext(ctx context.Context, dbName string, bucket base.Bucket,
 			if collOpts.ImportFilter != nil {
 				dbCollection.importFilterFunction = collOpts.ImportFilter
 			}
+			dbCollection.attachmentPolicy = collOpts.AttachmentPolicy
+			dbCollection.stagedSyncFunction = collOpts.StagedSync
+			dbCollection.allowConflicts = collOpts.AllowConflicts
+			dbCollection.revsLimitOverride = collOpts.RevsLimit
 
 			dbContext.Scopes[scopeName].Collections[collName] = dbCollection
 
-			collectionID := dbCollection.GetCollectionID()
-			dbContext.CollectionByID[collectionID] = dbCollection
 			collectionNameMap[collName] = struct{}{}
 		}
 		dbContext.CollectionNames[scopeName] = collectionNameMap
 	}
 
+	// Collections in the database's bucket are registered first, so that federated collections are only assigned a
+	// different ID when their own clashes.
+	for _, scope := range dbContext.Scopes {
+		for _, dbCollection := range scope.Collections {
+			if !dbCollection.IsFederated() {
+				dbContext.CollectionByID[dbCollection.GetCollectionID()] = dbCollection
+			}
+		}
+	}
+	dbContext.assignFederatedCollectionIDs(ctx)
+
 	if syncFunctionsChanged {
 		base.InfofCtx(ctx, base.KeyAll, "**NOTE:** %q's sync function has changed. The new function may assign different channels to documents, or permissions to users. You may want to re-sync the database to update these.", base.MD(dbContext.Name))
 	}
@@ -573,14 +669,22 @@ func (context *DatabaseContext) Close(ctx context.Context) {
 
 	context.OIDCProviders.Stop()
 	close(context.terminator)
+	context.accessExpiry.stop()
 
 	// Stop All background processors
-	bgManagers := context.stopBackgroundManagers()
+	bgManagers := context.StopBackgroundManagers()
 
 	// Wait for database background tasks to finish.
 	waitForBGTCompletion(ctx, BGTCompletionMaxWait, context.backgroundTasks, context.Name)
+	if context.requestQuotas.enabled() {
+		context.requestQuotas.flush(ctx)
+	}
+	if err := context.persistStats(ctx); err != nil {
+		base.WarnfCtx(ctx, "Error persisting stats for %q on close: %v", base.MD(context.Name), err)
+	}
 	context.sequences.Stop(ctx)
 	context.mutationListener.Stop(ctx)
+	context.stopFederatedListeners(ctx)
 	context.changeCache.Stop(ctx)
 	// Stop the channel cache and it's background tasks.
 	context.channelCache.Stop(ctx)
@@ -596,36 +700,50 @@ func (context *DatabaseContext) Close(ctx context.Context) {
 
 	context.Bucket.Close(ctx)
 	context.Bucket = nil
+	context.closeFederatedBuckets(ctx)
 
 	base.RemovePerDbStats(context.Name)
 
 }
 
-// stopBackgroundManagers stops any running BackgroundManager.
-// Returns a list of BackgroundManager it signalled to stop
-func (context *DatabaseContext) stopBackgroundManagers() []*BackgroundManager {
+// BackgroundManagers returns the database's background managers.
+func (context *DatabaseContext) BackgroundManagers() []*BackgroundManager {
 	bgManagers := make([]*BackgroundManager, 0)
-
-	if context.ResyncManager != nil {
-		if !isBackgroundManagerStopped(context.ResyncManager.GetRunState()) {
-			if err := context.ResyncManager.Stop(); err == nil {
-				bgManagers = append(bgManagers, context.ResyncManager)
-			}
+	for _, bgManager := range []*BackgroundManager{
+		context.ResyncManager,
+		context.AttachmentCompactionManager,
+		context.TombstoneCompactionManager,
+		context.AttachmentVerifyManager,
+		context.ImportBackfillManager,
+		context.MetadataCompactionManager,
+		context.ChannelRenameManager,
+	} {
+		if bgManager != nil {
+			bgManagers = append(bgManagers, bgManager)
 		}
 	}
+	return bgManagers
+}
 
-	if context.AttachmentCompactionManager != nil {
-		if !isBackgroundManagerStopped(context.AttachmentCompactionManager.GetRunState()) {
-			if err := context.AttachmentCompactionManager.Stop(); err == nil {
-				bgManagers = append(bgManagers, context.AttachmentCompactionManager)
-			}
+// BackgroundManager returns the database's background manager with the given name, or nil if there isn't one.
+func (context *DatabaseContext) BackgroundManager(name string) *BackgroundManager {
+	for _, bgManager := range context.BackgroundManagers() {
+		if bgManager.GetName() == name {
+			return bgManager
 		}
 	}
+	return nil
+}
+
+// StopBackgroundManagers stops any running BackgroundManager.
+// Returns a list of BackgroundManager it signalled to stop
+func (context *DatabaseContext) StopBackgroundManagers() []*BackgroundManager {
+	bgManagers := make([]*BackgroundManager, 0)
 
-	if context.TombstoneCompactionManager != nil {
-		if !isBackgroundManagerStopped(context.TombstoneCompactionManager.GetRunState()) {
-			if err := context.TombstoneCompactionManager.Stop(); err == nil {
-				bgManagers = append(bgManagers, context.TombstoneCompactionManager)
+	for _, bgManager := range context.BackgroundManagers() {
+		if !isBackgroundManagerStopped(bgManager.GetRunState()) {
+			if err := bgManager.Stop(); err == nil {
+				bgManagers = append(bgManagers, bgManager)
 			}
 		}
 	}
@@ -707,7 +825,7 @@ func (context *DatabaseContext) RestartListener(ctx context.Context) error {
 	time.Sleep(2 * time.Second)
 	context.mutationListener.Init(context.Bucket.GetName(), context.Options.GroupID, context.MetadataKeys)
 	cacheFeedStatsMap := context.DbStats.Database().CacheFeedMapStats
-	if err := context.mutationListener.Start(ctx, context.Bucket, cacheFeedStatsMap.Map, context.Scopes, context.MetadataStore); err != nil {
+	if err := context.mutationListener.Start(ctx, context.Bucket, cacheFeedStatsMap.Map, context.bucketScopes(""), context.MetadataStore); err != nil {
 		return err
 	}
 	return nil
@@ -788,7 +906,7 @@ func (context *DatabaseContext) NotifyTerminatedChanges(ctx context.Context, use
 
 func (dc *DatabaseContext) TakeDbOffline(ctx context.Context, reason string) error {
 
-	if atomic.CompareAndSwapUint32(&dc.State, DBOnline, DBStopping) {
+	if atomic.CompareAndSwapUint32(&dc.State, DBOnline, DBStopping) || atomic.CompareAndSwapUint32(&dc.State, DBPaused, DBStopping) {
 		// notify all active _changes feeds to close
 		close(dc.ExitChanges)
 
@@ -855,6 +973,9 @@ func (context *DatabaseContext) Authenticator(ctx context.Context) *auth.Authent
 		LogCtx:                     ctx,
 		Collections:                context.CollectionNames,
 		MetaKeys:                   context.MetadataKeys,
+		GuestChannelAllowlist:      context.Options.GuestChannelAllowlist,
+		SystemChannels:             context.Options.SystemChannels,
+		SessionCookieEncryption:    context.sessionCookieEncryption,
 	})
 
 	return authenticator
@@ -1431,6 +1552,10 @@ func (db *Database) Compact(ctx context.Context, skipRunningStateCheck bool, cal
 		}
 
 		for {
+			// Pause between batches while outside the maintenance window
+			if !db.MaintenanceSchedule.WaitForWindow(ctx, "tombstone compaction", terminator) {
+				return purgedDocCount, nil
+			}
 			purgedDocs := make([]string, 0)
 			results, err := collection.QueryTombstones(ctx, purgeOlderThan, QueryTombstoneBatch)
 			if err != nil {
@@ -1553,6 +1678,12 @@ func (db *DatabaseCollectionWithUser) UpdateAllDocChannels(ctx context.Context,
 	var unusedSequences []uint64
 	highSeq := uint64(0)
 	for {
+		// Pause between batches while outside the maintenance window
+		if !db.dbCtx.MaintenanceSchedule.WaitForWindow(ctx, "resync", terminator) {
+			base.InfofCtx(ctx, base.KeyAll, "Resync was stopped before the operation could be completed. System "+
+				"may be in an inconsistent state. Docs changed: %d Docs Processed: %d", docsChanged, docsProcessed)
+			return docsChanged, nil
+		}
 		results, err := db.QueryResync(ctx, queryLimit, startSeq, endSeq)
 		if err != nil {
 			return 0, err
@@ -1727,11 +1858,12 @@ func (db *DatabaseCollectionWithUser) getResyncedDocument(ctx context.Context, d
 		if err != nil {
 			return
 		}
-		channels, access, roles, syncExpiry, _, err := db.getChannelsAndAccess(ctx, doc, body, metaMap, rev.ID)
+		channels, access, accessExpiry, roles, syncExpiry, _, err := db.getChannelsAndAccess(ctx, doc, body, metaMap, rev.ID)
 		if err != nil {
 			// Probably the validator rejected the doc
 			base.WarnfCtx(ctx, "Error calling sync() on doc %q: %v", base.UD(docid), err)
 			access = nil
+			accessExpiry = nil
 			channels = nil
 		}
 		rev.Channels = channels
@@ -1746,12 +1878,14 @@ func (db *DatabaseCollectionWithUser) getResyncedDocument(ctx context.Context, d
 			}
 
 			changedChannels, err := doc.updateChannels(ctx, channels)
-			changed = len(doc.Access.updateAccess(ctx, doc, access)) +
-				len(doc.RoleAccess.updateAccess(ctx, doc, roles)) +
+			changed = len(doc.Access.updateAccess(ctx, doc, access, accessExpiry)) +
+				len(doc.RoleAccess.updateAccess(ctx, doc, roles, nil)) +
 				len(changedChannels)
 			if err != nil {
 				return
 			}
+			// Resync rewrites the document, so apply the channel history retention policy to existing history
+			changed += db.pruneChannelSetHistory(ctx, doc)
 			// Only update document expiry based on the current (active) rev
 			if syncExpiry != nil {
 				doc.UpdateExpiry(*syncExpiry)
@@ -2165,6 +2299,13 @@ func (db *DatabaseContext) StartOnlineProcesses(ctx context.Context) (returnedEr
 		}
 	}()
 
+	// Check the sequence counter before anything allocates sequences from it
+	if db.Options.SequenceCheckOptions.Enabled {
+		if _, err := db.checkSequenceConsistency(ctx); err != nil {
+			return err
+		}
+	}
+
 	// Create config-based principals
 	// Create default users & roles:
 	if db.Options.ConfigPrincipals != nil {
@@ -2201,7 +2342,7 @@ func (db *DatabaseContext) StartOnlineProcesses(ctx context.Context) (returnedEr
 		return err
 	}
 
-	db.mutationListener.OnChangeCallback = db.changeCache.DocChanged
+	db.mutationListener.OnChangeCallback = db.changeCache.feedCallback()
 
 	if base.IsEnterpriseEdition() {
 		cfgSG, ok := db.CfgSG.(*base.CfgSG)
@@ -2248,7 +2389,7 @@ func (db *DatabaseContext) StartOnlineProcesses(ctx context.Context) (returnedEr
 	// Start DCP feed
 	base.InfofCtx(ctx, base.KeyChanges, "Starting mutation feed on bucket %v", base.MD(db.Bucket.GetName()))
 	cacheFeedStatsMap := db.DbStats.Database().CacheFeedMapStats
-	if err := db.mutationListener.Start(ctx, db.Bucket, cacheFeedStatsMap.Map, db.Scopes, db.MetadataStore); err != nil {
+	if err := db.mutationListener.Start(ctx, db.Bucket, cacheFeedStatsMap.Map, db.bucketScopes(""), db.MetadataStore); err != nil {
 		db.channelCache = nil
 		return err
 	}
@@ -2257,6 +2398,22 @@ func (db *DatabaseContext) StartOnlineProcesses(ctx context.Context) (returnedEr
 		db.mutationListener.Stop(ctx)
 	})
 
+	// Federated buckets have their own feeds, merged into the same change cache
+	if err := db.startFederatedListeners(ctx); err != nil {
+		db.channelCache = nil
+		return err
+	}
+
+	cleanupFunctions = append(cleanupFunctions, func() {
+		db.stopFederatedListeners(ctx)
+	})
+
+	// Advance _sync:seq if it's moved backwards since sequences were last allocated, so the change cache starts after
+	// every sequence already allocated
+	if err := db.checkSequenceFence(ctx); err != nil {
+		return err
+	}
+
 	// Get current value of _sync:seq
 	initialSequence, seqErr := db.sequences.lastSequence(ctx)
 	if seqErr != nil {
@@ -2280,6 +2437,10 @@ func (db *DatabaseContext) StartOnlineProcesses(ctx context.Context) (returnedEr
 	// If this is an xattr import node, start import feed.  Must be started after the caching DCP feed, as import cfg
 	// subscription relies on the caching feed.
 	if db.autoImport {
+		db.importQuarantine = newImportQuarantine(db)
+		if err := db.importQuarantine.load(); err != nil {
+			base.WarnfCtx(ctx, "Unable to load import quarantine: %v", err)
+		}
 		db.ImportListener = NewImportListener(ctx, db.MetadataKeys.DCPCheckpointPrefix(db.Options.GroupID), db)
 		if importFeedErr := db.ImportListener.StartImportFeed(db); importFeedErr != nil {
 			return importFeedErr
@@ -2345,6 +2506,9 @@ func (db *DatabaseContext) StartOnlineProcesses(ctx context.Context) (returnedEr
 					bgtTerminator.Close()
 				}()
 				bgt, err := NewBackgroundTask(ctx, "Compact", func(ctx context.Context) error {
+					if !db.MaintenanceSchedule.WaitForWindow(ctx, "scheduled tombstone compaction", bgtTerminator) {
+						return nil
+					}
 					_, err := db.Compact(ctx, false, func(purgedDocCount *int) {}, bgtTerminator)
 					if err != nil {
 						base.WarnfCtx(ctx, "Error trying to compact tombstoned documents for %q with error: %v", db.Name, err)
@@ -2372,6 +2536,66 @@ func (db *DatabaseContext) StartOnlineProcesses(ctx context.Context) (returnedEr
 	}
 	db.backgroundTasks = append(db.backgroundTasks, bgtSyncTime)
 
+	bgtSequenceFence, err := NewBackgroundTask(ctx, "RecordSequenceFence", func(ctx context.Context) error {
+		highSeq, err := db.sequences.getSequence()
+		if err != nil {
+			return err
+		}
+		return db.recordSequenceFence(ctx, highSeq)
+	}, sequenceFenceInterval, db.terminator)
+	if err != nil {
+		return err
+	}
+	db.backgroundTasks = append(db.backgroundTasks, bgtSequenceFence)
+
+	if db.requestQuotas.enabled() {
+		bgtQuotas, err := NewBackgroundTask(ctx, "FlushRequestQuotas", func(ctx context.Context) error {
+			db.requestQuotas.flush(ctx)
+			return nil
+		}, quotaFlushInterval, db.terminator)
+		if err != nil {
+			return err
+		}
+		db.backgroundTasks = append(db.backgroundTasks, bgtQuotas)
+	}
+
+	if db.Options.AccessSnapshotOptions.Interval > 0 {
+		// Wrap the dbContext's terminator in a SafeTerminator, to stop snapshotting when the database is closed
+		bgtTerminator := base.NewSafeTerminator()
+		go func() {
+			<-db.terminator
+			bgtTerminator.Close()
+		}()
+		bgtAccessSnapshot, err := NewBackgroundTask(ctx, "AccessSnapshot", func(ctx context.Context) error {
+			if err := db.SnapshotAccess(ctx, bgtTerminator); err != nil && !errors.Is(err, errAccessSnapshotStopped) {
+				base.WarnfCtx(ctx, "Error snapshotting user access for %q: %v", base.MD(db.Name), err)
+			}
+			return nil
+		}, db.Options.AccessSnapshotOptions.Interval, db.terminator)
+		if err != nil {
+			return err
+		}
+		db.backgroundTasks = append(db.backgroundTasks, bgtAccessSnapshot)
+	}
+
+	if db.Options.StatsPersistenceOptions.Interval > 0 {
+		if err := db.restoreStats(ctx); err != nil {
+			// Counters aren't persisted if they can't be restored, so that persisted counters never go backwards
+			base.WarnfCtx(ctx, "Unable to restore persisted stats for %q - stats won't be persisted: %v", base.MD(db.Name), err)
+		} else {
+			bgtPersistStats, err := NewBackgroundTask(ctx, "PersistStats", func(ctx context.Context) error {
+				if err := db.persistStats(ctx); err != nil {
+					base.WarnfCtx(ctx, "Error persisting stats for %q: %v", base.MD(db.Name), err)
+				}
+				return nil
+			}, db.Options.StatsPersistenceOptions.Interval, db.terminator)
+			if err != nil {
+				return err
+			}
+			db.backgroundTasks = append(db.backgroundTasks, bgtPersistStats)
+		}
+	}
+
 	if err := base.RequireNoBucketTTL(ctx, db.Bucket); err != nil {
 		return err
 	}
@@ -2389,6 +2613,10 @@ func (db *DatabaseContext) StartOnlineProcesses(ctx context.Context) (returnedEr
 
 	db.TombstoneCompactionManager = NewTombstoneCompactionManager()
 	db.AttachmentCompactionManager = NewAttachmentCompactionManager(db.MetadataStore, db.MetadataKeys)
+	db.AttachmentVerifyManager = NewAttachmentVerifyManager()
+	db.ImportBackfillManager = NewImportBackfillManager()
+	db.MetadataCompactionManager = NewMetadataCompactionManager()
+	db.ChannelRenameManager = NewChannelRenameManager()
 
 	db.startReplications(ctx)
 
@@ -11,6 +11,8 @@ package db
 import (
 	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/couchbase/sync_gateway/auth"
@@ -25,7 +27,17 @@ type DatabaseCollection struct {
 	collectionStats      *base.CollectionStats   // pointer to the collection stats (to avoid map lookups when used)
 	dbCtx                *DatabaseContext        // pointer to database context to allow passthrough of functions
 	ChannelMapper        *channels.ChannelMapper // Collection's sync function
+	channelMapperLock    sync.RWMutex            // Guards ChannelMapper replacement when the sync function is hot-reloaded
+	syncFnGeneration     atomic.Uint64           // Incremented whenever the collection's sync function is updated, to invalidate cached channel mappers
 	importFilterFunction *ImportFilterFunction   // collections import options
+	collectionID         uint32                  // ID of the collection within the database. Only differs from the data store's collection ID for federated collections.
+	federatedBucket      string                  // Name of the bucket storing the collection, when it isn't the database's bucket
+	channelSizes         channelSizeReportCache  // Most recent channel size report
+	attachmentPolicy     *AttachmentPolicy       // Collection's attachment policy, overriding the database's policy when set
+	stagedSyncFunction   *StagedSyncFunction     // Sync function being rolled out to a subset of the collection's writes
+	checkpointWriters    checkpointWriters       // Replicators writing client checkpoints, to detect clients sharing a client ID
+	allowConflicts       *bool                   // Whether the collection allows conflicts, overriding the database's setting when set
+	revsLimitOverride    *uint32                 // Collection's revs limit, overriding the database's revs limit when set
 	Name                 string
 	ScopeName            string
 }
@@ -34,7 +46,8 @@ type DatabaseCollection struct {
 // so this struct does not have to be thread-safe.
 type DatabaseCollectionWithUser struct {
 	*DatabaseCollection
-	user auth.User
+	user          auth.User
+	channelMapper *channels.ChannelMapper // Channel mapper resolved by the caller (e.g. a blip collection context). When nil, the collection's is used.
 }
 
 // newDatabaseCollection returns a collection which inherits values from the database but is specific to a given DataStore.
@@ -48,6 +61,7 @@ func newDatabaseCollection(ctx context.Context, dbContext *DatabaseContext, data
 		dbContext.Options.RevisionCacheOptions,
 		dbCollection,
 		dbContext.DbStats.Cache(),
+		stats,
 	)
 	if metadataStoreName, ok := base.AsDataStoreName(dataStore); ok {
 		dbCollection.ScopeName = metadataStoreName.ScopeName()
@@ -56,16 +70,25 @@ func newDatabaseCollection(ctx context.Context, dbContext *DatabaseContext, data
 		dbCollection.ScopeName = base.DefaultScope
 		dbCollection.Name = base.DefaultCollection
 	}
+	dbCollection.collectionID = base.GetCollectionID(base.GetBaseDataStore(dataStore))
+	if collection, err := base.AsCollection(base.GetBaseDataStore(dataStore)); err == nil && stats != nil {
+		collection.SetCircuitBreakerStats(base.CircuitBreakerStats{
+			State:    stats.KvCircuitBreakerState,
+			Opens:    stats.KvCircuitBreakerOpens,
+			Rejected: stats.KvOpsRejected,
+		})
+	}
 
 	return dbCollection, nil
 }
 
-// AllowConflicts allows different revisions of a single document to be pushed. This is controlled at the database level.
+// AllowConflicts allows different revisions of a single document to be pushed. This is controlled at the collection
+// level, defaulting to the database's setting.
 func (c *DatabaseCollection) AllowConflicts() bool {
-	if c.dbCtx.Options.AllowConflicts != nil {
-		return *c.dbCtx.Options.AllowConflicts
+	if c.allowConflicts != nil {
+		return *c.allowConflicts
 	}
-	return base.DefaultAllowConflicts
+	return c.dbCtx.AllowConflicts()
 }
 
 func (c *DatabaseCollection) GetCollectionDatastore() base.DataStore {
@@ -140,9 +163,15 @@ func (c *DatabaseCollection) exitChanges() chan struct{} {
 }
 
 // GetCollectionID returns a collectionID. If couchbase server does not return collections, it will return base.DefaultCollectionID, like the default collection for a Couchbase Server that does support collections.
+// For a federated collection, this is the ID the collection is known by within the database, which may differ from the ID
+// assigned by the bucket storing it.
 func (c *DatabaseCollection) GetCollectionID() uint32 {
-	ds := base.GetBaseDataStore(c.dataStore)
-	return base.GetCollectionID(ds)
+	return c.collectionID
+}
+
+// IsFederated returns true if the collection is stored in a bucket other than the database's bucket.
+func (c *DatabaseCollection) IsFederated() bool {
+	return c.federatedBucket != ""
 }
 
 // GetRevisionCacheForTest allow accessing a copy of revision cache.
@@ -150,6 +179,22 @@ func (c *DatabaseCollection) GetRevisionCacheForTest() RevisionCache {
 	return c.revisionCache
 }
 
+// PinRevisionCacheDoc pins the current revision of the document in the collection's revision cache, so that it's never
+// evicted. Runtime pins aren't persisted, and are lost when the database is reloaded.
+func (c *DatabaseCollection) PinRevisionCacheDoc(docID string) {
+	c.revisionCache.Pin(docID)
+}
+
+// UnpinRevisionCacheDoc releases a document pinned in the collection's revision cache.
+func (c *DatabaseCollection) UnpinRevisionCacheDoc(docID string) {
+	c.revisionCache.Unpin(docID)
+}
+
+// PinnedRevisionCacheDocs returns the IDs of the documents pinned in the collection's revision cache, in sorted order.
+func (c *DatabaseCollection) PinnedRevisionCacheDocs() []string {
+	return c.revisionCache.PinnedDocIDs()
+}
+
 // FlushChannelCache flush support. Currently test-only - added for unit test access from rest package
 func (c *DatabaseCollection) FlushChannelCache(ctx context.Context) error {
 	base.InfofCtx(ctx, base.KeyCache, "Flushing channel cache")
@@ -162,6 +207,7 @@ func (c *DatabaseCollection) FlushRevisionCacheForTest() {
 		c.dbCtx.Options.RevisionCacheOptions,
 		c,
 		c.dbStats().Cache(),
+		c.collectionStats,
 	)
 
 }
@@ -171,6 +217,11 @@ func (c *DatabaseCollection) ForceAPIForbiddenErrors() bool {
 	return c.dbCtx.Options.UnsupportedOptions != nil && c.dbCtx.Options.UnsupportedOptions.ForceAPIForbiddenErrors
 }
 
+// importBinaryDocs returns true if binary documents should be imported as attachments. This is controlled at the database level.
+func (c *DatabaseCollection) importBinaryDocs() bool {
+	return c.dbCtx.Options.ImportOptions.BinaryDocs
+}
+
 // importFilter returns the sync function.
 func (c *DatabaseCollection) importFilter() *ImportFilterFunction {
 	return c.importFilterFunction
@@ -211,6 +262,17 @@ func (c *DatabaseCollection) oldRevExpirySeconds() uint32 {
 	return c.dbCtx.Options.OldRevExpirySeconds
 }
 
+// oldRevBackupEnabled returns true if old revision bodies are backed up. This is controlled at a database level.
+func (c *DatabaseCollection) oldRevBackupEnabled() bool {
+	return !c.dbCtx.Options.OldRevBackupOptions.Disabled
+}
+
+// oldRevBackupMaxPerDoc is the maximum number of revision backups kept for each document, or 0 for no limit. This is
+// controlled at a database level.
+func (c *DatabaseCollection) oldRevBackupMaxPerDoc() uint32 {
+	return c.dbCtx.Options.OldRevBackupOptions.MaxPerDoc
+}
+
 // queryPaginationLimit limits the size of large queries. This is is controlled at a database level.
 func (c *DatabaseCollection) queryPaginationLimit() int {
 	return c.dbCtx.Options.QueryPaginationLimit
@@ -237,11 +299,26 @@ func (c *DatabaseCollection) RemoveFromChangeCache(ctx context.Context, docIDs [
 	return c.dbCtx.changeCache.Remove(ctx, c.GetCollectionID(), docIDs, startTime)
 }
 
-// revsLimit is the max depth a document's revision tree can grow to. This is controlled at a database level.
+// revsLimit is the max depth a document's revision tree can grow to. This is controlled at the collection level,
+// defaulting to the database's revs limit.
 func (c *DatabaseCollection) revsLimit() uint32 {
+	if c.revsLimitOverride != nil {
+		return *c.revsLimitOverride
+	}
 	return c.dbCtx.RevsLimit
 }
 
+// pruneChannelSetHistory applies the database's channel history retention policy to the document. Returns the number of
+// history entries pruned.
+func (c *DatabaseCollection) pruneChannelSetHistory(ctx context.Context, doc *Document) int {
+	pruned := doc.pruneChannelSetHistory(c.dbCtx.Options.ChannelHistoryOptions, time.Now())
+	if pruned > 0 {
+		base.DebugfCtx(ctx, base.KeyCRUD, "Pruned %d channel history entries for doc %q", pruned, base.UD(doc.ID))
+		c.dbStats().Database().ChannelHistoryEntriesPruned.Add(int64(pruned))
+	}
+	return pruned
+}
+
 // sequences returns the sequence generator for a collection.
 func (c *DatabaseCollection) sequences() *sequenceAllocator {
 	return c.dbCtx.sequences
@@ -277,11 +354,19 @@ func (c *DatabaseCollection) useViews() bool {
 	return c.dbCtx.Options.UseViews
 }
 
+// getChannelMapper returns the collection's current sync function, along with the generation it belongs to.
+func (c *DatabaseCollection) getChannelMapper() (mapper *channels.ChannelMapper, generation uint64) {
+	c.channelMapperLock.RLock()
+	defer c.channelMapperLock.RUnlock()
+	return c.ChannelMapper, c.syncFnGeneration.Load()
+}
+
 // Sets the collection's sync function based on the JS code from config.
 // Returns a boolean indicating whether the function is different from the saved one.
 // If multiple gateway instances try to update the function at the same time (to the same new
 // value) only one of them will get a changed=true result.
 func (c *DatabaseCollection) UpdateSyncFun(ctx context.Context, syncFun string) (changed bool, err error) {
+	c.channelMapperLock.Lock()
 	if syncFun == "" {
 		c.ChannelMapper = nil
 	} else if c.ChannelMapper != nil {
@@ -289,6 +374,8 @@ func (c *DatabaseCollection) UpdateSyncFun(ctx context.Context, syncFun string)
 	} else {
 		c.ChannelMapper = channels.NewChannelMapper(ctx, syncFun, c.dbCtx.Options.JavascriptTimeout)
 	}
+	c.syncFnGeneration.Add(1)
+	c.channelMapperLock.Unlock()
 	if err != nil {
 		base.WarnfCtx(ctx, "Error setting sync function: %s", err)
 		return
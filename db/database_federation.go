@@ -0,0 +1,159 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"sort"
+
+	sgbucket "github.com/couchbase/sg-bucket"
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// federatedBucket is an additional bucket storing some of a database's collections. All metadata (sequences,
+// principals, checkpoints) is stored in the database's bucket, so documents in federated collections share the
+// database's sequence space. Each federated bucket has its own caching feed, which is merged into the database's
+// change cache.
+type federatedBucket struct {
+	bucket        base.Bucket
+	listener      changeListener
+	collections   []*DatabaseCollection
+	collectionIDs map[uint32]uint32 // Maps the bucket's collection IDs to the IDs used within the database
+}
+
+// addFederatedCollection records that dbCollection is stored in the given federated bucket.
+func (context *DatabaseContext) addFederatedCollection(ctx context.Context, bucket base.Bucket, dbCollection *DatabaseCollection) {
+	if context.federatedBuckets == nil {
+		context.federatedBuckets = make(map[string]*federatedBucket)
+	}
+	name := bucket.GetName()
+	fb, ok := context.federatedBuckets[name]
+	if !ok {
+		fb = &federatedBucket{
+			bucket:        bucket,
+			collectionIDs: make(map[uint32]uint32),
+		}
+		context.federatedBuckets[name] = fb
+	}
+	dbCollection.federatedBucket = name
+	fb.collections = append(fb.collections, dbCollection)
+	base.InfofCtx(ctx, base.KeyAll, "Collection %s.%s is stored in bucket %s", base.MD(dbCollection.ScopeName), base.MD(dbCollection.Name), base.MD(name))
+}
+
+// assignFederatedCollectionIDs registers federated collections in CollectionByID. Collection IDs are only unique
+// within a bucket, so a federated collection whose ID is already in use within the database is assigned an unused
+// ID, and events from its bucket's feed are rewritten to use that ID. Must be called after the collections in the
+// database's bucket have been registered.
+func (context *DatabaseContext) assignFederatedCollectionIDs(ctx context.Context) {
+	var maxID uint32
+	for collectionID := range context.CollectionByID {
+		if collectionID > maxID {
+			maxID = collectionID
+		}
+	}
+	for _, fb := range context.federatedBuckets {
+		for _, dbCollection := range fb.collections {
+			if id := dbCollection.GetCollectionID(); id > maxID {
+				maxID = id
+			}
+		}
+	}
+
+	// Assign in a stable order, so that the IDs are consistent across nodes with the same config
+	bucketNames := make([]string, 0, len(context.federatedBuckets))
+	for name := range context.federatedBuckets {
+		bucketNames = append(bucketNames, name)
+	}
+	sort.Strings(bucketNames)
+	for _, name := range bucketNames {
+		fb := context.federatedBuckets[name]
+		sort.Slice(fb.collections, func(i, j int) bool {
+			return fb.collections[i].GetCollectionID() < fb.collections[j].GetCollectionID()
+		})
+		for _, dbCollection := range fb.collections {
+			bucketCollectionID := dbCollection.GetCollectionID()
+			if _, inUse := context.CollectionByID[bucketCollectionID]; inUse {
+				maxID++
+				dbCollection.collectionID = maxID
+				base.DebugfCtx(ctx, base.KeyAll, "Collection %s.%s in bucket %s has ID %d within the database", base.MD(dbCollection.ScopeName), base.MD(dbCollection.Name), base.MD(name), dbCollection.collectionID)
+			}
+			fb.collectionIDs[bucketCollectionID] = dbCollection.collectionID
+			context.CollectionByID[dbCollection.collectionID] = dbCollection
+		}
+	}
+}
+
+// bucketScopes returns the subset of the database's scopes and collections stored in the given bucket, where the
+// empty string identifies the database's bucket. Every scope is included, even if it has no collections in the bucket.
+func (context *DatabaseContext) bucketScopes(federatedBucketName string) map[string]Scope {
+	scopes := make(map[string]Scope, len(context.Scopes))
+	for scopeName, scope := range context.Scopes {
+		collections := make(map[string]*DatabaseCollection, len(scope.Collections))
+		for collectionName, dbCollection := range scope.Collections {
+			if dbCollection.federatedBucket == federatedBucketName {
+				collections[collectionName] = dbCollection
+			}
+		}
+		scopes[scopeName] = Scope{Collections: collections}
+	}
+	return scopes
+}
+
+// startFederatedListeners starts a caching feed on each federated bucket. Events are passed to the change cache using
+// the collection IDs assigned within the database.
+func (context *DatabaseContext) startFederatedListeners(ctx context.Context) error {
+	cacheFeedStatsMap := context.DbStats.Database().CacheFeedMapStats
+	for name, fb := range context.federatedBuckets {
+		fb.listener.Init(name, context.Options.GroupID, context.MetadataKeys)
+		fb.listener.OnChangeCallback = fb.docChangedFunc(context.changeCache.feedCallback())
+		base.InfofCtx(ctx, base.KeyChanges, "Starting mutation feed on federated bucket %v", base.MD(name))
+		if err := fb.listener.Start(ctx, fb.bucket, cacheFeedStatsMap.Map, context.bucketScopes(name), nil); err != nil {
+			context.stopFederatedListeners(ctx)
+			return err
+		}
+	}
+	return nil
+}
+
+// docChangedFunc wraps the change cache's callback to translate the bucket's collection IDs to the database's.
+func (fb *federatedBucket) docChangedFunc(docChanged DocChangedFunc) DocChangedFunc {
+	return func(event sgbucket.FeedEvent) {
+		collectionID, ok := fb.collectionIDs[event.CollectionID]
+		if !ok {
+			return
+		}
+		event.CollectionID = collectionID
+		docChanged(event)
+	}
+}
+
+func (context *DatabaseContext) stopFederatedListeners(ctx context.Context) {
+	for _, fb := range context.federatedBuckets {
+		fb.listener.Stop(ctx)
+	}
+}
+
+func (context *DatabaseContext) closeFederatedBuckets(ctx context.Context) {
+	for _, fb := range context.federatedBuckets {
+		fb.bucket.Close(ctx)
+	}
+}
+
+// filterFederatedCollectionIDs returns the given collection IDs, less those of federated collections.
+func (context *DatabaseContext) filterFederatedCollectionIDs(ctx context.Context, collectionIDs []uint32) []uint32 {
+	filtered := make([]uint32, 0, len(collectionIDs))
+	for _, collectionID := range collectionIDs {
+		if dbCollection, ok := context.CollectionByID[collectionID]; ok && dbCollection.IsFederated() {
+			base.WarnfCtx(ctx, "Skipping collection %s.%s stored in bucket %s, which isn't supported by DCP resync", base.MD(dbCollection.ScopeName), base.MD(dbCollection.Name), base.MD(dbCollection.federatedBucket))
+			continue
+		}
+		filtered = append(filtered, collectionID)
+	}
+	return filtered
+}
@@ -0,0 +1,71 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"testing"
+
+	sgbucket "github.com/couchbase/sg-bucket"
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssignFederatedCollectionIDs(t *testing.T) {
+	ctx := base.TestCtx(t)
+
+	primary := &DatabaseCollection{collectionID: 8, ScopeName: "scope1", Name: "primary"}
+	clashing := &DatabaseCollection{collectionID: 8, ScopeName: "scope1", Name: "clashing", federatedBucket: "legacy"}
+	unique := &DatabaseCollection{collectionID: 9, ScopeName: "scope1", Name: "unique", federatedBucket: "legacy"}
+
+	dbCtx := &DatabaseContext{
+		CollectionByID: map[uint32]*DatabaseCollection{8: primary},
+		Scopes: map[string]Scope{
+			"scope1": {Collections: map[string]*DatabaseCollection{
+				"primary":  primary,
+				"clashing": clashing,
+				"unique":   unique,
+			}},
+		},
+		federatedBuckets: map[string]*federatedBucket{
+			"legacy": {
+				collections:   []*DatabaseCollection{unique, clashing},
+				collectionIDs: make(map[uint32]uint32),
+			},
+		},
+	}
+	dbCtx.assignFederatedCollectionIDs(ctx)
+
+	assert.Equal(t, uint32(8), primary.GetCollectionID())
+	assert.Equal(t, uint32(10), clashing.GetCollectionID())
+	assert.Equal(t, uint32(9), unique.GetCollectionID())
+	assert.Len(t, dbCtx.CollectionByID, 3)
+	assert.Equal(t, clashing, dbCtx.CollectionByID[10])
+
+	fb := dbCtx.federatedBuckets["legacy"]
+	assert.Equal(t, map[uint32]uint32{8: 10, 9: 9}, fb.collectionIDs)
+
+	// Events from the federated bucket's feed are delivered with the database's collection IDs
+	var received []uint32
+	docChanged := fb.docChangedFunc(func(event sgbucket.FeedEvent) {
+		received = append(received, event.CollectionID)
+	})
+	docChanged(sgbucket.FeedEvent{CollectionID: 8})
+	docChanged(sgbucket.FeedEvent{CollectionID: 9})
+	docChanged(sgbucket.FeedEvent{CollectionID: 12}) // not part of the database
+	assert.Equal(t, []uint32{10, 9}, received)
+
+	primaryScopes := dbCtx.bucketScopes("")
+	require.Contains(t, primaryScopes, "scope1")
+	assert.Len(t, primaryScopes["scope1"].Collections, 1)
+	legacyScopes := dbCtx.bucketScopes("legacy")
+	assert.Len(t, legacyScopes["scope1"].Collections, 2)
+
+	assert.Equal(t, []uint32{8}, dbCtx.filterFederatedCollectionIDs(ctx, []uint32{8, 9, 10}))
+}
@@ -0,0 +1,85 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// PauseDb takes an online database out of service without closing its replications, as an alternative to taking it
+// offline.  While paused, replications stay connected but no changes are sent to them, and pushed revisions are
+// rejected with ErrDatabasePaused.  Replications continue from where they left off once the database is resumed.
+func (dc *DatabaseContext) PauseDb(ctx context.Context, reason string) error {
+	dc.pauseLock.Lock()
+	defer dc.pauseLock.Unlock()
+
+	if !atomic.CompareAndSwapUint32(&dc.State, DBOnline, DBPaused) {
+		dbState := atomic.LoadUint32(&dc.State)
+		if dbState == DBPaused {
+			return nil
+		}
+		msg := "Unable to pause Database, database must be in Online state but was " + RunStateString[dbState]
+		base.InfofCtx(ctx, base.KeyCRUD, msg)
+		return base.HTTPErrorf(http.StatusServiceUnavailable, msg)
+	}
+	dc.resumed = make(chan struct{})
+
+	base.InfofCtx(ctx, base.KeyCRUD, "Database %s paused", base.MD(dc.Name))
+	if err := dc.EventMgr.RaiseDBStateChangeEvent(ctx, dc.Name, "paused", reason, dc.Options.AdminInterface); err != nil {
+		base.InfofCtx(ctx, base.KeyCRUD, "Error raising database state change event: %v", err)
+	}
+	return nil
+}
+
+// ResumeDb brings a paused database back online, resuming its replications.  Returns false if the database wasn't
+// paused.
+func (dc *DatabaseContext) ResumeDb(ctx context.Context, reason string) bool {
+	dc.pauseLock.Lock()
+	defer dc.pauseLock.Unlock()
+
+	if !atomic.CompareAndSwapUint32(&dc.State, DBPaused, DBOnline) {
+		return false
+	}
+	close(dc.resumed)
+	dc.resumed = nil
+
+	base.InfofCtx(ctx, base.KeyCRUD, "Database %s resumed", base.MD(dc.Name))
+	if err := dc.EventMgr.RaiseDBStateChangeEvent(ctx, dc.Name, "online", reason, dc.Options.AdminInterface); err != nil {
+		base.InfofCtx(ctx, base.KeyCRUD, "Error raising database state change event: %v", err)
+	}
+	return true
+}
+
+// IsPaused returns true if the database has been paused.
+func (dc *DatabaseContext) IsPaused() bool {
+	return atomic.LoadUint32(&dc.State) == DBPaused
+}
+
+// waitWhilePaused blocks until the database is resumed, if paused.  Returns false if the terminator is closed, or
+// the database is taken offline, before the database is resumed.
+func (dc *DatabaseContext) waitWhilePaused(terminator chan bool) bool {
+	dc.pauseLock.Lock()
+	resumed := dc.resumed
+	dc.pauseLock.Unlock()
+	if resumed == nil {
+		return true
+	}
+	select {
+	case <-resumed:
+		return true
+	case <-terminator:
+		return false
+	case <-dc.ExitChanges:
+		return false
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPauseDb(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	terminator := make(chan bool)
+	defer close(terminator)
+	atomic.StoreUint32(&db.State, DBOnline)
+
+	// Nothing waits while the database is online
+	assert.True(t, db.waitWhilePaused(terminator))
+	assert.False(t, db.ResumeDb(ctx, ""))
+
+	require.NoError(t, db.PauseDb(ctx, ""))
+	assert.Equal(t, DBPaused, atomic.LoadUint32(&db.State))
+	require.NoError(t, db.PauseDb(ctx, ""))
+
+	resumed := make(chan bool)
+	go func() {
+		resumed <- db.waitWhilePaused(terminator)
+	}()
+	select {
+	case <-resumed:
+		require.Fail(t, "waitWhilePaused returned while paused")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.True(t, db.ResumeDb(ctx, ""))
+	assert.True(t, <-resumed)
+	assert.Equal(t, DBOnline, atomic.LoadUint32(&db.State))
+
+	// Taking a paused database offline releases anything waiting for it to resume
+	require.NoError(t, db.PauseDb(ctx, ""))
+	go func() {
+		resumed <- db.waitWhilePaused(terminator)
+	}()
+	require.NoError(t, db.DatabaseContext.TakeDbOffline(ctx, ""))
+	assert.False(t, <-resumed)
+	assert.False(t, db.ResumeDb(ctx, ""))
+	assertHTTPError(t, db.PauseDb(ctx, ""), http.StatusServiceUnavailable)
+}
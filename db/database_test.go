@@ -317,7 +317,7 @@ func TestGetDeleted(t *testing.T) {
 	rev1id, _, err := collection.Put(ctx, "doc1", body)
 	assert.NoError(t, err, "Put")
 
-	rev2id, err := collection.DeleteDoc(ctx, "doc1", rev1id)
+	rev2id, _, err := collection.DeleteDoc(ctx, "doc1", rev1id)
 	assert.NoError(t, err, "DeleteDoc")
 
 	// Get the deleted doc with its history; equivalent to GET with ?revs=true
@@ -898,7 +898,7 @@ func TestAllDocsOnly(t *testing.T) {
 	}
 
 	// Now delete one document and try again:
-	_, err = collection.DeleteDoc(ctx, ids[23].DocID, ids[23].RevID)
+	_, _, err = collection.DeleteDoc(ctx, ids[23].DocID, ids[23].RevID)
 	assert.NoError(t, err, "Couldn't delete doc 23")
 
 	alldocs, err = allDocIDs(ctx, collection.DatabaseCollection)
@@ -1138,7 +1138,7 @@ func TestConflicts(t *testing.T) {
 	)
 
 	// Delete 2-b; verify this makes 2-a current:
-	rev3, err := collection.DeleteDoc(ctx, "doc", "2-b")
+	rev3, _, err := collection.DeleteDoc(ctx, "doc", "2-b")
 	assert.NoError(t, err, "delete 2-b")
 
 	rawBody, _, _ = collection.dataStore.GetRaw("doc")
@@ -1280,6 +1280,35 @@ func TestNoConflictsMode(t *testing.T) {
 	assertHTTPError(t, err, 409)
 }
 
+// TestCollectionAllowConflictsOverride ensures a collection's allow_conflicts and revs_limit override the database's.
+func TestCollectionAllowConflictsOverride(t *testing.T) {
+	db, ctx := SetupTestDBWithOptions(t, DatabaseContextOptions{AllowConflicts: base.BoolPtr(true)})
+	defer db.Close(ctx)
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+	assert.True(t, collection.AllowConflicts())
+	assert.Equal(t, uint32(DefaultRevsLimitConflicts), collection.revsLimit())
+
+	// Strictly speaking, these should be set before opening the database, but they're only read on writes
+	collection.allowConflicts = base.BoolPtr(false)
+	collection.revsLimitOverride = base.Uint32Ptr(DefaultRevsLimitNoConflicts)
+	assert.False(t, collection.AllowConflicts())
+	assert.Equal(t, uint32(DefaultRevsLimitNoConflicts), collection.revsLimit())
+	assert.True(t, db.AllowConflicts())
+
+	body := Body{"n": 1}
+	_, _, err := collection.PutExistingRevWithBody(ctx, "doc", body, []string{"1-a"}, false)
+	require.NoError(t, err)
+	_, _, err = collection.PutExistingRevWithBody(ctx, "doc", body, []string{"2-a", "1-a"}, false)
+	require.NoError(t, err)
+	_, _, err = collection.PutExistingRevWithBody(ctx, "doc", body, []string{"2-b", "1-a"}, false)
+	assertHTTPError(t, err, 409)
+
+	// Conflicts are allowed again once the override is removed
+	collection.allowConflicts = nil
+	_, _, err = collection.PutExistingRevWithBody(ctx, "doc", body, []string{"2-b", "1-a"}, false)
+	require.NoError(t, err)
+}
+
 // Test tombstoning of existing conflicts after AllowConflicts is set to false via Put
 func TestAllowConflictsFalseTombstoneExistingConflict(t *testing.T) {
 	db, ctx := setupTestDB(t)
@@ -2517,7 +2546,7 @@ func TestTombstoneCompactionStopWithManager(t *testing.T) {
 		docID := fmt.Sprintf("doc%d", i)
 		rev, _, err := collection.Put(ctx, docID, Body{})
 		assert.NoError(t, err)
-		_, err = collection.DeleteDoc(ctx, docID, rev)
+		_, _, err = collection.DeleteDoc(ctx, docID, rev)
 		assert.NoError(t, err)
 	}
 
@@ -2950,7 +2979,7 @@ func TestImportCompactPanic(t *testing.T) {
 	// Create a document, then delete it, to create a tombstone
 	rev, doc, err := collection.Put(ctx, "test", Body{})
 	require.NoError(t, err)
-	_, err = collection.DeleteDoc(ctx, doc.ID, rev)
+	_, _, err = collection.DeleteDoc(ctx, doc.ID, rev)
 	require.NoError(t, err)
 	require.NoError(t, collection.WaitForPendingChanges(ctx))
 
@@ -3165,7 +3194,7 @@ func Test_stopBackgroundManagers(t *testing.T) {
 				assert.NoError(t, err)
 			}
 
-			bgManagers := db.stopBackgroundManagers()
+			bgManagers := db.StopBackgroundManagers()
 			assert.Len(t, bgManagers, testCase.expected, "Unexpected Num of BackgroundManagers returned")
 		})
 	}
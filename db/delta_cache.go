@@ -0,0 +1,234 @@
+/*
+Copyright 2023-Present Couchbase, Inc.
+
+Use of this software is governed by the Business Source License included in
+the file licenses/BSL-Couchbase.txt.  As of the Change Date specified in that
+file, in accordance with the Business Source License, use of this software will
+be governed by the Apache License, Version 2.0, included in the file
+licenses/APL2.txt.
+*/
+
+package db
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// DefaultDeltaCacheExpiry is the default time a cached delta is retained for after it was generated.
+const DefaultDeltaCacheExpiry = 5 * time.Minute
+
+// deltaCacheLowWatermark is the fraction of maxBytes that eviction reduces the cache to, so that eviction isn't
+// repeated on every subsequent insert.
+const deltaCacheLowWatermark = 0.9
+
+// deltaCacheOptions configures a deltaCache.
+type deltaCacheOptions struct {
+	expiry        time.Duration    // How long deltas are retained after being generated. Zero for no expiry
+	maxBytes      int64            // Maximum estimated size of the cached deltas. Zero for no limit
+	bytesStat     *base.SgwIntStat // Estimated size of the cached deltas, shared between collections
+	evictionsStat *base.SgwIntStat // Number of deltas evicted to stay within maxBytes
+}
+
+// deltaCache stores the most recently generated delta from each delta source revision (deltaSrc), independently of
+// whether the source revision is still in the revision cache.
+//
+// Deltas are garbage collected:
+//   - when they expire,
+//   - when the feed delivers a newer revision of the document, as a delta to a revision that's no longer current won't
+//     be requested again,
+//   - when the cache is over its memory limit. The least requested deltaSrc entries are evicted first, so that
+//     deltas shared by many clients replicating the same document are retained.
+type deltaCache struct {
+	lock         sync.Mutex
+	entries      map[string]map[string]*deltaCacheEntry // Cached deltas, by doc ID then source rev ID
+	options      deltaCacheOptions
+	currentBytes int64     // Estimated size of all cached deltas
+	lastSweep    time.Time // When expired entries were last removed
+	lastSequence uint64    // Sequence assigned to the most recently cached delta
+}
+
+type deltaCacheEntry struct {
+	docID     string
+	fromRevID string
+	delta     *RevisionDelta
+	bytes     int64
+	expires   time.Time // Zero when the cache has no expiry
+	hits      int64     // Number of times a delta has been requested from this source revision
+	sequence  uint64    // Order in which the delta was cached, used to evict older deltas first
+}
+
+func newDeltaCache(options deltaCacheOptions) *deltaCache {
+	return &deltaCache{
+		entries:   make(map[string]map[string]*deltaCacheEntry),
+		options:   options,
+		lastSweep: time.Now(),
+	}
+}
+
+// get returns the cached delta from the given source revision, or nil if there isn't one.
+func (dc *deltaCache) get(docID, fromRevID string) *RevisionDelta {
+	dc.lock.Lock()
+	defer dc.lock.Unlock()
+	entry := dc.entries[docID][fromRevID]
+	if entry == nil {
+		return nil
+	}
+	if entry.expired(time.Now()) {
+		dc.remove_(entry)
+		return nil
+	}
+	entry.hits++
+	return entry.delta
+}
+
+// put caches a delta from the given source revision, replacing any previous delta from that revision.
+func (dc *deltaCache) put(docID, fromRevID string, delta RevisionDelta) {
+	now := time.Now()
+	entry := &deltaCacheEntry{
+		docID:     docID,
+		fromRevID: fromRevID,
+		delta:     &delta,
+		bytes:     estimateDeltaBytes(docID, fromRevID, &delta),
+	}
+	if dc.options.expiry > 0 {
+		entry.expires = now.Add(dc.options.expiry)
+	}
+
+	dc.lock.Lock()
+	defer dc.lock.Unlock()
+	dc.lastSequence++
+	entry.sequence = dc.lastSequence
+	docEntries := dc.entries[docID]
+	if docEntries == nil {
+		docEntries = make(map[string]*deltaCacheEntry, 1)
+		dc.entries[docID] = docEntries
+	}
+	if previous := docEntries[fromRevID]; previous != nil {
+		// Requests are counted against the source revision, so carry them over to the replacement delta
+		entry.hits = previous.hits
+		dc.adjustBytes_(-previous.bytes)
+	}
+	docEntries[fromRevID] = entry
+	dc.adjustBytes_(entry.bytes)
+
+	if dc.options.expiry > 0 && now.Sub(dc.lastSweep) >= dc.options.expiry {
+		dc.removeExpired_(now)
+	}
+	if dc.options.maxBytes > 0 && dc.currentBytes > dc.options.maxBytes {
+		dc.evict_(now, entry)
+	}
+}
+
+// removeStale removes the document's deltas to revisions other than its current revision. Called as the feed delivers
+// new revisions of the document.
+func (dc *deltaCache) removeStale(docID, currentRevID string) {
+	dc.lock.Lock()
+	defer dc.lock.Unlock()
+	for _, entry := range dc.entries[docID] {
+		if entry.delta.ToRevID != currentRevID {
+			dc.remove_(entry)
+		}
+	}
+}
+
+// removeRev removes any deltas from or to the given revision.
+func (dc *deltaCache) removeRev(docID, revID string) {
+	dc.lock.Lock()
+	defer dc.lock.Unlock()
+	for _, entry := range dc.entries[docID] {
+		if entry.fromRevID == revID || entry.delta.ToRevID == revID {
+			dc.remove_(entry)
+		}
+	}
+}
+
+func (dc *deltaCache) remove_(entry *deltaCacheEntry) {
+	docEntries := dc.entries[entry.docID]
+	if docEntries[entry.fromRevID] != entry {
+		return
+	}
+	delete(docEntries, entry.fromRevID)
+	if len(docEntries) == 0 {
+		delete(dc.entries, entry.docID)
+	}
+	dc.adjustBytes_(-entry.bytes)
+}
+
+// removeExpired_ removes all expired deltas. Requires the cache lock.
+func (dc *deltaCache) removeExpired_(now time.Time) {
+	for _, docEntries := range dc.entries {
+		for _, entry := range docEntries {
+			if entry.expired(now) {
+				dc.remove_(entry)
+			}
+		}
+	}
+	dc.lastSweep = now
+}
+
+// evict_ reduces the cache to its low watermark, removing expired deltas and then the deltas whose source revisions
+// have been requested least, oldest first. The delta that's just been added isn't evicted, as it hasn't had the
+// chance to be requested. Requires the cache lock.
+func (dc *deltaCache) evict_(now time.Time, added *deltaCacheEntry) {
+	dc.removeExpired_(now)
+	target := int64(float64(dc.options.maxBytes) * deltaCacheLowWatermark)
+	if dc.currentBytes <= target {
+		return
+	}
+
+	candidates := make([]*deltaCacheEntry, 0, len(dc.entries))
+	for _, docEntries := range dc.entries {
+		for _, entry := range docEntries {
+			if entry != added {
+				candidates = append(candidates, entry)
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].hits != candidates[j].hits {
+			return candidates[i].hits < candidates[j].hits
+		}
+		return candidates[i].sequence < candidates[j].sequence
+	})
+	for _, entry := range candidates {
+		if dc.currentBytes <= target {
+			break
+		}
+		dc.remove_(entry)
+		if dc.options.evictionsStat != nil {
+			dc.options.evictionsStat.Add(1)
+		}
+	}
+}
+
+// adjustBytes_ adjusts the tracked size of the cache. Requires the cache lock.
+func (dc *deltaCache) adjustBytes_(delta int64) {
+	dc.currentBytes += delta
+	if dc.options.bytesStat != nil {
+		dc.options.bytesStat.Add(delta)
+	}
+}
+
+func (entry *deltaCacheEntry) expired(now time.Time) bool {
+	return !entry.expires.IsZero() && now.After(entry.expires)
+}
+
+// estimateDeltaBytes returns the estimated size of a cached delta, based on the size of the delta itself, its revision
+// history and channels.
+func estimateDeltaBytes(docID, fromRevID string, delta *RevisionDelta) int64 {
+	bytes := int64(len(docID) + len(fromRevID) + len(delta.ToRevID) + len(delta.DeltaBytes))
+	for _, revID := range delta.RevisionHistory {
+		bytes += int64(len(revID))
+	}
+	for channel := range delta.ToChannels {
+		bytes += int64(len(channel))
+	}
+	for _, meta := range delta.AttachmentStorageMeta {
+		bytes += int64(len(meta.digest))
+	}
+	return bytes
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2023-Present Couchbase, Inc.
+
+Use of this software is governed by the Business Source License included in
+the file licenses/BSL-Couchbase.txt.  As of the Change Date specified in that
+file, in accordance with the Business Source License, use of this software will
+be governed by the Apache License, Version 2.0, included in the file
+licenses/APL2.txt.
+*/
+
+package db
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeltaCacheExpiry(t *testing.T) {
+	bytesStat := base.SgwIntStat{}
+	cache := newDeltaCache(deltaCacheOptions{expiry: 50 * time.Millisecond, bytesStat: &bytesStat})
+
+	cache.put("doc1", "1-a", RevisionDelta{ToRevID: "2-a", DeltaBytes: []byte(`{"v":1}`)})
+	delta := cache.get("doc1", "1-a")
+	require.NotNil(t, delta)
+	assert.Equal(t, "2-a", delta.ToRevID)
+	assert.Equal(t, int64(4+3+3+7), bytesStat.Value())
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Nil(t, cache.get("doc1", "1-a"))
+	assert.Equal(t, int64(0), bytesStat.Value())
+
+	// Expired deltas that aren't requested again are removed when later deltas are added
+	cache.put("doc1", "1-a", RevisionDelta{ToRevID: "2-a", DeltaBytes: []byte(`{"v":1}`)})
+	time.Sleep(100 * time.Millisecond)
+	cache.put("doc2", "1-a", RevisionDelta{ToRevID: "2-a", DeltaBytes: []byte(`{"v":1}`)})
+	assert.Equal(t, int64(4+3+3+7), bytesStat.Value())
+	assert.NotContains(t, cache.entries, "doc1")
+}
+
+func TestDeltaCacheRemoveStale(t *testing.T) {
+	bytesStat := base.SgwIntStat{}
+	cache := newDeltaCache(deltaCacheOptions{bytesStat: &bytesStat})
+
+	cache.put("doc1", "1-a", RevisionDelta{ToRevID: "3-a", DeltaBytes: []byte(`{"v":1}`)})
+	cache.put("doc1", "2-a", RevisionDelta{ToRevID: "3-a", DeltaBytes: []byte(`{"v":1}`)})
+	cache.put("doc1", "2-b", RevisionDelta{ToRevID: "2-a", DeltaBytes: []byte(`{"v":1}`)})
+	cache.put("doc2", "1-a", RevisionDelta{ToRevID: "2-a", DeltaBytes: []byte(`{"v":1}`)})
+
+	// A new revision of doc1 arriving on the feed removes the deltas to its earlier revisions
+	cache.removeStale("doc1", "4-a")
+	assert.Nil(t, cache.get("doc1", "1-a"))
+	assert.Nil(t, cache.get("doc1", "2-a"))
+	assert.Nil(t, cache.get("doc1", "2-b"))
+	assert.NotNil(t, cache.get("doc2", "1-a"))
+
+	// Deltas to the current revision are retained
+	cache.removeStale("doc2", "2-a")
+	assert.NotNil(t, cache.get("doc2", "1-a"))
+	assert.Equal(t, int64(4+3+3+7), bytesStat.Value())
+}
+
+// TestDeltaCacheEviction validates that the least requested deltas are evicted when the cache is over its memory limit.
+func TestDeltaCacheEviction(t *testing.T) {
+	bytesStat, evictionsStat := base.SgwIntStat{}, base.SgwIntStat{}
+	// Each delta is 1 byte doc ID + 3 byte rev IDs + 100 byte delta
+	const deltaBytes = 107
+	cache := newDeltaCache(deltaCacheOptions{maxBytes: 10 * deltaBytes, bytesStat: &bytesStat, evictionsStat: &evictionsStat})
+	delta := RevisionDelta{ToRevID: "2-a", DeltaBytes: []byte(strings.Repeat("a", 100))}
+
+	for i := 0; i < 10; i++ {
+		cache.put(strconv.Itoa(i), "1-a", delta)
+	}
+	assert.Equal(t, int64(10*deltaBytes), bytesStat.Value())
+
+	// Request the deltas from the later docs more often than those from the earlier docs
+	for i := 0; i < 10; i++ {
+		for j := 0; j < i; j++ {
+			require.NotNil(t, cache.get(strconv.Itoa(i), "1-a"))
+		}
+	}
+
+	// Replacing a delta retains the requests for its source revision
+	cache.put("9", "1-a", delta)
+
+	// Going over the limit evicts down to the low watermark, least requested first. The delta just added is retained,
+	// even though it hasn't been requested yet.
+	cache.put("a", "1-a", delta)
+	assert.Equal(t, int64(2), evictionsStat.Value())
+	assert.Equal(t, int64(9*deltaBytes), bytesStat.Value())
+	assert.Nil(t, cache.get("0", "1-a"))
+	assert.Nil(t, cache.get("1", "1-a"))
+	for i := 2; i < 10; i++ {
+		assert.NotNil(t, cache.get(strconv.Itoa(i), "1-a"))
+	}
+	assert.NotNil(t, cache.get("a", "1-a"))
+}
+
+// TestRevisionCacheDeltaRetention validates that deltas are retained independently of the revisions they're from.
+func TestRevisionCacheDeltaRetention(t *testing.T) {
+	cacheHitCounter, cacheMissCounter, getDocumentCounter, getRevisionCounter := base.SgwIntStat{}, base.SgwIntStat{}, base.SgwIntStat{}, base.SgwIntStat{}
+	cache := NewLRURevisionCache(2, &testBackingStore{nil, &getDocumentCounter, &getRevisionCounter}, &cacheHitCounter, &cacheMissCounter)
+
+	ctx := base.TestCtx(t)
+	_, err := cache.Get(ctx, "doc1", "1-abc", RevCacheOmitBody, RevCacheIncludeDelta)
+	require.NoError(t, err)
+	cache.UpdateDelta(ctx, "doc1", "1-abc", RevisionDelta{ToRevID: "2-abc", DeltaBytes: []byte("delta")})
+
+	// Evict doc1 from the revision cache
+	for _, docID := range []string{"doc2", "doc3"} {
+		_, err := cache.Get(ctx, docID, "1-abc", RevCacheOmitBody, RevCacheOmitDelta)
+		require.NoError(t, err)
+	}
+	_, found := cache.Peek(ctx, "doc1", "1-abc")
+	require.False(t, found)
+
+	docRev, err := cache.Get(ctx, "doc1", "1-abc", RevCacheOmitBody, RevCacheIncludeDelta)
+	require.NoError(t, err)
+	require.NotNil(t, docRev.Delta)
+	assert.Equal(t, "2-abc", docRev.Delta.ToRevID)
+
+	// A new revision of the doc removes the delta
+	cache.RefreshCurrentRev("doc1", "3-abc")
+	docRev, err = cache.Get(ctx, "doc1", "1-abc", RevCacheOmitBody, RevCacheIncludeDelta)
+	require.NoError(t, err)
+	assert.Nil(t, docRev.Delta)
+}
@@ -32,6 +32,20 @@ const MaximumInlineBodySize = 250
 // DocumentHistoryMaxEntriesPerChannel is the maximum allowed entries per channel in the Document ChannelSetHistory
 const DocumentHistoryMaxEntriesPerChannel = 5
 
+// ChannelHistoryOptions is the retention policy for the Document ChannelSetHistory
+type ChannelHistoryOptions struct {
+	MaxEntriesPerChannel int           // Max entries retained per channel, older entries are merged. Defaults to DocumentHistoryMaxEntriesPerChannel
+	MaxAge               time.Duration // History entries that ended longer ago than this are removed. Zero retains entries indefinitely
+}
+
+// maxEntriesPerChannel returns the maximum number of history entries retained per channel.
+func (o ChannelHistoryOptions) maxEntriesPerChannel() int {
+	if o.MaxEntriesPerChannel <= 0 {
+		return DocumentHistoryMaxEntriesPerChannel
+	}
+	return o.MaxEntriesPerChannel
+}
+
 type DocumentUnmarshalLevel uint8
 
 const (
@@ -57,9 +71,10 @@ type UserAccessMap map[string]channels.TimedSet
 type AttachmentsMeta map[string]interface{} // AttachmentsMeta metadata as included in sync metadata
 
 type ChannelSetEntry struct {
-	Name  string `json:"name"`
-	Start uint64 `json:"start"`
-	End   uint64 `json:"end,omitempty"`
+	Name    string `json:"name"`
+	Start   uint64 `json:"start"`
+	End     uint64 `json:"end,omitempty"`
+	EndTime int64  `json:"end_time,omitempty"` // Unix time the document left the channel, used to expire history entries
 }
 
 // The sync-gateway metadata stored in the "_sync" property of a Couchbase document.
@@ -82,6 +97,9 @@ type SyncData struct {
 	ChannelSet        []ChannelSetEntry   `json:"channel_set"`
 	ChannelSetHistory []ChannelSetEntry   `json:"channel_set_history"`
 
+	// Cold metadata relocated to side documents by sync metadata compaction, if any
+	ExternalMetadata *ExternalSyncMetadata `json:"external_metadata,omitempty"`
+
 	// Only used for performance metrics:
 	TimeSaved time.Time `json:"time_saved,omitempty"` // Timestamp of save.
 
@@ -869,6 +887,7 @@ func (doc *Document) updateChannelHistory(channelName string, seq uint64, additi
 				doc.ChannelSet[idx] = ChannelSetEntry{Name: channelName, Start: seq}
 			} else {
 				doc.ChannelSet[idx].End = seq
+				doc.ChannelSet[idx].EndTime = time.Now().Unix()
 			}
 			return
 		}
@@ -885,33 +904,67 @@ func (doc *Document) updateChannelHistory(channelName string, seq uint64, additi
 		})
 	} else {
 		doc.ChannelSet = append(doc.ChannelSet, ChannelSetEntry{
-			Name:  channelName,
-			Start: 1,
-			End:   seq,
+			Name:    channelName,
+			Start:   1,
+			End:     seq,
+			EndTime: time.Now().Unix(),
 		})
 	}
 
 }
 
 func (doc *Document) addToChannelSetHistory(channelName string, historyEntry ChannelSetEntry) {
-	// Before adding the entry we need to verify the number of items in the document channel history to check
-	// whether we need to prune them.
-	// As we iterate over the existing channels we will keep track of the oldest and second oldest entries along with
-	// their location in the slice. If we need to then prune we can 'merge' the oldest and second oldest and remove
-	// the oldest.
+	// Entries beyond the retention policy are pruned by pruneChannelSetHistory once the update is complete
+	doc.ChannelSetHistory = append(doc.ChannelSetHistory, historyEntry)
+}
 
+// pruneChannelSetHistory applies the retention policy to the document's channel history. Entries that ended longer ago
+// than the max age are removed, then the oldest entries for each channel are merged until the channel has no more than
+// the max entries. Returns the number of entries pruned.
+func (doc *Document) pruneChannelSetHistory(options ChannelHistoryOptions, now time.Time) (pruned int) {
+	if len(doc.ChannelSetHistory) == 0 {
+		return 0
+	}
+
+	if options.MaxAge > 0 {
+		cutoff := now.Add(-options.MaxAge).Unix()
+		retained := make([]ChannelSetEntry, 0, len(doc.ChannelSetHistory))
+		for _, entry := range doc.ChannelSetHistory {
+			// Entries written before end times were recorded are only subject to the max entries
+			if entry.EndTime != 0 && entry.EndTime < cutoff {
+				pruned++
+				continue
+			}
+			retained = append(retained, entry)
+		}
+		doc.ChannelSetHistory = retained
+	}
+
+	entryCounts := make(map[string]int)
+	for _, entry := range doc.ChannelSetHistory {
+		entryCounts[entry.Name]++
+	}
+	maxEntries := options.maxEntriesPerChannel()
+	for channelName, entryCount := range entryCounts {
+		for ; entryCount > maxEntries; entryCount-- {
+			doc.mergeOldestChannelSetHistory(channelName)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// mergeOldestChannelSetHistory 'merges' the oldest and second oldest history entries for the channel, by extending the
+// second oldest entry to start at the oldest entry's start and removing the oldest.
+func (doc *Document) mergeOldestChannelSetHistory(channelName string) {
 	var oldestEntryStartSeq uint64 = math.MaxUint64
 	var secondOldestEntryStartSeq uint64 = math.MaxUint64
 
 	oldestEntryIdx := -1
 	secondOldestEntryIdx := -1
 
-	entryCount := 0
-
 	for entryIdx, entry := range doc.ChannelSetHistory {
 		if entry.Name == channelName {
-			entryCount++
-
 			if entry.Start < oldestEntryStartSeq {
 				secondOldestEntryStartSeq = oldestEntryStartSeq
 				oldestEntryStartSeq = entry.Start
@@ -928,12 +981,11 @@ func (doc *Document) addToChannelSetHistory(channelName string, historyEntry Cha
 		}
 	}
 
-	if entryCount >= DocumentHistoryMaxEntriesPerChannel {
-		doc.ChannelSetHistory[secondOldestEntryIdx].Start = oldestEntryStartSeq
-		doc.ChannelSetHistory = append(doc.ChannelSetHistory[:oldestEntryIdx], doc.ChannelSetHistory[oldestEntryIdx+1:]...)
+	if secondOldestEntryIdx == -1 {
+		return
 	}
-
-	doc.ChannelSetHistory = append(doc.ChannelSetHistory, historyEntry)
+	doc.ChannelSetHistory[secondOldestEntryIdx].Start = oldestEntryStartSeq
+	doc.ChannelSetHistory = append(doc.ChannelSetHistory[:oldestEntryIdx], doc.ChannelSetHistory[oldestEntryIdx+1:]...)
 }
 
 // Updates the Channels property of a document object with current & past channels.
@@ -1022,12 +1074,17 @@ func (doc *Document) IsChannelRemoval(ctx context.Context, revID string) (bodyBy
 	return bodyBytes, history, activeChannels, true, isDelete, nil
 }
 
-// Updates a document's channel/role UserAccessMap with new access settings from an AccessMap.
+// Updates a document's channel/role UserAccessMap with new access settings from an AccessMap, and the expiry of any
+// time-boxed grants from newExpiry.
 // Returns an array of the user/role names whose access has changed as a result.
-func (accessMap *UserAccessMap) updateAccess(ctx context.Context, doc *Document, newAccess channels.AccessMap) (changedUsers []string) {
+func (accessMap *UserAccessMap) updateAccess(ctx context.Context, doc *Document, newAccess channels.AccessMap, newExpiry channels.AccessExpiryMap) (changedUsers []string) {
 	// Update users already appearing in doc.Access:
 	for name, access := range *accessMap {
-		if access.UpdateAtSequence(newAccess[name], doc.Sequence) {
+		changed := access.UpdateAtSequence(newAccess[name], doc.Sequence)
+		if access.SetExpiry(newExpiry[name]) {
+			changed = true
+		}
+		if changed {
 			if len(access) == 0 {
 				delete(*accessMap, name)
 			}
@@ -1040,7 +1097,9 @@ func (accessMap *UserAccessMap) updateAccess(ctx context.Context, doc *Document,
 			if *accessMap == nil {
 				*accessMap = UserAccessMap{}
 			}
-			(*accessMap)[name] = channels.AtSequence(access, doc.Sequence)
+			timedAccess := channels.AtSequence(access, doc.Sequence)
+			timedAccess.SetExpiry(newExpiry[name])
+			(*accessMap)[name] = timedAccess
 			changedUsers = append(changedUsers, name)
 		}
 	}
@@ -0,0 +1,139 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"github.com/couchbase/sync_gateway/auth"
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// DocumentAccess describes a document's channels and the access grants it makes, for troubleshooting why a user can
+// or can't see it.
+type DocumentAccess struct {
+	DocID          string                       `json:"id"`
+	RevID          string                       `json:"rev"`
+	Sequence       uint64                       `json:"seq"`
+	Deleted        bool                         `json:"deleted,omitempty"`
+	Channels       []string                     `json:"channels"`                  // Channels of the current revision
+	ChannelHistory map[string][]ChannelSetEntry `json:"channel_history,omitempty"` // When the document was added to and removed from each channel, oldest first
+	Access         map[string][]string          `json:"access,omitempty"`          // Channels granted by the document, keyed by user or role:name
+	RoleAccess     map[string][]string          `json:"role_access,omitempty"`     // Roles granted by the document, keyed by user
+	User           *DocumentUserAccess          `json:"user,omitempty"`
+}
+
+// DocumentUserAccess describes a user's access to a document.
+type DocumentUserAccess struct {
+	Name             string   `json:"name"`
+	CanSee           bool     `json:"can_see"`
+	MatchingChannels []string `json:"matching_channels"` // Channels of the current revision the user has access to
+}
+
+// GetDocumentAccess returns the document's channels, channel history and access grants.  If user is non-nil, the
+// channels of the document the user has access to are included.
+func (c *DatabaseCollection) GetDocumentAccess(ctx context.Context, docID string, user auth.User) (*DocumentAccess, error) {
+	doc, err := c.GetDocument(ctx, docID, DocUnmarshalSync)
+	if err != nil {
+		return nil, err
+	}
+	if !doc.HasValidSyncData() {
+		return nil, base.HTTPErrorf(http.StatusNotFound, "Document is not known to Sync Gateway")
+	}
+	if err := c.loadExternalSyncMetadata(ctx, docID, &doc.SyncData); err != nil {
+		return nil, err
+	}
+
+	currentChannels := doc.currentChannels()
+	access := &DocumentAccess{
+		DocID:          docID,
+		RevID:          doc.CurrentRev,
+		Sequence:       doc.Sequence,
+		Deleted:        doc.IsDeleted(),
+		Channels:       currentChannels.ToArray(),
+		ChannelHistory: doc.channelHistory(),
+		Access:         doc.Access.sortedNames(),
+		RoleAccess:     doc.RoleAccess.sortedNames(),
+	}
+	sort.Strings(access.Channels)
+
+	if user != nil {
+		userAccess := &DocumentUserAccess{
+			Name:             user.Name(),
+			CanSee:           user.AuthorizeAnyCollectionChannel(c.ScopeName, c.Name, currentChannels) == nil,
+			MatchingChannels: []string{},
+		}
+		if userAccess.Name == "" {
+			userAccess.Name = base.GuestUsername
+		}
+		for _, channel := range access.Channels {
+			if user.CanSeeCollectionChannel(c.ScopeName, c.Name, channel) {
+				userAccess.MatchingChannels = append(userAccess.MatchingChannels, channel)
+			}
+		}
+		access.User = userAccess
+	}
+	return access, nil
+}
+
+// filterForUser removes anything the user couldn't otherwise find out: the history of channels they don't have
+// access to, and grants to other users and roles.
+func (access *DocumentAccess) filterForUser(user auth.User, scope, collection string) {
+	for channel := range access.ChannelHistory {
+		if !user.CanSeeCollectionChannel(scope, collection, channel) {
+			delete(access.ChannelHistory, channel)
+		}
+	}
+	for name := range access.Access {
+		if name != user.Name() {
+			delete(access.Access, name)
+		}
+	}
+	for name := range access.RoleAccess {
+		if name != user.Name() {
+			delete(access.RoleAccess, name)
+		}
+	}
+}
+
+// channelHistory returns the periods the document was in each channel, from both the current and historical channel
+// sets, oldest first.
+func (doc *Document) channelHistory() map[string][]ChannelSetEntry {
+	if len(doc.ChannelSet) == 0 && len(doc.ChannelSetHistory) == 0 {
+		return nil
+	}
+	history := make(map[string][]ChannelSetEntry)
+	for _, entries := range [][]ChannelSetEntry{doc.ChannelSetHistory, doc.ChannelSet} {
+		for _, entry := range entries {
+			history[entry.Name] = append(history[entry.Name], entry)
+		}
+	}
+	for _, entries := range history {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Start < entries[j].Start
+		})
+	}
+	return history
+}
+
+// sortedNames returns the names granted to each principal in sorted order, or nil if there are no grants.
+func (accessMap UserAccessMap) sortedNames() map[string][]string {
+	if len(accessMap) == 0 {
+		return nil
+	}
+	names := make(map[string][]string, len(accessMap))
+	for principal, access := range accessMap {
+		granted := access.AllKeys()
+		sort.Strings(granted)
+		names[principal] = granted
+	}
+	return names
+}
@@ -0,0 +1,81 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/couchbase/sync_gateway/auth"
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDocumentAccess(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+
+	collection.ChannelMapper = channels.NewChannelMapper(ctx, `function(doc) { channel(doc.channels); access(doc.owner, "C"); access("role:editor", "D"); role(doc.owner, "role:editor"); }`, db.Options.JavascriptTimeout)
+	revID, _, err := collection.Put(ctx, "doc1", Body{"channels": []string{"A", "B"}, "owner": "alice"})
+	require.NoError(t, err)
+	revID, doc, err := collection.Put(ctx, "doc1", Body{BodyRev: revID, "channels": []string{"A"}, "owner": "alice"})
+	require.NoError(t, err)
+
+	newUser := func(name string, channelNames ...string) auth.User {
+		authenticator := db.Authenticator(ctx)
+		user, err := authenticator.NewUser(name, "letmein", nil)
+		require.NoError(t, err)
+		user.SetCollectionExplicitChannels(collection.ScopeName, collection.Name, channels.AtSequence(base.SetFromArray(channelNames), 1), 1)
+		require.NoError(t, authenticator.Save(user))
+		user, err = authenticator.GetUser(name)
+		require.NoError(t, err)
+		return user
+	}
+	alice := newUser("alice", "A")
+	bob := newUser("bob", "Z")
+
+	access, err := collection.GetDocumentAccess(ctx, "doc1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, revID, access.RevID)
+	assert.Equal(t, doc.Sequence, access.Sequence)
+	assert.Equal(t, []string{"A"}, access.Channels)
+	assert.Nil(t, access.User)
+
+	// The document was removed from B by the second revision
+	require.Len(t, access.ChannelHistory["B"], 1)
+	assert.Equal(t, doc.Sequence, access.ChannelHistory["B"][0].End)
+	require.Len(t, access.ChannelHistory["A"], 1)
+	assert.Equal(t, uint64(0), access.ChannelHistory["A"][0].End)
+
+	assert.Equal(t, []string{"C"}, access.Access["alice"])
+	assert.Equal(t, []string{"D"}, access.Access["role:editor"])
+	assert.Len(t, access.RoleAccess["alice"], 1)
+
+	access, err = collection.GetDocumentAccess(ctx, "doc1", alice)
+	require.NoError(t, err)
+	assert.Equal(t, &DocumentUserAccess{Name: "alice", CanSee: true, MatchingChannels: []string{"A"}}, access.User)
+
+	// Only the channels and grants visible to the user are kept when filtered for them
+	access.filterForUser(alice, collection.ScopeName, collection.Name)
+	assert.Contains(t, access.ChannelHistory, "A")
+	assert.NotContains(t, access.ChannelHistory, "B")
+	assert.Contains(t, access.Access, "alice")
+	assert.NotContains(t, access.Access, "role:editor")
+
+	access, err = collection.GetDocumentAccess(ctx, "doc1", bob)
+	require.NoError(t, err)
+	assert.Equal(t, &DocumentUserAccess{Name: "bob", CanSee: false, MatchingChannels: []string{}}, access.User)
+
+	_, err = collection.GetDocumentAccess(ctx, "missing", nil)
+	status, _ := base.ErrorAsHTTPStatus(err)
+	assert.Equal(t, http.StatusNotFound, status)
+}
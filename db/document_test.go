@@ -15,6 +15,7 @@ import (
 	"encoding/binary"
 	"log"
 	"testing"
+	"time"
 
 	"github.com/couchbase/sync_gateway/base"
 	"github.com/stretchr/testify/assert"
@@ -350,3 +351,70 @@ func TestInvalidXattrStreamEmptyBody(t *testing.T) {
 	require.Nil(t, rawUserXattr)
 
 }
+
+func TestPruneChannelSetHistory(t *testing.T) {
+	now := time.Now()
+	oldEndTime := now.Add(-2 * time.Hour).Unix()
+	recentEndTime := now.Add(-time.Minute).Unix()
+
+	newDoc := func() *Document {
+		doc := NewDocument("doc")
+		doc.ChannelSetHistory = []ChannelSetEntry{
+			{Name: "a", Start: 1, End: 2, EndTime: oldEndTime},
+			{Name: "b", Start: 1, End: 3},
+			{Name: "a", Start: 4, End: 5, EndTime: oldEndTime},
+			{Name: "a", Start: 6, End: 7, EndTime: recentEndTime},
+			{Name: "a", Start: 8, End: 9, EndTime: recentEndTime},
+		}
+		return doc
+	}
+
+	testCases := []struct {
+		name            string
+		options         ChannelHistoryOptions
+		expectedPruned  int
+		expectedHistory []ChannelSetEntry
+	}{
+		{
+			name:            "default",
+			expectedPruned:  0,
+			expectedHistory: newDoc().ChannelSetHistory,
+		},
+		{
+			name:           "maxEntries",
+			options:        ChannelHistoryOptions{MaxEntriesPerChannel: 2},
+			expectedPruned: 2,
+			expectedHistory: []ChannelSetEntry{
+				{Name: "b", Start: 1, End: 3},
+				{Name: "a", Start: 1, End: 7, EndTime: recentEndTime},
+				{Name: "a", Start: 8, End: 9, EndTime: recentEndTime},
+			},
+		},
+		{
+			name:           "maxAge",
+			options:        ChannelHistoryOptions{MaxAge: time.Hour},
+			expectedPruned: 2,
+			expectedHistory: []ChannelSetEntry{
+				{Name: "b", Start: 1, End: 3},
+				{Name: "a", Start: 6, End: 7, EndTime: recentEndTime},
+				{Name: "a", Start: 8, End: 9, EndTime: recentEndTime},
+			},
+		},
+		{
+			name:           "maxAgeAndMaxEntries",
+			options:        ChannelHistoryOptions{MaxEntriesPerChannel: 1, MaxAge: time.Hour},
+			expectedPruned: 3,
+			expectedHistory: []ChannelSetEntry{
+				{Name: "b", Start: 1, End: 3},
+				{Name: "a", Start: 6, End: 9, EndTime: recentEndTime},
+			},
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			doc := newDoc()
+			assert.Equal(t, test.expectedPruned, doc.pruneChannelSetHistory(test.options, now))
+			assert.Equal(t, test.expectedHistory, doc.ChannelSetHistory)
+		})
+	}
+}
@@ -68,7 +68,9 @@ type DocumentChangeEvent struct {
 	DocID            string
 	OldDoc           string
 	Channels         base.Set
-	WinningRevChange bool // whether this event is a change to the winning revision
+	WinningRevChange bool   // whether this event is a change to the winning revision
+	Sequence         uint64 // sequence assigned to the document by the write
+	Collection       string // keyspace of the document's collection, as scope.collection
 }
 
 func (dce *DocumentChangeEvent) String() string {
@@ -79,6 +81,12 @@ func (dce *DocumentChangeEvent) EventType() EventType {
 	return DocumentChange
 }
 
+// EventID identifies the write that raised the event, as scope.collection:sequence.  It's the same on every node and
+// across restarts, so can be used to discard duplicate events.
+func (dce *DocumentChangeEvent) EventID() string {
+	return dce.Collection + ":" + strconv.FormatUint(dce.Sequence, 10)
+}
+
 // DBStateChangeEvent is raised when a DB goes online or offline.
 // Event has name of DB that is firing event, the admin interface address for interacting with the db.
 // The new state, the reason for the state change, the local system time of the change
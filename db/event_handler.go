@@ -17,6 +17,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/couchbase/sync_gateway/base"
@@ -35,10 +36,13 @@ type Webhook struct {
 	AsyncEventHandler
 	url     string
 	filter  *JSEventFunction
+	route   *EventRoute
 	timeout time.Duration
 	client  *http.Client
 	options struct {
 		DocumentChangedWinningRevOnly bool
+		AtLeastOnce                   bool
+		EventMetadata                 bool
 	}
 }
 
@@ -47,6 +51,19 @@ const (
 	kDefaultWebhookTimeout = 60
 	// EventOptionDocumentChangedWinningRevOnly controls whether a document_changed event is processed for winning revs only.
 	EventOptionDocumentChangedWinningRevOnly = "winning_rev_only"
+	// EventOptionAtLeastOnce controls whether document_changed events are delivered in sequence order and retried until
+	// accepted, resuming from a persisted cursor after a restart.
+	EventOptionAtLeastOnce = "at_least_once"
+	// EventOptionEventMetadata controls whether document_changed payloads include the sequence, collection and event
+	// ID of the write.  Always enabled for at_least_once webhooks.
+	EventOptionEventMetadata = "event_metadata"
+)
+
+// Properties added to document_changed payloads to identify the event, when EventOptionEventMetadata is enabled.
+const (
+	webhookPropertySequence   = "_sequence"
+	webhookPropertyCollection = "_collection"
+	webhookPropertyEventID    = "_event_id"
 )
 
 // Creates a new webhook handler based on the url and filter function.
@@ -79,27 +96,59 @@ func NewWebhook(ctx context.Context, url string, filterFnString string, timeout
 
 	if options != nil {
 		wh.options.DocumentChangedWinningRevOnly, _ = options[EventOptionDocumentChangedWinningRevOnly].(bool)
+		wh.options.AtLeastOnce, _ = options[EventOptionAtLeastOnce].(bool)
+		wh.options.EventMetadata, _ = options[EventOptionEventMetadata].(bool)
 	}
 
 	return wh, err
 }
 
+// SetRoute restricts the document_changed events handled by the webhook to those matching the route, which are
+// matched before calling the filter function.
+func (wh *Webhook) SetRoute(route *EventRoute) {
+	wh.route = route
+}
+
+// AtLeastOnce returns true if the webhook should be driven by an atLeastOnceWebhookFeed, instead of being registered
+// with the EventManager.
+func (wh *Webhook) AtLeastOnce() bool {
+	return wh.options.AtLeastOnce
+}
+
 // Performs an HTTP POST to the url defined for the handler.  If a filter function is defined,
 // calls it to determine whether to POST.  The payload for the POST is depends
 // on the event type.
 func (wh *Webhook) HandleEvent(ctx context.Context, event Event) bool {
+	payload, ok := wh.payload(ctx, event)
+	if !ok || !wh.filterAllows(ctx, event) {
+		return false
+	}
+	_, err := wh.post(ctx, event, payload)
+	return err == nil
+}
 
-	const contentType = "application/json"
-	var payload []byte
-
+// payload returns the body to post for the event, or false if the event shouldn't be posted.
+func (wh *Webhook) payload(ctx context.Context, event Event) ([]byte, bool) {
 	// Different events post different content by default
 	switch event := event.(type) {
 	case *DocumentChangeEvent:
 		// skip event if this is for a non-winning rev and the winning rev only option is enabled
 		if !event.WinningRevChange && wh.options.DocumentChangedWinningRevOnly {
-			return false
+			return nil, false
+		}
+		if !wh.options.EventMetadata && !wh.options.AtLeastOnce {
+			return event.DocBytes, true
 		}
-		payload = event.DocBytes
+		payload, err := base.InjectJSONProperties(event.DocBytes,
+			base.KVPair{Key: webhookPropertyEventID, Val: event.EventID()},
+			base.KVPair{Key: webhookPropertySequence, Val: event.Sequence},
+			base.KVPair{Key: webhookPropertyCollection, Val: event.Collection},
+		)
+		if err != nil {
+			base.WarnfCtx(ctx, "Error adding event properties to doc for webhook post: %v", err)
+			return nil, false
+		}
+		return payload, true
 	case *DBStateChangeEvent:
 		// for DBStateChangeEvent, post JSON document with the following format
 		//{
@@ -112,56 +161,107 @@ func (wh *Webhook) HandleEvent(ctx context.Context, event Event) bool {
 		jsonOut, err := base.JSONMarshal(event.Doc)
 		if err != nil {
 			base.WarnfCtx(ctx, "Error marshalling doc for webhook post")
-			return false
+			return nil, false
 		}
-		payload = jsonOut
+		return jsonOut, true
 	default:
 		base.WarnfCtx(ctx, "Webhook invoked for unsupported event type.")
+		return nil, false
+	}
+}
+
+// filterAllows returns true if the event matches the webhook's route, and the webhook has no filter function or the
+// filter function accepts the event.
+func (wh *Webhook) filterAllows(ctx context.Context, event Event) bool {
+	if !wh.route.Matches(event) {
+		base.TracefCtx(ctx, base.KeyEvents, "%s not routed to %v", base.UD(event.String()), wh)
 		return false
 	}
+	if wh.filter == nil {
+		return true
+	}
+	success, err := wh.filter.CallValidateFunction(ctx, event)
+	if err != nil {
+		base.WarnfCtx(ctx, "Error calling webhook filter function: %v", err)
+	}
+	return success
+}
 
-	if wh.filter != nil {
-		// If filter function is defined, use it to determine whether to post
-		success, err := wh.filter.CallValidateFunction(ctx, event)
-		if err != nil {
-			base.WarnfCtx(ctx, "Error calling webhook filter function: %v", err)
+// post sends the payload to the webhook's url, returning the response status code.  Returns an error if the request
+// couldn't be sent.
+func (wh *Webhook) post(ctx context.Context, event Event, payload []byte) (int, error) {
+	const contentType = "application/json"
+	resp, err := wh.client.Post(wh.url, contentType, bytes.NewBuffer(payload))
+	defer func() {
+		// Ensure we're closing the response, so it can be reused
+		if resp != nil && resp.Body != nil {
+			_, err := io.Copy(io.Discard, resp.Body)
+			if err != nil {
+				base.DebugfCtx(ctx, base.KeyEvents, "Error copying response body: %v", err)
+			}
+			err = resp.Body.Close()
+			if err != nil {
+				base.DebugfCtx(ctx, base.KeyEvents, "Error closing response body: %v", err)
+			}
 		}
+	}()
 
-		// If filter returns false, cancel webhook post
-		if !success {
-			return false
-		}
+	if err != nil {
+		base.WarnfCtx(ctx, "Error attempting to post %s to url %s: %s", base.UD(event.String()), base.UD(wh.SanitizedUrl(ctx)), err)
+		return 0, err
 	}
 
-	success := func() bool {
-		resp, err := wh.client.Post(wh.url, contentType, bytes.NewBuffer(payload))
-		defer func() {
-			// Ensure we're closing the response, so it can be reused
-			if resp != nil && resp.Body != nil {
-				_, err := io.Copy(io.Discard, resp.Body)
-				if err != nil {
-					base.DebugfCtx(ctx, base.KeyEvents, "Error copying response body: %v", err)
-				}
-				err = resp.Body.Close()
-				if err != nil {
-					base.DebugfCtx(ctx, base.KeyEvents, "Error closing response body: %v", err)
-				}
-			}
-		}()
+	// Check Log Level first, as SanitizedUrl is expensive to evaluate.
+	if base.LogDebugEnabled(ctx, base.KeyEvents) {
+		base.DebugfCtx(ctx, base.KeyEvents, "Webhook handler ran for event.  Payload %s posted to URL %s, got status %s",
+			base.UD(string(payload)), base.UD(wh.SanitizedUrl(ctx)), resp.Status)
+	}
+	return resp.StatusCode, nil
+}
 
-		if err != nil {
-			base.WarnfCtx(ctx, "Error attempting to post %s to url %s: %s", base.UD(event.String()), base.UD(wh.SanitizedUrl(ctx)), err)
-			return false
+// EventRoute declaratively matches document_changed events by channel, collection and doc ID prefix, so handlers
+// only see the events they're interested in without evaluating a filter function for every write.  Each criteria
+// that is set must match, and matches if any of its values match.  Events of other types always match.
+type EventRoute struct {
+	Channels      base.Set // Matches documents in any of these channels
+	Collections   base.Set // Matches documents in any of these collections, as scope.collection
+	DocIDPrefixes []string // Matches documents whose IDs start with any of these prefixes
+}
+
+// Matches returns true if the event matches the route.  A nil route matches all events.
+func (r *EventRoute) Matches(event Event) bool {
+	dce, ok := event.(*DocumentChangeEvent)
+	if r == nil || !ok {
+		return true
+	}
+	if len(r.Collections) > 0 && !r.Collections.Contains(dce.Collection) {
+		return false
+	}
+	if len(r.Channels) > 0 && !r.matchesChannels(dce.Channels) {
+		return false
+	}
+	if len(r.DocIDPrefixes) > 0 && !r.matchesDocID(dce.DocID) {
+		return false
+	}
+	return true
+}
+
+func (r *EventRoute) matchesChannels(channels base.Set) bool {
+	for channel := range channels {
+		if r.Channels.Contains(channel) {
+			return true
 		}
+	}
+	return false
+}
 
-		// Check Log Level first, as SanitizedUrl is expensive to evaluate.
-		if base.LogDebugEnabled(ctx, base.KeyEvents) {
-			base.DebugfCtx(ctx, base.KeyEvents, "Webhook handler ran for event.  Payload %s posted to URL %s, got status %s",
-				base.UD(string(payload)), base.UD(wh.SanitizedUrl(ctx)), resp.Status)
+func (r *EventRoute) matchesDocID(docID string) bool {
+	for _, prefix := range r.DocIDPrefixes {
+		if strings.HasPrefix(docID, prefix) {
+			return true
 		}
-		return true
-	}()
-	return success
+	}
+	return false
 }
 
 func (wh *Webhook) String() string {
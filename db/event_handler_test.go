@@ -99,3 +99,35 @@ func TestCallValidateFunction(t *testing.T) {
 	assert.Error(t, err, "It should throw an error due to syntax error")
 	assert.Contains(t, err.Error(), "Unexpected token")
 }
+
+func TestEventRouteMatches(t *testing.T) {
+	event := &DocumentChangeEvent{DocID: "order::1", Channels: base.SetOf("orders", "user1"), Collection: "sales.orders"}
+	var route *EventRoute
+	assert.True(t, route.Matches(event), "a nil route should match all events")
+	assert.True(t, (&EventRoute{}).Matches(event), "an empty route should match all events")
+
+	assert.True(t, (&EventRoute{Channels: base.SetOf("invoices", "orders")}).Matches(event))
+	assert.False(t, (&EventRoute{Channels: base.SetOf("invoices")}).Matches(event))
+	assert.True(t, (&EventRoute{Collections: base.SetOf("sales.orders")}).Matches(event))
+	assert.False(t, (&EventRoute{Collections: base.SetOf("sales.invoices")}).Matches(event))
+	assert.True(t, (&EventRoute{DocIDPrefixes: []string{"invoice::", "order::"}}).Matches(event))
+	assert.False(t, (&EventRoute{DocIDPrefixes: []string{"invoice::"}}).Matches(event))
+
+	// Every criteria that's set must match
+	assert.True(t, (&EventRoute{Channels: base.SetOf("orders"), Collections: base.SetOf("sales.orders"), DocIDPrefixes: []string{"order::"}}).Matches(event))
+	assert.False(t, (&EventRoute{Channels: base.SetOf("orders"), DocIDPrefixes: []string{"invoice::"}}).Matches(event))
+
+	// Other event types aren't routed
+	assert.True(t, (&EventRoute{Channels: base.SetOf("invoices")}).Matches(&DBStateChangeEvent{}))
+}
+
+func TestWebhookRouteSkipsFilter(t *testing.T) {
+	ctx := base.TestCtx(t)
+	wh := &Webhook{filter: NewJSEventFunction(ctx, `function(doc) { throw "filter shouldn't be called"; }`)}
+	wh.SetRoute(&EventRoute{DocIDPrefixes: []string{"order::"}})
+	assert.False(t, wh.filterAllows(ctx, &DocumentChangeEvent{DocID: "invoice::1", DocBytes: []byte(`{}`)}))
+
+	wh.filter = NewJSEventFunction(ctx, `function(doc) { return true; }`)
+	assert.True(t, wh.filterAllows(ctx, &DocumentChangeEvent{DocID: "order::1", DocBytes: []byte(`{}`)}))
+	assert.False(t, wh.filterAllows(ctx, &DocumentChangeEvent{DocID: "invoice::1", DocBytes: []byte(`{}`)}))
+}
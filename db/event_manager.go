@@ -158,9 +158,9 @@ func (em *EventManager) raiseEvent(ctx context.Context, event Event) error {
 	return nil
 }
 
-// Raises a document change event based on the the document body and channel set.  If the
-// event manager doesn't have a listener for this event, ignores.
-func (em *EventManager) RaiseDocumentChangeEvent(ctx context.Context, docBytes []byte, docID string, oldBodyJSON string, channels base.Set, winningRevChange bool) error {
+// Raises a document change event based on the the document body and channel set, and the sequence and collection
+// (as scope.collection) of the write.  If the event manager doesn't have a listener for this event, ignores.
+func (em *EventManager) RaiseDocumentChangeEvent(ctx context.Context, docBytes []byte, docID string, oldBodyJSON string, channels base.Set, winningRevChange bool, sequence uint64, collection string) error {
 
 	if !em.activeEventTypes[DocumentChange] {
 		return nil
@@ -171,6 +171,8 @@ func (em *EventManager) RaiseDocumentChangeEvent(ctx context.Context, docBytes [
 		OldDoc:           oldBodyJSON,
 		Channels:         channels,
 		WinningRevChange: winningRevChange,
+		Sequence:         sequence,
+		Collection:       collection,
 	}
 
 	return em.raiseEvent(ctx, event)
@@ -114,7 +114,7 @@ func TestDocumentChangeEvent(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		body, docid, channels := eventForTest(i)
 		bodyBytes, _ := base.JSONMarshal(body)
-		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docid, "", channels, false)
+		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docid, "", channels, false, 0, "")
 		assert.NoError(t, err)
 	}
 
@@ -200,7 +200,7 @@ func TestSlowExecutionProcessing(t *testing.T) {
 	for i := 0; i < 20; i++ {
 		body, docid, channels := eventForTest(i % 10)
 		bodyBytes, _ := base.JSONMarshal(body)
-		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docid, "", channels, false)
+		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docid, "", channels, false, 0, "")
 		assert.NoError(t, err)
 	}
 
@@ -244,7 +244,7 @@ func TestCustomHandler(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		body, docid, channels := eventForTest(i)
 		bodyBytes, _ := base.JSONMarshal(body)
-		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docid, "", channels, false)
+		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docid, "", channels, false, 0, "")
 		assert.NoError(t, err)
 	}
 
@@ -290,7 +290,7 @@ func TestUnhandledEvent(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		body, docid, channels := eventForTest(i)
 		bodyBytes, _ := base.JSONMarshal(body)
-		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docid, "", channels, false)
+		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docid, "", channels, false, 0, "")
 		assert.NoError(t, err)
 	}
 
@@ -473,7 +473,7 @@ func TestWebhookBasic(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		body, docId, channels := eventForTest(i)
 		bodyBytes, _ := base.JSONMarshal(body)
-		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, "", channels, false)
+		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, "", channels, false, 0, "")
 		assert.NoError(t, err)
 	}
 	err := em.waitForProcessedTotal(ctx, 10, DefaultWaitForWebhook)
@@ -497,7 +497,7 @@ func TestWebhookBasic(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		body, docId, channels := eventForTest(i)
 		bodyBytes, _ := base.JSONMarshal(body)
-		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, "", channels, false)
+		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, "", channels, false, 0, "")
 		assert.NoError(t, err)
 	}
 
@@ -514,13 +514,27 @@ func TestWebhookBasic(t *testing.T) {
 	em.RegisterEventHandler(ctx, webhookHandler, DocumentChange)
 	body, docId, channels := eventForTest(0)
 	bodyBytes, _ := base.JSONMarshalCanonical(body)
-	err = em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, "", channels, false)
+	err = em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, "", channels, false, 0, "")
 	assert.NoError(t, err)
 	err = em.waitForProcessedTotal(ctx, 1, DefaultWaitForWebhook)
 	assert.NoError(t, err)
 	receivedPayload := string((wr.GetPayloads())[0])
 	fmt.Println("payload:", receivedPayload)
 	assert.Equal(t, `{"_id":"0","value":0}`, receivedPayload)
+
+	// Validate payload with the event metadata option
+	log.Println("Test event metadata payload validation")
+	wr.Clear()
+	em = NewEventManager(terminator)
+	em.Start(ctx, 0, -1)
+	webhookHandler, _ = NewWebhook(ctx, fmt.Sprintf("%s/echo", url), "", nil, map[string]interface{}{EventOptionEventMetadata: true})
+	em.RegisterEventHandler(ctx, webhookHandler, DocumentChange)
+	err = em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, "", channels, false, 12, "scope1.collection1")
+	assert.NoError(t, err)
+	err = em.waitForProcessedTotal(ctx, 1, DefaultWaitForWebhook)
+	assert.NoError(t, err)
+	receivedPayload = string((wr.GetPayloads())[0])
+	assert.Equal(t, `{"_id":"0","value":0,"_event_id":"scope1.collection1:12","_sequence":12,"_collection":"scope1.collection1"}`, receivedPayload)
 }
 
 func TestWebhookOverflows(t *testing.T) {
@@ -567,7 +581,7 @@ func TestWebhookOverflows(t *testing.T) {
 	for i := 0; i < 100; i++ {
 		body, docId, channels := eventForTest(i % 10)
 		bodyBytes, _ := base.JSONMarshal(body)
-		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, "", channels, false)
+		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, "", channels, false, 0, "")
 		assert.NoError(t, err)
 	}
 	err := em.waitForProcessedTotal(ctx, 100, DefaultWaitForWebhook)
@@ -587,7 +601,7 @@ func TestWebhookOverflows(t *testing.T) {
 	for i := 0; i < 100; i++ {
 		body, docId, channels := eventForTest(i)
 		bodyBytes, _ := base.JSONMarshal(body)
-		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, "", channels, false)
+		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, "", channels, false, 0, "")
 		if err != nil {
 			errCount++
 		}
@@ -610,7 +624,7 @@ func TestWebhookOverflows(t *testing.T) {
 	for i := 0; i < 100; i++ {
 		body, docId, channels := eventForTest(i % 10)
 		bodyBytes, _ := base.JSONMarshal(body)
-		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, "", channels, false)
+		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, "", channels, false, 0, "")
 		assert.NoError(t, err)
 	}
 	err = em.waitForProcessedTotal(ctx, 100, 10*time.Second)
@@ -660,7 +674,7 @@ func TestWebhookOldDoc(t *testing.T) {
 		oldBodyBytes, _ := base.JSONMarshal(oldBody)
 		body, docId, channels := eventForTest(strconv.Itoa(i), i)
 		bodyBytes, _ := base.JSONMarshal(body)
-		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, string(oldBodyBytes), channels, false)
+		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, string(oldBodyBytes), channels, false, 0, "")
 		assert.NoError(t, err)
 
 	}
@@ -689,7 +703,7 @@ func TestWebhookOldDoc(t *testing.T) {
 		oldBodyBytes, _ := base.JSONMarshal(oldBody)
 		body, docId, channels := eventForTest(strconv.Itoa(i), i)
 		bodyBytes, _ := base.JSONMarshal(body)
-		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, string(oldBodyBytes), channels, false)
+		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, string(oldBodyBytes), channels, false, 0, "")
 		assert.NoError(t, err)
 	}
 	err = em.waitForProcessedTotal(ctx, 10, DefaultWaitForWebhook)
@@ -717,7 +731,7 @@ func TestWebhookOldDoc(t *testing.T) {
 		oldBodyBytes, _ := base.JSONMarshal(oldBody)
 		body, docId, channels := eventForTest(strconv.Itoa(i), i)
 		bodyBytes, _ := base.JSONMarshal(body)
-		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, string(oldBodyBytes), channels, false)
+		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, string(oldBodyBytes), channels, false, 0, "")
 		assert.NoError(t, err)
 	}
 	err = em.waitForProcessedTotal(ctx, 10, DefaultWaitForWebhook)
@@ -742,7 +756,7 @@ func TestWebhookOldDoc(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		body, docId, channels := eventForTest(strconv.Itoa(i), i)
 		bodyBytes, _ := base.JSONMarshal(body)
-		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, "", channels, false)
+		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, "", channels, false, 0, "")
 		assert.NoError(t, err)
 	}
 	for i := 10; i < 20; i++ {
@@ -751,7 +765,7 @@ func TestWebhookOldDoc(t *testing.T) {
 		oldBodyBytes, _ := base.JSONMarshal(oldBody)
 		body, docId, channels := eventForTest(strconv.Itoa(i), i)
 		bodyBytes, _ := base.JSONMarshal(body)
-		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, string(oldBodyBytes), channels, false)
+		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, string(oldBodyBytes), channels, false, 0, "")
 		assert.NoError(t, err)
 	}
 	err = em.waitForProcessedTotal(ctx, 20, DefaultWaitForWebhook)
@@ -802,7 +816,7 @@ func TestWebhookTimeout(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		body, docid, channels := eventForTest(strconv.Itoa(i), i)
 		bodyBytes, _ := base.JSONMarshal(body)
-		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docid, "", channels, false)
+		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docid, "", channels, false, 0, "")
 		assert.NoError(t, err)
 	}
 	err := em.waitForProcessedTotal(ctx, 10, DefaultWaitForWebhook)
@@ -824,7 +838,7 @@ func TestWebhookTimeout(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		body, docid, channels := eventForTest(strconv.Itoa(i), i)
 		bodyBytes, _ := base.JSONMarshal(body)
-		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docid, "", channels, false)
+		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docid, "", channels, false, 0, "")
 		time.Sleep(2 * time.Millisecond)
 		if err != nil {
 			errCount++
@@ -849,7 +863,7 @@ func TestWebhookTimeout(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		body, docid, channels := eventForTest(strconv.Itoa(i), i)
 		bodyBytes, _ := base.JSONMarshal(body)
-		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docid, "", channels, false)
+		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docid, "", channels, false, 0, "")
 		time.Sleep(2 * time.Millisecond)
 		if err != nil {
 			errCount++
@@ -872,7 +886,7 @@ func TestWebhookTimeout(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		body, docid, channels := eventForTest(strconv.Itoa(i), i)
 		bodyBytes, _ := base.JSONMarshal(body)
-		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docid, "", channels, false)
+		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docid, "", channels, false, 0, "")
 		time.Sleep(2 * time.Millisecond)
 		if err != nil {
 			errCount++
@@ -921,7 +935,7 @@ func TestUnavailableWebhook(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		body, docId, channels := eventForTest(strconv.Itoa(-i), i)
 		bodyBytes, _ := base.JSONMarshal(body)
-		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, "", channels, false)
+		err := em.RaiseDocumentChangeEvent(ctx, bodyBytes, docId, "", channels, false, 0, "")
 		assert.NoError(t, err)
 	}
 	time.Sleep(50 * time.Millisecond)
@@ -0,0 +1,359 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+)
+
+const (
+	DefaultExternalAuthorizerTimeout  = 2 * time.Second // How long to wait for a decision from an external authorization service
+	DefaultExternalAuthorizerCacheTTL = time.Minute     // How long decisions from an external authorization service are cached for
+	externalAuthorizerCacheCapacity   = 100000          // Max number of cached decisions before expired decisions are evicted
+)
+
+// Actions that external authorization decisions are requested for.
+const (
+	ExternalAuthorizationActionRead  = "read"
+	ExternalAuthorizationActionWrite = "write"
+)
+
+// ExternalAuthorizer makes authorization decisions on behalf of an external authorization service, such as a policy
+// engine, for users reading channels, through the changes feed or by fetching documents, and writing documents.
+type ExternalAuthorizer interface {
+	// AuthorizeChannels returns which of the channels in the keyspace the user is allowed to read.
+	AuthorizeChannels(ctx context.Context, username, keyspace string, channels []string) (allowed []string, err error)
+	// AuthorizeWrite returns true if the user is allowed to write the document to the keyspace, assigned to the
+	// given channels by the sync function.
+	AuthorizeWrite(ctx context.Context, username, keyspace, docID string, channels []string) (allowed bool, err error)
+}
+
+// ExternalAuthorizerOptions configures an external authorizer, consulted alongside the access granted by the sync
+// function.  By default the authorizer can only restrict access granted by the sync function.
+type ExternalAuthorizerOptions struct {
+	Authorizer     ExternalAuthorizer // Makes authorization decisions
+	GrantsChannels bool               // Whether channels the authorizer allows can be read without a grant from the sync function
+	FailOpen       bool               // Whether access is allowed, rather than denied, when the authorizer can't make a decision
+}
+
+// ExternalAuthorizationRequest is posted to an external authorization service for each decision that isn't cached.
+type ExternalAuthorizationRequest struct {
+	Action   string   `json:"action"` // "read" or "write"
+	User     string   `json:"user"`
+	Keyspace string   `json:"keyspace"`
+	DocID    string   `json:"doc_id,omitempty"` // Document being written, for write requests
+	Channels []string `json:"channels"`         // Channels to read, or the channels the document being written is assigned to
+}
+
+// ExternalAuthorizationResponse is an external authorization service's decision.
+type ExternalAuthorizationResponse struct {
+	Allow    bool     `json:"allow"`              // Whether the write is allowed, for write requests
+	Channels []string `json:"channels,omitempty"` // Which of the requested channels can be read, for read requests
+}
+
+// HTTPExternalAuthorizer is an ExternalAuthorizer that requests decisions from an external authorization service over
+// HTTP.  Decisions are cached, so that the service isn't consulted on every changes request and write.
+type HTTPExternalAuthorizer struct {
+	url      string
+	client   *http.Client
+	cacheTTL time.Duration
+	cache    map[string]externalAuthorizationDecision
+	lock     sync.Mutex
+}
+
+// externalAuthorizationDecision is a cached decision.
+type externalAuthorizationDecision struct {
+	allowed bool
+	expires time.Time
+}
+
+var _ ExternalAuthorizer = &HTTPExternalAuthorizer{}
+
+// NewHTTPExternalAuthorizer creates an authorizer posting ExternalAuthorizationRequests to the url.  A cacheTTL of 0
+// disables caching.
+func NewHTTPExternalAuthorizer(url string, timeout, cacheTTL time.Duration) *HTTPExternalAuthorizer {
+	transport := base.DefaultHTTPTransport()
+	transport.DisableKeepAlives = false
+	return &HTTPExternalAuthorizer{
+		url:      url,
+		client:   &http.Client{Transport: transport, Timeout: timeout},
+		cacheTTL: cacheTTL,
+		cache:    make(map[string]externalAuthorizationDecision),
+	}
+}
+
+// AuthorizeChannels returns which of the channels the user is allowed to read.  Decisions are cached per channel, so
+// only channels without a cached decision are requested.
+func (a *HTTPExternalAuthorizer) AuthorizeChannels(ctx context.Context, username, keyspace string, chans []string) (allowed []string, err error) {
+	var uncached []string
+	now := time.Now()
+	for _, channel := range chans {
+		channelAllowed, ok := a.getDecision(externalAuthorizationCacheKey(ExternalAuthorizationActionRead, username, keyspace, channel), now)
+		if !ok {
+			uncached = append(uncached, channel)
+		} else if channelAllowed {
+			allowed = append(allowed, channel)
+		}
+	}
+	if len(uncached) == 0 {
+		return allowed, nil
+	}
+
+	response, err := a.request(ctx, ExternalAuthorizationRequest{
+		Action:   ExternalAuthorizationActionRead,
+		User:     username,
+		Keyspace: keyspace,
+		Channels: uncached,
+	})
+	if err != nil {
+		return nil, err
+	}
+	allowedChannels := base.SetFromArray(response.Channels)
+	for _, channel := range uncached {
+		channelAllowed := allowedChannels.Contains(channel)
+		a.putDecision(externalAuthorizationCacheKey(ExternalAuthorizationActionRead, username, keyspace, channel), channelAllowed, now)
+		if channelAllowed {
+			allowed = append(allowed, channel)
+		}
+	}
+	return allowed, nil
+}
+
+// AuthorizeWrite returns true if the user is allowed to write the document.  Decisions are cached per document and
+// channel set.
+func (a *HTTPExternalAuthorizer) AuthorizeWrite(ctx context.Context, username, keyspace, docID string, chans []string) (allowed bool, err error) {
+	sortedChannels := append([]string(nil), chans...)
+	sort.Strings(sortedChannels)
+	cacheKey := externalAuthorizationCacheKey(ExternalAuthorizationActionWrite, username, keyspace, docID, strings.Join(sortedChannels, ","))
+	now := time.Now()
+	if allowed, ok := a.getDecision(cacheKey, now); ok {
+		return allowed, nil
+	}
+
+	response, err := a.request(ctx, ExternalAuthorizationRequest{
+		Action:   ExternalAuthorizationActionWrite,
+		User:     username,
+		Keyspace: keyspace,
+		DocID:    docID,
+		Channels: sortedChannels,
+	})
+	if err != nil {
+		return false, err
+	}
+	a.putDecision(cacheKey, response.Allow, now)
+	return response.Allow, nil
+}
+
+// request posts the request to the external authorization service, and returns its decision.
+func (a *HTTPExternalAuthorizer) request(ctx context.Context, authRequest ExternalAuthorizationRequest) (*ExternalAuthorizationResponse, error) {
+	payload, err := base.JSONMarshal(authRequest)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("external authorizer returned status %d", resp.StatusCode)
+	}
+	var response ExternalAuthorizationResponse
+	if err := base.JSONDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("unable to decode external authorizer response: %w", err)
+	}
+	return &response, nil
+}
+
+// getDecision returns the cached decision for the key, or false if there's no unexpired decision cached.
+func (a *HTTPExternalAuthorizer) getDecision(key string, now time.Time) (allowed bool, ok bool) {
+	if a.cacheTTL <= 0 {
+		return false, false
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	decision, ok := a.cache[key]
+	if !ok || now.After(decision.expires) {
+		return false, false
+	}
+	return decision.allowed, true
+}
+
+// putDecision caches the decision for the key.  When the cache is full, expired decisions are evicted, and if it's
+// still full, the cache is cleared.
+func (a *HTTPExternalAuthorizer) putDecision(key string, allowed bool, now time.Time) {
+	if a.cacheTTL <= 0 {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if len(a.cache) >= externalAuthorizerCacheCapacity {
+		for cachedKey, decision := range a.cache {
+			if now.After(decision.expires) {
+				delete(a.cache, cachedKey)
+			}
+		}
+		if len(a.cache) >= externalAuthorizerCacheCapacity {
+			a.cache = make(map[string]externalAuthorizationDecision)
+		}
+	}
+	a.cache[key] = externalAuthorizationDecision{allowed: allowed, expires: now.Add(a.cacheTTL)}
+}
+
+// externalAuthorizationCacheKey returns the cache key for a decision, given the properties identifying it.
+func externalAuthorizationCacheKey(parts ...string) string {
+	return strings.Join(parts, "\x00")
+}
+
+// externalAuthorizerOptions returns the database's external authorizer options, or nil if there's no external
+// authorizer.
+func (c *DatabaseCollection) externalAuthorizerOptions() *ExternalAuthorizerOptions {
+	options := c.dbCtx.Options.ExternalAuthorizerOptions
+	if options == nil || options.Authorizer == nil {
+		return nil
+	}
+	return options
+}
+
+// externalAuthorizerKeyspace returns the scope and collection name of the collection, as sent to the external authorizer.
+func (c *DatabaseCollection) externalAuthorizerKeyspace() string {
+	return c.ScopeName + base.ScopeCollectionSeparator + c.Name
+}
+
+// filterToAvailableChannels restricts the channels to those the user can read, and returns when each channel became
+// available.  Channels available through the user's grants are further restricted to those allowed by the external
+// authorizer, if there is one.  Also returns the channels that were removed.
+func (col *DatabaseCollectionWithUser) filterToAvailableChannels(ctx context.Context, chans base.Set) (channelsSince channels.TimedSet, removed []string) {
+	channelsSince, removed = col.user.FilterToAvailableCollectionChannels(col.ScopeName, col.Name, chans)
+	options := col.externalAuthorizerOptions()
+	if options == nil {
+		return channelsSince, removed
+	}
+
+	candidates := channelsSince.AllKeys()
+	ungranted := base.Set{}
+	if options.GrantsChannels {
+		for _, channel := range removed {
+			if channel != channels.AllChannelWildcard {
+				candidates = append(candidates, channel)
+				ungranted.Add(channel)
+			}
+		}
+	}
+	allowed, err := options.Authorizer.AuthorizeChannels(ctx, col.user.Name(), col.externalAuthorizerKeyspace(), candidates)
+	if err != nil {
+		base.WarnfCtx(ctx, "External authorizer unable to authorize channels for user %s: %v", base.UD(col.user.Name()), err)
+		if options.FailOpen {
+			return channelsSince, removed
+		}
+		allowed = nil
+	}
+
+	allowedSince := make(channels.TimedSet, len(allowed))
+	for _, channel := range allowed {
+		if since, ok := channelsSince[channel]; ok {
+			allowedSince[channel] = since
+		} else if ungranted.Contains(channel) {
+			// Channels only allowed by the authorizer have been available since the start of the feed
+			allowedSince[channel] = channels.NewVbSimpleSequence(1)
+		}
+	}
+	filteredRemoved := make([]string, 0, len(removed))
+	for _, channel := range removed {
+		if _, ok := allowedSince[channel]; !ok {
+			filteredRemoved = append(filteredRemoved, channel)
+		}
+	}
+	for channel := range channelsSince {
+		if _, ok := allowedSince[channel]; !ok {
+			filteredRemoved = append(filteredRemoved, channel)
+		}
+	}
+	return allowedSince, filteredRemoved
+}
+
+// authorizeReadChannels returns an error if the user isn't allowed to read a revision assigned to the channels.  As
+// with the changes feed, at least one of the channels must be available through the user's grants, or allowed by the
+// external authorizer if it grants channels, and then also be allowed by the external authorizer if there is one.
+func (col *DatabaseCollectionWithUser) authorizeReadChannels(ctx context.Context, revChannels base.Set) error {
+	if col.user == nil {
+		return nil
+	}
+	authErr := col.user.AuthorizeAnyCollectionChannel(col.ScopeName, col.Name, revChannels)
+	options := col.externalAuthorizerOptions()
+	if options == nil {
+		return authErr
+	}
+
+	var candidates []string
+	if len(revChannels) == 0 && authErr == nil {
+		// Revisions in no channels are only visible through the star channel
+		candidates = append(candidates, channels.UserStarChannel)
+	}
+	for channel := range revChannels {
+		if options.GrantsChannels || col.user.CanSeeCollectionChannel(col.ScopeName, col.Name, channel) {
+			candidates = append(candidates, channel)
+		}
+	}
+	if len(candidates) == 0 {
+		if authErr != nil {
+			return authErr
+		}
+		return col.user.UnauthError("You are not allowed to see this")
+	}
+	allowed, err := options.Authorizer.AuthorizeChannels(ctx, col.user.Name(), col.externalAuthorizerKeyspace(), candidates)
+	if err != nil {
+		base.WarnfCtx(ctx, "External authorizer unable to authorize channels for user %s: %v", base.UD(col.user.Name()), err)
+		if options.FailOpen {
+			return authErr
+		}
+		allowed = nil
+	}
+	if len(allowed) == 0 {
+		return col.user.UnauthError("You are not allowed to see this")
+	}
+	return nil
+}
+
+// authorizeWriteExternally returns an error if the external authorizer doesn't allow the user to write the document
+// to the given channels.  Writes that aren't made by a user, such as admin writes and imports, are always allowed.
+func (col *DatabaseCollectionWithUser) authorizeWriteExternally(ctx context.Context, docID string, docChannels base.Set) error {
+	options := col.externalAuthorizerOptions()
+	if options == nil || col.user == nil {
+		return nil
+	}
+	allowed, err := options.Authorizer.AuthorizeWrite(ctx, col.user.Name(), col.externalAuthorizerKeyspace(), docID, docChannels.ToArray())
+	if err != nil {
+		base.WarnfCtx(ctx, "External authorizer unable to authorize write of doc %q by user %s: %v", base.UD(docID), base.UD(col.user.Name()), err)
+		if options.FailOpen {
+			return nil
+		}
+		return base.HTTPErrorf(http.StatusServiceUnavailable, "Unable to authorize write")
+	}
+	if !allowed {
+		col.dbStats().Security().NumAccessErrors.Add(1)
+		return base.HTTPErrorf(http.StatusForbidden, "Write not allowed by external authorizer")
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testExternalAuthorizer allows reads of the channels in readable, and writes to docs in writable.
+type testExternalAuthorizer struct {
+	readable base.Set
+	writable base.Set
+	err      error
+}
+
+func (a *testExternalAuthorizer) AuthorizeChannels(_ context.Context, _, _ string, chans []string) ([]string, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	var allowed []string
+	for _, channel := range chans {
+		if a.readable.Contains(channel) {
+			allowed = append(allowed, channel)
+		}
+	}
+	return allowed, nil
+}
+
+func (a *testExternalAuthorizer) AuthorizeWrite(_ context.Context, _, _, docID string, _ []string) (bool, error) {
+	if a.err != nil {
+		return false, a.err
+	}
+	return a.writable.Contains(docID), nil
+}
+
+func TestHTTPExternalAuthorizer(t *testing.T) {
+	var requestCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requestCount, 1)
+		var request ExternalAuthorizationRequest
+		require.NoError(t, base.JSONDecoder(r.Body).Decode(&request))
+		assert.Equal(t, "alice", request.User)
+		assert.Equal(t, "scope.collection", request.Keyspace)
+		response := ExternalAuthorizationResponse{}
+		if request.Action == ExternalAuthorizationActionWrite {
+			response.Allow = request.DocID == "allowed"
+		} else {
+			for _, channel := range request.Channels {
+				if channel != "secret" {
+					response.Channels = append(response.Channels, channel)
+				}
+			}
+		}
+		body, err := base.JSONMarshal(response)
+		require.NoError(t, err)
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	ctx := base.TestCtx(t)
+	authorizer := NewHTTPExternalAuthorizer(server.URL, time.Second, time.Minute)
+	allowed, err := authorizer.AuthorizeChannels(ctx, "alice", "scope.collection", []string{"A", "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A"}, allowed)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requestCount))
+
+	// Only channels without a cached decision are requested
+	allowed, err = authorizer.AuthorizeChannels(ctx, "alice", "scope.collection", []string{"secret", "A"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A"}, allowed)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requestCount))
+	allowed, err = authorizer.AuthorizeChannels(ctx, "alice", "scope.collection", []string{"A", "B"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A", "B"}, allowed)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requestCount))
+
+	writeAllowed, err := authorizer.AuthorizeWrite(ctx, "alice", "scope.collection", "allowed", []string{"B", "A"})
+	require.NoError(t, err)
+	assert.True(t, writeAllowed)
+	writeAllowed, err = authorizer.AuthorizeWrite(ctx, "alice", "scope.collection", "allowed", []string{"A", "B"})
+	require.NoError(t, err)
+	assert.True(t, writeAllowed)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requestCount))
+	writeAllowed, err = authorizer.AuthorizeWrite(ctx, "alice", "scope.collection", "denied", []string{"A"})
+	require.NoError(t, err)
+	assert.False(t, writeAllowed)
+
+	// Decisions aren't cached when the service is unavailable
+	server.Close()
+	_, err = authorizer.AuthorizeChannels(ctx, "alice", "scope.collection", []string{"C"})
+	require.Error(t, err)
+}
+
+func TestExternalAuthorizerFiltersChannels(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	authorizer := &testExternalAuthorizer{readable: base.SetOf("A", "C")}
+	db.Options.ExternalAuthorizerOptions = &ExternalAuthorizerOptions{Authorizer: authorizer}
+
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+	authenticator := db.Authenticator(ctx)
+	user, err := authenticator.NewUser("alice", "password", nil)
+	require.NoError(t, err)
+	user.SetCollectionExplicitChannels(collection.ScopeName, collection.Name, channels.AtSequence(base.SetOf("A", "B"), 1), 1)
+	require.NoError(t, authenticator.Save(user))
+	collection.user, err = authenticator.GetUser("alice")
+	require.NoError(t, err)
+
+	// Granted channels are restricted to those the authorizer allows
+	channelsSince, removed := collection.filterToAvailableChannels(ctx, base.SetOf("A", "B", "C"))
+	assert.Equal(t, []string{"A"}, channelsSince.AllKeys())
+	assert.ElementsMatch(t, []string{"B", "C"}, removed)
+
+	// The authorizer can also grant channels
+	db.Options.ExternalAuthorizerOptions.GrantsChannels = true
+	channelsSince, removed = collection.filterToAvailableChannels(ctx, base.SetOf("A", "B", "C"))
+	assert.ElementsMatch(t, []string{"A", "C"}, channelsSince.AllKeys())
+	assert.Equal(t, uint64(1), channelsSince["C"].Sequence)
+	assert.Equal(t, []string{"B"}, removed)
+
+	// When the authorizer is unavailable, no channels are available unless failing open
+	authorizer.err = errors.New("unavailable")
+	channelsSince, _ = collection.filterToAvailableChannels(ctx, base.SetOf("A", "B"))
+	assert.Empty(t, channelsSince)
+	db.Options.ExternalAuthorizerOptions.FailOpen = true
+	channelsSince, _ = collection.filterToAvailableChannels(ctx, base.SetOf("A", "B"))
+	assert.ElementsMatch(t, []string{"A", "B"}, channelsSince.AllKeys())
+}
+
+func TestExternalAuthorizerWrites(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	authorizer := &testExternalAuthorizer{writable: base.SetOf("allowed")}
+	db.Options.ExternalAuthorizerOptions = &ExternalAuthorizerOptions{Authorizer: authorizer}
+
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+	authenticator := db.Authenticator(ctx)
+	user, err := authenticator.NewUser("alice", "password", base.SetOf("*"))
+	require.NoError(t, err)
+	require.NoError(t, authenticator.Save(user))
+	collection.user, err = authenticator.GetUser("alice")
+	require.NoError(t, err)
+
+	_, _, err = collection.Put(ctx, "allowed", Body{"channels": "A"})
+	require.NoError(t, err)
+	_, _, err = collection.Put(ctx, "denied", Body{"channels": "A"})
+	assertHTTPError(t, err, http.StatusForbidden)
+
+	authorizer.err = errors.New("unavailable")
+	_, _, err = collection.Put(ctx, "allowed2", Body{"channels": "A"})
+	assertHTTPError(t, err, http.StatusServiceUnavailable)
+
+	// Writes that aren't made by a user aren't authorized externally
+	collection.user = nil
+	_, _, err = collection.Put(ctx, "denied", Body{"channels": "A"})
+	require.NoError(t, err)
+}
@@ -0,0 +1,106 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+const (
+	HealthStatusOK          = "ok"          // Online, and all dependencies are available
+	HealthStatusDegraded    = "degraded"    // Online, but one or more dependencies are unavailable
+	HealthStatusUnavailable = "unavailable" // Not online
+
+	FeedStatusRunning = "running"
+	FeedStatusStopped = "stopped"
+)
+
+// DatabaseHealth is the result of probing a database's dependencies, as reported by the health check endpoints.
+type DatabaseHealth struct {
+	Status          string `json:"status"`
+	State           string `json:"state"`
+	BucketReachable bool   `json:"bucket_reachable"`
+	BucketError     string `json:"bucket_error,omitempty"`
+	CachingFeed     string `json:"caching_feed"`
+	ImportFeed      string `json:"import_feed,omitempty"` // Only set when this node is importing documents
+}
+
+// Ready returns true if the database is online and all its dependencies are available.
+func (h *DatabaseHealth) Ready() bool {
+	return h.Status == HealthStatusOK
+}
+
+// CheckHealth probes the database's bucket connectivity and DCP feeds, and reports them along with the database's
+// run state.
+func (db *DatabaseContext) CheckHealth(ctx context.Context) DatabaseHealth {
+	state := atomic.LoadUint32(&db.State)
+	health := DatabaseHealth{
+		State:       RunStateString[state],
+		CachingFeed: FeedStatusStopped,
+	}
+
+	// Any response other than a successful lookup means the bucket can't be reached
+	if _, err := db.MetadataStore.Exists(db.MetadataKeys.SyncSeqKey()); err != nil {
+		base.InfofCtx(ctx, base.KeyAll, "Health check: unable to reach bucket %s: %v", base.MD(db.Bucket.GetName()), err)
+		health.BucketError = err.Error()
+	} else {
+		health.BucketReachable = true
+	}
+
+	if db.mutationListener.isRunning() {
+		health.CachingFeed = FeedStatusRunning
+	}
+	if db.autoImport {
+		health.ImportFeed = FeedStatusStopped
+		if db.ImportListener.isRunning() {
+			health.ImportFeed = FeedStatusRunning
+		}
+	}
+
+	switch {
+	case state != DBOnline:
+		health.Status = HealthStatusUnavailable
+	case !health.BucketReachable || health.CachingFeed != FeedStatusRunning || health.ImportFeed == FeedStatusStopped:
+		health.Status = HealthStatusDegraded
+	default:
+		health.Status = HealthStatusOK
+	}
+	return health
+}
+
+// isRunning returns true if the listener's feed has been started, and hasn't yet stopped.
+func (listener *changeListener) isRunning() bool {
+	if !listener.started.IsTrue() {
+		return false
+	}
+	select {
+	case <-listener.terminator:
+		return false
+	case <-listener.FeedArgs.DoneChan:
+		return false
+	default:
+		return true
+	}
+}
+
+// isRunning returns true if the import feed hasn't been stopped.
+func (il *importListener) isRunning() bool {
+	if il == nil {
+		return false
+	}
+	select {
+	case <-il.terminator:
+		return false
+	default:
+		return true
+	}
+}
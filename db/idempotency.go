@@ -0,0 +1,129 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+const (
+	// DefaultIdempotencyKeyTTL is how long the response to a document write is replayed for retries with the same
+	// idempotency key, if the database doesn't set an IdempotencyKeyTTL.
+	DefaultIdempotencyKeyTTL = 24 * time.Hour
+
+	// MaxIdempotencyKeyLength is the maximum length of an idempotency key.
+	MaxIdempotencyKeyLength = 255
+
+	// idempotencyPendingTTL is how long a write's idempotency key is reserved for before its response is recorded,
+	// so that the key can be reused if the node making the write fails.
+	idempotencyPendingTTL = time.Minute
+
+	// idempotencyReserveRetries is how many times a key is reserved again if its record expires while being read.
+	idempotencyReserveRetries = 3
+)
+
+// IdempotentResponse is the response to a document write, recorded against the write's idempotency key so that
+// retries of the write are given the same response rather than being written again.
+type IdempotentResponse struct {
+	RequestHash string            `json:"request_hash"`      // Hash of the request, to detect a key reused for a different request
+	Pending     bool              `json:"pending,omitempty"` // Set while the write is being made, before its response is recorded
+	Status      int               `json:"status,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+}
+
+// IdempotencyKey identifies the writes made with an idempotency key, by a user to a keyspace.
+type IdempotencyKey struct {
+	docID       string
+	requestHash string
+}
+
+// NewIdempotencyKey returns the IdempotencyKey of a request made by a user to a keyspace, with the given
+// idempotency key.  requestHash identifies the request, so that the key can't be reused for a different request.
+func (context *DatabaseContext) NewIdempotencyKey(username, keyspace, key string, requestHash string) (*IdempotencyKey, error) {
+	if key == "" || len(key) > MaxIdempotencyKeyLength {
+		return nil, base.HTTPErrorf(http.StatusBadRequest, "Idempotency key must be between 1 and %d characters", MaxIdempotencyKeyLength)
+	}
+	// Keys are scoped to the user, so that one user can't be given the response to another's write
+	hash := sha256.Sum256([]byte(username + "\x00" + keyspace + "\x00" + key))
+	return &IdempotencyKey{
+		docID:       context.MetadataKeys.IdempotencyKey(hex.EncodeToString(hash[:])),
+		requestHash: requestHash,
+	}, nil
+}
+
+// ReserveIdempotencyKey reserves the key for a write.  If a write has already been made with the key, returns its
+// recorded response rather than reserving the key, which should be replayed instead of making the write again.
+// Returns an error if the key was used for a different request, or a write with the key is still being made.
+func (context *DatabaseContext) ReserveIdempotencyKey(ctx context.Context, key *IdempotencyKey) (*IdempotentResponse, error) {
+	pending := IdempotentResponse{RequestHash: key.requestHash, Pending: true}
+	for i := 0; i < idempotencyReserveRetries; i++ {
+		added, err := context.MetadataStore.Add(key.docID, base.DurationToCbsExpiry(idempotencyPendingTTL), pending)
+		if err != nil {
+			return nil, err
+		} else if added {
+			return nil, nil
+		}
+
+		var recorded IdempotentResponse
+		if _, err := context.MetadataStore.Get(key.docID, &recorded); base.IsDocNotFoundError(err) {
+			// Expired since it was added, so try again
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if recorded.RequestHash != key.requestHash {
+			return nil, base.NewCodedError(base.ErrorCodeIdempotencyKeyReused,
+				base.HTTPErrorf(http.StatusUnprocessableEntity, "Idempotency key has already been used for a different request"), nil)
+		}
+		if recorded.Pending {
+			return nil, base.NewCodedError(base.ErrorCodeIdempotencyKeyInUse,
+				base.HTTPErrorf(http.StatusConflict, "A request with this idempotency key is in progress"), nil)
+		}
+		base.DebugfCtx(ctx, base.KeyCRUD, "Replaying response to earlier request with the same idempotency key")
+		context.DbStats.Database().NumIdempotentReplays.Add(1)
+		return &recorded, nil
+	}
+	return nil, base.HTTPErrorf(http.StatusServiceUnavailable, "Unable to reserve idempotency key")
+}
+
+// RecordIdempotentResponse records the response to a write made with a reserved key, to be replayed for retries of
+// the write until the database's idempotency key TTL has passed.
+func (context *DatabaseContext) RecordIdempotentResponse(ctx context.Context, key *IdempotencyKey, status int, headers map[string]string, body []byte) {
+	response := IdempotentResponse{
+		RequestHash: key.requestHash,
+		Status:      status,
+		Headers:     headers,
+		Body:        body,
+	}
+	if err := context.MetadataStore.Set(key.docID, base.DurationToCbsExpiry(context.idempotencyKeyTTL()), nil, response); err != nil {
+		// The write has been made, so only retries of it are affected
+		base.WarnfCtx(ctx, "Unable to record response for idempotency key: %v", err)
+	}
+}
+
+// ReleaseIdempotencyKey releases a key reserved for a write that failed, so that the write can be retried.
+func (context *DatabaseContext) ReleaseIdempotencyKey(ctx context.Context, key *IdempotencyKey) {
+	if err := context.MetadataStore.Delete(key.docID); err != nil && !base.IsDocNotFoundError(err) {
+		base.InfofCtx(ctx, base.KeyCRUD, "Unable to release idempotency key: %v", err)
+	}
+}
+
+func (context *DatabaseContext) idempotencyKeyTTL() time.Duration {
+	if ttl := context.Options.IdempotencyKeyTTL; ttl > 0 {
+		return ttl
+	}
+	return DefaultIdempotencyKeyTTL
+}
@@ -0,0 +1,70 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReserveIdempotencyKey(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+
+	key, err := db.NewIdempotencyKey("alice", "_default._default", "key1", "hash1")
+	require.NoError(t, err)
+	response, err := db.ReserveIdempotencyKey(ctx, key)
+	require.NoError(t, err)
+	assert.Nil(t, response)
+
+	// The key is in use until the write's response is recorded
+	_, err = db.ReserveIdempotencyKey(ctx, key)
+	var codedErr *base.CodedError
+	require.True(t, errors.As(err, &codedErr))
+	assert.Equal(t, base.ErrorCodeIdempotencyKeyInUse, codedErr.Code)
+
+	db.RecordIdempotentResponse(ctx, key, http.StatusCreated, map[string]string{"Etag": `"1-abc"`}, []byte(`{"ok":true}`))
+	response, err = db.ReserveIdempotencyKey(ctx, key)
+	require.NoError(t, err)
+	require.NotNil(t, response)
+	assert.Equal(t, http.StatusCreated, response.Status)
+	assert.Equal(t, `"1-abc"`, response.Headers["Etag"])
+	assert.Equal(t, []byte(`{"ok":true}`), response.Body)
+
+	// A different request can't reuse the key
+	otherRequest, err := db.NewIdempotencyKey("alice", "_default._default", "key1", "hash2")
+	require.NoError(t, err)
+	_, err = db.ReserveIdempotencyKey(ctx, otherRequest)
+	require.True(t, errors.As(err, &codedErr))
+	assert.Equal(t, base.ErrorCodeIdempotencyKeyReused, codedErr.Code)
+
+	// Keys are scoped to the user and keyspace
+	for _, scoped := range [][2]string{{"bob", "_default._default"}, {"alice", "scope1.collection1"}} {
+		otherKey, err := db.NewIdempotencyKey(scoped[0], scoped[1], "key1", "hash1")
+		require.NoError(t, err)
+		response, err = db.ReserveIdempotencyKey(ctx, otherKey)
+		require.NoError(t, err)
+		assert.Nil(t, response)
+	}
+
+	// A released key can be reserved again
+	otherKey, err := db.NewIdempotencyKey("alice", "_default._default", "key2", "hash1")
+	require.NoError(t, err)
+	_, err = db.ReserveIdempotencyKey(ctx, otherKey)
+	require.NoError(t, err)
+	db.ReleaseIdempotencyKey(ctx, otherKey)
+	response, err = db.ReserveIdempotencyKey(ctx, otherKey)
+	require.NoError(t, err)
+	assert.Nil(t, response)
+}
@@ -30,6 +30,28 @@ const (
 	ImportOnDemand                    // On-demand import. Reattempt import on cas write failure of the imported doc until either the import succeeds, or existing doc is an SG write.
 )
 
+const (
+	BinaryImportAttachmentName = "binary"                   // Name of the attachment holding the content of an imported binary document
+	BinaryImportDigestProperty = "binary_digest"            // Body property of an imported binary document holding the digest of its content
+	binaryImportContentType    = "application/octet-stream" // Content type of the attachment holding the content of an imported binary document
+)
+
+// binaryImportAttachment returns the attachment metadata and data for the content of an imported binary document.
+func binaryImportAttachment(docID string, content []byte, generation int) (AttachmentsMeta, AttachmentData) {
+	digest := Sha1DigestKey(content)
+	meta := AttachmentsMeta{
+		BinaryImportAttachmentName: map[string]interface{}{
+			"stub":         true,
+			"digest":       digest,
+			"revpos":       generation,
+			"ver":          AttVersion2,
+			"length":       len(content),
+			"content_type": binaryImportContentType,
+		},
+	}
+	return meta, AttachmentData{MakeAttachmentKey(AttVersion2, docID, digest): content}
+}
+
 // Imports a document that was written by someone other than sync gateway, given the existing state of the doc in raw bytes
 func (db *DatabaseCollectionWithUser) ImportDocRaw(ctx context.Context, docid string, value []byte, xattrValue []byte, userXattrValue []byte, isDelete bool, cas uint64, expiry *uint32, mode ImportMode) (docOut *Document, err error) {
 
@@ -60,6 +82,37 @@ func (db *DatabaseCollectionWithUser) ImportDocRaw(ctx context.Context, docid st
 	return db.importDoc(ctx, docid, body, expiry, isDelete, existingBucketDoc, mode)
 }
 
+// ImportBinaryDocRaw imports a binary (non-JSON) document written by someone other than sync gateway, given the existing
+// binary content of the doc.  The binary content is stored as an attachment, and the document is replaced by a JSON
+// document holding the digest of the content, so that it can be replicated to clients.
+func (db *DatabaseCollectionWithUser) ImportBinaryDocRaw(ctx context.Context, docid string, value []byte, cas uint64, expiry *uint32) (docOut *Document, err error) {
+	if len(value) > MaxAttachmentSizeBytes {
+		return nil, ErrAttachmentTooLarge
+	}
+	body := Body{BinaryImportDigestProperty: Sha1DigestKey(value)}
+	rawBody, err := base.JSONMarshalCanonical(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// The document is imported as though the JSON body was the existing document body.  Binary documents are only
+	// imported from the feed, so the write is cancelled on cas mismatch if the binary document has been updated since.
+	existingBucketDoc := &sgbucket.BucketDocument{
+		Body: rawBody,
+		Cas:  cas,
+	}
+
+	docOut, err = db.importDocWithBinaryContent(ctx, docid, body, value, expiry, false, existingBucketDoc, ImportFromFeed)
+	if err != nil && err != base.ErrImportCasFailure {
+		// A cas mismatch on write rereads the document, which fails to unmarshal when it's been updated with new binary
+		// content.  This is reported as a cas failure, as the update will be imported based on its own mutation.
+		if _, currentCas, getErr := db.dataStore.GetRaw(docid); getErr == nil && currentCas != cas {
+			return nil, base.ErrImportCasFailure
+		}
+	}
+	return docOut, err
+}
+
 // Import a document, given the existing state of the doc in *document format.
 func (db *DatabaseCollectionWithUser) ImportDoc(ctx context.Context, docid string, existingDoc *Document, isDelete bool, expiry *uint32, mode ImportMode) (docOut *Document, err error) {
 
@@ -102,6 +155,12 @@ func (db *DatabaseCollectionWithUser) ImportDoc(ctx context.Context, docid strin
 //	existingDoc - bytes/cas/expiry of the  document to be imported (including xattr when available)
 //	mode - ImportMode - ImportFromFeed or ImportOnDemand
 func (db *DatabaseCollectionWithUser) importDoc(ctx context.Context, docid string, body Body, expiry *uint32, isDelete bool, existingDoc *sgbucket.BucketDocument, mode ImportMode) (docOut *Document, err error) {
+	return db.importDocWithBinaryContent(ctx, docid, body, nil, expiry, isDelete, existingDoc, mode)
+}
+
+// importDocWithBinaryContent imports a document as importDoc, replacing the document's attachments with
+// binaryContent when set.
+func (db *DatabaseCollectionWithUser) importDocWithBinaryContent(ctx context.Context, docid string, body Body, binaryContent []byte, expiry *uint32, isDelete bool, existingDoc *sgbucket.BucketDocument, mode ImportMode) (docOut *Document, err error) {
 
 	base.DebugfCtx(ctx, base.KeyImport, "Attempting to import doc %q...", base.UD(docid))
 	importStartTime := time.Now()
@@ -312,14 +371,19 @@ func (db *DatabaseCollectionWithUser) importDoc(ctx context.Context, docid strin
 
 		// Existing attachments are preserved while importing an updated body - we don't (currently) support changing
 		// attachments through anything but SG. When importing a "delete" mutation, existing attachments are removed
-		// to ensure obsolete attachments are removed from the bucket.
+		// to ensure obsolete attachments are removed from the bucket. The content of a binary document replaces any
+		// existing attachments.
+		var attachmentData AttachmentData
 		if isDelete {
 			doc.SyncData.Attachments = nil
+		} else if binaryContent != nil && shouldGenerateNewRev {
+			generation, _ := ParseRevID(ctx, newDoc.RevID)
+			newDoc.DocAttachments, attachmentData = binaryImportAttachment(newDoc.ID, binaryContent, generation)
 		} else {
 			newDoc.DocAttachments = doc.SyncData.Attachments
 		}
 
-		return newDoc, nil, !shouldGenerateNewRev, updatedExpiry, nil
+		return newDoc, attachmentData, !shouldGenerateNewRev, updatedExpiry, nil
 	})
 
 	switch err {
@@ -36,6 +36,7 @@ type importListener struct {
 	checkpointPrefix string            // DCP checkpoint key prefix
 	loggingCtx       context.Context   // ctx for logging on event callbacks
 	importDestKey    string            // cbgt index name
+	quarantine       *importQuarantine // Documents quarantined after repeatedly failing import
 }
 
 // NewImportListener constructs an object to start an import feed.
@@ -48,6 +49,7 @@ func NewImportListener(ctx context.Context, checkpointPrefix string, dbContext *
 		checkpointPrefix: checkpointPrefix,
 		collections:      make(map[uint32]DatabaseCollectionWithUser),
 		importStats:      dbContext.DbStats.SharedBucketImport(),
+		quarantine:       dbContext.importQuarantine,
 		loggingCtx:       ctx,
 		metadataKeys:     dbContext.MetadataKeys,
 		terminator:       make(chan bool),
@@ -71,6 +73,10 @@ func (il *importListener) StartImportFeed(dbContext *DatabaseContext) (err error
 	}
 
 	for collectionID, collection := range dbContext.CollectionByID {
+		// Federated collections aren't imported, as the import feed only runs on the database's bucket
+		if collection.IsFederated() {
+			continue
+		}
 		il.collections[collectionID] = DatabaseCollectionWithUser{
 			DatabaseCollection: collection,
 			user:               nil, // admin
@@ -157,8 +163,9 @@ func (il *importListener) ProcessFeedEvent(event sgbucket.FeedEvent) (shouldPers
 		return true
 	}
 
-	// If this is a binary document we can ignore, but update checkpoint to avoid reprocessing upon restart
-	if event.DataType == base.MemcachedDataTypeRaw {
+	// If this is a binary document we can ignore unless binary documents are imported, but update checkpoint to avoid
+	// reprocessing upon restart
+	if event.DataType == base.MemcachedDataTypeRaw && !collection.importBinaryDocs() {
 		base.InfofCtx(ctx, base.KeyImport, "Ignoring binary mutation event for %s.", base.UD(docID))
 		return true
 	}
@@ -181,15 +188,23 @@ func (il *importListener) ImportFeedEvent(ctx context.Context, collection *Datab
 		il.dbStats.ImportProcessCompute.Add(stat)
 	}()
 
-	syncData, rawBody, rawXattr, rawUserXattr, err := UnmarshalDocumentSyncDataFromFeed(event.Value, event.DataType, collection.userXattrKey(), false)
-	if err != nil {
-		if err == base.ErrEmptyMetadata {
-			base.WarnfCtx(ctx, "Unexpected empty metadata when processing feed event.  docid: %s opcode: %v datatype:%v", base.UD(event.Key), event.Opcode, event.DataType)
-		} else {
-			base.WarnfCtx(ctx, "Found sync metadata, but unable to unmarshal for feed document %q.  Will not be imported.  Error: %v", base.UD(event.Key), err)
+	var syncData *SyncData
+	var rawBody, rawXattr, rawUserXattr []byte
+	if event.DataType == base.MemcachedDataTypeRaw {
+		// Binary documents don't have sync metadata, so are always imported
+		rawBody = event.Value
+	} else {
+		var err error
+		syncData, rawBody, rawXattr, rawUserXattr, err = UnmarshalDocumentSyncDataFromFeed(event.Value, event.DataType, collection.userXattrKey(), false)
+		if err != nil {
+			if err == base.ErrEmptyMetadata {
+				base.WarnfCtx(ctx, "Unexpected empty metadata when processing feed event.  docid: %s opcode: %v datatype:%v", base.UD(event.Key), event.Opcode, event.DataType)
+			} else {
+				base.WarnfCtx(ctx, "Found sync metadata, but unable to unmarshal for feed document %q.  Will not be imported.  Error: %v", base.UD(event.Key), err)
+			}
+			il.importStats.ImportErrorCount.Add(1)
+			return
 		}
-		il.importStats.ImportErrorCount.Add(1)
-		return
 	}
 
 	var isSGWrite bool
@@ -218,7 +233,24 @@ func (il *importListener) ImportFeedEvent(ctx context.Context, collection *Datab
 		default:
 		}
 
-		_, err := collection.ImportDocRaw(ctx, docID, rawBody, rawXattr, rawUserXattr, isDelete, event.Cas, &event.Expiry, ImportFromFeed)
+		var quarantineKey string
+		if il.quarantine != nil {
+			quarantineKey = importQuarantineKey(collection.ScopeName, collection.Name, docID)
+			if il.quarantine.isQuarantined(ctx, quarantineKey) {
+				base.DebugfCtx(ctx, base.KeyImport, "Not importing mutation - document %s is quarantined.", base.UD(docID))
+				il.importStats.ImportQuarantineSkippedCount.Add(1)
+				return
+			}
+		}
+
+		err := importDocRawWithRecovery(ctx, collection, docID, event, rawBody, rawXattr, rawUserXattr, isDelete)
+		if il.quarantine != nil {
+			if err == nil {
+				il.quarantine.recordSuccess(quarantineKey)
+			} else if importFailureCounts(err) {
+				il.quarantine.recordFailure(ctx, collection.DatabaseCollection, docID, err)
+			}
+		}
 		if err != nil {
 			if err == base.ErrImportCasFailure {
 				base.DebugfCtx(ctx, base.KeyImport, "Not importing mutation - document %s has been subsequently updated and will be imported based on that mutation.", base.UD(docID))
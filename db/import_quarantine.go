@@ -0,0 +1,313 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	sgbucket "github.com/couchbase/sg-bucket"
+	"github.com/couchbase/sync_gateway/base"
+)
+
+const (
+	// DefaultImportQuarantineThreshold is the default number of consecutive import failures for a document before it
+	// is quarantined.
+	DefaultImportQuarantineThreshold = 5
+
+	// maxImportQuarantineEntries limits the number of quarantined documents, to bound the size of the quarantine document.
+	maxImportQuarantineEntries = 1000
+
+	// maxImportFailureKeys limits the number of documents with failures being tracked before they are quarantined.
+	maxImportFailureKeys = 10000
+)
+
+// ImportQuarantineEntry is a document that was quarantined after repeatedly failing import.  Mutations to the document
+// aren't imported until it is retried.
+type ImportQuarantineEntry struct {
+	DocID         string    `json:"doc_id"`
+	Scope         string    `json:"scope"`
+	Collection    string    `json:"collection"`
+	Failures      int       `json:"failures"`   // Number of consecutive failures before the document was quarantined
+	LastError     string    `json:"last_error"` // Error returned by the last failed import
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+// importQuarantineDoc is the metadata document storing the quarantined documents for a database, keyed by
+// importQuarantineKey.
+type importQuarantineDoc struct {
+	Entries map[string]*ImportQuarantineEntry `json:"entries"`
+}
+
+// importQuarantineKey returns the key identifying a document in the quarantine.  Scope and collection names can't
+// contain ':', so the key is unambiguous.
+func importQuarantineKey(scope, collection, docID string) string {
+	return scope + "." + collection + ":" + docID
+}
+
+// importQuarantine tracks consecutive import failures per document, and quarantines documents that reach the threshold
+// so that a document that can't be imported doesn't repeatedly consume import processing.  Quarantined documents are
+// persisted in the metadata store, and cached on each node.
+type importQuarantine struct {
+	lock          sync.Mutex
+	threshold     int
+	failures      map[string]int      // Consecutive failures for documents that haven't been quarantined
+	quarantined   map[string]struct{} // Documents quarantined by this node, or found quarantined when loaded
+	metadataStore base.DataStore
+	docKey        string
+	importStats   *base.SharedBucketImportStats
+}
+
+func newImportQuarantine(dbContext *DatabaseContext) *importQuarantine {
+	return &importQuarantine{
+		threshold:     int(dbContext.Options.ImportOptions.QuarantineThreshold),
+		failures:      make(map[string]int),
+		quarantined:   make(map[string]struct{}),
+		metadataStore: dbContext.MetadataStore,
+		docKey:        dbContext.MetadataKeys.ImportQuarantineKey(),
+		importStats:   dbContext.DbStats.SharedBucketImport(),
+	}
+}
+
+// load populates the cache of quarantined documents from the metadata store.
+func (q *importQuarantine) load() error {
+	quarantineDoc, err := getImportQuarantineDoc(q.metadataStore, q.docKey)
+	if err != nil {
+		return err
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for key := range quarantineDoc.Entries {
+		q.quarantined[key] = struct{}{}
+	}
+	q.setQuarantineCount(len(quarantineDoc.Entries))
+	return nil
+}
+
+// isQuarantined returns true if the document is quarantined, and shouldn't be imported.  Documents in the cache are
+// checked against the metadata store, in case they've been retried on another node.
+func (q *importQuarantine) isQuarantined(ctx context.Context, key string) bool {
+	q.lock.Lock()
+	_, ok := q.quarantined[key]
+	q.lock.Unlock()
+	if !ok {
+		return false
+	}
+
+	quarantineDoc, err := getImportQuarantineDoc(q.metadataStore, q.docKey)
+	if err != nil {
+		base.WarnfCtx(ctx, "Unable to check import quarantine for %q, treating as quarantined: %v", base.UD(key), err)
+		return true
+	}
+	if _, ok := quarantineDoc.Entries[key]; ok {
+		return true
+	}
+	q.lock.Lock()
+	delete(q.quarantined, key)
+	q.lock.Unlock()
+	return false
+}
+
+// recordSuccess resets the consecutive failure count for a document.
+func (q *importQuarantine) recordSuccess(key string) {
+	q.lock.Lock()
+	delete(q.failures, key)
+	q.lock.Unlock()
+}
+
+// recordFailure increments the consecutive failure count for a document, and quarantines it once the threshold has been
+// reached.
+func (q *importQuarantine) recordFailure(ctx context.Context, collection *DatabaseCollection, docID string, importErr error) {
+	if q.threshold <= 0 {
+		return
+	}
+	key := importQuarantineKey(collection.ScopeName, collection.Name, docID)
+
+	q.lock.Lock()
+	if len(q.failures) >= maxImportFailureKeys {
+		q.failures = make(map[string]int)
+	}
+	q.failures[key]++
+	failures := q.failures[key]
+	if failures < q.threshold {
+		q.lock.Unlock()
+		return
+	}
+	delete(q.failures, key)
+	q.lock.Unlock()
+
+	entry := &ImportQuarantineEntry{
+		DocID:         docID,
+		Scope:         collection.ScopeName,
+		Collection:    collection.Name,
+		Failures:      failures,
+		LastError:     importErr.Error(),
+		QuarantinedAt: time.Now().UTC(),
+	}
+	count, err := q.update(func(quarantineDoc *importQuarantineDoc) (bool, error) {
+		if _, ok := quarantineDoc.Entries[key]; ok {
+			return false, nil
+		}
+		if len(quarantineDoc.Entries) >= maxImportQuarantineEntries {
+			return false, errors.New("import quarantine is full")
+		}
+		quarantineDoc.Entries[key] = entry
+		return true, nil
+	})
+	if err != nil {
+		base.WarnfCtx(ctx, "Document %q failed import %d consecutive times but could not be quarantined: %v", base.UD(docID), failures, err)
+		return
+	}
+
+	q.lock.Lock()
+	q.quarantined[key] = struct{}{}
+	q.lock.Unlock()
+	q.setQuarantineCount(count)
+	base.WarnfCtx(ctx, "Document %q failed import %d consecutive times and has been quarantined. Mutations to the document won't be imported until it is retried. Last error: %v", base.UD(docID), failures, importErr)
+}
+
+// remove removes a document from the quarantine.  Returns false if the document wasn't quarantined.
+func (q *importQuarantine) remove(key string) (bool, error) {
+	found := false
+	count, err := q.update(func(quarantineDoc *importQuarantineDoc) (bool, error) {
+		_, found = quarantineDoc.Entries[key]
+		delete(quarantineDoc.Entries, key)
+		return found, nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	q.lock.Lock()
+	delete(q.quarantined, key)
+	delete(q.failures, key)
+	q.lock.Unlock()
+	if found {
+		q.setQuarantineCount(count)
+	}
+	return found, nil
+}
+
+// setQuarantineCount updates the quarantined document stat, when import stats are being tracked.
+func (q *importQuarantine) setQuarantineCount(count int) {
+	if q.importStats != nil {
+		q.importStats.ImportQuarantineCount.Set(int64(count))
+	}
+}
+
+// update applies updateFn to the quarantine document, writing it if updateFn returns true.  Returns the number of
+// quarantined documents after the update.
+func (q *importQuarantine) update(updateFn func(*importQuarantineDoc) (bool, error)) (count int, err error) {
+	_, err = q.metadataStore.Update(q.docKey, 0, func(current []byte) (updated []byte, expiry *uint32, delete bool, err error) {
+		quarantineDoc := &importQuarantineDoc{}
+		if len(current) > 0 {
+			if err := base.JSONUnmarshal(current, quarantineDoc); err != nil {
+				return nil, nil, false, err
+			}
+		}
+		if quarantineDoc.Entries == nil {
+			quarantineDoc.Entries = make(map[string]*ImportQuarantineEntry)
+		}
+		changed, err := updateFn(quarantineDoc)
+		count = len(quarantineDoc.Entries)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if !changed {
+			return nil, nil, false, base.ErrUpdateCancel
+		}
+		updated, err = base.JSONMarshal(quarantineDoc)
+		return updated, nil, false, err
+	})
+	if err == base.ErrUpdateCancel {
+		err = nil
+	}
+	return count, err
+}
+
+// getImportQuarantineDoc returns the quarantine document from the metadata store, or an empty document if there is none.
+func getImportQuarantineDoc(metadataStore base.DataStore, docKey string) (*importQuarantineDoc, error) {
+	quarantineDoc := &importQuarantineDoc{}
+	_, err := metadataStore.Get(docKey, quarantineDoc)
+	if err != nil && !base.IsDocNotFoundError(err) {
+		return nil, err
+	}
+	if quarantineDoc.Entries == nil {
+		quarantineDoc.Entries = make(map[string]*ImportQuarantineEntry)
+	}
+	return quarantineDoc, nil
+}
+
+// GetImportQuarantine returns the documents quarantined after repeatedly failing import, ordered by the time they were
+// quarantined.
+func (context *DatabaseContext) GetImportQuarantine(ctx context.Context) ([]ImportQuarantineEntry, error) {
+	quarantineDoc, err := getImportQuarantineDoc(context.MetadataStore, context.MetadataKeys.ImportQuarantineKey())
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]ImportQuarantineEntry, 0, len(quarantineDoc.Entries))
+	for _, entry := range quarantineDoc.Entries {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].QuarantinedAt.Before(entries[j].QuarantinedAt) })
+	return entries, nil
+}
+
+// RetryQuarantinedImport removes a document from the import quarantine and attempts to import it.  Returns a 404 error
+// if the document isn't quarantined, or the import error if the document still can't be imported.
+func (c *DatabaseCollection) RetryQuarantinedImport(ctx context.Context, docID string) error {
+	quarantine := c.dbCtx.importQuarantine
+	if quarantine == nil {
+		// Import isn't running on this node, update the persisted quarantine directly
+		quarantine = newImportQuarantine(c.dbCtx)
+	}
+	found, err := quarantine.remove(importQuarantineKey(c.ScopeName, c.Name, docID))
+	if err != nil {
+		return err
+	}
+	if !found {
+		return base.HTTPErrorf(http.StatusNotFound, "Document is not quarantined")
+	}
+
+	base.InfofCtx(ctx, base.KeyImport, "Retrying import of quarantined document %q", base.UD(docID))
+	_, err = c.GetDocument(ctx, docID, DocUnmarshalSync)
+	return err
+}
+
+// importFailureCounts returns true if an error returned by import indicates a problem processing the document that
+// should count towards quarantine, rather than the import being cancelled or the document being rejected.
+func importFailureCounts(err error) bool {
+	switch err {
+	case base.ErrImportCasFailure, base.ErrImportCancelledFilter, base.ErrImportCancelledPurged, base.ErrImportCancelled:
+		return false
+	}
+	status, _ := base.ErrorAsHTTPStatus(err)
+	return status != http.StatusForbidden
+}
+
+// importDocRawWithRecovery imports a document from the feed, converting a panic during import into an error so that a
+// single document can't stop import processing.
+func importDocRawWithRecovery(ctx context.Context, collection *DatabaseCollectionWithUser, docID string, event sgbucket.FeedEvent, rawBody, rawXattr, rawUserXattr []byte, isDelete bool) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			base.WarnfCtx(ctx, "PANIC importing doc %q: %v", base.UD(docID), r)
+			err = base.HTTPErrorf(http.StatusInternalServerError, "Panic during import: %v", r)
+		}
+	}()
+	if event.DataType == base.MemcachedDataTypeRaw && !isDelete {
+		_, err = collection.ImportBinaryDocRaw(ctx, docID, rawBody, event.Cas, &event.Expiry)
+		return err
+	}
+	_, err = collection.ImportDocRaw(ctx, docID, rawBody, rawXattr, rawUserXattr, isDelete, event.Cas, &event.Expiry, ImportFromFeed)
+	return err
+}
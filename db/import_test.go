@@ -423,6 +423,46 @@ func TestImportNullDocRaw(t *testing.T) {
 	assert.True(t, importedDoc == nil, "Expected no imported doc")
 }
 
+// Imports a binary document, which is replaced by a JSON document holding the binary content as an attachment
+func TestImportBinaryDocRaw(t *testing.T) {
+	if !base.TestUseXattrs() {
+		t.Skip("This test only works with XATTRS enabled")
+	}
+
+	base.SetUpTestLogging(t, base.LevelInfo, base.KeyImport)
+
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+	key := "TestImportBinaryDocRaw"
+	content := []byte{0x00, 0x01, 0xfe, 0xff}
+	cas, err := collection.dataStore.WriteCas(key, 0, 0, 0, content, sgbucket.Raw)
+	require.NoError(t, err)
+
+	// Import is cancelled if the binary document has been updated since
+	_, err = collection.ImportBinaryDocRaw(ctx, key, []byte("stale"), cas+1, nil)
+	assert.Equal(t, base.ErrImportCasFailure, err)
+
+	importedDoc, err := collection.ImportBinaryDocRaw(ctx, key, content, cas, nil)
+	require.NoError(t, err)
+	digest := Sha1DigestKey(content)
+	assert.Equal(t, Body{BinaryImportDigestProperty: digest}, importedDoc.Body(ctx))
+	require.Contains(t, importedDoc.Attachments, BinaryImportAttachmentName)
+	meta := importedDoc.Attachments[BinaryImportAttachmentName].(map[string]interface{})
+	assert.Equal(t, digest, meta["digest"])
+	assert.Equal(t, "application/octet-stream", meta["content_type"])
+
+	// The original key now holds the JSON document, and the binary content is available as the attachment
+	var body Body
+	_, err = collection.dataStore.Get(key, &body)
+	require.NoError(t, err)
+	assert.Equal(t, digest, body[BinaryImportDigestProperty])
+	attachment, err := collection.GetAttachment(MakeAttachmentKey(AttVersion2, key, digest))
+	require.NoError(t, err)
+	assert.Equal(t, content, attachment)
+}
+
 func assertXattrSyncMetaRevGeneration(t *testing.T, dataStore base.DataStore, key string, expectedRevGeneration int) {
 	xattr := map[string]interface{}{}
 	_, err := dataStore.GetWithXattr(base.TestCtx(t), key, base.SyncXattrName, "", nil, &xattr, nil)
@@ -580,3 +620,68 @@ func TestImportInvalidMetadata(t *testing.T) {
 	}, 1)
 	require.Equal(t, int64(0), db.DbStats.SharedBucketImport().ImportCount.Value())
 }
+
+func TestImportQuarantine(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+	quarantine := newImportQuarantine(db.DatabaseContext)
+	quarantine.threshold = 3
+	db.importQuarantine = quarantine
+
+	docID := "poison"
+	key := importQuarantineKey(collection.ScopeName, collection.Name, docID)
+	importErr := base.HTTPErrorf(500, "Exception in JS sync function")
+
+	// Failures below the threshold don't quarantine the document, and are reset by a successful import
+	quarantine.recordFailure(ctx, collection.DatabaseCollection, docID, importErr)
+	quarantine.recordFailure(ctx, collection.DatabaseCollection, docID, importErr)
+	quarantine.recordSuccess(key)
+	quarantine.recordFailure(ctx, collection.DatabaseCollection, docID, importErr)
+	quarantine.recordFailure(ctx, collection.DatabaseCollection, docID, importErr)
+	assert.False(t, quarantine.isQuarantined(ctx, key))
+	entries, err := db.GetImportQuarantine(ctx)
+	require.NoError(t, err)
+	assert.Len(t, entries, 0)
+
+	// Reaching the threshold quarantines the document
+	quarantine.recordFailure(ctx, collection.DatabaseCollection, docID, importErr)
+	assert.True(t, quarantine.isQuarantined(ctx, key))
+	entries, err = db.GetImportQuarantine(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, docID, entries[0].DocID)
+	assert.Equal(t, collection.ScopeName, entries[0].Scope)
+	assert.Equal(t, collection.Name, entries[0].Collection)
+	assert.Equal(t, 3, entries[0].Failures)
+	assert.Equal(t, importErr.Error(), entries[0].LastError)
+
+	// A new node loads the quarantined documents from the metadata store
+	reloaded := newImportQuarantine(db.DatabaseContext)
+	require.NoError(t, reloaded.load())
+	assert.True(t, reloaded.isQuarantined(ctx, key))
+
+	// Retrying removes the document from the quarantine on every node
+	_, _, err = collection.Put(ctx, docID, Body{"valid": true})
+	require.NoError(t, err)
+	require.NoError(t, collection.RetryQuarantinedImport(ctx, docID))
+	assert.False(t, quarantine.isQuarantined(ctx, key))
+	assert.False(t, reloaded.isQuarantined(ctx, key))
+	entries, err = db.GetImportQuarantine(ctx)
+	require.NoError(t, err)
+	assert.Len(t, entries, 0)
+
+	// Retrying a document that isn't quarantined returns not found
+	err = collection.RetryQuarantinedImport(ctx, docID)
+	status, _ := base.ErrorAsHTTPStatus(err)
+	assert.Equal(t, 404, status)
+}
+
+func TestImportFailureCounts(t *testing.T) {
+	assert.False(t, importFailureCounts(base.ErrImportCasFailure))
+	assert.False(t, importFailureCounts(base.ErrImportCancelledFilter))
+	assert.False(t, importFailureCounts(base.HTTPErrorf(403, "forbidden")))
+	assert.True(t, importFailureCounts(base.HTTPErrorf(500, "Exception in JS sync function")))
+	assert.True(t, importFailureCounts(base.ErrEmptyDocument))
+}
@@ -0,0 +1,197 @@
+//  Copyright 2023-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included
+//  in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+//  in that file, in accordance with the Business Source License, use of this
+//  software will be governed by the Apache License, Version 2.0, included in
+//  the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// MaxMaintenanceWindowDuration is the longest a single maintenance window can stay open for.
+const MaxMaintenanceWindowDuration = 7 * 24 * time.Hour
+
+// maintenanceWindowPollInterval is how often a deferred task re-checks whether a maintenance window has opened.
+var maintenanceWindowPollInterval = 30 * time.Second
+
+// MaintenanceWindow is a recurring period in which heavy background tasks are allowed to run. A window opens at each
+// time matching its cron schedule, and stays open for its duration.
+type MaintenanceWindow struct {
+	schedule *base.CronSchedule
+	duration time.Duration
+	location *time.Location
+}
+
+// NewMaintenanceWindow returns a window opening at times matching the cron expression in the given location (UTC
+// when nil), and staying open for duration.
+func NewMaintenanceWindow(schedule string, duration time.Duration, location *time.Location) (*MaintenanceWindow, error) {
+	cronSchedule, err := base.ParseCronSchedule(schedule)
+	if err != nil {
+		return nil, err
+	}
+	if duration < time.Minute || duration > MaxMaintenanceWindowDuration {
+		return nil, fmt.Errorf("maintenance window duration must be between 1 minute and %v", MaxMaintenanceWindowDuration)
+	}
+	if location == nil {
+		location = time.UTC
+	}
+	return &MaintenanceWindow{schedule: cronSchedule, duration: duration, location: location}, nil
+}
+
+// Contains returns true if the window is open at t.
+func (w *MaintenanceWindow) Contains(t time.Time) bool {
+	t = t.In(w.location).Truncate(time.Minute)
+	// The window is open if it was opened by a schedule match within the last duration
+	for start := t; t.Sub(start) < w.duration; start = start.Add(-time.Minute) {
+		if w.schedule.Matches(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaintenanceOverride forces a database's maintenance window open or closed, regardless of its schedule.
+type MaintenanceOverride string
+
+const (
+	MaintenanceOverrideNone   MaintenanceOverride = ""       // The configured windows apply
+	MaintenanceOverrideOpen   MaintenanceOverride = "open"   // Background tasks run immediately
+	MaintenanceOverrideClosed MaintenanceOverride = "closed" // Background tasks are deferred
+)
+
+// MaintenanceSchedule determines when a database's heavy background tasks (compaction, resync and cache cleanup) can
+// run. With no windows configured tasks can always run, unless overridden. Methods are safe to call on a nil
+// MaintenanceSchedule, which is always open.
+type MaintenanceSchedule struct {
+	windows []*MaintenanceWindow
+
+	lock           sync.RWMutex
+	override       MaintenanceOverride
+	overrideExpiry time.Time     // When the override stops applying. Zero if it applies until cleared
+	overrideSet    chan struct{} // Closed when the override changes, to wake tasks waiting for a window
+}
+
+// MaintenanceScheduleStatus is the current state of a database's maintenance schedule.
+type MaintenanceScheduleStatus struct {
+	InWindow       bool                `json:"in_window"`
+	NumWindows     int                 `json:"num_windows"`
+	Override       MaintenanceOverride `json:"override,omitempty"`
+	OverrideExpiry *time.Time          `json:"override_expiry,omitempty"`
+}
+
+// NewMaintenanceSchedule returns a schedule for the given windows.
+func NewMaintenanceSchedule(windows []*MaintenanceWindow) *MaintenanceSchedule {
+	return &MaintenanceSchedule{
+		windows:     windows,
+		overrideSet: make(chan struct{}),
+	}
+}
+
+// InWindow returns true if heavy background tasks can currently run.
+func (s *MaintenanceSchedule) InWindow() bool {
+	if s == nil {
+		return true
+	}
+	now := time.Now()
+	switch s.currentOverride(now) {
+	case MaintenanceOverrideOpen:
+		return true
+	case MaintenanceOverrideClosed:
+		return false
+	}
+	if len(s.windows) == 0 {
+		return true
+	}
+	for _, window := range s.windows {
+		if window.Contains(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// currentOverride returns the override in effect at now.
+func (s *MaintenanceSchedule) currentOverride(now time.Time) MaintenanceOverride {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if !s.overrideExpiry.IsZero() && now.After(s.overrideExpiry) {
+		return MaintenanceOverrideNone
+	}
+	return s.override
+}
+
+// SetOverride forces the schedule open or closed for duration, or until cleared when duration is zero. Setting
+// MaintenanceOverrideNone clears any override.
+func (s *MaintenanceSchedule) SetOverride(override MaintenanceOverride, duration time.Duration) error {
+	switch override {
+	case MaintenanceOverrideNone, MaintenanceOverrideOpen, MaintenanceOverrideClosed:
+	default:
+		return fmt.Errorf("unknown maintenance window override %q - must be %q, %q or empty", override, MaintenanceOverrideOpen, MaintenanceOverrideClosed)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.override = override
+	s.overrideExpiry = time.Time{}
+	if override != MaintenanceOverrideNone && duration > 0 {
+		s.overrideExpiry = time.Now().Add(duration)
+	}
+	close(s.overrideSet)
+	s.overrideSet = make(chan struct{})
+	return nil
+}
+
+// Status returns the current state of the schedule.
+func (s *MaintenanceSchedule) Status() MaintenanceScheduleStatus {
+	status := MaintenanceScheduleStatus{InWindow: s.InWindow()}
+	if s == nil {
+		return status
+	}
+	status.NumWindows = len(s.windows)
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.overrideExpiry.IsZero() || time.Now().Before(s.overrideExpiry) {
+		status.Override = s.override
+		if status.Override != MaintenanceOverrideNone && !s.overrideExpiry.IsZero() {
+			expiry := s.overrideExpiry.UTC()
+			status.OverrideExpiry = &expiry
+		}
+	}
+	return status
+}
+
+// WaitForWindow blocks until heavy background tasks can run. Returns false if the terminator was closed or ctx was
+// cancelled while waiting, in which case the task should stop. taskName identifies the deferred task in logging.
+func (s *MaintenanceSchedule) WaitForWindow(ctx context.Context, taskName string, terminator *base.SafeTerminator) bool {
+	if s.InWindow() {
+		return true
+	}
+	base.InfofCtx(ctx, base.KeyAll, "Deferring %s until the database's maintenance window opens", taskName)
+	ticker := time.NewTicker(maintenanceWindowPollInterval)
+	defer ticker.Stop()
+	for {
+		s.lock.RLock()
+		overrideSet := s.overrideSet
+		s.lock.RUnlock()
+		select {
+		case <-terminator.Done():
+			return false
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		case <-overrideSet:
+		}
+		if s.InWindow() {
+			base.InfofCtx(ctx, base.KeyAll, "Maintenance window open, resuming %s", taskName)
+			return true
+		}
+	}
+}
@@ -0,0 +1,93 @@
+//  Copyright 2023-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included
+//  in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+//  in that file, in accordance with the Business Source License, use of this
+//  software will be governed by the Apache License, Version 2.0, included in
+//  the file licenses/APL2.txt.
+
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceWindowContains(t *testing.T) {
+	window, err := NewMaintenanceWindow("30 23 * * *", 2*time.Hour, time.UTC)
+	require.NoError(t, err)
+
+	start := time.Date(2023, time.June, 5, 23, 30, 0, 0, time.UTC)
+	assert.False(t, window.Contains(start.Add(-time.Second)))
+	assert.True(t, window.Contains(start))
+	// Windows can span midnight
+	assert.True(t, window.Contains(start.Add(time.Hour)))
+	assert.True(t, window.Contains(start.Add(2*time.Hour-time.Second)))
+	assert.False(t, window.Contains(start.Add(2*time.Hour)))
+
+	// The schedule is evaluated in the window's location
+	location := time.FixedZone("UTC+2", 2*60*60)
+	window, err = NewMaintenanceWindow("0 2 * * *", time.Hour, location)
+	require.NoError(t, err)
+	assert.True(t, window.Contains(time.Date(2023, time.June, 5, 0, 30, 0, 0, time.UTC)))
+	assert.False(t, window.Contains(time.Date(2023, time.June, 5, 2, 30, 0, 0, time.UTC)))
+
+	_, err = NewMaintenanceWindow("0 2 * * *", 0, nil)
+	assert.Error(t, err)
+	_, err = NewMaintenanceWindow("0 2 * *", time.Hour, nil)
+	assert.Error(t, err)
+}
+
+func TestMaintenanceScheduleOverride(t *testing.T) {
+	// A window that's never open while the test runs
+	now := time.Now().UTC()
+	window, err := NewMaintenanceWindow(fmt.Sprintf("0 %d * * *", (now.Hour()+12)%24), time.Hour, time.UTC)
+	require.NoError(t, err)
+	schedule := NewMaintenanceSchedule([]*MaintenanceWindow{window})
+	assert.False(t, schedule.InWindow())
+
+	// Tasks waiting for the window resume when it's overridden open
+	ctx := base.TestCtx(t)
+	terminator := base.NewSafeTerminator()
+	defer terminator.Close()
+	resumed := make(chan bool)
+	go func() {
+		resumed <- schedule.WaitForWindow(ctx, "test task", terminator)
+	}()
+	require.NoError(t, schedule.SetOverride(MaintenanceOverrideOpen, time.Hour))
+	select {
+	case ok := <-resumed:
+		assert.True(t, ok)
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "task waiting for maintenance window wasn't resumed by override")
+	}
+	status := schedule.Status()
+	assert.True(t, status.InWindow)
+	assert.Equal(t, MaintenanceOverrideOpen, status.Override)
+	require.NotNil(t, status.OverrideExpiry)
+
+	require.NoError(t, schedule.SetOverride(MaintenanceOverrideNone, 0))
+	assert.False(t, schedule.InWindow())
+	assert.Error(t, schedule.SetOverride("sometimes", 0))
+
+	// With no windows configured, tasks can run unless overridden closed
+	schedule = NewMaintenanceSchedule(nil)
+	assert.True(t, schedule.InWindow())
+	require.NoError(t, schedule.SetOverride(MaintenanceOverrideClosed, 0))
+	assert.False(t, schedule.InWindow())
+
+	// Waiting tasks stop when terminated
+	go func() {
+		resumed <- schedule.WaitForWindow(ctx, "test task", terminator)
+	}()
+	terminator.Close()
+	assert.False(t, <-resumed)
+
+	var nilSchedule *MaintenanceSchedule
+	assert.True(t, nilSchedule.InWindow())
+}
@@ -0,0 +1,110 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// pushResumeTokenTTL is how long after it was issued a push resume token can be presented by a client.
+var pushResumeTokenTTL = time.Hour
+
+// maxPushResumeTokens is the number of push resume tokens held by a database, after which the oldest are dropped.
+const maxPushResumeTokens = 10000
+
+// pushResumeBatch is a batch of changes proposed by a client, identified by a push resume token.
+type pushResumeBatch struct {
+	user         string
+	collectionID uint32
+	revs         map[string]string // Current revID of each document once the batch has been pushed, by docID
+	issued       time.Time
+}
+
+// pushResumeTokens holds the batches of proposed changes that clients can present a token for on reconnect, to skip
+// reproposing changes the server already has.
+type pushResumeTokens struct {
+	lock    sync.Mutex
+	batches map[string]*pushResumeBatch
+	order   []string // Tokens in the order issued, to drop the oldest
+}
+
+func newPushResumeTokens() *pushResumeTokens {
+	return &pushResumeTokens{
+		batches: make(map[string]*pushResumeBatch),
+	}
+}
+
+// issue returns a new token for a batch of proposed changes.
+func (t *pushResumeTokens) issue(batch *pushResumeBatch) (string, error) {
+	token, err := base.GenerateRandomID()
+	if err != nil {
+		return "", err
+	}
+	batch.issued = time.Now()
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.batches[token] = batch
+	t.order = append(t.order, token)
+	for len(t.order) > maxPushResumeTokens {
+		delete(t.batches, t.order[0])
+		t.order = t.order[1:]
+	}
+	return token, nil
+}
+
+// get returns the batch for a token issued to the user for the collection, or nil if the token is unknown or expired.
+func (t *pushResumeTokens) get(token, user string, collectionID uint32) *pushResumeBatch {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	batch, ok := t.batches[token]
+	if !ok || batch.user != user || batch.collectionID != collectionID {
+		return nil
+	}
+	if time.Since(batch.issued) > pushResumeTokenTTL {
+		delete(t.batches, token)
+		return nil
+	}
+	return batch
+}
+
+// issuePushResumeToken returns a token for the proposed changes that weren't rejected, to be returned to the client
+// with the proposeChanges response.
+func (bh *blipHandler) issuePushResumeToken(revs map[string]string) string {
+	token, err := bh.db.pushResumeTokens.issue(&pushResumeBatch{
+		user:         bh.userName,
+		collectionID: bh.collection.GetCollectionID(),
+		revs:         revs,
+	})
+	if err != nil {
+		base.WarnfCtx(bh.loggingCtx, "Unable to issue push resume token: %v", err)
+		return ""
+	}
+	return token
+}
+
+// isPushResumeTokenValid returns true if token identifies a batch of changes proposed by the user in this collection,
+// and every document in it is still at the proposed revision.  The client can then skip reproposing the batch.
+func (bh *blipHandler) isPushResumeTokenValid(ctx context.Context, token string) bool {
+	batch := bh.db.pushResumeTokens.get(token, bh.userName, bh.collection.GetCollectionID())
+	if batch == nil {
+		return false
+	}
+	for docID, revID := range batch.revs {
+		if status, _ := bh.collection.CheckProposedRev(ctx, docID, revID, ""); status != ProposedRev_Exists {
+			base.DebugfCtx(ctx, base.KeySync, "Push resume token no longer valid, %s is not at rev %s", base.UD(docID), revID)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushResumeTokens(t *testing.T) {
+	tokens := newPushResumeTokens()
+	batch := &pushResumeBatch{user: "alice", collectionID: 8, revs: map[string]string{"doc1": "1-a"}}
+	token, err := tokens.issue(batch)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+
+	assert.Equal(t, batch, tokens.get(token, "alice", 8))
+
+	// Tokens are only valid for the user and collection they were issued for
+	assert.Nil(t, tokens.get(token, "bob", 8))
+	assert.Nil(t, tokens.get(token, "alice", 9))
+	assert.Nil(t, tokens.get("unknown", "alice", 8))
+
+	// Expired tokens are dropped
+	batch.issued = time.Now().Add(-pushResumeTokenTTL - time.Second)
+	assert.Nil(t, tokens.get(token, "alice", 8))
+	assert.NotContains(t, tokens.batches, token)
+}
+
+func TestPushResumeTokensDropOldest(t *testing.T) {
+	tokens := newPushResumeTokens()
+	first, err := tokens.issue(&pushResumeBatch{user: "alice"})
+	require.NoError(t, err)
+	for i := 0; i < maxPushResumeTokens; i++ {
+		_, err := tokens.issue(&pushResumeBatch{user: "alice"})
+		require.NoError(t, err)
+	}
+	assert.Len(t, tokens.batches, maxPushResumeTokens)
+	assert.Nil(t, tokens.get(first, "alice", 0))
+}
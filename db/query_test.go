@@ -533,3 +533,29 @@ func TestQueryChannelsActiveOnlyWithLimit(t *testing.T) {
 	require.Len(t, entries, 50)
 	checkFlags(entries)
 }
+
+// TestQueryChannelsCancelled validates that a channel query made with a cancelled context returns the context's error,
+// and is counted as a cancelled backfill.
+func TestQueryChannelsCancelled(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+	collection.ChannelMapper = channels.NewChannelMapper(ctx, channels.DocChannelsSyncFunction, db.Options.JavascriptTimeout)
+
+	for i := 1; i <= 10; i++ {
+		_, _, err := collection.Put(ctx, "doc"+strconv.Itoa(i), Body{"channels": []string{"ABC"}})
+		require.NoError(t, err)
+	}
+	cancelledStat := db.DbStats.Cache().ChannelCacheBackfillsCancelled
+	entries, err := collection.getChangesInChannelFromQuery(ctx, "ABC", 0, 100, 0, false)
+	require.NoError(t, err)
+	require.Len(t, entries, 10)
+	assert.Equal(t, int64(0), cancelledStat.Value())
+
+	queryCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	entries, err = collection.getChangesInChannelFromQuery(queryCtx, "ABC", 0, 100, 0, false)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, entries)
+	assert.Equal(t, int64(1), cancelledStat.Value())
+}
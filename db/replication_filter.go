@@ -0,0 +1,76 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"strings"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// DefaultReplicationFilterTypeProperty is the document property matched against a named filter's DocTypes by default.
+const DefaultReplicationFilterTypeProperty = "type"
+
+// ReplicationFilter is a named filter defined in the database config, which clients reference by name in the filter
+// property of subChanges. This lets the documents replicated to clients be changed server-side. A change is sent
+// when it matches all of the filter's criteria.
+type ReplicationFilter struct {
+	Channels        base.Set // If set, only changes in these channels are sent
+	DocIDPrefixes   []string // If set, only changes to documents whose IDs start with one of these prefixes are sent
+	DocTypeProperty string   // Document property matched against DocTypes. Defaults to "type"
+	DocTypes        base.Set // If set, only changes to documents whose type property is one of these values are sent
+}
+
+// includesDocID returns true if a change to the given document passes the filter's docID prefixes. Safe to call on a
+// nil filter.
+func (f *ReplicationFilter) includesDocID(docID string) bool {
+	if f == nil || len(f.DocIDPrefixes) == 0 {
+		return true
+	}
+	for _, prefix := range f.DocIDPrefixes {
+		if strings.HasPrefix(docID, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// includesChange returns true if the change passes the filter. Matching on document type requires the document's
+// body, which is retrieved through the revision cache. Deletions, removals and revocations are always sent when their
+// document ID matches, as the client needs them to remove documents it already has. Safe to call on a nil filter.
+func (f *ReplicationFilter) includesChange(ctx context.Context, collection *DatabaseCollectionWithUser, change *ChangeEntry) bool {
+	if f == nil {
+		return true
+	}
+	if !f.includesDocID(change.ID) {
+		return false
+	}
+	if len(f.DocTypes) == 0 || change.Deleted || change.allRemoved || change.Revoked || len(change.Changes) == 0 {
+		return true
+	}
+
+	docRev, err := collection.revisionCache.Get(ctx, change.ID, change.Changes[0]["rev"], RevCacheOmitBody, RevCacheOmitDelta)
+	if err != nil {
+		// Send the change, and let the client's request for the revision handle the error
+		base.DebugfCtx(ctx, base.KeySync, "Unable to get %q to apply replication filter, sending change: %v", base.UD(change.ID), err)
+		return true
+	}
+	var body map[string]interface{}
+	if err := base.JSONUnmarshal(docRev.BodyBytes, &body); err != nil {
+		base.DebugfCtx(ctx, base.KeySync, "Unable to unmarshal %q to apply replication filter, sending change: %v", base.UD(change.ID), err)
+		return true
+	}
+	typeProperty := f.DocTypeProperty
+	if typeProperty == "" {
+		typeProperty = DefaultReplicationFilterTypeProperty
+	}
+	docType, ok := body[typeProperty].(string)
+	return ok && f.DocTypes.Contains(docType)
+}
@@ -0,0 +1,69 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"testing"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicationFilterIncludesChange(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+
+	docs := map[string]Body{
+		"order:1":    {"type": "order"},
+		"order:2":    {"type": "quote"},
+		"order:3":    {"kind": "order"},
+		"invoice:1":  {"type": "order"},
+		"order:noty": {},
+	}
+	revIDs := make(map[string]string, len(docs))
+	for docID, body := range docs {
+		revID, _, err := collection.Put(ctx, docID, body)
+		require.NoError(t, err)
+		revIDs[docID] = revID
+	}
+	change := func(docID string) *ChangeEntry {
+		return &ChangeEntry{ID: docID, Changes: []ChangeRev{{"rev": revIDs[docID]}}}
+	}
+
+	var nilFilter *ReplicationFilter
+	assert.True(t, nilFilter.includesChange(ctx, collection, change("order:1")))
+
+	filter := &ReplicationFilter{
+		DocIDPrefixes: []string{"order:"},
+		DocTypes:      base.SetOf("order"),
+	}
+	assert.True(t, filter.includesChange(ctx, collection, change("order:1")))
+	assert.False(t, filter.includesChange(ctx, collection, change("order:2")))
+	assert.False(t, filter.includesChange(ctx, collection, change("order:3")))
+	assert.False(t, filter.includesChange(ctx, collection, change("invoice:1")))
+	assert.False(t, filter.includesChange(ctx, collection, change("order:noty")))
+
+	// Removals are sent regardless of type, as long as the doc ID matches
+	removal := change("order:2")
+	removal.allRemoved = true
+	assert.True(t, filter.includesChange(ctx, collection, removal))
+	removal = change("invoice:1")
+	removal.Revoked = true
+	assert.False(t, filter.includesChange(ctx, collection, removal))
+
+	// The type property can be configured
+	filter = &ReplicationFilter{
+		DocTypeProperty: "kind",
+		DocTypes:        base.SetOf("order"),
+	}
+	assert.True(t, filter.includesChange(ctx, collection, change("order:3")))
+	assert.False(t, filter.includesChange(ctx, collection, change("order:1")))
+}
@@ -0,0 +1,290 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/couchbase/sync_gateway/auth"
+	"github.com/couchbase/sync_gateway/base"
+)
+
+const (
+	QuotaReads  = "reads"  // Revisions sent to a user
+	QuotaWrites = "writes" // Revisions pushed by a user
+
+	// quotaFlushInterval is how often usage counted on this node is added to the usage stored in the metadata store,
+	// and so how long other nodes may take to see it.
+	quotaFlushInterval = 5 * time.Second
+
+	// quotaUsageExpiry is the expiry in seconds of stored usage, long enough for the previous day's usage to still be
+	// viewed.
+	quotaUsageExpiry = 2 * 24 * 60 * 60
+
+	// quotaDayFormat formats the UTC date identifying a daily quota window.
+	quotaDayFormat = "2006-01-02"
+)
+
+// RequestQuotaOptions limits the number of revisions each user can be sent and push per day.  Admin requests aren't
+// subject to quotas.
+type RequestQuotaOptions struct {
+	DailyReads  uint64        // Revisions a user can be sent per day, 0 for unlimited
+	DailyWrites uint64        // Revisions a user can push per day, 0 for unlimited
+	Throttle    time.Duration // If set, revisions over quota are delayed by this duration rather than rejected
+}
+
+// RequestQuotaUsage is a user's usage of their quotas for a day.
+type RequestQuotaUsage struct {
+	Username    string `json:"username"`
+	Day         string `json:"day"`
+	Reads       uint64 `json:"reads"`
+	Writes      uint64 `json:"writes"`
+	DailyReads  uint64 `json:"daily_reads,omitempty"`
+	DailyWrites uint64 `json:"daily_writes,omitempty"`
+}
+
+type requestQuotaKey struct {
+	day      string
+	username string
+	quota    string
+}
+
+// requestQuotaUsage is a user's usage of a quota on a day, as known to this node.
+type requestQuotaUsage struct {
+	loaded  bool   // Whether stored has been read from the metadata store
+	stored  uint64 // Usage in the metadata store as of the last flush, including usage on other nodes
+	pending uint64 // Usage on this node not yet added to the metadata store
+}
+
+// requestQuotas counts each user's daily reads and writes, and enforces the database's quotas.  Usage is counted in
+// memory and periodically added to counters in the metadata store shared by all nodes, so a user may briefly exceed a
+// quota by the usage on other nodes not yet flushed.
+type requestQuotas struct {
+	lock          sync.Mutex
+	options       RequestQuotaOptions
+	metadataStore base.DataStore
+	metaKeys      *base.MetadataKeys
+	dbStats       *base.DatabaseStats
+	usage         map[requestQuotaKey]*requestQuotaUsage
+	now           func() time.Time // Testing seam
+}
+
+func newRequestQuotas(dbContext *DatabaseContext) *requestQuotas {
+	return &requestQuotas{
+		options:       dbContext.Options.RequestQuotaOptions,
+		metadataStore: dbContext.MetadataStore,
+		metaKeys:      dbContext.MetadataKeys,
+		dbStats:       dbContext.DbStats.Database(),
+		usage:         make(map[requestQuotaKey]*requestQuotaUsage),
+		now:           time.Now,
+	}
+}
+
+// enabled returns true if a quota is set, and so usage needs to be counted.
+func (q *requestQuotas) enabled() bool {
+	return q.options.DailyReads > 0 || q.options.DailyWrites > 0
+}
+
+func (q *requestQuotas) limit(quota string) uint64 {
+	if quota == QuotaReads {
+		return q.options.DailyReads
+	}
+	return q.options.DailyWrites
+}
+
+func (q *requestQuotas) today() string {
+	return q.now().UTC().Format(quotaDayFormat)
+}
+
+// quotaUsername returns the name a user's usage is counted under, or empty for admin requests.
+func quotaUsername(user auth.User) string {
+	if user == nil {
+		return ""
+	}
+	if user.Name() == "" {
+		return base.GuestUsername
+	}
+	return user.Name()
+}
+
+// check returns an error if the user has reached the quota for today.  When throttling, the request is delayed
+// instead.  Failure to read usage isn't treated as the quota being reached.
+func (q *requestQuotas) check(ctx context.Context, username, quota string) error {
+	limit := q.limit(quota)
+	if limit == 0 || username == "" {
+		return nil
+	}
+	used, err := q.used(username, quota)
+	if err != nil {
+		base.WarnfCtx(ctx, "Unable to read %s quota usage for user %s: %v", quota, base.UD(username), err)
+		return nil
+	}
+	if used < limit {
+		return nil
+	}
+
+	if q.options.Throttle > 0 {
+		q.dbStats.QuotaThrottledCount.Add(1)
+		base.DebugfCtx(ctx, base.KeyAll, "User %s over daily %s quota of %d, throttling for %v", base.UD(username), quota, limit, q.options.Throttle)
+		select {
+		case <-time.After(q.options.Throttle):
+		case <-ctx.Done():
+		}
+		return nil
+	}
+	q.dbStats.QuotaRejectedCount.Add(1)
+	return base.HTTPErrorf(http.StatusTooManyRequests, "Daily %s quota of %d exceeded", quota, limit)
+}
+
+// record counts a read or write by the user.
+func (q *requestQuotas) record(username, quota string) {
+	if q.limit(quota) == 0 || username == "" {
+		return
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q._getUsage(requestQuotaKey{day: q.today(), username: username, quota: quota}).pending++
+}
+
+// used returns the user's usage of the quota today, reading the stored usage on first use.
+func (q *requestQuotas) used(username, quota string) (uint64, error) {
+	key := requestQuotaKey{day: q.today(), username: username, quota: quota}
+	q.lock.Lock()
+	usage := q._getUsage(key)
+	if usage.loaded {
+		used := usage.stored + usage.pending
+		q.lock.Unlock()
+		return used, nil
+	}
+	q.lock.Unlock()
+
+	stored, err := base.GetCounter(q.metadataStore, q.metaKeys.QuotaUsageKey(key.day, key.quota, key.username))
+	if err != nil {
+		return 0, err
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	usage = q._getUsage(key)
+	if !usage.loaded {
+		usage.stored = stored
+		usage.loaded = true
+	}
+	return usage.stored + usage.pending, nil
+}
+
+// _getUsage returns the usage for key, creating it if not found.  Requires the lock to be held.
+func (q *requestQuotas) _getUsage(key requestQuotaKey) *requestQuotaUsage {
+	usage, ok := q.usage[key]
+	if !ok {
+		usage = &requestQuotaUsage{}
+		q.usage[key] = usage
+	}
+	return usage
+}
+
+// flush adds usage counted on this node to the usage stored in the metadata store, and picks up usage flushed by
+// other nodes.  Usage without pending usage on this node is dropped, so that it's read again from the metadata store
+// on next use, picking up usage on other nodes and resets.  Usage for previous days is dropped once flushed.
+func (q *requestQuotas) flush(ctx context.Context) {
+	today := q.today()
+	q.lock.Lock()
+	pending := make(map[requestQuotaKey]uint64)
+	for key, usage := range q.usage {
+		if usage.pending > 0 {
+			pending[key] = usage.pending
+			usage.pending = 0
+		} else {
+			delete(q.usage, key)
+		}
+	}
+	q.lock.Unlock()
+
+	for key, amount := range pending {
+		stored, err := q.metadataStore.Incr(q.metaKeys.QuotaUsageKey(key.day, key.quota, key.username), amount, amount, quotaUsageExpiry)
+		q.lock.Lock()
+		usage := q._getUsage(key)
+		if err != nil {
+			base.WarnfCtx(ctx, "Unable to store %s quota usage for user %s: %v", key.quota, base.UD(key.username), err)
+			usage.pending += amount
+		} else {
+			usage.stored = stored
+			usage.loaded = true
+			if key.day != today && usage.pending == 0 {
+				delete(q.usage, key)
+			}
+		}
+		q.lock.Unlock()
+	}
+}
+
+// getUsage returns the user's usage of their quotas today, including usage not yet flushed on this node.
+func (q *requestQuotas) getUsage(ctx context.Context, username string) (*RequestQuotaUsage, error) {
+	q.flush(ctx)
+	day := q.today()
+	reads, err := base.GetCounter(q.metadataStore, q.metaKeys.QuotaUsageKey(day, QuotaReads, username))
+	if err != nil {
+		return nil, err
+	}
+	writes, err := base.GetCounter(q.metadataStore, q.metaKeys.QuotaUsageKey(day, QuotaWrites, username))
+	if err != nil {
+		return nil, err
+	}
+	return &RequestQuotaUsage{
+		Username:    username,
+		Day:         day,
+		Reads:       reads,
+		Writes:      writes,
+		DailyReads:  q.options.DailyReads,
+		DailyWrites: q.options.DailyWrites,
+	}, nil
+}
+
+// resetUsage clears the user's usage of their quotas today.  Other nodes pick up the reset when they next flush.
+func (q *requestQuotas) resetUsage(ctx context.Context, username string) error {
+	day := q.today()
+	q.lock.Lock()
+	for _, quota := range []string{QuotaReads, QuotaWrites} {
+		delete(q.usage, requestQuotaKey{day: day, username: username, quota: quota})
+	}
+	q.lock.Unlock()
+
+	for _, quota := range []string{QuotaReads, QuotaWrites} {
+		err := q.metadataStore.Delete(q.metaKeys.QuotaUsageKey(day, quota, username))
+		if err != nil && !base.IsDocNotFoundError(err) {
+			return err
+		}
+	}
+	base.InfofCtx(ctx, base.KeyAll, "Reset request quota usage for user %s", base.UD(username))
+	return nil
+}
+
+// GetRequestQuotaUsage returns the user's usage of the database's request quotas today.
+func (context *DatabaseContext) GetRequestQuotaUsage(ctx context.Context, username string) (*RequestQuotaUsage, error) {
+	return context.requestQuotas.getUsage(ctx, username)
+}
+
+// ResetRequestQuotaUsage clears the user's usage of the database's request quotas today.
+func (context *DatabaseContext) ResetRequestQuotaUsage(ctx context.Context, username string) error {
+	return context.requestQuotas.resetUsage(ctx, username)
+}
+
+// CheckRequestQuota returns an error if the database's user has reached the quota for today, or when throttling,
+// delays the request instead.  Admin requests aren't subject to quotas.
+func (db *Database) CheckRequestQuota(ctx context.Context, quota string) error {
+	return db.requestQuotas.check(ctx, quotaUsername(db.user), quota)
+}
+
+// RecordRequestQuota counts a read or write by the database's user towards their quota.
+func (db *Database) RecordRequestQuota(quota string) {
+	db.requestQuotas.record(quotaUsername(db.user), quota)
+}
@@ -0,0 +1,131 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// requireQuotaExceeded requires err to be the error returned when a quota has been reached.
+func requireQuotaExceeded(t *testing.T, err error) {
+	require.Error(t, err)
+	httpErr, ok := err.(*base.HTTPError)
+	require.True(t, ok, "expected HTTPError, got %T", err)
+	require.Equal(t, http.StatusTooManyRequests, httpErr.Status)
+}
+
+func TestRequestQuotas(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+
+	quotas := db.requestQuotas
+	quotas.options = RequestQuotaOptions{DailyReads: 2, DailyWrites: 1}
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	quotas.now = func() time.Time { return now }
+
+	// Admin requests aren't subject to quotas
+	for i := 0; i < 3; i++ {
+		require.NoError(t, quotas.check(ctx, "", QuotaReads))
+		quotas.record("", QuotaReads)
+	}
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, quotas.check(ctx, "alice", QuotaReads))
+		quotas.record("alice", QuotaReads)
+	}
+	err := quotas.check(ctx, "alice", QuotaReads)
+	requireQuotaExceeded(t, err)
+	assert.Equal(t, int64(1), db.DbStats.Database().QuotaRejectedCount.Value())
+
+	// Quotas are counted separately for each user and quota
+	require.NoError(t, quotas.check(ctx, "bob", QuotaReads))
+	require.NoError(t, quotas.check(ctx, "alice", QuotaWrites))
+	quotas.record("alice", QuotaWrites)
+	requireQuotaExceeded(t, quotas.check(ctx, "alice", QuotaWrites))
+
+	usage, err := db.GetRequestQuotaUsage(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, RequestQuotaUsage{Username: "alice", Day: "2023-06-01", Reads: 2, Writes: 1, DailyReads: 2, DailyWrites: 1}, *usage)
+
+	// Usage stored by another node is picked up when this node next reads it
+	other := newRequestQuotas(db.DatabaseContext)
+	other.options = quotas.options
+	other.now = quotas.now
+	requireQuotaExceeded(t, other.check(ctx, "alice", QuotaReads))
+
+	// And usage flushed by another node is picked up once this node next flushes, even without usage of its own
+	require.NoError(t, quotas.check(ctx, "bob", QuotaReads))
+	other.record("bob", QuotaReads)
+	other.record("bob", QuotaReads)
+	other.flush(ctx)
+	quotas.flush(ctx)
+	requireQuotaExceeded(t, quotas.check(ctx, "bob", QuotaReads))
+
+	// Usage is counted from zero the next day, and the previous day's usage is dropped once flushed
+	now = now.Add(24 * time.Hour)
+	require.NoError(t, quotas.check(ctx, "alice", QuotaReads))
+	quotas.flush(ctx)
+	quotas.lock.Lock()
+	for key := range quotas.usage {
+		assert.Equal(t, "2023-06-02", key.day)
+	}
+	quotas.lock.Unlock()
+}
+
+func TestRequestQuotasThrottle(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+
+	quotas := db.requestQuotas
+	quotas.options = RequestQuotaOptions{DailyWrites: 1, Throttle: 10 * time.Millisecond}
+
+	quotas.record("alice", QuotaWrites)
+	start := time.Now()
+	require.NoError(t, quotas.check(ctx, "alice", QuotaWrites))
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+	assert.Equal(t, int64(1), db.DbStats.Database().QuotaThrottledCount.Value())
+	assert.Equal(t, int64(0), db.DbStats.Database().QuotaRejectedCount.Value())
+}
+
+func TestResetRequestQuotaUsage(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+
+	quotas := db.requestQuotas
+	quotas.options = RequestQuotaOptions{DailyReads: 1}
+
+	// Resetting a user with no usage isn't an error
+	require.NoError(t, db.ResetRequestQuotaUsage(ctx, "alice"))
+
+	quotas.record("alice", QuotaReads)
+	quotas.flush(ctx)
+	requireQuotaExceeded(t, quotas.check(ctx, "alice", QuotaReads))
+
+	require.NoError(t, db.ResetRequestQuotaUsage(ctx, "alice"))
+	require.NoError(t, quotas.check(ctx, "alice", QuotaReads))
+	usage, err := db.GetRequestQuotaUsage(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), usage.Reads)
+
+	// A reset on another node is picked up once this node next flushes
+	quotas.record("alice", QuotaReads)
+	quotas.flush(ctx)
+	requireQuotaExceeded(t, quotas.check(ctx, "alice", QuotaReads))
+	other := newRequestQuotas(db.DatabaseContext)
+	other.options = quotas.options
+	require.NoError(t, other.resetUsage(ctx, "alice"))
+	quotas.flush(ctx)
+	require.NoError(t, quotas.check(ctx, "alice", QuotaReads))
+}
@@ -0,0 +1,158 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// SetDiff lists the names added to and removed from a set.
+type SetDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// IsEmpty returns true if nothing was added or removed.
+func (d SetDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+// diffSets returns the names in after but not before, and in before but not after, in sorted order.
+func diffSets(before, after base.Set) SetDiff {
+	var diff SetDiff
+	for name := range after {
+		if !before.Contains(name) {
+			diff.Added = append(diff.Added, name)
+		}
+	}
+	for name := range before {
+		if !after.Contains(name) {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	return diff
+}
+
+// DocumentResyncResult describes the changes made by re-running the sync function on a single document.
+type DocumentResyncResult struct {
+	DocID      string             `json:"id"`
+	RevID      string             `json:"rev"`
+	Changed    bool               `json:"changed"`
+	Sequence   uint64             `json:"seq,omitempty"`         // Sequence assigned to the updated document, when changed
+	Channels   *SetDiff           `json:"channels,omitempty"`    // Changes to the channels of the current revision
+	Access     map[string]SetDiff `json:"access,omitempty"`      // Changes to channel grants, keyed by user or role:name
+	RoleAccess map[string]SetDiff `json:"role_access,omitempty"` // Changes to role grants, keyed by user
+}
+
+// ResyncDocument re-runs the sync function on a single document's leaf revisions, and updates its channels and access
+// grants if they've drifted from what the sync function assigns.  Unlike a full database resync this can run while
+// the database is online: an updated document is given a new sequence, so the change is picked up by the change cache
+// and the channel access of affected users and roles is recomputed.
+func (db *DatabaseCollectionWithUser) ResyncDocument(ctx context.Context, docID string) (*DocumentResyncResult, error) {
+	doc, err := db.GetDocument(ctx, docID, DocUnmarshalAll)
+	if err != nil {
+		return nil, err
+	}
+	if !doc.HasValidSyncData() {
+		return nil, base.HTTPErrorf(http.StatusNotFound, "Document is not known to Sync Gateway")
+	}
+
+	// Run the sync function against the in-memory document first, to find what would change
+	result := &DocumentResyncResult{DocID: docID, RevID: doc.CurrentRev}
+	channelsBefore := doc.currentChannels()
+	accessBefore := doc.Access.channelSets()
+	roleAccessBefore := doc.RoleAccess.channelSets()
+	resyncedDoc, _, _, _, _, err := db.getResyncedDocument(ctx, doc, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	if channelsDiff := diffSets(channelsBefore, resyncedDoc.currentChannels()); !channelsDiff.IsEmpty() {
+		result.Channels = &channelsDiff
+	}
+	result.Access = diffAccessSets(accessBefore, resyncedDoc.Access.channelSets())
+	result.RoleAccess = diffAccessSets(roleAccessBefore, resyncedDoc.RoleAccess.channelSets())
+	if result.Channels == nil && result.Access == nil && result.RoleAccess == nil {
+		base.InfofCtx(ctx, base.KeyAccess, "Resync of %q found no changes to channels or access grants", base.UD(docID))
+		return result, nil
+	}
+
+	// Rewrite the document with a new sequence.  The update re-runs the sync function, in case the document has
+	// been modified since it was read.
+	highSeq, unusedSequences, err := db.resyncDocument(ctx, docID, realDocID(docID), true, nil)
+	db.releaseSequences(ctx, unusedSequences)
+	if err == base.ErrUpdateCancel {
+		return &DocumentResyncResult{DocID: docID, RevID: doc.CurrentRev}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Changed = true
+	result.Sequence = highSeq
+
+	changedPrincipals := make([]string, 0, len(result.Access))
+	for name := range result.Access {
+		changedPrincipals = append(changedPrincipals, name)
+	}
+	changedRoleUsers := make([]string, 0, len(result.RoleAccess))
+	for name := range result.RoleAccess {
+		changedRoleUsers = append(changedRoleUsers, name)
+	}
+	db.MarkPrincipalsChanged(ctx, docID, doc.CurrentRev, changedPrincipals, changedRoleUsers, highSeq)
+	base.InfofCtx(ctx, base.KeyAccess, "Resync of %q updated channels and access grants at sequence %d", base.UD(docID), highSeq)
+	return result, nil
+}
+
+// currentChannels returns the channels the current revision of the document is in.
+func (doc *Document) currentChannels() base.Set {
+	current := make(base.Set, len(doc.Channels))
+	for name, removal := range doc.Channels {
+		if removal == nil {
+			current.Add(name)
+		}
+	}
+	return current
+}
+
+// channelSets returns a copy of the names granted to each principal, without the grant sequences.
+func (accessMap UserAccessMap) channelSets() map[string]base.Set {
+	sets := make(map[string]base.Set, len(accessMap))
+	for name, access := range accessMap {
+		sets[name] = access.AsSet()
+	}
+	return sets
+}
+
+// diffAccessSets returns the changes to the names granted to each principal, or nil if there are none.
+func diffAccessSets(before, after map[string]base.Set) map[string]SetDiff {
+	var diffs map[string]SetDiff
+	addDiff := func(principal string) {
+		if _, ok := diffs[principal]; ok {
+			return
+		}
+		if diff := diffSets(before[principal], after[principal]); !diff.IsEmpty() {
+			if diffs == nil {
+				diffs = make(map[string]SetDiff)
+			}
+			diffs[principal] = diff
+		}
+	}
+	for principal := range before {
+		addDiff(principal)
+	}
+	for principal := range after {
+		addDiff(principal)
+	}
+	return diffs
+}
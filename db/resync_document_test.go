@@ -0,0 +1,60 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResyncDocument(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+
+	collection.ChannelMapper = channels.NewChannelMapper(ctx, `function(doc) { channel(doc.channel); }`, db.Options.JavascriptTimeout)
+	revID, doc, err := collection.Put(ctx, "doc1", Body{"channel": "A", "owner": "alice"})
+	require.NoError(t, err)
+	initialSeq := doc.Sequence
+
+	// Channels already match the sync function, so the document isn't updated
+	result, err := collection.ResyncDocument(ctx, "doc1")
+	require.NoError(t, err)
+	assert.Equal(t, &DocumentResyncResult{DocID: "doc1", RevID: revID}, result)
+
+	// Change the sync function, so the document's channels and grants drift from what it assigns
+	collection.ChannelMapper = channels.NewChannelMapper(ctx, `function(doc) { channel(doc.channel, "B"); access(doc.owner, "B"); }`, db.Options.JavascriptTimeout)
+	result, err = collection.ResyncDocument(ctx, "doc1")
+	require.NoError(t, err)
+	assert.True(t, result.Changed)
+	assert.Greater(t, result.Sequence, initialSeq)
+	assert.Equal(t, &SetDiff{Added: []string{"B"}}, result.Channels)
+	assert.Equal(t, map[string]SetDiff{"alice": {Added: []string{"B"}}}, result.Access)
+	assert.Nil(t, result.RoleAccess)
+
+	doc, err = collection.GetDocument(ctx, "doc1", DocUnmarshalAll)
+	require.NoError(t, err)
+	assert.Equal(t, result.Sequence, doc.Sequence)
+	assert.Equal(t, base.SetOf("A", "B"), doc.currentChannels())
+	assert.Equal(t, base.SetOf("B"), doc.Access["alice"].AsSet())
+
+	// Resyncing again finds nothing to change
+	result, err = collection.ResyncDocument(ctx, "doc1")
+	require.NoError(t, err)
+	assert.False(t, result.Changed)
+
+	_, err = collection.ResyncDocument(ctx, "missing")
+	status, _ := base.ErrorAsHTTPStatus(err)
+	assert.Equal(t, http.StatusNotFound, status)
+}
@@ -233,6 +233,7 @@ func (c *DatabaseCollection) getOldRevisionJSON(ctx context.Context, docid strin
 	data, _, err := c.dataStore.GetRaw(oldRevisionKey(docid, revid))
 	if base.IsDocNotFoundError(err) {
 		base.DebugfCtx(ctx, base.KeyCRUD, "No old revision %q / %q", base.UD(docid), revid)
+		c.dbStats().Database().OldRevBackupMissCount.Add(1)
 		err = ErrMissing
 	}
 	if data != nil {
@@ -254,14 +255,20 @@ func (c *DatabaseCollection) getOldRevisionJSON(ctx context.Context, docid strin
 //	   - new revision stored (as duplicate), with expiry rev_max_age_seconds
 //	delta=true && shared_bucket_access=false
 //	   - old revision stored, with expiry rev_max_age_seconds
-func (db *DatabaseCollectionWithUser) backupRevisionJSON(ctx context.Context, docId, newRevId, oldRevId string, newBody []byte, oldBody []byte, newAtts AttachmentsMeta) {
+//
+// No revisions are stored when old revision backups are disabled.  Returns the revision that was stored, if any.
+func (db *DatabaseCollectionWithUser) backupRevisionJSON(ctx context.Context, docId, newRevId, oldRevId string, newBody []byte, oldBody []byte, newAtts AttachmentsMeta) (backedUpRevId string) {
+	if !db.oldRevBackupEnabled() {
+		return ""
+	}
 
 	// Without delta sync, store the old rev for in-flight replication purposes
 	if !db.deltaSyncEnabled() || db.deltaSyncRevMaxAgeSeconds() == 0 {
 		if len(oldBody) > 0 {
 			_ = db.setOldRevisionJSON(ctx, docId, oldRevId, oldBody, db.oldRevExpirySeconds())
+			return oldRevId
 		}
-		return
+		return ""
 	}
 
 	// Otherwise, store the revs for delta generation purposes, with a longer expiry
@@ -278,23 +285,53 @@ func (db *DatabaseCollectionWithUser) backupRevisionJSON(ctx context.Context, do
 			})
 			if err != nil {
 				base.WarnfCtx(ctx, "Unable to marshal new revision body during backupRevisionJSON: doc=%q rev=%q err=%v ", base.UD(docId), newRevId, err)
-				return
+				return ""
 			}
 		}
 		_ = db.setOldRevisionJSON(ctx, docId, newRevId, newBodyWithAtts, db.deltaSyncRevMaxAgeSeconds())
 
-		// Refresh the expiry on the previous revision backup
-		_ = db.refreshPreviousRevisionBackup(ctx, docId, oldRevId, oldBody, db.deltaSyncRevMaxAgeSeconds())
-		return
+		// Refresh the expiry on the previous revision backup, unless only the current revision's backup is kept
+		if db.oldRevBackupMaxPerDoc() != 1 {
+			_ = db.refreshPreviousRevisionBackup(ctx, docId, oldRevId, oldBody, db.deltaSyncRevMaxAgeSeconds())
+		}
+		return newRevId
 	}
 
 	// Non-xattr only need to store the previous revision, as all writes come through SG
 	if len(oldBody) > 0 {
 		_ = db.setOldRevisionJSON(ctx, docId, oldRevId, oldBody, db.deltaSyncRevMaxAgeSeconds())
+		return oldRevId
+	}
+	return ""
+}
+
+// pruneOldRevisionBackups removes the backup of the revision max_per_doc generations older than the given backed up
+// revision, so that only the backups of the revisions leading up to it are kept.  Backups of older revisions were
+// removed as the document was updated, or have expired.
+func (db *DatabaseCollectionWithUser) pruneOldRevisionBackups(ctx context.Context, doc *Document, backedUpRevId string) {
+	maxPerDoc := db.oldRevBackupMaxPerDoc()
+	if maxPerDoc == 0 {
+		return
+	}
+	history, err := doc.History.getHistory(backedUpRevId)
+	if err != nil || uint32(len(history)) <= maxPerDoc {
+		return
+	}
+	prunedRevId := history[maxPerDoc]
+	err = db.dataStore.Delete(oldRevisionKey(doc.ID, prunedRevId))
+	if err == nil {
+		base.DebugfCtx(ctx, base.KeyCRUD, "Removed revision body backup %q/%q over max_per_doc %d", base.UD(doc.ID), prunedRevId, maxPerDoc)
+	} else if !base.IsDocNotFoundError(err) {
+		base.WarnfCtx(ctx, "Unable to remove revision body backup: doc=%q rev=%q err=%v", base.UD(doc.ID), prunedRevId, err)
 	}
 }
 
+// setOldRevisionJSON stores a backup of a revision body, which expires after the given number of seconds.  Nothing is
+// stored when old revision backups are disabled.
 func (db *DatabaseCollectionWithUser) setOldRevisionJSON(ctx context.Context, docid string, revid string, body []byte, expiry uint32) error {
+	if !db.oldRevBackupEnabled() {
+		return nil
+	}
 
 	// Setting the binary flag isn't sufficient to make N1QL ignore the doc - the binary flag is only used by the SDKs.
 	// To ensure it's not available via N1QL, need to prefix the raw bytes with non-JSON data.
@@ -305,6 +342,8 @@ func (db *DatabaseCollectionWithUser) setOldRevisionJSON(ctx context.Context, do
 	err := db.dataStore.SetRaw(oldRevisionKey(docid, revid), expiry, nil, nonJSONBytes)
 	if err == nil {
 		base.DebugfCtx(ctx, base.KeyCRUD, "Backed up revision body %q/%q (%d bytes, ttl:%d)", base.UD(docid), revid, len(body), expiry)
+		db.dbStats().Database().OldRevBackupCount.Add(1)
+		db.dbStats().Database().OldRevBackupBytes.Add(int64(len(body)))
 	} else {
 		base.WarnfCtx(ctx, "setOldRevisionJSON failed: doc=%q rev=%q err=%v", base.UD(docid), revid, err)
 	}
@@ -104,3 +104,23 @@ func (rc *BypassRevisionCache) Remove(docID, revID string) {
 func (rc *BypassRevisionCache) UpdateDelta(ctx context.Context, docID, revID string, toDelta RevisionDelta) {
 	// no-op
 }
+
+// RefreshCurrentRev is a no-op for a BypassRevisionCache
+func (rc *BypassRevisionCache) RefreshCurrentRev(docID, currentRevID string) {
+	// no-op
+}
+
+// Pin is a no-op for a BypassRevisionCache
+func (rc *BypassRevisionCache) Pin(docID string) {
+	// no-op
+}
+
+// Unpin is a no-op for a BypassRevisionCache
+func (rc *BypassRevisionCache) Unpin(docID string) {
+	// no-op
+}
+
+// PinnedDocIDs always returns an empty list for a BypassRevisionCache, as it doesn't cache revisions
+func (rc *BypassRevisionCache) PinnedDocIDs() []string {
+	return []string{}
+}
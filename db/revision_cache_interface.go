@@ -49,8 +49,21 @@ type RevisionCache interface {
 	// Remove eliminates a revision in the cache.
 	Remove(docID, revID string)
 
-	// UpdateDelta stores the given toDelta value in the given rev if cached
+	// UpdateDelta stores the given toDelta value as the delta from the given rev
 	UpdateDelta(ctx context.Context, docID, revID string, toDelta RevisionDelta)
+
+	// RefreshCurrentRev is called when currentRevID becomes the current revision of the document. Removes cached deltas
+	// to other revisions, and replaces the revision cached for a pinned document.
+	RefreshCurrentRev(docID, currentRevID string)
+
+	// Pin pins the current revision of the document in the cache, exempting it from eviction.
+	Pin(docID string)
+
+	// Unpin releases a document pinned by Pin.
+	Unpin(docID string)
+
+	// PinnedDocIDs returns the IDs of the pinned documents, in sorted order.
+	PinnedDocIDs() []string
 }
 
 const (
@@ -65,8 +78,9 @@ var _ RevisionCache = &LRURevisionCache{}
 var _ RevisionCache = &ShardedLRURevisionCache{}
 var _ RevisionCache = &BypassRevisionCache{}
 
-// NewRevisionCache returns a RevisionCache implementation for the given config options.
-func NewRevisionCache(cacheOptions *RevisionCacheOptions, backingStore RevisionCacheBackingStore, cacheStats *base.CacheStats) RevisionCache {
+// NewRevisionCache returns a RevisionCache implementation for the given config options. collectionStats is optional,
+// and is used to track the collection's share of the revision cache's memory usage.
+func NewRevisionCache(cacheOptions *RevisionCacheOptions, backingStore RevisionCacheBackingStore, cacheStats *base.CacheStats, collectionStats *base.CollectionStats) RevisionCache {
 
 	// If cacheOptions is not passed in, use defaults
 	if cacheOptions == nil {
@@ -81,22 +95,55 @@ func NewRevisionCache(cacheOptions *RevisionCacheOptions, backingStore RevisionC
 	cacheHitStat := cacheStats.RevisionCacheHits
 	cacheMissStat := cacheStats.RevisionCacheMisses
 
+	memoryOptions := revCacheMemoryOptions{
+		maxBytes:      cacheOptions.MaxBytes,
+		dbBytesStat:   cacheStats.RevisionCacheMemoryBytes,
+		evictionsStat: cacheStats.RevisionCacheMemoryEvictions,
+	}
+	if collectionStats != nil {
+		memoryOptions.collectionBytesStat = collectionStats.RevisionCacheMemoryBytes
+	}
+
+	deltas := newDeltaCache(deltaCacheOptions{
+		expiry:        cacheOptions.DeltaExpiry,
+		maxBytes:      cacheOptions.DeltaMaxBytes,
+		bytesStat:     cacheStats.DeltaCacheMemoryBytes,
+		evictionsStat: cacheStats.DeltaCacheMemoryEvictions,
+	})
+
 	if cacheOptions.ShardCount > 1 {
-		return NewShardedLRURevisionCache(cacheOptions.ShardCount, cacheOptions.Size, backingStore, cacheHitStat, cacheMissStat)
+		revCache := NewShardedLRURevisionCache(cacheOptions.ShardCount, cacheOptions.Size, backingStore, cacheHitStat, cacheMissStat)
+		revCache.setMemoryOptions(memoryOptions)
+		revCache.setDeltaCache(deltas)
+		for _, docID := range cacheOptions.PinnedDocIDs {
+			revCache.Pin(docID)
+		}
+		return revCache
 	}
 
-	return NewLRURevisionCache(cacheOptions.Size, backingStore, cacheHitStat, cacheMissStat)
+	revCache := NewLRURevisionCache(cacheOptions.Size, backingStore, cacheHitStat, cacheMissStat)
+	revCache.setMemoryOptions(memoryOptions)
+	revCache.setDeltaCache(deltas)
+	for _, docID := range cacheOptions.PinnedDocIDs {
+		revCache.Pin(docID)
+	}
+	return revCache
 }
 
 type RevisionCacheOptions struct {
-	Size       uint32
-	ShardCount uint16
+	Size          uint32
+	ShardCount    uint16
+	MaxBytes      int64         // Maximum estimated size of the cached revisions, per collection. Zero for no limit.
+	DeltaExpiry   time.Duration // How long generated deltas are cached for. Zero for no expiry.
+	DeltaMaxBytes int64         // Maximum estimated size of the cached deltas, per collection. Zero for no limit.
+	PinnedDocIDs  []string      // Documents whose current revisions are never evicted from the cache
 }
 
 func DefaultRevisionCacheOptions() *RevisionCacheOptions {
 	return &RevisionCacheOptions{
-		Size:       DefaultRevisionCacheSize,
-		ShardCount: DefaultRevisionCacheShardCount,
+		Size:        DefaultRevisionCacheSize,
+		ShardCount:  DefaultRevisionCacheShardCount,
+		DeltaExpiry: DefaultDeltaCacheExpiry,
 	}
 }
 
@@ -106,6 +153,12 @@ type RevisionCacheBackingStore interface {
 	getRevision(ctx context.Context, doc *Document, revid string) ([]byte, Body, AttachmentsMeta, error)
 }
 
+// externalSyncMetadataLoader is implemented by backing stores that can load the sync metadata relocated by sync
+// metadata compaction, such as the channels of non-leaf revisions.
+type externalSyncMetadataLoader interface {
+	loadExternalSyncMetadata(ctx context.Context, docID string, syncData *SyncData) error
+}
+
 // DocumentRevision stored and returned by the rev cache
 type DocumentRevision struct {
 	DocID string
@@ -261,6 +314,12 @@ func revCacheLoader(ctx context.Context, backingStore RevisionCacheBackingStore,
 
 // Common revCacheLoader functionality used either during a cache miss (from revCacheLoader), or directly when retrieving current rev from cache
 func revCacheLoaderForDocument(ctx context.Context, backingStore RevisionCacheBackingStore, doc *Document, revid string) (bodyBytes []byte, body Body, history Revisions, channels base.Set, removed bool, attachments AttachmentsMeta, deleted bool, expiry *time.Time, err error) {
+	// The channels of non-leaf revisions may have been relocated by sync metadata compaction
+	if loader, ok := backingStore.(externalSyncMetadataLoader); ok && doc.needsExternalRevChannels(revid) {
+		if err := loader.loadExternalSyncMetadata(ctx, doc.ID, &doc.SyncData); err != nil {
+			return bodyBytes, body, history, channels, removed, nil, deleted, nil, err
+		}
+	}
 	if bodyBytes, body, attachments, err = backingStore.getRevision(ctx, doc, revid); err != nil {
 		// If we can't find the revision (either as active or conflicted body from the document, or as old revision body backup), check whether
 		// the revision was a channel removal. If so, we want to store as removal in the revision cache
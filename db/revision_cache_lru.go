@@ -13,6 +13,7 @@ package db
 import (
 	"container/list"
 	"context"
+	"sort"
 	"sync"
 	"time"
 
@@ -41,6 +42,22 @@ func NewShardedLRURevisionCache(shardCount uint16, capacity uint32, backingStore
 	}
 }
 
+// setMemoryOptions sets memory tracking options on each shard, dividing any memory limit evenly between them.
+func (sc *ShardedLRURevisionCache) setMemoryOptions(options revCacheMemoryOptions) {
+	shardOptions := options
+	shardOptions.maxBytes = options.maxBytes / int64(sc.numShards)
+	for _, cache := range sc.caches {
+		cache.setMemoryOptions(shardOptions)
+	}
+}
+
+// setDeltaCache sets the cache used to store deltas, shared by all shards.
+func (sc *ShardedLRURevisionCache) setDeltaCache(deltas *deltaCache) {
+	for _, cache := range sc.caches {
+		cache.setDeltaCache(deltas)
+	}
+}
+
 func (sc *ShardedLRURevisionCache) getShard(docID string) *LRURevisionCache {
 	return sc.caches[sgbucket.VBHash(docID, sc.numShards)]
 }
@@ -73,6 +90,27 @@ func (sc *ShardedLRURevisionCache) Remove(docID, revID string) {
 	sc.getShard(docID).Remove(docID, revID)
 }
 
+func (sc *ShardedLRURevisionCache) RefreshCurrentRev(docID, currentRevID string) {
+	sc.getShard(docID).RefreshCurrentRev(docID, currentRevID)
+}
+
+func (sc *ShardedLRURevisionCache) Pin(docID string) {
+	sc.getShard(docID).Pin(docID)
+}
+
+func (sc *ShardedLRURevisionCache) Unpin(docID string) {
+	sc.getShard(docID).Unpin(docID)
+}
+
+func (sc *ShardedLRURevisionCache) PinnedDocIDs() []string {
+	docIDs := []string{}
+	for _, cache := range sc.caches {
+		docIDs = append(docIDs, cache.PinnedDocIDs()...)
+	}
+	sort.Strings(docIDs)
+	return docIDs
+}
+
 // An LRU cache of document revision bodies, together with their channel access.
 type LRURevisionCache struct {
 	backingStore RevisionCacheBackingStore
@@ -82,6 +120,18 @@ type LRURevisionCache struct {
 	cacheMisses  *base.SgwIntStat
 	lock         sync.Mutex
 	capacity     uint32
+	memory       revCacheMemoryOptions
+	currentBytes int64                     // Estimated size of all values in the LRU list, guarded by lock
+	deltas       *deltaCache               // Deltas generated from cached revisions, retained independently of the revisions
+	pinnedDocs   map[string]*revCacheValue // Pinned doc IDs, and the value for the doc's current revision (nil until known), guarded by lock
+}
+
+// revCacheMemoryOptions configures the memory tracking of an LRURevisionCache.
+type revCacheMemoryOptions struct {
+	maxBytes            int64            // Maximum estimated size of the cached values, or zero for no limit
+	dbBytesStat         *base.SgwIntStat // Estimated size of the cached values across the database, shared between collections
+	collectionBytesStat *base.SgwIntStat // Estimated size of the collection's cached values
+	evictionsStat       *base.SgwIntStat // Number of values evicted to stay within maxBytes
 }
 
 // The cache payload data. Stored as the Value of a list Element.
@@ -91,13 +141,13 @@ type revCacheValue struct {
 	channels    base.Set
 	expiry      *time.Time
 	attachments AttachmentsMeta
-	delta       *RevisionDelta
 	body        Body
 	key         IDAndRev
 	bodyBytes   []byte
 	lock        sync.RWMutex
 	deleted     bool
 	removed     bool
+	bytes       int64 // Estimated size of the value as of the last updateValueBytes, guarded by the cache's lock
 }
 
 // Creates a revision cache with the given capacity and an optional loader function.
@@ -110,6 +160,8 @@ func NewLRURevisionCache(capacity uint32, backingStore RevisionCacheBackingStore
 		backingStore: backingStore,
 		cacheHits:    cacheHitStat,
 		cacheMisses:  cacheMissStat,
+		deltas:       newDeltaCache(deltaCacheOptions{expiry: DefaultDeltaCacheExpiry}),
+		pinnedDocs:   map[string]*revCacheValue{},
 	}
 }
 
@@ -131,13 +183,9 @@ func (rc *LRURevisionCache) Peek(ctx context.Context, docID, revID string) (docR
 	return docRev, docRev.BodyBytes != nil
 }
 
-// Attempt to update the delta on a revision cache entry.  If the entry is no longer resident in the cache,
-// fails silently
+// Stores the delta from a revision in the delta cache.  Deltas are retained independently of the revision cache entry.
 func (rc *LRURevisionCache) UpdateDelta(ctx context.Context, docID, revID string, toDelta RevisionDelta) {
-	value := rc.getValue(docID, revID, false)
-	if value != nil {
-		value.updateDelta(toDelta)
-	}
+	rc.deltas.put(docID, revID, toDelta)
 }
 
 func (rc *LRURevisionCache) getFromCache(ctx context.Context, docID, revID string, loadOnCacheMiss bool, includeBody bool, includeDelta bool) (DocumentRevision, error) {
@@ -146,11 +194,16 @@ func (rc *LRURevisionCache) getFromCache(ctx context.Context, docID, revID strin
 		return DocumentRevision{}, nil
 	}
 
-	docRev, statEvent, err := value.load(ctx, rc.backingStore, includeBody, includeDelta)
+	docRev, statEvent, err := value.load(ctx, rc.backingStore, includeBody)
 	rc.statsRecorderFunc(statEvent)
 
 	if err != nil {
 		rc.removeValue(value) // don't keep failed loads in the cache
+	} else if !statEvent {
+		rc.updateValueBytes(value)
+	}
+	if includeDelta {
+		docRev.Delta = rc.deltas.get(docID, revID)
 	}
 	return docRev, err
 }
@@ -158,7 +211,6 @@ func (rc *LRURevisionCache) getFromCache(ctx context.Context, docID, revID strin
 // In the event that a revision in invalid it needs to be replaced later and the revision cache value should not be
 // used. This function grabs the value directly from the bucket.
 func (rc *LRURevisionCache) LoadInvalidRevFromBackingStore(ctx context.Context, key IDAndRev, doc *Document, includeBody bool, includeDelta bool) (DocumentRevision, error) {
-	var delta *RevisionDelta
 	var docRevBody Body
 
 	value := revCacheValue{
@@ -173,15 +225,11 @@ func (rc *LRURevisionCache) LoadInvalidRevFromBackingStore(ctx context.Context,
 		value.bodyBytes, value.body, value.history, value.channels, value.removed, value.attachments, value.deleted, value.expiry, value.err = revCacheLoader(ctx, rc.backingStore, key, includeBody)
 	}
 
-	if includeDelta {
-		delta = value.delta
-	}
-
 	if includeBody {
 		docRevBody = value.body
 	}
 
-	docRev, err := value.asDocumentRevision(docRevBody, delta)
+	docRev, err := value.asDocumentRevision(docRevBody)
 
 	// Classify operation as a cache miss
 	rc.statsRecorderFunc(false)
@@ -208,13 +256,15 @@ func (rc *LRURevisionCache) GetActive(ctx context.Context, docID string, include
 	}
 
 	// Retrieve from or add to rev cache
-	value := rc.getValue(docID, bucketDoc.CurrentRev, true)
+	value := rc.getCurrentValue(docID, bucketDoc.CurrentRev)
 
 	docRev, statEvent, err := value.loadForDoc(ctx, rc.backingStore, bucketDoc, includeBody)
 	rc.statsRecorderFunc(statEvent)
 
 	if err != nil {
 		rc.removeValue(value) // don't keep failed loads in the cache
+	} else if !statEvent {
+		rc.updateValueBytes(value)
 	}
 	return docRev, err
 }
@@ -236,6 +286,7 @@ func (rc *LRURevisionCache) Put(ctx context.Context, docRev DocumentRevision) {
 	}
 	value := rc.getValue(docRev.DocID, docRev.RevID, true)
 	value.store(docRev)
+	rc.updateValueBytes(value)
 }
 
 // Upsert a revision in the cache.
@@ -243,9 +294,21 @@ func (rc *LRURevisionCache) Upsert(ctx context.Context, docRev DocumentRevision)
 	key := IDAndRev{DocID: docRev.DocID, RevID: docRev.RevID}
 
 	rc.lock.Lock()
+	// If the value is pinned, replace it in place
+	if pinnedValue := rc.pinnedDocs[key.DocID]; pinnedValue != nil && pinnedValue.key == key {
+		value := &revCacheValue{key: key}
+		rc.pinnedDocs[key.DocID] = value
+		rc.adjustBytesStats_(-pinnedValue.bytes)
+		rc.lock.Unlock()
+		value.store(docRev)
+		rc.updateValueBytes(value)
+		return
+	}
+
 	// If element exists remove from lrulist
 	if elem := rc.cache[key]; elem != nil {
 		rc.lruList.Remove(elem)
+		rc.adjustBytes_(-elem.Value.(*revCacheValue).bytes)
 	}
 
 	// Add new value and overwrite existing cache key, pushing to front to maintain order
@@ -259,6 +322,7 @@ func (rc *LRURevisionCache) Upsert(ctx context.Context, docRev DocumentRevision)
 	rc.lock.Unlock()
 
 	value.store(docRev)
+	rc.updateValueBytes(value)
 }
 
 func (rc *LRURevisionCache) getValue(docID, revID string, create bool) (value *revCacheValue) {
@@ -268,7 +332,9 @@ func (rc *LRURevisionCache) getValue(docID, revID string, create bool) (value *r
 	}
 	key := IDAndRev{DocID: docID, RevID: revID}
 	rc.lock.Lock()
-	if elem := rc.cache[key]; elem != nil {
+	if pinnedValue := rc.pinnedDocs[docID]; pinnedValue != nil && pinnedValue.key == key {
+		value = pinnedValue
+	} else if elem := rc.cache[key]; elem != nil {
 		rc.lruList.MoveToFront(elem)
 		value = elem.Value.(*revCacheValue)
 	} else if create {
@@ -282,25 +348,115 @@ func (rc *LRURevisionCache) getValue(docID, revID string, create bool) (value *r
 	return
 }
 
+// getCurrentValue returns the value for the current revision of a document, creating it if needed. If the document is
+// pinned, the value is pinned in place of the value for any previous revision.
+func (rc *LRURevisionCache) getCurrentValue(docID, revID string) *revCacheValue {
+	key := IDAndRev{DocID: docID, RevID: revID}
+	rc.lock.Lock()
+	pinnedValue, pinned := rc.pinnedDocs[docID]
+	if !pinned {
+		rc.lock.Unlock()
+		return rc.getValue(docID, revID, true)
+	}
+	defer rc.lock.Unlock()
+	if pinnedValue != nil && pinnedValue.key == key {
+		return pinnedValue
+	}
+	if pinnedValue != nil {
+		rc.adjustBytesStats_(-pinnedValue.bytes)
+	}
+
+	// Move the value out of the LRU list if it's already cached, otherwise create it
+	var value *revCacheValue
+	if elem := rc.cache[key]; elem != nil {
+		value = elem.Value.(*revCacheValue)
+		rc.lruList.Remove(elem)
+		delete(rc.cache, key)
+		rc.currentBytes -= value.bytes
+	} else {
+		value = &revCacheValue{key: key}
+	}
+	rc.pinnedDocs[docID] = value
+	return value
+}
+
+// RefreshCurrentRev is called when the feed delivers a new current revision of a document. Removes deltas to the
+// document's earlier revisions and, if the document is pinned, replaces its pinned revision with the new revision,
+// which is loaded when it's next retrieved.
+func (rc *LRURevisionCache) RefreshCurrentRev(docID, currentRevID string) {
+	rc.deltas.removeStale(docID, currentRevID)
+
+	rc.lock.Lock()
+	_, pinned := rc.pinnedDocs[docID]
+	rc.lock.Unlock()
+	if pinned {
+		_ = rc.getCurrentValue(docID, currentRevID)
+	}
+}
+
+// Pin pins the current revision of a document in the cache, so that it isn't evicted. The current revision is cached
+// the next time it's retrieved as the active revision, or received from the feed.
+func (rc *LRURevisionCache) Pin(docID string) {
+	rc.lock.Lock()
+	if _, ok := rc.pinnedDocs[docID]; !ok {
+		rc.pinnedDocs[docID] = nil
+	}
+	rc.lock.Unlock()
+}
+
+// Unpin releases a document pinned with Pin, removing its pinned revision from the cache.
+func (rc *LRURevisionCache) Unpin(docID string) {
+	rc.lock.Lock()
+	if pinnedValue := rc.pinnedDocs[docID]; pinnedValue != nil {
+		rc.adjustBytesStats_(-pinnedValue.bytes)
+	}
+	delete(rc.pinnedDocs, docID)
+	rc.lock.Unlock()
+}
+
+// PinnedDocIDs returns the IDs of the pinned documents, in sorted order.
+func (rc *LRURevisionCache) PinnedDocIDs() []string {
+	rc.lock.Lock()
+	docIDs := make([]string, 0, len(rc.pinnedDocs))
+	for docID := range rc.pinnedDocs {
+		docIDs = append(docIDs, docID)
+	}
+	rc.lock.Unlock()
+	sort.Strings(docIDs)
+	return docIDs
+}
+
 // Remove removes a value from the revision cache, if present.
 func (rc *LRURevisionCache) Remove(docID, revID string) {
 	key := IDAndRev{DocID: docID, RevID: revID}
 	rc.lock.Lock()
 	defer rc.lock.Unlock()
+	if pinnedValue := rc.pinnedDocs[docID]; pinnedValue != nil && pinnedValue.key == key {
+		rc.pinnedDocs[docID] = nil
+		rc.adjustBytesStats_(-pinnedValue.bytes)
+		rc.deltas.removeRev(docID, revID)
+		return
+	}
 	element, ok := rc.cache[key]
 	if !ok {
 		return
 	}
 	rc.lruList.Remove(element)
 	delete(rc.cache, key)
+	rc.adjustBytes_(-element.Value.(*revCacheValue).bytes)
+	rc.deltas.removeRev(docID, revID)
 }
 
 // removeValue removes a value from the revision cache, if present and the value matches the the value. If there's an item in the revision cache with a matching docID and revID but the document is different, this item will not be removed from the rev cache.
 func (rc *LRURevisionCache) removeValue(value *revCacheValue) {
 	rc.lock.Lock()
-	if element := rc.cache[value.key]; element != nil && element.Value == value {
+	if rc.pinnedDocs[value.key.DocID] == value {
+		rc.pinnedDocs[value.key.DocID] = nil
+		rc.adjustBytesStats_(-value.bytes)
+	} else if element := rc.cache[value.key]; element != nil && element.Value == value {
 		rc.lruList.Remove(element)
 		delete(rc.cache, value.key)
+		rc.adjustBytes_(-value.bytes)
 	}
 	rc.lock.Unlock()
 }
@@ -308,30 +464,85 @@ func (rc *LRURevisionCache) removeValue(value *revCacheValue) {
 func (rc *LRURevisionCache) purgeOldest_() {
 	value := rc.lruList.Remove(rc.lruList.Back()).(*revCacheValue)
 	delete(rc.cache, value.key)
+	rc.adjustBytes_(-value.bytes)
+}
+
+// setMemoryOptions sets the memory tracking options for the cache. Must be called before the cache is used.
+func (rc *LRURevisionCache) setMemoryOptions(options revCacheMemoryOptions) {
+	rc.memory = options
+}
+
+// setDeltaCache sets the cache used to store deltas. Must be called before the cache is used.
+func (rc *LRURevisionCache) setDeltaCache(deltas *deltaCache) {
+	rc.deltas = deltas
+}
+
+// updateValueBytes updates the tracked size of a value after it's been loaded or modified, then evicts the least
+// recently used values while the cache is over its memory limit. The most recently used value is never evicted, so
+// a single value larger than the limit can still be cached.
+func (rc *LRURevisionCache) updateValueBytes(value *revCacheValue) {
+	// Estimated before acquiring the cache lock, as value.lock is held while loading from the bucket
+	bytes := value.estimateBytes()
+
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	if rc.pinnedDocs[value.key.DocID] == value {
+		// Pinned values are tracked in the stats, but aren't subject to the memory limit
+		rc.adjustBytesStats_(bytes - value.bytes)
+		value.bytes = bytes
+		return
+	}
+	if element := rc.cache[value.key]; element == nil || element.Value != value {
+		// Already evicted or replaced
+		return
+	}
+	rc.adjustBytes_(bytes - value.bytes)
+	value.bytes = bytes
+
+	for rc.memory.maxBytes > 0 && rc.currentBytes > rc.memory.maxBytes && rc.lruList.Len() > 1 {
+		rc.purgeOldest_()
+		if rc.memory.evictionsStat != nil {
+			rc.memory.evictionsStat.Add(1)
+		}
+	}
+}
+
+// adjustBytes_ adjusts the tracked size of the LRU list. Requires the cache lock.
+func (rc *LRURevisionCache) adjustBytes_(delta int64) {
+	rc.currentBytes += delta
+	rc.adjustBytesStats_(delta)
+}
+
+// adjustBytesStats_ adjusts the memory stats for a change in the size of the cached values, including pinned values.
+// Requires the cache lock.
+func (rc *LRURevisionCache) adjustBytesStats_(delta int64) {
+	if delta == 0 {
+		return
+	}
+	if rc.memory.dbBytesStat != nil {
+		rc.memory.dbBytesStat.Add(delta)
+	}
+	if rc.memory.collectionBytesStat != nil {
+		rc.memory.collectionBytesStat.Add(delta)
+	}
 }
 
 // Gets the body etc. out of a revCacheValue. If they aren't present already, the loader func
 // will be called. This is synchronized so that the loader will only be called once even if
 // multiple goroutines try to load at the same time.
-func (value *revCacheValue) load(ctx context.Context, backingStore RevisionCacheBackingStore, includeBody bool, includeDelta bool) (docRev DocumentRevision, cacheHit bool, err error) {
+func (value *revCacheValue) load(ctx context.Context, backingStore RevisionCacheBackingStore, includeBody bool) (docRev DocumentRevision, cacheHit bool, err error) {
 
-	// Reading the delta from the revCacheValue requires holding the read lock, so it's managed outside asDocumentRevision,
-	// to reduce locking when includeDelta=false
-	var delta *RevisionDelta
 	var docRevBody Body
 
 	// Attempt to read cached value.
 	value.lock.RLock()
 	if value.bodyBytes != nil || value.err != nil {
-		if includeDelta {
-			delta = value.delta
-		}
 		if includeBody {
 			docRevBody = value.body
 		}
 		value.lock.RUnlock()
 
-		docRev, err = value.asDocumentRevision(docRevBody, delta)
+		docRev, err = value.asDocumentRevision(docRevBody)
 
 		// On cache hit, if body is requested and not present in revCacheValue, generate from bytes and update revCacheValue
 		if includeBody && docRev._shallowCopyBody == nil && err == nil {
@@ -357,15 +568,12 @@ func (value *revCacheValue) load(ctx context.Context, backingStore RevisionCache
 		value.bodyBytes, value.body, value.history, value.channels, value.removed, value.attachments, value.deleted, value.expiry, value.err = revCacheLoader(ctx, backingStore, value.key, includeBody)
 	}
 
-	if includeDelta {
-		delta = value.delta
-	}
 	if includeBody {
 		docRevBody = value.body
 	}
 	value.lock.Unlock()
 
-	docRev, err = value.asDocumentRevision(docRevBody, delta)
+	docRev, err = value.asDocumentRevision(docRevBody)
 	return docRev, cacheHit, err
 }
 
@@ -387,8 +595,8 @@ func (value *revCacheValue) updateBody(ctx context.Context) (err error) {
 }
 
 // asDocumentRevision copies the rev cache value into a DocumentRevision.  Should only be called for non-empty
-// revCacheValues - copies all immutable revCacheValue properties, and adds the provided body/delta.
-func (value *revCacheValue) asDocumentRevision(body Body, delta *RevisionDelta) (DocumentRevision, error) {
+// revCacheValues - copies all immutable revCacheValue properties, and adds the provided body.
+func (value *revCacheValue) asDocumentRevision(body Body) (DocumentRevision, error) {
 
 	docRev := DocumentRevision{
 		DocID:       value.key.DocID,
@@ -404,7 +612,6 @@ func (value *revCacheValue) asDocumentRevision(body Body, delta *RevisionDelta)
 	if body != nil {
 		docRev._shallowCopyBody = body.ShallowCopy()
 	}
-	docRev.Delta = delta
 
 	return docRev, value.err
 }
@@ -420,7 +627,7 @@ func (value *revCacheValue) loadForDoc(ctx context.Context, backingStore Revisio
 			docRevBody = value.body
 		}
 		value.lock.RUnlock()
-		docRev, err = value.asDocumentRevision(docRevBody, nil)
+		docRev, err = value.asDocumentRevision(docRevBody)
 		// If the body is requested and not yet populated on revCacheValue, populate it from the doc
 		if includeBody && docRev._shallowCopyBody == nil {
 			body := doc.Body(ctx)
@@ -454,7 +661,7 @@ func (value *revCacheValue) loadForDoc(ctx context.Context, backingStore Revisio
 		docRevBody = value.body
 	}
 	value.lock.Unlock()
-	docRev, err = value.asDocumentRevision(docRevBody, nil)
+	docRev, err = value.asDocumentRevision(docRevBody)
 	return docRev, cacheHit, err
 }
 
@@ -475,8 +682,17 @@ func (value *revCacheValue) store(docRev DocumentRevision) {
 	value.lock.Unlock()
 }
 
-func (value *revCacheValue) updateDelta(toDelta RevisionDelta) {
-	value.lock.Lock()
-	value.delta = &toDelta
-	value.lock.Unlock()
+// estimateBytes returns the estimated size of the value, based on the size of its raw body, history and channels. Doesn't include the unmarshalled body, or the overhead of the value itself.
+func (value *revCacheValue) estimateBytes() int64 {
+	value.lock.RLock()
+	defer value.lock.RUnlock()
+	bytes := int64(len(value.key.DocID) + len(value.key.RevID) + len(value.bodyBytes))
+	_, historyIDs := splitRevisionList(value.history)
+	for _, digest := range historyIDs {
+		bytes += int64(len(digest))
+	}
+	for channel := range value.channels {
+		bytes += int64(len(channel))
+	}
+	return bytes
 }
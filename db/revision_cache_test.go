@@ -15,6 +15,7 @@ import (
 	"fmt"
 	"math/rand"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 
@@ -129,6 +130,139 @@ func TestLRURevisionCacheEviction(t *testing.T) {
 	}
 }
 
+// Tests memory tracking and memory-bounded eviction from the LRURevisionCache
+func TestLRURevisionCacheMemoryEviction(t *testing.T) {
+	cacheHitCounter, cacheMissCounter := base.SgwIntStat{}, base.SgwIntStat{}
+	dbBytes, collectionBytes, evictions := base.SgwIntStat{}, base.SgwIntStat{}, base.SgwIntStat{}
+	cache := NewLRURevisionCache(10, &noopBackingStore{}, &cacheHitCounter, &cacheMissCounter)
+
+	// Each value is 1 byte doc ID + 5 byte rev ID + 100 byte body
+	const valueBytes = 106
+	cache.setMemoryOptions(revCacheMemoryOptions{
+		maxBytes:            5 * valueBytes,
+		dbBytesStat:         &dbBytes,
+		collectionBytesStat: &collectionBytes,
+		evictionsStat:       &evictions,
+	})
+	body := []byte(`{"v":"` + strings.Repeat("a", 92) + `"}`)
+	require.Len(t, body, 100)
+
+	ctx := base.TestCtx(t)
+	for docID := 0; docID < 5; docID++ {
+		cache.Put(ctx, DocumentRevision{BodyBytes: body, DocID: strconv.Itoa(docID), RevID: "1-abc", History: Revisions{"start": 1}})
+	}
+	assert.Equal(t, int64(5*valueBytes), dbBytes.Value())
+	assert.Equal(t, int64(5*valueBytes), collectionBytes.Value())
+	assert.Equal(t, int64(0), evictions.Value())
+
+	// Adding more docs evicts the oldest to stay within the memory limit, before the count capacity is reached
+	for docID := 5; docID < 8; docID++ {
+		cache.Put(ctx, DocumentRevision{BodyBytes: body, DocID: strconv.Itoa(docID), RevID: "1-abc", History: Revisions{"start": 1}})
+	}
+	assert.Equal(t, int64(5*valueBytes), dbBytes.Value())
+	assert.Equal(t, int64(3), evictions.Value())
+	for docID := 0; docID < 3; docID++ {
+		_, ok := cache.Peek(ctx, strconv.Itoa(docID), "1-abc")
+		assert.False(t, ok)
+	}
+
+	// Deltas are stored in the delta cache, so aren't included in the size
+	cache.UpdateDelta(ctx, "7", "1-abc", RevisionDelta{DeltaBytes: []byte(`{"v":"b"}`)})
+	assert.Equal(t, int64(5*valueBytes), dbBytes.Value())
+	assert.Equal(t, int64(3), evictions.Value())
+
+	// Removal releases the value's bytes
+	cache.Remove("7", "1-abc")
+	assert.Equal(t, int64(4*valueBytes), dbBytes.Value())
+	assert.Equal(t, int64(4*valueBytes), collectionBytes.Value())
+}
+
+// updatedDocBackingStore returns the documents of a testBackingStore, with the given docs updated to a new current
+// revision.
+type updatedDocBackingStore struct {
+	*testBackingStore
+	currentRevs map[string]string
+}
+
+func (s *updatedDocBackingStore) GetDocument(ctx context.Context, docid string, unmarshalLevel DocumentUnmarshalLevel) (*Document, error) {
+	doc, err := s.testBackingStore.GetDocument(ctx, docid, unmarshalLevel)
+	if err != nil {
+		return nil, err
+	}
+	if revID, ok := s.currentRevs[docid]; ok {
+		doc.CurrentRev = revID
+		doc.History = RevTree{
+			revID: {ID: revID, Channels: base.SetOf("*")},
+		}
+	}
+	return doc, nil
+}
+
+// TestLRURevisionCachePinnedDocs validates that pinned documents aren't evicted, and are refreshed when updated.
+func TestLRURevisionCachePinnedDocs(t *testing.T) {
+	cacheHitCounter, cacheMissCounter, getDocumentCounter, getRevisionCounter := base.SgwIntStat{}, base.SgwIntStat{}, base.SgwIntStat{}, base.SgwIntStat{}
+	backingStore := &updatedDocBackingStore{&testBackingStore{nil, &getDocumentCounter, &getRevisionCounter}, map[string]string{}}
+	cache := NewLRURevisionCache(2, backingStore, &cacheHitCounter, &cacheMissCounter)
+
+	ctx := base.TestCtx(t)
+	cache.Pin("doc1")
+	cache.Pin("doc1")
+	assert.Equal(t, []string{"doc1"}, cache.PinnedDocIDs())
+
+	docRev, err := cache.GetActive(ctx, "doc1", RevCacheOmitBody)
+	require.NoError(t, err)
+	assert.Equal(t, "1-abc", docRev.RevID)
+
+	// Pinned revisions survive eviction, and don't count towards the cache's capacity
+	for _, docID := range []string{"doc2", "doc3", "doc4"} {
+		_, err := cache.Get(ctx, docID, "1-abc", RevCacheOmitBody, RevCacheOmitDelta)
+		require.NoError(t, err)
+	}
+	assert.Len(t, cache.cache, 2)
+	_, found := cache.Peek(ctx, "doc1", "1-abc")
+	assert.True(t, found)
+	_, found = cache.Peek(ctx, "doc2", "1-abc")
+	assert.False(t, found)
+
+	// An update to the pinned doc replaces its pinned revision
+	backingStore.currentRevs["doc1"] = "2-abc"
+	cache.RefreshCurrentRev("doc1", "2-abc")
+	_, found = cache.Peek(ctx, "doc1", "1-abc")
+	assert.False(t, found)
+	_, err = cache.Get(ctx, "doc1", "2-abc", RevCacheOmitBody, RevCacheOmitDelta)
+	require.NoError(t, err)
+	for _, docID := range []string{"doc5", "doc6", "doc7"} {
+		_, err := cache.Get(ctx, docID, "1-abc", RevCacheOmitBody, RevCacheOmitDelta)
+		require.NoError(t, err)
+	}
+	getRevisionCount := getRevisionCounter.Value()
+	_, err = cache.Get(ctx, "doc1", "2-abc", RevCacheOmitBody, RevCacheOmitDelta)
+	require.NoError(t, err)
+	assert.Equal(t, getRevisionCount, getRevisionCounter.Value())
+
+	// Updates to unpinned docs don't add them to the cache
+	cache.RefreshCurrentRev("doc8", "2-abc")
+	_, found = cache.Peek(ctx, "doc8", "2-abc")
+	assert.False(t, found)
+
+	cache.Unpin("doc1")
+	assert.Empty(t, cache.PinnedDocIDs())
+	_, found = cache.Peek(ctx, "doc1", "2-abc")
+	assert.False(t, found)
+}
+
+func TestShardedLRURevisionCachePinnedDocs(t *testing.T) {
+	cacheHitCounter, cacheMissCounter := base.SgwIntStat{}, base.SgwIntStat{}
+	cache := NewShardedLRURevisionCache(4, 10, &noopBackingStore{}, &cacheHitCounter, &cacheMissCounter)
+
+	for _, docID := range []string{"doc3", "doc1", "doc2"} {
+		cache.Pin(docID)
+	}
+	assert.Equal(t, []string{"doc1", "doc2", "doc3"}, cache.PinnedDocIDs())
+	cache.Unpin("doc2")
+	assert.Equal(t, []string{"doc1", "doc3"}, cache.PinnedDocIDs())
+}
+
 func TestBackingStore(t *testing.T) {
 
 	cacheHitCounter, cacheMissCounter, getDocumentCounter, getRevisionCounter := base.SgwIntStat{}, base.SgwIntStat{}, base.SgwIntStat{}, base.SgwIntStat{}
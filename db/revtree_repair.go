@@ -0,0 +1,206 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	sgbucket "github.com/couchbase/sg-bucket"
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// Actions taken when repairing a rev tree.
+const (
+	RevTreeRepairMissingParent = "missing_parent" // The revision's parent isn't in the tree, so it was made a root
+	RevTreeRepairInvalidParent = "invalid_parent" // The revision's parent doesn't have a lower generation, as in a cycle, so it was made a root
+	RevTreeRepairPruned        = "pruned"         // The revision was in a branch detached by the repair that doesn't lead to the current revision, so was removed
+)
+
+// RevTreeRepair is a change made, or that would be made, when repairing a document's rev tree.
+type RevTreeRepair struct {
+	Action string `json:"action"`
+	RevID  string `json:"rev"`
+	Parent string `json:"parent,omitempty"` // For detached revisions, the parent they were linked to
+}
+
+// RevTreeRepairResult reports the repairs made to a document's rev tree, or for a dry run, the repairs that would be
+// made.
+type RevTreeRepairResult struct {
+	DocID      string          `json:"id"`
+	DryRun     bool            `json:"dry_run"`
+	CurrentRev string          `json:"current_rev"`
+	Repairs    []RevTreeRepair `json:"repairs"`
+}
+
+// repair fixes broken parent links in the tree, which would otherwise cause walks of the tree to fail or never end.
+// Revisions whose parent is missing, or doesn't have a lower generation, are made roots.  Branches detached this way
+// that don't lead to currentRev are pruned, as they're only reachable through the broken links.  Returns the repairs
+// made, along with the body keys of pruned revisions stored externally, keyed by revID.
+func (tree RevTree) repair(ctx context.Context, currentRev string) (repairs []RevTreeRepair, prunedBodyKeys map[string]string) {
+	// Sort revIDs so that repairs are reported in a stable order
+	revIDs := make([]string, 0, len(tree))
+	for revID := range tree {
+		revIDs = append(revIDs, revID)
+	}
+	sort.Strings(revIDs)
+
+	detachedRoots := make(map[string]bool)
+	for _, revID := range revIDs {
+		info := tree[revID]
+		if info.IsRoot() {
+			continue
+		}
+		action := ""
+		if _, ok := tree[info.Parent]; !ok {
+			action = RevTreeRepairMissingParent
+		} else if info.ParentGenGTENodeGen(ctx) {
+			action = RevTreeRepairInvalidParent
+		}
+		if action == "" {
+			continue
+		}
+		repairs = append(repairs, RevTreeRepair{Action: action, RevID: revID, Parent: info.Parent})
+		info.Parent = ""
+		detachedRoots[revID] = true
+	}
+
+	// With every parent having a lower generation, walking up from any revision reaches a root
+	rootOf := func(revID string) string {
+		for !tree[revID].IsRoot() {
+			revID = tree[revID].Parent
+		}
+		return revID
+	}
+	if _, ok := tree[currentRev]; !ok || len(detachedRoots) == 0 {
+		return repairs, nil
+	}
+	currentRoot := rootOf(currentRev)
+	for _, revID := range revIDs {
+		root := rootOf(revID)
+		if !detachedRoots[root] || root == currentRoot {
+			continue
+		}
+		repairs = append(repairs, RevTreeRepair{Action: RevTreeRepairPruned, RevID: revID})
+		if bodyKey := tree[revID].BodyKey; bodyKey != "" {
+			if prunedBodyKeys == nil {
+				prunedBodyKeys = make(map[string]string)
+			}
+			prunedBodyKeys[revID] = bodyKey
+		}
+	}
+	for _, repair := range repairs {
+		if repair.Action == RevTreeRepairPruned {
+			delete(tree, repair.RevID)
+		}
+	}
+	return repairs, prunedBodyKeys
+}
+
+// repairRevTree repairs the document's rev tree, recording the bodies of pruned revisions for deletion once the
+// document is saved.
+func (doc *Document) repairRevTree(ctx context.Context) []RevTreeRepair {
+	repairs, prunedBodyKeys := doc.History.repair(ctx, doc.CurrentRev)
+	for revID, bodyKey := range prunedBodyKeys {
+		if doc.removedRevisionBodyKeys == nil {
+			doc.removedRevisionBodyKeys = make(map[string]string)
+		}
+		doc.removedRevisionBodyKeys[revID] = bodyKey
+	}
+	return repairs
+}
+
+// RepairRevTree validates a document's rev tree, and repairs broken parent links that would otherwise cause rev tree
+// operations on the document to fail.  For a dry run, the repairs that would be made are returned without saving them.
+func (db *DatabaseCollectionWithUser) RepairRevTree(ctx context.Context, docid string, dryRun bool) (*RevTreeRepairResult, error) {
+	result := &RevTreeRepairResult{DocID: docid, DryRun: dryRun}
+	if dryRun {
+		doc, err := db.GetDocument(ctx, docid, DocUnmarshalSync)
+		if err != nil {
+			return nil, err
+		}
+		doc.History = doc.History.copy()
+		result.CurrentRev = doc.CurrentRev
+		result.Repairs = doc.repairRevTree(ctx)
+		return result, nil
+	}
+
+	var repairedDoc *Document
+	repair := func(doc *Document) bool {
+		result.CurrentRev = doc.CurrentRev
+		result.Repairs = doc.repairRevTree(ctx)
+		repairedDoc = doc
+		return len(result.Repairs) > 0
+	}
+
+	key := realDocID(docid)
+	if key == "" {
+		return nil, base.HTTPErrorf(http.StatusBadRequest, "Invalid doc ID")
+	}
+	var err error
+	if db.UseXattrs() {
+		writeUpdateFunc := func(currentValue []byte, currentXattr []byte, currentUserXattr []byte, cas uint64) (
+			raw []byte, rawXattr []byte, deleteDoc bool, expiry *uint32, updatedSpec []sgbucket.MacroExpansionSpec, err error) {
+			if len(currentXattr) == 0 {
+				return nil, nil, false, nil, nil, base.ErrNotFound
+			}
+			doc, err := unmarshalDocumentWithXattr(ctx, docid, currentValue, currentXattr, currentUserXattr, cas, DocUnmarshalAll)
+			if err != nil {
+				return nil, nil, false, nil, nil, err
+			}
+			if !repair(doc) {
+				return nil, nil, false, nil, nil, base.ErrUpdateCancel
+			}
+			// Tombstones are repaired by updating their sync metadata alone
+			deleteDoc = len(currentValue) == 0
+			raw, rawXattr, err = doc.MarshalWithXattr()
+			return raw, rawXattr, deleteDoc, nil, nil, err
+		}
+		opts := &sgbucket.MutateInOptions{
+			MacroExpansion: macroExpandSpec(base.SyncXattrName),
+		}
+		_, err = db.dataStore.WriteUpdateWithXattr(ctx, key, base.SyncXattrName, db.userXattrKey(), 0, nil, opts, writeUpdateFunc)
+	} else {
+		_, err = db.dataStore.Update(key, 0, func(currentValue []byte) ([]byte, *uint32, bool, error) {
+			if currentValue == nil {
+				return nil, nil, false, base.ErrNotFound
+			}
+			doc, err := unmarshalDocument(docid, currentValue)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			if !repair(doc) {
+				return nil, nil, false, base.ErrUpdateCancel
+			}
+			updatedBytes, marshalErr := base.JSONMarshal(doc)
+			return updatedBytes, nil, false, marshalErr
+		})
+	}
+	if err == base.ErrUpdateCancel {
+		return result, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, repair := range result.Repairs {
+		base.InfofCtx(ctx, base.KeyCRUD, "Repaired rev tree of doc %q: %s", base.UD(docid), repair)
+	}
+	repairedDoc.deleteRemovedRevisionBodies(ctx, db.dataStore)
+	db.revisionCache.Remove(docid, repairedDoc.CurrentRev)
+	return result, nil
+}
+
+func (r RevTreeRepair) String() string {
+	if r.Parent != "" {
+		return fmt.Sprintf("%s %s (parent %s)", r.Action, r.RevID, r.Parent)
+	}
+	return r.Action + " " + r.RevID
+}
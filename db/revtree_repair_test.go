@@ -0,0 +1,58 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"testing"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevTreeRepair(t *testing.T) {
+	ctx := base.TestCtx(t)
+
+	// A valid tree isn't changed
+	tree := branchymap.copy()
+	repairs, prunedBodyKeys := tree.repair(ctx, "3-three")
+	assert.Empty(t, repairs)
+	assert.Empty(t, prunedBodyKeys)
+	assert.Equal(t, branchymap, tree)
+
+	// The current revision's branch lost its parent, and a branch off a cycle is only reachable through broken links
+	tree = RevTree{
+		"1-a": {ID: "1-a"},
+		"2-a": {ID: "2-a", Parent: "1-a"},
+		"4-b": {ID: "4-b", Parent: "3-missing"},
+		"5-b": {ID: "5-b", Parent: "4-b"},
+		"2-c": {ID: "2-c", Parent: "3-c"},
+		"3-c": {ID: "3-c", Parent: "2-c", BodyKey: "_sync:rb:c"},
+	}
+	repairs, prunedBodyKeys = tree.repair(ctx, "5-b")
+	assert.Equal(t, []RevTreeRepair{
+		{Action: RevTreeRepairInvalidParent, RevID: "2-c", Parent: "3-c"},
+		{Action: RevTreeRepairMissingParent, RevID: "4-b", Parent: "3-missing"},
+		{Action: RevTreeRepairPruned, RevID: "2-c"},
+		{Action: RevTreeRepairPruned, RevID: "3-c"},
+	}, repairs)
+	assert.Equal(t, map[string]string{"3-c": "_sync:rb:c"}, prunedBodyKeys)
+
+	// The current revision's branch and the intact branch are kept, and the tree can be walked again
+	assert.Len(t, tree, 4)
+	assert.ElementsMatch(t, []string{"2-a", "5-b"}, tree.GetLeaves())
+	assert.True(t, tree["4-b"].IsRoot())
+	history, err := tree.getHistory("5-b")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"5-b", "4-b"}, history)
+
+	// The repaired tree is valid
+	repairs, _ = tree.repair(ctx, "5-b")
+	assert.Empty(t, repairs)
+}
@@ -26,6 +26,10 @@ const (
 	// Maximum time to wait after a reserve before releasing sequences
 	defaultReleaseSequenceWait = 1500 * time.Millisecond
 
+	// Minimum time to wait after a reserve before releasing sequences, when most allocated sequences are being released
+	// unused
+	minReleaseSequenceWait = 100 * time.Millisecond
+
 	// Maximum batch size
 	maxBatchSize = 10
 
@@ -53,6 +57,11 @@ type sequenceAllocator struct {
 	lastSequenceReserveTime time.Time           // Time of most recent sequence reserve
 	releaseSequenceWait     time.Duration       // Supports test customization
 	metaKeys                *base.MetadataKeys  // Key generator for sequence and unused sequence documents
+	allocatedSinceRelease   uint64              // Sequences allocated since unused sequences were last released
+	rejectedSinceRelease    uint64              // Allocated sequences released unused by failed writes since unused sequences were last released
+
+	// regressionCallback is called after the counter is found to have moved backwards and has been advanced
+	regressionCallback func(ctx context.Context, advancedSeq uint64)
 }
 
 func newSequenceAllocator(ctx context.Context, datastore base.DataStore, dbStatsMap *base.DatabaseStats, metaKeys *base.MetadataKeys) (*sequenceAllocator, error) {
@@ -103,7 +112,9 @@ func (s *sequenceAllocator) releaseSequenceMonitor(ctx context.Context) {
 		case <-s.reserveNotify:
 			// On reserve, start the timer to release unused sequences. A new reserve resets the timer.
 			// On timeout, release sequences and return to idle state
-			_ = timer.Reset(s.releaseSequenceWait)
+			releaseWait := s.releaseWait()
+			s.dbStats.SequenceReleaseWait.Set(releaseWait.Nanoseconds())
+			_ = timer.Reset(releaseWait)
 		case <-timer.C:
 			s.releaseUnusedSequences(ctx)
 		case <-s.terminator:
@@ -113,6 +124,54 @@ func (s *sequenceAllocator) releaseSequenceMonitor(ctx context.Context) {
 	}
 }
 
+// releaseWait returns how long to wait after a reserve before releasing unused sequences.  Sequences released unused
+// by failed writes are reserved sequences that were never going to be used, so the wait is shortened in proportion
+// to the rate of failed writes, to release the rest of the batch before it stalls the stable sequence.
+func (s *sequenceAllocator) releaseWait() time.Duration {
+	s.mutex.Lock()
+	rejectionRate := s._rejectionRate()
+	s.mutex.Unlock()
+
+	minWait := minReleaseSequenceWait
+	if minWait > s.releaseSequenceWait {
+		minWait = s.releaseSequenceWait
+	}
+	releaseWait := time.Duration(float64(s.releaseSequenceWait) * (1 - rejectionRate))
+	if releaseWait < minWait {
+		releaseWait = minWait
+	}
+	return releaseWait
+}
+
+// _rejectionRate returns the proportion of sequences allocated since unused sequences were last released that have
+// been released unused by failed writes.  Requires the mutex to be held.
+func (s *sequenceAllocator) _rejectionRate() float64 {
+	if s.allocatedSinceRelease == 0 || s.rejectedSinceRelease == 0 {
+		return 0
+	}
+	if s.rejectedSinceRelease >= s.allocatedSinceRelease {
+		return 1
+	}
+	return float64(s.rejectedSinceRelease) / float64(s.allocatedSinceRelease)
+}
+
+// _updateReleasePending updates the count of reserved sequences not yet allocated or released.  Requires the mutex
+// to be held.
+func (s *sequenceAllocator) _updateReleasePending() {
+	var pending uint64
+	if s.max > s.last {
+		pending = s.max - s.last
+	}
+	s.dbStats.SequenceReleasePending.Set(int64(pending))
+}
+
+// lock acquires the allocator mutex, tracking time spent waiting for it as a measure of allocator contention.
+func (s *sequenceAllocator) lock() {
+	lockStart := time.Now()
+	s.mutex.Lock()
+	s.dbStats.SequenceAllocatorLockWaitTime.Add(time.Since(lockStart).Nanoseconds())
+}
+
 // Releases any currently reserved, non-allocated sequences.
 func (s *sequenceAllocator) releaseUnusedSequences(ctx context.Context) {
 	s.mutex.Lock()
@@ -137,8 +196,12 @@ func (s *sequenceAllocator) releaseUnusedSequences(ctx context.Context) {
 		// Some sequences were used - reduce batch size by the unused amount.
 		s.sequenceBatchSize = s.sequenceBatchSize - unusedAmount
 	}
+	s.dbStats.SequenceBatchSize.Set(int64(s.sequenceBatchSize))
 
 	s.last = s.max
+	s.allocatedSinceRelease = 0
+	s.rejectedSinceRelease = 0
+	s._updateReleasePending()
 	s.mutex.Unlock()
 }
 
@@ -165,7 +228,7 @@ func (s *sequenceAllocator) lastSequence(ctx context.Context) (uint64, error) {
 // and increments s.last.
 // If no previously reserved sequences are available, reserves new batch.
 func (s *sequenceAllocator) nextSequence(ctx context.Context) (sequence uint64, err error) {
-	s.mutex.Lock()
+	s.lock()
 	sequence, sequencesReserved, err := s._nextSequence(ctx)
 	s.mutex.Unlock()
 	if err != nil {
@@ -188,7 +251,7 @@ func (s *sequenceAllocator) nextSequence(ctx context.Context) (sequence uint64,
 func (s *sequenceAllocator) nextSequenceGreaterThan(ctx context.Context, existingSequence uint64) (sequence uint64, err error) {
 
 	targetSequence := existingSequence + 1
-	s.mutex.Lock()
+	s.lock()
 	// If the target sequence is less than or equal to one we've already allocated, can assign the sequence in the standard way
 	if targetSequence <= s.last {
 		sequence, sequencesReserved, err := s._nextSequence(ctx)
@@ -208,6 +271,8 @@ func (s *sequenceAllocator) nextSequenceGreaterThan(ctx context.Context, existin
 	if targetSequence <= s.max {
 		releaseFrom := s.last + 1
 		s.last = targetSequence
+		s.allocatedSinceRelease++
+		s._updateReleasePending()
 		s.mutex.Unlock()
 		if releaseFrom < targetSequence {
 			if err := s.releaseSequenceRange(ctx, releaseFrom, targetSequence-1); err != nil {
@@ -242,6 +307,8 @@ func (s *sequenceAllocator) nextSequenceGreaterThan(ctx context.Context, existin
 
 	s.max = allocatedToSeq
 	s.last = allocatedToSeq - numberToAllocate + 1
+	s.allocatedSinceRelease++
+	s._updateReleasePending()
 	sequence = s.last
 	s.mutex.Unlock()
 
@@ -279,6 +346,8 @@ func (s *sequenceAllocator) _nextSequence(ctx context.Context) (sequence uint64,
 		sequencesReserved = true
 	}
 	s.last++
+	s.allocatedSinceRelease++
+	s._updateReleasePending()
 	sequence = s.last
 	return sequence, sequencesReserved, nil
 }
@@ -289,13 +358,30 @@ func (s *sequenceAllocator) _reserveSequenceBatch(ctx context.Context) error {
 	// If the time elapsed since the last reserveSequenceRange invocation reserve is shorter than our target frequency,
 	// this indicates we're making an incr call more frequently than we want to.  Triggers an increase in batch size to
 	// reduce incr frequency.
-	if time.Since(s.lastSequenceReserveTime) < MaxSequenceIncrFrequency {
-		s.sequenceBatchSize = s.sequenceBatchSize * sequenceBatchMultiplier
+	sinceLastReserve := time.Since(s.lastSequenceReserveTime)
+	if sinceLastReserve < MaxSequenceIncrFrequency {
+		// Sequences released unused by failed writes don't need to be reserved in advance, so only grow the batch
+		// for the sequences that went on to be used.
+		grownBatchSize := s.sequenceBatchSize * sequenceBatchMultiplier
+		s.sequenceBatchSize += uint64(float64(grownBatchSize-s.sequenceBatchSize) * (1 - s._rejectionRate()))
 		if s.sequenceBatchSize > maxBatchSize {
 			s.sequenceBatchSize = maxBatchSize
 		}
 		base.DebugfCtx(ctx, base.KeyCRUD, "Increased sequence batch to %d", s.sequenceBatchSize)
+	} else if !s.lastSequenceReserveTime.IsZero() && s.sequenceBatchSize > idleBatchSize {
+		// The previous batch took longer than the target frequency to be used, so write throughput has dropped.  Shrink
+		// the batch to the number of sequences expected to be used within the target frequency at the recent write rate,
+		// to avoid reserving sequences that will only be released unused.
+		targetBatchSize := uint64(math.Round(float64(s.sequenceBatchSize) * float64(MaxSequenceIncrFrequency) / float64(sinceLastReserve)))
+		if targetBatchSize < idleBatchSize {
+			targetBatchSize = idleBatchSize
+		}
+		if targetBatchSize < s.sequenceBatchSize {
+			s.sequenceBatchSize = targetBatchSize
+			base.DebugfCtx(ctx, base.KeyCRUD, "Decreased sequence batch to %d", s.sequenceBatchSize)
+		}
 	}
+	s.dbStats.SequenceBatchSize.Set(int64(s.sequenceBatchSize))
 
 	max, err := s.incrementSequence(s.sequenceBatchSize)
 	if err != nil {
@@ -303,6 +389,32 @@ func (s *sequenceAllocator) _reserveSequenceBatch(ctx context.Context) error {
 		return err
 	}
 
+	// If the counter is no higher than our previous reservation it has moved backwards, as when the bucket is flushed
+	// or restored from a backup while the database is online.  Advance it past our previous reservation rather than
+	// reassigning sequences the change cache and clients' checkpoints have already passed.
+	if max <= s.max {
+		s.dbStats.SequenceRegressionCount.Add(1)
+		base.ErrorfCtx(ctx, "The sequence counter %s moved backwards to %d, below sequence %d already allocated by this node. "+
+			"The bucket may have been flushed or restored from a backup. Advancing the sequence counter and starting a new "+
+			"sequence epoch, which reinitializes the change cache and replicates to clients again from the start.",
+			base.MD(s.metaKeys.SyncSeqKey()), max-s.sequenceBatchSize, s.max)
+		max, err = s.incrementSequence(s.max - max + s.sequenceBatchSize)
+		if err != nil {
+			base.WarnfCtx(ctx, "Error from incrementSequence advancing sequence counter past %d: %v", s.max, err)
+			return err
+		}
+		if s.regressionCallback != nil {
+			// Run asynchronously, as reinitializing the change cache reads the last sequence from this allocator
+			go s.regressionCallback(ctx, max)
+		}
+	}
+
+	// If the counter has moved past our previous reservation by more than this batch, another node has allocated
+	// sequences in the interim.
+	if s.max > 0 && max-s.sequenceBatchSize != s.max {
+		s.dbStats.SequenceIncrConflictCount.Add(1)
+	}
+
 	// Update max and last used sequences.  Last is updated here to account for sequences allocated/used by other
 	// Sync Gateway nodes
 	s.max = max
@@ -313,6 +425,26 @@ func (s *sequenceAllocator) _reserveSequenceBatch(ctx context.Context) error {
 	return nil
 }
 
+// advanceSequence increments the sequence counter to at least target, when it has fallen behind sequences already
+// allocated.  Returns the counter's value.
+func (s *sequenceAllocator) advanceSequence(ctx context.Context, target uint64) (uint64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	current, err := s.getSequence()
+	if err != nil {
+		return 0, err
+	}
+	if current >= target {
+		return current, nil
+	}
+	advanced, err := s.incrementSequence(target - current)
+	if err != nil {
+		return 0, err
+	}
+	base.InfofCtx(ctx, base.KeyCRUD, "Advanced sequence counter from %d to %d", current, advanced)
+	return advanced, nil
+}
+
 // Gets the _sync:seq document value.  Retry handling provided by bucket.Get.
 func (s *sequenceAllocator) getSequence() (max uint64, err error) {
 	return base.GetCounter(s.datastore, s.metaKeys.SyncSeqKey())
@@ -320,9 +452,11 @@ func (s *sequenceAllocator) getSequence() (max uint64, err error) {
 
 // Increments the _sync:seq document.  Retry handling provided by bucket.Incr.
 func (s *sequenceAllocator) incrementSequence(numToReserve uint64) (max uint64, err error) {
+	incrStart := time.Now()
 	value, err := s.datastore.Incr(s.metaKeys.SyncSeqKey(), numToReserve, numToReserve, 0)
 	if err == nil {
 		s.dbStats.SequenceIncrCount.Add(1)
+		s.dbStats.SequenceIncrTime.Add(time.Since(incrStart).Nanoseconds())
 	}
 	return value, err
 }
@@ -338,6 +472,9 @@ func (s *sequenceAllocator) releaseSequence(ctx context.Context, sequence uint64
 		return err
 	}
 	s.dbStats.SequenceReleasedCount.Add(1)
+	s.mutex.Lock()
+	s.rejectedSinceRelease++
+	s.mutex.Unlock()
 	base.DebugfCtx(ctx, base.KeyCRUD, "Released unused sequence #%d", sequence)
 	return nil
 }
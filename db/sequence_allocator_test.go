@@ -11,6 +11,7 @@ licenses/APL2.txt.
 package db
 
 import (
+	"context"
 	"sync"
 	"testing"
 	"time"
@@ -378,3 +379,207 @@ func TestNextSequenceGreaterThanMultiNode(t *testing.T) {
 	assertNewAllocatorStats(t, dbStatsA, 2, 25, 2, 14)
 
 }
+
+// TestSequenceAllocatorAdaptiveBatchSize verifies that the batch size shrinks when the previous batch took longer than
+// MaxSequenceIncrFrequency to be used.
+func TestSequenceAllocatorAdaptiveBatchSize(t *testing.T) {
+
+	ctx := base.TestCtx(t)
+	bucket := base.GetTestBucket(t)
+	defer bucket.Close(ctx)
+
+	sgw, err := base.NewSyncGatewayStats()
+	require.NoError(t, err)
+	dbstats, err := sgw.NewDBStats("", false, false, false, nil, nil)
+	require.NoError(t, err)
+	testStats := dbstats.Database()
+
+	a := &sequenceAllocator{
+		datastore:         bucket.GetSingleDataStore(),
+		dbStats:           testStats,
+		sequenceBatchSize: 8,
+		reserveNotify:     make(chan struct{}, 50),
+		metaKeys:          base.DefaultMetadataKeys,
+	}
+
+	oldFrequency := MaxSequenceIncrFrequency
+	defer func() { MaxSequenceIncrFrequency = oldFrequency }()
+	MaxSequenceIncrFrequency = 1 * time.Second
+
+	// Previous batch of 8 was used over 4s, so the expected usage within MaxSequenceIncrFrequency is 2
+	a.lastSequenceReserveTime = time.Now().Add(-4 * time.Second)
+	nextSequence, err := a.nextSequence(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), nextSequence)
+	assert.Equal(t, 2, int(a.sequenceBatchSize))
+	assert.Equal(t, int64(2), testStats.SequenceBatchSize.Value())
+
+	// Very slow usage should drop back to the idle batch size
+	a.last = a.max
+	a.lastSequenceReserveTime = time.Now().Add(-time.Minute)
+	_, err = a.nextSequence(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, idleBatchSize, int(a.sequenceBatchSize))
+	assert.Equal(t, int64(idleBatchSize), testStats.SequenceBatchSize.Value())
+
+	assert.Equal(t, int64(2), testStats.SequenceIncrCount.Value())
+	assert.Greater(t, testStats.SequenceIncrTime.Value(), int64(0))
+	assert.Equal(t, int64(0), testStats.SequenceIncrConflictCount.Value())
+}
+
+// TestSequenceAllocatorIncrConflict verifies that reservations following an increment by another node are counted as conflicts.
+func TestSequenceAllocatorIncrConflict(t *testing.T) {
+
+	ctx := base.TestCtx(t)
+	bucket := base.GetTestBucket(t)
+	defer bucket.Close(ctx)
+
+	sgw, err := base.NewSyncGatewayStats()
+	require.NoError(t, err)
+	dbstats, err := sgw.NewDBStats("", false, false, false, nil, nil)
+	require.NoError(t, err)
+	testStats := dbstats.Database()
+
+	a := &sequenceAllocator{
+		datastore:         bucket.GetSingleDataStore(),
+		dbStats:           testStats,
+		sequenceBatchSize: idleBatchSize,
+		reserveNotify:     make(chan struct{}, 50),
+		metaKeys:          base.DefaultMetadataKeys,
+	}
+
+	_, err = a.nextSequence(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), testStats.SequenceIncrConflictCount.Value())
+
+	// Simulate another node allocating sequences
+	_, err = a.datastore.Incr(a.metaKeys.SyncSeqKey(), 5, 5, 0)
+	require.NoError(t, err)
+
+	nextSequence, err := a.nextSequence(ctx)
+	require.NoError(t, err)
+	assert.Greater(t, nextSequence, uint64(6))
+	assert.Equal(t, int64(1), testStats.SequenceIncrConflictCount.Value())
+}
+
+// TestSequenceAllocatorReleaseRejected verifies that sequences released unused by failed writes shorten the wait before
+// releasing unused sequences, and limit batch growth.
+func TestSequenceAllocatorReleaseRejected(t *testing.T) {
+
+	ctx := base.TestCtx(t)
+	bucket := base.GetTestBucket(t)
+	defer bucket.Close(ctx)
+
+	sgw, err := base.NewSyncGatewayStats()
+	require.NoError(t, err)
+	dbstats, err := sgw.NewDBStats("", false, false, false, nil, nil)
+	require.NoError(t, err)
+	testStats := dbstats.Database()
+
+	a := &sequenceAllocator{
+		datastore:           bucket.GetSingleDataStore(),
+		dbStats:             testStats,
+		sequenceBatchSize:   4,
+		reserveNotify:       make(chan struct{}, 50),
+		releaseSequenceWait: time.Second,
+		metaKeys:            base.DefaultMetadataKeys,
+	}
+
+	oldFrequency := MaxSequenceIncrFrequency
+	defer func() { MaxSequenceIncrFrequency = oldFrequency }()
+	MaxSequenceIncrFrequency = 1 * time.Minute
+
+	for i := 0; i < 4; i++ {
+		_, err := a.nextSequence(ctx)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, time.Second, a.releaseWait())
+	assert.Equal(t, int64(0), testStats.SequenceReleasePending.Value())
+
+	// Half of the allocated sequences are released by failed writes
+	require.NoError(t, a.releaseSequence(ctx, 3))
+	require.NoError(t, a.releaseSequence(ctx, 4))
+	assert.Equal(t, 500*time.Millisecond, a.releaseWait())
+
+	// The next batch only grows by the proportion of sequences used
+	_, err = a.nextSequence(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 6, int(a.sequenceBatchSize))
+	assert.Equal(t, int64(5), testStats.SequenceReleasePending.Value())
+
+	// When every allocated sequence is released unused, the wait doesn't drop below the minimum
+	for seq := uint64(5); seq < 12; seq++ {
+		require.NoError(t, a.releaseSequence(ctx, seq))
+	}
+	assert.Equal(t, minReleaseSequenceWait, a.releaseWait())
+
+	// Releasing unused sequences resets the rate
+	a.releaseUnusedSequences(ctx)
+	assert.Equal(t, time.Second, a.releaseWait())
+	assert.Equal(t, int64(0), testStats.SequenceReleasePending.Value())
+	assert.Equal(t, int64(14), testStats.SequenceReleasedCount.Value())
+}
+
+func TestSequenceAllocatorCounterRegression(t *testing.T) {
+
+	ctx := base.TestCtx(t)
+	bucket := base.GetTestBucket(t)
+	defer bucket.Close(ctx)
+
+	sgw, err := base.NewSyncGatewayStats()
+	require.NoError(t, err)
+	dbstats, err := sgw.NewDBStats("", false, false, false, nil, nil)
+	require.NoError(t, err)
+	testStats := dbstats.Database()
+
+	dataStore := bucket.GetSingleDataStore()
+	a := &sequenceAllocator{
+		datastore:           dataStore,
+		dbStats:             testStats,
+		sequenceBatchSize:   idleBatchSize,
+		reserveNotify:       make(chan struct{}, 50),
+		releaseSequenceWait: time.Second,
+		metaKeys:            base.DefaultMetadataKeys,
+	}
+	regressions := make(chan uint64, 1)
+	a.regressionCallback = func(_ context.Context, advancedSeq uint64) {
+		regressions <- advancedSeq
+	}
+
+	var lastSeq uint64
+	for i := 0; i < 10; i++ {
+		lastSeq, err = a.nextSequence(ctx)
+		require.NoError(t, err)
+	}
+
+	// Simulate the bucket being restored from a backup taken after the first sequence was allocated
+	require.NoError(t, dataStore.Delete(base.DefaultMetadataKeys.SyncSeqKey()))
+	_, err = dataStore.Incr(base.DefaultMetadataKeys.SyncSeqKey(), 1, 1, 0)
+	require.NoError(t, err)
+
+	// Sequences already allocated aren't reassigned once the reserved batch has been used
+	a.last = a.max
+	nextSeq, err := a.nextSequence(ctx)
+	require.NoError(t, err)
+	assert.Greater(t, nextSeq, lastSeq)
+	assert.Equal(t, int64(1), testStats.SequenceRegressionCount.Value())
+	counter, err := a.getSequence()
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, counter, nextSeq)
+
+	// The callback is given the advanced counter, to start a new sequence epoch
+	select {
+	case advancedSeq := <-regressions:
+		assert.Equal(t, counter, advancedSeq)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for regression callback")
+	}
+
+	// advanceSequence only moves the counter forwards
+	advanced, err := a.advanceSequence(ctx, counter+100)
+	require.NoError(t, err)
+	assert.Equal(t, counter+100, advanced)
+	advanced, err = a.advanceSequence(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, counter+100, advanced)
+}
@@ -0,0 +1,132 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// DefaultSevereSequenceGap is how far the sequence counter can be from the sequences it's compared with before a
+// mismatch is severe, if the startup consistency check doesn't set a SevereSequenceGap.
+const DefaultSevereSequenceGap = 10000
+
+// ErrSequenceInconsistent is returned when starting a database whose strict startup consistency check found a severe
+// mismatch between the sequence counter and the bucket.
+var ErrSequenceInconsistent = errors.New("sequence counter is inconsistent with the bucket")
+
+// SequenceCheckOptions enables a consistency check when the database is started, comparing the sequence counter with
+// the sequences already allocated and the mutations made to the bucket, to detect corrupted or mismatched metadata
+// before anything is started from the counter.
+type SequenceCheckOptions struct {
+	Enabled           bool
+	Strict            bool   // Keep the database offline if a severe mismatch is found
+	SevereSequenceGap uint64 // Mismatches larger than this are severe. 0 uses DefaultSevereSequenceGap
+}
+
+// SequenceConsistencyReport is the result of the startup consistency check.
+type SequenceConsistencyReport struct {
+	Counter          uint64   // Value of the sequence counter
+	HighestAllocated uint64   // Highest sequence known to have been allocated, from the sequence fence and documents
+	AllocatedSource  string   // Where the highest allocated sequence was found, if beyond the counter
+	BucketHighSeqno  uint64   // Total of the high seqnos of the vbuckets of the database's buckets. 0 if unavailable
+	Anomalies        []string // Descriptions of the mismatches found
+	Severe           bool     // Whether any mismatch is larger than the severe sequence gap
+}
+
+// evaluate records the mismatches between the counter and the sequences it's compared with.  Every sequence allocated
+// is assigned to a revision or released, which are mutations of the bucket, so only sequences reserved and never
+// released can leave the counter ahead of the bucket's high seqnos.
+func (r *SequenceConsistencyReport) evaluate(severeGap uint64) {
+	if r.HighestAllocated > r.Counter {
+		gap := r.HighestAllocated - r.Counter
+		r.addAnomaly(gap > severeGap, "sequence counter %d is %d behind sequence %d found in %s", r.Counter, gap, r.HighestAllocated, r.AllocatedSource)
+	}
+	if r.BucketHighSeqno > 0 && r.Counter > r.BucketHighSeqno {
+		gap := r.Counter - r.BucketHighSeqno
+		r.addAnomaly(gap > severeGap, "sequence counter %d is %d ahead of the bucket's high seqnos, totalling %d", r.Counter, gap, r.BucketHighSeqno)
+	}
+}
+
+func (r *SequenceConsistencyReport) addAnomaly(severe bool, format string, args ...interface{}) {
+	r.Anomalies = append(r.Anomalies, fmt.Sprintf(format, args...))
+	r.Severe = r.Severe || severe
+}
+
+// checkSequenceConsistency compares the sequence counter with the sequences already allocated and the bucket's high
+// seqnos, logging any mismatch and recording the number found in the sequence_consistency_anomalies stat.  Returns
+// ErrSequenceInconsistent if the check is strict and a mismatch is severe.
+func (context *DatabaseContext) checkSequenceConsistency(ctx context.Context) (*SequenceConsistencyReport, error) {
+	options := context.Options.SequenceCheckOptions
+	counter, err := context.sequences.getSequence()
+	if err != nil {
+		return nil, err
+	}
+	fence, err := context.getSequenceFence()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SequenceConsistencyReport{Counter: counter}
+	report.HighestAllocated, report.AllocatedSource = context.highestAllocatedSequence(ctx, counter, fence)
+	report.BucketHighSeqno = context.bucketHighSeqno(ctx)
+
+	severeGap := options.SevereSequenceGap
+	if severeGap == 0 {
+		severeGap = DefaultSevereSequenceGap
+	}
+	report.evaluate(severeGap)
+
+	context.DbStats.Database().SequenceConsistencyAnomalies.Set(int64(len(report.Anomalies)))
+	if len(report.Anomalies) == 0 {
+		base.InfofCtx(ctx, base.KeyCRUD, "Startup consistency check found sequence counter %d consistent with the bucket", counter)
+		return report, nil
+	}
+	for _, anomaly := range report.Anomalies {
+		base.WarnfCtx(ctx, "Startup consistency check: %s. The bucket's metadata may be corrupted, or from another bucket.", anomaly)
+	}
+	if options.Strict && report.Severe {
+		return report, fmt.Errorf("%w: %d mismatches found, keeping database offline", ErrSequenceInconsistent, len(report.Anomalies))
+	}
+	return report, nil
+}
+
+// bucketHighSeqno returns the total of the high seqnos of every vbucket of the database's buckets, or 0 if they can't
+// be retrieved from any of the buckets.
+func (context *DatabaseContext) bucketHighSeqno(ctx context.Context) uint64 {
+	buckets := []base.Bucket{context.Bucket}
+	for _, bucket := range context.Options.FederatedBuckets {
+		buckets = append(buckets, bucket)
+	}
+	total := uint64(0)
+	for _, bucket := range buckets {
+		store, ok := base.AsCouchbaseBucketStore(bucket)
+		if !ok {
+			base.DebugfCtx(ctx, base.KeyCRUD, "Startup consistency check skipping high seqnos of bucket %s, which doesn't provide them", base.MD(bucket.GetName()))
+			return 0
+		}
+		maxVbno, err := store.GetMaxVbno()
+		if err != nil {
+			base.WarnfCtx(ctx, "Startup consistency check unable to get the number of vbuckets of bucket %s: %v", base.MD(bucket.GetName()), err)
+			return 0
+		}
+		_, highSeqnos, err := store.GetStatsVbSeqno(maxVbno, true)
+		if err != nil {
+			base.WarnfCtx(ctx, "Startup consistency check unable to get the high seqnos of bucket %s: %v", base.MD(bucket.GetName()), err)
+			return 0
+		}
+		for _, highSeqno := range highSeqnos {
+			total += highSeqno
+		}
+	}
+	return total
+}
@@ -0,0 +1,72 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequenceConsistencyReportEvaluate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		report    SequenceConsistencyReport
+		anomalies int
+		severe    bool
+	}{
+		{name: "consistent", report: SequenceConsistencyReport{Counter: 100, HighestAllocated: 100, BucketHighSeqno: 150}},
+		{name: "bucket high seqnos unavailable", report: SequenceConsistencyReport{Counter: 100, HighestAllocated: 100}},
+		{name: "behind allocated", report: SequenceConsistencyReport{Counter: 100, HighestAllocated: 105, BucketHighSeqno: 150}, anomalies: 1},
+		{name: "far behind allocated", report: SequenceConsistencyReport{Counter: 100, HighestAllocated: 200, BucketHighSeqno: 250}, anomalies: 1, severe: true},
+		{name: "ahead of bucket", report: SequenceConsistencyReport{Counter: 100, HighestAllocated: 100, BucketHighSeqno: 95}, anomalies: 1},
+		{name: "far ahead of bucket", report: SequenceConsistencyReport{Counter: 100, HighestAllocated: 100, BucketHighSeqno: 10}, anomalies: 1, severe: true},
+		{name: "behind and ahead", report: SequenceConsistencyReport{Counter: 100, HighestAllocated: 105, BucketHighSeqno: 95}, anomalies: 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.report.evaluate(50)
+			assert.Len(t, tc.report.Anomalies, tc.anomalies)
+			assert.Equal(t, tc.severe, tc.report.Severe)
+		})
+	}
+}
+
+func TestCheckSequenceConsistency(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	anomalies := db.DbStats.Database().SequenceConsistencyAnomalies
+
+	report, err := db.checkSequenceConsistency(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, report.Anomalies)
+	assert.Equal(t, int64(0), anomalies.Value())
+
+	// A fence beyond the counter means the counter has been restored or replaced
+	counter, err := db.sequences.getSequence()
+	require.NoError(t, err)
+	require.NoError(t, db.recordSequenceFence(ctx, counter+100))
+	db.Options.SequenceCheckOptions = SequenceCheckOptions{Enabled: true, SevereSequenceGap: 10}
+	report, err = db.checkSequenceConsistency(ctx)
+	require.NoError(t, err)
+	require.Len(t, report.Anomalies, 1)
+	assert.True(t, report.Severe)
+	assert.Equal(t, counter+100, report.HighestAllocated)
+	assert.Equal(t, int64(1), anomalies.Value())
+
+	// Only a strict check fails on a severe mismatch
+	db.Options.SequenceCheckOptions.Strict = true
+	_, err = db.checkSequenceConsistency(ctx)
+	assert.ErrorIs(t, err, ErrSequenceInconsistent)
+
+	db.Options.SequenceCheckOptions.SevereSequenceGap = 1000
+	_, err = db.checkSequenceConsistency(ctx)
+	assert.NoError(t, err)
+}
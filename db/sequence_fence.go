@@ -0,0 +1,276 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+)
+
+const (
+	// sequenceFenceInterval is how often the sequence counter's value is recorded in the sequence fence.
+	sequenceFenceInterval = time.Minute
+
+	// sequenceFenceQueryLimit is the page size used when searching for documents with sequences beyond the counter.
+	sequenceFenceQueryLimit = 1000
+)
+
+// sequenceFence is stored in the metadata store to detect the sequence counter moving backwards, for example after
+// the bucket is flushed or restored from a backup.  Each time the counter is found to have moved backwards it's
+// advanced past the highest sequence known to have been allocated, and a new epoch is started.  Sequences sent to
+// clients after the first epoch include the epoch, and a client whose since value is from a different epoch replicates
+// again from the start, as its checkpoint may have passed sequences allocated since.
+type sequenceFence struct {
+	Epoch      uint64    `json:"epoch"`
+	HighSeq    uint64    `json:"high_seq"`                  // Highest sequence known to have been allocated
+	EpochStart uint64    `json:"epoch_start_seq,omitempty"` // Sequence counter value when the epoch started
+	EpochTime  time.Time `json:"epoch_time"`                // Time the epoch started
+}
+
+// checkSequenceFence detects the sequence counter being lower than sequences already allocated, which would otherwise
+// cause new revisions to be given sequences the change cache and clients' checkpoints have already passed, and so
+// never be replicated.  The counter is compared with the highest sequence recorded in the fence, and the sequences
+// of documents in each collection.  If the counter has moved backwards, it's advanced and a new epoch is started
+// before the change cache is started from it.
+func (context *DatabaseContext) checkSequenceFence(ctx context.Context) error {
+	current, err := context.sequences.getSequence()
+	if err != nil {
+		return err
+	}
+	fence, err := context.getSequenceFence()
+	if err != nil {
+		return err
+	}
+
+	highSeq, source := context.highestAllocatedSequence(ctx, current, fence)
+	if highSeq <= current {
+		return context.recordSequenceFence(ctx, current)
+	}
+
+	context.DbStats.Database().SequenceRegressionCount.Add(1)
+	base.WarnfCtx(ctx, "The sequence counter %s is %d, lower than sequence %d found in %s. The bucket may have been "+
+		"flushed or restored from a backup. Advancing the sequence counter past %d and starting a new sequence epoch, "+
+		"so that new revisions are replicated. Clients with checkpoints ahead of the sequence counter will replicate "+
+		"again from the start.", base.MD(context.MetadataKeys.SyncSeqKey()), current, highSeq, source, highSeq)
+	advancedSeq, err := context.sequences.advanceSequence(ctx, highSeq)
+	if err != nil {
+		return err
+	}
+	return context.startSequenceEpoch(ctx, fence.Epoch, advancedSeq)
+}
+
+// highestAllocatedSequence returns the highest sequence known to have been allocated, from the sequence counter value
+// current, the highest sequence recorded in the fence, and the sequences of documents in each collection, along with
+// where it was found.
+func (context *DatabaseContext) highestAllocatedSequence(ctx context.Context, current uint64, fence *sequenceFence) (highSeq uint64, source string) {
+	highSeq = current
+	if fence.HighSeq > highSeq {
+		highSeq = fence.HighSeq
+		source = "the sequence fence"
+	}
+	for _, collection := range context.CollectionByID {
+		collectionHighSeq, err := collection.highestSequenceAfter(ctx, highSeq)
+		if err != nil {
+			base.WarnfCtx(ctx, "Unable to check %s.%s for documents with sequences beyond the sequence counter: %v", base.MD(collection.ScopeName), base.MD(collection.Name), err)
+			continue
+		}
+		if collectionHighSeq > highSeq {
+			highSeq = collectionHighSeq
+			source = "documents in " + collection.ScopeName + "." + collection.Name
+		}
+	}
+	return highSeq, source
+}
+
+// getSequenceFence returns the stored sequence fence, or an empty fence if none has been stored.
+func (context *DatabaseContext) getSequenceFence() (*sequenceFence, error) {
+	fence := &sequenceFence{}
+	_, err := context.MetadataStore.Get(context.MetadataKeys.SeqFenceKey(), fence)
+	if err != nil && !base.IsDocNotFoundError(err) {
+		return nil, err
+	}
+	return fence, nil
+}
+
+// recordSequenceFence raises the highest sequence recorded in the fence to highSeq, and picks up any epoch started by
+// another node.
+func (context *DatabaseContext) recordSequenceFence(ctx context.Context, highSeq uint64) error {
+	var epoch uint64
+	_, err := context.MetadataStore.Update(context.MetadataKeys.SeqFenceKey(), 0, func(current []byte) (updated []byte, expiry *uint32, delete bool, err error) {
+		fence := sequenceFence{}
+		if len(current) > 0 {
+			if err := base.JSONUnmarshal(current, &fence); err != nil {
+				return nil, nil, false, err
+			}
+		}
+		epoch = fence.Epoch
+		if fence.Epoch > 0 && fence.HighSeq >= highSeq {
+			return nil, nil, false, base.ErrUpdateCancel
+		}
+		if fence.Epoch == 0 {
+			fence.Epoch = 1
+			fence.EpochTime = time.Now().UTC()
+			epoch = fence.Epoch
+		}
+		fence.HighSeq = highSeq
+		updated, err = base.JSONMarshal(fence)
+		return updated, nil, false, err
+	})
+	if err != nil && err != base.ErrUpdateCancel {
+		return err
+	}
+	context.setSequenceEpoch(ctx, epoch)
+	return nil
+}
+
+// startSequenceEpoch starts a new epoch from the advanced sequence counter, unless another node has already started
+// one since previousEpoch was read.
+func (context *DatabaseContext) startSequenceEpoch(ctx context.Context, previousEpoch, startSeq uint64) error {
+	var epoch uint64
+	_, err := context.MetadataStore.Update(context.MetadataKeys.SeqFenceKey(), 0, func(current []byte) (updated []byte, expiry *uint32, delete bool, err error) {
+		fence := sequenceFence{}
+		if len(current) > 0 {
+			if err := base.JSONUnmarshal(current, &fence); err != nil {
+				return nil, nil, false, err
+			}
+		}
+		if fence.Epoch > previousEpoch {
+			epoch = fence.Epoch
+			return nil, nil, false, base.ErrUpdateCancel
+		}
+		fence.Epoch = previousEpoch + 1
+		fence.HighSeq = startSeq
+		fence.EpochStart = startSeq
+		fence.EpochTime = time.Now().UTC()
+		epoch = fence.Epoch
+		updated, err = base.JSONMarshal(fence)
+		return updated, nil, false, err
+	})
+	if err != nil && err != base.ErrUpdateCancel {
+		return err
+	}
+	base.InfofCtx(ctx, base.KeyAll, "Sequence epoch %d started from sequence %d", epoch, startSeq)
+	context.setSequenceEpoch(ctx, epoch)
+	return nil
+}
+
+// handleSequenceRegression starts a new epoch when the sequence allocator finds the counter has moved backwards while
+// the database is online, and has advanced it to advancedSeq.
+func (context *DatabaseContext) handleSequenceRegression(ctx context.Context, advancedSeq uint64) {
+	if err := context.startSequenceEpoch(ctx, atomic.LoadUint64(&context.sequenceEpoch), advancedSeq); err != nil {
+		base.ErrorfCtx(ctx, "Unable to start a new sequence epoch after the sequence counter moved backwards - take the "+
+			"database offline and back online to reinitialize the change cache: %v", err)
+	}
+}
+
+// setSequenceEpoch records the current epoch read from the sequence fence.  When a later epoch is started while the
+// database is online, the change cache is cleared and changes feeds are terminated, so that clients reconnect and
+// replicate again from the start.
+func (context *DatabaseContext) setSequenceEpoch(ctx context.Context, epoch uint64) {
+	var previous uint64
+	for {
+		previous = atomic.LoadUint64(&context.sequenceEpoch)
+		if epoch <= previous {
+			return
+		}
+		if atomic.CompareAndSwapUint64(&context.sequenceEpoch, previous, epoch) {
+			break
+		}
+	}
+	if previous == 0 {
+		return
+	}
+	base.WarnfCtx(ctx, "Sequence epoch %d started while the database is online. Reinitializing the change cache, and "+
+		"replicating to clients again from the start.", epoch)
+	if err := context.changeCache.Clear(ctx); err != nil {
+		base.WarnfCtx(ctx, "Unable to clear the change cache for sequence epoch %d: %v", epoch, err)
+	}
+	context.mutationListener.NotifyCheckForTermination(ctx, base.SetOf(channels.UserStarChannel))
+}
+
+// SinceForCurrentEpoch returns since if it was sent in the current sequence epoch.  Otherwise the client's checkpoint
+// predates the sequence counter moving backwards, so may have passed sequences allocated since, and a zero since value
+// is returned so the client replicates again from the start.
+func (context *DatabaseContext) SinceForCurrentEpoch(ctx context.Context, since SequenceID) SequenceID {
+	epoch := context.sequenceIDEpoch()
+	if since.Epoch == epoch {
+		return since
+	}
+	if since.IsNonZero() {
+		base.InfofCtx(ctx, base.KeyChanges, "Since value %s is from a different sequence epoch to the current epoch %d, so predates the sequence counter moving backwards. Replicating from the start.", since, epoch)
+	}
+	return SequenceID{Epoch: epoch}
+}
+
+// sequenceIDEpoch returns the epoch to include in sequences sent to clients, which is zero for the first epoch so that
+// sequences are unchanged until the sequence counter first moves backwards.
+func (context *DatabaseContext) sequenceIDEpoch() uint64 {
+	if epoch := atomic.LoadUint64(&context.sequenceEpoch); epoch > 1 {
+		return epoch
+	}
+	return 0
+}
+
+// highestSequenceAfter returns the highest sequence of the documents in the collection with sequences greater than
+// since, or zero if there are none.
+func (c *DatabaseCollection) highestSequenceAfter(ctx context.Context, since uint64) (uint64, error) {
+	highSeq := uint64(0)
+	for {
+		results, err := c.QueryResync(ctx, sequenceFenceQueryLimit, since+1, 0)
+		if err != nil {
+			return 0, err
+		}
+		rowCount := 0
+		for {
+			var seq uint64
+			if c.useViews() {
+				var viewRow channelsViewRow
+				if !results.Next(ctx, &viewRow) {
+					break
+				}
+				seq = uint64(viewRow.Key[1].(float64))
+			} else {
+				var row QueryIdRow
+				if !results.Next(ctx, &row) {
+					break
+				}
+				seq = row.Seq
+			}
+			rowCount++
+			if seq > highSeq {
+				highSeq = seq
+			}
+		}
+		if err := results.Close(); err != nil {
+			return 0, err
+		}
+		if rowCount < sequenceFenceQueryLimit || highSeq <= since {
+			return highSeq, nil
+		}
+		since = highSeq
+	}
+}
+
+// sequenceAheadOfCounter returns true if seq is higher than the sequence counter, as for a checkpoint made before the
+// bucket was flushed or restored from a backup.
+func (context *DatabaseContext) sequenceAheadOfCounter(ctx context.Context, seq uint64) (bool, error) {
+	// Sequences up to the last allocated by this node are known to be behind the counter without reading it
+	if last, err := context.sequences.lastSequence(ctx); err != nil || seq <= last {
+		return false, err
+	}
+	current, err := context.sequences.getSequence()
+	if err != nil {
+		return false, err
+	}
+	return seq > current, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// resetSequenceCounter sets the sequence counter to seq, as when the bucket is restored from a backup.
+func resetSequenceCounter(t *testing.T, db *DatabaseContext, seq uint64) {
+	require.NoError(t, db.MetadataStore.Delete(db.MetadataKeys.SyncSeqKey()))
+	_, err := db.MetadataStore.Incr(db.MetadataKeys.SyncSeqKey(), seq, seq, 0)
+	require.NoError(t, err)
+}
+
+func TestCheckSequenceFence(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+
+	// The fence is created when the database starts
+	fence, err := db.getSequenceFence()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), fence.Epoch)
+
+	for _, docID := range []string{"doc1", "doc2", "doc3"} {
+		_, _, err := collection.Put(ctx, docID, Body{"foo": "bar"})
+		require.NoError(t, err)
+	}
+	doc, err := collection.GetDocument(ctx, "doc3", DocUnmarshalSync)
+	require.NoError(t, err)
+	highSeq := doc.Sequence
+
+	// Nothing changes while the counter is ahead of every sequence allocated
+	counter, err := db.sequences.getSequence()
+	require.NoError(t, err)
+	require.NoError(t, db.checkSequenceFence(ctx))
+	fence, err = db.getSequenceFence()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), fence.Epoch)
+	assert.Equal(t, counter, fence.HighSeq)
+	assert.Equal(t, int64(0), db.DbStats.Database().SequenceRegressionCount.Value())
+
+	// Restoring the counter and fence from a backup leaves documents with sequences beyond them
+	require.NoError(t, db.MetadataStore.Set(db.MetadataKeys.SeqFenceKey(), 0, nil, sequenceFence{Epoch: 1, HighSeq: 1}))
+	resetSequenceCounter(t, db.DatabaseContext, 1)
+	require.NoError(t, db.checkSequenceFence(ctx))
+	counter, err = db.sequences.getSequence()
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, counter, highSeq)
+	assert.Equal(t, int64(1), db.DbStats.Database().SequenceRegressionCount.Value())
+	fence, err = db.getSequenceFence()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), fence.Epoch)
+	assert.Equal(t, counter, fence.EpochStart)
+
+	// The fence detects the counter moving backwards even when no documents are beyond it
+	require.NoError(t, db.recordSequenceFence(ctx, counter+10))
+	resetSequenceCounter(t, db.DatabaseContext, counter)
+	require.NoError(t, db.checkSequenceFence(ctx))
+	counter, err = db.sequences.getSequence()
+	require.NoError(t, err)
+	assert.Equal(t, fence.EpochStart+10, counter)
+	fence, err = db.getSequenceFence()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), fence.Epoch)
+}
+
+func TestSequenceAheadOfCounter(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+
+	_, _, err := GetSingleDatabaseCollectionWithUser(t, db).Put(ctx, "doc1", Body{"foo": "bar"})
+	require.NoError(t, err)
+	counter, err := db.sequences.getSequence()
+	require.NoError(t, err)
+
+	ahead, err := db.sequenceAheadOfCounter(ctx, counter)
+	require.NoError(t, err)
+	assert.False(t, ahead)
+	ahead, err = db.sequenceAheadOfCounter(ctx, 0)
+	require.NoError(t, err)
+	assert.False(t, ahead)
+	ahead, err = db.sequenceAheadOfCounter(ctx, counter+1)
+	require.NoError(t, err)
+	assert.True(t, ahead)
+}
+
+func TestSequenceEpochSinceValues(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+
+	for _, docID := range []string{"doc1", "doc2"} {
+		_, _, err := collection.Put(ctx, docID, Body{"foo": "bar"})
+		require.NoError(t, err)
+	}
+	require.NoError(t, collection.WaitForPendingChanges(ctx))
+
+	// Sequences in the first epoch don't include it
+	changes, err := collection.GetChanges(ctx, base.SetOf("*"), getChangesOptionsWithZeroSeq(t))
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, uint64(0), changes[1].Seq.Epoch)
+	lastSeq := changes[1].Seq
+	assert.Equal(t, lastSeq, db.SinceForCurrentEpoch(ctx, lastSeq))
+
+	// Starting a new epoch while online resets since values from the previous epoch
+	counter, err := db.sequences.getSequence()
+	require.NoError(t, err)
+	require.NoError(t, db.startSequenceEpoch(ctx, 1, counter))
+	assert.Equal(t, SequenceID{Epoch: 2}, db.SinceForCurrentEpoch(ctx, lastSeq))
+
+	changes, err = collection.GetChanges(ctx, base.SetOf("*"), getChangesOptionsWithSeq(t, lastSeq))
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, "doc1", changes[0].ID)
+	for _, change := range changes {
+		assert.Equal(t, uint64(2), change.Seq.Epoch)
+	}
+
+	// Since values from the current epoch are kept
+	lastSeq = changes[1].Seq
+	assert.Equal(t, fmt.Sprintf("2@%d", lastSeq.Seq), lastSeq.String())
+	assert.Equal(t, lastSeq, db.SinceForCurrentEpoch(ctx, lastSeq))
+	changes, err = collection.GetChanges(ctx, base.SetOf("*"), getChangesOptionsWithSeq(t, lastSeq))
+	require.NoError(t, err)
+	assert.Len(t, changes, 0)
+}
@@ -34,6 +34,7 @@ type SequenceID struct {
 	TriggeredBy uint64 // Int sequence: The sequence # that triggered this (0 if none)
 	LowSeq      uint64 // Int sequence: Lowest contiguous sequence seen on the feed
 	Seq         uint64 // Int sequence: The actual internal sequence
+	Epoch       uint64 // Sequence epoch the sequence was sent in, zero for the first epoch (see sequenceFence)
 }
 
 var MaxSequenceID = SequenceID{
@@ -48,7 +49,11 @@ var MaxSequenceID = SequenceID{
 //
 // When LowSeq is non-zero but TriggeredBy is zero, will appear as LowSeq::Seq.
 // When LowSeq is non-zero but is greater than s.Seq (occurs when sending previously skipped sequences), ignore LowSeq.
+// When Epoch is non-zero, any of the above is prefixed with Epoch@.
 func (s SequenceID) String() string {
+	if s.Epoch > 0 {
+		return strconv.FormatUint(s.Epoch, 10) + "@" + s.intSeqToString()
+	}
 	return s.intSeqToString()
 }
 
@@ -95,6 +100,21 @@ func parseIntegerSequenceID(str string) (SequenceID, error) {
 	if str == "" {
 		return SequenceID{}, nil
 	}
+	if epochStr, seqStr, found := strings.Cut(str, "@"); found {
+		epoch, err := ParseIntSequenceComponent(epochStr, false)
+		if err != nil {
+			return SequenceID{}, base.HTTPErrorf(400, "Invalid sequence")
+		}
+		if strings.Contains(seqStr, "@") {
+			return SequenceID{}, base.HTTPErrorf(400, "Invalid sequence")
+		}
+		s, err := parseIntegerSequenceID(seqStr)
+		if err != nil {
+			return SequenceID{}, err
+		}
+		s.Epoch = epoch
+		return s, nil
+	}
 	s := SequenceID{}
 	components := strings.Split(str, ":")
 	var err error
@@ -141,7 +161,7 @@ func ParseIntSequenceComponent(component string, allowEmpty bool) (uint64, error
 
 func (s SequenceID) MarshalJSON() ([]byte, error) {
 
-	if s.TriggeredBy > 0 || s.LowSeq > 0 {
+	if s.TriggeredBy > 0 || s.LowSeq > 0 || s.Epoch > 0 {
 		return []byte(fmt.Sprintf("\"%s\"", s.String())), nil
 	} else {
 		return []byte(strconv.FormatUint(s.Seq, 10)), nil
@@ -58,6 +58,21 @@ func TestParseSequenceID(t *testing.T) {
 	s, err = parseIntegerSequenceID("123:ggg")
 	require.Error(t, err)
 	require.Equal(t, SequenceID{}, s)
+
+	s, err = parseIntegerSequenceID("2@1234")
+	assert.NoError(t, err, "parseIntegerSequenceID")
+	assert.Equal(t, SequenceID{Seq: 1234, Epoch: 2}, s)
+
+	s, err = parseIntegerSequenceID("3@123:456:789")
+	assert.NoError(t, err, "parseIntegerSequenceID")
+	assert.Equal(t, SequenceID{Seq: 789, TriggeredBy: 456, LowSeq: 123, Epoch: 3}, s)
+
+	s, err = parseIntegerSequenceID("@1234")
+	require.Error(t, err)
+	require.Equal(t, SequenceID{}, s)
+	s, err = parseIntegerSequenceID("2@3@1234")
+	require.Error(t, err)
+	require.Equal(t, SequenceID{}, s)
 }
 
 func BenchmarkParseSequenceID(b *testing.B) {
@@ -98,6 +113,19 @@ func TestMarshalSequenceID(t *testing.T) {
 	assert.Equal(t, s, s2)
 }
 
+func TestMarshalEpochSequenceID(t *testing.T) {
+	s := SequenceID{Seq: 1234, Epoch: 2}
+	assert.Equal(t, "2@1234", s.String())
+	asJson, err := base.JSONMarshal(s)
+	assert.NoError(t, err, "Marshal failed")
+	assert.Equal(t, "\"2@1234\"", string(asJson))
+
+	var s2 SequenceID
+	err = base.JSONUnmarshal(asJson, &s2)
+	assert.NoError(t, err, "Unmarshal failed")
+	assert.Equal(t, s, s2)
+}
+
 func TestSequenceIDUnmarshalJSON(t *testing.T) {
 
 	str := "123"
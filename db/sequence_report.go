@@ -0,0 +1,170 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+const (
+	// DefaultSequenceReportLimit is the default maximum number of pending and skipped sequences included in a sequence
+	// report.
+	DefaultSequenceReportLimit = 1000
+
+	// MaxSequenceReportLimit is the largest limit of pending and skipped sequences that can be requested.
+	MaxSequenceReportLimit = 100000
+)
+
+// SequenceReport summarizes the state of sequence buffering on this node, to help correlate documents missing from
+// changes feeds with skipped, pending and unused sequences.
+type SequenceReport struct {
+	GeneratedAt      time.Time                           `json:"generated_at"`
+	LastSequence     uint64                              `json:"last_sequence"`       // Last sequence allocated for the database
+	NextSequence     uint64                              `json:"next_sequence"`       // Next sequence the cache is waiting for
+	StableSequence   uint64                              `json:"stable_sequence"`     // Sequence the cache is stable to, below the oldest skipped sequence
+	PendingCount     int                                 `json:"pending_count"`       // Number of sequences received ahead of next_sequence
+	PendingSequences []PendingSequenceInfo               `json:"pending_sequences"`   // Pending sequences, oldest first, up to limit
+	SkippedCount     int                                 `json:"skipped_count"`       // Number of sequences that were skipped and haven't arrived
+	SkippedSequences []SkippedSequenceInfo               `json:"skipped_sequences"`   // Skipped sequences, oldest first, up to limit
+	UnusedSequences  UnusedSequenceCounts                `json:"unused_sequences"`    // Counts of unused sequences released and abandoned
+	Collections      map[string]CollectionSequenceReport `json:"collections"`         // Per collection summary, keyed by scope.collection
+	PendingMaxWaitMs int64                               `json:"pending_max_wait_ms"` // Time pending sequences are buffered before next_sequence is skipped
+	PendingMaxNum    int                                 `json:"pending_max_num"`     // Number of pending sequences buffered before next_sequence is skipped
+	SkippedMaxWaitMs int64                               `json:"skipped_max_wait_ms"` // Time skipped sequences are waited for before being abandoned
+}
+
+// PendingSequenceInfo is a sequence that has been received by the cache before an earlier sequence.
+type PendingSequenceInfo struct {
+	Sequence   uint64 `json:"seq"`
+	DocID      string `json:"doc_id,omitempty"`
+	RevID      string `json:"rev_id,omitempty"`
+	Collection string `json:"collection,omitempty"`
+	AgeMs      int64  `json:"age_ms"`
+}
+
+// SkippedSequenceInfo is a sequence that the cache stopped waiting for, and how long ago it was skipped.
+type SkippedSequenceInfo struct {
+	Sequence uint64 `json:"seq"`
+	AgeMs    int64  `json:"age_ms"`
+}
+
+// UnusedSequenceCounts are the number of unused sequences released by this node, and skipped sequences that were
+// abandoned after not arriving within the skipped sequence wait time.
+type UnusedSequenceCounts struct {
+	Released  int64 `json:"released"`
+	Skipped   int64 `json:"skipped"`
+	Abandoned int64 `json:"abandoned"`
+}
+
+// CollectionSequenceReport summarizes the sequences for a collection.  All collections share the database's sequences,
+// so the stable sequence of a collection is the database's stable sequence, further held back by any of the
+// collection's own pending sequences.
+type CollectionSequenceReport struct {
+	StableSequence        uint64 `json:"stable_sequence"`
+	PendingCount          int    `json:"pending_count"`
+	OldestPendingSequence uint64 `json:"oldest_pending_sequence,omitempty"`
+}
+
+// GetSequenceReport returns a report of the current sequence buffering state for the database.  Up to limit pending and
+// skipped sequences are listed.
+func (context *DatabaseContext) GetSequenceReport(ctx context.Context, limit int) (*SequenceReport, error) {
+	lastSequence, err := context.LastSequence(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	report := &SequenceReport{
+		GeneratedAt:      now,
+		LastSequence:     lastSequence,
+		Collections:      make(map[string]CollectionSequenceReport, len(context.CollectionByID)),
+		PendingMaxWaitMs: context.changeCache.options.CachePendingSeqMaxWait.Milliseconds(),
+		PendingMaxNum:    context.changeCache.options.CachePendingSeqMaxNum,
+		SkippedMaxWaitMs: context.changeCache.options.CacheSkippedSeqMaxWait.Milliseconds(),
+		UnusedSequences: UnusedSequenceCounts{
+			Released:  context.DbStats.Database().SequenceReleasedCount.Value(),
+			Skipped:   context.DbStats.Cache().NumSkippedSeqs.Value(),
+			Abandoned: context.DbStats.Cache().AbandonedSeqs.Value(),
+		},
+	}
+
+	pending := context.changeCache.getPendingEntries()
+	report.PendingCount = len(pending)
+
+	report.SkippedCount, report.SkippedSequences = context.changeCache.skippedSeqs.getSkippedInfo(now, limit)
+	report.NextSequence = context.changeCache.getNextSequence()
+	report.StableSequence = report.NextSequence - 1
+	if oldestSkipped := context.changeCache.getOldestSkippedSequence(ctx); oldestSkipped > 0 && oldestSkipped-1 < report.StableSequence {
+		report.StableSequence = oldestSkipped - 1
+	}
+
+	for _, collection := range context.CollectionByID {
+		report.Collections[collection.ScopeName+"."+collection.Name] = CollectionSequenceReport{StableSequence: report.StableSequence}
+	}
+
+	report.PendingSequences = make([]PendingSequenceInfo, 0, base.Min(len(pending), limit))
+	for _, entry := range pending {
+		var collectionName string
+		if collection, ok := context.CollectionByID[entry.CollectionID]; ok {
+			collectionName = collection.ScopeName + "." + collection.Name
+			collectionReport := report.Collections[collectionName]
+			if collectionReport.PendingCount == 0 {
+				collectionReport.OldestPendingSequence = entry.Sequence
+				if entry.Sequence-1 < collectionReport.StableSequence {
+					collectionReport.StableSequence = entry.Sequence - 1
+				}
+			}
+			collectionReport.PendingCount++
+			report.Collections[collectionName] = collectionReport
+		}
+		if len(report.PendingSequences) < limit {
+			report.PendingSequences = append(report.PendingSequences, PendingSequenceInfo{
+				Sequence:   entry.Sequence,
+				DocID:      entry.DocID,
+				RevID:      entry.RevID,
+				Collection: collectionName,
+				AgeMs:      now.Sub(entry.TimeReceived).Milliseconds(),
+			})
+		}
+	}
+
+	return report, nil
+}
+
+// getPendingEntries returns a copy of the pending log entries, ordered by sequence.
+func (c *changeCache) getPendingEntries() []LogEntry {
+	c.lock.RLock()
+	pending := make([]LogEntry, 0, len(c.pendingLogs))
+	for _, entry := range c.pendingLogs {
+		pending = append(pending, *entry)
+	}
+	c.lock.RUnlock()
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Sequence < pending[j].Sequence })
+	return pending
+}
+
+// getSkippedInfo returns the number of skipped sequences, and the oldest of them up to limit.
+func (l *SkippedSequenceList) getSkippedInfo(now time.Time, limit int) (count int, skipped []SkippedSequenceInfo) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	count = l.skippedList.Len()
+	skipped = make([]SkippedSequenceInfo, 0, base.Min(count, limit))
+	for e := l.skippedList.Front(); e != nil && len(skipped) < limit; e = e.Next() {
+		skippedSeq := e.Value.(*SkippedSequence)
+		skipped = append(skipped, SkippedSequenceInfo{
+			Sequence: skippedSeq.seq,
+			AgeMs:    now.Sub(skippedSeq.timeAdded).Milliseconds(),
+		})
+	}
+	return count, skipped
+}
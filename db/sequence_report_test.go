@@ -0,0 +1,87 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequenceReport(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+
+	collection := GetSingleDatabaseCollection(t, db.DatabaseContext)
+	collectionName := collection.ScopeName + "." + collection.Name
+
+	report, err := db.GetSequenceReport(ctx, DefaultSequenceReportLimit)
+	require.NoError(t, err)
+	nextSequence := report.NextSequence
+	assert.Equal(t, nextSequence-1, report.StableSequence)
+	assert.Equal(t, 0, report.PendingCount)
+	assert.Equal(t, 0, report.SkippedCount)
+	require.Contains(t, report.Collections, collectionName)
+	assert.Equal(t, nextSequence-1, report.Collections[collectionName].StableSequence)
+
+	// Sequences arriving ahead of the next expected sequence are pending
+	for _, seq := range []uint64{nextSequence + 2, nextSequence + 1} {
+		db.changeCache.processEntry(ctx, &LogEntry{
+			Sequence:     seq,
+			DocID:        "doc1",
+			RevID:        "1-a",
+			CollectionID: collection.GetCollectionID(),
+			TimeReceived: time.Now(),
+		})
+	}
+	db.changeCache.PushSkipped(ctx, nextSequence+10)
+
+	report, err = db.GetSequenceReport(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, nextSequence, report.NextSequence)
+	assert.Equal(t, nextSequence-1, report.StableSequence)
+	assert.Equal(t, 2, report.PendingCount)
+	require.Len(t, report.PendingSequences, 1)
+	assert.Equal(t, nextSequence+1, report.PendingSequences[0].Sequence)
+	assert.Equal(t, "doc1", report.PendingSequences[0].DocID)
+	assert.Equal(t, collectionName, report.PendingSequences[0].Collection)
+	assert.Equal(t, 1, report.SkippedCount)
+	require.Len(t, report.SkippedSequences, 1)
+	assert.Equal(t, nextSequence+10, report.SkippedSequences[0].Sequence)
+
+	collectionReport := report.Collections[collectionName]
+	assert.Equal(t, 2, collectionReport.PendingCount)
+	assert.Equal(t, nextSequence+1, collectionReport.OldestPendingSequence)
+	assert.Equal(t, nextSequence-1, collectionReport.StableSequence)
+}
+
+func TestReleaseSkippedSequences(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+
+	for _, seq := range []uint64{10, 11, 12, 15, 20} {
+		db.changeCache.PushSkipped(ctx, seq)
+	}
+	abandonedSeqs := db.DbStats.Cache().AbandonedSeqs.Value()
+
+	// Sequences that aren't skipped are ignored
+	released := db.ReleaseSkippedSequences(ctx, []SequenceRange{{Start: 11, End: 16}, {Start: 3, End: 3}})
+	assert.Equal(t, []uint64{11, 12, 15}, released)
+	assert.Equal(t, abandonedSeqs+3, db.DbStats.Cache().AbandonedSeqs.Value())
+	assert.Equal(t, int64(2), db.DbStats.Cache().SkippedSeqLen.Value())
+	assert.True(t, db.changeCache.WasSkipped(10))
+	assert.False(t, db.changeCache.WasSkipped(11))
+	assert.True(t, db.changeCache.WasSkipped(20))
+
+	released = db.ReleaseSkippedSequences(ctx, []SequenceRange{{Start: 10, End: 10}})
+	assert.Equal(t, []uint64{10}, released)
+	assert.Equal(t, uint64(20), db.changeCache.getOldestSkippedSequence(ctx))
+}
@@ -0,0 +1,96 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"hash/crc32"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+)
+
+// stagedSyncSampleBuckets is the number of buckets document IDs are hashed into when sampling writes for the staged
+// sync function, allowing percentages to two decimal places.
+const stagedSyncSampleBuckets = 10000
+
+// StagedSyncFunction is a new sync function being rolled out to a subset of a collection's writes.  Writes of
+// documents tagged with the doc property, or sampled by document ID, use it in place of the collection's current sync
+// function.  Every write is also evaluated by the function it didn't use, so that writes the two functions disagree
+// on are counted before the staged function is fully activated.
+type StagedSyncFunction struct {
+	mapper      *channels.ChannelMapper
+	docProperty string  // Top-level property that selects the staged function when true in a document's body
+	percentage  float64 // Percentage of documents, sampled by document ID, that use the staged function
+}
+
+// NewStagedSyncFunction returns a staged sync function for the JS source fnSource.
+func NewStagedSyncFunction(ctx context.Context, fnSource string, docProperty string, percentage float64, timeout time.Duration) *StagedSyncFunction {
+	base.InfofCtx(ctx, base.KeyAll, "Staged sync function applies to documents with property %q and %.2f%% of other documents", base.MD(docProperty), percentage)
+	return &StagedSyncFunction{
+		mapper:      channels.NewChannelMapper(ctx, fnSource, timeout),
+		docProperty: docProperty,
+		percentage:  percentage,
+	}
+}
+
+// appliesTo returns true if a write of the document should use the staged function.  Sampling is by document ID, so
+// all revisions of a document use the same function.
+func (s *StagedSyncFunction) appliesTo(docID string, body Body) bool {
+	if s.docProperty != "" {
+		if tagged, _ := body[s.docProperty].(bool); tagged {
+			return true
+		}
+	}
+	if s.percentage <= 0 {
+		return false
+	}
+	return float64(crc32.ChecksumIEEE([]byte(docID))%stagedSyncSampleBuckets) < s.percentage*stagedSyncSampleBuckets/100
+}
+
+// compareStagedSyncFunction evaluates the write with the function it didn't use, and counts it as divergent if the
+// result differs from that of the function it used.
+func (col *DatabaseCollectionWithUser) compareStagedSyncFunction(ctx context.Context, mapper *channels.ChannelMapper, docID string, body Body, oldJson string, metaMap map[string]interface{}, output *channels.ChannelMapperOutput, err error) {
+	otherOutput, otherErr := mapper.MapToChannelsAndAccess(ctx, body, oldJson, metaMap, MakeUserCtx(col.user, col.ScopeName, col.Name))
+	if syncOutputsDiverge(output, err, otherOutput, otherErr) {
+		col.collectionStats.StagedSyncFunctionDivergenceCount.Add(1)
+		base.DebugfCtx(ctx, base.KeyCRUD, "Staged and current sync functions diverge for doc %q", base.UD(docID))
+	}
+}
+
+// syncOutputsDiverge returns true if two sync function results assign different channels or grants, or only one of
+// them rejects the write or throws an exception.
+func syncOutputsDiverge(output *channels.ChannelMapperOutput, err error, other *channels.ChannelMapperOutput, otherErr error) bool {
+	if err != nil || otherErr != nil {
+		return (err == nil) != (otherErr == nil)
+	}
+	if (output.Rejection == nil) != (other.Rejection == nil) {
+		return true
+	}
+	if output.Rejection != nil {
+		return false
+	}
+	return !output.Channels.Equals(other.Channels) ||
+		!accessMapsEqual(output.Access, other.Access) ||
+		!accessMapsEqual(output.Roles, other.Roles)
+}
+
+// accessMapsEqual returns true if both maps grant the same names to the same principals.
+func accessMapsEqual(a, b channels.AccessMap) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for principal, names := range a {
+		if !names.Equals(b[principal]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStagedSyncFunction(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+
+	_, err := collection.UpdateSyncFun(ctx, `function(doc) {channel("current");}`)
+	require.NoError(t, err)
+	collection.stagedSyncFunction = NewStagedSyncFunction(ctx, `function(doc) {channel(doc.owner ? "staged" : "current");}`, "useStaged", 0, time.Minute)
+
+	requireChannels := func(docID string, expected ...string) {
+		doc, err := collection.GetDocument(ctx, docID, DocUnmarshalAll)
+		require.NoError(t, err)
+		assert.Equal(t, base.SetFromArray(expected), doc.currentChannels())
+	}
+
+	// Untagged writes use the current function, and are only counted as divergent when the staged function differs
+	_, _, err = collection.Put(ctx, "doc1", Body{"owner": "alice"})
+	require.NoError(t, err)
+	requireChannels("doc1", "current")
+	assert.Equal(t, int64(0), collection.collectionStats.StagedSyncFunctionCount.Value())
+	assert.Equal(t, int64(1), collection.collectionStats.StagedSyncFunctionDivergenceCount.Value())
+
+	_, _, err = collection.Put(ctx, "doc2", Body{})
+	require.NoError(t, err)
+	requireChannels("doc2", "current")
+	assert.Equal(t, int64(1), collection.collectionStats.StagedSyncFunctionDivergenceCount.Value())
+
+	// Tagged writes use the staged function
+	_, _, err = collection.Put(ctx, "doc3", Body{"owner": "alice", "useStaged": true})
+	require.NoError(t, err)
+	requireChannels("doc3", "staged")
+	assert.Equal(t, int64(1), collection.collectionStats.StagedSyncFunctionCount.Value())
+	assert.Equal(t, int64(2), collection.collectionStats.StagedSyncFunctionDivergenceCount.Value())
+
+	// At 100%, every write uses the staged function
+	collection.stagedSyncFunction = NewStagedSyncFunction(ctx, `function(doc) {channel(doc.owner ? "staged" : "current");}`, "", 100, time.Minute)
+	_, _, err = collection.Put(ctx, "doc4", Body{"owner": "bob"})
+	require.NoError(t, err)
+	requireChannels("doc4", "staged")
+	assert.Equal(t, int64(2), collection.collectionStats.StagedSyncFunctionCount.Value())
+	assert.Equal(t, int64(3), collection.collectionStats.StagedSyncFunctionDivergenceCount.Value())
+}
+
+func TestStagedSyncFunctionSampling(t *testing.T) {
+	staged := &StagedSyncFunction{percentage: 25}
+	sampled := 0
+	for i := 0; i < 1000; i++ {
+		docID := fmt.Sprintf("doc%d", i)
+		if staged.appliesTo(docID, Body{}) {
+			sampled++
+		}
+		// Every revision of a document uses the same function
+		assert.Equal(t, staged.appliesTo(docID, Body{}), staged.appliesTo(docID, Body{"rev": 2}))
+	}
+	assert.InDelta(t, 250, sampled, 50)
+
+	staged.percentage = 0
+	assert.False(t, staged.appliesTo("doc1", Body{}))
+	staged.percentage = 100
+	assert.True(t, staged.appliesTo("doc1", Body{}))
+}
+
+func TestSyncOutputsDiverge(t *testing.T) {
+	output := func(channelNames ...string) *channels.ChannelMapperOutput {
+		return &channels.ChannelMapperOutput{
+			Channels: base.SetFromArray(channelNames),
+			Access:   channels.AccessMap{"alice": base.SetOf("a")},
+		}
+	}
+	exception := fmt.Errorf("exception")
+
+	assert.False(t, syncOutputsDiverge(output("a", "b"), nil, output("b", "a"), nil))
+	assert.True(t, syncOutputsDiverge(output("a"), nil, output("b"), nil))
+	assert.False(t, syncOutputsDiverge(nil, exception, nil, exception))
+	assert.True(t, syncOutputsDiverge(output("a"), nil, nil, exception))
+
+	granted := output("a")
+	granted.Access["bob"] = base.SetOf("b")
+	assert.True(t, syncOutputsDiverge(output("a"), nil, granted, nil))
+
+	rejected := output("b")
+	rejected.Rejection = base.HTTPErrorf(403, "forbidden")
+	assert.True(t, syncOutputsDiverge(output("a"), nil, rejected, nil))
+	assert.False(t, syncOutputsDiverge(rejected, nil, rejected, nil))
+}
@@ -0,0 +1,70 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// DefaultStatsPersistenceInterval is how often counters are persisted if stats persistence is enabled without an
+// interval.
+const DefaultStatsPersistenceInterval = time.Minute
+
+// StatsPersistenceOptions enables periodic persistence of the database's cumulative counter stats to the metadata
+// store, so that counters continue from their previous values after a restart rather than resetting to zero.
+type StatsPersistenceOptions struct {
+	Interval time.Duration // How often counters are persisted. 0 disables persistence
+	NodeID   string        // Identifies this node's persisted counters. Must be unique within the cluster, and the same across restarts
+}
+
+// restoreStats adds the counters persisted by this node before it restarted to the database's counters.  Counters
+// are only persisted once restored, since persisting counters that haven't been restored would lose their previous
+// values.
+func (db *DatabaseContext) restoreStats(ctx context.Context) error {
+	// Counters are only restored once, when the database is first brought online
+	if db.statsRestored {
+		return nil
+	}
+	var persisted base.PersistedCounters
+	_, err := db.MetadataStore.Get(db.MetadataKeys.StatsKey(db.Options.StatsPersistenceOptions.NodeID), &persisted)
+	if base.IsDocNotFoundError(err) {
+		base.InfofCtx(ctx, base.KeyAll, "Stats Persistence: No persisted counters found for node %s", base.MD(db.Options.StatsPersistenceOptions.NodeID))
+	} else if err != nil {
+		return err
+	} else {
+		db.DbStats.RestoreCounters(persisted)
+		base.InfofCtx(ctx, base.KeyAll, "Stats Persistence: Restored %d counters for node %s", len(persisted.Ints)+len(persisted.Floats), base.MD(db.Options.StatsPersistenceOptions.NodeID))
+	}
+	db.statsRestored = true
+	return nil
+}
+
+// persistStats persists the current values of the database's counters.  A persisted counter is never decreased, so
+// persisted counters are monotonic even if an earlier instance of the node is still persisting its counters.
+func (db *DatabaseContext) persistStats(ctx context.Context) error {
+	if !db.statsRestored {
+		return nil
+	}
+	counters := db.DbStats.PersistableCounters()
+	_, err := db.MetadataStore.Update(db.MetadataKeys.StatsKey(db.Options.StatsPersistenceOptions.NodeID), 0, func(current []byte) (updated []byte, expiry *uint32, delete bool, err error) {
+		var persisted base.PersistedCounters
+		if len(current) > 0 {
+			if err := base.JSONUnmarshal(current, &persisted); err != nil {
+				return nil, nil, false, err
+			}
+		}
+		persisted.Merge(counters)
+		updated, err = base.JSONMarshal(persisted)
+		return updated, nil, false, err
+	})
+	return err
+}
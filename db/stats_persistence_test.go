@@ -0,0 +1,61 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsPersistence(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	db.Options.StatsPersistenceOptions = StatsPersistenceOptions{Interval: time.Minute, NodeID: "node1"}
+	docWrites := db.DbStats.Database().NumDocWrites
+	restarted := db.DbStats.Database().StatsRestarted
+
+	// Counters aren't persisted until they've been restored
+	docWrites.Set(5)
+	require.NoError(t, db.persistStats(ctx))
+	var persisted base.PersistedCounters
+	_, err := db.MetadataStore.Get(db.MetadataKeys.StatsKey("node1"), &persisted)
+	require.True(t, base.IsDocNotFoundError(err))
+
+	require.NoError(t, db.restoreStats(ctx))
+	assert.Equal(t, int64(5), docWrites.Value())
+	assert.Equal(t, int64(0), restarted.Value())
+	require.NoError(t, db.persistStats(ctx))
+	_, err = db.MetadataStore.Get(db.MetadataKeys.StatsKey("node1"), &persisted)
+	require.NoError(t, err)
+	require.Len(t, persisted.Ints, len(db.DbStats.PersistableCounters().Ints))
+	assert.NotContains(t, persisted.Ints, restarted.Name(), "gauges shouldn't be persisted")
+
+	// After a restart, counters continue from their persisted values, including increments made before the restore
+	docWrites.Set(1)
+	db.statsRestored = false
+	require.NoError(t, db.restoreStats(ctx))
+	assert.Equal(t, int64(6), docWrites.Value())
+	assert.Equal(t, int64(1), restarted.Value())
+
+	// Counters are only restored once
+	require.NoError(t, db.restoreStats(ctx))
+	assert.Equal(t, int64(6), docWrites.Value())
+
+	// Persisted counters never decrease
+	docWrites.Set(2)
+	require.NoError(t, db.persistStats(ctx))
+	persisted = base.PersistedCounters{}
+	_, err = db.MetadataStore.Get(db.MetadataKeys.StatsKey("node1"), &persisted)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), persisted.Ints["sgw_database_num_doc_writes"])
+}
@@ -0,0 +1,297 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	sgbucket "github.com/couchbase/sg-bucket"
+	"github.com/couchbase/sync_gateway/base"
+)
+
+const (
+	// DefaultSyncMetadataCompactionThreshold is the size of a document's sync metadata above which sync metadata
+	// compaction relocates its cold portions.
+	DefaultSyncMetadataCompactionThreshold = 256 * 1024
+
+	// syncMetadataChunkSize is the maximum size of each side document storing relocated sync metadata.
+	syncMetadataChunkSize = 1024 * 1024
+
+	// externalSyncMetadataPrefix is the key prefix of the side documents storing relocated sync metadata.
+	externalSyncMetadataPrefix = base.SyncDocPrefix + "xmeta:"
+
+	// externalSyncMetadataHashedDocIDLen is the doc ID length from which doc IDs are hashed in the keys of side
+	// documents, so that keys with the 32 character relocation ID and chunk number fit the 250 byte key limit.
+	externalSyncMetadataHashedDocIDLen = 250 - len(externalSyncMetadataPrefix) - 32 - 12
+)
+
+// ExternalSyncMetadata locates the cold portions of a document's sync metadata that have been relocated to side
+// documents by sync metadata compaction.  They're only loaded into memory by reads that need them, and are never merged
+// back into the document: writes keep the reference as is, and the next compaction relocates the referenced metadata
+// again along with any cold metadata added since.
+type ExternalSyncMetadata struct {
+	ID     string `json:"id"`     // Identifies the relocation, so that relocating again doesn't overwrite chunks being read
+	Chunks int    `json:"chunks"` // Number of side documents the relocated metadata is split across
+	Bytes  int    `json:"bytes"`  // Total size of the relocated metadata
+}
+
+// externalSyncMetadata is the sync metadata relocated to side documents.
+type externalSyncMetadata struct {
+	ChannelSetHistory []ChannelSetEntry   `json:"channel_set_history,omitempty"` // Channel history entries
+	RevChannels       map[string]base.Set `json:"rev_channels,omitempty"`        // Channels of non-leaf revisions, by revision ID
+}
+
+func (m *externalSyncMetadata) isEmpty() bool {
+	return len(m.ChannelSetHistory) == 0 && len(m.RevChannels) == 0
+}
+
+// externalSyncMetadataKey returns the key of a side document storing relocated sync metadata.
+//
+//	format: _sync:xmeta:[relocationID]:[docID or sha1 of long docID]:[chunk]
+func externalSyncMetadataKey(docID, relocationID string, chunk int) string {
+	docID = base.SerializeIfLonger(docID, externalSyncMetadataHashedDocIDLen)
+	return fmt.Sprintf("%s%s:%s:%d", externalSyncMetadataPrefix, relocationID, docID, chunk)
+}
+
+// needsExternalRevChannels returns true if the channels of the revision have been relocated, and have to be loaded.
+func (sd *SyncData) needsExternalRevChannels(revID string) bool {
+	if sd.ExternalMetadata == nil {
+		return false
+	}
+	rev, ok := sd.History[revID]
+	return ok && rev.Channels == nil && !sd.History.isLeaf(revID)
+}
+
+// loadExternalSyncMetadata merges any sync metadata relocated by sync metadata compaction back into the document's
+// sync metadata.  Should only be used on sync metadata that's about to be read, or rewritten in full.
+func (c *DatabaseCollection) loadExternalSyncMetadata(ctx context.Context, docID string, syncData *SyncData) error {
+	ref := syncData.ExternalMetadata
+	if ref == nil {
+		return nil
+	}
+	external, err := c.readExternalSyncMetadata(docID, ref)
+	if err != nil {
+		base.WarnfCtx(ctx, "Unable to load relocated sync metadata of doc %q: %v", base.UD(docID), err)
+		return err
+	}
+	syncData.ChannelSetHistory = append(external.ChannelSetHistory, syncData.ChannelSetHistory...)
+	for revID, channels := range external.RevChannels {
+		if rev, ok := syncData.History[revID]; ok && rev.Channels == nil {
+			rev.Channels = channels
+		}
+	}
+	syncData.ExternalMetadata = nil
+	return nil
+}
+
+// readExternalSyncMetadata reads and reassembles the chunks of relocated sync metadata.
+func (c *DatabaseCollection) readExternalSyncMetadata(docID string, ref *ExternalSyncMetadata) (*externalSyncMetadata, error) {
+	data := make([]byte, 0, ref.Bytes)
+	for i := 0; i < ref.Chunks; i++ {
+		chunk, _, err := c.dataStore.GetRaw(externalSyncMetadataKey(docID, ref.ID, i))
+		if err != nil {
+			return nil, fmt.Errorf("chunk %d: %w", i, err)
+		}
+		data = append(data, chunk...)
+	}
+	if len(data) != ref.Bytes {
+		return nil, fmt.Errorf("expected %d bytes, found %d", ref.Bytes, len(data))
+	}
+	var external externalSyncMetadata
+	if err := base.JSONUnmarshal(data, &external); err != nil {
+		return nil, err
+	}
+	return &external, nil
+}
+
+// writeExternalSyncMetadata writes relocated sync metadata to side documents, returning their location.
+func (c *DatabaseCollection) writeExternalSyncMetadata(ctx context.Context, docID string, external *externalSyncMetadata) (*ExternalSyncMetadata, error) {
+	data, err := base.JSONMarshal(external)
+	if err != nil {
+		return nil, err
+	}
+	relocationID, err := base.GenerateRandomID()
+	if err != nil {
+		return nil, err
+	}
+	ref := &ExternalSyncMetadata{ID: relocationID, Bytes: len(data)}
+	for start := 0; start < len(data); start += syncMetadataChunkSize {
+		end := start + syncMetadataChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		if err := c.dataStore.SetRaw(externalSyncMetadataKey(docID, ref.ID, ref.Chunks), 0, nil, data[start:end]); err != nil {
+			c.deleteExternalSyncMetadata(ctx, docID, ref)
+			return nil, err
+		}
+		ref.Chunks++
+	}
+	return ref, nil
+}
+
+// deleteExternalSyncMetadata deletes the side documents of relocated sync metadata that's no longer referenced.
+func (c *DatabaseCollection) deleteExternalSyncMetadata(ctx context.Context, docID string, ref *ExternalSyncMetadata) {
+	for i := 0; i < ref.Chunks; i++ {
+		key := externalSyncMetadataKey(docID, ref.ID, i)
+		if err := c.dataStore.Delete(key); err != nil && !base.IsDocNotFoundError(err) {
+			base.InfofCtx(ctx, base.KeyCRUD, "Unable to delete relocated sync metadata %q: %v", base.UD(key), err)
+		}
+	}
+}
+
+// coldSyncMetadata returns the portions of the sync metadata that are rarely read: the channel history, and the
+// channels of non-leaf revisions.
+func coldSyncMetadata(syncData *SyncData) *externalSyncMetadata {
+	cold := &externalSyncMetadata{ChannelSetHistory: syncData.ChannelSetHistory}
+	for revID, rev := range syncData.History {
+		if rev.Channels == nil || syncData.History.isLeaf(revID) {
+			continue
+		}
+		if cold.RevChannels == nil {
+			cold.RevChannels = make(map[string]base.Set)
+		}
+		cold.RevChannels[revID] = rev.Channels
+	}
+	return cold
+}
+
+// removeRelocatedSyncMetadata removes the metadata that has been relocated from the sync metadata, keeping anything
+// added since it was read.
+func (sd *SyncData) removeRelocatedSyncMetadata(relocated *externalSyncMetadata) {
+	relocatedEntries := make(map[ChannelSetEntry]struct{}, len(relocated.ChannelSetHistory))
+	for _, entry := range relocated.ChannelSetHistory {
+		relocatedEntries[entry] = struct{}{}
+	}
+	retained := make([]ChannelSetEntry, 0)
+	for _, entry := range sd.ChannelSetHistory {
+		if _, ok := relocatedEntries[entry]; !ok {
+			retained = append(retained, entry)
+		}
+	}
+	sd.ChannelSetHistory = retained
+	for revID := range relocated.RevChannels {
+		if rev, ok := sd.History[revID]; ok && !sd.History.isLeaf(revID) {
+			rev.Channels = nil
+		}
+	}
+}
+
+// compactSyncMetadata relocates the cold portions of the document's sync metadata to side documents, if its sync
+// metadata is larger than threshold bytes.  Metadata relocated by an earlier compaction is relocated again along with
+// it.  Returns the number of bytes relocated, or zero if the document didn't need compacting or was updated
+// concurrently, in which case it's compacted on the next run.  Deleted documents are skipped.
+func (c *DatabaseCollection) compactSyncMetadata(ctx context.Context, docID string, threshold int) (relocatedBytes int, err error) {
+	doc, err := c.GetDocument(ctx, docID, DocUnmarshalSync)
+	if err != nil {
+		if base.IsDocNotFoundError(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if doc.IsDeleted() {
+		return 0, nil
+	}
+	rawSyncData, err := base.JSONMarshal(doc.SyncData)
+	if err != nil {
+		return 0, err
+	}
+	if len(rawSyncData) <= threshold {
+		return 0, nil
+	}
+
+	previousRef := doc.ExternalMetadata
+	if err := c.loadExternalSyncMetadata(ctx, docID, &doc.SyncData); err != nil {
+		return 0, err
+	}
+	cold := coldSyncMetadata(&doc.SyncData)
+	if cold.isEmpty() {
+		return 0, nil
+	}
+	ref, err := c.writeExternalSyncMetadata(ctx, docID, cold)
+	if err != nil {
+		return 0, err
+	}
+
+	// Only the relocated metadata is removed, and the document's sequence is checked, so that a concurrent update
+	// isn't lost, and doesn't leave the document referring to the wrong relocation
+	sequence := doc.Sequence
+	relocate := func(current *Document) (expiry *uint32, err error) {
+		if current.Sequence != sequence || current.IsDeleted() || !sameExternalSyncMetadata(current.ExternalMetadata, previousRef) {
+			return nil, base.ErrUpdateCancel
+		}
+		current.removeRelocatedSyncMetadata(cold)
+		current.ExternalMetadata = ref
+		if current.Expiry != nil {
+			cbsExpiry := uint32(current.Expiry.Unix())
+			expiry = &cbsExpiry
+		}
+		return expiry, nil
+	}
+
+	key := realDocID(docID)
+	if c.UseXattrs() {
+		opts := &sgbucket.MutateInOptions{
+			MacroExpansion: macroExpandSpec(base.SyncXattrName),
+		}
+		_, err = c.dataStore.WriteUpdateWithXattr(ctx, key, base.SyncXattrName, c.userXattrKey(), 0, nil, opts, func(currentValue []byte, currentXattr []byte, currentUserXattr []byte, cas uint64) (
+			raw []byte, rawXattr []byte, deleteDoc bool, expiry *uint32, updatedSpec []sgbucket.MacroExpansionSpec, err error) {
+			if len(currentValue) == 0 {
+				return nil, nil, false, nil, nil, base.ErrUpdateCancel
+			}
+			current, err := unmarshalDocumentWithXattr(ctx, docID, currentValue, currentXattr, currentUserXattr, cas, DocUnmarshalAll)
+			if err != nil {
+				return nil, nil, false, nil, nil, err
+			}
+			if expiry, err = relocate(current); err != nil {
+				return nil, nil, false, nil, nil, err
+			}
+			current.SetCrc32cUserXattrHash()
+			raw, rawXattr, err = current.MarshalWithXattr()
+			return raw, rawXattr, false, expiry, nil, err
+		})
+	} else {
+		_, err = c.dataStore.Update(key, 0, func(currentValue []byte) ([]byte, *uint32, bool, error) {
+			if currentValue == nil {
+				return nil, nil, false, base.ErrUpdateCancel
+			}
+			current, err := unmarshalDocument(docID, currentValue)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			expiry, err := relocate(current)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			updatedBytes, err := base.JSONMarshal(current)
+			return updatedBytes, expiry, false, err
+		})
+	}
+	if err != nil {
+		c.deleteExternalSyncMetadata(ctx, docID, ref)
+		if errors.Is(err, base.ErrUpdateCancel) {
+			base.DebugfCtx(ctx, base.KeyCRUD, "Doc %q was updated during sync metadata compaction, skipping", base.UD(docID))
+			return 0, nil
+		}
+		return 0, err
+	}
+	if previousRef != nil {
+		c.deleteExternalSyncMetadata(ctx, docID, previousRef)
+	}
+	base.DebugfCtx(ctx, base.KeyCRUD, "Relocated %d bytes of sync metadata of doc %q", ref.Bytes, base.UD(docID))
+	return ref.Bytes, nil
+}
+
+func sameExternalSyncMetadata(a, b *ExternalSyncMetadata) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.ID == b.ID
+}
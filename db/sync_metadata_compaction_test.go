@@ -0,0 +1,110 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactSyncMetadata(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+	collection.ChannelMapper = channels.NewChannelMapper(ctx, channels.DocChannelsSyncFunction, db.Options.JavascriptTimeout)
+
+	// Moving the doc between channels builds up channel history, and the channels of non-leaf revisions
+	const docID = "doc"
+	history := []string{}
+	for i, channel := range []string{"A", "B", "A", "B"} {
+		history = append([]string{CreateRevIDWithBytes(i+1, "", []byte(channel))}, history...)
+		_, _, err := collection.PutExistingRevWithBody(ctx, docID, Body{"channels": []string{channel}}, history, false)
+		require.NoError(t, err)
+	}
+	oldRevID, leafRevID := history[1], history[0]
+	doc, err := collection.GetDocument(ctx, docID, DocUnmarshalSync)
+	require.NoError(t, err)
+	require.Len(t, doc.ChannelSetHistory, 2)
+	require.Equal(t, base.SetOf("A"), doc.History[oldRevID].Channels)
+
+	// Documents under the threshold aren't compacted
+	relocatedBytes, err := collection.compactSyncMetadata(ctx, docID, DefaultSyncMetadataCompactionThreshold)
+	require.NoError(t, err)
+	assert.Zero(t, relocatedBytes)
+
+	relocatedBytes, err = collection.compactSyncMetadata(ctx, docID, 1)
+	require.NoError(t, err)
+	assert.Greater(t, relocatedBytes, 0)
+	doc, err = collection.GetDocument(ctx, docID, DocUnmarshalSync)
+	require.NoError(t, err)
+	ref := doc.ExternalMetadata
+	require.NotNil(t, ref)
+	assert.Equal(t, relocatedBytes, ref.Bytes)
+	assert.Empty(t, doc.ChannelSetHistory)
+	assert.Nil(t, doc.History[oldRevID].Channels)
+	assert.Equal(t, base.SetOf("B"), doc.History[leafRevID].Channels)
+	assert.True(t, doc.needsExternalRevChannels(oldRevID))
+	assert.False(t, doc.needsExternalRevChannels(leafRevID))
+
+	// Relocated metadata is loaded when needed
+	_, _, _, channels, _, _, _, _, err := revCacheLoaderForDocument(ctx, collection.DatabaseCollection, doc, oldRevID)
+	require.NoError(t, err)
+	assert.Equal(t, base.SetOf("A"), channels)
+	assert.Nil(t, doc.ExternalMetadata)
+	assert.Len(t, doc.ChannelSetHistory, 2)
+	access, err := collection.GetDocumentAccess(ctx, docID, nil)
+	require.NoError(t, err)
+	assert.NotNil(t, access)
+
+	// Compacting again relocates the previously relocated metadata along with the inline metadata, and deletes the
+	// previous side documents
+	relocatedBytes, err = collection.compactSyncMetadata(ctx, docID, 1)
+	require.NoError(t, err)
+	assert.Greater(t, relocatedBytes, 0)
+	_, _, err = collection.dataStore.GetRaw(externalSyncMetadataKey(docID, ref.ID, 0))
+	assert.True(t, base.IsDocNotFoundError(err))
+	doc, err = collection.GetDocument(ctx, docID, DocUnmarshalSync)
+	require.NoError(t, err)
+	ref = doc.ExternalMetadata
+	require.NotNil(t, ref)
+	require.NoError(t, collection.loadExternalSyncMetadata(ctx, docID, &doc.SyncData))
+	assert.Len(t, doc.ChannelSetHistory, 2)
+	assert.Equal(t, base.SetOf("A"), doc.History[oldRevID].Channels)
+
+	// Purging the document deletes the side documents
+	_, _, err = collection.dataStore.GetRaw(externalSyncMetadataKey(docID, ref.ID, 0))
+	require.NoError(t, err)
+	require.NoError(t, collection.Purge(ctx, docID))
+	_, _, err = collection.dataStore.GetRaw(externalSyncMetadataKey(docID, ref.ID, 0))
+	assert.True(t, base.IsDocNotFoundError(err))
+}
+
+func TestExternalSyncMetadataKeyLength(t *testing.T) {
+	relocationID, err := base.GenerateRandomID()
+	require.NoError(t, err)
+
+	// Short doc IDs are included as is
+	assert.Equal(t, "_sync:xmeta:"+relocationID+":doc:0", externalSyncMetadataKey("doc", relocationID, 0))
+
+	// Long doc IDs are hashed to keep keys within the key limit, and still give distinct keys
+	keys := make(map[string]struct{})
+	for _, docID := range []string{strings.Repeat("a", 200), strings.Repeat("a", 249), strings.Repeat("b", 249)} {
+		key := externalSyncMetadataKey(docID, relocationID, math.MaxInt32)
+		assert.LessOrEqual(t, len(key), 250)
+		assert.NotContains(t, key, docID)
+		keys[key] = struct{}{}
+	}
+	assert.Len(t, keys, 3)
+}
@@ -0,0 +1,343 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+)
+
+var (
+	// atLeastOnceWebhookPollInterval is how often an at-least-once webhook checks the changes feed for new events.
+	atLeastOnceWebhookPollInterval = time.Second
+	// atLeastOnceWebhookMaxRetryInterval is the longest an at-least-once webhook waits before retrying a failed post.
+	atLeastOnceWebhookMaxRetryInterval = time.Minute
+	// atLeastOnceWebhookLeaseDuration is how long a node owns an at-least-once webhook's cursor without renewing it.
+	// Only the owning node delivers events, so a webhook isn't sent an event by every node in the cluster.
+	atLeastOnceWebhookLeaseDuration = time.Minute
+)
+
+// atLeastOnceWebhookBatchSize is the maximum number of changes read from each collection's changes feed at a time.
+const atLeastOnceWebhookBatchSize = 200
+
+// webhookCursor is the metadata document storing the sequence an at-least-once webhook has been sent every change up
+// to, and the node currently delivering its events.
+type webhookCursor struct {
+	Sequence    uint64    `json:"seq"`
+	Owner       string    `json:"owner,omitempty"`
+	LeaseExpiry time.Time `json:"lease_expiry,omitempty"`
+}
+
+// atLeastOnceWebhookFeed delivers document_changed events to a webhook with the at_least_once option.  Rather than
+// being raised on write, events are read from the changes feed of all the database's collections in sequence order,
+// and each is retried until the webhook accepts it with a 2xx response.  Event IDs are the document's collection and
+// sequence, so are stable across restarts.  The cursor is persisted after each event, so delivery resumes from where it
+// stopped after a restart.  The cursor doesn't move past a sequence skipped by the change cache, so a late arriving
+// change is still delivered, and the feed is read again from the cursor when the oldest skipped sequence changes.  Only the latest revision of a document is delivered, so intermediate updates
+// made while the webhook is unavailable are coalesced.
+type atLeastOnceWebhookFeed struct {
+	dbCtx      *DatabaseContext
+	webhook    *Webhook
+	cursorKey  string
+	nodeID     string
+	lowSeq     uint64              // sequence every change has been delivered up to, as persisted in the cursor
+	lastSeq    uint64              // last sequence read from the changes feed
+	delivered  map[uint64]struct{} // sequences after lowSeq that have been delivered
+	skippedSeq uint64              // oldest sequence skipped by the change cache, when the feed was last read
+	leaseUntil time.Time
+	terminator chan bool
+}
+
+// StartAtLeastOnceWebhook starts delivering document_changed events to a webhook with the at_least_once option, from
+// its persisted cursor.  Delivery stops when the database is closed.
+func (dbCtx *DatabaseContext) StartAtLeastOnceWebhook(ctx context.Context, wh *Webhook) error {
+	feed, err := newAtLeastOnceWebhookFeed(dbCtx, wh, dbCtx.terminator)
+	if err != nil {
+		return err
+	}
+	base.InfofCtx(ctx, base.KeyEvents, "Starting at-least-once delivery for %v", wh)
+	go feed.run(ctx)
+	return nil
+}
+
+func newAtLeastOnceWebhookFeed(dbCtx *DatabaseContext, wh *Webhook, terminator chan bool) (*atLeastOnceWebhookFeed, error) {
+	nodeID, err := base.GenerateRandomID()
+	if err != nil {
+		return nil, err
+	}
+	return &atLeastOnceWebhookFeed{
+		dbCtx:      dbCtx,
+		webhook:    wh,
+		cursorKey:  dbCtx.MetadataKeys.WebhookCursorKey(base.Sha1HashString(wh.url, "")),
+		nodeID:     nodeID,
+		delivered:  make(map[uint64]struct{}),
+		terminator: terminator,
+	}, nil
+}
+
+// run delivers events until the terminator is closed.
+func (f *atLeastOnceWebhookFeed) run(ctx context.Context) {
+	ticker := time.NewTicker(atLeastOnceWebhookPollInterval)
+	defer ticker.Stop()
+	for {
+		if err := f.deliverPending(ctx); err != nil {
+			base.WarnfCtx(ctx, "Error delivering events to %v, will retry: %v", f.webhook, err)
+		}
+		select {
+		case <-f.terminator:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverPending delivers all changes since the cursor, if this node owns the cursor.
+func (f *atLeastOnceWebhookFeed) deliverPending(ctx context.Context) error {
+	for {
+		owner, err := f.claimCursor(ctx)
+		if err != nil || !owner {
+			return err
+		}
+		changes, err := f.nextChanges(ctx)
+		if err != nil {
+			return err
+		}
+		// Read after the changes, as every earlier sequence is either cached or skipped by the time a change is cached.
+		// If the oldest skipped sequence changed, the cursor can't pass either value until the feed has been reread.
+		skippedSeq := f.dbCtx.changeCache.getOldestSkippedSequence(ctx)
+		cursorLimit := skippedSeq
+		if f.skippedSeq > 0 && (cursorLimit == 0 || f.skippedSeq < cursorLimit) {
+			cursorLimit = f.skippedSeq
+		}
+		reread := skippedSeq != f.skippedSeq
+		f.skippedSeq = skippedSeq
+		if len(changes) == 0 && !reread {
+			return nil
+		}
+		for _, change := range changes {
+			seq := change.entry.Seq.Seq
+			if _, ok := f.delivered[seq]; !ok {
+				if !f.deliver(ctx, change) {
+					return nil
+				}
+				f.delivered[seq] = struct{}{}
+			}
+			f.lastSeq = seq
+			if err := f.advanceCursor(cursorLimit); err != nil {
+				return err
+			}
+		}
+		if reread {
+			// A skipped sequence has been found, or has arrived or been abandoned, so read again from the cursor
+			f.lastSeq = f.lowSeq
+		}
+	}
+}
+
+// advanceCursor persists the last sequence read as the cursor, or the sequence before skippedSeq if that's lower, as
+// the skipped sequence may still arrive.
+func (f *atLeastOnceWebhookFeed) advanceCursor(skippedSeq uint64) error {
+	seq := f.lastSeq
+	if skippedSeq > 0 && skippedSeq-1 < seq {
+		seq = skippedSeq - 1
+	}
+	if seq <= f.lowSeq {
+		return nil
+	}
+	if err := f.saveCursor(seq); err != nil {
+		return err
+	}
+	for deliveredSeq := range f.delivered {
+		if deliveredSeq <= seq {
+			delete(f.delivered, deliveredSeq)
+		}
+	}
+	return nil
+}
+
+// claimCursor takes or renews ownership of the cursor, returning false if another node owns it.  When ownership is
+// taken from another node, delivery resumes from that node's cursor.
+func (f *atLeastOnceWebhookFeed) claimCursor(ctx context.Context) (owner bool, err error) {
+	if time.Until(f.leaseUntil) > atLeastOnceWebhookLeaseDuration/2 {
+		return true, nil
+	}
+	var cursorSeq uint64
+	takeover := false
+	_, err = f.dbCtx.MetadataStore.Update(f.cursorKey, 0, func(current []byte) (updated []byte, expiry *uint32, delete bool, err error) {
+		cursor := webhookCursor{}
+		if len(current) > 0 {
+			if err := base.JSONUnmarshal(current, &cursor); err != nil {
+				return nil, nil, false, err
+			}
+		} else {
+			// Nothing written before the webhook was first configured is delivered
+			cursor.Sequence, err = f.dbCtx.LastSequence(ctx)
+			if err != nil {
+				return nil, nil, false, err
+			}
+		}
+		if cursor.Owner != f.nodeID && cursor.Owner != "" && time.Now().Before(cursor.LeaseExpiry) {
+			owner = false
+			return nil, nil, false, base.ErrUpdateCancel
+		}
+		owner = true
+		takeover = cursor.Owner != f.nodeID
+		cursorSeq = cursor.Sequence
+		cursor.Owner = f.nodeID
+		cursor.LeaseExpiry = time.Now().Add(atLeastOnceWebhookLeaseDuration)
+		updated, err = base.JSONMarshal(cursor)
+		return updated, nil, false, err
+	})
+	if err == base.ErrUpdateCancel {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if takeover {
+		f.lowSeq, f.lastSeq = cursorSeq, cursorSeq
+		f.delivered = make(map[uint64]struct{})
+	}
+	f.leaseUntil = time.Now().Add(atLeastOnceWebhookLeaseDuration)
+	return owner, nil
+}
+
+// saveCursor persists seq as the sequence every change has been delivered up to, renewing the lease.
+func (f *atLeastOnceWebhookFeed) saveCursor(seq uint64) error {
+	leaseExpiry := time.Now().Add(atLeastOnceWebhookLeaseDuration)
+	err := f.dbCtx.MetadataStore.Set(f.cursorKey, 0, nil, webhookCursor{Sequence: seq, Owner: f.nodeID, LeaseExpiry: leaseExpiry})
+	if err != nil {
+		return err
+	}
+	f.lowSeq = seq
+	f.leaseUntil = leaseExpiry
+	return nil
+}
+
+// webhookFeedChange is a change to deliver, and the collection it was made in.
+type webhookFeedChange struct {
+	collection *DatabaseCollectionWithUser
+	entry      *ChangeEntry
+}
+
+// nextChanges returns the next changes after the cursor across all collections, in sequence order.  When a
+// collection's changes are limited by the batch size, later changes from other collections are held back to the next
+// batch so no sequence is skipped.
+func (f *atLeastOnceWebhookFeed) nextChanges(ctx context.Context) ([]webhookFeedChange, error) {
+	var changes []webhookFeedChange
+	maxSeq := uint64(0)
+	for _, dbCollection := range f.dbCtx.CollectionByID {
+		collection := &DatabaseCollectionWithUser{DatabaseCollection: dbCollection}
+		entries, err := collection.GetChanges(ctx, base.SetOf(channels.UserStarChannel), ChangesOptions{
+			Since: SequenceID{Seq: f.lastSeq},
+			Limit: atLeastOnceWebhookBatchSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == atLeastOnceWebhookBatchSize {
+			if lastSeq := entries[len(entries)-1].Seq.Seq; maxSeq == 0 || lastSeq < maxSeq {
+				maxSeq = lastSeq
+			}
+		}
+		for _, entry := range entries {
+			changes = append(changes, webhookFeedChange{collection: collection, entry: entry})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].entry.Seq.Seq < changes[j].entry.Seq.Seq
+	})
+	if maxSeq > 0 {
+		i := sort.Search(len(changes), func(i int) bool { return changes[i].entry.Seq.Seq > maxSeq })
+		changes = changes[:i]
+	}
+	return changes, nil
+}
+
+// deliver posts the change to the webhook, retrying with backoff until it's accepted.  Returns false if the feed was
+// terminated before the change was delivered.
+func (f *atLeastOnceWebhookFeed) deliver(ctx context.Context, change webhookFeedChange) bool {
+	retryInterval := 100 * time.Millisecond
+	for {
+		if f.tryDeliver(ctx, change) {
+			return true
+		}
+		select {
+		case <-f.terminator:
+			return false
+		case <-time.After(retryInterval):
+		}
+		retryInterval *= 2
+		if retryInterval > atLeastOnceWebhookMaxRetryInterval {
+			retryInterval = atLeastOnceWebhookMaxRetryInterval
+		}
+	}
+}
+
+// tryDeliver makes a single attempt to deliver the change, returning true if it was accepted by the webhook or doesn't
+// need to be sent.
+func (f *atLeastOnceWebhookFeed) tryDeliver(ctx context.Context, change webhookFeedChange) bool {
+	event, err := f.changeEvent(ctx, change)
+	if err != nil {
+		base.WarnfCtx(ctx, "Unable to load doc %q for delivery to %v, will retry: %v", base.UD(change.entry.ID), f.webhook, err)
+		return false
+	}
+	if event == nil {
+		return true
+	}
+	payload, ok := f.webhook.payload(ctx, event)
+	if !ok || !f.webhook.filterAllows(ctx, event) {
+		return true
+	}
+	status, err := f.webhook.post(ctx, event, payload)
+	if err == nil && status >= 200 && status < 300 {
+		f.dbCtx.EventMgr.IncrementEventsProcessedSuccess(1)
+		return true
+	}
+	f.dbCtx.EventMgr.IncrementEventsProcessedFail(1)
+	if err == nil {
+		base.WarnfCtx(ctx, "%v returned status %d for %s, will retry", f.webhook, status, base.UD(event.String()))
+	}
+	return false
+}
+
+// changeEvent returns the event for the current revision of the changed document, or nil if the document has been
+// purged or changed again since, in which case it will be delivered at its later sequence.
+func (f *atLeastOnceWebhookFeed) changeEvent(ctx context.Context, change webhookFeedChange) (*DocumentChangeEvent, error) {
+	doc, err := change.collection.GetDocument(ctx, change.entry.ID, DocUnmarshalAll)
+	if base.IsDocNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if doc.Sequence != change.entry.Seq.Seq {
+		return nil, nil
+	}
+	docBytes, err := doc.BodyWithSpecialProperties(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var revChannels base.Set
+	if revInfo, ok := doc.History[doc.CurrentRev]; ok {
+		revChannels = revInfo.Channels
+	}
+	return &DocumentChangeEvent{
+		DocBytes:         docBytes,
+		DocID:            doc.ID,
+		Channels:         revChannels,
+		WinningRevChange: true,
+		Sequence:         doc.Sequence,
+		Collection:       change.collection.ScopeName + "." + change.collection.Name,
+	}, nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAtLeastOnceWebhookFeed(t *testing.T) {
+	db, ctx := setupTestDB(t)
+	defer db.Close(ctx)
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+
+	// Allow a second feed to take over the cursor immediately, as if the node had restarted after its lease expired
+	defer func(leaseDuration time.Duration) { atLeastOnceWebhookLeaseDuration = leaseDuration }(atLeastOnceWebhookLeaseDuration)
+	atLeastOnceWebhookLeaseDuration = 0
+
+	ts, wr := InitWebhookTest()
+	defer ts.Close()
+	wh, err := NewWebhook(ctx, ts.URL, "", nil, map[string]interface{}{EventOptionAtLeastOnce: true})
+	require.NoError(t, err)
+	require.True(t, wh.AtLeastOnce())
+
+	putDoc := func(docID string) uint64 {
+		_, doc, err := collection.Put(ctx, docID, Body{"value": 1})
+		require.NoError(t, err)
+		require.NoError(t, collection.WaitForPendingChanges(ctx))
+		return doc.Sequence
+	}
+
+	terminator := make(chan bool)
+	defer close(terminator)
+
+	// Changes made before the webhook's cursor was created aren't delivered
+	putDoc("doc0")
+	feed, err := newAtLeastOnceWebhookFeed(db.DatabaseContext, wh, terminator)
+	require.NoError(t, err)
+	require.NoError(t, feed.deliverPending(ctx))
+	assert.Equal(t, 0, wr.GetCount())
+
+	// Changes are delivered in sequence order, with the sequence, collection and event ID
+	seq1 := putDoc("doc1")
+	seq2 := putDoc("doc2")
+	require.NoError(t, feed.deliverPending(ctx))
+	payloads := wr.GetPayloads()
+	require.Len(t, payloads, 2)
+	keyspace := collection.ScopeName + "." + collection.Name
+	for i, expected := range []struct {
+		docID string
+		seq   uint64
+	}{{"doc1", seq1}, {"doc2", seq2}} {
+		var payload map[string]interface{}
+		require.NoError(t, base.JSONUnmarshal(payloads[i], &payload))
+		assert.Equal(t, expected.docID, payload[BodyId])
+		assert.Equal(t, float64(expected.seq), payload[webhookPropertySequence])
+		assert.Equal(t, fmt.Sprintf("%s:%d", keyspace, expected.seq), payload[webhookPropertyEventID])
+		assert.Equal(t, keyspace, payload[webhookPropertyCollection])
+	}
+
+	// A new feed resumes from the persisted cursor, so delivered changes aren't resent
+	seq3 := putDoc("doc3")
+	restartedFeed, err := newAtLeastOnceWebhookFeed(db.DatabaseContext, wh, terminator)
+	require.NoError(t, err)
+	require.NoError(t, restartedFeed.deliverPending(ctx))
+	payloads = wr.GetPayloads()
+	require.Len(t, payloads, 3)
+	var payload map[string]interface{}
+	require.NoError(t, base.JSONUnmarshal(payloads[2], &payload))
+	assert.Equal(t, "doc3", payload[BodyId])
+	assert.Equal(t, seq3, restartedFeed.lastSeq)
+}
+
+func TestAtLeastOnceWebhookFeedSkippedSequence(t *testing.T) {
+	if base.TestUseXattrs() {
+		t.Skip("This test does not work with XATTRs due to calling WriteDirect().  Skipping.")
+	}
+
+	db, ctx := setupTestDBWithCacheOptions(t, shortWaitCache())
+	defer db.Close(ctx)
+	collection := GetSingleDatabaseCollectionWithUser(t, db)
+
+	ts, wr := InitWebhookTest()
+	defer ts.Close()
+	wh, err := NewWebhook(ctx, ts.URL, "", nil, map[string]interface{}{EventOptionAtLeastOnce: true})
+	require.NoError(t, err)
+
+	terminator := make(chan bool)
+	defer close(terminator)
+	feed, err := newAtLeastOnceWebhookFeed(db.DatabaseContext, wh, terminator)
+	require.NoError(t, err)
+	require.NoError(t, feed.deliverPending(ctx))
+	startSeq := feed.lowSeq
+
+	deliveredDocIDs := func() []string {
+		var docIDs []string
+		for _, p := range wr.GetPayloads() {
+			var payload map[string]interface{}
+			require.NoError(t, base.JSONUnmarshal(p, &payload))
+			docIDs = append(docIDs, payload[BodyId].(string))
+		}
+		return docIDs
+	}
+
+	// Sequence startSeq+2 is skipped by the change cache, so the cursor stays before it
+	WriteDirect(t, db, []string{"ABC"}, startSeq+1)
+	WriteDirect(t, db, []string{"ABC"}, startSeq+3)
+	require.NoError(t, collection.changeCache().waitForSequence(ctx, startSeq+3, base.DefaultWaitForSequence))
+	require.NoError(t, feed.deliverPending(ctx))
+	assert.Equal(t, []string{fmt.Sprintf("doc-%d", startSeq+1), fmt.Sprintf("doc-%d", startSeq+3)}, deliveredDocIDs())
+	assert.Equal(t, startSeq+1, feed.lowSeq)
+
+	// The late arriving sequence is delivered once it's cached, without resending the changes after it
+	WriteDirect(t, db, []string{"ABC"}, startSeq+2)
+	require.NoError(t, collection.changeCache().waitForSequenceNotSkipped(ctx, startSeq+2, base.DefaultWaitForSequence))
+	require.NoError(t, feed.deliverPending(ctx))
+	assert.Equal(t, []string{fmt.Sprintf("doc-%d", startSeq+1), fmt.Sprintf("doc-%d", startSeq+3), fmt.Sprintf("doc-%d", startSeq+2)}, deliveredDocIDs())
+	assert.Equal(t, startSeq+3, feed.lowSeq)
+}
@@ -14,8 +14,10 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/http/httptest"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/couchbase/sync_gateway/auth"
 	"github.com/couchbase/sync_gateway/base"
@@ -1113,6 +1115,54 @@ func TestDynamicChannelGrant(t *testing.T) {
 	RequireStatus(t, response, 200)
 }
 
+// Verify a channel granted with an expiry is revoked once the expiry has passed
+func TestDynamicChannelGrantWithExpiry(t *testing.T) {
+
+	base.SetUpTestLogging(t, base.LevelInfo, base.KeyAccess)
+
+	rtConfig := RestTesterConfig{SyncFn: `function(doc) {if(doc.type == "setaccess") {access(doc.owner, doc.channel, {expiry: doc.expiry});} else { channel(doc.channel)}}`}
+	rt := NewRestTester(t, &rtConfig)
+	defer rt.Close()
+
+	ctx := rt.Context()
+	a := rt.ServerContext().Database(ctx, "db").Authenticator(ctx)
+	user, err := a.NewUser("user1", "letmein", nil)
+	require.NoError(t, err)
+	require.NoError(t, a.Save(user))
+
+	response := rt.SendAdminRequest("PUT", "/{{.keyspace}}/doc1", `{"channel":"chan1"}`)
+	RequireStatus(t, response, http.StatusCreated)
+	response = rt.SendAdminRequest("PUT", "/{{.keyspace}}/doc2", `{"channel":"chan2"}`)
+	RequireStatus(t, response, http.StatusCreated)
+
+	// Grant chan1 for two seconds, and chan2 for a day
+	response = rt.SendAdminRequest("PUT", "/{{.keyspace}}/grant1", `{"type":"setaccess", "owner":"user1", "channel":"chan1", "expiry":2}`)
+	RequireStatus(t, response, http.StatusCreated)
+	response = rt.SendAdminRequest("PUT", "/{{.keyspace}}/grant2", `{"type":"setaccess", "owner":"user1", "channel":"chan2", "expiry":86400}`)
+	RequireStatus(t, response, http.StatusCreated)
+
+	response = rt.SendUserRequest("GET", "/{{.keyspace}}/doc1", "", "user1")
+	RequireStatus(t, response, http.StatusOK)
+
+	// Once chan1 expires the user loses access to it, and the revocation is recorded in the user's channel history
+	require.Eventually(t, func() bool {
+		response = rt.SendUserRequest("GET", "/{{.keyspace}}/doc1", "", "user1")
+		return response.Code == http.StatusForbidden
+	}, 10*time.Second, 100*time.Millisecond)
+
+	response = rt.SendUserRequest("GET", "/{{.keyspace}}/doc2", "", "user1")
+	RequireStatus(t, response, http.StatusOK)
+
+	collection := rt.GetSingleTestDatabaseCollection()
+	user, err = a.GetUser("user1")
+	require.NoError(t, err)
+	assert.False(t, user.CanSeeCollectionChannel(collection.ScopeName, collection.Name, "chan1"))
+	assert.True(t, user.CanSeeCollectionChannel(collection.ScopeName, collection.Name, "chan2"))
+	channelHistory := user.CollectionChannelHistory(collection.ScopeName, collection.Name)
+	require.Contains(t, channelHistory, "chan1")
+	assert.NotZero(t, channelHistory["chan1"].Entries[0].EndSeq)
+}
+
 // Verify a dynamic grant of a channel to a role is inherited by a user with that role
 func TestRoleChannelGrantInheritance(t *testing.T) {
 
@@ -1246,3 +1296,61 @@ func TestPublicChannel(t *testing.T) {
 	response = rt.SendUserRequest("GET", "/{{.keyspace}}/privateDoc", "", "user1")
 	RequireStatus(t, response, 403)
 }
+
+// TestExternalAuthorizerDocReads ensures documents fetched by a user are authorized by the external authorizer in the
+// same way as the changes feed: channels it grants can be read, and channels it denies can't.
+func TestExternalAuthorizerDocReads(t *testing.T) {
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request db.ExternalAuthorizationRequest
+		require.NoError(t, base.JSONDecoder(r.Body).Decode(&request))
+		response := db.ExternalAuthorizationResponse{Allow: true}
+		for _, channel := range request.Channels {
+			if channel == "granted" || channel == "external" {
+				response.Channels = append(response.Channels, channel)
+			}
+		}
+		body, err := base.JSONMarshal(response)
+		require.NoError(t, err)
+		_, _ = w.Write(body)
+	}))
+	defer authServer.Close()
+
+	rt := NewRestTester(t, &RestTesterConfig{
+		SyncFn: channels.DocChannelsSyncFunction,
+		DatabaseConfig: &DatabaseConfig{DbConfig: DbConfig{
+			ExternalAuthorizer: &ExternalAuthorizerConfig{
+				URL:            authServer.URL,
+				CacheTTLSecs:   base.Uint32Ptr(0),
+				GrantsChannels: base.BoolPtr(true),
+			},
+		}},
+	})
+	defer rt.Close()
+	rt.CreateUser("alice", []string{"granted", "denied"})
+
+	// Docs in channels granted by the sync function and allowed by the authorizer, or granted by the authorizer, can
+	// be read.  Docs in channels denied by the authorizer, or not granted at all, can't.
+	for docID, channel := range map[string]string{"grantedDoc": "granted", "externalDoc": "external", "deniedDoc": "denied", "otherDoc": "other"} {
+		RequireStatus(t, rt.SendAdminRequest(http.MethodPut, "/{{.keyspace}}/"+docID, `{"channels": "`+channel+`"}`), http.StatusCreated)
+	}
+	RequireStatus(t, rt.SendUserRequest(http.MethodGet, "/{{.keyspace}}/grantedDoc", "", "alice"), http.StatusOK)
+	RequireStatus(t, rt.SendUserRequest(http.MethodGet, "/{{.keyspace}}/externalDoc", "", "alice"), http.StatusOK)
+	RequireStatus(t, rt.SendUserRequest(http.MethodGet, "/{{.keyspace}}/deniedDoc", "", "alice"), http.StatusForbidden)
+	RequireStatus(t, rt.SendUserRequest(http.MethodGet, "/{{.keyspace}}/otherDoc", "", "alice"), http.StatusForbidden)
+
+	// _bulk_get is authorized per document
+	response := rt.SendUserRequest(http.MethodPost, "/{{.keyspace}}/_bulk_get", `{"docs": [{"id": "externalDoc"}, {"id": "deniedDoc"}]}`, "alice")
+	RequireStatus(t, response, http.StatusOK)
+	assert.Contains(t, response.Body.String(), `"_id":"externalDoc"`)
+	assert.Contains(t, response.Body.String(), `"id":"deniedDoc"`)
+	assert.Contains(t, response.Body.String(), `"status":403`)
+
+	// A changes feed of the same channels includes the same docs, along with the user's own user doc
+	changes, err := rt.WaitForChanges(3, "/{{.keyspace}}/_changes?filter=sync_gateway/bychannel&channels=granted,external,denied,other", "alice", false)
+	require.NoError(t, err)
+	var changedDocs []string
+	for _, change := range changes.Results {
+		changedDocs = append(changedDocs, change.ID)
+	}
+	assert.ElementsMatch(t, []string{"_user/alice", "grantedDoc", "externalDoc"}, changedDocs)
+}
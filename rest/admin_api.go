@@ -55,6 +55,10 @@ func (h *handler) handleCreateDB() error {
 	}
 
 	config.Name = dbName
+	if err := h.server.checkDatabaseAliases(dbName, config.Aliases); err != nil {
+		return err
+	}
+
 	if h.server.persistentConfig {
 		if err := config.validatePersistentDbConfig(); err != nil {
 			return base.HTTPErrorf(http.StatusBadRequest, err.Error())
@@ -194,7 +198,7 @@ func getAuthScopeHandleCreateDB(ctx context.Context, h *handler) (bucketName str
 		Bucket string `json:"bucket"`
 	}
 	reader := bytes.NewReader(bodyJSON)
-	err = DecodeAndSanitiseConfig(ctx, reader, &dbConfigBody, false)
+	err = DecodeAndSanitiseConfig(ctx, reader, &dbConfigBody, false, false)
 	if err != nil {
 		return "", err
 	}
@@ -241,15 +245,23 @@ func (h *handler) handleDbOnline() error {
 	base.InfofCtx(h.ctx(), base.KeyCRUD, "Taking Database : %v, online in %v seconds", base.MD(h.db.Name), input.Delay)
 	go func() {
 		time.Sleep(time.Duration(input.Delay) * time.Second)
-		h.server.TakeDbOnline(base.NewNonCancelCtx(), h.db.DatabaseContext)
+		nonContextStruct := base.NewNonCancelCtx()
+		// A paused DB is resumed without being reloaded, so that its replications continue
+		if h.db.ResumeDb(nonContextStruct.Ctx, "ADMIN Request") {
+			return
+		}
+		h.server.TakeDbOnline(nonContextStruct, h.db.DatabaseContext)
 	}()
 
 	return nil
 }
 
-// Take a DB offline
+// Take a DB offline, or pause it if requested
 func (h *handler) handleDbOffline() error {
 	h.assertAdminOnly()
+	if h.getBoolQuery("pause") {
+		return h.db.PauseDb(h.ctx(), "ADMIN Request")
+	}
 	var err error
 	if err = h.db.TakeDbOffline(base.NewNonCancelCtx(), "ADMIN Request"); err != nil {
 		base.InfofCtx(h.ctx(), base.KeyCRUD, "Unable to take Database : %v, offline", base.MD(h.db.Name))
@@ -422,13 +434,15 @@ func (h *handler) handlePutConfig() error {
 	}
 
 	type ConsoleLoggerPutConfig struct {
-		LogLevel *base.LogLevel `json:"log_level,omitempty"`
-		LogKeys  []string       `json:"log_keys,omitempty"`
+		LogLevel     *base.LogLevel           `json:"log_level,omitempty"`
+		LogKeys      []string                 `json:"log_keys,omitempty"`
+		LogKeyLevels map[string]base.LogLevel `json:"log_key_levels,omitempty"`
 	}
 
 	// Probably need to make our own to remove log file path / redaction level
 	type ServerPutConfig struct {
 		Logging struct {
+			Format  *base.LogFormat         `json:"format,omitempty"`
 			Console *ConsoleLoggerPutConfig `json:"console,omitempty"`
 			Error   FileLoggerPutConfig     `json:"error,omitempty"`
 			Warn    FileLoggerPutConfig     `json:"warn,omitempty"`
@@ -463,6 +477,19 @@ func (h *handler) handlePutConfig() error {
 
 			base.UpdateLogKeys(h.ctx(), testMap, true)
 		}
+
+		if config.Logging.Console.LogKeyLevels != nil {
+			if err := base.SetConsoleLogKeyLevels(h.ctx(), config.Logging.Console.LogKeyLevels); err != nil {
+				return base.HTTPErrorf(http.StatusBadRequest, "%v", err)
+			}
+		}
+	}
+
+	if config.Logging.Format != nil {
+		if err := base.SetLogFormat(*config.Logging.Format); err != nil {
+			return base.HTTPErrorf(http.StatusBadRequest, "%v", err)
+		}
+		h.server.Config.Logging.Format = *config.Logging.Format
 	}
 
 	if config.Logging.Error.Enabled != nil {
@@ -554,7 +581,7 @@ func (h *handler) handlePutDbConfig() (err error) {
 	}
 
 	// Set dbName based on path value (since db doesn't necessarily exist), and update in incoming config in case of insert
-	dbName := h.PathVar("db")
+	dbName := h.server.resolveDatabaseAlias(h.PathVar("db"))
 	if dbConfig.Name != "" && dbName != dbConfig.Name {
 		return base.HTTPErrorf(http.StatusBadRequest, "Cannot update database name. "+
 			"This requires removing and re-creating the database with a new name")
@@ -564,6 +591,10 @@ func (h *handler) handlePutDbConfig() (err error) {
 		dbConfig.Name = dbName
 	}
 
+	if err := h.server.checkDatabaseAliases(dbName, dbConfig.Aliases); err != nil {
+		return err
+	}
+
 	validateOIDC := !h.getBoolQuery(paramDisableOIDCValidation)
 
 	if !h.server.persistentConfig {
@@ -707,10 +738,12 @@ func (h *handler) handleDeleteCollectionConfigSync() error {
 
 	bucket := h.db.Bucket.GetName()
 	var updatedDbConfig *DatabaseConfig
+	var previousVersion string
 	cas, err := h.server.BootstrapContext.UpdateConfig(h.ctx(), bucket, h.server.Config.Bootstrap.ConfigGroupID, h.db.Name, func(bucketDbConfig *DatabaseConfig) (updatedConfig *DatabaseConfig, err error) {
 		if h.headerDoesNotMatchEtag(bucketDbConfig.Version) {
 			return nil, base.HTTPErrorf(http.StatusPreconditionFailed, "Provided If-Match header does not match current config version")
 		}
+		previousVersion = bucketDbConfig.Version
 		if bucketDbConfig.Scopes != nil {
 			config := bucketDbConfig.Scopes[h.collection.ScopeName].Collections[h.collection.Name]
 			config.SyncFn = nil
@@ -744,8 +777,7 @@ func (h *handler) handleDeleteCollectionConfigSync() error {
 	h.server.lock.Lock()
 	defer h.server.lock.Unlock()
 
-	// TODO: Dynamic update instead of reload
-	if err := h.server._reloadDatabaseWithConfig(h.ctx(), *updatedDbConfig, false, false); err != nil {
+	if err := h.server._reloadCollectionSyncFunction(h.ctx(), *updatedDbConfig, previousVersion, h.collection.ScopeName, h.collection.Name, ""); err != nil {
 		return err
 	}
 
@@ -768,10 +800,12 @@ func (h *handler) handlePutCollectionConfigSync() error {
 	dbName := h.db.Name
 
 	var updatedDbConfig *DatabaseConfig
+	var previousVersion string
 	cas, err := h.server.BootstrapContext.UpdateConfig(h.ctx(), bucket, h.server.Config.Bootstrap.ConfigGroupID, dbName, func(bucketDbConfig *DatabaseConfig) (updatedConfig *DatabaseConfig, err error) {
 		if h.headerDoesNotMatchEtag(bucketDbConfig.Version) {
 			return nil, base.HTTPErrorf(http.StatusPreconditionFailed, "Provided If-Match header does not match current config version")
 		}
+		previousVersion = bucketDbConfig.Version
 
 		if bucketDbConfig.Scopes != nil {
 			config := bucketDbConfig.Scopes[h.collection.ScopeName].Collections[h.collection.Name]
@@ -808,8 +842,7 @@ func (h *handler) handlePutCollectionConfigSync() error {
 	h.server.lock.Lock()
 	defer h.server.lock.Unlock()
 
-	// TODO: Dynamic update instead of reload
-	if err := h.server._reloadDatabaseWithConfig(h.ctx(), *updatedDbConfig, false, false); err != nil {
+	if err := h.server._reloadCollectionSyncFunction(h.ctx(), *updatedDbConfig, previousVersion, h.collection.ScopeName, h.collection.Name, js); err != nil {
 		return err
 	}
 
@@ -980,7 +1013,7 @@ func (h *handler) handlePutCollectionConfigImportFilter() error {
 // In non-persistent mode, the endpoint just removes the database from the node.
 func (h *handler) handleDeleteDB() error {
 	h.assertAdminOnly()
-	dbName := h.PathVar("db")
+	dbName := h.server.resolveDatabaseAlias(h.PathVar("db"))
 
 	var bucket string
 
@@ -1149,6 +1182,34 @@ func (h *handler) handleGetStatus() error {
 	return nil
 }
 
+// TLSCertificateStatus describes the certificate currently served by the REST APIs.
+type TLSCertificateStatus struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+}
+
+// handleReloadTLSCertificate reloads the REST API TLS cert/key pair from disk, so that new connections are
+// served the updated certificate without restarting Sync Gateway.
+func (h *handler) handleReloadTLSCertificate() error {
+	certReloader := h.server.CertReloader()
+	if certReloader == nil {
+		return base.HTTPErrorf(http.StatusServiceUnavailable, "TLS is not enabled for the REST APIs")
+	}
+	if err := certReloader.Reload(h.ctx()); err != nil {
+		return base.HTTPErrorf(http.StatusInternalServerError, "Unable to reload TLS certificate: %v", err)
+	}
+	cert := certReloader.Certificate()
+	h.writeJSON(TLSCertificateStatus{
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+	})
+	return nil
+}
+
 func (h *handler) handleSetLogging() error {
 	base.WarnfCtx(h.ctx(), "Using deprecated /_logging endpoint. Use /_config endpoints instead.")
 
@@ -1473,6 +1534,181 @@ func (h *handler) getRoleInfo() error {
 	return err
 }
 
+// getCollectionAccessPrincipal returns the user or role named by a _collection_access request, and the scope and
+// collection it's for.  The collection must be configured for the database.
+func (h *handler) getCollectionAccessPrincipal(isUser bool) (princ auth.Principal, scopeName, collectionName string, err error) {
+	scopeName, collectionName = mux.Vars(h.rq)["scope"], mux.Vars(h.rq)["collection"]
+	if base.IsDefaultCollection(scopeName, collectionName) {
+		return nil, "", "", base.HTTPErrorf(http.StatusBadRequest, "Channels in the default collection are set with admin_channels")
+	}
+	if _, err := h.db.GetDatabaseCollection(scopeName, collectionName); err != nil {
+		return nil, "", "", base.HTTPErrorf(http.StatusNotFound, "keyspace %s.%s.%s not found", base.MD(h.db.Name), base.MD(scopeName), base.MD(collectionName))
+	}
+
+	name := mux.Vars(h.rq)["name"]
+	if isUser {
+		var user auth.User
+		user, err = h.db.Authenticator(h.ctx()).GetUser(internalUserName(name))
+		if user != nil {
+			princ = user
+		}
+	} else {
+		princ, err = h.db.Authenticator(h.ctx()).GetRole(name)
+	}
+	if princ == nil {
+		if err == nil {
+			err = kNotFoundError
+		}
+		return nil, "", "", err
+	}
+	return princ, scopeName, collectionName, nil
+}
+
+// Handles GET /_user/{name}/_collection_access/{scope}/{collection} and the equivalent for roles.
+func (h *handler) getCollectionAccess(isUser bool) error {
+	h.assertAdminOnly()
+	princ, scopeName, collectionName, err := h.getCollectionAccessPrincipal(isUser)
+	if err != nil {
+		return err
+	}
+	access := auth.CollectionAccessConfig{
+		ExplicitChannels_: princ.CollectionExplicitChannels(scopeName, collectionName).AsSet(),
+	}
+	if h.permissionsResults[PermReadPrincipalAppData.PermissionName] {
+		if user, ok := princ.(auth.User); ok {
+			access.Channels_ = user.InheritedCollectionChannels(scopeName, collectionName).AsSet()
+			access.JWTChannels_ = user.CollectionJWTChannels(scopeName, collectionName).AsSet()
+		} else {
+			access.Channels_ = princ.CollectionChannels(scopeName, collectionName).AsSet()
+		}
+	}
+	h.writeJSON(access)
+	return nil
+}
+
+// Handles PUT and DELETE of /_user/{name}/_collection_access/{scope}/{collection} and the equivalent for roles, which
+// set or remove the principal's admin channels in a single collection without replacing the rest of the principal.
+func (h *handler) updateCollectionAccess(isUser bool) error {
+	h.assertAdminOnly()
+	princ, scopeName, collectionName, err := h.getCollectionAccessPrincipal(isUser)
+	if err != nil {
+		return err
+	}
+
+	var access *auth.CollectionAccessConfig
+	if h.rq.Method == http.MethodPut {
+		access = &auth.CollectionAccessConfig{}
+		if err := h.readJSONInto(access); err != nil {
+			return err
+		}
+		if access.ExplicitChannels_ == nil {
+			access.ExplicitChannels_ = base.Set{}
+		}
+	}
+
+	name := princ.Name()
+	updates := auth.PrincipalConfig{
+		Name: &name,
+		CollectionAccess: map[string]map[string]*auth.CollectionAccessConfig{
+			scopeName: {collectionName: access},
+		},
+	}
+	if _, err := h.db.UpdatePrincipal(h.ctx(), &updates, isUser, true); err != nil {
+		return err
+	}
+
+	principalType := "role"
+	if isUser {
+		principalType = "user"
+	}
+	if access != nil {
+		base.InfofCtx(h.ctx(), base.KeyAuth, "Set admin channels of %s %s in %s.%s to %v%s", principalType, base.UD(externalUserName(name)), base.MD(scopeName), base.MD(collectionName), base.UD(access.ExplicitChannels_), h.formattedEffectiveUserName())
+	} else {
+		base.InfofCtx(h.ctx(), base.KeyAuth, "Removed admin channels of %s %s in %s.%s%s", principalType, base.UD(externalUserName(name)), base.MD(scopeName), base.MD(collectionName), h.formattedEffectiveUserName())
+	}
+	h.writeStatus(http.StatusOK, "OK")
+	return nil
+}
+
+func (h *handler) getUserCollectionAccess() error {
+	return h.getCollectionAccess(true)
+}
+
+func (h *handler) putUserCollectionAccess() error {
+	return h.updateCollectionAccess(true)
+}
+
+func (h *handler) deleteUserCollectionAccess() error {
+	return h.updateCollectionAccess(true)
+}
+
+func (h *handler) getRoleCollectionAccess() error {
+	return h.getCollectionAccess(false)
+}
+
+func (h *handler) putRoleCollectionAccess() error {
+	return h.updateCollectionAccess(false)
+}
+
+func (h *handler) deleteRoleCollectionAccess() error {
+	return h.updateCollectionAccess(false)
+}
+
+// getUserQuota returns the user's usage of the database's daily request quotas.
+func (h *handler) getUserQuota() error {
+	username, err := h.quotaUsername()
+	if err != nil {
+		return err
+	}
+	usage, err := h.db.GetRequestQuotaUsage(h.ctx(), username)
+	if err != nil {
+		return err
+	}
+	h.writeJSON(usage)
+	return nil
+}
+
+// deleteUserQuota resets the user's usage of the database's daily request quotas, so they can make requests again
+// before the next day.
+func (h *handler) deleteUserQuota() error {
+	username, err := h.quotaUsername()
+	if err != nil {
+		return err
+	}
+	return h.db.ResetRequestQuotaUsage(h.ctx(), username)
+}
+
+// getUserAccessSnapshot returns the channels and roles the user had at the time given by the 'at' query parameter, or
+// now if not set, according to the database's access snapshots.  Snapshots remain available after a user is deleted.
+func (h *handler) getUserAccessSnapshot() error {
+	at := time.Now()
+	if atParam := h.getQuery("at"); atParam != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339, atParam); err != nil {
+			return base.HTTPErrorf(http.StatusBadRequest, "Invalid 'at' parameter, must be an RFC3339 date and time: %v", err)
+		}
+	}
+	snapshot, err := h.db.GetUserAccessSnapshot(h.ctx(), internalUserName(h.PathVar("name")), at)
+	if err != nil {
+		return err
+	}
+	h.writeJSON(snapshot)
+	return nil
+}
+
+// quotaUsername returns the name of the user in the request path, or a not found error if there's no such user.
+func (h *handler) quotaUsername() (string, error) {
+	username := h.PathVar("name")
+	user, err := h.db.Authenticator(h.ctx()).GetUser(internalUserName(username))
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		return "", kNotFoundError
+	}
+	return username, nil
+}
+
 func (h *handler) getUsers() error {
 
 	limit := h.getIntQuery(paramLimit, 0)
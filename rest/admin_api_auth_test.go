@@ -524,6 +524,10 @@ func TestAdminAPIAuth(t *testing.T) {
 			Method:   "GET",
 			Endpoint: "/{{.keyspace}}/_raw/doc",
 		},
+		{
+			Method:   "GET",
+			Endpoint: "/{{.keyspace}}/_access/doc",
+		},
 		{
 			Method:   "GET",
 			Endpoint: "/{{.db}}/_user/",
@@ -547,6 +551,24 @@ func TestAdminAPIAuth(t *testing.T) {
 		}, {
 			Method:   "DELETE",
 			Endpoint: "/{{.db}}/_user/user/_session/id",
+		}, {
+			Method:   "GET",
+			Endpoint: "/{{.db}}/_user/user/_collection_access/scope/collection",
+		}, {
+			Method:   "PUT",
+			Endpoint: "/{{.db}}/_user/user/_collection_access/scope/collection",
+		}, {
+			Method:   "DELETE",
+			Endpoint: "/{{.db}}/_user/user/_collection_access/scope/collection",
+		}, {
+			Method:   "GET",
+			Endpoint: "/{{.db}}/_user/user/_quota",
+		}, {
+			Method:   "DELETE",
+			Endpoint: "/{{.db}}/_user/user/_quota",
+		}, {
+			Method:   "GET",
+			Endpoint: "/{{.db}}/_user/user/_access_snapshot",
 		},
 		{
 			Method:   "GET",
@@ -564,6 +586,15 @@ func TestAdminAPIAuth(t *testing.T) {
 		}, {
 			Method:   "DELETE",
 			Endpoint: "/{{.db}}/_role/role",
+		}, {
+			Method:   "GET",
+			Endpoint: "/{{.db}}/_role/role/_collection_access/scope/collection",
+		}, {
+			Method:   "PUT",
+			Endpoint: "/{{.db}}/_role/role/_collection_access/scope/collection",
+		}, {
+			Method:   "DELETE",
+			Endpoint: "/{{.db}}/_role/role/_collection_access/scope/collection",
 		}, {
 			Method:   "GET",
 			Endpoint: "/{{.db}}/_replication/",
@@ -736,6 +767,82 @@ func TestAdminAPIAuth(t *testing.T) {
 			Method:   "GET",
 			Endpoint: "/{{.keyspace}}/_dumpchannel/channel",
 		},
+		{
+			Method:   "GET",
+			Endpoint: "/{{.keyspace}}/_channel_sizes",
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/{{.db}}/_sequence_report",
+		},
+		{
+			Method:   "POST",
+			Endpoint: "/{{.db}}/_release_sequences",
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/{{.db}}/_maintenance_window",
+		},
+		{
+			Method:   "PUT",
+			Endpoint: "/{{.db}}/_maintenance_window",
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/{{.db}}/_connected_clients",
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/{{.db}}/_replication_errors",
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/{{.db}}/_flight_recorder",
+		},
+		{
+			Method:   "POST",
+			Endpoint: "/{{.db}}/_flight_recorder",
+		},
+		{
+			Method:   "DELETE",
+			Endpoint: "/{{.db}}/_flight_recorder",
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/{{.db}}/_import_quarantine",
+		},
+		{
+			Method:   "POST",
+			Endpoint: "/{{.keyspace}}/_import_quarantine/doc",
+		},
+		{
+			Method:   "POST",
+			Endpoint: "/{{.keyspace}}/doc/_resync",
+		},
+		{
+			Method:   "POST",
+			Endpoint: "/{{.keyspace}}/doc/_repair_revtree",
+		},
+		{
+			Method:   "POST",
+			Endpoint: "/{{.keyspace}}/_migrate_checkpoints",
+		},
+		{
+			Method:   "POST",
+			Endpoint: "/{{.keyspace}}/_verify_checkpoint",
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/{{.keyspace}}/_rev_cache/pinned",
+		},
+		{
+			Method:   "PUT",
+			Endpoint: "/{{.keyspace}}/_rev_cache/pinned/doc",
+		},
+		{
+			Method:   "DELETE",
+			Endpoint: "/{{.keyspace}}/_rev_cache/pinned/doc",
+		},
 		{
 			Method:   "POST",
 			Endpoint: "/{{.db}}/_repair",
@@ -757,6 +864,22 @@ func TestAdminAPIAuth(t *testing.T) {
 			Method:   "POST",
 			Endpoint: "/{{.db}}/_compact",
 		},
+		{
+			Method:   "GET",
+			Endpoint: "/{{.db}}/_attachment_verify",
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/{{.db}}/_import_backfill",
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/{{.db}}/_channel_rename",
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/{{.db}}/_background_tasks",
+		},
 		{
 			Method:          "GET",
 			Endpoint:        "/{{.db}}/",
@@ -1174,6 +1297,11 @@ func TestNewlyCreateSGWPermissions(t *testing.T) {
 			Endpoint: "/{{.keyspace}}/_revtree/doc",
 			Users:    []string{syncGatewayApp, syncGatewayAppRo},
 		},
+		{
+			Method:   "GET",
+			Endpoint: "/{{.keyspace}}/_access/doc",
+			Users:    []string{syncGatewayApp, syncGatewayAppRo},
+		},
 		{
 			Method:   "GET",
 			Endpoint: "/db/_user/",
@@ -1209,6 +1337,36 @@ func TestNewlyCreateSGWPermissions(t *testing.T) {
 			Endpoint: "/db/_user/user/_session/session",
 			Users:    []string{syncGatewayConfigurator, syncGatewayApp},
 		},
+		{
+			Method:   "GET",
+			Endpoint: "/db/_user/user/_collection_access/scope/collection",
+			Users:    []string{syncGatewayConfigurator, syncGatewayApp, syncGatewayAppRo},
+		},
+		{
+			Method:   "PUT",
+			Endpoint: "/db/_user/user/_collection_access/scope/collection",
+			Users:    []string{syncGatewayConfigurator, syncGatewayApp},
+		},
+		{
+			Method:   "DELETE",
+			Endpoint: "/db/_user/user/_collection_access/scope/collection",
+			Users:    []string{syncGatewayConfigurator, syncGatewayApp},
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/db/_user/user/_quota",
+			Users:    []string{syncGatewayConfigurator, syncGatewayApp, syncGatewayAppRo},
+		},
+		{
+			Method:   "DELETE",
+			Endpoint: "/db/_user/user/_quota",
+			Users:    []string{syncGatewayConfigurator, syncGatewayApp},
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/db/_user/user/_access_snapshot",
+			Users:    []string{syncGatewayConfigurator, syncGatewayApp, syncGatewayAppRo},
+		},
 		{
 			Method:   "GET",
 			Endpoint: "/db/_role/",
@@ -1234,6 +1392,21 @@ func TestNewlyCreateSGWPermissions(t *testing.T) {
 			Endpoint: "/db/_role/role",
 			Users:    []string{syncGatewayConfigurator, syncGatewayApp},
 		},
+		{
+			Method:   "GET",
+			Endpoint: "/db/_role/role/_collection_access/scope/collection",
+			Users:    []string{syncGatewayConfigurator, syncGatewayApp, syncGatewayAppRo},
+		},
+		{
+			Method:   "PUT",
+			Endpoint: "/db/_role/role/_collection_access/scope/collection",
+			Users:    []string{syncGatewayConfigurator, syncGatewayApp},
+		},
+		{
+			Method:   "DELETE",
+			Endpoint: "/db/_role/role/_collection_access/scope/collection",
+			Users:    []string{syncGatewayConfigurator, syncGatewayApp},
+		},
 		{
 			Method:   "GET",
 			Endpoint: "/db/_replicationStatus/",
@@ -1414,6 +1587,101 @@ func TestNewlyCreateSGWPermissions(t *testing.T) {
 			Endpoint: "/db/_dumpchannel/channel",
 			Users:    []string{syncGatewayApp, syncGatewayAppRo},
 		},
+		{
+			Method:   "GET",
+			Endpoint: "/db/_channel_sizes",
+			Users:    []string{syncGatewayApp, syncGatewayAppRo},
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/db/_sequence_report",
+			Users:    []string{syncGatewayApp, syncGatewayAppRo},
+		},
+		{
+			Method:   "POST",
+			Endpoint: "/db/_release_sequences",
+			Users:    []string{syncGatewayConfigurator},
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/db/_maintenance_window",
+			Users:    []string{syncGatewayConfigurator},
+		},
+		{
+			Method:   "PUT",
+			Endpoint: "/db/_maintenance_window",
+			Users:    []string{syncGatewayConfigurator},
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/db/_connected_clients",
+			Users:    []string{syncGatewayApp, syncGatewayAppRo},
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/db/_replication_errors",
+			Users:    []string{syncGatewayApp, syncGatewayAppRo},
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/db/_flight_recorder",
+			Users:    []string{syncGatewayConfigurator},
+		},
+		{
+			Method:   "POST",
+			Endpoint: "/db/_flight_recorder",
+			Users:    []string{syncGatewayConfigurator},
+		},
+		{
+			Method:   "DELETE",
+			Endpoint: "/db/_flight_recorder",
+			Users:    []string{syncGatewayConfigurator},
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/db/_import_quarantine",
+			Users:    []string{syncGatewayApp, syncGatewayAppRo},
+		},
+		{
+			Method:   "POST",
+			Endpoint: "/{{.keyspace}}/_import_quarantine/doc",
+			Users:    []string{syncGatewayApp},
+		},
+		{
+			Method:   "POST",
+			Endpoint: "/{{.keyspace}}/doc/_resync",
+			Users:    []string{syncGatewayConfigurator},
+		},
+		{
+			Method:   "POST",
+			Endpoint: "/{{.keyspace}}/doc/_repair_revtree",
+			Users:    []string{syncGatewayConfigurator},
+		},
+		{
+			Method:   "POST",
+			Endpoint: "/{{.keyspace}}/_migrate_checkpoints",
+			Users:    []string{syncGatewayConfigurator},
+		},
+		{
+			Method:   "POST",
+			Endpoint: "/{{.keyspace}}/_verify_checkpoint",
+			Users:    []string{syncGatewayApp, syncGatewayAppRo},
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/{{.keyspace}}/_rev_cache/pinned",
+			Users:    []string{syncGatewayApp, syncGatewayAppRo},
+		},
+		{
+			Method:   "PUT",
+			Endpoint: "/{{.keyspace}}/_rev_cache/pinned/doc",
+			Users:    []string{syncGatewayConfigurator},
+		},
+		{
+			Method:   "DELETE",
+			Endpoint: "/{{.keyspace}}/_rev_cache/pinned/doc",
+			Users:    []string{syncGatewayConfigurator},
+		},
 		{
 			Method:   "PUT",
 			Endpoint: "/db/",
@@ -1424,6 +1692,26 @@ func TestNewlyCreateSGWPermissions(t *testing.T) {
 			Endpoint: "/db/_compact",
 			Users:    []string{syncGatewayConfigurator},
 		},
+		{
+			Method:   "GET",
+			Endpoint: "/db/_attachment_verify",
+			Users:    []string{syncGatewayConfigurator},
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/db/_import_backfill",
+			Users:    []string{syncGatewayConfigurator},
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/db/_channel_rename",
+			Users:    []string{syncGatewayConfigurator},
+		},
+		{
+			Method:   "GET",
+			Endpoint: "/db/_background_tasks",
+			Users:    []string{syncGatewayConfigurator},
+		},
 		{
 			Method:   "DELETE",
 			Endpoint: "/db/",
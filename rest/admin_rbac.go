@@ -0,0 +1,201 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// Sync Gateway admin roles, each granting access to a group of admin API endpoints.  They can be granted to Couchbase
+// Server users that don't have the Couchbase Server roles an endpoint requires, and to local admin accounts.
+const (
+	AdminRoleConfigRead       = "config-read"       // Read database and server config, logging and status
+	AdminRoleConfigWrite      = "config-write"      // Create, update and delete databases and their config, including config-read
+	AdminRoleUserAdmin        = "user-admin"        // Manage users, roles and sessions
+	AdminRoleReplicationAdmin = "replication-admin" // Manage and monitor replications
+	AdminRoleMetricsRead      = "metrics-read"      // Read stats and metrics
+)
+
+var allAdminRoles = []string{AdminRoleConfigRead, AdminRoleConfigWrite, AdminRoleUserAdmin, AdminRoleReplicationAdmin, AdminRoleMetricsRead}
+
+// Sources of an authorized admin request's identity, for audit logging.
+const (
+	adminAuthSourceServer = "server" // Authenticated by Couchbase Server, and authorized by its roles
+	adminAuthSourceRole   = "role"   // Authenticated by Couchbase Server, and authorized by a Sync Gateway admin role
+	adminAuthSourceLocal  = "local"  // Authenticated as a local admin account
+)
+
+// AdminRBACConfig grants Sync Gateway admin roles to Couchbase Server users and local admin accounts.
+type AdminRBACConfig struct {
+	Users         map[string][]string                 `json:"users,omitempty"`          // Couchbase Server usernames to the admin roles granted to them
+	LocalAccounts map[string]*LocalAdminAccountConfig `json:"local_accounts,omitempty"` // Admin accounts authenticated by Sync Gateway, keyed by username
+}
+
+// LocalAdminAccountConfig is an admin account authenticated by Sync Gateway rather than Couchbase Server.  Local
+// accounts can only access the endpoints their roles grant.
+type LocalAdminAccountConfig struct {
+	Password string   `json:"password"`
+	Roles    []string `json:"roles"`
+}
+
+// validate returns an error if a role is unknown, a local account has no password, or a username is both a Couchbase
+// Server user and a local account.
+func (c *AdminRBACConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	var multiError *base.MultiError
+	for username, roles := range c.Users {
+		if err := validateAdminRoles(roles); err != nil {
+			multiError = multiError.Append(fmt.Errorf("api.admin_rbac.users %q: %w", base.MD(username), err))
+		}
+		if _, ok := c.LocalAccounts[username]; ok {
+			multiError = multiError.Append(fmt.Errorf("api.admin_rbac: %q cannot be both a user and a local account", base.MD(username)))
+		}
+	}
+	for username, account := range c.LocalAccounts {
+		if account == nil || account.Password == "" {
+			multiError = multiError.Append(fmt.Errorf("api.admin_rbac.local_accounts %q: password must be set", base.MD(username)))
+			continue
+		}
+		if err := validateAdminRoles(account.Roles); err != nil {
+			multiError = multiError.Append(fmt.Errorf("api.admin_rbac.local_accounts %q: %w", base.MD(username), err))
+		}
+	}
+	return multiError.ErrorOrNil()
+}
+
+func validateAdminRoles(roles []string) error {
+	for _, role := range roles {
+		if !base.StringSliceContains(allAdminRoles, role) {
+			return fmt.Errorf("unknown admin role %q, must be one of %v", role, allAdminRoles)
+		}
+	}
+	return nil
+}
+
+// localAccount returns the local admin account with the username, or nil if there isn't one.
+func (c *AdminRBACConfig) localAccount(username string) *LocalAdminAccountConfig {
+	if c == nil || username == "" {
+		return nil
+	}
+	return c.LocalAccounts[username]
+}
+
+// userRoles returns the admin roles granted to the Couchbase Server user.
+func (c *AdminRBACConfig) userRoles(username string) []string {
+	if c == nil {
+		return nil
+	}
+	return c.Users[username]
+}
+
+// authenticate returns true if password is the account's password.
+func (account *LocalAdminAccountConfig) authenticate(password string) bool {
+	return subtle.ConstantTimeCompare([]byte(account.Password), []byte(password)) == 1
+}
+
+// adminRoleForPermission returns the admin role granting perm for requests with the HTTP method, or the empty string if
+// no admin role grants it.  Access to document data isn't granted by any admin role.
+func adminRoleForPermission(perm Permission, method string) string {
+	switch perm {
+	case PermStatsExport:
+		return AdminRoleMetricsRead
+	case PermReadReplications, PermWriteReplications:
+		return AdminRoleReplicationAdmin
+	case PermReadPrincipal, PermWritePrincipal, PermReadPrincipalAppData:
+		return AdminRoleUserAdmin
+	case PermCreateDb, PermDeleteDb, PermUpdateDb, PermConfigureSyncFn, PermConfigureAuth, PermDevOps:
+		if method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions {
+			return AdminRoleConfigRead
+		}
+		return AdminRoleConfigWrite
+	}
+	return ""
+}
+
+// adminRolesGrant returns true if roles include one granting perm for requests with the HTTP method.
+func adminRolesGrant(roles []string, perm Permission, method string) bool {
+	required := adminRoleForPermission(perm, method)
+	if required == "" {
+		return false
+	}
+	for _, role := range roles {
+		if role == required || (role == AdminRoleConfigWrite && required == AdminRoleConfigRead) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizeAdminRoles returns whether the roles grant all of an endpoint's access permissions, and which of its
+// response permissions they grant.  Any role grants access to endpoints without access permissions.  The admin role
+// used is returned for audit logging.
+func authorizeAdminRoles(roles []string, method string, accessPermissions, responsePermissions []Permission) (authorized bool, role string, responsePermissionResults map[string]bool) {
+	if len(roles) == 0 {
+		return false, "", nil
+	}
+	for _, perm := range accessPermissions {
+		if !adminRolesGrant(roles, perm, method) {
+			return false, "", nil
+		}
+		role = adminRoleForPermission(perm, method)
+	}
+	responsePermissionResults = make(map[string]bool, len(responsePermissions))
+	for _, perm := range responsePermissions {
+		responsePermissionResults[perm.PermissionName] = adminRolesGrant(roles, perm, method)
+	}
+	return true, role, responsePermissionResults
+}
+
+// logAdminAudit records an admin request that changes state, or that was refused, when admin RBAC is configured.
+func (h *handler) logAdminAudit() {
+	if h.privs != adminPrivs || h.server.Config.API.AdminRBAC == nil {
+		return
+	}
+	method := h.rq.Method
+	denied := h.status == http.StatusUnauthorized || h.status == http.StatusForbidden
+	if !denied && (method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions) {
+		return
+	}
+	username := h.authorizedAdminUser
+	if username == "" {
+		username, _ = h.getBasicAuth()
+	}
+	outcome := "allowed"
+	if denied {
+		outcome = "denied"
+	}
+	base.InfofCtx(h.ctx(), base.KeyAll, "Admin audit: %s %s %s by user %s (auth: %s, role: %s) -> %d",
+		outcome, method, base.SanitizeRequestURL(h.rq, nil), base.UD(username), h.adminAuthSource, h.adminRole, h.status)
+}
+
+// authorizeLocalAdmin authenticates the request as the local admin account, and checks the account's roles grant
+// access to the endpoint.
+func (h *handler) authorizeLocalAdmin(username, password string, account *LocalAdminAccountConfig, accessPermissions, responsePermissions []Permission) error {
+	if !account.authenticate(password) {
+		base.InfofCtx(h.ctx(), base.KeyAuth, "%s: Local admin account %s failed to authenticate", h.formatSerialNumber(), base.UD(username))
+		return ErrInvalidLogin
+	}
+	authorized, role, permissions := authorizeAdminRoles(account.Roles, h.rq.Method, accessPermissions, responsePermissions)
+	if !authorized {
+		base.InfofCtx(h.ctx(), base.KeyAuth, "%s: Local admin account %s does not have an admin role granting access", h.formatSerialNumber(), base.UD(username))
+		return base.HTTPErrorf(http.StatusForbidden, "")
+	}
+	h.authorizedAdminUser = username
+	h.adminAuthSource = adminAuthSourceLocal
+	h.adminRole = role
+	h.permissionsResults = permissions
+	base.DebugfCtx(h.ctx(), base.KeyAuth, "%s: Local admin account %s was successfully authorized", h.formatSerialNumber(), base.UD(username))
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorizeAdminRoles(t *testing.T) {
+	testCases := []struct {
+		name              string
+		roles             []string
+		method            string
+		accessPermissions []Permission
+		authorized        bool
+	}{
+		{"no roles", nil, http.MethodGet, nil, false},
+		{"any role for unrestricted endpoint", []string{AdminRoleMetricsRead}, http.MethodGet, nil, true},
+		{"config-read reads config", []string{AdminRoleConfigRead}, http.MethodGet, []Permission{PermUpdateDb, PermConfigureSyncFn}, true},
+		{"config-read can't write config", []string{AdminRoleConfigRead}, http.MethodPut, []Permission{PermUpdateDb}, false},
+		{"config-write reads config", []string{AdminRoleConfigWrite}, http.MethodGet, []Permission{PermUpdateDb}, true},
+		{"config-write writes config", []string{AdminRoleConfigWrite}, http.MethodDelete, []Permission{PermDeleteDb}, true},
+		{"user-admin writes users", []string{AdminRoleUserAdmin}, http.MethodPut, []Permission{PermWritePrincipal}, true},
+		{"user-admin can't write replications", []string{AdminRoleUserAdmin}, http.MethodPut, []Permission{PermWriteReplications}, false},
+		{"replication-admin writes replications", []string{AdminRoleReplicationAdmin}, http.MethodPost, []Permission{PermWriteReplications}, true},
+		{"metrics-read reads stats", []string{AdminRoleMetricsRead}, http.MethodGet, []Permission{PermStatsExport}, true},
+		{"no role reads documents", allAdminRoles, http.MethodGet, []Permission{PermReadAppData}, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			authorized, _, _ := authorizeAdminRoles(tc.roles, tc.method, tc.accessPermissions, nil)
+			assert.Equal(t, tc.authorized, authorized)
+		})
+	}
+
+	// Response permissions are granted by the roles individually
+	authorized, role, permissions := authorizeAdminRoles([]string{AdminRoleConfigRead}, http.MethodGet, []Permission{PermUpdateDb}, []Permission{PermConfigureSyncFn, PermReadPrincipalAppData})
+	require.True(t, authorized)
+	assert.Equal(t, AdminRoleConfigRead, role)
+	assert.Equal(t, map[string]bool{PermConfigureSyncFn.PermissionName: true, PermReadPrincipalAppData.PermissionName: false}, permissions)
+}
+
+func TestAdminRBACConfigValidate(t *testing.T) {
+	config := &AdminRBACConfig{
+		Users:         map[string][]string{"cbsuser": {AdminRoleUserAdmin}},
+		LocalAccounts: map[string]*LocalAdminAccountConfig{"local": {Password: "password", Roles: []string{AdminRoleMetricsRead}}},
+	}
+	require.NoError(t, config.validate())
+
+	config.Users["cbsuser"] = []string{"superuser"}
+	assert.ErrorContains(t, config.validate(), "unknown admin role")
+
+	config.Users = map[string][]string{"local": {AdminRoleUserAdmin}}
+	assert.ErrorContains(t, config.validate(), "cannot be both a user and a local account")
+
+	config.Users = nil
+	config.LocalAccounts["local"].Password = ""
+	assert.ErrorContains(t, config.validate(), "password must be set")
+}
+
+func TestLocalAdminAccounts(t *testing.T) {
+	rt := NewRestTester(t, &RestTesterConfig{
+		AdminInterfaceAuthentication: true,
+		// Admin authentication is disabled for walrus on the default admin interface, and local accounts don't need
+		// Couchbase Server to authenticate
+		adminInterface: "127.0.0.1:14985",
+		MutateStartupConfig: func(config *StartupConfig) {
+			config.API.AdminRBAC = &AdminRBACConfig{
+				LocalAccounts: map[string]*LocalAdminAccountConfig{
+					"useradmin":    {Password: "password", Roles: []string{AdminRoleUserAdmin}},
+					"configreader": {Password: "password", Roles: []string{AdminRoleConfigRead}},
+				},
+			}
+		},
+	})
+	defer rt.Close()
+	_ = rt.Bucket()
+
+	resp := rt.SendAdminRequestWithAuth(http.MethodPut, "/{{.db}}/_user/alice", `{"password": "letmein"}`, "useradmin", "password")
+	RequireStatus(t, resp, http.StatusCreated)
+	resp = rt.SendAdminRequestWithAuth(http.MethodGet, "/{{.db}}/_config", "", "useradmin", "password")
+	RequireStatus(t, resp, http.StatusForbidden)
+	resp = rt.SendAdminRequestWithAuth(http.MethodGet, "/{{.keyspace}}/doc1", "", "useradmin", "password")
+	RequireStatus(t, resp, http.StatusForbidden)
+
+	resp = rt.SendAdminRequestWithAuth(http.MethodGet, "/{{.db}}/_config", "", "configreader", "password")
+	RequireStatus(t, resp, http.StatusOK)
+	resp = rt.SendAdminRequestWithAuth(http.MethodPut, "/{{.db}}/_user/bob", `{"password": "letmein"}`, "configreader", "password")
+	RequireStatus(t, resp, http.StatusForbidden)
+
+	resp = rt.SendAdminRequestWithAuth(http.MethodPut, "/{{.db}}/_user/bob", `{"password": "letmein"}`, "useradmin", "wrong")
+	RequireStatus(t, resp, http.StatusUnauthorized)
+}
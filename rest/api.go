@@ -21,7 +21,9 @@ import (
 	"time"
 
 	sgbucket "github.com/couchbase/sg-bucket"
+	"github.com/couchbase/sync_gateway/auth"
 	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
 	"github.com/couchbase/sync_gateway/db"
 	"github.com/felixge/fgprof"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -74,6 +76,32 @@ func (h *handler) handlePing() error {
 	return nil
 }
 
+// healthReport is the response body of the health check endpoints.
+type healthReport struct {
+	Status    string                       `json:"status"`
+	Databases map[string]db.DatabaseHealth `json:"databases"`
+}
+
+// HTTP handler for the liveness healthcheck.  Reports the health of each database, but always returns OK while the
+// server is able to serve requests, since restarting the server won't recover an unavailable dependency.
+func (h *handler) handleHealth() error {
+	report := h.server.healthReport(h.ctx())
+	h.writeJSONStatus(http.StatusOK, report)
+	return nil
+}
+
+// HTTP handler for the readiness healthcheck.  Returns Service Unavailable unless every database is online, and its
+// bucket and DCP feeds are available.
+func (h *handler) handleReady() error {
+	report := h.server.healthReport(h.ctx())
+	status := http.StatusOK
+	if report.Status != db.HealthStatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	h.writeJSONStatus(status, report)
+	return nil
+}
+
 func (h *handler) handleAllDbs() error {
 	if h.getBoolQuery("verbose") {
 		h.writeJSON(h.server.allDatabaseSummaries())
@@ -89,8 +117,8 @@ func (h *handler) handleGetCompact() error {
 		compactionType = "tombstone"
 	}
 
-	if compactionType != "tombstone" && compactionType != "attachment" {
-		return base.HTTPErrorf(http.StatusBadRequest, "Unknown parameter for 'type'. Must be 'tombstone' or 'attachment'")
+	if compactionType != "tombstone" && compactionType != "attachment" && compactionType != "sync_metadata" {
+		return base.HTTPErrorf(http.StatusBadRequest, "Unknown parameter for 'type'. Must be 'tombstone', 'attachment' or 'sync_metadata'")
 	}
 
 	var status []byte
@@ -103,6 +131,10 @@ func (h *handler) handleGetCompact() error {
 		status, err = h.db.AttachmentCompactionManager.GetStatus(h.ctx())
 	}
 
+	if compactionType == "sync_metadata" {
+		status, err = h.db.MetadataCompactionManager.GetStatus(h.ctx())
+	}
+
 	if err != nil {
 		return err
 	}
@@ -126,8 +158,8 @@ func (h *handler) handleCompact() error {
 		compactionType = "tombstone"
 	}
 
-	if compactionType != "tombstone" && compactionType != "attachment" {
-		return base.HTTPErrorf(http.StatusBadRequest, "Unknown parameter for 'type'. Must be 'tombstone' or 'attachment'")
+	if compactionType != "tombstone" && compactionType != "attachment" && compactionType != "sync_metadata" {
+		return base.HTTPErrorf(http.StatusBadRequest, "Unknown parameter for 'type'. Must be 'tombstone', 'attachment' or 'sync_metadata'")
 	}
 
 	if compactionType == "tombstone" {
@@ -197,6 +229,212 @@ func (h *handler) handleCompact() error {
 		}
 	}
 
+	if compactionType == "sync_metadata" {
+		if action == string(db.BackgroundProcessActionStart) {
+			err := h.db.MetadataCompactionManager.Start(h.ctx(), map[string]interface{}{
+				"database":  h.db,
+				"threshold": int(h.getIntQuery("threshold_bytes", db.DefaultSyncMetadataCompactionThreshold)),
+			})
+			if err != nil {
+				return err
+			}
+
+			status, err := h.db.MetadataCompactionManager.GetStatus(h.ctx())
+			if err != nil {
+				return err
+			}
+			h.writeRawJSON(status)
+		} else if action == string(db.BackgroundProcessActionStop) {
+			err := h.db.MetadataCompactionManager.Stop()
+			if err != nil {
+				return err
+			}
+
+			status, err := h.db.MetadataCompactionManager.GetStatus(h.ctx())
+			if err != nil {
+				return err
+			}
+			h.writeRawJSON(status)
+		}
+	}
+
+	return nil
+}
+
+// HTTP handler for GET /{db}/_attachment_verify, reporting the status of the most recent attachment integrity scan
+func (h *handler) handleGetAttachmentVerify() error {
+	status, err := h.db.AttachmentVerifyManager.GetStatus(h.ctx())
+	if err != nil {
+		return err
+	}
+	h.writeRawJSON(status)
+	return nil
+}
+
+// HTTP handler for POST /{db}/_attachment_verify, starting or stopping an attachment integrity scan
+func (h *handler) handleAttachmentVerify() error {
+	action := h.getQuery("action")
+	if action == "" {
+		action = string(db.BackgroundProcessActionStart)
+	}
+
+	switch action {
+	case string(db.BackgroundProcessActionStart):
+		err := h.db.AttachmentVerifyManager.Start(h.ctx(), map[string]interface{}{
+			"database":   h.db,
+			"quarantine": h.getBoolQuery("quarantine"),
+		})
+		if err != nil {
+			return err
+		}
+	case string(db.BackgroundProcessActionStop):
+		if err := h.db.AttachmentVerifyManager.Stop(); err != nil {
+			return err
+		}
+	default:
+		return base.HTTPErrorf(http.StatusBadRequest, "Unknown parameter for 'action'. Must be start or stop")
+	}
+
+	status, err := h.db.AttachmentVerifyManager.GetStatus(h.ctx())
+	if err != nil {
+		return err
+	}
+	h.writeRawJSON(status)
+	return nil
+}
+
+// HTTP handler for GET /{db}/_import_backfill, reporting the status of the most recent import backfill
+func (h *handler) handleGetImportBackfill() error {
+	status, err := h.db.ImportBackfillManager.GetStatus(h.ctx())
+	if err != nil {
+		return err
+	}
+	h.writeRawJSON(status)
+	return nil
+}
+
+// HTTP handler for POST /{db}/_import_backfill, starting or stopping the import of the documents already in the
+// database's collections
+func (h *handler) handleImportBackfill() error {
+	if !h.db.UseXattrs() {
+		return base.HTTPErrorf(http.StatusBadRequest, "Import backfill requires enable_shared_bucket_access")
+	}
+
+	action := h.getQuery("action")
+	if action == "" {
+		action = string(db.BackgroundProcessActionStart)
+	}
+
+	switch action {
+	case string(db.BackgroundProcessActionStart):
+		err := h.db.ImportBackfillManager.Start(h.ctx(), map[string]interface{}{
+			"database": h.db,
+		})
+		if err != nil {
+			return err
+		}
+	case string(db.BackgroundProcessActionStop):
+		if err := h.db.ImportBackfillManager.Stop(); err != nil {
+			return err
+		}
+	default:
+		return base.HTTPErrorf(http.StatusBadRequest, "Unknown parameter for 'action'. Must be start or stop")
+	}
+
+	status, err := h.db.ImportBackfillManager.GetStatus(h.ctx())
+	if err != nil {
+		return err
+	}
+	h.writeRawJSON(status)
+	return nil
+}
+
+// HTTP handler for GET /{db}/_channel_rename, reporting the status of the most recent channel rename
+func (h *handler) handleGetChannelRename() error {
+	status, err := h.db.ChannelRenameManager.GetStatus(h.ctx())
+	if err != nil {
+		return err
+	}
+	h.writeRawJSON(status)
+	return nil
+}
+
+// HTTP handler for POST /{db}/_channel_rename, starting or stopping the rename of a channel across the database's
+// documents and principals
+func (h *handler) handleChannelRename() error {
+	action := h.getQuery("action")
+	if action == "" {
+		action = string(db.BackgroundProcessActionStart)
+	}
+
+	switch action {
+	case string(db.BackgroundProcessActionStart):
+		from, to := h.getQuery("from"), h.getQuery("to")
+		if err := db.ValidateChannelRename(from, to); err != nil {
+			return base.HTTPErrorf(http.StatusBadRequest, "Invalid channel rename: %v", err)
+		}
+		err := h.db.ChannelRenameManager.Start(h.ctx(), map[string]interface{}{
+			"database": h.db,
+			"from":     from,
+			"to":       to,
+		})
+		if err != nil {
+			return err
+		}
+	case string(db.BackgroundProcessActionStop):
+		if err := h.db.ChannelRenameManager.Stop(); err != nil {
+			return err
+		}
+	default:
+		return base.HTTPErrorf(http.StatusBadRequest, "Unknown parameter for 'action'. Must be start or stop")
+	}
+
+	status, err := h.db.ChannelRenameManager.GetStatus(h.ctx())
+	if err != nil {
+		return err
+	}
+	h.writeRawJSON(status)
+	return nil
+}
+
+// HTTP handler for GET /{db}/_background_tasks, returning the status of all of the database's background managers
+func (h *handler) handleGetBackgroundTasks() error {
+	return h.writeBackgroundTasks()
+}
+
+// HTTP handler for POST /{db}/_background_tasks, stopping the named background manager, or all running background
+// managers if no name is given
+func (h *handler) handleBackgroundTasks() error {
+	if action := h.getQuery("action"); action != string(db.BackgroundProcessActionStop) {
+		return base.HTTPErrorf(http.StatusBadRequest, "Unknown parameter for 'action'. Must be stop")
+	}
+
+	if name := h.getQuery("name"); name != "" {
+		bgManager := h.db.BackgroundManager(name)
+		if bgManager == nil {
+			return base.HTTPErrorf(http.StatusNotFound, "Unknown background task %q", name)
+		}
+		if err := bgManager.Stop(); err != nil {
+			return err
+		}
+	} else {
+		h.db.StopBackgroundManagers()
+	}
+	return h.writeBackgroundTasks()
+}
+
+// writeBackgroundTasks writes the status of all of the database's background managers.
+func (h *handler) writeBackgroundTasks() error {
+	bgManagers := h.db.BackgroundManagers()
+	tasks := make([]*db.BackgroundTaskStatus, 0, len(bgManagers))
+	for _, bgManager := range bgManagers {
+		status, err := bgManager.GetTaskStatus(h.ctx())
+		if err != nil {
+			return err
+		}
+		tasks = append(tasks, status)
+	}
+	h.writeJSON(tasks)
 	return nil
 }
 
@@ -275,6 +513,270 @@ func (h *handler) handleFlush() error {
 
 }
 
+// HTTP handler for GET /db/_sequence_report, reporting pending, skipped and unused sequences on this node
+func (h *handler) handleGetSequenceReport() error {
+	limit := int(h.getRestrictedIntQuery("limit", db.DefaultSequenceReportLimit, 0, db.MaxSequenceReportLimit, true))
+	report, err := h.db.GetSequenceReport(h.ctx(), limit)
+	if err != nil {
+		return err
+	}
+	h.writeJSON(report)
+	return nil
+}
+
+// ReleaseSequencesRequest is the request body of POST /{db}/_release_sequences.
+type ReleaseSequencesRequest struct {
+	Ranges []db.SequenceRange `json:"ranges"`
+}
+
+// ReleaseSequencesResponse is the response body of POST /{db}/_release_sequences.
+type ReleaseSequencesResponse struct {
+	Released []uint64 `json:"released"` // Skipped sequences that were released
+}
+
+// HTTP handler for POST /{db}/_release_sequences, abandoning skipped sequences on this node that are known to never
+// arrive, rather than waiting for the skipped sequence max wait.
+func (h *handler) handleReleaseSequences() error {
+	var body ReleaseSequencesRequest
+	if err := h.readJSONInto(&body); err != nil {
+		return err
+	}
+	if len(body.Ranges) == 0 {
+		return base.HTTPErrorf(http.StatusBadRequest, "At least one sequence range is required")
+	}
+	for i, r := range body.Ranges {
+		// An omitted end releases a single sequence
+		if r.End == 0 {
+			body.Ranges[i].End = r.Start
+		}
+		if r.Start == 0 || body.Ranges[i].End < r.Start {
+			return base.HTTPErrorf(http.StatusBadRequest, "Invalid sequence range {start: %d, end: %d}", r.Start, r.End)
+		}
+	}
+
+	released := h.db.ReleaseSkippedSequences(h.ctx(), body.Ranges)
+	base.InfofCtx(h.ctx(), base.KeyAll, "Skipped sequences in ranges %v released by %s, %d sequences abandoned: %v", body.Ranges, h.taggedEffectiveUserName(), len(released), released)
+	if released == nil {
+		released = []uint64{}
+	}
+	h.writeJSON(ReleaseSequencesResponse{Released: released})
+	return nil
+}
+
+// MaintenanceWindowOverrideRequest is the request body of PUT /{db}/_maintenance_window.
+type MaintenanceWindowOverrideRequest struct {
+	Override     db.MaintenanceOverride `json:"override"`                // "open", "closed", or empty to clear the override
+	DurationMins uint32                 `json:"duration_mins,omitempty"` // How long the override applies for. 0 applies it until cleared
+}
+
+// HTTP handler for GET /{db}/_maintenance_window
+func (h *handler) handleGetMaintenanceWindow() error {
+	h.writeJSON(h.db.MaintenanceSchedule.Status())
+	return nil
+}
+
+// HTTP handler for PUT /{db}/_maintenance_window, forcing the database's maintenance window open or closed
+// regardless of its schedule.
+func (h *handler) handlePutMaintenanceWindow() error {
+	var body MaintenanceWindowOverrideRequest
+	if err := h.readJSONInto(&body); err != nil {
+		return err
+	}
+	if err := h.db.MaintenanceSchedule.SetOverride(body.Override, time.Duration(body.DurationMins)*time.Minute); err != nil {
+		return base.HTTPErrorf(http.StatusBadRequest, "%s", err)
+	}
+	base.InfofCtx(h.ctx(), base.KeyAll, "Maintenance window override set to %q for %d mins by %s", body.Override, body.DurationMins, h.taggedEffectiveUserName())
+	h.writeJSON(h.db.MaintenanceSchedule.Status())
+	return nil
+}
+
+// ImportQuarantineResponse is the response body of GET /{db}/_import_quarantine.
+type ImportQuarantineResponse struct {
+	Documents []db.ImportQuarantineEntry `json:"documents"`
+}
+
+// HTTP handler for GET /{db}/_import_quarantine, listing documents quarantined after repeatedly failing import.
+func (h *handler) handleGetImportQuarantine() error {
+	entries, err := h.db.GetImportQuarantine(h.ctx())
+	if err != nil {
+		return err
+	}
+	h.writeJSON(ImportQuarantineResponse{Documents: entries})
+	return nil
+}
+
+// HTTP handler for POST /{keyspace}/_import_quarantine/{docid}, removing a document from the import quarantine and
+// retrying its import.
+func (h *handler) handleRetryQuarantinedImport() error {
+	docID := h.PathVar("docid")
+	if err := h.collection.RetryQuarantinedImport(h.ctx(), docID); err != nil {
+		return err
+	}
+	h.writeRawJSONStatus(http.StatusOK, []byte(`{"id":`+base.ConvertToJSONString(docID)+`,"ok":true}`))
+	return nil
+}
+
+// HTTP handler for POST /{keyspace}/{docid}/_resync, re-running the sync function on a single document and reporting
+// the changes made to its channels and access grants.
+func (h *handler) handlePostDocResync() error {
+	result, err := h.collection.ResyncDocument(h.ctx(), h.PathVar("docid"))
+	if err != nil {
+		return err
+	}
+	h.writeJSON(result)
+	return nil
+}
+
+// HTTP handler for POST /{keyspace}/{docid}/_repair_revtree, repairing broken parent links in the document's rev tree
+// and reporting the repairs made.  With dry_run=true, the repairs that would be made are reported without saving them.
+func (h *handler) handlePostRepairRevTree() error {
+	result, err := h.collection.RepairRevTree(h.ctx(), h.PathVar("docid"), h.getBoolQuery("dry_run"))
+	if err != nil {
+		return err
+	}
+	h.writeJSON(result)
+	return nil
+}
+
+// HTTP handler for GET /{keyspace}/_access/{docid}, reporting the document's channels, channel history and access
+// grants.  If a user is given, the document's channels the user has access to are included.
+func (h *handler) handleGetDocAccess() error {
+	var user auth.User
+	if username := h.getQuery("user"); username != "" {
+		var err error
+		user, err = h.db.Authenticator(h.ctx()).GetUser(internalUserName(username))
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return base.HTTPErrorf(http.StatusNotFound, "No such user %q", username)
+		}
+	}
+	access, err := h.collection.GetDocumentAccess(h.ctx(), h.PathVar("docid"), user)
+	if err != nil {
+		return err
+	}
+	h.writeJSON(access)
+	return nil
+}
+
+// MigrateCheckpointsRequest is the request body of POST /{keyspace}/_migrate_checkpoints.
+type MigrateCheckpointsRequest struct {
+	// Checkpoints maps the client ID of each pre-collections checkpoint to the checkpoint ID the collection aware
+	// client will use. An empty checkpoint ID keeps the client ID.
+	Checkpoints map[string]string `json:"checkpoints"`
+	Overwrite   bool              `json:"overwrite,omitempty"` // Replace checkpoints already present in the collection
+}
+
+// MigrateCheckpointsResponse is the response body of POST /{keyspace}/_migrate_checkpoints, keyed by client ID.
+type MigrateCheckpointsResponse struct {
+	Results map[string]string `json:"results"`
+}
+
+// HTTP handler for POST /{keyspace}/_migrate_checkpoints, copying pre-collections client checkpoints into the
+// keyspace so that clients upgraded to collection aware replication resume rather than replicating from scratch.
+func (h *handler) handleMigrateCheckpoints() error {
+	var body MigrateCheckpointsRequest
+	if err := h.readJSONInto(&body); err != nil {
+		return err
+	}
+	if len(body.Checkpoints) == 0 {
+		return base.HTTPErrorf(http.StatusBadRequest, "At least one checkpoint is required")
+	}
+
+	results := make(map[string]string, len(body.Checkpoints))
+	for clientID, checkpointID := range body.Checkpoints {
+		if checkpointID == "" {
+			checkpointID = clientID
+		}
+		outcome, err := h.collection.MigrateCheckpoint(h.ctx(), clientID, checkpointID, body.Overwrite)
+		if err != nil {
+			return err
+		}
+		results[clientID] = outcome
+	}
+	base.InfofCtx(h.ctx(), base.KeyAll, "Checkpoints migrated to %s.%s by %s: %v", base.MD(h.collection.ScopeName), base.MD(h.collection.Name), h.taggedEffectiveUserName(), base.UD(results))
+	h.writeJSON(MigrateCheckpointsResponse{Results: results})
+	return nil
+}
+
+// VerifyCheckpointRequest is the request body of POST /{keyspace}/_verify_checkpoint.
+type VerifyCheckpointRequest struct {
+	CheckpointID string            `json:"checkpoint_id"`      // ID of the client's checkpoint, as sent in getCheckpoint
+	Username     string            `json:"user,omitempty"`     // User the client replicates as. Omit to replay all documents
+	Channels     []string          `json:"channels,omitempty"` // Channels the client replicates. Omit for all the user's channels
+	Docs         map[string]string `json:"docs"`               // Current revision ID of each document the client has, by doc ID
+	Limit        int               `json:"limit,omitempty"`    // Maximum number of changes to replay
+}
+
+// HTTP handler for POST /{keyspace}/_verify_checkpoint, replaying the changes feed a client would see from its
+// checkpoint and reporting differences from the documents the client has.
+func (h *handler) handleVerifyCheckpoint() error {
+	var body VerifyCheckpointRequest
+	if err := h.readJSONInto(&body); err != nil {
+		return err
+	}
+	if body.CheckpointID == "" {
+		return base.HTTPErrorf(http.StatusBadRequest, "checkpoint_id is required")
+	}
+	if body.Limit < 0 {
+		return base.HTTPErrorf(http.StatusBadRequest, "limit must not be negative")
+	}
+
+	options := db.CheckpointVerificationOptions{
+		Username: body.Username,
+		Docs:     body.Docs,
+		Limit:    body.Limit,
+	}
+	if body.Channels != nil {
+		chans, err := channels.SetFromArray(body.Channels, channels.KeepStar)
+		if err != nil {
+			return base.HTTPErrorf(http.StatusBadRequest, "Invalid channels: %v", err)
+		}
+		options.Channels = chans
+	}
+
+	result, err := h.collection.VerifyCheckpoint(h.ctx(), body.CheckpointID, options)
+	if err != nil {
+		return err
+	}
+	h.writeJSON(result)
+	return nil
+}
+
+// PinnedRevCacheDocsResponse is the response body of the /{keyspace}/_rev_cache/pinned endpoints.
+type PinnedRevCacheDocsResponse struct {
+	PinnedDocs []string `json:"pinned_docs"`
+}
+
+// HTTP handler for GET /{keyspace}/_rev_cache/pinned
+func (h *handler) handleGetPinnedRevCacheDocs() error {
+	h.writeJSON(PinnedRevCacheDocsResponse{PinnedDocs: h.collection.PinnedRevisionCacheDocs()})
+	return nil
+}
+
+// HTTP handler for PUT /{keyspace}/_rev_cache/pinned/{docid}, pinning the document's current revision in the revision
+// cache so that it's never evicted.
+func (h *handler) handlePinRevCacheDoc() error {
+	if h.db.Options.RevisionCacheOptions != nil && h.db.Options.RevisionCacheOptions.Size == 0 {
+		return base.HTTPErrorf(http.StatusServiceUnavailable, "The revision cache is disabled")
+	}
+	docID := h.PathVar("docid")
+	h.collection.PinRevisionCacheDoc(docID)
+	base.InfofCtx(h.ctx(), base.KeyCache, "Doc %q pinned in revision cache by %s", base.UD(docID), h.taggedEffectiveUserName())
+	h.writeJSON(PinnedRevCacheDocsResponse{PinnedDocs: h.collection.PinnedRevisionCacheDocs()})
+	return nil
+}
+
+// HTTP handler for DELETE /{keyspace}/_rev_cache/pinned/{docid}
+func (h *handler) handleUnpinRevCacheDoc() error {
+	docID := h.PathVar("docid")
+	h.collection.UnpinRevisionCacheDoc(docID)
+	base.InfofCtx(h.ctx(), base.KeyCache, "Doc %q unpinned from revision cache by %s", base.UD(docID), h.taggedEffectiveUserName())
+	h.writeJSON(PinnedRevCacheDocsResponse{PinnedDocs: h.collection.PinnedRevisionCacheDocs()})
+	return nil
+}
+
 func (h *handler) handleGetResync() error {
 	status, err := h.db.ResyncManager.GetStatus(h.ctx())
 	if err != nil {
@@ -421,6 +923,52 @@ type databaseRootCollection struct {
 	SequenceNumber uint64 `json:"update_seq"` // The last sequence written for this collection
 }
 
+// DatabaseCapabilities is the response body of GET /{db}/_capabilities, describing the replication features supported
+// by the database so that clients don't need to discover them by trial and error.
+type DatabaseCapabilities struct {
+	BlipSubprotocols []string                `json:"blip_subprotocols"` // BLIP AppProtocolIds accepted by the database, in order of preference
+	DeltaSync        bool                    `json:"delta_sync"`        // True if delta sync is enabled
+	Revocations      bool                    `json:"revocations"`       // True if revocation messages can be requested in subChanges
+	Collections      bool                    `json:"collections"`       // True if the database has named collections, which require collection aware replication
+	Attachments      AttachmentCapabilities  `json:"attachments"`
+	MaxRequestSizes  RequestSizeCapabilities `json:"max_request_sizes"`
+}
+
+type AttachmentCapabilities struct {
+	MaxSizeBytes int `json:"max_size_bytes"` // Maximum size of a single attachment
+}
+
+type RequestSizeCapabilities struct {
+	DocumentBytes int  `json:"document_bytes"`           // Maximum size of a document
+	FunctionBytes *int `json:"function_bytes,omitempty"` // Maximum size of the arguments to a user function, when limited
+	GraphQLBytes  *int `json:"graphql_bytes,omitempty"`  // Maximum size of a GraphQL query and its arguments, when limited
+}
+
+// HTTP handler for GET /{db}/_capabilities. Doesn't require authentication, as clients probe capabilities before
+// deciding how to connect.
+func (h *handler) handleGetCapabilities() error {
+	capabilities := DatabaseCapabilities{
+		BlipSubprotocols: db.SupportedBlipProtocols(),
+		DeltaSync:        h.db.DeltaSyncEnabled(),
+		Revocations:      true,
+		Collections:      !h.db.OnlyDefaultCollection(),
+		Attachments: AttachmentCapabilities{
+			MaxSizeBytes: db.MaxAttachmentSizeBytes,
+		},
+		MaxRequestSizes: RequestSizeCapabilities{
+			DocumentBytes: db.MaxDocumentSizeBytes,
+		},
+	}
+	if h.db.Options.UserFunctions != nil {
+		capabilities.MaxRequestSizes.FunctionBytes = h.db.Options.UserFunctions.MaxRequestSize
+	}
+	if h.db.Options.GraphQL != nil {
+		capabilities.MaxRequestSizes.GraphQLBytes = h.db.Options.GraphQL.MaxRequestSize()
+	}
+	h.writeJSON(capabilities)
+	return nil
+}
+
 func (h *handler) handleGetDB() error {
 	if h.rq.Method == "HEAD" {
 		return nil
@@ -453,6 +453,43 @@ func TestBulkDocsIDGeneration(t *testing.T) {
 	assert.True(t, docs[1]["id"] != "")
 }
 
+func TestBulkDocsMemoryLimits(t *testing.T) {
+	rt := NewRestTester(t, nil)
+	defer rt.Close()
+
+	input := `{"docs": [{"_id": "bulk1", "n": 1}, {"_id": "bulk2", "n": 2}]}`
+	bodyMemory := int64(len(input)) * bulkMemoryExpansion
+	inFlight := base.SyncGatewayStats.GlobalStats.ResourceUtilizationStats().BulkMemoryInFlight
+
+	// Over the per-request limit
+	rt.ServerContext().bulkMemory = newBulkMemoryGuard(bodyMemory-1, 0)
+	response := rt.SendAdminRequest(http.MethodPost, "/{{.keyspace}}/_bulk_docs", input)
+	RequireStatus(t, response, http.StatusServiceUnavailable)
+	assert.Contains(t, response.Body.String(), "smaller batches")
+
+	// Over the node limit while another request is in flight, until it completes
+	guard := newBulkMemoryGuard(0, bodyMemory)
+	rt.ServerContext().bulkMemory = guard
+	otherRequest := guard.newReservation()
+	require.NoError(t, otherRequest.reserve(base.TestCtx(t), 1))
+	response = rt.SendAdminRequest(http.MethodPost, "/{{.keyspace}}/_bulk_docs", input)
+	RequireStatus(t, response, http.StatusServiceUnavailable)
+	assert.Contains(t, response.Body.String(), "Retry the request later")
+	assert.Equal(t, int64(1), inFlight.Value())
+
+	otherRequest.release()
+	response = rt.SendAdminRequest(http.MethodPost, "/{{.keyspace}}/_bulk_docs", input)
+	RequireStatus(t, response, http.StatusCreated)
+	assert.Equal(t, int64(0), inFlight.Value())
+
+	// A body without a known length is rejected as it's read
+	reservation := newBulkMemoryGuard(bodyMemory-1, 0).newReservation()
+	reader := &bulkMemoryReader{ctx: base.TestCtx(t), reservation: reservation, reader: io.NopCloser(strings.NewReader(input))}
+	_, err := io.ReadAll(reader)
+	assert.Equal(t, reservation.err, err)
+	assert.Equal(t, http.StatusServiceUnavailable, err.(*base.HTTPError).Status)
+}
+
 /*
 func TestBulkDocsUnusedSequences(t *testing.T) {
 
@@ -1437,7 +1474,7 @@ func TestEventConfigValidationInvalid(t *testing.T) {
 
 	buf := bytes.NewBufferString(dbConfigJSON)
 	var dbConfig DbConfig
-	err := DecodeAndSanitiseConfig(base.TestCtx(t), buf, &dbConfig, true)
+	err := DecodeAndSanitiseConfig(base.TestCtx(t), buf, &dbConfig, true, false)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "document_scribbled_on")
 }
@@ -2537,7 +2574,9 @@ func TestDocumentChannelHistory(t *testing.T) {
 	assert.NoError(t, err)
 
 	require.Len(t, syncData.ChannelSet, 1)
-	assert.Equal(t, syncData.ChannelSet[0], db.ChannelSetEntry{Name: "test", Start: 1, End: 2})
+	endTime := syncData.ChannelSet[0].EndTime
+	assert.NotZero(t, endTime)
+	assert.Equal(t, syncData.ChannelSet[0], db.ChannelSetEntry{Name: "test", Start: 1, End: 2, EndTime: endTime})
 	assert.Len(t, syncData.ChannelSetHistory, 0)
 
 	// Update doc to add to channels test and test2 and ensure a single channel history entry for both test and test2
@@ -2553,7 +2592,7 @@ func TestDocumentChannelHistory(t *testing.T) {
 	assert.Contains(t, syncData.ChannelSet, db.ChannelSetEntry{Name: "test", Start: 3, End: 0})
 	assert.Contains(t, syncData.ChannelSet, db.ChannelSetEntry{Name: "test2", Start: 3, End: 0})
 	require.Len(t, syncData.ChannelSetHistory, 1)
-	assert.Equal(t, syncData.ChannelSetHistory[0], db.ChannelSetEntry{Name: "test", Start: 1, End: 2})
+	assert.Equal(t, syncData.ChannelSetHistory[0], db.ChannelSetEntry{Name: "test", Start: 1, End: 2, EndTime: endTime})
 }
 
 func TestChannelHistoryLegacyDoc(t *testing.T) {
@@ -2614,10 +2653,12 @@ func TestChannelHistoryLegacyDoc(t *testing.T) {
 	syncData, err := rt.GetSingleTestDatabaseCollection().GetDocSyncData(base.TestCtx(t), "doc1")
 	assert.NoError(t, err)
 	require.Len(t, syncData.ChannelSet, 1)
+	assert.NotZero(t, syncData.ChannelSet[0].EndTime)
 	assert.Contains(t, syncData.ChannelSet, db.ChannelSetEntry{
-		Name:  "test",
-		Start: 1,
-		End:   2,
+		Name:    "test",
+		Start:   1,
+		End:     2,
+		EndTime: syncData.ChannelSet[0].EndTime,
 	})
 	assert.Len(t, syncData.ChannelSetHistory, 0)
 }
@@ -2803,6 +2844,48 @@ func TestTombstoneCompactionAPI(t *testing.T) {
 	}
 }
 
+func TestBackgroundTasksAPI(t *testing.T) {
+	rt := NewRestTester(t, nil)
+	defer rt.Close()
+
+	getTasks := func() map[string]db.BackgroundTaskStatus {
+		resp := rt.SendAdminRequest(http.MethodGet, "/{{.db}}/_background_tasks", "")
+		RequireStatus(t, resp, http.StatusOK)
+		var tasks []db.BackgroundTaskStatus
+		require.NoError(t, base.JSONUnmarshal(resp.BodyBytes(), &tasks))
+		tasksByName := make(map[string]db.BackgroundTaskStatus, len(tasks))
+		for _, task := range tasks {
+			tasksByName[task.Name] = task
+		}
+		return tasksByName
+	}
+
+	tasks := getTasks()
+	require.Len(t, tasks, len(rt.GetDatabase().BackgroundManagers()))
+	for _, name := range []string{"resync", "tombstone_compaction", "attachment_compaction", "channel_rename"} {
+		require.Contains(t, tasks, name)
+		assert.Equal(t, db.BackgroundProcessStateCompleted, tasks[name].State)
+	}
+	assert.Contains(t, tasks["tombstone_compaction"].Progress, "docs_purged")
+	assert.NotContains(t, tasks["tombstone_compaction"].Progress, "status")
+
+	RequireStatus(t, rt.SendAdminRequest(http.MethodPost, "/{{.db}}/_compact", ""), http.StatusOK)
+	require.NoError(t, rt.WaitForCondition(func() bool {
+		return getTasks()["tombstone_compaction"].State == db.BackgroundProcessStateCompleted
+	}))
+	assert.False(t, getTasks()["tombstone_compaction"].StartTime.IsZero())
+
+	// Stopping a task that isn't running fails, but stopping all tasks only stops those running
+	RequireStatus(t, rt.SendAdminRequest(http.MethodPost, "/{{.db}}/_background_tasks?action=stop&name=tombstone_compaction", ""), http.StatusServiceUnavailable)
+	RequireStatus(t, rt.SendAdminRequest(http.MethodPost, "/{{.db}}/_background_tasks?action=stop&name=unknown", ""), http.StatusNotFound)
+	RequireStatus(t, rt.SendAdminRequest(http.MethodPost, "/{{.db}}/_background_tasks?action=start", ""), http.StatusBadRequest)
+	resp := rt.SendAdminRequest(http.MethodPost, "/{{.db}}/_background_tasks?action=stop", "")
+	RequireStatus(t, resp, http.StatusOK)
+	var tasksAfterStop []db.BackgroundTaskStatus
+	require.NoError(t, base.JSONUnmarshal(resp.BodyBytes(), &tasksAfterStop))
+	assert.Len(t, tasksAfterStop, len(tasks))
+}
+
 func assertHTTPErrorReason(t testing.TB, response *TestResponse, expectedStatus int, expectedReason string) {
 	var httpError struct {
 		Reason string `json:"reason"`
@@ -2862,6 +2945,43 @@ func TestPing(t *testing.T) {
 	}
 }
 
+// TestHealthCheck ensures that /_health always reports the server as live, and /_ready reports it as unavailable
+// when a database isn't online.
+func TestHealthCheck(t *testing.T) {
+	rt := NewRestTester(t, nil)
+	defer rt.Close()
+	dbName := rt.GetDatabase().Name
+
+	for _, endpoint := range []string{"/_health", "/_ready"} {
+		resp := rt.SendRequest(http.MethodGet, endpoint, "")
+		RequireStatus(t, resp, http.StatusOK)
+		var report healthReport
+		require.NoError(t, base.JSONUnmarshal(resp.BodyBytes(), &report))
+		assert.Equal(t, db.HealthStatusOK, report.Status)
+		require.Contains(t, report.Databases, dbName)
+		dbHealth := report.Databases[dbName]
+		assert.Equal(t, db.HealthStatusOK, dbHealth.Status)
+		assert.Equal(t, "Online", dbHealth.State)
+		assert.True(t, dbHealth.BucketReachable)
+		assert.Equal(t, db.FeedStatusRunning, dbHealth.CachingFeed)
+	}
+
+	rt.TakeDbOffline()
+
+	resp := rt.SendAdminRequest(http.MethodGet, "/_ready", "")
+	RequireStatus(t, resp, http.StatusServiceUnavailable)
+	var report healthReport
+	require.NoError(t, base.JSONUnmarshal(resp.BodyBytes(), &report))
+	assert.Equal(t, db.HealthStatusDegraded, report.Status)
+	assert.Equal(t, db.HealthStatusUnavailable, report.Databases[dbName].Status)
+
+	resp = rt.SendMetricsRequest(http.MethodGet, "/_health", "")
+	RequireStatus(t, resp, http.StatusOK)
+	report = healthReport{}
+	require.NoError(t, base.JSONUnmarshal(resp.BodyBytes(), &report))
+	assert.Equal(t, db.HealthStatusDegraded, report.Status)
+}
+
 func TestAllDbs(t *testing.T) {
 	rt := NewRestTester(t, nil)
 	defer rt.Close()
@@ -2874,3 +2994,130 @@ func TestAllDbs(t *testing.T) {
 	RequireStatus(t, resp, http.StatusOK)
 	require.Equal(t, fmt.Sprintf(`[{"db_name":"%s","bucket":"%s","state":"Online"}]`, rt.GetDatabase().Name, rt.GetDatabase().Bucket.GetName()), resp.Body.String())
 }
+
+func TestReleaseSequences(t *testing.T) {
+	rt := NewRestTester(t, nil)
+	defer rt.Close()
+
+	testCases := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "noRanges", body: `{"ranges":[]}`, expectedStatus: http.StatusBadRequest},
+		{name: "zeroStart", body: `{"ranges":[{"start":0,"end":5}]}`, expectedStatus: http.StatusBadRequest},
+		{name: "endBeforeStart", body: `{"ranges":[{"start":10,"end":5}]}`, expectedStatus: http.StatusBadRequest},
+		{name: "singleSequence", body: `{"ranges":[{"start":10}]}`, expectedStatus: http.StatusOK},
+		{name: "range", body: `{"ranges":[{"start":10,"end":20}]}`, expectedStatus: http.StatusOK},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			resp := rt.SendAdminRequest(http.MethodPost, "/{{.db}}/_release_sequences", test.body)
+			RequireStatus(t, resp, test.expectedStatus)
+			if test.expectedStatus == http.StatusOK {
+				// No sequences are skipped, so none are released
+				assert.JSONEq(t, `{"released":[]}`, resp.Body.String())
+			}
+		})
+	}
+}
+
+func TestPinnedRevCacheDocs(t *testing.T) {
+	rt := NewRestTester(t, &RestTesterConfig{
+		DatabaseConfig: &DatabaseConfig{DbConfig: DbConfig{
+			CacheConfig: &CacheConfig{
+				RevCacheConfig: &RevCacheConfig{
+					PinnedDocs: []string{"config"},
+				},
+			},
+		}},
+	})
+	defer rt.Close()
+
+	resp := rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/_rev_cache/pinned", "")
+	RequireStatus(t, resp, http.StatusOK)
+	assert.JSONEq(t, `{"pinned_docs":["config"]}`, resp.Body.String())
+
+	resp = rt.SendAdminRequest(http.MethodPut, "/{{.keyspace}}/_rev_cache/pinned/lookup", "")
+	RequireStatus(t, resp, http.StatusOK)
+	assert.JSONEq(t, `{"pinned_docs":["config","lookup"]}`, resp.Body.String())
+
+	version := rt.PutDoc("lookup", `{"value":1}`)
+	_, found := rt.GetSingleTestDatabaseCollection().GetRevisionCacheForTest().Peek(base.TestCtx(t), "lookup", version.RevID)
+	assert.True(t, found)
+
+	resp = rt.SendAdminRequest(http.MethodDelete, "/{{.keyspace}}/_rev_cache/pinned/config", "")
+	RequireStatus(t, resp, http.StatusOK)
+	assert.JSONEq(t, `{"pinned_docs":["lookup"]}`, resp.Body.String())
+}
+
+func TestGetCapabilities(t *testing.T) {
+	rt := NewRestTester(t, &RestTesterConfig{
+		DatabaseConfig: &DatabaseConfig{DbConfig: DbConfig{
+			DeltaSync: &DeltaSyncConfig{Enabled: base.BoolPtr(true)},
+		}},
+	})
+	defer rt.Close()
+
+	// Capabilities don't require authentication
+	resp := rt.SendRequest(http.MethodGet, "/{{.db}}/_capabilities", "")
+	RequireStatus(t, resp, http.StatusOK)
+
+	var capabilities DatabaseCapabilities
+	require.NoError(t, base.JSONUnmarshal(resp.Body.Bytes(), &capabilities))
+	assert.Equal(t, []string{db.BlipCBMobileReplicationV3, db.BlipCBMobileReplicationV2}, capabilities.BlipSubprotocols)
+	assert.Equal(t, rt.GetDatabase().DeltaSyncEnabled(), capabilities.DeltaSync)
+	assert.True(t, capabilities.Revocations)
+	assert.Equal(t, !rt.GetDatabase().OnlyDefaultCollection(), capabilities.Collections)
+	assert.Equal(t, db.MaxAttachmentSizeBytes, capabilities.Attachments.MaxSizeBytes)
+	assert.Equal(t, db.MaxDocumentSizeBytes, capabilities.MaxRequestSizes.DocumentBytes)
+	assert.Nil(t, capabilities.MaxRequestSizes.FunctionBytes)
+
+	// The existence of databases isn't revealed to unauthenticated requests
+	resp = rt.SendRequest(http.MethodGet, "/nodb/_capabilities", "")
+	RequireStatus(t, resp, http.StatusUnauthorized)
+}
+
+func TestReplicationOnlyMode(t *testing.T) {
+	rt := NewRestTester(t, &RestTesterConfig{
+		GuestEnabled: true,
+		MutateStartupConfig: func(config *StartupConfig) {
+			config.API.ReplicationOnly = base.BoolPtr(true)
+		},
+	})
+	defer rt.Close()
+	_ = rt.Bucket()
+
+	// Health checks and sessions are served on the public API, but not the REST document API
+	RequireStatus(t, rt.SendRequest(http.MethodGet, "/_ping", ""), http.StatusOK)
+	RequireStatus(t, rt.SendRequest(http.MethodGet, "/", ""), http.StatusOK)
+	RequireStatus(t, rt.SendRequest(http.MethodGet, "/{{.db}}/_session", ""), http.StatusOK)
+	RequireStatus(t, rt.SendRequest(http.MethodPut, "/{{.keyspace}}/doc1", `{"foo": "bar"}`), http.StatusNotFound)
+	RequireStatus(t, rt.SendRequest(http.MethodGet, "/{{.keyspace}}/_changes", ""), http.StatusNotFound)
+
+	// The admin API can be read, but not changed
+	RequireStatus(t, rt.SendAdminRequest(http.MethodGet, "/{{.db}}/", ""), http.StatusOK)
+	RequireStatus(t, rt.SendAdminRequest(http.MethodPut, "/{{.keyspace}}/doc1", `{"foo": "bar"}`), http.StatusForbidden)
+	RequireStatus(t, rt.SendAdminRequest(http.MethodPost, "/{{.db}}/_user/", `{"name": "alice", "password": "letmein"}`), http.StatusForbidden)
+
+	// Clients can still replicate
+	client, err := NewBlipTesterClientOptsWithRT(t, rt, nil)
+	require.NoError(t, err)
+	defer client.Close()
+	version, err := client.PushRev("doc1", EmptyDocVersion(), []byte(`{"foo": "bar"}`))
+	require.NoError(t, err)
+	require.NoError(t, rt.WaitForVersion("doc1", version))
+}
+
+func TestSequenceReportLimit(t *testing.T) {
+	rt := NewRestTester(t, nil)
+	defer rt.Close()
+
+	// Limits too large to allocate for, or to convert to an int, are capped
+	for _, limit := range []string{"0", "1", "18446744073709551615"} {
+		response := rt.SendAdminRequest(http.MethodGet, "/{{.db}}/_sequence_report?limit="+limit, "")
+		RequireStatus(t, response, http.StatusOK)
+		var report db.SequenceReport
+		require.NoError(t, base.JSONUnmarshal(response.Body.Bytes(), &report))
+	}
+}
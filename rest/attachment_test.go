@@ -135,6 +135,50 @@ func TestDocAttachment(t *testing.T) {
 	RequireStatus(t, response, 404)
 }
 
+// TestAttachmentConditionalAndRangeRequests ensures attachment GET and HEAD requests support ranges and digest ETags.
+func TestAttachmentConditionalAndRangeRequests(t *testing.T) {
+	rt := NewRestTester(t, &RestTesterConfig{GuestEnabled: true})
+	defer rt.Close()
+
+	version := rt.PutDoc("doc", `{"prop":true}`)
+	attachmentBody := "this is the body of attachment"
+	_ = rt.storeAttachment("doc", version, "attach1", attachmentBody)
+
+	response := rt.SendRequest(http.MethodHead, "/{{.keyspace}}/doc/attach1", "")
+	RequireStatus(t, response, http.StatusOK)
+	assert.Empty(t, response.Body.String())
+	assert.Equal(t, "30", response.Header().Get("Content-Length"))
+	assert.Equal(t, "bytes", response.Header().Get("Accept-Ranges"))
+	etag := response.Header().Get("Etag")
+	require.NotEmpty(t, etag)
+
+	// The digest ETag allows a cached attachment to be revalidated
+	response = rt.SendRequestWithHeaders(http.MethodGet, "/{{.keyspace}}/doc/attach1", "", map[string]string{"If-None-Match": etag})
+	RequireStatus(t, response, http.StatusNotModified)
+	assert.Empty(t, response.Body.String())
+	response = rt.SendRequestWithHeaders(http.MethodGet, "/{{.keyspace}}/doc/attach1", "", map[string]string{"If-None-Match": `"sha1-abc"`})
+	RequireStatus(t, response, http.StatusOK)
+
+	// Ranges are served when If-Range matches the digest, otherwise the whole attachment is returned
+	response = rt.SendRequestWithHeaders(http.MethodGet, "/{{.keyspace}}/doc/attach1", "", map[string]string{"Range": "bytes=-10", "If-Range": etag})
+	RequireStatus(t, response, http.StatusPartialContent)
+	assert.Equal(t, "attachment", response.Body.String())
+	assert.Equal(t, "bytes 20-29/30", response.Header().Get("Content-Range"))
+	response = rt.SendRequestWithHeaders(http.MethodGet, "/{{.keyspace}}/doc/attach1", "", map[string]string{"Range": "bytes=-10", "If-Range": `"sha1-abc"`})
+	RequireStatus(t, response, http.StatusOK)
+	assert.Equal(t, attachmentBody, response.Body.String())
+
+	// HEAD with a range reports the length of the range
+	response = rt.SendRequestWithHeaders(http.MethodHead, "/{{.keyspace}}/doc/attach1", "", map[string]string{"Range": "bytes=0-3"})
+	RequireStatus(t, response, http.StatusPartialContent)
+	assert.Equal(t, "4", response.Header().Get("Content-Length"))
+
+	// Unsatisfiable ranges report the attachment length
+	response = rt.SendRequestWithHeaders(http.MethodGet, "/{{.keyspace}}/doc/attach1", "", map[string]string{"Range": "bytes=100-"})
+	RequireStatus(t, response, http.StatusRequestedRangeNotSatisfiable)
+	assert.Equal(t, "bytes */30", response.Header().Get("Content-Range"))
+}
+
 func TestDocAttachmentMetaOption(t *testing.T) {
 	rt := NewRestTester(t, &RestTesterConfig{GuestEnabled: true})
 	defer rt.Close()
@@ -2765,3 +2809,44 @@ func (rt *RestTester) storeAttachmentWithIfMatch(docID string, version DocVersio
 	require.True(rt.TB, body["ok"].(bool))
 	return DocVersionFromPutResponse(rt.TB, response)
 }
+
+// TestAttachmentPolicy ensures attachments that exceed the maximum size or have a disallowed content type are rejected.
+func TestAttachmentPolicy(t *testing.T) {
+	rt := NewRestTester(t, &RestTesterConfig{
+		DatabaseConfig: &DatabaseConfig{DbConfig: DbConfig{
+			AttachmentPolicy: &AttachmentPolicyConfig{
+				MaxSizeBytes:        base.Int64Ptr(10),
+				AllowedContentTypes: []string{"image/*", "text/plain"},
+				BlockedContentTypes: []string{"image/svg+xml"},
+			},
+		}},
+	})
+	defer rt.Close()
+
+	version := rt.PutDoc("doc", `{"prop":true}`)
+	dbStats := rt.GetDatabase().DbStats.Database()
+
+	// allowed content type, within size limit
+	response := rt.SendAdminRequestWithHeaders(http.MethodPut, "/{{.keyspace}}/doc/att1?rev="+version.RevID, "small", map[string]string{"Content-Type": "image/png"})
+	RequireStatus(t, response, http.StatusCreated)
+	version = DocVersionFromPutResponse(t, response)
+
+	// exceeds size limit
+	response = rt.SendAdminRequestWithHeaders(http.MethodPut, "/{{.keyspace}}/doc/att2?rev="+version.RevID, "this attachment is too large", map[string]string{"Content-Type": "text/plain"})
+	RequireStatus(t, response, http.StatusRequestEntityTooLarge)
+	assert.Equal(t, int64(1), dbStats.NumAttachmentsRejectedSize.Value())
+
+	// content type not allowed
+	response = rt.SendAdminRequestWithHeaders(http.MethodPut, "/{{.keyspace}}/doc/att2?rev="+version.RevID, "small", map[string]string{"Content-Type": "application/pdf"})
+	RequireStatus(t, response, http.StatusUnsupportedMediaType)
+
+	// content type blocked
+	response = rt.SendAdminRequestWithHeaders(http.MethodPut, "/{{.keyspace}}/doc/att2?rev="+version.RevID, "<svg/>", map[string]string{"Content-Type": "image/svg+xml"})
+	RequireStatus(t, response, http.StatusUnsupportedMediaType)
+	assert.Equal(t, int64(2), dbStats.NumAttachmentsRejectedContentType.Value())
+
+	// inline attachment is also subject to the policy
+	response = rt.SendAdminRequest(http.MethodPut, "/{{.keyspace}}/doc2", `{"_attachments": {"att.pdf": {"data": "aGVsbG8=", "content_type": "application/pdf"}}}`)
+	RequireStatus(t, response, http.StatusUnsupportedMediaType)
+	assert.Equal(t, int64(3), dbStats.NumAttachmentsRejectedContentType.Value())
+}
@@ -16,7 +16,9 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
+	"github.com/couchbase/go-blip"
 	"github.com/couchbase/gocb/v2"
 	sgbucket "github.com/couchbase/sg-bucket"
 	"github.com/couchbase/sync_gateway/base"
@@ -710,3 +712,79 @@ func TestAttachmentComputeStat(t *testing.T) {
 	require.Greater(t, btc.rt.GetDatabase().DbStats.DatabaseStats.SyncProcessCompute.Value(), syncProcessCompute)
 
 }
+
+// TestBlipDeferredAttachments tests that a client deferring attachments on subChanges can get a revision's attachments
+// with getAttachment after acknowledging the rev, until the deferred attachment window expires.
+func TestBlipDeferredAttachments(t *testing.T) {
+	rt := NewRestTester(t, &RestTesterConfig{
+		GuestEnabled: true,
+		DatabaseConfig: &DatabaseConfig{DbConfig: DbConfig{
+			DeferredAttachmentWindowSecs: base.Uint32Ptr(1),
+		}},
+	})
+	defer rt.Close()
+	bt, err := NewBlipTesterFromSpecWithRT(t, &BlipTesterSpec{GuestEnabled: true}, rt)
+	require.NoError(t, err)
+	defer bt.Close()
+
+	const docID = "doc1"
+	version := rt.PutDoc(docID, `{"_attachments":{"hello.txt":{"data":"aGVsbG8gd29ybGQ="}}}`)
+	const digest = "sha1-Kq5sNclPz7QV2+lfQIuc6R7oRu0="
+
+	revReceived := make(chan []byte, 1)
+	bt.blipContext.HandlerForProfile[db.MessageRev] = func(request *blip.Message) {
+		body, err := request.Body()
+		assert.NoError(t, err)
+		// Acknowledge the rev without getting its attachment
+		request.Response().SetBody([]byte{})
+		revReceived <- body
+	}
+	bt.blipContext.HandlerForProfile[db.MessageChanges] = func(request *blip.Message) {
+		body, err := request.Body()
+		require.NoError(t, err)
+		if string(body) == "null" {
+			return
+		}
+		request.Response().SetBody([]byte(`[[]]`))
+	}
+
+	subChangesRequest := bt.newRequest()
+	subChangesRequest.SetProfile(db.MessageSubChanges)
+	subChangesRequest.Properties[db.SubChangesContinuous] = "false"
+	subChangesRequest.Properties[db.SubChangesDeferAttachments] = "true"
+	require.True(t, bt.sender.Send(subChangesRequest))
+	require.Equal(t, "", subChangesRequest.Response().Properties[db.BlipErrorCode])
+
+	select {
+	case body := <-revReceived:
+		assert.Contains(t, string(body), `"stub":true`)
+		assert.NotContains(t, string(body), `"data"`)
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "Timed out waiting for rev of %s", version.RevID)
+	}
+
+	getAttachment := func() *blip.Message {
+		getAttachmentRequest := bt.newRequest()
+		getAttachmentRequest.SetProfile(db.MessageGetAttachment)
+		getAttachmentRequest.Properties[db.GetAttachmentDigest] = digest
+		getAttachmentRequest.Properties[db.GetAttachmentID] = docID
+		require.True(t, bt.sender.Send(getAttachmentRequest))
+		return getAttachmentRequest.Response()
+	}
+
+	// The attachment can be pulled on demand once the rev has been acknowledged
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		response := getAttachment()
+		if !assert.Equal(c, "", response.Properties[db.BlipErrorCode]) {
+			return
+		}
+		body, err := response.Body()
+		assert.NoError(c, err)
+		assert.Equal(c, "hello world", string(body))
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// But not once the window has expired
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.Equal(c, "403", getAttachment().Properties[db.BlipErrorCode])
+	}, 10*time.Second, 100*time.Millisecond)
+}
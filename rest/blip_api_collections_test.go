@@ -351,3 +351,92 @@ func TestBlipReplicationMultipleCollectionsMismatchedDocSizes(t *testing.T) {
 	}
 
 }
+
+// TestBlipPushMultipleCollectionsSyncFunctions ensures revs pushed to several collections over one replication are
+// each run through their own collection's sync function, and that reloading one collection's sync function is picked
+// up by the active replication without affecting the other collection.
+func TestBlipPushMultipleCollectionsSyncFunctions(t *testing.T) {
+	rt := NewRestTesterMultipleCollections(t, &RestTesterConfig{
+		GuestEnabled: true,
+	}, 2)
+	defer rt.Close()
+
+	ctx := base.TestCtx(t)
+	collections := rt.GetDbCollections()
+	require.Len(t, collections, 2)
+	for i, collection := range collections {
+		_, err := collection.UpdateSyncFun(ctx, fmt.Sprintf(`function(doc) { channel("collection%d"); }`, i))
+		require.NoError(t, err)
+	}
+
+	btc, err := NewBlipTesterClientOptsWithRT(t, rt, nil)
+	require.NoError(t, err)
+	defer btc.Close()
+	require.Len(t, btc.collectionClients, 2)
+
+	requireDocChannels := func(collection *db.DatabaseCollection, docID string, expectedChannel string) {
+		doc, err := collection.GetDocument(ctx, docID, db.DocUnmarshalSync)
+		require.NoError(t, err)
+		assert.Equal(t, []string{expectedChannel}, doc.Channels.KeySet())
+	}
+
+	// interleave pushes across both collections on the same connection
+	for i, collectionClient := range btc.collectionClients {
+		_, err := collectionClient.PushRev("doc1", EmptyDocVersion(), []byte(`{"foo":"bar"}`))
+		require.NoError(t, err)
+		requireDocChannels(collections[i], "doc1", fmt.Sprintf("collection%d", i))
+	}
+
+	// reload the sync function of only the first collection
+	_, err = collections[0].UpdateSyncFun(ctx, `function(doc) { channel("reloaded"); }`)
+	require.NoError(t, err)
+
+	for _, collectionClient := range btc.collectionClients {
+		_, err := collectionClient.PushRev("doc2", EmptyDocVersion(), []byte(`{"foo":"bar"}`))
+		require.NoError(t, err)
+	}
+	requireDocChannels(collections[0], "doc2", "reloaded")
+	requireDocChannels(collections[1], "doc2", "collection1")
+}
+
+func TestMigrateCheckpoints(t *testing.T) {
+	base.TestRequiresCollections(t)
+
+	rt := NewRestTester(t, &RestTesterConfig{
+		GuestEnabled: true,
+	})
+	defer rt.Close()
+
+	collection := rt.GetSingleTestDatabaseCollection()
+	require.False(t, collection.IsDefaultCollection())
+
+	// checkpoint written by a client that wasn't collection aware
+	legacyKey := db.RealSpecialDocID(db.DocTypeLocal, db.CheckpointDocIDPrefix+"legacyClient")
+	require.NoError(t, rt.Bucket().DefaultDataStore().SetRaw(legacyKey, 0, nil, []byte(`{"_rev":"0-3","seq":"123"}`)))
+
+	resp := rt.SendAdminRequest(http.MethodPost, "/{{.keyspace}}/_migrate_checkpoints", `{"checkpoints":{}}`)
+	RequireStatus(t, resp, http.StatusBadRequest)
+
+	resp = rt.SendAdminRequest(http.MethodPost, "/{{.keyspace}}/_migrate_checkpoints", `{"checkpoints":{"legacyClient":"collectionClient","unknownClient":""}}`)
+	RequireStatus(t, resp, http.StatusOK)
+	assert.JSONEq(t, `{"results":{"legacyClient":"migrated","unknownClient":"missing"}}`, resp.Body.String())
+
+	// revision is preserved, so the client can continue to update the checkpoint
+	checkpoint, err := collection.GetSpecial(db.DocTypeLocal, db.CheckpointDocIDPrefix+"collectionClient")
+	require.NoError(t, err)
+	assert.Equal(t, db.Body{db.BodyRev: "0-3", "seq": "123"}, checkpoint)
+
+	// an existing checkpoint is only replaced when requested
+	_, err = collection.PutSpecial(db.DocTypeLocal, db.CheckpointDocIDPrefix+"collectionClient", db.Body{db.BodyRev: "0-3", "seq": "456"})
+	require.NoError(t, err)
+	resp = rt.SendAdminRequest(http.MethodPost, "/{{.keyspace}}/_migrate_checkpoints", `{"checkpoints":{"legacyClient":"collectionClient"}}`)
+	RequireStatus(t, resp, http.StatusOK)
+	assert.JSONEq(t, `{"results":{"legacyClient":"exists"}}`, resp.Body.String())
+
+	resp = rt.SendAdminRequest(http.MethodPost, "/{{.keyspace}}/_migrate_checkpoints", `{"checkpoints":{"legacyClient":"collectionClient"},"overwrite":true}`)
+	RequireStatus(t, resp, http.StatusOK)
+	assert.JSONEq(t, `{"results":{"legacyClient":"migrated"}}`, resp.Body.String())
+	checkpoint, err = collection.GetSpecial(db.DocTypeLocal, db.CheckpointDocIDPrefix+"collectionClient")
+	require.NoError(t, err)
+	assert.Equal(t, "123", checkpoint["seq"])
+}
@@ -17,6 +17,7 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -1903,6 +1904,134 @@ func TestActiveOnlyContinuous(t *testing.T) {
 	assert.Equal(t, `{}`, string(rev))
 }
 
+// changesDeletedFlags returns the deleted flags of each changes row sent to the client for docID, in the order sent.
+// A row without deleted flags is returned as 0.
+func changesDeletedFlags(t *testing.T, btc *BlipTesterClient, docID string) []int64 {
+	messages := btc.pullReplication.GetMessages()
+	serialNumbers := make([]blip.MessageNumber, 0, len(messages))
+	for serialNumber, msg := range messages {
+		if msg.Properties[db.BlipProfile] == db.MessageChanges {
+			serialNumbers = append(serialNumbers, serialNumber)
+		}
+	}
+	sort.Slice(serialNumbers, func(i, j int) bool { return serialNumbers[i] < serialNumbers[j] })
+
+	var flags []int64
+	for _, serialNumber := range serialNumbers {
+		msg := messages[serialNumber]
+		var rows [][]interface{}
+		require.NoError(t, msg.ReadJSONBody(&rows))
+		for _, row := range rows {
+			if row[1] != docID {
+				continue
+			}
+			var deleted int64
+			if len(row) > 3 {
+				number, ok := row[3].(json.Number)
+				require.True(t, ok)
+				var err error
+				deleted, err = number.Int64()
+				require.NoError(t, err)
+			}
+			flags = append(flags, deleted)
+		}
+	}
+	return flags
+}
+
+// Tombstones, removals and revocations are skipped while an activeOnly replication catches up, and sent once it has.
+func TestActiveOnlyContinuousRevocations(t *testing.T) {
+	base.SetUpTestLogging(t, base.LevelDebug, base.KeyAll)
+
+	revocationTester, rt := InitScenario(t, nil)
+	defer rt.Close()
+
+	btc, err := NewBlipTesterClientOptsWithRT(t, rt, &BlipTesterClientOpts{
+		Username:        "user",
+		Channels:        []string{"*"},
+		SendRevocations: true,
+	})
+	require.NoError(t, err)
+	defer btc.Close()
+
+	revocationTester.addRoleChannel("foo", "A")
+	revocationTester.addRole("user", "foo")
+
+	tombstoneVersion := rt.PutDoc("docTombstone", `{"channels": "A"}`)
+	rt.DeleteDoc("docTombstone", tombstoneVersion)
+	removedVersion := rt.PutDoc("docRemoved", `{"channels": "A"}`)
+	_ = rt.UpdateDoc("docRemoved", removedVersion, `{"channels": "B"}`)
+	deletedVersion := rt.PutDoc("docDeleted", `{"channels": "A"}`)
+	activeVersion := rt.PutDoc("docActive", `{"channels": "A"}`)
+	require.NoError(t, rt.WaitForPendingChanges())
+
+	require.NoError(t, btc.StartPullSince("true", "0", "true"))
+	_, found := btc.WaitForVersion("docActive", activeVersion)
+	require.True(t, found)
+
+	// The initial replication is sent in sequence order, so it's complete once docActive has been received
+	assert.Empty(t, changesDeletedFlags(t, btc, "docTombstone"))
+	assert.Empty(t, changesDeletedFlags(t, btc, "docRemoved"))
+	assert.Equal(t, []int64{0}, changesDeletedFlags(t, btc, "docDeleted"))
+
+	// Once caught up, tombstones are sent
+	deletedVersion = rt.DeleteDocReturnVersion("docDeleted", deletedVersion)
+	_, found = btc.WaitForVersion("docDeleted", deletedVersion)
+	require.True(t, found)
+	// The tombstone has no channels, so is sent as both deleted (1) and removed (4)
+	assert.Equal(t, []int64{0, 5}, changesDeletedFlags(t, btc, "docDeleted"))
+
+	// As are revocations
+	revocationTester.removeRole("user", "foo")
+	require.NoError(t, rt.WaitForCondition(func() bool {
+		return len(changesDeletedFlags(t, btc, "docActive")) == 2
+	}))
+	assert.Equal(t, []int64{0, 2}, changesDeletedFlags(t, btc, "docActive"))
+}
+
+// Channels revoked between a client's last replication and an activeOnly replication resuming from it are revoked
+// once the activeOnly replication has caught up.
+func TestActiveOnlyContinuousRevocationsSince(t *testing.T) {
+	base.SetUpTestLogging(t, base.LevelDebug, base.KeyAll)
+
+	revocationTester, rt := InitScenario(t, nil)
+	defer rt.Close()
+
+	btc, err := NewBlipTesterClientOptsWithRT(t, rt, &BlipTesterClientOpts{
+		Username:        "user",
+		Channels:        []string{"*"},
+		SendRevocations: true,
+	})
+	require.NoError(t, err)
+	defer btc.Close()
+
+	revocationTester.addRoleChannel("foo", "A")
+	revocationTester.addRole("user", "foo")
+
+	revokedVersion := rt.PutDoc("docRevoked", `{"channels": "A"}`)
+	require.NoError(t, rt.WaitForPendingChanges())
+
+	require.NoError(t, btc.StartOneshotPull())
+	_, found := btc.WaitForVersion("docRevoked", revokedVersion)
+	require.True(t, found)
+	since, err := rt.GetDatabase().LastSequence(rt.Context())
+	require.NoError(t, err)
+
+	revocationTester.removeRole("user", "foo")
+	revocationTester.addUserChannel("user", "B")
+	activeVersion := rt.PutDoc("docActive", `{"channels": "B"}`)
+	require.NoError(t, rt.WaitForPendingChanges())
+
+	require.NoError(t, btc.StartPullSince("true", strconv.FormatUint(since, 10), "true"))
+	_, found = btc.WaitForVersion("docActive", activeVersion)
+	require.True(t, found)
+
+	require.NoError(t, rt.WaitForCondition(func() bool {
+		return len(changesDeletedFlags(t, btc, "docRevoked")) == 2
+	}))
+	assert.Equal(t, []int64{0, 2}, changesDeletedFlags(t, btc, "docRevoked"))
+}
+
 // Test that exercises Sync Gateway's norev handler
 func TestBlipNorev(t *testing.T) {
 
@@ -2844,3 +2973,282 @@ func TestBlipRefreshUser(t *testing.T) {
 	require.NoError(t, err)
 	require.NotContains(t, string(body), "Panic:")
 }
+
+// TestBlipDrain ensures that draining the server context sends a goAway message to connected replication
+// clients and rejects new replications.
+func TestBlipDrain(t *testing.T) {
+
+	base.SetUpTestLogging(t, base.LevelInfo, base.KeyHTTP, base.KeySync, base.KeySyncMsg)
+
+	bt, err := NewBlipTester(t)
+	require.NoError(t, err)
+	defer bt.Close()
+
+	goAwayReceived := make(chan string, 1)
+	bt.blipContext.HandlerForProfile[db.MessageGoAway] = func(request *blip.Message) {
+		goAwayReceived <- request.Properties[db.GoAwayReconnectAfter]
+	}
+
+	// Send a request so that Sync Gateway has a sender for the connection
+	sent, _, resp, err := bt.SetCheckpoint("testclient", "", []byte(`{"client_seq":1}`))
+	require.True(t, sent)
+	require.NoError(t, err)
+	require.Equal(t, "", resp.Properties[db.BlipErrorCode])
+
+	sc := bt.restTester.ServerContext()
+	sc.Config.Replicator.DrainTimeout = base.NewConfigDuration(5 * time.Second)
+	sc.Config.Replicator.DrainReconnectAfter = base.NewConfigDuration(7 * time.Second)
+	sc.Drain(base.TestCtx(t))
+	assert.True(t, sc.IsDraining())
+
+	select {
+	case reconnectAfter := <-goAwayReceived:
+		assert.Equal(t, "7", reconnectAfter)
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "Didn't receive goAway message")
+	}
+
+	// New replications are rejected while draining
+	response := bt.restTester.SendAdminRequest(http.MethodGet, "/"+bt.restTester.GetDatabase().Name+"/_blipsync", "")
+	RequireStatus(t, response, http.StatusServiceUnavailable)
+	assert.Equal(t, "7", response.Header().Get("Retry-After"))
+}
+
+// TestBlipSubChangesStyleAllDocs ensures subChanges with style=all_docs sends a changes row for each leaf revision of a
+// conflicted document, winning revision first, and is rejected for clients using protocol V2.
+func TestBlipSubChangesStyleAllDocs(t *testing.T) {
+	rt := NewRestTester(t, &RestTesterConfig{
+		GuestEnabled:   true,
+		DatabaseConfig: &DatabaseConfig{DbConfig: DbConfig{AllowConflicts: base.BoolPtr(true)}},
+	})
+	defer rt.Close()
+
+	// Create a conflicted document, 2-b wins over 2-a
+	resp := rt.SendAdminRequest(http.MethodPut, "/{{.keyspace}}/doc?new_edits=false", `{"_revisions":{"start":2,"ids":["b","a"]}}`)
+	RequireStatus(t, resp, http.StatusCreated)
+	resp = rt.SendAdminRequest(http.MethodPut, "/{{.keyspace}}/doc?new_edits=false", `{"_revisions":{"start":2,"ids":["a","a"]}}`)
+	RequireStatus(t, resp, http.StatusCreated)
+	require.NoError(t, rt.WaitForPendingChanges())
+
+	// getChanges runs a one-shot subChanges, returning the changes rows or the error code if subChanges was rejected
+	getChanges := func(t *testing.T, protocol string, style string) (changes [][]interface{}, errorCode string) {
+		bt, err := NewBlipTesterFromSpecWithRT(t, &BlipTesterSpec{blipProtocols: []string{protocol}}, rt)
+		require.NoError(t, err)
+		defer bt.Close()
+
+		changesBodies := make(chan []byte, 10)
+		bt.blipContext.HandlerForProfile[db.MessageChanges] = func(request *blip.Message) {
+			body, err := request.Body()
+			assert.NoError(t, err)
+			changesBodies <- body
+			if !request.NoReply() {
+				request.Response().SetBody([]byte(`[]`))
+			}
+		}
+
+		subChangesRequest := bt.newRequest()
+		subChangesRequest.SetProfile(db.MessageSubChanges)
+		subChangesRequest.Properties[db.SubChangesContinuous] = "false"
+		if style != "" {
+			subChangesRequest.Properties[db.SubChangesStyle] = style
+		}
+		require.True(t, bt.sender.Send(subChangesRequest))
+		if errorCode := subChangesRequest.Response().Properties[db.BlipErrorCode]; errorCode != "" {
+			return nil, errorCode
+		}
+
+		for {
+			select {
+			case body := <-changesBodies:
+				// A null changes body indicates the client has caught up
+				if string(body) == "null" {
+					return changes, ""
+				}
+				var batch [][]interface{}
+				require.NoError(t, base.JSONUnmarshal(body, &batch))
+				changes = append(changes, batch...)
+			case <-time.After(10 * time.Second):
+				require.FailNow(t, "Timed out waiting for changes")
+			}
+		}
+	}
+
+	t.Run("winning rev only", func(t *testing.T) {
+		changes, errorCode := getChanges(t, db.BlipCBMobileReplicationV3, "")
+		require.Empty(t, errorCode)
+		require.Len(t, changes, 1)
+		assert.Equal(t, "doc", changes[0][1])
+		assert.Equal(t, "2-b", changes[0][2])
+	})
+
+	t.Run("all_docs", func(t *testing.T) {
+		changes, errorCode := getChanges(t, db.BlipCBMobileReplicationV3, db.SubChangesStyleAllDocs)
+		require.Empty(t, errorCode)
+		require.Len(t, changes, 2)
+		assert.Equal(t, "doc", changes[0][1])
+		assert.Equal(t, "2-b", changes[0][2])
+		assert.Equal(t, "doc", changes[1][1])
+		assert.Equal(t, "2-a", changes[1][2])
+	})
+
+	t.Run("all_docs protocol V2", func(t *testing.T) {
+		_, errorCode := getChanges(t, db.BlipCBMobileReplicationV2, db.SubChangesStyleAllDocs)
+		assert.Equal(t, strconv.Itoa(http.StatusBadRequest), errorCode)
+	})
+}
+
+// TestBlipPullRevMessageMaxHistory tests that the history sent in rev messages is capped by max_rev_message_history.
+func TestBlipPullRevMessageMaxHistory(t *testing.T) {
+	rtConfig := RestTesterConfig{
+		DatabaseConfig: &DatabaseConfig{DbConfig: DbConfig{
+			MaxRevMessageHistory: base.Uint32Ptr(2),
+		}},
+		GuestEnabled: true,
+	}
+	rt := NewRestTester(t, &rtConfig)
+	defer rt.Close()
+
+	// create doc1 with 4 revisions before the client replicates
+	version := rt.PutDoc("doc1", `{"rev":1}`)
+	for i := 2; i <= 4; i++ {
+		version = rt.UpdateDoc("doc1", version, fmt.Sprintf(`{"rev":%d}`, i))
+	}
+	require.NoError(t, rt.WaitForPendingChanges())
+
+	client, err := NewBlipTesterClientOptsWithRT(t, rt, nil)
+	require.NoError(t, err)
+	defer client.Close()
+
+	require.NoError(t, client.StartOneshotPull())
+	msg, ok := client.WaitForBlipRevMessage("doc1", version.RevID)
+	require.True(t, ok)
+	history := strings.Split(msg.Properties[db.RevMessageHistory], ",")
+	require.Len(t, history, 2)
+	assert.True(t, strings.HasPrefix(history[0], "3-"))
+	assert.True(t, strings.HasPrefix(history[1], "2-"))
+}
+
+// TestBlipPullIdsOnly tests that a client replicating ids only is sent changes but no revisions, and gets the revisions
+// it wants with getRev.
+func TestBlipPullIdsOnly(t *testing.T) {
+	bt, err := NewBlipTester(t)
+	require.NoError(t, err)
+	defer bt.Close()
+
+	sent, _, resp, err := bt.SendRev("doc1", "1-abc", []byte(`{"key": "val1"}`), blip.Properties{})
+	require.True(t, sent)
+	require.NoError(t, err)
+	require.Equal(t, "", resp.Properties[db.BlipErrorCode])
+	sent, _, resp, err = bt.SendRevWithHistory("doc1", "2-bcd", []string{"1-abc"}, []byte(`{"key": "val2"}`), blip.Properties{})
+	require.True(t, sent)
+	require.NoError(t, err)
+	require.Equal(t, "", resp.Properties[db.BlipErrorCode])
+	require.NoError(t, bt.restTester.WaitForPendingChanges())
+
+	var revsReceived int32
+	bt.blipContext.HandlerForProfile[db.MessageRev] = func(request *blip.Message) {
+		atomic.AddInt32(&revsReceived, 1)
+	}
+
+	changesFinished := make(chan struct{})
+	var changes [][]interface{}
+	bt.blipContext.HandlerForProfile[db.MessageChanges] = func(request *blip.Message) {
+		assert.Equal(t, "true", request.Properties[db.ChangesMessageIdsOnly])
+		body, err := request.Body()
+		require.NoError(t, err)
+		if string(body) == "null" {
+			close(changesFinished)
+			return
+		}
+		var batch [][]interface{}
+		require.NoError(t, base.JSONUnmarshal(body, &batch))
+		changes = append(changes, batch...)
+
+		// Ask for every revision, as a client that isn't replicating ids only would
+		answer := make([][]interface{}, len(batch))
+		for i := range answer {
+			answer[i] = []interface{}{}
+		}
+		answerBytes, err := base.JSONMarshal(answer)
+		require.NoError(t, err)
+		request.Response().SetBody(answerBytes)
+	}
+
+	subChangesRequest := bt.newRequest()
+	subChangesRequest.SetProfile(db.MessageSubChanges)
+	subChangesRequest.Properties[db.SubChangesContinuous] = "false"
+	subChangesRequest.Properties[db.SubChangesIdsOnly] = "true"
+	require.True(t, bt.sender.Send(subChangesRequest))
+	require.Equal(t, "", subChangesRequest.Response().Properties[db.BlipErrorCode])
+
+	select {
+	case <-changesFinished:
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "Timed out waiting for changes")
+	}
+	require.Len(t, changes, 1)
+	assert.Equal(t, "doc1", changes[0][1])
+	assert.Equal(t, "2-bcd", changes[0][2])
+
+	// The client gets the revision with getRev
+	getRevRequest := bt.newRequest()
+	getRevRequest.SetProfile(db.MessageGetRev)
+	getRevRequest.Properties[db.GetRevMessageId] = "doc1"
+	getRevRequest.Properties[db.GetRevRevId] = "2-bcd"
+	require.True(t, bt.sender.Send(getRevRequest))
+	getRevResponse := getRevRequest.Response()
+	require.Equal(t, "", getRevResponse.Properties[db.BlipErrorCode])
+	assert.Equal(t, "2-bcd", getRevResponse.Properties[db.GetRevRevId])
+	body, err := getRevResponse.Body()
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"key":"val2"`)
+
+	getRevRequest = bt.newRequest()
+	getRevRequest.SetProfile(db.MessageGetRev)
+	getRevRequest.Properties[db.GetRevMessageId] = "doc1"
+	getRevRequest.Properties[db.GetRevRevId] = "3-cde"
+	require.True(t, bt.sender.Send(getRevRequest))
+	assert.Equal(t, "404", getRevRequest.Response().Properties[db.BlipErrorCode])
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&revsReceived))
+}
+
+// TestBlipPausedDatabase ensures that replications stay connected while the database is paused, with pushes rejected
+// and no changes sent, and continue once the database is resumed.
+func TestBlipPausedDatabase(t *testing.T) {
+	rt := NewRestTester(t, &RestTesterConfig{GuestEnabled: true})
+	defer rt.Close()
+	btc, err := NewBlipTesterClientOptsWithRT(t, rt, nil)
+	require.NoError(t, err)
+	defer btc.Close()
+	require.NoError(t, btc.StartPull())
+
+	version1 := rt.PutDoc("doc1", `{"foo": "bar"}`)
+	_, found := btc.WaitForVersion("doc1", version1)
+	require.True(t, found)
+
+	RequireStatus(t, rt.SendAdminRequest(http.MethodPost, "/{{.db}}/_offline?pause=true", ""), http.StatusOK)
+	require.Equal(t, db.DBPaused, atomic.LoadUint32(&rt.GetDatabase().State))
+	RequireStatus(t, rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/doc1", ""), http.StatusServiceUnavailable)
+
+	// Pushes are rejected with a retryable error
+	_, err = btc.PushRev("doc2", EmptyDocVersion(), []byte(`{"foo": "bar"}`))
+	require.ErrorContains(t, err, strconv.Itoa(http.StatusTooManyRequests))
+
+	// Changes made while paused aren't sent until the database is resumed
+	collection := rt.GetSingleTestDatabaseCollectionWithUser()
+	rev3, _, err := collection.Put(base.TestCtx(t), "doc3", db.Body{"foo": "bar"})
+	require.NoError(t, err)
+	require.NoError(t, rt.WaitForPendingChanges())
+	assert.Never(t, func() bool {
+		_, found := btc.GetRev("doc3", rev3)
+		return found
+	}, 500*time.Millisecond, 50*time.Millisecond)
+
+	RequireStatus(t, rt.SendAdminRequest(http.MethodPost, "/{{.db}}/_online", ""), http.StatusOK)
+	require.NoError(t, rt.WaitForDBOnline())
+	_, found = btc.WaitForRev("doc3", rev3)
+	require.True(t, found)
+	_, err = btc.PushRev("doc2", EmptyDocVersion(), []byte(`{"foo": "bar"}`))
+	require.NoError(t, err)
+}
@@ -15,6 +15,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -566,6 +567,8 @@ func getCollectionsForBLIP(_ testing.TB, rt *RestTester) []string {
 		collections = append(collections,
 			strings.Join([]string{collection.ScopeName, collection.Name}, base.ScopeCollectionSeparator))
 	}
+	// Match the order of the collections in the GetCollections message
+	sort.Strings(collections)
 	return collections
 }
 
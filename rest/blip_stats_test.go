@@ -287,3 +287,113 @@ func TestComputeStatAfterContextTeardown(t *testing.T) {
 	require.Greater(t, activeRT.GetDatabase().DbStats.DatabaseStats.SyncProcessCompute.Value(), syncProcessComputeActive)
 
 }
+
+// TestBlipClientCapabilities ensures the capabilities negotiated and messages exchanged by a connection are listed by
+// _connected_clients and counted in the database stats.
+func TestBlipClientCapabilities(t *testing.T) {
+	rt := NewRestTester(t, &RestTesterConfig{GuestEnabled: true})
+	defer rt.Close()
+
+	bt, err := NewBlipTesterFromSpecWithRT(t, nil, rt)
+	require.NoError(t, err)
+
+	rq := bt.newRequest()
+	rq.SetProfile(db.MessageGetCheckpoint)
+	rq.Properties[db.BlipClientApp] = "TestApp/1.0"
+	require.True(t, bt.sender.Send(rq))
+	require.Equal(t, "404", rq.Response().Properties[db.BlipErrorCode])
+
+	response := rt.SendAdminRequest(http.MethodGet, "/{{.db}}/_connected_clients", "")
+	RequireStatus(t, response, http.StatusOK)
+	var clients ConnectedClientsResponse
+	require.NoError(t, base.JSONUnmarshal(response.BodyBytes(), &clients))
+	require.Len(t, clients.Connections, 1)
+	connection := clients.Connections[0]
+	require.Equal(t, db.BlipCBMobileReplicationV3, connection.ProtocolVersion)
+	require.Equal(t, string(db.BLIPClientTypeCBL2), connection.ClientType)
+	require.Equal(t, "TestApp/1.0", connection.ClientApp)
+	require.Equal(t, bt.useCollections, connection.CollectionsAware)
+	require.False(t, connection.Deltas)
+	require.Equal(t, int64(1), connection.Usage.MessagesReceived[db.MessageGetCheckpoint].Count)
+	require.Equal(t, int64(1), connection.Usage.MessagesSent[db.MessageGetCheckpoint].Count)
+	require.Greater(t, connection.Usage.BytesReceived, uint64(0))
+	require.Greater(t, connection.Usage.BytesSent, uint64(0))
+	messageUsage := rt.GetDatabase().DbStats.CBLReplicationMessages().Usage()
+	require.Equal(t, int64(1), messageUsage[base.BLIPMessageReceived][db.MessageGetCheckpoint].Count)
+
+	dbStats := rt.GetDatabase().DbStats.Database()
+	require.Equal(t, int64(1), dbStats.NumReplicationsActiveProtocolV3.Value())
+	require.Equal(t, int64(0), dbStats.NumReplicationsActiveProtocolV2.Value())
+	if bt.useCollections {
+		require.Equal(t, int64(1), dbStats.NumReplicationsActiveCollectionsAware.Value())
+	}
+
+	// Stats are released once the connection is closed
+	bt.Close()
+	base.RequireWaitForStat(t, dbStats.NumReplicationsActiveProtocolV3.Value, 0)
+	base.RequireWaitForStat(t, dbStats.NumReplicationsActiveCollectionsAware.Value, 0)
+
+	response = rt.SendAdminRequest(http.MethodGet, "/{{.db}}/_connected_clients", "")
+	RequireStatus(t, response, http.StatusOK)
+	require.NoError(t, base.JSONUnmarshal(response.BodyBytes(), &clients))
+	require.Len(t, clients.Connections, 0)
+}
+
+// TestBlipPendingInsertions ensures documents proposed as new but not yet pushed are listed by _connected_clients and
+// counted in the database stats.
+func TestBlipPendingInsertions(t *testing.T) {
+	rt := NewRestTester(t, &RestTesterConfig{GuestEnabled: true})
+	defer rt.Close()
+
+	bt, err := NewBlipTesterFromSpecWithRT(t, &BlipTesterSpec{noConflictsMode: true}, rt)
+	require.NoError(t, err)
+
+	proposeChanges := func(docIDs ...string) {
+		changes := make([][]string, 0, len(docIDs))
+		for _, docID := range docIDs {
+			changes = append(changes, []string{docID, "1-abc"})
+		}
+		body, err := base.JSONMarshal(changes)
+		require.NoError(t, err)
+		rq := bt.newRequest()
+		rq.SetProfile(db.MessageProposeChanges)
+		rq.SetBody(body)
+		require.True(t, bt.sender.Send(rq))
+		_, err = rq.Response().Body()
+		require.NoError(t, err)
+	}
+	getPendingInsertions := func() db.BlipPendingInsertions {
+		response := rt.SendAdminRequest(http.MethodGet, "/{{.db}}/_connected_clients", "")
+		RequireStatus(t, response, http.StatusOK)
+		var clients ConnectedClientsResponse
+		require.NoError(t, base.JSONUnmarshal(response.BodyBytes(), &clients))
+		require.Len(t, clients.Connections, 1)
+		return clients.Connections[0].PendingInsertions
+	}
+	pushStats := rt.GetDatabase().DbStats.CBLReplicationPush()
+
+	proposeChanges("doc1", "doc2")
+	require.Equal(t, db.BlipPendingInsertions{Count: 2}, getPendingInsertions())
+	require.Equal(t, int64(2), pushStats.PendingInsertions.Value())
+
+	// Pushing a proposed document removes it from the pending insertions
+	sent, _, _, err := bt.SendRev("doc1", "1-abc", []byte(`{"key":"val"}`), nil)
+	require.True(t, sent)
+	require.NoError(t, err)
+	require.Equal(t, db.BlipPendingInsertions{Count: 1}, getPendingInsertions())
+	require.Equal(t, int64(1), pushStats.PendingInsertions.Value())
+
+	// Documents proposed beyond the maximum pending aren't tracked, but are counted
+	docIDs := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		docIDs = append(docIDs, fmt.Sprintf("overflow%d", i))
+	}
+	proposeChanges(docIDs...)
+	require.Equal(t, db.BlipPendingInsertions{Count: 1000, Overflows: 1}, getPendingInsertions())
+	require.Equal(t, int64(1000), pushStats.PendingInsertions.Value())
+	require.Equal(t, int64(1), pushStats.PendingInsertionsOverflowCount.Value())
+
+	// Pending insertions are released once the connection is closed
+	bt.Close()
+	base.RequireWaitForStat(t, pushStats.PendingInsertions.Value, 0)
+}
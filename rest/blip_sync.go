@@ -14,6 +14,9 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/couchbase/sync_gateway/db"
 
@@ -23,6 +26,11 @@ import (
 
 // HTTP handler for incoming BLIP sync WebSocket request (/db/_blipsync)
 func (h *handler) handleBLIPSync() error {
+	if h.server.IsDraining() {
+		h.setHeader("Retry-After", strconv.FormatInt(int64(h.server.Config.Replicator.DrainReconnectAfter.Value().Seconds()), 10))
+		return base.HTTPErrorf(http.StatusServiceUnavailable, "Sync Gateway is shutting down")
+	}
+
 	needRelease, err := h.server.incrementConcurrentReplications(h.rqCtx)
 	if err != nil {
 		h.db.DbStats.Database().NumReplicationsRejectedLimit.Add(1)
@@ -53,18 +61,30 @@ func (h *handler) handleBLIPSync() error {
 		return err
 	}
 
-	// Overwrite the existing logging context with the blip context ID
-	h.rqCtx = base.CorrelationIDLogCtx(h.ctx(), base.FormatBlipContextID(blipContext.ID))
+	// Overwrite the existing logging context with the blip context ID, unless the client supplied its own correlation ID
+	correlationID := h.clientCorrelationID()
+	if correlationID == "" {
+		correlationID = base.FormatBlipContextID(blipContext.ID)
+	}
+	h.rqCtx = base.CorrelationIDLogCtx(h.ctx(), correlationID)
+	h.setHeader(correlationIDHeader, correlationID)
 
 	// Create a new BlipSyncContext attached to the given blipContext.
 	ctx := db.NewBlipSyncContext(h.rqCtx, blipContext, h.db, h.formatSerialNumber(), db.BlipSyncStatsForCBL(h.db.DbStats))
 	defer ctx.Close()
+	ctx.SetCorrelationID(correlationID)
+
+	if !h.server.blipSyncContexts.add(ctx) {
+		return base.HTTPErrorf(http.StatusServiceUnavailable, "Sync Gateway is shutting down")
+	}
+	defer h.server.blipSyncContexts.remove(ctx)
 
 	if string(db.BLIPClientTypeSGR2) == h.getQuery(db.BLIPSyncClientTypeQueryParam) {
 		ctx.SetClientType(db.BLIPClientTypeSGR2)
 	} else {
 		ctx.SetClientType(db.BLIPClientTypeCBL2)
 	}
+	ctx.TrackClientCapabilities(h.db.DbStats.Database())
 
 	// Create a BLIP WebSocket handler and have it handle the request:
 	server := blipContext.WebSocketServer()
@@ -83,6 +103,102 @@ func (h *handler) handleBLIPSync() error {
 	return nil
 }
 
+// ConnectedClientsResponse is the response body of GET /{db}/_connected_clients.
+type ConnectedClientsResponse struct {
+	Connections []ConnectedClient `json:"connections"`
+}
+
+// ConnectedClient describes a replication connection's negotiated capabilities, its network usage, and the documents
+// its client has proposed but not yet pushed.
+type ConnectedClient struct {
+	db.BlipClientCapabilities
+	Usage             db.BlipConnectionUsage   `json:"usage"`
+	PendingInsertions db.BlipPendingInsertions `json:"pending_insertions"`
+}
+
+// HTTP handler for GET /{db}/_connected_clients, listing the capabilities negotiated by each replication connection
+// open for the database on this node and its network usage, oldest first.
+func (h *handler) handleGetConnectedClients() error {
+	contexts := h.server.blipSyncContexts.forDatabase(h.db.DatabaseContext)
+	response := ConnectedClientsResponse{Connections: make([]ConnectedClient, 0, len(contexts))}
+	for _, bsc := range contexts {
+		response.Connections = append(response.Connections, ConnectedClient{
+			BlipClientCapabilities: bsc.ClientCapabilities(),
+			Usage:                  bsc.ConnectionUsage(),
+			PendingInsertions:      bsc.PendingInsertions(),
+		})
+	}
+	sort.Slice(response.Connections, func(i, j int) bool {
+		return response.Connections[i].ConnectedAt.Before(response.Connections[j].ConnectedAt)
+	})
+	h.writeJSON(response)
+	return nil
+}
+
+// ReplicationErrorsResponse is the response body of GET /{db}/_replication_errors.
+type ReplicationErrorsResponse struct {
+	Counts     map[base.BLIPErrorDirection]map[string]map[string]int64 `json:"counts"`
+	TopReasons []base.BLIPErrorReason                                  `json:"top_reasons"`
+}
+
+// HTTP handler for GET /{db}/_replication_errors, reporting the BLIP error responses sent and received by the
+// database's replications on this node, by message profile and status class, with the most frequent error reasons.
+func (h *handler) handleGetReplicationErrors() error {
+	limit := int(h.getIntQuery("limit", 10))
+	errorStats := h.db.DbStats.CBLReplicationErrors()
+	h.writeJSON(ReplicationErrorsResponse{
+		Counts:     errorStats.Counts(),
+		TopReasons: errorStats.TopReasons(limit),
+	})
+	return nil
+}
+
+// FlightRecorderRequest is the request body of POST /{db}/_flight_recorder.
+type FlightRecorderRequest struct {
+	User         string                  `json:"user,omitempty"`          // Captures messages on the user's replications
+	DocID        string                  `json:"doc_id,omitempty"`        // Captures messages about the document, and the responses to them
+	DurationMins uint32                  `json:"duration_mins,omitempty"` // How long to capture messages for. Defaults to 10 minutes
+	Bodies       db.FlightRecorderBodies `json:"bodies,omitempty"`        // "redacted" or "full" to capture message bodies
+	MaxRecords   int                     `json:"max_records,omitempty"`   // The number of most recent messages kept. Defaults to 1000
+}
+
+// HTTP handler for POST /{db}/_flight_recorder, starting a capture of the BLIP messages of a specific user or document
+// on this node, replacing any capture already running.
+func (h *handler) handlePostFlightRecorder() error {
+	var body FlightRecorderRequest
+	if err := h.readJSONInto(&body); err != nil {
+		return err
+	}
+	err := h.db.FlightRecorder.Start(db.FlightRecorderOptions{
+		User:       body.User,
+		DocID:      body.DocID,
+		Duration:   time.Duration(body.DurationMins) * time.Minute,
+		Bodies:     body.Bodies,
+		MaxRecords: body.MaxRecords,
+	})
+	if err != nil {
+		return base.HTTPErrorf(http.StatusBadRequest, "%s", err)
+	}
+	base.InfofCtx(h.ctx(), base.KeySyncMsg, "Flight recorder started for user %s doc %s by %s", base.UD(body.User), base.UD(body.DocID), h.taggedEffectiveUserName())
+	status := h.db.FlightRecorder.Status()
+	status.Records = nil
+	h.writeJSON(status)
+	return nil
+}
+
+// HTTP handler for GET /{db}/_flight_recorder, returning the state of the capture and the messages captured so far.
+func (h *handler) handleGetFlightRecorder() error {
+	h.writeJSON(h.db.FlightRecorder.Status())
+	return nil
+}
+
+// HTTP handler for DELETE /{db}/_flight_recorder, stopping the capture and discarding the messages captured.
+func (h *handler) handleDeleteFlightRecorder() error {
+	h.db.FlightRecorder.Stop()
+	base.InfofCtx(h.ctx(), base.KeySyncMsg, "Flight recorder stopped by %s", h.taggedEffectiveUserName())
+	return nil
+}
+
 // incrementConcurrentReplications increments the number of active replications (if there is capacity to do so)
 // and rejects calls if no capacity is available
 func (sc *ServerContext) incrementConcurrentReplications(ctx context.Context) (bool, error) {
@@ -28,6 +28,10 @@ import (
 // HTTP handler for _all_docs
 func (h *handler) handleAllDocs() error {
 	// http://wiki.apache.org/couchdb/HTTP_Bulk_Document_API
+	if err := h.waitForConsistencyToken(); err != nil {
+		return err
+	}
+
 	includeDocs := h.getBoolQuery("include_docs")
 	includeChannels := h.getBoolQuery("channels")
 	includeAccess := h.getBoolQuery("access") && h.user == nil
@@ -339,6 +343,67 @@ func (h *handler) handleDumpChannel() error {
 	return nil
 }
 
+// HTTP handler for _export_channel. Streams the current revision of every document in the channel as
+// newline-delimited JSON, consistent as of the collection's sequence at the start of the export.
+// ?attachments=true inlines attachment bodies, otherwise attachments are exported as stubs.
+func (h *handler) handleExportChannel() error {
+	channelName := h.PathVar("channel")
+	includeAttachments := h.getBoolQuery("attachments")
+	base.InfofCtx(h.ctx(), base.KeyHTTP, "Export channel %q", base.UD(channelName))
+
+	h.setHeader("Content-Type", "application/x-ndjson")
+	_, err := h.collection.ExportChannel(h.ctx(), channelName, includeAttachments, h.response)
+	return err
+}
+
+// HTTP handler for a POST to _import_archive. Restores the documents in a newline-delimited JSON archive, as written by
+// _export_channel, through the normal write path. Streams the outcome of each document as newline-delimited JSON,
+// followed by a summary. ?dry_run=true validates the archive without writing, and ?max_docs_per_sec limits the write
+// rate.
+func (h *handler) handleImportArchive() error {
+	options := db.ArchiveImportOptions{
+		DryRun:        h.getBoolQuery("dry_run"),
+		MaxDocsPerSec: int(h.getIntQuery("max_docs_per_sec", db.DefaultArchiveImportMaxDocsPerSec)),
+	}
+
+	input, err := processContentEncoding(h.rq.Header, h.requestBody, "application/x-ndjson")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = input.Close() }()
+
+	h.setHeader("Content-Type", "application/x-ndjson")
+	writeLine := func(value interface{}) error {
+		lineBytes, err := base.JSONMarshal(value)
+		if err != nil {
+			return err
+		}
+		_, err = h.response.Write(append(lineBytes, '\n'))
+		return err
+	}
+	summary, err := h.collection.ImportArchive(h.ctx(), input, options, func(result db.ArchiveImportResult) error {
+		return writeLine(result)
+	})
+	if err != nil {
+		return err
+	}
+	return writeLine(summary)
+}
+
+// HTTP handler for _channel_sizes. Returns the estimated document count and body size of each channel in the
+// collection, from a cached report unless the report has expired or ?refresh=true is set.
+func (h *handler) handleGetChannelSizes() error {
+	sampleSize := int(h.getRestrictedIntQuery("sample_size", db.DefaultChannelSizeSampleSize, 0, db.MaxChannelSizeSampleSize, true))
+	refresh := h.getBoolQuery("refresh")
+
+	report, err := h.collection.GetChannelSizeReport(h.ctx(), sampleSize, db.DefaultChannelSizeReportTTL, refresh)
+	if err != nil {
+		return err
+	}
+	h.writeJSON(report)
+	return nil
+}
+
 // HTTP handler for a POST to _bulk_get
 // Request looks like POST /db/_bulk_get?revs=___&attachments=___
 // where the boolean ?revs parameter adds a revision history to each doc
@@ -372,7 +437,12 @@ func (h *handler) handleBulkGet() error {
 		canCompressParts = true
 	}
 
-	body, err := h.readJSON()
+	reservation, err := h.reserveBulkMemory()
+	defer reservation.release()
+	if err != nil {
+		return err
+	}
+	body, err := h.readBulkJSON(reservation)
 	if err != nil {
 		return err
 	}
@@ -429,10 +499,23 @@ func (h *handler) handleBulkGet() error {
 
 			}
 
+			if err == nil {
+				err = h.db.CheckRequestQuota(h.ctx(), db.QuotaReads)
+			}
 			if err == nil {
 				body, err = h.collection.Get1xRevBodyWithHistory(h.ctx(), docid, revid, docRevsLimit, revsFrom, attsSince, showExp)
 			}
 
+			// Documents are streamed one at a time, so memory is only reserved for the inline attachments of the
+			// document being written.  A document that can't be reserved for is reported as an error.
+			var attsSize int64
+			if err == nil {
+				attsSize = inlineAttachmentsSize(body)
+				if err = reservation.reserve(h.ctx(), attsSize); err != nil {
+					attsSize = 0
+				}
+			}
+
 			if err != nil {
 				// Report error in the response for this doc:
 				status, reason := base.ErrorAsHTTPStatus(err)
@@ -444,8 +527,12 @@ func (h *handler) handleBulkGet() error {
 			}
 
 			_ = WriteRevisionAsPart(h.ctx(), h.db.DatabaseContext.DbStats.CBLReplicationPull(), body, err != nil, canCompressParts, writer)
+			reservation.unreserve(attsSize)
 
 			h.db.DbStats.Database().NumDocReadsRest.Add(1)
+			if err == nil {
+				h.db.RecordRequestQuota(db.QuotaReads)
+			}
 		}
 		return nil
 	})
@@ -459,7 +546,12 @@ func (h *handler) handleBulkDocs() error {
 		h.db.DbStats.CBLReplicationPush().WriteProcessingTime.Add(time.Since(startTime).Nanoseconds())
 	}()
 
-	body, err := h.readJSON()
+	reservation, err := h.reserveBulkMemory()
+	defer reservation.release()
+	if err != nil {
+		return err
+	}
+	body, err := h.readBulkJSON(reservation)
 	if err != nil {
 		return err
 	}
@@ -498,24 +590,41 @@ func (h *handler) handleBulkDocs() error {
 	}
 
 	result := make([]db.Body, 0, len(docs))
+	var maxSequence uint64 // Highest sequence written, used as the consistency token for the batch
+
+	// Notify waiting changes feeds once for the whole request, rather than once per document
+	if len(docs) > 1 {
+		h.db.StartChangeNotificationBatch()
+		defer func() {
+			h.db.EndChangeNotificationBatch(h.ctx(), maxSequence)
+		}()
+	}
 	for _, item := range docs {
 		doc := item.(map[string]interface{})
 		docid, _ := doc[db.BodyId].(string)
-		var err error
 		var revid string
-		if newEdits {
+		var writtenDoc *db.Document
+		// Each document is counted towards the user's quota, and those over it are rejected individually
+		err := h.db.CheckRequestQuota(h.ctx(), db.QuotaWrites)
+		if err == nil && newEdits {
 			if docid != "" {
-				revid, _, err = h.collection.Put(h.ctx(), docid, doc)
+				revid, writtenDoc, err = h.collection.Put(h.ctx(), docid, doc)
 			} else {
-				docid, revid, _, err = h.collection.Post(h.ctx(), doc)
+				docid, revid, writtenDoc, err = h.collection.Post(h.ctx(), doc)
 			}
-		} else {
+		} else if err == nil {
 			revisions := db.ParseRevisions(h.ctx(), doc)
 			if revisions == nil {
 				err = base.HTTPErrorf(http.StatusBadRequest, "Bad _revisions")
 			} else {
 				revid = revisions[0]
-				_, _, err = h.collection.PutExistingRevWithBody(h.ctx(), docid, doc, revisions, false)
+				writtenDoc, _, err = h.collection.PutExistingRevWithBody(h.ctx(), docid, doc, revisions, false)
+			}
+		}
+		if err == nil {
+			h.db.RecordRequestQuota(db.QuotaWrites)
+			if writtenDoc != nil && writtenDoc.Sequence > maxSequence {
+				maxSequence = writtenDoc.Sequence
 			}
 		}
 
@@ -562,6 +671,7 @@ func (h *handler) handleBulkDocs() error {
 		result = append(result, status)
 	}
 
+	h.setConsistencyToken(maxSequence)
 	h.writeJSONStatus(http.StatusCreated, result)
 	return nil
 }
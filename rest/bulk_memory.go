@@ -0,0 +1,150 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/db"
+)
+
+// bulkMemoryExpansion is the estimated number of bytes of memory used for each byte of a bulk request's JSON body, once
+// it's been decoded into document bodies and the documents are being written.
+const bulkMemoryExpansion = 4
+
+// bulkMemoryGuard limits the estimated memory used by bulk requests, both by a single request and by all the requests
+// in flight on the node, so that a few large requests can't exhaust the node's memory.
+type bulkMemoryGuard struct {
+	requestLimit int64 // Maximum bytes reserved by a single request, or 0 for no limit
+	limit        int64 // Maximum bytes reserved by all requests, or 0 for no limit
+	lock         sync.Mutex
+	inFlight     int64 // Bytes reserved by requests in flight
+}
+
+func newBulkMemoryGuard(requestLimit, limit int64) *bulkMemoryGuard {
+	return &bulkMemoryGuard{
+		requestLimit: requestLimit,
+		limit:        limit,
+	}
+}
+
+// bulkMemoryReservation is the memory reserved by a single bulk request.
+type bulkMemoryReservation struct {
+	guard    *bulkMemoryGuard
+	reserved int64
+	err      error // The error rejecting the last reservation that failed, if any
+}
+
+func (g *bulkMemoryGuard) newReservation() *bulkMemoryReservation {
+	return &bulkMemoryReservation{guard: g}
+}
+
+// reserve reserves the given number of bytes for the request, returning a 503 error without reserving them if the
+// request or the node would go over its limit.
+func (r *bulkMemoryReservation) reserve(ctx context.Context, bytes int64) error {
+	g := r.guard
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.requestLimit > 0 && r.reserved+bytes > g.requestLimit {
+		base.InfofCtx(ctx, base.KeyHTTP, "Bulk request memory limit exceeded (request: %d limit: %d)", r.reserved+bytes, g.requestLimit)
+		r.err = base.HTTPErrorf(http.StatusServiceUnavailable, "Request needs an estimated %d bytes of memory, more than the limit of %d bytes for a single bulk request. "+
+			"Split the request into smaller batches.", r.reserved+bytes, g.requestLimit)
+	} else if g.limit > 0 && g.inFlight+bytes > g.limit {
+		base.InfofCtx(ctx, base.KeyHTTP, "Bulk memory limit exceeded (in flight: %d requested: %d limit: %d)", g.inFlight, bytes, g.limit)
+		r.err = base.HTTPErrorf(http.StatusServiceUnavailable, "Not enough memory available for request: bulk requests in flight are using an estimated %d of %d bytes. "+
+			"Retry the request later, or split it into smaller batches.", g.inFlight, g.limit)
+	} else {
+		r.reserved += bytes
+		g.inFlight += bytes
+		base.SyncGatewayStats.GlobalStats.ResourceUtilizationStats().BulkMemoryInFlight.Set(g.inFlight)
+		return nil
+	}
+	base.SyncGatewayStats.GlobalStats.ResourceUtilizationStats().BulkMemoryRejectedCount.Add(1)
+	return r.err
+}
+
+// reserveUpTo grows the request's reservation to the given number of bytes, if it's smaller.
+func (r *bulkMemoryReservation) reserveUpTo(ctx context.Context, bytes int64) error {
+	if bytes <= r.reserved {
+		return nil
+	}
+	return r.reserve(ctx, bytes-r.reserved)
+}
+
+// unreserve releases the given number of bytes of the request's reservation.
+func (r *bulkMemoryReservation) unreserve(bytes int64) {
+	g := r.guard
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	r.reserved -= bytes
+	g.inFlight -= bytes
+	base.SyncGatewayStats.GlobalStats.ResourceUtilizationStats().BulkMemoryInFlight.Set(g.inFlight)
+}
+
+// release releases the request's reservation once the request has completed.
+func (r *bulkMemoryReservation) release() {
+	r.unreserve(r.reserved)
+}
+
+// bulkMemoryReader reserves memory for a bulk request's body as it's read, so a body without a known length is
+// rejected as soon as it goes over the limits, without being read in full.
+type bulkMemoryReader struct {
+	ctx         context.Context
+	reservation *bulkMemoryReservation
+	reader      io.ReadCloser
+	bytesRead   int64
+}
+
+func (r *bulkMemoryReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.bytesRead += int64(n)
+	if reserveErr := r.reservation.reserveUpTo(r.ctx, r.bytesRead*bulkMemoryExpansion); reserveErr != nil {
+		return n, reserveErr
+	}
+	return n, err
+}
+
+func (r *bulkMemoryReader) Close() error {
+	return r.reader.Close()
+}
+
+// reserveBulkMemory starts accounting for the memory used by a bulk request, reserving memory for the request body
+// as it's read.  A body with a known length is checked before any of it is read.  The reservation must be released
+// once the request has completed, including when an error is returned.
+func (h *handler) reserveBulkMemory() (*bulkMemoryReservation, error) {
+	reservation := h.server.bulkMemory.newReservation()
+	if h.rq.ContentLength > 0 {
+		if err := reservation.reserve(h.ctx(), h.rq.ContentLength*bulkMemoryExpansion); err != nil {
+			return reservation, err
+		}
+	}
+	if h.requestBody.reader != nil {
+		h.requestBody.reader = &bulkMemoryReader{
+			ctx:         h.ctx(),
+			reservation: reservation,
+			reader:      h.requestBody.reader,
+		}
+	}
+	return reservation, nil
+}
+
+// readBulkJSON parses a bulk request's JSON body, returning the reservation's error instead of a parse error if the
+// body was rejected for going over the memory limits while being read.
+func (h *handler) readBulkJSON(reservation *bulkMemoryReservation) (db.Body, error) {
+	body, err := h.readJSON()
+	if err != nil && reservation.err != nil {
+		return nil, reservation.err
+	}
+	return body, err
+}
@@ -36,11 +36,24 @@ const kDefaultTimeoutMS = 5 * 60 * 1000
 // Maximum value of _changes?timeout property
 const kMaxTimeoutMS = 15 * 60 * 1000
 
+// Values for heartbeat_style parameter on changes request, and the replicator.heartbeat_style config
+const (
+	heartbeatStyleNewline = "newline" // Heartbeats are written as a newline, which is ignored by JSON parsers
+	heartbeatStyleComment = "comment" // Heartbeats are written as a JSON comment, for proxies that treat whitespace-only writes as idle
+)
+
 // Values for feed parameter on changes request
 const feedTypeContinuous = "continuous"
 const feedTypeLongpoll = "longpoll"
 const feedTypeNormal = "normal"
 const feedTypeWebsocket = "websocket"
+const feedTypeSSE = "sse"
+
+// Default heartbeat of an SSE _changes feed, to keep the connection open through proxies while no changes are sent.
+const kDefaultSSEHeartbeatMS = 30 * 1000
+
+// lastEventIDHeader is sent by SSE clients when reconnecting, with the id of the last event they received.
+const lastEventIDHeader = "Last-Event-ID"
 
 func (h *handler) handleRevsDiff() error {
 	var input map[string][]string
@@ -110,6 +123,10 @@ func (h *handler) updateChangesOptionsFromQuery(feed *string, options *db.Change
 		options.IncludeDocs = (h.getBoolQuery("include_docs"))
 	}
 
+	if _, ok := values["include_channels"]; ok {
+		options.ShowChannels = h.getBoolQuery("include_channels")
+	}
+
 	if _, ok := values["filter"]; ok {
 		*filter = h.getQuery("filter")
 	}
@@ -143,8 +160,8 @@ func (h *handler) updateChangesOptionsFromQuery(feed *string, options *db.Change
 			h.getQueryValues(),
 			"heartbeat",
 			kDefaultHeartbeatMS,
-			kMinHeartbeatMS,
-			uint64(h.server.Config.Replicator.MaxHeartbeat.Value().Milliseconds()),
+			h.minChangesHeartbeatMs(),
+			h.maxChangesHeartbeatMs(),
 			true,
 		)
 	}
@@ -155,10 +172,18 @@ func (h *handler) updateChangesOptionsFromQuery(feed *string, options *db.Change
 			"timeout",
 			kDefaultTimeoutMS,
 			0,
-			kMaxTimeoutMS,
+			h.maxChangesTimeoutMs(),
 			true,
 		)
 	}
+
+	if _, ok := values["heartbeat_style"]; ok {
+		heartbeatStyle, err := h.getChangesHeartbeatStyle(h.getQuery("heartbeat_style"))
+		if err != nil {
+			return nil, nil, err
+		}
+		options.HeartbeatStyle = heartbeatStyle
+	}
 	return channelsArray, docIdsArray, nil
 }
 
@@ -185,9 +210,10 @@ func (h *handler) handleChanges() error {
 		options.ActiveOnly = h.getBoolQuery("active_only")
 		options.IncludeDocs = h.getBoolQuery("include_docs")
 		options.Revocations = h.getBoolQuery("revocations")
+		options.ShowChannels = h.getBoolQuery("include_channels")
 
 		useRequestPlus, _ := h.getOptBoolQuery("request_plus", h.db.Options.ChangesRequestPlus)
-		if useRequestPlus && feed != feedTypeContinuous {
+		if useRequestPlus && feed != feedTypeContinuous && feed != feedTypeSSE {
 			var seqErr error
 			options.RequestPlusSeq, seqErr = h.db.GetRequestPlusSequence()
 			if seqErr != nil {
@@ -195,6 +221,15 @@ func (h *handler) handleChanges() error {
 			}
 
 		}
+		if feed != feedTypeContinuous && feed != feedTypeSSE {
+			consistencySeq, err := parseConsistencyToken(h.getQuery(consistencyTokenParam))
+			if err != nil {
+				return err
+			}
+			if consistencySeq > options.RequestPlusSeq {
+				options.RequestPlusSeq = consistencySeq
+			}
+		}
 		filter = h.getQuery("filter")
 		channelsParam := h.getQuery("channels")
 		if channelsParam != "" {
@@ -219,8 +254,8 @@ func (h *handler) handleChanges() error {
 			h.getQueryValues(),
 			"heartbeat",
 			kDefaultHeartbeatMS,
-			kMinHeartbeatMS,
-			uint64(h.server.Config.Replicator.MaxHeartbeat.Value().Milliseconds()),
+			h.minChangesHeartbeatMs(),
+			h.maxChangesHeartbeatMs(),
 			true,
 		)
 		options.TimeoutMs = base.GetRestrictedIntQuery(
@@ -228,9 +263,13 @@ func (h *handler) handleChanges() error {
 			"timeout",
 			kDefaultTimeoutMS,
 			0,
-			kMaxTimeoutMS,
+			h.maxChangesTimeoutMs(),
 			true,
 		)
+		options.HeartbeatStyle, err = h.getChangesHeartbeatStyle(h.getQuery("heartbeat_style"))
+		if err != nil {
+			return err
+		}
 
 	} else {
 		// POST request has parameters in JSON body:
@@ -257,6 +296,10 @@ func (h *handler) handleChanges() error {
 
 	}
 
+	if err := h.checkChangesShowChannels(options); err != nil {
+		return err
+	}
+
 	// Default to feed type normal
 	if feed == "" {
 		feed = "normal"
@@ -339,6 +382,8 @@ func (h *handler) handleChanges() error {
 		err, forceClose = h.sendContinuousChangesByHTTP(userChannels, options)
 	case feedTypeWebsocket:
 		err, forceClose = h.sendContinuousChangesByWebSocket(userChannels, options)
+	case feedTypeSSE:
+		err, forceClose = h.sendContinuousChangesBySSE(userChannels, options)
 	default:
 		err = base.HTTPErrorf(http.StatusBadRequest, "Unknown feed type")
 		forceClose = false
@@ -359,6 +404,8 @@ func (h *handler) handleChanges() error {
 }
 
 func (h *handler) sendSimpleChanges(channels base.Set, options db.ChangesOptions, docids []string) (error, bool) {
+	// Return a since value in the current sequence epoch when there are no changes, so a reset client isn't reset again
+	options.Since = h.db.SinceForCurrentEpoch(h.ctx(), options.Since)
 	lastSeq := options.Since
 	var first bool = true
 	var feed <-chan *db.ChangeEntry
@@ -422,7 +469,7 @@ func (h *handler) sendSimpleChanges(channels base.Set, options db.ChangesOptions
 				}
 
 			case <-heartbeat:
-				_, err = h.response.Write([]byte("\n"))
+				_, err = h.response.Write(changesHeartbeat(options.HeartbeatStyle))
 				h.flush()
 				base.DebugfCtx(h.ctx(), base.KeyChanges, "heartbeat written to _changes feed for request received")
 			case <-timeout:
@@ -475,26 +522,67 @@ func (h *handler) sendContinuousChangesByHTTP(inChannels base.Set, options db.Ch
 	h.setHeader("Content-Type", "application/octet-stream")
 	h.setHeader("Cache-Control", "private, max-age=0, no-cache, no-store")
 	h.logStatus(http.StatusOK, "sending continuous feed")
+	writer := h.newChangesFeedWriter()
+	defer writer.finish()
 	return h.generateContinuousChanges(inChannels, options, func(changes []*db.ChangeEntry) error {
-		var err error
-		if changes != nil {
-			for _, change := range changes {
-				data, _ := base.JSONMarshal(change)
-				if _, err = h.response.Write(data); err != nil {
-					break
-				}
-				if _, err = h.response.Write([]byte("\n")); err != nil {
-					break
-				}
+		if changes == nil {
+			return writer.write(changesHeartbeat(options.HeartbeatStyle))
+		}
+		// Each entry is flushed as it's written, so a slow client is detected on the entry it stalls on
+		for _, change := range changes {
+			data, _ := base.JSONMarshal(change)
+			if err := writer.write(append(data, '\n')); err != nil {
+				return err
 			}
-		} else {
-			_, err = h.response.Write([]byte("\n"))
 		}
-		h.flush()
-		return err
+		return nil
 	})
 }
 
+// sendContinuousChangesBySSE sends a continuous changes feed as Server-Sent Events. Each change is sent as a "change"
+// event whose id is the change's sequence, so a client reconnecting with a Last-Event-ID header resumes after the
+// last change it received. Heartbeats are sent as comments.
+func (h *handler) sendContinuousChangesBySSE(inChannels base.Set, options db.ChangesOptions) (error, bool) {
+	if lastEventID := h.rq.Header.Get(lastEventIDHeader); lastEventID != "" {
+		since, err := db.ParsePlainSequenceID(lastEventID)
+		if err != nil {
+			return base.HTTPErrorf(http.StatusBadRequest, "Invalid %s header: %q", lastEventIDHeader, lastEventID), false
+		}
+		options.Since = since
+	}
+	// A timeout only applies to feeds without a heartbeat, so only default the heartbeat when neither is requested
+	if options.HeartbeatMs == 0 && options.TimeoutMs == 0 {
+		options.HeartbeatMs = base.MaxUint64(kDefaultSSEHeartbeatMS, h.minChangesHeartbeatMs())
+	}
+
+	h.setHeader("Content-Type", "text/event-stream")
+	h.setHeader("Cache-Control", "private, max-age=0, no-cache, no-store")
+	h.logStatus(http.StatusOK, "sending SSE feed")
+	h.flush()
+	writer := h.newChangesFeedWriter()
+	defer writer.finish()
+	return h.generateContinuousChanges(inChannels, options, func(changes []*db.ChangeEntry) error {
+		if changes == nil {
+			return writer.write([]byte(": heartbeat\n\n"))
+		}
+		for _, change := range changes {
+			if err := writer.write(sseChangeEvent(change)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// sseChangeEvent returns a change formatted as a Server-Sent Event.
+func sseChangeEvent(change *db.ChangeEntry) []byte {
+	data, _ := base.JSONMarshal(change)
+	event := make([]byte, 0, len(data)+64)
+	event = append(event, "id: "+change.Seq.String()+"\nevent: change\ndata: "...)
+	event = append(event, data...)
+	return append(event, "\n\n"...)
+}
+
 func (h *handler) sendContinuousChangesByWebSocket(inChannels base.Set, options db.ChangesOptions) (error, bool) {
 
 	forceClose := false
@@ -518,6 +606,9 @@ func (h *handler) sendContinuousChangesByWebSocket(inChannels base.Set, options
 			if _, wsoptions, _, channelNames, _, compress, err = h.readChangesOptionsFromJSON(msg); err != nil {
 				return
 			}
+			if err = h.checkChangesShowChannels(wsoptions); err != nil {
+				return
+			}
 			if channelNames != nil {
 				inChannels, _ = ch.SetFromArray(channelNames, ch.ExpandStar)
 			}
@@ -575,19 +666,22 @@ func (h *handler) sendContinuousChangesByWebSocket(inChannels base.Set, options
 
 func (h *handler) readChangesOptionsFromJSON(jsonData []byte) (feed string, options db.ChangesOptions, filter string, channelsArray []string, docIdsArray []string, compress bool, err error) {
 	var input struct {
-		Feed           string        `json:"feed"`
-		Since          db.SequenceID `json:"since"`
-		Limit          int           `json:"limit"`
-		Style          string        `json:"style"`
-		IncludeDocs    bool          `json:"include_docs"`
-		Filter         string        `json:"filter"`
-		Channels       string        `json:"channels"` // a filter query param, so it has to be a string
-		DocIds         []string      `json:"doc_ids"`
-		HeartbeatMs    *uint64       `json:"heartbeat"`
-		TimeoutMs      *uint64       `json:"timeout"`
-		AcceptEncoding string        `json:"accept_encoding"`
-		ActiveOnly     bool          `json:"active_only"`  // Return active revisions only
-		RequestPlus    *bool         `json:"request_plus"` // Wait for sequence buffering to catch up to database seq value at time request was issued
+		Feed             string        `json:"feed"`
+		Since            db.SequenceID `json:"since"`
+		Limit            int           `json:"limit"`
+		Style            string        `json:"style"`
+		IncludeDocs      bool          `json:"include_docs"`
+		IncludeChannels  bool          `json:"include_channels"` // Include each document's current channels, on admin feeds only
+		Filter           string        `json:"filter"`
+		Channels         string        `json:"channels"` // a filter query param, so it has to be a string
+		DocIds           []string      `json:"doc_ids"`
+		HeartbeatMs      *uint64       `json:"heartbeat"`
+		TimeoutMs        *uint64       `json:"timeout"`
+		HeartbeatStyle   string        `json:"heartbeat_style"`
+		AcceptEncoding   string        `json:"accept_encoding"`
+		ActiveOnly       bool          `json:"active_only"`       // Return active revisions only
+		RequestPlus      *bool         `json:"request_plus"`      // Wait for sequence buffering to catch up to database seq value at time request was issued
+		ConsistencyToken string        `json:"consistency_token"` // Wait for sequence buffering to catch up to the write that returned this token
 	}
 
 	// Initialize since clock and hasher ahead of unmarshalling sequence
@@ -606,6 +700,7 @@ func (h *handler) readChangesOptionsFromJSON(jsonData []byte) (feed string, opti
 	options.ActiveOnly = input.ActiveOnly
 
 	options.IncludeDocs = input.IncludeDocs
+	options.ShowChannels = input.IncludeChannels
 	filter = input.Filter
 
 	if input.Channels != "" {
@@ -616,8 +711,8 @@ func (h *handler) readChangesOptionsFromJSON(jsonData []byte) (feed string, opti
 	options.HeartbeatMs = base.GetRestrictedInt(
 		input.HeartbeatMs,
 		kDefaultHeartbeatMS,
-		kMinHeartbeatMS,
-		uint64(h.server.Config.Replicator.MaxHeartbeat.Value().Milliseconds()),
+		h.minChangesHeartbeatMs(),
+		h.maxChangesHeartbeatMs(),
 		true,
 	)
 
@@ -625,13 +720,18 @@ func (h *handler) readChangesOptionsFromJSON(jsonData []byte) (feed string, opti
 		input.TimeoutMs,
 		kDefaultTimeoutMS,
 		0,
-		kMaxTimeoutMS,
+		h.maxChangesTimeoutMs(),
 		true,
 	)
 
+	options.HeartbeatStyle, err = h.getChangesHeartbeatStyle(input.HeartbeatStyle)
+	if err != nil {
+		return
+	}
+
 	compress = (input.AcceptEncoding == "gzip")
 
-	if h.db != nil && feed != feedTypeContinuous {
+	if h.db != nil && feed != feedTypeContinuous && feed != feedTypeSSE {
 		useRequestPlus := h.db.Options.ChangesRequestPlus
 		if input.RequestPlus != nil {
 			useRequestPlus = *input.RequestPlus
@@ -644,10 +744,62 @@ func (h *handler) readChangesOptionsFromJSON(jsonData []byte) (feed string, opti
 				return
 			}
 		}
+		consistencySeq, parseErr := parseConsistencyToken(input.ConsistencyToken)
+		if parseErr != nil {
+			err = parseErr
+			return
+		}
+		if consistencySeq > options.RequestPlusSeq {
+			options.RequestPlusSeq = consistencySeq
+		}
 	}
 	return
 }
 
+// minChangesHeartbeatMs returns the minimum heartbeat interval a _changes request can specify.
+func (h *handler) minChangesHeartbeatMs() uint64 {
+	if h.server.Config.Replicator.MinHeartbeat == nil {
+		return kMinHeartbeatMS
+	}
+	return uint64(h.server.Config.Replicator.MinHeartbeat.Value().Milliseconds())
+}
+
+// maxChangesHeartbeatMs returns the maximum heartbeat interval a _changes request can specify, or zero if unbounded.
+func (h *handler) maxChangesHeartbeatMs() uint64 {
+	return uint64(h.server.Config.Replicator.MaxHeartbeat.Value().Milliseconds())
+}
+
+// maxChangesTimeoutMs returns the maximum longpoll timeout a _changes request can specify.
+func (h *handler) maxChangesTimeoutMs() uint64 {
+	if h.server.Config.Replicator.MaxChangesTimeout == nil {
+		return kMaxTimeoutMS
+	}
+	return uint64(h.server.Config.Replicator.MaxChangesTimeout.Value().Milliseconds())
+}
+
+// getChangesHeartbeatStyle validates a _changes request's heartbeat_style, defaulting to the server's configured style.
+func (h *handler) getChangesHeartbeatStyle(heartbeatStyle string) (string, error) {
+	if heartbeatStyle == "" {
+		heartbeatStyle = h.server.Config.Replicator.HeartbeatStyle
+	}
+	switch heartbeatStyle {
+	case "":
+		return heartbeatStyleNewline, nil
+	case heartbeatStyleNewline, heartbeatStyleComment:
+		return heartbeatStyle, nil
+	default:
+		return "", base.HTTPErrorf(http.StatusBadRequest, "Invalid heartbeat_style %q, must be one of %q or %q", heartbeatStyle, heartbeatStyleNewline, heartbeatStyleComment)
+	}
+}
+
+// changesHeartbeat returns the bytes written to an HTTP _changes feed as a heartbeat.
+func changesHeartbeat(heartbeatStyle string) []byte {
+	if heartbeatStyle == heartbeatStyleComment {
+		return []byte("/* heartbeat */\n")
+	}
+	return []byte("\n")
+}
+
 // Helper function to read a complete message from a WebSocket
 func readWebSocketMessage(ctx context.Context, conn *websocket.Conn) ([]byte, error) {
 
@@ -661,3 +813,18 @@ func readWebSocketMessage(ctx context.Context, conn *websocket.Conn) ([]byte, er
 	return message, nil
 
 }
+
+// checkChangesShowChannels returns an error if a changes feed requests each document's channels, but isn't an admin
+// feed or the database doesn't retain channels for changes feeds.
+func (h *handler) checkChangesShowChannels(options db.ChangesOptions) error {
+	if !options.ShowChannels {
+		return nil
+	}
+	if h.privs != adminPrivs {
+		return base.HTTPErrorf(http.StatusBadRequest, "include_channels is only supported by the admin API")
+	}
+	if !h.db.Options.ChangesIncludeChannels {
+		return base.HTTPErrorf(http.StatusBadRequest, "include_channels requires changes_include_channels to be enabled in the database config")
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// slowConsumerPolicy decides when a continuous _changes feed client is too slow to read the feed and is disconnected,
+// so that proxies and misbehaving clients can't pin the feed's goroutines indefinitely.
+type slowConsumerPolicy struct {
+	writeTimeout       time.Duration // Maximum time to write and flush an entry, 0 for no limit
+	slowWriteThreshold time.Duration // Time after which a write is slow, 0 to not count slow writes
+	maxSlowWrites      int           // Consecutive slow writes before the client is disconnected, 0 for no limit
+}
+
+// changesFeedWriter writes the entries of a continuous _changes feed, flushing each one to the client, and disconnects
+// the client when it's too slow to read them.
+type changesFeedWriter struct {
+	h          *handler
+	policy     slowConsumerPolicy
+	conn       net.Conn // The client's connection, for write deadlines, or nil if not available
+	slowWrites int      // Consecutive slow writes
+	slow       bool     // Set once the client has been disconnected as a slow consumer
+	now        func() time.Time
+}
+
+// newChangesFeedWriter returns a writer for the request's continuous _changes feed, using the server's slow consumer
+// policy.  Write deadlines are only set on HTTP/1.x connections, as HTTP/2 connections are shared with other requests.
+func (h *handler) newChangesFeedWriter() *changesFeedWriter {
+	config := h.server.Config.Replicator
+	w := &changesFeedWriter{
+		h: h,
+		policy: slowConsumerPolicy{
+			writeTimeout:       config.ChangesWriteTimeout.Value(),
+			slowWriteThreshold: config.ChangesSlowWriteThreshold.Value(),
+		},
+		now: time.Now,
+	}
+	if config.ChangesMaxSlowWrites != nil {
+		w.policy.maxSlowWrites = *config.ChangesMaxSlowWrites
+	}
+	if h.rq.ProtoMajor == 1 {
+		w.conn = base.HTTPConnFromContext(h.rq.Context())
+	}
+	return w
+}
+
+// write writes and flushes an entry or heartbeat to the client.  Returns an error if the write failed, or the client
+// is disconnected as a slow consumer.
+func (w *changesFeedWriter) write(data []byte) error {
+	if w.conn != nil && w.policy.writeTimeout > 0 {
+		_ = w.conn.SetWriteDeadline(w.now().Add(w.policy.writeTimeout))
+	}
+	start := w.now()
+	_, err := w.h.response.Write(data)
+	if err == nil {
+		w.h.flush()
+	}
+	return w.checkWriteTime(w.now().Sub(start), err)
+}
+
+// checkWriteTime applies the slow consumer policy to a write that took the given time.
+func (w *changesFeedWriter) checkWriteTime(elapsed time.Duration, writeErr error) error {
+	dbStats := w.h.db.DbStats.Database()
+	if w.policy.writeTimeout > 0 && elapsed >= w.policy.writeTimeout {
+		w.slow = true
+		dbStats.ChangesSlowConsumerDisconnectCount.Add(1)
+		base.InfofCtx(w.h.ctx(), base.KeyChanges, "Disconnecting slow _changes feed consumer: write took %v, over the write timeout of %v", elapsed, w.policy.writeTimeout)
+		return fmt.Errorf("write timed out after %v", elapsed)
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if w.policy.slowWriteThreshold <= 0 || elapsed < w.policy.slowWriteThreshold {
+		w.slowWrites = 0
+		return nil
+	}
+	dbStats.ChangesSlowWriteCount.Add(1)
+	w.slowWrites++
+	base.DebugfCtx(w.h.ctx(), base.KeyChanges, "Slow _changes feed write took %v (%d consecutive)", elapsed, w.slowWrites)
+	if w.policy.maxSlowWrites > 0 && w.slowWrites >= w.policy.maxSlowWrites {
+		w.slow = true
+		dbStats.ChangesSlowConsumerDisconnectCount.Add(1)
+		base.InfofCtx(w.h.ctx(), base.KeyChanges, "Disconnecting slow _changes feed consumer after %d consecutive writes over %v", w.slowWrites, w.policy.slowWriteThreshold)
+		return fmt.Errorf("%d consecutive slow writes", w.slowWrites)
+	}
+	return nil
+}
+
+// finish bounds the time to write the rest of the response once the feed has ended, so a slow consumer can't block
+// the handler from returning.  A client disconnected as a slow consumer gets no more writes.
+func (w *changesFeedWriter) finish() {
+	if w.conn == nil || w.policy.writeTimeout <= 0 {
+		return
+	}
+	deadline := w.now()
+	if !w.slow {
+		deadline = deadline.Add(w.policy.writeTimeout)
+	}
+	_ = w.conn.SetWriteDeadline(deadline)
+}
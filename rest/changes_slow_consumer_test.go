@@ -0,0 +1,77 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/couchbase/sync_gateway/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangesFeedWriterSlowConsumer(t *testing.T) {
+	rt := NewRestTester(t, nil)
+	defer rt.Close()
+
+	database, err := db.CreateDatabase(rt.GetDatabase())
+	require.NoError(t, err)
+	dbStats := rt.GetDatabase().DbStats.Database()
+	slowWritesBefore := dbStats.ChangesSlowWriteCount.Value()
+	disconnectsBefore := dbStats.ChangesSlowConsumerDisconnectCount.Value()
+
+	// Each write takes writeTime, as the clock advances on each call
+	now := time.Now()
+	var writeTime time.Duration
+	newWriter := func() (*changesFeedWriter, *httptest.ResponseRecorder) {
+		response := httptest.NewRecorder()
+		return &changesFeedWriter{
+			h:      &handler{db: database, response: NewNonCountedResponseWriter(response), rqCtx: rt.Context()},
+			policy: slowConsumerPolicy{writeTimeout: time.Minute, slowWriteThreshold: time.Second, maxSlowWrites: 2},
+			now: func() time.Time {
+				now = now.Add(writeTime)
+				return now
+			},
+		}, response
+	}
+
+	// Entries are flushed as they're written, and a fast write resets the count of consecutive slow writes
+	writer, response := newWriter()
+	writeTime = 2 * time.Second
+	require.NoError(t, writer.write([]byte("entry1\n")))
+	assert.True(t, response.Flushed)
+	writeTime = time.Millisecond
+	require.NoError(t, writer.write([]byte("entry2\n")))
+	writeTime = 2 * time.Second
+	require.NoError(t, writer.write([]byte("entry3\n")))
+	assert.Equal(t, "entry1\nentry2\nentry3\n", response.Body.String())
+	assert.Equal(t, slowWritesBefore+2, dbStats.ChangesSlowWriteCount.Value())
+	assert.Equal(t, disconnectsBefore, dbStats.ChangesSlowConsumerDisconnectCount.Value())
+
+	// Too many consecutive slow writes disconnect the client
+	require.Error(t, writer.write([]byte("entry4\n")))
+	assert.True(t, writer.slow)
+	assert.Equal(t, slowWritesBefore+3, dbStats.ChangesSlowWriteCount.Value())
+	assert.Equal(t, disconnectsBefore+1, dbStats.ChangesSlowConsumerDisconnectCount.Value())
+
+	// A write over the write timeout disconnects the client immediately
+	writer, _ = newWriter()
+	writeTime = 2 * time.Minute
+	require.Error(t, writer.write([]byte("entry1\n")))
+	assert.True(t, writer.slow)
+	assert.Equal(t, disconnectsBefore+2, dbStats.ChangesSlowConsumerDisconnectCount.Value())
+
+	// Without a policy, slow writes aren't counted
+	writer, _ = newWriter()
+	writer.policy = slowConsumerPolicy{}
+	require.NoError(t, writer.write([]byte("entry1\n")))
+	assert.Equal(t, slowWritesBefore+3, dbStats.ChangesSlowWriteCount.Value())
+}
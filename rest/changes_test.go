@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
 	"github.com/couchbase/sync_gateway/db"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -82,6 +83,46 @@ func TestReadChangesOptionsFromJSON(t *testing.T) {
 	_, options, _, _, _, _, err = h.readChangesOptionsFromJSON([]byte(optStr))
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(60000), options.HeartbeatMs)
+
+	// Set min heartbeat and max timeout in server context, attempt to set values outside them
+	h.server.Config.Replicator.MinHeartbeat = base.NewConfigDuration(5 * time.Second)
+	h.server.Config.Replicator.MaxChangesTimeout = base.NewConfigDuration(time.Minute)
+	optStr = `{"feed":"longpoll", "since": "1", "heartbeat":1000, "timeout":90000}`
+	_, options, _, _, _, _, err = h.readChangesOptionsFromJSON([]byte(optStr))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5000), options.HeartbeatMs)
+	assert.Equal(t, uint64(60000), options.TimeoutMs)
+
+	// Heartbeat style defaults to newline, unless configured on the server or specified by the request
+	assert.Equal(t, heartbeatStyleNewline, options.HeartbeatStyle)
+	optStr = `{"feed":"longpoll", "since": "1", "heartbeat_style":"comment"}`
+	_, options, _, _, _, _, err = h.readChangesOptionsFromJSON([]byte(optStr))
+	assert.NoError(t, err)
+	assert.Equal(t, heartbeatStyleComment, options.HeartbeatStyle)
+
+	h.server.Config.Replicator.HeartbeatStyle = heartbeatStyleComment
+	optStr = `{"feed":"longpoll", "since": "1"}`
+	_, options, _, _, _, _, err = h.readChangesOptionsFromJSON([]byte(optStr))
+	assert.NoError(t, err)
+	assert.Equal(t, heartbeatStyleComment, options.HeartbeatStyle)
+
+	optStr = `{"feed":"longpoll", "since": "1", "heartbeat_style":"whitespace"}`
+	_, _, _, _, _, _, err = h.readChangesOptionsFromJSON([]byte(optStr))
+	assert.Error(t, err)
+}
+
+func TestChangesHeartbeatStyle(t *testing.T) {
+	assert.Equal(t, []byte("\n"), changesHeartbeat(heartbeatStyleNewline))
+	assert.Equal(t, []byte("/* heartbeat */\n"), changesHeartbeat(heartbeatStyleComment))
+
+	rt := NewRestTester(t, nil)
+	defer rt.Close()
+
+	response := rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/_changes?heartbeat_style=whitespace", "")
+	RequireStatus(t, response, http.StatusBadRequest)
+
+	response = rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/_changes?heartbeat_style=comment", "")
+	RequireStatus(t, response, http.StatusOK)
 }
 
 // Test for wrong _changes entries for user joining a populated channel
@@ -275,3 +316,67 @@ func TestWebhookWinningRevChangedEvent(t *testing.T) {
 	assert.Equal(t, 5, int(atomic.LoadUint32(&WinningRevChangedCount)))
 	assert.Equal(t, 6, int(atomic.LoadUint32(&DocumentChangedCount)))
 }
+
+func TestChangesSSE(t *testing.T) {
+	rt := NewRestTester(t, nil)
+	defer rt.Close()
+
+	RequireStatus(t, rt.SendAdminRequest(http.MethodPut, "/{{.keyspace}}/doc1", `{"foo":"bar"}`), http.StatusCreated)
+	RequireStatus(t, rt.SendAdminRequest(http.MethodPut, "/{{.keyspace}}/doc2", `{"foo":"bar"}`), http.StatusCreated)
+	require.NoError(t, rt.WaitForPendingChanges())
+
+	response := rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/_changes?feed=sse&since=0&timeout=500", "")
+	RequireStatus(t, response, http.StatusOK)
+	assert.Equal(t, "text/event-stream", response.Header().Get("Content-Type"))
+	body := response.Body.String()
+	assert.Contains(t, body, "id: 1\nevent: change\ndata: {")
+	assert.Contains(t, body, "id: 2\nevent: change\ndata: {")
+	assert.Contains(t, body, `"id":"doc2"`)
+
+	// Last-Event-ID resumes after the last change received, and takes precedence over since
+	response = rt.SendAdminRequestWithHeaders(http.MethodGet, "/{{.keyspace}}/_changes?feed=sse&since=0&timeout=500", "", map[string]string{"Last-Event-ID": "1"})
+	RequireStatus(t, response, http.StatusOK)
+	body = response.Body.String()
+	assert.NotContains(t, body, "id: 1\n")
+	assert.Contains(t, body, "id: 2\nevent: change\n")
+
+	response = rt.SendAdminRequestWithHeaders(http.MethodGet, "/{{.keyspace}}/_changes?feed=sse&timeout=500", "", map[string]string{"Last-Event-ID": "bogus"})
+	RequireStatus(t, response, http.StatusBadRequest)
+}
+
+func TestChangesIncludeChannels(t *testing.T) {
+	rtConfig := &RestTesterConfig{
+		SyncFn: channels.DocChannelsSyncFunction,
+		DatabaseConfig: &DatabaseConfig{DbConfig: DbConfig{
+			ChangesIncludeChannels: base.BoolPtr(true),
+		}},
+	}
+	rt := NewRestTester(t, rtConfig)
+	defer rt.Close()
+
+	rt.PutDoc("doc1", `{"channels": ["B", "A"]}`)
+	rt.PutDoc("doc2", `{"channels": "C"}`)
+	require.NoError(t, rt.WaitForPendingChanges())
+
+	getChannels := func(path string) map[string][]string {
+		response := rt.SendAdminRequest(http.MethodGet, path, "")
+		RequireStatus(t, response, http.StatusOK)
+		var changes ChangesResults
+		require.NoError(t, base.JSONUnmarshal(response.Body.Bytes(), &changes))
+		channelsByDoc := make(map[string][]string)
+		for _, change := range changes.Results {
+			channelsByDoc[change.ID] = change.Channels
+		}
+		return channelsByDoc
+	}
+
+	expected := map[string][]string{"doc1": {"A", "B"}, "doc2": {"C"}}
+	assert.Equal(t, expected, getChannels("/{{.keyspace}}/_changes?include_channels=true"))
+	assert.Equal(t, expected, getChannels(`/{{.keyspace}}/_changes?include_channels=true&filter=_doc_ids&doc_ids=doc1,doc2`))
+	assert.Equal(t, map[string][]string{"doc1": nil, "doc2": nil}, getChannels("/{{.keyspace}}/_changes"))
+
+	// Channels are only included by admin feeds
+	rt.CreateUser("alice", []string{"A"})
+	response := rt.SendUserRequestWithHeaders(http.MethodGet, "/{{.keyspace}}/_changes?include_channels=true", "", nil, "alice", RestTesterDefaultUserPassword)
+	RequireStatus(t, response, http.StatusBadRequest)
+}
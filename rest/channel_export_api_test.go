@@ -0,0 +1,125 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+	"github.com/couchbase/sync_gateway/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportChannel(t *testing.T) {
+	rt := NewRestTester(t, &RestTesterConfig{SyncFn: channels.DocChannelsSyncFunction})
+	defer rt.Close()
+
+	rt.PutDoc("doc1", `{"channels":["books"]}`)
+	rt.PutDoc("doc2", `{"channels":["books", "gifts"], "_attachments":{"att1":{"data":"aGVsbG8gd29ybGQ="}}}`)
+	rt.PutDoc("doc3", `{"channels":["gifts"]}`)
+	lastSeq, err := rt.GetSingleTestDatabaseCollection().LastSequence(base.TestCtx(t))
+	require.NoError(t, err)
+
+	exportChannel := func(query string) (map[string]db.ChannelExportEntry, db.ChannelExportSummary) {
+		response := rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/_export_channel/books"+query, "")
+		RequireStatus(t, response, http.StatusOK)
+		assert.Equal(t, "application/x-ndjson", response.Header().Get("Content-Type"))
+
+		lines := bytes.Split(bytes.TrimSpace(response.Body.Bytes()), []byte("\n"))
+		require.NotEmpty(t, lines)
+		entries := make(map[string]db.ChannelExportEntry, len(lines)-1)
+		for _, line := range lines[:len(lines)-1] {
+			var entry db.ChannelExportEntry
+			require.NoError(t, base.JSONUnmarshal(line, &entry))
+			entries[entry.DocID] = entry
+		}
+		var summary db.ChannelExportSummary
+		require.NoError(t, base.JSONUnmarshal(lines[len(lines)-1], &summary))
+		return entries, summary
+	}
+
+	entries, summary := exportChannel("")
+	assert.Equal(t, db.ChannelExportSummary{Channel: "books", SnapshotSeq: lastSeq, Exported: 2}, summary)
+	require.Len(t, entries, 2)
+	require.Contains(t, entries, "doc1")
+	assert.Equal(t, "doc1", entries["doc1"].Doc[db.BodyId])
+	require.Contains(t, entries, "doc2")
+	attachment := entries["doc2"].Doc[db.BodyAttachments].(map[string]interface{})["att1"].(map[string]interface{})
+	assert.Equal(t, true, attachment["stub"])
+	assert.NotContains(t, attachment, "data")
+
+	// Attachment bodies inlined on request
+	entries, _ = exportChannel("?attachments=true")
+	attachment = entries["doc2"].Doc[db.BodyAttachments].(map[string]interface{})["att1"].(map[string]interface{})
+	assert.NotContains(t, attachment, "stub")
+	assert.Contains(t, attachment, "data")
+}
+
+func TestImportArchive(t *testing.T) {
+	rt := NewRestTester(t, &RestTesterConfig{SyncFn: channels.DocChannelsSyncFunction})
+	defer rt.Close()
+
+	rt.PutDoc("doc1", `{"channels":["books"], "title":"one"}`)
+	doc2Version := rt.PutDoc("doc2", `{"channels":["books"], "title":"two"}`)
+	response := rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/_export_channel/books", "")
+	RequireStatus(t, response, http.StatusOK)
+	archive := response.Body.String() + `{"_id":"doc3","channels":["books"]}` + "\n"
+
+	// doc1 has been modified since the export, and doc2 purged
+	doc1Version, _ := rt.GetDoc("doc1")
+	rt.UpdateDoc("doc1", doc1Version, `{"channels":["books"], "title":"modified"}`)
+	RequireStatus(t, rt.SendAdminRequest(http.MethodPost, "/{{.keyspace}}/_purge", `{"doc2":["*"]}`), http.StatusOK)
+
+	importArchive := func(query string) (map[string]db.ArchiveImportResult, db.ArchiveImportSummary) {
+		response := rt.SendAdminRequest(http.MethodPost, "/{{.keyspace}}/_import_archive"+query, archive)
+		RequireStatus(t, response, http.StatusOK)
+		lines := bytes.Split(bytes.TrimSpace(response.Body.Bytes()), []byte("\n"))
+		require.NotEmpty(t, lines)
+		results := make(map[string]db.ArchiveImportResult, len(lines)-1)
+		for _, line := range lines[:len(lines)-1] {
+			var result db.ArchiveImportResult
+			require.NoError(t, base.JSONUnmarshal(line, &result))
+			results[result.DocID] = result
+		}
+		var summary db.ArchiveImportSummary
+		require.NoError(t, base.JSONUnmarshal(lines[len(lines)-1], &summary))
+		return results, summary
+	}
+
+	// Dry run doesn't write anything
+	results, summary := importArchive("?dry_run=true")
+	assert.Equal(t, db.ArchiveImportSummary{Imported: 3, DryRun: true}, summary)
+	assert.Equal(t, http.StatusOK, results["doc2"].Status)
+	RequireStatus(t, rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/doc2", ""), http.StatusNotFound)
+
+	results, summary = importArchive("?max_docs_per_sec=0")
+	assert.Equal(t, db.ArchiveImportSummary{Imported: 3}, summary)
+
+	// Purged doc restored with its archived revision
+	assert.Equal(t, db.ArchiveImportResult{DocID: "doc2", RevID: doc2Version.RevID, RevPreserved: true, Status: http.StatusCreated}, results["doc2"])
+	restoredVersion, doc2 := rt.GetDoc("doc2")
+	assert.Equal(t, doc2Version, restoredVersion)
+	assert.Equal(t, "two", doc2["title"])
+
+	// Modified doc restored as a new revision
+	assert.False(t, results["doc1"].RevPreserved)
+	doc1Version, doc1 := rt.GetDoc("doc1")
+	assert.Equal(t, results["doc1"].RevID, doc1Version.RevID)
+	assert.Equal(t, "3-", doc1Version.RevID[:2])
+	assert.Equal(t, "one", doc1["title"])
+
+	// Doc without a revision written as a new document
+	assert.Equal(t, http.StatusCreated, results["doc3"].Status)
+	doc3Version, _ := rt.GetDoc("doc3")
+	assert.Equal(t, results["doc3"].RevID, doc3Version.RevID)
+}
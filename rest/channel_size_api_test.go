@@ -0,0 +1,65 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+	"github.com/couchbase/sync_gateway/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelSizes(t *testing.T) {
+	rt := NewRestTester(t, &RestTesterConfig{SyncFn: channels.DocChannelsSyncFunction})
+	defer rt.Close()
+
+	rt.PutDoc("doc1", `{"channels":["books"]}`)
+	rt.PutDoc("doc2", `{"channels":["books", "gifts"]}`)
+	rt.PutDoc("doc3", `{"channels":["gifts"], "padding":"`+base.CreateProperty(1000)+`"}`)
+
+	getReport := func(query string) db.ChannelSizeReport {
+		response := rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/_channel_sizes"+query, "")
+		RequireStatus(t, response, http.StatusOK)
+		var report db.ChannelSizeReport
+		require.NoError(t, base.JSONUnmarshal(response.Body.Bytes(), &report))
+		return report
+	}
+
+	report := getReport("")
+	assert.Equal(t, int64(3), report.TotalDocs)
+	assert.Equal(t, int64(3), report.SampledDocs)
+	require.Len(t, report.Channels, 2)
+	assert.Equal(t, int64(2), report.Channels["books"].DocCount)
+	assert.Equal(t, int64(2), report.Channels["gifts"].DocCount)
+	assert.Greater(t, report.Channels["gifts"].BodyBytes, report.Channels["books"].BodyBytes)
+
+	// Served from cache until refreshed
+	rt.PutDoc("doc4", `{"channels":["books"]}`)
+	cached := getReport("")
+	assert.Equal(t, report.GeneratedAt.UnixNano(), cached.GeneratedAt.UnixNano())
+	assert.Equal(t, int64(2), cached.Channels["books"].DocCount)
+
+	refreshed := getReport("?refresh=true&sample_size=1")
+	assert.Equal(t, int64(4), refreshed.TotalDocs)
+	assert.Equal(t, int64(1), refreshed.SampledDocs)
+	assert.Equal(t, int64(3), refreshed.Channels["books"].DocCount)
+
+	// Most recent report is exported to prometheus
+	collection := rt.GetSingleTestDatabaseCollection()
+	sizes := rt.GetDatabase().DbStats.ChannelSizes().Get(collection.ScopeName + "." + collection.Name)
+	assert.Equal(t, refreshed.Channels, sizes)
+
+	// Sample sizes are capped, rather than allocated for
+	unbounded := getReport("?refresh=true&sample_size=18446744073709551615")
+	assert.Equal(t, int64(4), unbounded.SampledDocs)
+}
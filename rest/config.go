@@ -13,6 +13,7 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
@@ -34,6 +35,7 @@ import (
 	sgbucket "github.com/couchbase/sg-bucket"
 	"github.com/couchbase/sync_gateway/auth"
 	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
 	"github.com/couchbase/sync_gateway/db"
 	"github.com/couchbase/sync_gateway/db/functions"
 	"github.com/couchbaselabs/rosmar"
@@ -66,6 +68,30 @@ const (
 	DefaultUseTLSServer = true
 
 	DefaultMinConfigFetchInterval = time.Second
+
+	// Default interval at which the REST API TLS cert and key files are checked for changes
+	DefaultTLSCertReloadInterval = time.Minute
+
+	// Default maximum time to wait for in-flight replication work to complete on shutdown
+	DefaultDrainTimeout = 30 * time.Second
+
+	// Default time replication clients are asked to wait before reconnecting on shutdown
+	DefaultDrainReconnectAfter = 10 * time.Second
+
+	// Default maximum time to write an entry of a continuous _changes feed before disconnecting the client
+	DefaultChangesWriteTimeout = time.Minute
+
+	// Default time to write an entry of a continuous _changes feed after which the write is slow
+	DefaultChangesSlowWriteThreshold = 5 * time.Second
+
+	// Default number of consecutive slow continuous _changes feed writes before disconnecting the client
+	DefaultChangesMaxSlowWrites = 10
+
+	// Default maximum estimated memory used by a single _bulk_docs or _bulk_get request
+	DefaultMaxBulkRequestMemory = 256 * 1024 * 1024
+
+	// Default maximum estimated memory used by all in-flight _bulk_docs and _bulk_get requests on the node
+	DefaultMaxBulkMemory = 1024 * 1024 * 1024
 )
 
 // Bucket configuration elements - used by db, index
@@ -126,39 +152,51 @@ func (bucketConfig *BucketConfig) GetCredentials() (username string, password st
 // DbConfig defines a database configuration used in a config file or the REST API.
 type DbConfig struct {
 	BucketConfig
-	Scopes                           ScopesConfig                     `json:"scopes,omitempty"`                // Scopes and collection specific config
-	Name                             string                           `json:"name,omitempty"`                  // Database name in REST API (stored as key in JSON)
-	Sync                             *string                          `json:"sync,omitempty"`                  // The sync function applied to write operations in the _default scope and collection
-	Users                            map[string]*auth.PrincipalConfig `json:"users,omitempty"`                 // Initial user accounts
-	Roles                            map[string]*auth.PrincipalConfig `json:"roles,omitempty"`                 // Initial roles
-	RevsLimit                        *uint32                          `json:"revs_limit,omitempty"`            // Max depth a document's revision tree can grow to
-	AutoImport                       interface{}                      `json:"import_docs,omitempty"`           // Whether to automatically import Couchbase Server docs into SG.  Xattrs must be enabled.  true or "continuous" both enable this.
-	ImportPartitions                 *uint16                          `json:"import_partitions,omitempty"`     // Number of partitions for import sharding.  Impacts the total DCP concurrency for import
-	ImportFilter                     *string                          `json:"import_filter,omitempty"`         // The import filter applied to import operations in the _default scope and collection
-	ImportBackupOldRev               *bool                            `json:"import_backup_old_rev,omitempty"` // Whether import should attempt to create a temporary backup of the previous revision body, when available.
-	EventHandlers                    *EventHandlerConfig              `json:"event_handlers,omitempty"`        // Event handlers (webhook)
-	FeedType                         string                           `json:"feed_type,omitempty"`             // Feed type - "DCP" or "TAP"; defaults based on Couchbase server version
-	AllowEmptyPassword               *bool                            `json:"allow_empty_password,omitempty"`  // Allow empty passwords?  Defaults to false
-	CacheConfig                      *CacheConfig                     `json:"cache,omitempty"`                 // Cache settings
-	DeprecatedRevCacheSize           *uint32                          `json:"rev_cache_size,omitempty"`        // Maximum number of revisions to store in the revision cache (deprecated, CBG-356)
-	StartOffline                     *bool                            `json:"offline,omitempty"`               // start the DB in the offline state, defaults to false
-	Unsupported                      *db.UnsupportedOptions           `json:"unsupported,omitempty"`           // Config for unsupported features
-	OIDCConfig                       *auth.OIDCOptions                `json:"oidc,omitempty"`                  // Config properties for OpenID Connect authentication
+	Scopes                           ScopesConfig                     `json:"scopes,omitempty"`                      // Scopes and collection specific config
+	Name                             string                           `json:"name,omitempty"`                        // Database name in REST API (stored as key in JSON)
+	Aliases                          []string                         `json:"aliases,omitempty"`                     // Alternative names the database is also served under in the REST API
+	Sync                             *string                          `json:"sync,omitempty"`                        // The sync function applied to write operations in the _default scope and collection
+	Users                            map[string]*auth.PrincipalConfig `json:"users,omitempty"`                       // Initial user accounts
+	Roles                            map[string]*auth.PrincipalConfig `json:"roles,omitempty"`                       // Initial roles
+	RevsLimit                        *uint32                          `json:"revs_limit,omitempty"`                  // Max depth a document's revision tree can grow to
+	AutoImport                       interface{}                      `json:"import_docs,omitempty"`                 // Whether to automatically import Couchbase Server docs into SG.  Xattrs must be enabled.  true or "continuous" both enable this.
+	ImportPartitions                 *uint16                          `json:"import_partitions,omitempty"`           // Number of partitions for import sharding.  Impacts the total DCP concurrency for import
+	ImportFilter                     *string                          `json:"import_filter,omitempty"`               // The import filter applied to import operations in the _default scope and collection
+	ImportBackupOldRev               *bool                            `json:"import_backup_old_rev,omitempty"`       // Whether import should attempt to create a temporary backup of the previous revision body, when available.
+	ImportQuarantineThreshold        *uint                            `json:"import_quarantine_threshold,omitempty"` // Number of consecutive import failures for a document before it is quarantined. 0 disables quarantine.
+	ImportBinaryDocs                 *bool                            `json:"import_binary_docs,omitempty"`          // Whether binary documents are imported as a JSON document with the binary content as an attachment.
+	EventHandlers                    *EventHandlerConfig              `json:"event_handlers,omitempty"`              // Event handlers (webhook)
+	FeedType                         string                           `json:"feed_type,omitempty"`                   // Feed type - "DCP" or "TAP"; defaults based on Couchbase server version
+	AllowEmptyPassword               *bool                            `json:"allow_empty_password,omitempty"`        // Allow empty passwords?  Defaults to false
+	CacheConfig                      *CacheConfig                     `json:"cache,omitempty"`                       // Cache settings
+	DeprecatedRevCacheSize           *uint32                          `json:"rev_cache_size,omitempty"`              // Maximum number of revisions to store in the revision cache (deprecated, CBG-356)
+	StartOffline                     *bool                            `json:"offline,omitempty"`                     // start the DB in the offline state, defaults to false
+	Unsupported                      *db.UnsupportedOptions           `json:"unsupported,omitempty"`                 // Config for unsupported features
+	OIDCConfig                       *auth.OIDCOptions                `json:"oidc,omitempty"`                        // Config properties for OpenID Connect authentication
 	LocalJWTConfig                   auth.LocalJWTConfig              `json:"local_jwt,omitempty"`
 	OldRevExpirySeconds              *uint32                          `json:"old_rev_expiry_seconds,omitempty"`               // The number of seconds before old revs are removed from CBS bucket
+	OldRevBackup                     *OldRevBackupConfig              `json:"old_rev_backup,omitempty"`                       // Storage policy for the old revision bodies backed up for delta sync and in-flight replications
 	ViewQueryTimeoutSecs             *uint32                          `json:"view_query_timeout_secs,omitempty"`              // The view query timeout in seconds
 	LocalDocExpirySecs               *uint32                          `json:"local_doc_expiry_secs,omitempty"`                // The _local doc expiry time in seconds
 	EnableXattrs                     *bool                            `json:"enable_shared_bucket_access,omitempty"`          // Whether to use extended attributes to store _sync metadata
 	SecureCookieOverride             *bool                            `json:"session_cookie_secure,omitempty"`                // Override cookie secure flag
 	SessionCookieName                string                           `json:"session_cookie_name,omitempty"`                  // Custom per-database session cookie name
 	SessionCookieHTTPOnly            *bool                            `json:"session_cookie_http_only,omitempty"`             // HTTP only cookies
+	SessionCookieKeys                []SessionCookieKeyConfig         `json:"session_cookie_keys,omitempty"`                  // If set, session cookies are encrypted, so sessions can be verified without a document lookup
 	AllowConflicts                   *bool                            `json:"allow_conflicts,omitempty"`                      // Deprecated: False forbids creating conflicts
 	NumIndexReplicas                 *uint                            `json:"num_index_replicas,omitempty"`                   // Number of GSI index replicas used for core indexes
 	UseViews                         *bool                            `json:"use_views,omitempty"`                            // Force use of views instead of GSI
 	SendWWWAuthenticateHeader        *bool                            `json:"send_www_authenticate_header,omitempty"`         // If false, disables setting of 'WWW-Authenticate' header in 401 responses. Implicitly false if disable_password_auth is true.
 	DisablePasswordAuth              *bool                            `json:"disable_password_auth,omitempty"`                // If true, disables user/pass authentication, only permitting OIDC or guest access
 	BucketOpTimeoutMs                *uint32                          `json:"bucket_op_timeout_ms,omitempty"`                 // How long bucket ops should block returning "operation timed out". If nil, uses GoCB default.  GoCB buckets only.
+	KVCircuitBreaker                 *KVCircuitBreakerConfig          `json:"kv_circuit_breaker,omitempty"`                   // If set, KV ops are rejected with a 503 while they're timing out, instead of waiting.  GoCB buckets only.
 	SlowQueryWarningThresholdMs      *uint32                          `json:"slow_query_warning_threshold,omitempty"`         // Log warnings if N1QL queries take this many ms
+	SlowChangesThresholdMs           *uint32                          `json:"slow_changes_threshold_ms,omitempty"`            // Log changes requests that take this many ms under the SlowChanges log key. 0 disables
+	MaxRevMessageHistory             *uint32                          `json:"max_rev_message_history,omitempty"`              // Maximum number of ancestor revIDs sent in the history of rev messages. 0 disables
+	MaxPendingRevs                   *int                             `json:"max_pending_revs,omitempty"`                     // Maximum number of pushed revs processed concurrently per replication. 0 disables
+	MaxPendingRevBytes               *int64                           `json:"max_pending_rev_bytes,omitempty"`                // Maximum total size of pushed revs processed concurrently per replication. 0 disables
+	DeferredAttachmentWindowSecs     *uint32                          `json:"deferred_attachment_window_secs,omitempty"`      // How long clients deferring attachments can get a revision's attachments for after acknowledging it
+	IdempotencyKeyTTLSecs            *uint32                          `json:"idempotency_key_ttl_secs,omitempty"`             // How long responses to document writes with an Idempotency-Key are replayed for retries
 	DeltaSync                        *DeltaSyncConfig                 `json:"delta_sync,omitempty"`                           // Config for delta sync
 	CompactIntervalDays              *float32                         `json:"compact_interval_days,omitempty"`                // Interval between scheduled compaction runs (in days) - 0 means don't run
 	SGReplicateEnabled               *bool                            `json:"sgreplicate_enabled,omitempty"`                  // When false, node will not be assigned replications
@@ -169,13 +207,27 @@ type DbConfig struct {
 	UserXattrKey                     string                           `json:"user_xattr_key,omitempty"`                       // Key of user xattr that will be accessible from the Sync Function. If empty the feature will be disabled.
 	ClientPartitionWindowSecs        *int                             `json:"client_partition_window_secs,omitempty"`         // How long clients can remain offline for without losing replication metadata. Default 30 days (in seconds)
 	Guest                            *auth.PrincipalConfig            `json:"guest,omitempty"`                                // Guest user settings
+	GuestChannelAllowlist            []string                         `json:"guest_channel_allowlist,omitempty"`              // If set, the guest user can only see these channels, regardless of their grants
+	SystemChannels                   []string                         `json:"system_channels,omitempty"`                      // Channels excluded from the star channel, only visible to users granted them explicitly
 	JavascriptTimeoutSecs            *uint32                          `json:"javascript_timeout_secs,omitempty"`              // The amount of seconds a Javascript function can run for. Set to 0 for no timeout.
 	GraphQL                          *functions.GraphQLConfig         `json:"graphql,omitempty"`                              // GraphQL configuration & resolver fns
 	UserFunctions                    *functions.FunctionsConfig       `json:"functions,omitempty"`                            // Named JS fns for clients to call
 	Suspendable                      *bool                            `json:"suspendable,omitempty"`                          // Allow the database to be suspended
 	ChangesRequestPlus               *bool                            `json:"changes_request_plus,omitempty"`                 // If set, is used as the default value of request_plus for non-continuous replications
+	ChangesIncludeChannels           *bool                            `json:"changes_include_channels,omitempty"`             // Allow admin changes feeds to include each document's channels, using include_channels=true
+	AttachmentPolicy                 *AttachmentPolicyConfig          `json:"attachment_policy,omitempty"`                    // Restricts the size and content type of attachments written to the database
+	ChannelHistory                   *ChannelHistoryConfig            `json:"channel_history,omitempty"`                      // Retention policy for the channel history stored in document sync metadata
+	ReplicationFilters               ReplicationFiltersConfig         `json:"replication_filters,omitempty"`                  // Named filters clients can reference by name in the subChanges filter property
 	CORS                             *auth.CORSConfig                 `json:"cors,omitempty"`                                 // Per-database CORS config
 	Logging                          *DbLoggingConfig                 `json:"logging,omitempty"`                              // Per-database Logging config
+	MaintenanceWindows               []MaintenanceWindowConfig        `json:"maintenance_windows,omitempty"`                  // Windows in which compaction, resync and cache cleanup run. If unset, they can always run
+	CheckpointMirror                 *CheckpointMirrorConfig          `json:"checkpoint_mirror,omitempty"`                    // Mirrors client checkpoints so clients can resume replicating with sister clusters
+	FenceDuplicateCheckpointWriters  *bool                            `json:"fence_duplicate_checkpoint_writers,omitempty"`   // Rejects checkpoint writes by a second replicator detected using the same client ID
+	RequestQuotas                    *RequestQuotaConfig              `json:"request_quotas,omitempty"`                       // Daily limits on the revisions each user can be sent and push
+	AccessSnapshots                  *AccessSnapshotConfig            `json:"access_snapshots,omitempty"`                     // Periodic snapshots of each user's effective access, for auditing
+	ExternalAuthorizer               *ExternalAuthorizerConfig        `json:"external_authorizer,omitempty"`                  // External authorization service consulted on changes feeds, document reads and document writes
+	StatsPersistence                 *StatsPersistenceConfig          `json:"stats_persistence,omitempty"`                    // Periodically persists counter stats, so they survive a restart
+	StartupConsistencyCheck          *StartupConsistencyCheckConfig   `json:"startup_consistency_check,omitempty"`            // Checks the sequence counter against the bucket when the database is started
 }
 
 type ScopesConfig map[string]ScopeConfig
@@ -185,8 +237,37 @@ type ScopeConfig struct {
 
 type CollectionsConfig map[string]*CollectionConfig
 type CollectionConfig struct {
-	SyncFn       *string `json:"sync,omitempty"`          // The sync function applied to write operations in this collection.
-	ImportFilter *string `json:"import_filter,omitempty"` // The import filter applied to import operations in this collection.
+	SyncFn           *string                 `json:"sync,omitempty"`              // The sync function applied to write operations in this collection.
+	ImportFilter     *string                 `json:"import_filter,omitempty"`     // The import filter applied to import operations in this collection.
+	Bucket           *string                 `json:"bucket,omitempty"`            // The bucket storing this collection, when different to the database's bucket.
+	AttachmentPolicy *AttachmentPolicyConfig `json:"attachment_policy,omitempty"` // Overrides the database's attachment policy for this collection.
+	StagedSync       *StagedSyncConfig       `json:"staged_sync,omitempty"`       // A new sync function being rolled out to a subset of this collection's writes.
+	AllowConflicts   *bool                   `json:"allow_conflicts,omitempty"`   // Overrides the database's allow_conflicts for this collection.
+	RevsLimit        *uint32                 `json:"revs_limit,omitempty"`        // Overrides the database's revs_limit for this collection.
+}
+
+// FederatedBucketName returns the name of the bucket storing the collection when it differs from the database's
+// bucket, or the empty string if the collection is stored in the database's bucket.
+func (c *CollectionConfig) FederatedBucketName(dbBucketName string) string {
+	if c == nil || c.Bucket == nil || *c.Bucket == dbBucketName {
+		return ""
+	}
+	return *c.Bucket
+}
+
+// revsLimit returns the collection's revs limit, or nil to use the database's.  When the collection's allow_conflicts
+// differs from the database's and neither sets a revs_limit, the collection uses the default for its own setting.
+func (c *CollectionConfig) revsLimit(dbConfig *DbConfig) *uint32 {
+	if c.RevsLimit != nil || c.AllowConflicts == nil || dbConfig.RevsLimit != nil {
+		return c.RevsLimit
+	}
+	if *c.AllowConflicts == *dbConfig.ConflictsAllowed() {
+		return nil
+	}
+	if *c.AllowConflicts {
+		return base.Uint32Ptr(db.DefaultRevsLimitConflicts)
+	}
+	return base.Uint32Ptr(db.DefaultRevsLimitNoConflicts)
 }
 
 type DeltaSyncConfig struct {
@@ -194,6 +275,455 @@ type DeltaSyncConfig struct {
 	RevMaxAgeSeconds *uint32 `json:"rev_max_age_seconds,omitempty"` // The number of seconds deltas for old revs are available for
 }
 
+// OldRevBackupConfig controls the backups of old revision bodies, which are kept for a limited time so that deltas can
+// be generated from them, and in-flight replications can still fetch them.  How long backups are kept is set by
+// old_rev_expiry_seconds, or delta_sync.rev_max_age_seconds when delta sync is enabled.
+type OldRevBackupConfig struct {
+	Enabled   *bool   `json:"enabled,omitempty"`     // Whether old revision bodies are backed up. Default: true
+	MaxPerDoc *uint32 `json:"max_per_doc,omitempty"` // The maximum number of revision backups kept for each document. 0 means no limit
+}
+
+// SessionCookieKeyConfig is a key that encrypts session cookies.  The first key configured encrypts new cookies, and
+// the rest decrypt cookies encrypted before a key rotation.
+type SessionCookieKeyConfig struct {
+	ID  string `json:"id"`  // Identifies the key in cookies it encrypts
+	Key string `json:"key"` // Base64 encoded 32 byte AES key
+}
+
+// toSessionCookieKeys returns the auth.SessionCookieKeys for the config, or nil if none are configured.
+func toSessionCookieKeys(configs []SessionCookieKeyConfig) ([]auth.SessionCookieKey, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	keys := make([]auth.SessionCookieKey, 0, len(configs))
+	for i, c := range configs {
+		key, err := base64.StdEncoding.DecodeString(c.Key)
+		if err != nil {
+			return nil, fmt.Errorf("session_cookie_keys[%d].key must be base64 encoded: %w", i, err)
+		}
+		keys = append(keys, auth.SessionCookieKey{ID: c.ID, Key: key})
+	}
+	if err := auth.ValidateSessionCookieKeys(keys); err != nil {
+		return nil, fmt.Errorf("session_cookie_keys is invalid: %w", err)
+	}
+	return keys, nil
+}
+
+// KVCircuitBreakerConfig enables a circuit breaker for KV operations on each of the database's collections.  Operations
+// are rejected with a 503 while the breaker is open, rather than queueing behind operations that are timing out.
+type KVCircuitBreakerConfig struct {
+	OpBudgetMs            *uint32  `json:"op_budget_ms,omitempty"`            // Maximum time a KV operation waits to start before being rejected. Defaults to bucket_op_timeout_ms
+	ErrorThresholdPercent *float64 `json:"error_threshold_percent,omitempty"` // Percentage of KV operations within the rolling window that time out or fail temporarily before the breaker opens
+	VolumeThreshold       *int     `json:"volume_threshold,omitempty"`        // Minimum number of KV operations within the rolling window before the breaker can open
+	RollingWindowMs       *uint32  `json:"rolling_window_ms,omitempty"`       // Period over which the error rate is measured
+	SleepWindowMs         *uint32  `json:"sleep_window_ms,omitempty"`         // How long the breaker stays open before allowing a trial operation
+}
+
+// toCircuitBreakerOptions returns the base.CircuitBreakerOptions for the config, or nil if not configured.  The op
+// budget defaults to the bucket op timeout.
+func (c *KVCircuitBreakerConfig) toCircuitBreakerOptions(bucketOpTimeout *time.Duration) *base.CircuitBreakerOptions {
+	if c == nil {
+		return nil
+	}
+	options := &base.CircuitBreakerOptions{
+		OpBudget: base.DefaultGocbV2OperationTimeout,
+	}
+	if bucketOpTimeout != nil {
+		options.OpBudget = *bucketOpTimeout
+	}
+	if c.OpBudgetMs != nil {
+		options.OpBudget = time.Duration(*c.OpBudgetMs) * time.Millisecond
+	}
+	if c.ErrorThresholdPercent != nil {
+		options.ErrorThresholdPercent = *c.ErrorThresholdPercent
+	}
+	if c.VolumeThreshold != nil {
+		options.VolumeThreshold = *c.VolumeThreshold
+	}
+	if c.RollingWindowMs != nil {
+		options.RollingWindow = time.Duration(*c.RollingWindowMs) * time.Millisecond
+	}
+	if c.SleepWindowMs != nil {
+		options.SleepWindow = time.Duration(*c.SleepWindowMs) * time.Millisecond
+	}
+	return options
+}
+
+// validate returns an error if the circuit breaker config is invalid.  name is the config key it was set under.
+func (c *KVCircuitBreakerConfig) validate(name string) error {
+	if c == nil {
+		return nil
+	}
+	if c.ErrorThresholdPercent != nil && (*c.ErrorThresholdPercent <= 0 || *c.ErrorThresholdPercent > 100) {
+		return fmt.Errorf(rangeValueErrorMsg, name+".error_threshold_percent", "(0-100]")
+	}
+	if c.VolumeThreshold != nil && *c.VolumeThreshold < 1 {
+		return fmt.Errorf(minValueErrorMsg, name+".volume_threshold", 1)
+	}
+	for key, value := range map[string]*uint32{"rolling_window_ms": c.RollingWindowMs, "sleep_window_ms": c.SleepWindowMs} {
+		if value != nil && *value < 1 {
+			return fmt.Errorf(minValueErrorMsg, name+"."+key, 1)
+		}
+	}
+	return nil
+}
+
+// AttachmentPolicyConfig restricts the attachments that can be written.  Content types can be matched exactly, or by
+// wildcard subtype (e.g. "image/*").
+type AttachmentPolicyConfig struct {
+	MaxSizeBytes        *int64   `json:"max_size_bytes,omitempty"`        // Maximum attachment size in bytes, 0 for no limit
+	AllowedContentTypes []string `json:"allowed_content_types,omitempty"` // If set, only attachments with a matching content type can be written
+	BlockedContentTypes []string `json:"blocked_content_types,omitempty"` // Attachments with a matching content type can't be written
+}
+
+// toAttachmentPolicy returns the db.AttachmentPolicy for the config, or nil if not configured.
+func (c *AttachmentPolicyConfig) toAttachmentPolicy() *db.AttachmentPolicy {
+	if c == nil {
+		return nil
+	}
+	policy := &db.AttachmentPolicy{
+		AllowedContentTypes: c.AllowedContentTypes,
+		BlockedContentTypes: c.BlockedContentTypes,
+	}
+	if c.MaxSizeBytes != nil {
+		policy.MaxSizeBytes = *c.MaxSizeBytes
+	}
+	return policy
+}
+
+// StagedSyncConfig rolls out a new sync function to a subset of a collection's writes.  Writes selected by doc_property
+// or percentage use the staged function in place of the collection's sync function, and every write is evaluated by
+// both so that divergence is counted before the staged function is made the collection's sync function.
+type StagedSyncConfig struct {
+	SyncFn      *string  `json:"sync,omitempty"`         // The staged sync function
+	DocProperty *string  `json:"doc_property,omitempty"` // Writes of documents with this top-level property set to true use the staged function
+	Percentage  *float64 `json:"percentage,omitempty"`   // Percentage of documents, sampled by document ID, whose writes use the staged function
+}
+
+// toStagedSyncFunction returns the db.StagedSyncFunction for the config, or nil if not configured.
+func (c *StagedSyncConfig) toStagedSyncFunction(ctx context.Context, javascriptTimeout time.Duration) *db.StagedSyncFunction {
+	if c == nil || c.SyncFn == nil {
+		return nil
+	}
+	percentage := 0.0
+	if c.Percentage != nil {
+		percentage = *c.Percentage
+	}
+	return db.NewStagedSyncFunction(ctx, *c.SyncFn, base.StringDefault(c.DocProperty, ""), percentage, javascriptTimeout)
+}
+
+// validate returns an error if the staged sync function config is invalid.  name is the config key it was set under.
+func (c *StagedSyncConfig) validate(name string, collectionSyncFn *string) error {
+	if c == nil {
+		return nil
+	}
+	if isEmpty, err := validateJavascriptFunction(c.SyncFn); err != nil {
+		return fmt.Errorf("%s sync function error: %w", name, err)
+	} else if isEmpty {
+		return fmt.Errorf("%s.sync must be set", name)
+	}
+	if collectionSyncFn == nil {
+		return fmt.Errorf("%s requires the collection's sync function to be set", name)
+	}
+	if c.Percentage != nil && (*c.Percentage < 0 || *c.Percentage > 100) {
+		return fmt.Errorf(rangeValueErrorMsg, name+".percentage", "[0-100]")
+	}
+	if c.DocProperty == nil && c.Percentage == nil {
+		return fmt.Errorf("%s requires doc_property or percentage to be set", name)
+	}
+	return nil
+}
+
+// validate returns an error if the attachment policy is invalid.  name is the config key the policy was set under.
+func (c *AttachmentPolicyConfig) validate(name string) error {
+	if c == nil {
+		return nil
+	}
+	if c.MaxSizeBytes != nil && *c.MaxSizeBytes < 0 {
+		return fmt.Errorf(minValueErrorMsg, name+".max_size_bytes", 0)
+	}
+	for _, contentTypes := range [][]string{c.AllowedContentTypes, c.BlockedContentTypes} {
+		for _, contentType := range contentTypes {
+			if !strings.Contains(contentType, "/") {
+				return fmt.Errorf("%s content type %q must be of the form type/subtype", name, contentType)
+			}
+		}
+	}
+	return nil
+}
+
+// ReplicationFiltersConfig defines named replication filters, keyed by name.
+type ReplicationFiltersConfig map[string]*ReplicationFilterConfig
+
+// ReplicationFilterConfig defines a named filter, which clients can reference by name in the filter property of
+// subChanges.  A change is replicated when it matches all of the configured criteria.
+type ReplicationFilterConfig struct {
+	Channels        []string `json:"channels,omitempty"`          // If set, only changes in these channels are replicated
+	DocIDPrefixes   []string `json:"doc_id_prefixes,omitempty"`   // If set, only documents whose IDs start with one of these prefixes are replicated
+	DocTypeProperty string   `json:"doc_type_property,omitempty"` // Document property matched against doc_types, defaults to "type"
+	DocTypes        []string `json:"doc_types,omitempty"`         // If set, only documents whose type property is one of these values are replicated
+}
+
+// toReplicationFilters returns the db.ReplicationFilters for the named filter configs.  The configs must have been
+// validated.
+func (configs ReplicationFiltersConfig) toReplicationFilters() map[string]*db.ReplicationFilter {
+	if len(configs) == 0 {
+		return nil
+	}
+	filters := make(map[string]*db.ReplicationFilter, len(configs))
+	for name, c := range configs {
+		filter := &db.ReplicationFilter{
+			DocIDPrefixes:   c.DocIDPrefixes,
+			DocTypeProperty: c.DocTypeProperty,
+		}
+		if len(c.Channels) > 0 {
+			filter.Channels, _ = channels.SetFromArray(c.Channels, channels.ExpandStar)
+		}
+		if len(c.DocTypes) > 0 {
+			filter.DocTypes = base.SetFromArray(c.DocTypes)
+		}
+		filters[name] = filter
+	}
+	return filters
+}
+
+// validate returns an error if the named filter is invalid.
+func (c *ReplicationFilterConfig) validate(name string) error {
+	if name == "" || name == base.ByChannelFilter {
+		return fmt.Errorf("replication_filters name %q is reserved", name)
+	}
+	if c == nil {
+		return fmt.Errorf("replication_filters.%s must not be null", name)
+	}
+	if c.Channels != nil {
+		if len(c.Channels) == 0 {
+			return fmt.Errorf("replication_filters.%s.channels must not be empty", name)
+		}
+		if _, err := channels.SetFromArray(c.Channels, channels.ExpandStar); err != nil {
+			return fmt.Errorf("replication_filters.%s.channels is invalid: %w", name, err)
+		}
+	}
+	for _, prefix := range c.DocIDPrefixes {
+		if prefix == "" {
+			return fmt.Errorf("replication_filters.%s.doc_id_prefixes must not contain an empty prefix", name)
+		}
+	}
+	if c.DocTypeProperty != "" && len(c.DocTypes) == 0 {
+		return fmt.Errorf("replication_filters.%s.doc_type_property requires doc_types", name)
+	}
+	return nil
+}
+
+// ChannelHistoryConfig bounds the channel history stored in a document's sync metadata, which is used to revoke access
+// to documents that have left channels.
+type ChannelHistoryConfig struct {
+	MaxEntriesPerChannel *int    `json:"max_entries_per_channel,omitempty"` // Max history entries retained per channel, older entries are merged
+	MaxAgeSeconds        *uint32 `json:"max_age_seconds,omitempty"`         // History entries that ended longer ago than this are removed, 0 to retain indefinitely
+}
+
+// toChannelHistoryOptions returns the db.ChannelHistoryOptions for the config.
+func (c *ChannelHistoryConfig) toChannelHistoryOptions() db.ChannelHistoryOptions {
+	options := db.ChannelHistoryOptions{
+		MaxEntriesPerChannel: db.DocumentHistoryMaxEntriesPerChannel,
+	}
+	if c == nil {
+		return options
+	}
+	if c.MaxEntriesPerChannel != nil {
+		options.MaxEntriesPerChannel = *c.MaxEntriesPerChannel
+	}
+	if c.MaxAgeSeconds != nil {
+		options.MaxAge = time.Duration(*c.MaxAgeSeconds) * time.Second
+	}
+	return options
+}
+
+// RequestQuotaConfig limits the number of revisions each user can be sent and push per UTC day.  Once a quota is
+// reached, further revisions are rejected with a 429 status until the next day, or delayed when throttling.
+type RequestQuotaConfig struct {
+	DailyReads  *uint64 `json:"daily_reads,omitempty"`  // Revisions a user can be sent per day. Unlimited if unset or 0
+	DailyWrites *uint64 `json:"daily_writes,omitempty"` // Revisions a user can push per day. Unlimited if unset or 0
+	ThrottleMs  *uint32 `json:"throttle_ms,omitempty"`  // If set, revisions over quota are delayed by this many milliseconds rather than rejected
+}
+
+// toRequestQuotaOptions returns the db.RequestQuotaOptions for the config.
+func (c *RequestQuotaConfig) toRequestQuotaOptions() db.RequestQuotaOptions {
+	var options db.RequestQuotaOptions
+	if c == nil {
+		return options
+	}
+	if c.DailyReads != nil {
+		options.DailyReads = *c.DailyReads
+	}
+	if c.DailyWrites != nil {
+		options.DailyWrites = *c.DailyWrites
+	}
+	if c.ThrottleMs != nil {
+		options.Throttle = time.Duration(*c.ThrottleMs) * time.Millisecond
+	}
+	return options
+}
+
+// AccessSnapshotConfig enables periodic snapshots of each user's channels and roles, so the channels a user could read
+// at a point in time can be audited later.
+type AccessSnapshotConfig struct {
+	IntervalMins  *uint32 `json:"interval_mins,omitempty"`  // How often users' access is snapshotted. Defaults to daily
+	RetentionDays *uint32 `json:"retention_days,omitempty"` // How long superseded snapshots are kept for. Kept indefinitely if unset or 0
+	Keyspace      string  `json:"keyspace,omitempty"`       // Collection in the database's bucket, as scope.collection, storing snapshots. Defaults to the metadata store
+}
+
+// toAccessSnapshotOptions returns the db.AccessSnapshotOptions for the config.
+func (c *AccessSnapshotConfig) toAccessSnapshotOptions() db.AccessSnapshotOptions {
+	var options db.AccessSnapshotOptions
+	if c == nil {
+		return options
+	}
+	options.Interval = db.DefaultAccessSnapshotInterval
+	if c.IntervalMins != nil && *c.IntervalMins > 0 {
+		options.Interval = time.Duration(*c.IntervalMins) * time.Minute
+	}
+	if c.RetentionDays != nil {
+		options.Retention = time.Duration(*c.RetentionDays) * 24 * time.Hour
+	}
+	if keyspace, ok := c.keyspace(); ok {
+		options.Keyspace = &keyspace
+	}
+	return options
+}
+
+// keyspace returns the scope and collection of the configured keyspace, or false if not set or invalid.
+func (c *AccessSnapshotConfig) keyspace() (base.ScopeAndCollectionName, bool) {
+	scope, collection, ok := strings.Cut(c.Keyspace, base.ScopeCollectionSeparator)
+	if !ok || scope == "" || collection == "" || strings.Contains(collection, base.ScopeCollectionSeparator) {
+		return base.ScopeAndCollectionName{}, false
+	}
+	return base.ScopeAndCollectionName{Scope: scope, Collection: collection}, true
+}
+
+// ExternalAuthorizerConfig configures an external authorization service, such as a policy engine, consulted for the
+// channels users can read, from the changes feed or by fetching documents, and the documents they can write.
+type ExternalAuthorizerConfig struct {
+	URL            string  `json:"url"`                       // URL that authorization requests are posted to
+	TimeoutMs      *uint32 `json:"timeout_ms,omitempty"`      // How long to wait for a decision. Defaults to 2 seconds
+	CacheTTLSecs   *uint32 `json:"cache_ttl_secs,omitempty"`  // How long decisions are cached for. Defaults to 60 seconds, 0 disables caching
+	GrantsChannels *bool   `json:"grants_channels,omitempty"` // Whether channels allowed by the service can be read without a grant from the sync function
+	FailOpen       *bool   `json:"fail_open,omitempty"`       // Whether access is allowed when the service can't make a decision. Defaults to false
+}
+
+// toExternalAuthorizerOptions returns the db.ExternalAuthorizerOptions for the config, or nil if not configured.
+func (c *ExternalAuthorizerConfig) toExternalAuthorizerOptions() *db.ExternalAuthorizerOptions {
+	if c == nil || c.URL == "" {
+		return nil
+	}
+	timeout := db.DefaultExternalAuthorizerTimeout
+	if c.TimeoutMs != nil && *c.TimeoutMs > 0 {
+		timeout = time.Duration(*c.TimeoutMs) * time.Millisecond
+	}
+	cacheTTL := db.DefaultExternalAuthorizerCacheTTL
+	if c.CacheTTLSecs != nil {
+		cacheTTL = time.Duration(*c.CacheTTLSecs) * time.Second
+	}
+	return &db.ExternalAuthorizerOptions{
+		Authorizer:     db.NewHTTPExternalAuthorizer(c.URL, timeout, cacheTTL),
+		GrantsChannels: base.BoolDefault(c.GrantsChannels, false),
+		FailOpen:       base.BoolDefault(c.FailOpen, false),
+	}
+}
+
+// StatsPersistenceConfig enables periodic persistence of the database's counter stats, so that counters continue from
+// their previous values after a restart.
+type StatsPersistenceConfig struct {
+	IntervalSecs *uint32 `json:"interval_secs,omitempty"` // How often counters are persisted. Defaults to every minute
+	NodeID       string  `json:"node_id,omitempty"`       // Identifies this node's persisted counters across restarts. Defaults to the hostname
+}
+
+// toStatsPersistenceOptions returns the db.StatsPersistenceOptions for the config.  Persistence is disabled if no node
+// ID is set and the hostname can't be determined.
+func (c *StatsPersistenceConfig) toStatsPersistenceOptions(ctx context.Context) db.StatsPersistenceOptions {
+	var options db.StatsPersistenceOptions
+	if c == nil {
+		return options
+	}
+	options.NodeID = c.NodeID
+	if options.NodeID == "" {
+		hostname, err := os.Hostname()
+		if err != nil || hostname == "" {
+			base.WarnfCtx(ctx, "Unable to determine hostname to identify persisted stats - set stats_persistence.node_id to persist stats: %v", err)
+			return options
+		}
+		options.NodeID = hostname
+	}
+	options.Interval = db.DefaultStatsPersistenceInterval
+	if c.IntervalSecs != nil && *c.IntervalSecs > 0 {
+		options.Interval = time.Duration(*c.IntervalSecs) * time.Second
+	}
+	return options
+}
+
+// StartupConsistencyCheckConfig enables a check of the sequence counter against the sequences already allocated and the
+// bucket's high seqnos when the database is started, to detect corrupted or mismatched metadata early.
+type StartupConsistencyCheckConfig struct {
+	Enabled           *bool   `json:"enabled,omitempty"`             // Defaults to true
+	Strict            *bool   `json:"strict,omitempty"`              // Keep the database offline if a severe mismatch is found. Defaults to false
+	SevereSequenceGap *uint64 `json:"severe_sequence_gap,omitempty"` // Mismatches larger than this are severe
+}
+
+// toSequenceCheckOptions returns the db.SequenceCheckOptions for the config.  The check is disabled if not configured.
+func (c *StartupConsistencyCheckConfig) toSequenceCheckOptions() db.SequenceCheckOptions {
+	if c == nil {
+		return db.SequenceCheckOptions{}
+	}
+	options := db.SequenceCheckOptions{
+		Enabled:           base.BoolDefault(c.Enabled, true),
+		Strict:            base.BoolDefault(c.Strict, false),
+		SevereSequenceGap: db.DefaultSevereSequenceGap,
+	}
+	if c.SevereSequenceGap != nil {
+		options.SevereSequenceGap = *c.SevereSequenceGap
+	}
+	return options
+}
+
+// CheckpointMirrorConfig enables mirroring of client checkpoints to sister clusters replicating the bucket with XDCR,
+// so that a client moving between clusters resumes from its last checkpoint.
+type CheckpointMirrorConfig struct {
+	ClusterID string `json:"cluster_id"` // Uniquely identifies this cluster amongst the sister clusters
+}
+
+// MaintenanceWindowConfig is a recurring window in which the database's heavy background tasks run.  Outside every
+// window, they're deferred until the next one opens.
+type MaintenanceWindowConfig struct {
+	Schedule     string `json:"schedule"`           // Cron expression (minute hour day-of-month month day-of-week) for when the window opens
+	DurationMins uint32 `json:"duration_mins"`      // How long the window stays open for
+	Timezone     string `json:"timezone,omitempty"` // IANA time zone the schedule is evaluated in. Defaults to UTC
+}
+
+// toMaintenanceWindow returns the db.MaintenanceWindow for the config.
+func (c *MaintenanceWindowConfig) toMaintenanceWindow() (*db.MaintenanceWindow, error) {
+	location := time.UTC
+	if c.Timezone != "" {
+		var err error
+		location, err = time.LoadLocation(c.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("unknown timezone %q: %w", c.Timezone, err)
+		}
+	}
+	return db.NewMaintenanceWindow(c.Schedule, time.Duration(c.DurationMins)*time.Minute, location)
+}
+
+// toMaintenanceWindows returns the db.MaintenanceWindows for the config.
+func toMaintenanceWindows(configs []MaintenanceWindowConfig) ([]*db.MaintenanceWindow, error) {
+	windows := make([]*db.MaintenanceWindow, 0, len(configs))
+	for i := range configs {
+		window, err := configs[i].toMaintenanceWindow()
+		if err != nil {
+			return nil, fmt.Errorf("maintenance_windows[%d] is invalid: %w", i, err)
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
 type DbConfigMap map[string]*DbConfig
 
 type EventHandlerConfig struct {
@@ -209,11 +739,36 @@ type EventConfig struct {
 	Filter      string                 `json:"filter,omitempty"`  // Filter function (webhook)
 	Timeout     *uint64                `json:"timeout,omitempty"` // Timeout (webhook)
 	Options     map[string]interface{} `json:"options,omitempty"` // Options can be specified per-handler, and are specific to each type.
+	Route       *EventRouteConfig      `json:"route,omitempty"`   // Restricts document_changed events to matching documents, before calling the filter function
+}
+
+// EventRouteConfig routes document_changed events to a handler by channel, collection and doc ID prefix.  Each
+// criteria that is set must match, and matches if any of its values match.
+type EventRouteConfig struct {
+	Channels      []string `json:"channels,omitempty"`        // Documents in any of these channels
+	Collections   []string `json:"collections,omitempty"`     // Documents in any of these collections, as scope.collection
+	DocIDPrefixes []string `json:"doc_id_prefixes,omitempty"` // Documents whose IDs start with any of these prefixes
+}
+
+// toEventRoute returns the db.EventRoute for the route config, or nil if no route is configured.
+func (c *EventRouteConfig) toEventRoute() *db.EventRoute {
+	if c == nil {
+		return nil
+	}
+	route := &db.EventRoute{DocIDPrefixes: c.DocIDPrefixes}
+	if len(c.Channels) > 0 {
+		route.Channels = base.SetFromArray(c.Channels)
+	}
+	if len(c.Collections) > 0 {
+		route.Collections = base.SetFromArray(c.Collections)
+	}
+	return route
 }
 
 type CacheConfig struct {
 	RevCacheConfig     *RevCacheConfig     `json:"rev_cache,omitempty"`     // Revision Cache Config Settings
 	ChannelCacheConfig *ChannelCacheConfig `json:"channel_cache,omitempty"` // Channel Cache Config Settings
+	DeltaCacheConfig   *DeltaCacheConfig   `json:"delta_cache,omitempty"`   // Delta Cache Config Settings
 	DeprecatedCacheConfig
 }
 
@@ -229,8 +784,15 @@ type DeprecatedCacheConfig struct {
 }
 
 type RevCacheConfig struct {
-	Size       *uint32 `json:"size,omitempty"`        // Maximum number of revisions to store in the revision cache
-	ShardCount *uint16 `json:"shard_count,omitempty"` // Number of shards the rev cache should be split into
+	Size           *uint32  `json:"size,omitempty"`             // Maximum number of revisions to store in the revision cache
+	ShardCount     *uint16  `json:"shard_count,omitempty"`      // Number of shards the rev cache should be split into
+	MaxMemoryBytes *int64   `json:"max_memory_bytes,omitempty"` // Maximum estimated size of the revisions in each collection's revision cache
+	PinnedDocs     []string `json:"pinned_docs,omitempty"`      // IDs of documents whose current revisions are never evicted from the revision cache
+}
+
+type DeltaCacheConfig struct {
+	ExpirySeconds  *uint32 `json:"expiry_seconds,omitempty"`   // Time (seconds) to keep generated deltas in the cache. Zero for no expiry
+	MaxMemoryBytes *int64  `json:"max_memory_bytes,omitempty"` // Maximum estimated size of the deltas in each collection's delta cache
 }
 
 type ChannelCacheConfig struct {
@@ -245,6 +807,8 @@ type ChannelCacheConfig struct {
 	MinLength            *int    `json:"min_length,omitempty"`                 // Minimum number of entries maintained in cache per channel
 	ExpirySeconds        *int    `json:"expiry_seconds,omitempty"`             // Time (seconds) to keep entries in cache beyond the minimum retained
 	DeprecatedQueryLimit *int    `json:"query_limit,omitempty"`                // Limit used for channel queries, if not specified by client DEPRECATED in favour of db.QueryPaginationLimit
+	MaxBackfillQueries   *int    `json:"max_backfill_queries,omitempty"`       // Maximum number of channel backfill queries run concurrently
+	MaxFeedBuffer        *int    `json:"max_feed_buffer,omitempty"`            // Maximum number of mutation feed events buffered for the cache before pausing the feed
 }
 
 // DbLoggingConfig allows per-database logging overrides
@@ -701,6 +1265,12 @@ func (dbConfig *DbConfig) validateVersion(ctx context.Context, isEnterpriseEditi
 			if dbConfig.CacheConfig.ChannelCacheConfig.MaxNumber != nil && *dbConfig.CacheConfig.ChannelCacheConfig.MaxNumber < db.MinimumChannelCacheMaxNumber {
 				multiError = multiError.Append(fmt.Errorf(minValueErrorMsg, "cache.channel_cache.max_number", db.MinimumChannelCacheMaxNumber))
 			}
+			if dbConfig.CacheConfig.ChannelCacheConfig.MaxBackfillQueries != nil && *dbConfig.CacheConfig.ChannelCacheConfig.MaxBackfillQueries < 1 {
+				multiError = multiError.Append(fmt.Errorf(minValueErrorMsg, "cache.channel_cache.max_backfill_queries", 1))
+			}
+			if dbConfig.CacheConfig.ChannelCacheConfig.MaxFeedBuffer != nil && *dbConfig.CacheConfig.ChannelCacheConfig.MaxFeedBuffer < 0 {
+				multiError = multiError.Append(fmt.Errorf(minValueErrorMsg, "cache.channel_cache.max_feed_buffer", 0))
+			}
 
 			// Compact watermark validation
 			hwm := db.DefaultCompactHighWatermarkPercent
@@ -736,6 +1306,60 @@ func (dbConfig *DbConfig) validateVersion(ctx context.Context, isEnterpriseEditi
 					multiError = multiError.Append(fmt.Errorf(minValueErrorMsg, "cache.rev_cache.shard_count", 1))
 				}
 			}
+
+			if dbConfig.CacheConfig.RevCacheConfig.MaxMemoryBytes != nil {
+				if *dbConfig.CacheConfig.RevCacheConfig.MaxMemoryBytes < 0 {
+					multiError = multiError.Append(fmt.Errorf(minValueErrorMsg, "cache.rev_cache.max_memory_bytes", 0))
+				}
+			}
+		}
+
+		if dbConfig.CacheConfig.DeltaCacheConfig != nil {
+			if dbConfig.CacheConfig.DeltaCacheConfig.MaxMemoryBytes != nil && *dbConfig.CacheConfig.DeltaCacheConfig.MaxMemoryBytes < 0 {
+				multiError = multiError.Append(fmt.Errorf(minValueErrorMsg, "cache.delta_cache.max_memory_bytes", 0))
+			}
+		}
+	}
+
+	if err := dbConfig.AttachmentPolicy.validate("attachment_policy"); err != nil {
+		multiError = multiError.Append(err)
+	}
+
+	if err := dbConfig.KVCircuitBreaker.validate("kv_circuit_breaker"); err != nil {
+		multiError = multiError.Append(err)
+	}
+
+	if _, err := toMaintenanceWindows(dbConfig.MaintenanceWindows); err != nil {
+		multiError = multiError.Append(err)
+	}
+
+	if _, err := toSessionCookieKeys(dbConfig.SessionCookieKeys); err != nil {
+		multiError = multiError.Append(err)
+	}
+
+	for name, filterConfig := range dbConfig.ReplicationFilters {
+		if err := filterConfig.validate(name); err != nil {
+			multiError = multiError.Append(err)
+		}
+	}
+
+	if dbConfig.CheckpointMirror != nil && dbConfig.CheckpointMirror.ClusterID == "" {
+		multiError = multiError.Append(fmt.Errorf("checkpoint_mirror.cluster_id must be set when checkpoint mirroring is enabled"))
+	}
+
+	if dbConfig.ChannelHistory != nil && dbConfig.ChannelHistory.MaxEntriesPerChannel != nil && *dbConfig.ChannelHistory.MaxEntriesPerChannel < 1 {
+		multiError = multiError.Append(fmt.Errorf(minValueErrorMsg, "channel_history.max_entries_per_channel", 1))
+	}
+
+	if dbConfig.AccessSnapshots != nil && dbConfig.AccessSnapshots.Keyspace != "" {
+		if _, ok := dbConfig.AccessSnapshots.keyspace(); !ok {
+			multiError = multiError.Append(fmt.Errorf("access_snapshots.keyspace must be in the format scope.collection"))
+		}
+	}
+
+	if dbConfig.ExternalAuthorizer != nil {
+		if authorizerURL, err := url.Parse(dbConfig.ExternalAuthorizer.URL); err != nil || (authorizerURL.Scheme != "http" && authorizerURL.Scheme != "https") {
+			multiError = multiError.Append(fmt.Errorf("external_authorizer.url must be an http or https URL"))
 		}
 	}
 
@@ -795,6 +1419,16 @@ func (dbConfig *DbConfig) validateVersion(ctx context.Context, isEnterpriseEditi
 		multiError = multiError.Append(err)
 	}
 
+	for i, alias := range dbConfig.Aliases {
+		if err := db.ValidateDatabaseName(alias); err != nil {
+			multiError = multiError.Append(fmt.Errorf("aliases: %w", err))
+		} else if alias == dbConfig.Name {
+			multiError = multiError.Append(fmt.Errorf("aliases: %q is the database's name", alias))
+		} else if base.StringSliceContains(dbConfig.Aliases[:i], alias) {
+			multiError = multiError.Append(fmt.Errorf("aliases: %q is listed more than once", alias))
+		}
+	}
+
 	if dbConfig.Unsupported != nil && dbConfig.Unsupported.WarningThresholds != nil {
 		warningThresholdXattrSize := dbConfig.Unsupported.WarningThresholds.XattrSize
 		if warningThresholdXattrSize != nil {
@@ -824,20 +1458,27 @@ func (dbConfig *DbConfig) validateVersion(ctx context.Context, isEnterpriseEditi
 		}
 	}
 
-	revsLimit := dbConfig.RevsLimit
-	if revsLimit != nil {
-		if *dbConfig.ConflictsAllowed() {
-			if *revsLimit < 20 {
-				multiError = multiError.Append(fmt.Errorf("The revs_limit (%v) value in your Sync Gateway configuration cannot be set lower than 20.", *revsLimit))
-			}
-		} else {
-			if *revsLimit <= 0 {
-				multiError = multiError.Append(fmt.Errorf("The revs_limit (%v) value in your Sync Gateway configuration must be greater than zero.", *revsLimit))
-			}
+	if dbConfig.RevsLimit != nil {
+		if err := validateRevsLimit(*dbConfig.RevsLimit, *dbConfig.ConflictsAllowed()); err != nil {
+			multiError = multiError.Append(err)
+		}
+	}
+
+	for _, channel := range dbConfig.GuestChannelAllowlist {
+		if channel == channels.UserStarChannel || !channels.IsValidChannel(channel) {
+			multiError = multiError.Append(fmt.Errorf("invalid channel %q in guest_channel_allowlist", channel))
 		}
 	}
 
-	seenIssuers := make(map[string]int)
+	for _, channel := range dbConfig.SystemChannels {
+		if channel == channels.UserStarChannel || !channels.IsValidChannel(channel) {
+			multiError = multiError.Append(fmt.Errorf("invalid channel %q in system_channels", channel))
+		}
+	}
+
+	// Providers can share an issuer when they have different client IDs, as tokens are routed by audience
+	type issuerClient struct{ issuer, clientID string }
+	seenIssuers := make(map[issuerClient]int)
 	if dbConfig.OIDCConfig != nil {
 		validProviders := len(dbConfig.OIDCConfig.Providers)
 		for name, oidc := range dbConfig.OIDCConfig.Providers {
@@ -856,7 +1497,12 @@ func (dbConfig *DbConfig) validateVersion(ctx context.Context, isEnterpriseEditi
 				validProviders--
 				continue
 			}
-			seenIssuers[oidc.Issuer]++
+			for _, mapping := range oidc.ChannelsClaimMappings {
+				if err := mapping.Validate(); err != nil {
+					multiError = multiError.Append(fmt.Errorf("invalid channels_claim_mappings for OpenID Connect provider %s: %w", name, err))
+				}
+			}
+			seenIssuers[issuerClient{oidc.Issuer, base.StringDefault(oidc.ClientID, "")}]++
 			if validateOIDCConfig {
 				_, _, err := oidc.DiscoverConfig(ctx)
 				if err != nil {
@@ -879,6 +1525,11 @@ func (dbConfig *DbConfig) validateVersion(ctx context.Context, isEnterpriseEditi
 		if len(local.Algorithms) == 0 {
 			multiError = multiError.Append(fmt.Errorf("algorithms required for Local JWT provider %s", name))
 		}
+		for _, mapping := range local.ChannelsClaimMappings {
+			if err := mapping.Validate(); err != nil {
+				multiError = multiError.Append(fmt.Errorf("invalid channels_claim_mappings for Local JWT provider %s: %w", name, err))
+			}
+		}
 		if len(local.Keys) == 0 && len(local.JWKSURI) == 0 {
 			multiError = multiError.Append(fmt.Errorf("either 'keys' or 'jwks_uri' must be specified for Local JWT provider %s", name))
 		}
@@ -914,14 +1565,14 @@ func (dbConfig *DbConfig) validateVersion(ctx context.Context, isEnterpriseEditi
 				multiError = multiError.Append(fmt.Errorf("%s: signing algorithm %q invalid or unsupported", name, algo))
 			}
 		}
-		seenIssuers[local.Issuer]++
+		seenIssuers[issuerClient{local.Issuer, base.StringDefault(local.ClientID, "")}]++
 	}
 
 	// CBG-2185: This should be an error but having duplicate configs is valid so this would be a breaking change
 	for iss, count := range seenIssuers {
 		if count > 1 {
 			// issuer names are not UD - see https://github.com/couchbase/sync_gateway/pull/5513#discussion_r856335452 for context
-			base.WarnfCtx(ctx, "Found multiple OIDC/JWT providers using the same issuer (%s) - Implicit Grant flow may use incorrect providers.", iss)
+			base.WarnfCtx(ctx, "Found multiple OIDC/JWT providers using the same issuer (%s) and client ID - Implicit Grant flow will only use the first provider by name.", iss.issuer)
 		}
 	}
 
@@ -959,6 +1610,35 @@ func (dbConfig *DbConfig) validateVersion(ctx context.Context, isEnterpriseEditi
 				} else if isEmpty {
 					collectionConfig.ImportFilter = nil
 				}
+
+				if collectionConfig.Bucket != nil {
+					if *collectionConfig.Bucket == "" {
+						multiError = multiError.Append(fmt.Errorf("collection %q bucket cannot be empty", collectionName))
+					} else if base.IsDefaultCollection(scopeName, collectionName) && collectionConfig.FederatedBucketName(dbConfig.GetBucketName()) != "" {
+						multiError = multiError.Append(fmt.Errorf("the _default collection must be stored in the database's bucket"))
+					}
+				}
+
+				if err := collectionConfig.AttachmentPolicy.validate(fmt.Sprintf("collection %q attachment_policy", collectionName)); err != nil {
+					multiError = multiError.Append(err)
+				}
+
+				if err := collectionConfig.StagedSync.validate(fmt.Sprintf("collection %q staged_sync", collectionName), collectionConfig.SyncFn); err != nil {
+					multiError = multiError.Append(err)
+				}
+
+				if collectionConfig.AllowConflicts != nil || collectionConfig.RevsLimit != nil {
+					revsLimit := collectionConfig.revsLimit(dbConfig)
+					if revsLimit == nil {
+						revsLimit = dbConfig.RevsLimit
+					}
+					allowConflicts := base.BoolDefault(collectionConfig.AllowConflicts, *dbConfig.ConflictsAllowed())
+					if revsLimit != nil {
+						if err := validateRevsLimit(*revsLimit, allowConflicts); err != nil {
+							multiError = multiError.Append(fmt.Errorf("collection %q: %w", collectionName, err))
+						}
+					}
+				}
 			}
 		}
 	}
@@ -1072,6 +1752,19 @@ func (dbConfig *DbConfig) GetCredentials() (string, string, string) {
 	return base.TransformBucketCredentials(dbConfig.Username, dbConfig.Password, *dbConfig.Bucket)
 }
 
+// validateRevsLimit returns an error if the revs limit is too low for a database or collection that does or doesn't
+// allow conflicts.
+func validateRevsLimit(revsLimit uint32, allowConflicts bool) error {
+	if allowConflicts {
+		if revsLimit < 20 {
+			return fmt.Errorf("The revs_limit (%v) value in your Sync Gateway configuration cannot be set lower than 20.", revsLimit)
+		}
+	} else if revsLimit <= 0 {
+		return fmt.Errorf("The revs_limit (%v) value in your Sync Gateway configuration must be greater than zero.", revsLimit)
+	}
+	return nil
+}
+
 func (dbConfig *DbConfig) ConflictsAllowed() *bool {
 	if dbConfig.AllowConflicts != nil {
 		return dbConfig.AllowConflicts
@@ -1115,18 +1808,23 @@ func (config *DbConfig) redactInPlace(ctx context.Context) error {
 		config.Replications[i] = config.Replications[i].Redacted(ctx)
 	}
 
+	for i := range config.SessionCookieKeys {
+		config.SessionCookieKeys[i].Key = base.RedactedStr
+	}
+
 	return nil
 }
 
 // DecodeAndSanitiseConfig will sanitise a config from an io.Reader and unmarshal it into the given config parameter.
-func DecodeAndSanitiseConfig(ctx context.Context, r io.Reader, config interface{}, disallowUnknownFields bool) (err error) {
+// startupConfig should only be set for configs read from the local filesystem, see expandEnv.
+func DecodeAndSanitiseConfig(ctx context.Context, r io.Reader, config interface{}, disallowUnknownFields, startupConfig bool) (err error) {
 	b, err := io.ReadAll(r)
 	if err != nil {
 		return err
 	}
 
 	// Expand environment variables.
-	b, err = expandEnv(ctx, b)
+	b, err = expandEnv(ctx, b, startupConfig)
 	if err != nil {
 		return err
 	}
@@ -1143,14 +1841,27 @@ func DecodeAndSanitiseConfig(ctx context.Context, r io.Reader, config interface{
 // expandEnv replaces $var or ${var} in config according to the values of the
 // current environment variables. The replacement is case-sensitive. References
 // to undefined variables will result in an error. A default value can
-// be given by using the form ${var:-default value}.
-func expandEnv(ctx context.Context, config []byte) (value []byte, err error) {
+// be given by using the form ${var:-default value}. References of the form
+// ${provider:ref} are resolved via the registered SecretsProvider of that name.
+// Providers other than env are only resolved when startupConfig is true.
+func expandEnv(ctx context.Context, config []byte, startupConfig bool) (value []byte, err error) {
 	var multiError *base.MultiError
 	val := []byte(os.Expand(string(config), func(key string) string {
 		if key == "$" {
 			base.DebugfCtx(ctx, base.KeyConfig, "Skipping environment variable expansion: %s", key)
 			return key
 		}
+		if provider, ref, ok := splitSecretReference(key); ok {
+			if !startupConfig && provider.Name() != SecretsProviderEnv {
+				multiError = multiError.Append(ErrSecretsProviderNotAllowed{provider: provider.Name(), ref: ref})
+				return ""
+			}
+			val, err := secretExpansion(ctx, provider, ref)
+			if err != nil {
+				multiError = multiError.Append(err)
+			}
+			return val
+		}
 		val, err := envDefaultExpansion(ctx, key, os.Getenv)
 		if err != nil {
 			multiError = multiError.Append(err)
@@ -1193,6 +1904,13 @@ func envDefaultExpansion(ctx context.Context, key string, getEnvFn func(string)
 func (sc *StartupConfig) SetupAndValidateLogging(ctx context.Context) (err error) {
 
 	base.SetRedaction(sc.Logging.RedactionLevel)
+	if err := base.SetUserDataHashSalt(sc.Logging.RedactionSalt); err != nil {
+		return err
+	}
+
+	if err := base.SetLogFormat(sc.Logging.Format); err != nil {
+		return err
+	}
 
 	if sc.Logging.LogFilePath == "" {
 		sc.Logging.LogFilePath = defaultLogFilePath
@@ -1223,7 +1941,8 @@ func SetMaxFileDescriptors(ctx context.Context, maxP *uint64) error {
 	return nil
 }
 
-func (sc *ServerContext) Serve(ctx context.Context, config *StartupConfig, addr string, handler http.Handler) error {
+// Serve serves handler on addr.  If clientCAs is non-nil, client certificates signed by them are verified if presented.
+func (sc *ServerContext) Serve(ctx context.Context, config *StartupConfig, addr string, handler http.Handler, clientCAs *x509.CertPool) error {
 	http2Enabled := false
 	if config.Unsupported.HTTP2 != nil && config.Unsupported.HTTP2.Enabled != nil {
 		http2Enabled = *config.Unsupported.HTTP2.Enabled
@@ -1231,12 +1950,16 @@ func (sc *ServerContext) Serve(ctx context.Context, config *StartupConfig, addr
 
 	tlsMinVersion := GetTLSVersionFromString(&config.API.HTTPS.TLSMinimumVersion)
 
+	certReloader, err := sc.getOrCreateCertReloader(ctx, config)
+	if err != nil {
+		return err
+	}
+
 	serveFn, server, err := base.ListenAndServeHTTP(
 		ctx,
 		addr,
 		config.API.MaximumConnections,
-		config.API.HTTPS.TLSCertPath,
-		config.API.HTTPS.TLSKeyPath,
+		certReloader,
 		handler,
 		config.API.ServerReadTimeout.Value(),
 		config.API.ServerWriteTimeout.Value(),
@@ -1244,6 +1967,7 @@ func (sc *ServerContext) Serve(ctx context.Context, config *StartupConfig, addr
 		config.API.IdleTimeout.Value(),
 		http2Enabled,
 		tlsMinVersion,
+		clientCAs,
 	)
 	if err != nil {
 		return err
@@ -1254,6 +1978,37 @@ func (sc *ServerContext) Serve(ctx context.Context, config *StartupConfig, addr
 	return serveFn()
 }
 
+// getOrCreateCertReloader returns the CertificateReloader shared by all REST APIs, loading the TLS cert and
+// key on first use. Returns nil when TLS isn't configured.
+func (sc *ServerContext) getOrCreateCertReloader(ctx context.Context, config *StartupConfig) (*base.CertificateReloader, error) {
+	if config.API.HTTPS.TLSCertPath == "" {
+		return nil, nil
+	}
+
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	if sc._certReloader != nil {
+		return sc._certReloader, nil
+	}
+
+	certReloader, err := base.NewCertificateReloader(config.API.HTTPS.TLSCertPath, config.API.HTTPS.TLSKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	if interval := config.API.HTTPS.TLSCertReloadInterval.Value(); interval > 0 {
+		certReloader.StartWatching(ctx, interval)
+	}
+	sc._certReloader = certReloader
+	return certReloader, nil
+}
+
+// CertReloader returns the CertificateReloader used by the REST APIs, or nil if TLS isn't enabled.
+func (sc *ServerContext) CertReloader() *base.CertificateReloader {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	return sc._certReloader
+}
+
 func (sc *ServerContext) addHTTPServer(s *http.Server) {
 	sc.lock.Lock()
 	defer sc.lock.Unlock()
@@ -1287,10 +2042,37 @@ func (sc *StartupConfig) Validate(ctx context.Context, isEnterpriseEdition bool)
 		multiError = multiError.Append(fmt.Errorf("both TLS Key Path and TLS Cert Path must be provided when using client TLS. Disable client TLS by not providing either of these options"))
 	}
 
+	for _, token := range sc.API.MetricsBearerTokens {
+		if token == "" {
+			multiError = multiError.Append(fmt.Errorf("api.metrics_bearer_tokens cannot contain an empty token"))
+			break
+		}
+	}
+
+	if sc.API.MetricsClientCACertPath != "" && sc.API.HTTPS.TLSCertPath == "" {
+		multiError = multiError.Append(fmt.Errorf("api.metrics_client_ca_cert_path requires TLS to be enabled with api.https.tls_cert_path and api.https.tls_key_path"))
+	}
+
 	if sc.Auth.BcryptCost > 0 && (sc.Auth.BcryptCost < auth.DefaultBcryptCost || sc.Auth.BcryptCost > bcrypt.MaxCost) {
 		multiError = multiError.Append(fmt.Errorf("%v: %d outside allowed range: %d-%d", auth.ErrInvalidBcryptCost, sc.Auth.BcryptCost, auth.DefaultBcryptCost, bcrypt.MaxCost))
 	}
 
+	if sc.Replicator.HeartbeatStyle != "" && sc.Replicator.HeartbeatStyle != heartbeatStyleNewline && sc.Replicator.HeartbeatStyle != heartbeatStyleComment {
+		multiError = multiError.Append(fmt.Errorf("replicator.heartbeat_style must be one of %q or %q", heartbeatStyleNewline, heartbeatStyleComment))
+	}
+
+	if sc.Replicator.MinHeartbeat != nil && sc.Replicator.MaxHeartbeat != nil && sc.Replicator.MaxHeartbeat.Value() > 0 && sc.Replicator.MinHeartbeat.Value() > sc.Replicator.MaxHeartbeat.Value() {
+		multiError = multiError.Append(fmt.Errorf("replicator.min_heartbeat must not be greater than replicator.max_heartbeat"))
+	}
+
+	if sc.Replicator.ChangesWriteTimeout.Value() < 0 || sc.Replicator.ChangesSlowWriteThreshold.Value() < 0 {
+		multiError = multiError.Append(fmt.Errorf("replicator.changes_write_timeout and replicator.changes_slow_write_threshold cannot be negative"))
+	}
+
+	if sc.Replicator.ChangesMaxSlowWrites != nil && *sc.Replicator.ChangesMaxSlowWrites < 0 {
+		multiError = multiError.Append(fmt.Errorf("replicator.changes_max_slow_writes cannot be negative"))
+	}
+
 	if len(sc.Bootstrap.ConfigGroupID) > persistentConfigGroupIDMaxLength {
 		multiError = multiError.Append(fmt.Errorf("group_id must be at most %d characters in length", persistentConfigGroupIDMaxLength))
 	}
@@ -1303,6 +2085,14 @@ func (sc *StartupConfig) Validate(ctx context.Context, isEnterpriseEdition bool)
 		}
 	}
 
+	if err := sc.API.AdminRBAC.validate(); err != nil {
+		multiError = multiError.Append(err)
+	}
+
+	if err := sc.API.RateLimit.validate(); err != nil {
+		multiError = multiError.Append(err)
+	}
+
 	if sc.IsServerless() && len(sc.BucketCredentials) == 0 {
 		multiError = multiError.Append(fmt.Errorf("at least 1 bucket must be defined in bucket_credentials when running in serverless mode"))
 	}
@@ -1864,6 +2654,11 @@ func (sc *ServerContext) addLegacyPrincipals(ctx context.Context, legacyDbUsers,
 
 // StartServer starts and runs the server with the given configuration. (This function never returns.)
 func StartServer(ctx context.Context, config *StartupConfig, sc *ServerContext) error {
+	metricsClientCAs, err := base.LoadClientCAs(ctx, config.API.MetricsClientCACertPath)
+	if err != nil {
+		return fmt.Errorf("unable to load api.metrics_client_ca_cert_path: %w", err)
+	}
+
 	if config.API.ProfileInterface != "" {
 		// runtime.MemProfileRate = 10 * 1024
 		base.InfofCtx(ctx, base.KeyAll, "Starting profile server on %s", base.UD(config.API.ProfileInterface))
@@ -1874,22 +2669,24 @@ func StartServer(ctx context.Context, config *StartupConfig, sc *ServerContext)
 
 	go sc.PostStartup()
 
+	signalServerContext.Store(sc)
+
 	base.ConsolefCtx(ctx, base.LevelInfo, base.KeyAll, "Starting metrics server on %s", config.API.MetricsInterface)
 	go func() {
-		if err := sc.Serve(ctx, config, config.API.MetricsInterface, CreateMetricHandler(sc)); err != nil {
+		if err := sc.Serve(ctx, config, config.API.MetricsInterface, CreateMetricHandler(sc), metricsClientCAs); err != nil {
 			base.ErrorfCtx(ctx, "Error serving the Metrics API: %v", err)
 		}
 	}()
 
 	base.ConsolefCtx(ctx, base.LevelInfo, base.KeyAll, "Starting admin server on %s", config.API.AdminInterface)
 	go func() {
-		if err := sc.Serve(ctx, config, config.API.AdminInterface, CreateAdminHandler(sc)); err != nil {
+		if err := sc.Serve(ctx, config, config.API.AdminInterface, CreateAdminHandler(sc), nil); err != nil {
 			base.ErrorfCtx(ctx, "Error serving the Admin API: %v", err)
 		}
 	}()
 
 	base.ConsolefCtx(ctx, base.LevelInfo, base.KeyAll, "Starting server on %s ...", config.API.PublicInterface)
-	return sc.Serve(ctx, config, config.API.PublicInterface, CreatePublicHandler(sc))
+	return sc.Serve(ctx, config, config.API.PublicInterface, CreatePublicHandler(sc), nil)
 }
 
 func sharedBucketDatabaseCheck(ctx context.Context, sc *ServerContext) (errors error) {
@@ -2004,7 +2801,8 @@ func HandleSighup(ctx context.Context) {
 
 // RegisterSignalHandler invokes functions based on the given signals:
 // - SIGHUP causes Sync Gateway to rotate log files.
-// - SIGINT or SIGTERM causes Sync Gateway to exit cleanly.
+// - SIGINT or SIGTERM causes Sync Gateway to drain replication connections and exit cleanly. A second
+// SIGINT or SIGTERM received while draining exits immediately.
 // - SIGKILL cannot be handled by the application.
 func RegisterSignalHandler(ctx context.Context) {
 	signalChannel := make(chan os.Signal, 1)
@@ -2017,6 +2815,14 @@ func RegisterSignalHandler(ctx context.Context) {
 			case syscall.SIGHUP:
 				HandleSighup(ctx)
 			default:
+				if sc := signalServerContext.Load(); sc != nil && !sc.IsDraining() {
+					go func() {
+						sc.Drain(ctx)
+						base.FlushLogBuffers()
+						os.Exit(130)
+					}()
+					continue
+				}
 				// Ensure log buffers are flushed before exiting.
 				base.FlushLogBuffers()
 				os.Exit(130) // 130 == exit code 128 + 2 (interrupt)
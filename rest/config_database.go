@@ -133,6 +133,9 @@ func DefaultDbConfig(sc *StartupConfig, useXattrs bool) *DbConfig {
 				Size:       base.Uint32Ptr(db.DefaultRevisionCacheSize),
 				ShardCount: base.Uint16Ptr(db.DefaultRevisionCacheShardCount),
 			},
+			DeltaCacheConfig: &DeltaCacheConfig{
+				ExpirySeconds: base.Uint32Ptr(uint32(db.DefaultDeltaCacheExpiry.Seconds())),
+			},
 			ChannelCacheConfig: &ChannelCacheConfig{
 				MaxNumber:            base.IntPtr(db.DefaultChannelCacheMaxNumber),
 				HighWatermarkPercent: base.IntPtr(db.DefaultCompactHighWatermarkPercent),
@@ -144,6 +147,7 @@ func DefaultDbConfig(sc *StartupConfig, useXattrs bool) *DbConfig {
 				MaxLength:            base.IntPtr(db.DefaultChannelCacheMaxLength),
 				MinLength:            base.IntPtr(db.DefaultChannelCacheMinLength),
 				ExpirySeconds:        base.IntPtr(int(db.DefaultChannelCacheAge.Seconds())),
+				MaxBackfillQueries:   base.IntPtr(db.DefaultChannelBackfillConcurrency),
 			},
 		},
 		StartOffline:                base.BoolPtr(false),
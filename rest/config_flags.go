@@ -38,6 +38,7 @@ func registerConfigFlags(config *StartupConfig, fs *flag.FlagSet) map[string]con
 		"bootstrap.x509_cert_path":          {&config.Bootstrap.X509CertPath, fs.String("bootstrap.x509_cert_path", "", "Cert path (public key) for X.509 bucket auth")},
 		"bootstrap.x509_key_path":           {&config.Bootstrap.X509KeyPath, fs.String("bootstrap.x509_key_path", "", "Key path (private key) for X.509 bucket auth")},
 		"bootstrap.use_tls_server":          {&config.Bootstrap.UseTLSServer, fs.Bool("bootstrap.use_tls_server", false, "Forces the connection to Couchbase Server to use TLS")},
+		"bootstrap.dns_refresh_interval":    {&config.Bootstrap.DNSRefreshInterval, fs.String("bootstrap.dns_refresh_interval", "", "How often to re-resolve the server's DNS and SRV records, reconnecting to Couchbase Server when they change")},
 
 		"api.public_interface":                              {&config.API.PublicInterface, fs.String("api.public_interface", "", "Network interface to bind public API to")},
 		"api.admin_interface":                               {&config.API.AdminInterface, fs.String("api.admin_interface", "", "Network interface to bind admin API to")},
@@ -46,6 +47,8 @@ func registerConfigFlags(config *StartupConfig, fs *flag.FlagSet) map[string]con
 		"api.admin_interface_authentication":                {&config.API.AdminInterfaceAuthentication, fs.Bool("api.admin_interface_authentication", false, "Whether the admin API requires authentication")},
 		"api.metrics_interface_authentication":              {&config.API.MetricsInterfaceAuthentication, fs.Bool("api.metrics_interface_authentication", false, "Whether the metrics API requires authentication")},
 		"api.enable_admin_authentication_permissions_check": {&config.API.EnableAdminAuthenticationPermissionsCheck, fs.Bool("api.enable_admin_authentication_permissions_check", false, "Whether to enable the DP permissions check feature of admin auth")},
+		"api.metrics_bearer_tokens":                         {&config.API.MetricsBearerTokens, fs.String("api.metrics_bearer_tokens", "", "Comma separated static bearer tokens that authenticate requests to the metrics API")},
+		"api.metrics_client_ca_cert_path":                   {&config.API.MetricsClientCACertPath, fs.String("api.metrics_client_ca_cert_path", "", "CA cert verifying client certificates that authenticate requests to the metrics API. Requires TLS")},
 		"api.server_read_timeout":                           {&config.API.ServerReadTimeout, fs.String("api.server_read_timeout", "", "Maximum duration.Second before timing out read of the HTTP(S) request")},
 		"api.server_write_timeout":                          {&config.API.ServerWriteTimeout, fs.String("api.server_write_timeout", "", "Maximum duration.Second before timing out write of the HTTP(S) response")},
 		"api.read_header_timeout":                           {&config.API.ReadHeaderTimeout, fs.String("api.read_header_timeout", "", "The amount of time allowed to read request headers")},
@@ -54,18 +57,28 @@ func registerConfigFlags(config *StartupConfig, fs *flag.FlagSet) map[string]con
 		"api.max_connections":                               {&config.API.MaximumConnections, fs.Uint("api.max_connections", 0, "Max # of incoming HTTP connections to accept")},
 		"api.compress_responses":                            {&config.API.CompressResponses, fs.Bool("api.compress_responses", false, "If false, disables compression of HTTP responses")},
 		"api.hide_product_version":                          {&config.API.CompressResponses, fs.Bool("api.hide_product_version", false, "Whether product versions removed from Server headers and REST API responses")},
+		"api.max_bulk_request_memory":                       {&config.API.MaxBulkRequestMemory, fs.Int("api.max_bulk_request_memory", 0, "Maximum estimated memory in bytes a single _bulk_docs or _bulk_get request can use. Set to 0 for no limit. Default: 256MiB")},
+		"api.max_bulk_memory":                               {&config.API.MaxBulkMemory, fs.Int("api.max_bulk_memory", 0, "Maximum estimated memory in bytes used by all in-flight _bulk_docs and _bulk_get requests on the node. Set to 0 for no limit. Default: 1GiB")},
+		"api.replication_only":                              {&config.API.ReplicationOnly, fs.Bool("api.replication_only", false, "Only serve replication and health check endpoints on the public API, and reject admin API requests that make changes")},
+		"api.legacy_error_format":                           {&config.API.LegacyErrorFormat, fs.Bool("api.legacy_error_format", false, "Omit the code, retryable and details fields from error responses, returning only the legacy error, reason and correlation_id fields")},
 
-		"api.https.tls_minimum_version": {&config.API.HTTPS.TLSMinimumVersion, fs.String("api.https.tls_minimum_version", "", "The minimum allowable TLS version for the REST APIs")},
-		"api.https.tls_cert_path":       {&config.API.HTTPS.TLSCertPath, fs.String("api.https.tls_cert_path", "", "The TLS cert file to use for the REST APIs")},
-		"api.https.tls_key_path":        {&config.API.HTTPS.TLSKeyPath, fs.String("api.https.tls_key_path", "", "The TLS key file to use for the REST APIs")},
+		"api.https.tls_minimum_version":      {&config.API.HTTPS.TLSMinimumVersion, fs.String("api.https.tls_minimum_version", "", "The minimum allowable TLS version for the REST APIs")},
+		"api.https.tls_cert_path":            {&config.API.HTTPS.TLSCertPath, fs.String("api.https.tls_cert_path", "", "The TLS cert file to use for the REST APIs")},
+		"api.https.tls_key_path":             {&config.API.HTTPS.TLSKeyPath, fs.String("api.https.tls_key_path", "", "The TLS key file to use for the REST APIs")},
+		"api.https.tls_cert_reload_interval": {&config.API.HTTPS.TLSCertReloadInterval, fs.String("api.https.tls_cert_reload_interval", "", "How often to check the TLS cert and key files for changes, reloading them without a restart. Set to 0 to disable. Default: 1m")},
 
 		"api.cors.origin":       {&config.API.CORS.Origin, fs.String("api.cors.origin", "", "List of comma seperated allowed origins. Use '*' to allow access from everywhere")},
 		"api.cors.login_origin": {&config.API.CORS.LoginOrigin, fs.String("api.cors.login_origin", "", "List of comma seperated allowed login origins")},
 		"api.cors.headers":      {&config.API.CORS.Headers, fs.String("api.cors.headers", "", "List of comma seperated allowed headers")},
 		"api.cors.max_age":      {&config.API.CORS.MaxAge, fs.Int("api.cors.max_age", 0, "Maximum age of the CORS Options request")},
 
+		"api.admin_rbac": {&config.API.AdminRBAC, fs.String("api.admin_rbac", "null", "JSON-encoded Sync Gateway admin roles granted to Couchbase Server users and local admin accounts")},
+		"api.rate_limit": {&config.API.RateLimit, fs.String("api.rate_limit", "null", "JSON-encoded per-client IP rate limits for the public API, by class of endpoint")},
+
 		"logging.log_file_path":   {&config.Logging.LogFilePath, fs.String("logging.log_file_path", "", "Absolute or relative path on the filesystem to the log file directory. A relative path is from the directory that contains the Sync Gateway executable file")},
 		"logging.redaction_level": {&config.Logging.RedactionLevel, fs.String("logging.redaction_level", "", "Redaction level to apply to log output. Options: none, partial, full, unset")},
+		"logging.format":          {&config.Logging.Format, fs.String("logging.format", "", "Format of log lines written to all log outputs. Options: text, json")},
+		"logging.redaction_salt":  {&config.Logging.RedactionSalt, fs.String("logging.redaction_salt", "", "Salt used to hash user data for log outputs with a redaction_profile of hash. Random if not set")},
 
 		"logging.console.enabled":                          {&config.Logging.Console.Enabled, fs.Bool("logging.console.enabled", false, "")},
 		"logging.console.rotation.max_size":                {&config.Logging.Console.Rotation.MaxSize, fs.Int("logging.console.rotation.max_size", 0, "")},
@@ -73,10 +86,12 @@ func registerConfigFlags(config *StartupConfig, fs *flag.FlagSet) map[string]con
 		"logging.console.rotation.localtime":               {&config.Logging.Console.Rotation.LocalTime, fs.Bool("logging.console.rotation.localtime", false, "")},
 		"logging.console.rotation.rotated_logs_size_limit": {&config.Logging.Console.Rotation.RotatedLogsSizeLimit, fs.Int("logging.console.rotation.rotated_logs_size_limit", 0, "")},
 		"logging.console.collation_buffer_size":            {&config.Logging.Console.CollationBufferSize, fs.Int("logging.console.collation_buffer_size", 0, "")},
+		"logging.console.redaction_profile":                {&config.Logging.Console.RedactionProfile, fs.String("logging.console.redaction_profile", "", "How user data is redacted in this output. Options: tag, hash")},
 		"logging.console.log_level":                        {&config.Logging.Console.LogLevel, fs.String("logging.console.log_level", "", "Options: none, error, warn, info, debug, trace")},
 		"logging.console.log_keys":                         {&config.Logging.Console.LogKeys, fs.String("logging.console.log_keys", "", "Comma seperated log keys")},
 		"logging.console.color_enabled":                    {&config.Logging.Console.ColorEnabled, fs.Bool("logging.console.color_enabled", false, "")},
 		"logging.console.file_output":                      {&config.Logging.Console.FileOutput, fs.String("logging.console.file_output", "", "")}, // can be used to override the default stderr output, and write to the file specified instead.
+		"logging.console.log_key_levels":                   {&config.Logging.Console.LogKeyLevels, fs.String("logging.console.log_key_levels", "", "Comma separated log level overrides for specific log keys, e.g. Sync=debug,HTTP=warn")},
 
 		"logging.error.enabled":                          {&config.Logging.Error.Enabled, fs.Bool("logging.error.enabled", false, "")},
 		"logging.error.rotation.max_size":                {&config.Logging.Error.Rotation.MaxSize, fs.Int("logging.error.rotation.max_size", 0, "")},
@@ -84,6 +99,7 @@ func registerConfigFlags(config *StartupConfig, fs *flag.FlagSet) map[string]con
 		"logging.error.rotation.localtime":               {&config.Logging.Error.Rotation.LocalTime, fs.Bool("logging.error.rotation.localtime", false, "")},
 		"logging.error.rotation.rotated_logs_size_limit": {&config.Logging.Error.Rotation.RotatedLogsSizeLimit, fs.Int("logging.error.rotation.rotated_logs_size_limit", 0, "")},
 		"logging.error.collation_buffer_size":            {&config.Logging.Error.CollationBufferSize, fs.Int("logging.error.collation_buffer_size", 0, "")},
+		"logging.error.redaction_profile":                {&config.Logging.Error.RedactionProfile, fs.String("logging.error.redaction_profile", "", "How user data is redacted in this output. Options: tag, hash")},
 
 		"logging.warn.enabled":                          {&config.Logging.Warn.Enabled, fs.Bool("logging.warn.enabled", false, "")},
 		"logging.warn.rotation.max_size":                {&config.Logging.Warn.Rotation.MaxSize, fs.Int("logging.warn.rotation.max_size", 0, "")},
@@ -91,6 +107,7 @@ func registerConfigFlags(config *StartupConfig, fs *flag.FlagSet) map[string]con
 		"logging.warn.rotation.localtime":               {&config.Logging.Warn.Rotation.LocalTime, fs.Bool("logging.warn.rotation.localtime", false, "")},
 		"logging.warn.rotation.rotated_logs_size_limit": {&config.Logging.Warn.Rotation.RotatedLogsSizeLimit, fs.Int("logging.warn.rotation.rotated_logs_size_limit", 0, "")},
 		"logging.warn.collation_buffer_size":            {&config.Logging.Warn.CollationBufferSize, fs.Int("logging.warn.collation_buffer_size", 0, "")},
+		"logging.warn.redaction_profile":                {&config.Logging.Warn.RedactionProfile, fs.String("logging.warn.redaction_profile", "", "How user data is redacted in this output. Options: tag, hash")},
 
 		"logging.info.enabled":                          {&config.Logging.Info.Enabled, fs.Bool("logging.info.enabled", false, "")},
 		"logging.info.rotation.max_size":                {&config.Logging.Info.Rotation.MaxSize, fs.Int("logging.info.rotation.max_size", 0, "")},
@@ -98,6 +115,7 @@ func registerConfigFlags(config *StartupConfig, fs *flag.FlagSet) map[string]con
 		"logging.info.rotation.localtime":               {&config.Logging.Info.Rotation.LocalTime, fs.Bool("logging.info.rotation.localtime", false, "")},
 		"logging.info.rotation.rotated_logs_size_limit": {&config.Logging.Info.Rotation.RotatedLogsSizeLimit, fs.Int("logging.info.rotation.rotated_logs_size_limit", 0, "")},
 		"logging.info.collation_buffer_size":            {&config.Logging.Info.CollationBufferSize, fs.Int("logging.info.collation_buffer_size", 0, "")},
+		"logging.info.redaction_profile":                {&config.Logging.Info.RedactionProfile, fs.String("logging.info.redaction_profile", "", "How user data is redacted in this output. Options: tag, hash")},
 
 		"logging.debug.enabled":                          {&config.Logging.Debug.Enabled, fs.Bool("logging.debug.enabled", false, "")},
 		"logging.debug.rotation.max_size":                {&config.Logging.Debug.Rotation.MaxSize, fs.Int("logging.debug.rotation.max_size", 0, "")},
@@ -105,6 +123,7 @@ func registerConfigFlags(config *StartupConfig, fs *flag.FlagSet) map[string]con
 		"logging.debug.rotation.localtime":               {&config.Logging.Debug.Rotation.LocalTime, fs.Bool("logging.debug.rotation.localtime", false, "")},
 		"logging.debug.rotation.rotated_logs_size_limit": {&config.Logging.Debug.Rotation.RotatedLogsSizeLimit, fs.Int("logging.debug.rotation.rotated_logs_size_limit", 0, "")},
 		"logging.debug.collation_buffer_size":            {&config.Logging.Debug.CollationBufferSize, fs.Int("logging.debug.collation_buffer_size", 0, "")},
+		"logging.debug.redaction_profile":                {&config.Logging.Debug.RedactionProfile, fs.String("logging.debug.redaction_profile", "", "How user data is redacted in this output. Options: tag, hash")},
 
 		"logging.trace.enabled":                          {&config.Logging.Trace.Enabled, fs.Bool("logging.trace.enabled", false, "")},
 		"logging.trace.rotation.max_size":                {&config.Logging.Trace.Rotation.MaxSize, fs.Int("logging.trace.rotation.max_size", 0, "")},
@@ -112,6 +131,7 @@ func registerConfigFlags(config *StartupConfig, fs *flag.FlagSet) map[string]con
 		"logging.trace.rotation.localtime":               {&config.Logging.Trace.Rotation.LocalTime, fs.Bool("logging.trace.rotation.localtime", false, "")},
 		"logging.trace.rotation.rotated_logs_size_limit": {&config.Logging.Trace.Rotation.RotatedLogsSizeLimit, fs.Int("logging.trace.rotation.rotated_logs_size_limit", 0, "")},
 		"logging.trace.collation_buffer_size":            {&config.Logging.Trace.CollationBufferSize, fs.Int("logging.trace.collation_buffer_size", 0, "")},
+		"logging.trace.redaction_profile":                {&config.Logging.Trace.RedactionProfile, fs.String("logging.trace.redaction_profile", "", "How user data is redacted in this output. Options: tag, hash")},
 
 		"logging.stats.enabled":                          {&config.Logging.Stats.Enabled, fs.Bool("logging.stats.enabled", false, "")},
 		"logging.stats.rotation.max_size":                {&config.Logging.Stats.Rotation.MaxSize, fs.Int("logging.stats.rotation.max_size", 0, "")},
@@ -119,12 +139,21 @@ func registerConfigFlags(config *StartupConfig, fs *flag.FlagSet) map[string]con
 		"logging.stats.rotation.localtime":               {&config.Logging.Stats.Rotation.LocalTime, fs.Bool("logging.stats.rotation.localtime", false, "")},
 		"logging.stats.rotation.rotated_logs_size_limit": {&config.Logging.Stats.Rotation.RotatedLogsSizeLimit, fs.Int("logging.stats.rotation.rotated_logs_size_limit", 0, "")},
 		"logging.stats.collation_buffer_size":            {&config.Logging.Stats.CollationBufferSize, fs.Int("logging.stats.collation_buffer_size", 0, "")},
+		"logging.stats.redaction_profile":                {&config.Logging.Stats.RedactionProfile, fs.String("logging.stats.redaction_profile", "", "How user data is redacted in this output. Options: tag, hash")},
 
 		"auth.bcrypt_cost": {&config.Auth.BcryptCost, fs.Int("auth.bcrypt_cost", 0, "Cost to use for bcrypt password hashes")},
 
-		"replicator.max_heartbeat":               {&config.Replicator.MaxHeartbeat, fs.String("replicator.max_heartbeat", "", "Max heartbeat value for _changes request")},
-		"replicator.blip_compression":            {&config.Replicator.BLIPCompression, fs.Int("replicator.blip_compression", 0, "BLIP data compression level (0-9)")},
-		"replicator.max_concurrent_replications": {&config.Replicator.MaxConcurrentReplications, fs.Int("replicator.max_concurrent_replications", 0, "Maximum number of replication connections to the node")},
+		"replicator.max_heartbeat":                {&config.Replicator.MaxHeartbeat, fs.String("replicator.max_heartbeat", "", "Max heartbeat value for _changes request")},
+		"replicator.min_heartbeat":                {&config.Replicator.MinHeartbeat, fs.String("replicator.min_heartbeat", "", "Min heartbeat value for _changes request. Default: 25s")},
+		"replicator.max_changes_timeout":          {&config.Replicator.MaxChangesTimeout, fs.String("replicator.max_changes_timeout", "", "Max timeout value for longpoll _changes request. Default: 15m")},
+		"replicator.heartbeat_style":              {&config.Replicator.HeartbeatStyle, fs.String("replicator.heartbeat_style", "", "How heartbeats are written to _changes feeds by default, either 'newline' or 'comment'. Default: newline")},
+		"replicator.blip_compression":             {&config.Replicator.BLIPCompression, fs.Int("replicator.blip_compression", 0, "BLIP data compression level (0-9)")},
+		"replicator.max_concurrent_replications":  {&config.Replicator.MaxConcurrentReplications, fs.Int("replicator.max_concurrent_replications", 0, "Maximum number of replication connections to the node")},
+		"replicator.drain_timeout":                {&config.Replicator.DrainTimeout, fs.String("replicator.drain_timeout", "", "Maximum time to wait for in-flight replication work to complete when shutting down. Set to 0 to shut down immediately. Default: 30s")},
+		"replicator.drain_reconnect_after":        {&config.Replicator.DrainReconnectAfter, fs.String("replicator.drain_reconnect_after", "", "How long replication clients are asked to wait before reconnecting when shutting down. Default: 10s")},
+		"replicator.changes_write_timeout":        {&config.Replicator.ChangesWriteTimeout, fs.String("replicator.changes_write_timeout", "", "Maximum time to write each entry of a continuous _changes feed before the client is disconnected as a slow consumer. Set to 0 for no limit. Default: 1m")},
+		"replicator.changes_slow_write_threshold": {&config.Replicator.ChangesSlowWriteThreshold, fs.String("replicator.changes_slow_write_threshold", "", "Time to write an entry of a continuous _changes feed after which the write is counted as slow. Default: 5s")},
+		"replicator.changes_max_slow_writes":      {&config.Replicator.ChangesMaxSlowWrites, fs.Int("replicator.changes_max_slow_writes", 0, "Number of consecutive slow writes to a continuous _changes feed before the client is disconnected as a slow consumer. Set to 0 for no limit. Default: 10")},
 
 		"unsupported.stats_log_frequency":                  {&config.Unsupported.StatsLogFrequency, fs.String("unsupported.stats_log_frequency", "", "How often should stats be written to stats logs")},
 		"unsupported.use_stdlib_json":                      {&config.Unsupported.UseStdlibJSON, fs.Bool("unsupported.use_stdlib_json", false, "Bypass the jsoniter package and use Go's stdlib instead")},
@@ -208,6 +237,32 @@ func fillConfigWithFlags(fs *flag.FlagSet, flags map[string]configFlag) error {
 					return
 				}
 				rval.Set(reflect.ValueOf(&ll))
+			case *base.LogFormat:
+				var lf base.LogFormat
+				err := lf.UnmarshalText([]byte(*val.flagValue.(*string)))
+				if err != nil {
+					err = fmt.Errorf("flag %s error: %w", f.Name, err)
+					errorMessages = errorMessages.Append(err)
+					return
+				}
+				*val.config.(*base.LogFormat) = lf
+			case *base.RedactionProfile:
+				var rp base.RedactionProfile
+				err := rp.UnmarshalText([]byte(*val.flagValue.(*string)))
+				if err != nil {
+					err = fmt.Errorf("flag %s error: %w", f.Name, err)
+					errorMessages = errorMessages.Append(err)
+					return
+				}
+				*val.config.(*base.RedactionProfile) = rp
+			case *map[string]base.LogLevel:
+				logKeyLevels, err := parseLogKeyLevels(*val.flagValue.(*string))
+				if err != nil {
+					err = fmt.Errorf("flag %s error: %w", f.Name, err)
+					errorMessages = errorMessages.Append(err)
+					return
+				}
+				*val.config.(*map[string]base.LogLevel) = logKeyLevels
 			case *PerDatabaseCredentialsConfig:
 				str := *val.flagValue.(*string)
 				var dbCredentials PerDatabaseCredentialsConfig
@@ -220,6 +275,30 @@ func fillConfigWithFlags(fs *flag.FlagSet, flags map[string]configFlag) error {
 					return
 				}
 				*val.config.(*PerDatabaseCredentialsConfig) = dbCredentials
+			case *AdminRBACConfig:
+				str := *val.flagValue.(*string)
+				var adminRBAC *AdminRBACConfig
+				d := base.JSONDecoder(strings.NewReader(str))
+				d.DisallowUnknownFields()
+				err := d.Decode(&adminRBAC)
+				if err != nil {
+					err = fmt.Errorf("flag %s for value %q error: %w", f.Name, str, err)
+					errorMessages = errorMessages.Append(err)
+					return
+				}
+				*val.config.(**AdminRBACConfig) = adminRBAC
+			case *RateLimitConfig:
+				str := *val.flagValue.(*string)
+				var rateLimit *RateLimitConfig
+				d := base.JSONDecoder(strings.NewReader(str))
+				d.DisallowUnknownFields()
+				err := d.Decode(&rateLimit)
+				if err != nil {
+					err = fmt.Errorf("flag %s for value %q error: %w", f.Name, str, err)
+					errorMessages = errorMessages.Append(err)
+					return
+				}
+				*val.config.(**RateLimitConfig) = rateLimit
 			case *base.PerBucketCredentialsConfig:
 				str := *val.flagValue.(*string)
 				var bucketCredentials base.PerBucketCredentialsConfig
@@ -239,3 +318,20 @@ func fillConfigWithFlags(fs *flag.FlagSet, flags map[string]configFlag) error {
 	})
 	return errorMessages.ErrorOrNil()
 }
+
+// parseLogKeyLevels parses comma separated logKey=level pairs, e.g. "Sync=debug,HTTP=warn".
+func parseLogKeyLevels(str string) (map[string]base.LogLevel, error) {
+	logKeyLevels := make(map[string]base.LogLevel)
+	for _, pair := range strings.Split(str, ",") {
+		logKey, level, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || logKey == "" {
+			return nil, fmt.Errorf("expected logKey=level but got %q", pair)
+		}
+		var logLevel base.LogLevel
+		if err := logLevel.UnmarshalText([]byte(level)); err != nil {
+			return nil, err
+		}
+		logKeyLevels[logKey] = logLevel
+	}
+	return logKeyLevels, nil
+}
@@ -44,10 +44,20 @@ func TestAllConfigFlags(t *testing.T) {
 				val = "partial"
 			case *base.LogLevel:
 				val = "trace"
+			case *base.LogFormat:
+				val = "json"
+			case *base.RedactionProfile:
+				val = "hash"
+			case *map[string]base.LogLevel:
+				val = "Sync=debug,HTTP=warn"
 			case *PerDatabaseCredentialsConfig:
 				val = `{"db1":{"password":"foo"}}`
 			case *base.PerBucketCredentialsConfig:
 				val = `{"bucket":{"password":"foo"}}`
+			case *AdminRBACConfig:
+				val = `{"local_accounts":{"admin":{"password":"foo","roles":["config-read"]}}}`
+			case *RateLimitConfig:
+				val = `{"auth":{"rate":5,"burst":10},"allowed_cidrs":["10.0.0.0/8"]}`
 			}
 			flags = append(flags, "-"+name, val)
 		case bool:
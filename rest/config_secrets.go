@@ -0,0 +1,278 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+const (
+	// Names of the built-in secrets providers, used as the prefix in ${provider:ref} config references.
+	SecretsProviderEnv   = "env"
+	SecretsProviderFile  = "file"
+	SecretsProviderVault = "vault"
+
+	// defaultVaultSecretField is the field read from a Vault secret when the reference doesn't specify one.
+	defaultVaultSecretField = "value"
+	// defaultVaultSecretTTL is how long a Vault secret is cached when Vault doesn't return a lease duration.
+	defaultVaultSecretTTL = 5 * time.Minute
+)
+
+// SecretsProvider resolves references of the form ${<name>:<ref>} found in StartupConfig into their secret
+// values. Database configs can be supplied over the admin API, so only the env provider is resolved in them;
+// other providers can read arbitrary files or secrets and are restricted to the startup config.
+//
+// Secrets are resolved once, when the config is loaded. Rotation isn't supported: a rotated secret is only
+// picked up when Sync Gateway is restarted.
+type SecretsProvider interface {
+	// Name is the prefix used to select this provider in a config reference.
+	Name() string
+	// Resolve returns the secret value for the given reference.
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+var (
+	secretsProvidersLock sync.RWMutex
+	secretsProviders     = map[string]SecretsProvider{}
+)
+
+func init() {
+	RegisterSecretsProvider(&envSecretsProvider{getEnvFn: os.Getenv})
+	RegisterSecretsProvider(&fileSecretsProvider{})
+	RegisterSecretsProvider(newVaultSecretsProviderFromEnv())
+}
+
+// RegisterSecretsProvider makes the given provider available for config interpolation, replacing any
+// existing provider registered under the same name.
+func RegisterSecretsProvider(provider SecretsProvider) {
+	secretsProvidersLock.Lock()
+	secretsProviders[provider.Name()] = provider
+	secretsProvidersLock.Unlock()
+}
+
+// getSecretsProvider returns the provider registered with the given name, or nil if none exists.
+func getSecretsProvider(name string) SecretsProvider {
+	secretsProvidersLock.RLock()
+	defer secretsProvidersLock.RUnlock()
+	return secretsProviders[name]
+}
+
+// ErrSecretUnresolved is returned when a ${provider:ref} reference can't be resolved and no default
+// value is supplied in the configuration.
+type ErrSecretUnresolved struct {
+	provider string
+	ref      string
+	err      error
+}
+
+func (e ErrSecretUnresolved) Error() string {
+	return fmt.Sprintf("unable to resolve '${%s:%s}' specified in the config without default value: %v", e.provider, e.ref, e.err)
+}
+
+func (e ErrSecretUnresolved) Unwrap() error {
+	return e.err
+}
+
+// ErrSecretsProviderNotAllowed is returned when a ${provider:ref} reference is found in a config that isn't
+// allowed to use that provider.
+type ErrSecretsProviderNotAllowed struct {
+	provider string
+	ref      string
+}
+
+func (e ErrSecretsProviderNotAllowed) Error() string {
+	return fmt.Sprintf("'${%s:%s}' specified in the config can only be used in the startup config", e.provider, e.ref)
+}
+
+// splitSecretReference splits a config reference into provider and ref if its prefix matches a registered
+// secrets provider. ok is false for plain environment variables, including ${var:-default} expansions.
+func splitSecretReference(key string) (provider SecretsProvider, ref string, ok bool) {
+	name, ref, found := strings.Cut(key, ":")
+	if !found || name == "" || strings.HasPrefix(ref, "-") {
+		return nil, "", false
+	}
+	provider = getSecretsProvider(name)
+	if provider == nil {
+		return nil, "", false
+	}
+	return provider, ref, true
+}
+
+// secretExpansion resolves a ${provider:ref} or ${provider:ref:-default} reference via the given provider.
+func secretExpansion(ctx context.Context, provider SecretsProvider, ref string) (value string, err error) {
+	ref, defaultValue, hasDefault := strings.Cut(ref, ":-")
+	value, err = provider.Resolve(ctx, ref)
+	if err == nil && value != "" {
+		base.DebugfCtx(ctx, base.KeyConfig, "Replacing config reference '${%s:%s}'", provider.Name(), base.MD(ref))
+		return value, nil
+	}
+	if hasDefault {
+		base.DebugfCtx(ctx, base.KeyConfig, "Replacing config reference '${%s:%s}' with default value specified", provider.Name(), base.MD(ref))
+		return defaultValue, nil
+	}
+	if err == nil {
+		err = fmt.Errorf("empty value")
+	}
+	return "", ErrSecretUnresolved{provider: provider.Name(), ref: ref, err: err}
+}
+
+// envSecretsProvider resolves ${env:VAR} from the process environment.
+type envSecretsProvider struct {
+	getEnvFn func(string) string
+}
+
+func (p *envSecretsProvider) Name() string { return SecretsProviderEnv }
+
+func (p *envSecretsProvider) Resolve(_ context.Context, ref string) (string, error) {
+	value := p.getEnvFn(ref)
+	if value == "" {
+		return "", fmt.Errorf("environment variable %q is not set", ref)
+	}
+	return value, nil
+}
+
+// fileSecretsProvider resolves ${file:/path/to/secret} to the contents of the file, with any trailing
+// newline removed. This is the format used by Docker and Kubernetes mounted secrets.
+type fileSecretsProvider struct{}
+
+func (p *fileSecretsProvider) Name() string { return SecretsProviderFile }
+
+func (p *fileSecretsProvider) Resolve(_ context.Context, ref string) (string, error) {
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// vaultSecretsProvider resolves ${vault:<path>#<field>} by reading a secret from HashiCorp Vault's HTTP
+// API. Both KV v1 and KV v2 (secret/data/...) response formats are supported. When no field is given,
+// the "value" field is used. Secrets are cached for their lease duration.
+type vaultSecretsProvider struct {
+	address   string
+	token     string
+	namespace string
+	client    *http.Client
+
+	cacheLock sync.Mutex
+	cache     map[string]vaultCachedSecret
+}
+
+type vaultCachedSecret struct {
+	data    map[string]interface{}
+	expires time.Time
+}
+
+// newVaultSecretsProviderFromEnv creates a Vault provider configured via the standard VAULT_ADDR,
+// VAULT_TOKEN and VAULT_NAMESPACE environment variables.
+func newVaultSecretsProviderFromEnv() *vaultSecretsProvider {
+	return newVaultSecretsProvider(os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN"), os.Getenv("VAULT_NAMESPACE"),
+		os.Getenv("VAULT_SKIP_VERIFY") == "true")
+}
+
+func newVaultSecretsProvider(address, token, namespace string, insecureSkipVerify bool) *vaultSecretsProvider {
+	return &vaultSecretsProvider{
+		address:   strings.TrimSuffix(address, "/"),
+		token:     token,
+		namespace: namespace,
+		client:    base.GetHttpClient(insecureSkipVerify),
+		cache:     make(map[string]vaultCachedSecret),
+	}
+}
+
+func (p *vaultSecretsProvider) Name() string { return SecretsProviderVault }
+
+func (p *vaultSecretsProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	if p.address == "" {
+		return "", fmt.Errorf("VAULT_ADDR is not set")
+	}
+
+	path, field, _ := strings.Cut(ref, "#")
+	if field == "" {
+		field = defaultVaultSecretField
+	}
+
+	data, err := p.readSecret(ctx, strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("field %q not found in vault secret %q", field, path)
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	default:
+		return fmt.Sprintf("%v", v), nil
+	}
+}
+
+// readSecret returns the key/value data stored at path, using the cached copy if it hasn't expired.
+func (p *vaultSecretsProvider) readSecret(ctx context.Context, path string) (map[string]interface{}, error) {
+	p.cacheLock.Lock()
+	cached, ok := p.cache[path]
+	p.cacheLock.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.address+"/v1/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned status %d reading secret %q", resp.StatusCode, path)
+	}
+
+	var body struct {
+		LeaseDuration int                    `json:"lease_duration"`
+		Data          map[string]interface{} `json:"data"`
+	}
+	if err := base.JSONDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	data := body.Data
+	// KV v2 nests the secret's key/values under data.data alongside its metadata.
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = nested
+		}
+	}
+
+	ttl := defaultVaultSecretTTL
+	if body.LeaseDuration > 0 {
+		ttl = time.Duration(body.LeaseDuration) * time.Second
+	}
+	p.cacheLock.Lock()
+	p.cache[path] = vaultCachedSecret{data: data, expires: time.Now().Add(ttl)}
+	p.cacheLock.Unlock()
+
+	return data, nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandEnvSecretsProviders(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(secretFile, []byte("filepassword\n"), 0600))
+
+	t.Setenv("SG_TEST_SECRET_USERNAME", "envuser")
+
+	tests := []struct {
+		name          string
+		input         string
+		expected      string
+		expectedError bool
+	}{
+		{
+			name:     "env provider",
+			input:    `{"username": "${env:SG_TEST_SECRET_USERNAME}"}`,
+			expected: `{"username": "envuser"}`,
+		},
+		{
+			name:     "file provider",
+			input:    `{"password": "${file:` + secretFile + `}"}`,
+			expected: `{"password": "filepassword"}`,
+		},
+		{
+			name:     "env provider default",
+			input:    `{"username": "${env:SG_TEST_SECRET_UNSET:-fallback}"}`,
+			expected: `{"username": "fallback"}`,
+		},
+		{
+			name:          "env provider undefined",
+			input:         `{"username": "${env:SG_TEST_SECRET_UNSET}"}`,
+			expected:      `{"username": ""}`,
+			expectedError: true,
+		},
+		{
+			name:          "file provider missing",
+			input:         `{"password": "${file:` + secretFile + `.missing}"}`,
+			expected:      `{"password": ""}`,
+			expectedError: true,
+		},
+		{
+			name:     "plain env var default unaffected",
+			input:    `{"username": "${env:-plain}"}`,
+			expected: `{"username": "plain"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := expandEnv(base.TestCtx(t), []byte(test.input), true)
+			if test.expectedError {
+				var multiErr *base.MultiError
+				require.True(t, errors.As(err, &multiErr), "expected MultiError, got %v", err)
+				require.Len(t, multiErr.Errors, 1)
+				var unresolvedErr ErrSecretUnresolved
+				require.True(t, errors.As(multiErr.Errors[0], &unresolvedErr), "expected ErrSecretUnresolved, got %v", err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, test.expected, string(actual))
+		})
+	}
+}
+
+// TestExpandEnvSecretsProvidersDbConfig ensures only the env provider can be used outside of the startup config.
+func TestExpandEnvSecretsProvidersDbConfig(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(secretFile, []byte("filepassword\n"), 0600))
+
+	t.Setenv("SG_TEST_SECRET_USERNAME", "envuser")
+
+	actual, err := expandEnv(base.TestCtx(t), []byte(`{"username": "${env:SG_TEST_SECRET_USERNAME}"}`), false)
+	require.NoError(t, err)
+	assert.Equal(t, `{"username": "envuser"}`, string(actual))
+
+	for _, input := range []string{
+		`{"password": "${file:` + secretFile + `}"}`,
+		`{"password": "${file:` + secretFile + `:-fallback}"}`,
+		`{"password": "${vault:secret/data/sg#password}"}`,
+	} {
+		actual, err := expandEnv(base.TestCtx(t), []byte(input), false)
+		var multiErr *base.MultiError
+		require.True(t, errors.As(err, &multiErr), "expected MultiError, got %v", err)
+		require.Len(t, multiErr.Errors, 1)
+		var notAllowedErr ErrSecretsProviderNotAllowed
+		require.True(t, errors.As(multiErr.Errors[0], &notAllowedErr), "expected ErrSecretsProviderNotAllowed, got %v", err)
+		assert.Equal(t, `{"password": ""}`, string(actual))
+	}
+}
+
+// TestDbConfigFileSecretRejected ensures a database config sent over the admin API can't read local files.
+func TestDbConfigFileSecretRejected(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(secretFile, []byte("filepassword\n"), 0600))
+
+	rt := NewRestTesterDefaultCollection(t, &RestTesterConfig{PersistentConfig: true})
+	defer rt.Close()
+
+	dbc := rt.NewDbConfig()
+	dbc.Sync = base.StringPtr("function(doc) {channel('${file:" + secretFile + "}');}")
+
+	resp := rt.CreateDatabase("db", dbc)
+	assert.GreaterOrEqual(t, resp.Code, http.StatusBadRequest)
+	assert.NotContains(t, resp.Body.String(), "filepassword")
+
+	resp = rt.SendAdminRequest(http.MethodGet, "/db/_config", "")
+	assert.NotContains(t, resp.Body.String(), "filepassword")
+}
+
+func TestVaultSecretsProvider(t *testing.T) {
+	var requestCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requestCount, 1)
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/sg":
+			_, _ = w.Write([]byte(`{"lease_duration": 0, "data": {"data": {"password": "kv2pass"}, "metadata": {"version": 1}}}`))
+		case "/v1/kv/sg":
+			_, _ = w.Write([]byte(`{"lease_duration": 60, "data": {"value": "kv1pass"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := base.TestCtx(t)
+	provider := newVaultSecretsProvider(server.URL, "s.token", "", false)
+
+	value, err := provider.Resolve(ctx, "secret/data/sg#password")
+	require.NoError(t, err)
+	assert.Equal(t, "kv2pass", value)
+
+	value, err = provider.Resolve(ctx, "kv/sg")
+	require.NoError(t, err)
+	assert.Equal(t, "kv1pass", value)
+
+	// Cached values shouldn't trigger another request
+	_, err = provider.Resolve(ctx, "kv/sg")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requestCount))
+
+	_, err = provider.Resolve(ctx, "secret/data/sg#missing")
+	assert.Error(t, err)
+
+	_, err = provider.Resolve(ctx, "secret/data/unknown")
+	assert.Error(t, err)
+
+	_, err = newVaultSecretsProvider("", "", "", false).Resolve(ctx, "kv/sg")
+	assert.Error(t, err)
+}
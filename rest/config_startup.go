@@ -42,7 +42,8 @@ func DefaultStartupConfig(defaultLogFilePath string) StartupConfig {
 			MaximumConnections: DefaultMaxIncomingConnections,
 			CompressResponses:  base.BoolPtr(true),
 			HTTPS: HTTPSConfig{
-				TLSMinimumVersion: "tlsv1.2",
+				TLSMinimumVersion:     "tlsv1.2",
+				TLSCertReloadInterval: base.NewConfigDuration(DefaultTLSCertReloadInterval),
 			},
 			ReadHeaderTimeout:                         base.NewConfigDuration(base.DefaultReadHeaderTimeout),
 			IdleTimeout:                               base.NewConfigDuration(base.DefaultIdleTimeout),
@@ -57,6 +58,13 @@ func DefaultStartupConfig(defaultLogFilePath string) StartupConfig {
 		Auth: AuthConfig{
 			BcryptCost: auth.DefaultBcryptCost,
 		},
+		Replicator: ReplicatorConfig{
+			DrainTimeout:              base.NewConfigDuration(DefaultDrainTimeout),
+			DrainReconnectAfter:       base.NewConfigDuration(DefaultDrainReconnectAfter),
+			ChangesWriteTimeout:       base.NewConfigDuration(DefaultChangesWriteTimeout),
+			ChangesSlowWriteThreshold: base.NewConfigDuration(DefaultChangesSlowWriteThreshold),
+			ChangesMaxSlowWrites:      base.IntPtr(DefaultChangesMaxSlowWrites),
+		},
 		Unsupported: UnsupportedConfig{
 			StatsLogFrequency: base.NewConfigDuration(time.Minute),
 			Serverless: ServerlessConfig{
@@ -99,6 +107,7 @@ type BootstrapConfig struct {
 	X509CertPath          string               `json:"x509_cert_path,omitempty"          help:"Cert path (public key) for X.509 bucket auth"`
 	X509KeyPath           string               `json:"x509_key_path,omitempty"           help:"Key path (private key) for X.509 bucket auth"`
 	UseTLSServer          *bool                `json:"use_tls_server,omitempty"          help:"Enforces a secure or non-secure server scheme"`
+	DNSRefreshInterval    *base.ConfigDuration `json:"dns_refresh_interval,omitempty"    help:"How often to re-resolve the server's DNS and SRV records, reconnecting to Couchbase Server when they change. Default: disabled"`
 }
 
 type APIConfig struct {
@@ -111,6 +120,9 @@ type APIConfig struct {
 	MetricsInterfaceAuthentication            *bool `json:"metrics_interface_authentication,omitempty" help:"Whether the metrics API requires authentication"`
 	EnableAdminAuthenticationPermissionsCheck *bool `json:"enable_advanced_auth_dp,omitempty" help:"Whether to enable the DP permissions check feature of admin auth"`
 
+	MetricsBearerTokens     []string `json:"metrics_bearer_tokens,omitempty"       help:"Static bearer tokens that authenticate requests to the metrics API"`
+	MetricsClientCACertPath string   `json:"metrics_client_ca_cert_path,omitempty" help:"CA cert verifying client certificates that authenticate requests to the metrics API. Requires TLS"`
+
 	ServerReadTimeout  *base.ConfigDuration `json:"server_read_timeout,omitempty"  help:"Maximum duration.Second before timing out read of the HTTP(S) request"`
 	ServerWriteTimeout *base.ConfigDuration `json:"server_write_timeout,omitempty" help:"Maximum duration.Second before timing out write of the HTTP(S) response"`
 	ReadHeaderTimeout  *base.ConfigDuration `json:"read_header_timeout,omitempty"  help:"The amount of time allowed to read request headers"`
@@ -120,15 +132,30 @@ type APIConfig struct {
 	MaximumConnections uint  `json:"max_connections,omitempty"      help:"Max # of incoming HTTP connections to accept"`
 	CompressResponses  *bool `json:"compress_responses,omitempty"   help:"If false, disables compression of HTTP responses"`
 	HideProductVersion *bool `json:"hide_product_version,omitempty" help:"Whether product versions removed from Server headers and REST API responses"`
+	ReplicationOnly    *bool `json:"replication_only,omitempty"     help:"Only serve replication and health check endpoints on the public API, and reject admin API requests that make changes"`
+	LegacyErrorFormat  *bool `json:"legacy_error_format,omitempty"  help:"Omit the code, retryable and details fields from error responses, returning only the legacy error, reason and correlation_id fields"`
+
+	MaxBulkRequestMemory *int `json:"max_bulk_request_memory,omitempty" help:"Maximum estimated memory in bytes a single _bulk_docs or _bulk_get request can use. Set to 0 for no limit. Default: 256MiB"`
+	MaxBulkMemory        *int `json:"max_bulk_memory,omitempty"         help:"Maximum estimated memory in bytes used by all in-flight _bulk_docs and _bulk_get requests on the node. Set to 0 for no limit. Default: 1GiB"`
 
 	HTTPS HTTPSConfig      `json:"https,omitempty"`
 	CORS  *auth.CORSConfig `json:"cors,omitempty"`
+
+	AdminRBAC *AdminRBACConfig `json:"admin_rbac,omitempty" help:"Sync Gateway admin roles granted to Couchbase Server users and local admin accounts"`
+	RateLimit *RateLimitConfig `json:"rate_limit,omitempty" help:"Per-client IP rate limits for the public API, by class of endpoint"`
+}
+
+// replicationOnly returns true if Sync Gateway is running as a passive replication target, with a restricted API.
+func (c *APIConfig) replicationOnly() bool {
+	return base.BoolDefault(c.ReplicationOnly, false)
 }
 
 type HTTPSConfig struct {
 	TLSMinimumVersion string `json:"tls_minimum_version,omitempty" help:"The minimum allowable TLS version for the REST APIs"`
 	TLSCertPath       string `json:"tls_cert_path,omitempty"       help:"The TLS cert file to use for the REST APIs"`
 	TLSKeyPath        string `json:"tls_key_path,omitempty"        help:"The TLS key file to use for the REST APIs"`
+
+	TLSCertReloadInterval *base.ConfigDuration `json:"tls_cert_reload_interval,omitempty" help:"How often to check the TLS cert and key files for changes, reloading them without a restart. Set to 0 to disable. Default: 1m"`
 }
 
 type AuthConfig struct {
@@ -137,8 +164,16 @@ type AuthConfig struct {
 
 type ReplicatorConfig struct {
 	MaxHeartbeat              *base.ConfigDuration `json:"max_heartbeat,omitempty"    help:"Max heartbeat value for _changes request"`
+	MinHeartbeat              *base.ConfigDuration `json:"min_heartbeat,omitempty"    help:"Min heartbeat value for _changes request. Default: 25s"`
+	MaxChangesTimeout         *base.ConfigDuration `json:"max_changes_timeout,omitempty" help:"Max timeout value for longpoll _changes request. Default: 15m"`
+	HeartbeatStyle            string               `json:"heartbeat_style,omitempty"  help:"How heartbeats are written to _changes feeds by default, either 'newline' or 'comment'. Default: newline"`
 	BLIPCompression           *int                 `json:"blip_compression,omitempty" help:"BLIP data compression level (0-9)"`
 	MaxConcurrentReplications int                  `json:"max_concurrent_replications,omitempty" help:"Maximum number of replication connections to the node"`
+	DrainTimeout              *base.ConfigDuration `json:"drain_timeout,omitempty" help:"Maximum time to wait for in-flight replication work to complete when shutting down. Set to 0 to shut down immediately. Default: 30s"`
+	DrainReconnectAfter       *base.ConfigDuration `json:"drain_reconnect_after,omitempty" help:"How long replication clients are asked to wait before reconnecting when shutting down. Default: 10s"`
+	ChangesWriteTimeout       *base.ConfigDuration `json:"changes_write_timeout,omitempty" help:"Maximum time to write each entry of a continuous _changes feed before the client is disconnected as a slow consumer. Set to 0 for no limit. Default: 1m"`
+	ChangesSlowWriteThreshold *base.ConfigDuration `json:"changes_slow_write_threshold,omitempty" help:"Time to write an entry of a continuous _changes feed after which the write is counted as slow. Default: 5s"`
+	ChangesMaxSlowWrites      *int                 `json:"changes_max_slow_writes,omitempty" help:"Number of consecutive slow writes to a continuous _changes feed before the client is disconnected as a slow consumer. Set to 0 for no limit. Default: 10"`
 }
 
 type UnsupportedConfig struct {
@@ -179,6 +214,10 @@ func (sc *StartupConfig) Redacted() (*StartupConfig, error) {
 		config.Bootstrap.Password = base.RedactedStr
 	}
 
+	if config.Logging.RedactionSalt != "" {
+		config.Logging.RedactionSalt = base.RedactedStr
+	}
+
 	for _, credentialsConfig := range config.DatabaseCredentials {
 		if credentialsConfig != nil && credentialsConfig.Password != "" {
 			credentialsConfig.Password = base.RedactedStr
@@ -191,6 +230,18 @@ func (sc *StartupConfig) Redacted() (*StartupConfig, error) {
 		}
 	}
 
+	for i := range config.API.MetricsBearerTokens {
+		config.API.MetricsBearerTokens[i] = base.RedactedStr
+	}
+
+	if config.API.AdminRBAC != nil {
+		for _, account := range config.API.AdminRBAC.LocalAccounts {
+			if account != nil && account.Password != "" {
+				account.Password = base.RedactedStr
+			}
+		}
+	}
+
 	return &config, nil
 }
 
@@ -207,7 +258,7 @@ func LoadStartupConfigFromPath(ctx context.Context, path string) (*StartupConfig
 	defer func() { _ = rc.Close() }()
 
 	var sc StartupConfig
-	err = DecodeAndSanitiseConfig(ctx, rc, &sc, true)
+	err = DecodeAndSanitiseConfig(ctx, rc, &sc, true, true)
 	return &sc, err
 }
 
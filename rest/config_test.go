@@ -29,6 +29,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/square/go-jose.v2"
@@ -1283,7 +1284,7 @@ func TestExpandEnv(t *testing.T) {
 				require.Equal(t, v, value, "Unexpected value set for environment variable %q", k)
 			}
 			// Check environment variable substitutions.
-			actualConfig, err := expandEnv(base.TestCtx(t), test.inputConfig)
+			actualConfig, err := expandEnv(base.TestCtx(t), test.inputConfig, true)
 			if test.expectedError != nil {
 				errs, ok := err.(*base.MultiError)
 				require.True(t, ok)
@@ -2665,6 +2666,95 @@ func TestCollectionsValidation(t *testing.T) {
 			},
 			expectedError: nil,
 		},
+		{
+			name: "federated collection",
+			dbConfig: DbConfig{
+				Name: "db",
+				Scopes: ScopesConfig{
+					"fooScope": ScopeConfig{
+						map[string]*CollectionConfig{
+							"fooCollection": {Bucket: base.StringPtr("legacy")},
+						},
+					},
+				},
+			},
+			expectedError: nil,
+		},
+		{
+			name: "federated collection empty bucket",
+			dbConfig: DbConfig{
+				Name: "db",
+				Scopes: ScopesConfig{
+					"fooScope": ScopeConfig{
+						map[string]*CollectionConfig{
+							"fooCollection": {Bucket: base.StringPtr("")},
+						},
+					},
+				},
+			},
+			expectedError: base.StringPtr("bucket cannot be empty"),
+		},
+		{
+			name: "federated default collection",
+			dbConfig: DbConfig{
+				Name: "db",
+				Scopes: ScopesConfig{
+					base.DefaultScope: ScopeConfig{
+						map[string]*CollectionConfig{
+							base.DefaultCollection: {Bucket: base.StringPtr("legacy")},
+						},
+					},
+				},
+			},
+			expectedError: base.StringPtr("_default collection must be stored in the database's bucket"),
+		},
+		{
+			name: "collection allows conflicts with database revs_limit",
+			dbConfig: DbConfig{
+				Name:           "db",
+				AllowConflicts: base.BoolPtr(false),
+				RevsLimit:      base.Uint32Ptr(10),
+				Scopes: ScopesConfig{
+					"fooScope": ScopeConfig{
+						map[string]*CollectionConfig{
+							"fooCollection": {AllowConflicts: base.BoolPtr(true)},
+						},
+					},
+				},
+			},
+			expectedError: base.StringPtr(`collection "fooCollection": The revs_limit (10) value in your Sync Gateway configuration cannot be set lower than 20.`),
+		},
+		{
+			name: "collection allows conflicts with collection revs_limit",
+			dbConfig: DbConfig{
+				Name:           "db",
+				AllowConflicts: base.BoolPtr(false),
+				RevsLimit:      base.Uint32Ptr(10),
+				Scopes: ScopesConfig{
+					"fooScope": ScopeConfig{
+						map[string]*CollectionConfig{
+							"fooCollection": {AllowConflicts: base.BoolPtr(true), RevsLimit: base.Uint32Ptr(20)},
+						},
+					},
+				},
+			},
+			expectedError: nil,
+		},
+		{
+			name: "collection zero revs_limit",
+			dbConfig: DbConfig{
+				Name:           "db",
+				AllowConflicts: base.BoolPtr(false),
+				Scopes: ScopesConfig{
+					"fooScope": ScopeConfig{
+						map[string]*CollectionConfig{
+							"fooCollection": {RevsLimit: base.Uint32Ptr(0)},
+						},
+					},
+				},
+			},
+			expectedError: base.StringPtr("must be greater than zero"),
+		},
 	}
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
@@ -2681,6 +2771,42 @@ func TestCollectionsValidation(t *testing.T) {
 	}
 }
 
+func TestCollectionConfigRevsLimit(t *testing.T) {
+	dbConfig := &DbConfig{AllowConflicts: base.BoolPtr(false)}
+
+	// Collections with the database's allow_conflicts setting use the database's revs limit
+	assert.Nil(t, (&CollectionConfig{}).revsLimit(dbConfig))
+	assert.Nil(t, (&CollectionConfig{AllowConflicts: base.BoolPtr(false)}).revsLimit(dbConfig))
+
+	// Collections with a different setting use the default for their own setting, unless a revs limit is configured
+	assert.Equal(t, base.Uint32Ptr(db.DefaultRevsLimitConflicts), (&CollectionConfig{AllowConflicts: base.BoolPtr(true)}).revsLimit(dbConfig))
+	assert.Equal(t, base.Uint32Ptr(30), (&CollectionConfig{AllowConflicts: base.BoolPtr(true), RevsLimit: base.Uint32Ptr(30)}).revsLimit(dbConfig))
+	dbConfig.RevsLimit = base.Uint32Ptr(40)
+	assert.Nil(t, (&CollectionConfig{AllowConflicts: base.BoolPtr(true)}).revsLimit(dbConfig))
+
+	dbConfig = &DbConfig{AllowConflicts: base.BoolPtr(true)}
+	assert.Equal(t, base.Uint32Ptr(db.DefaultRevsLimitNoConflicts), (&CollectionConfig{AllowConflicts: base.BoolPtr(false)}).revsLimit(dbConfig))
+}
+
+func TestAccessSnapshotConfig(t *testing.T) {
+	var nilConfig *AccessSnapshotConfig
+	assert.Equal(t, db.AccessSnapshotOptions{}, nilConfig.toAccessSnapshotOptions())
+
+	// Snapshots default to daily, stored in the metadata store and kept indefinitely
+	options := (&AccessSnapshotConfig{}).toAccessSnapshotOptions()
+	assert.Equal(t, db.AccessSnapshotOptions{Interval: db.DefaultAccessSnapshotInterval}, options)
+
+	options = (&AccessSnapshotConfig{IntervalMins: base.Uint32Ptr(30), RetentionDays: base.Uint32Ptr(7), Keyspace: "audit.access"}).toAccessSnapshotOptions()
+	assert.Equal(t, 30*time.Minute, options.Interval)
+	assert.Equal(t, 7*24*time.Hour, options.Retention)
+	assert.Equal(t, &base.ScopeAndCollectionName{Scope: "audit", Collection: "access"}, options.Keyspace)
+
+	for _, keyspace := range []string{"access", "audit.", ".access", "bucket.audit.access"} {
+		_, ok := (&AccessSnapshotConfig{Keyspace: keyspace}).keyspace()
+		assert.False(t, ok, "keyspace %q should be invalid", keyspace)
+	}
+}
+
 // This function allows for error checking on both x509.UnknownAuthorityError non-x509.UnknownAuthorityError types as we switch on the expected error type
 // We get OS specific errors on x509.UnknownAuthorityError so we switch the expected error string if on darwin OS
 func requireErrorWithX509UnknownAuthority(t testing.TB, actual, expected error) {
@@ -2712,3 +2838,65 @@ func TestDatabaseConfigDropScopes(t *testing.T) {
 	require.Contains(t, resp.Body.String(), "cannot change scope")
 
 }
+
+func TestReplicationFiltersConfigValidation(t *testing.T) {
+	testCases := []struct {
+		name          string
+		filters       ReplicationFiltersConfig
+		expectedError string
+	}{
+		{
+			name: "valid",
+			filters: ReplicationFiltersConfig{
+				"orders": {Channels: []string{"store-1"}, DocIDPrefixes: []string{"order:"}, DocTypes: []string{"order"}},
+			},
+		},
+		{
+			name:          "reserved name",
+			filters:       ReplicationFiltersConfig{base.ByChannelFilter: {}},
+			expectedError: "is reserved",
+		},
+		{
+			name:          "empty channels",
+			filters:       ReplicationFiltersConfig{"orders": {Channels: []string{}}},
+			expectedError: "replication_filters.orders.channels must not be empty",
+		},
+		{
+			name:          "empty prefix",
+			filters:       ReplicationFiltersConfig{"orders": {DocIDPrefixes: []string{""}}},
+			expectedError: "replication_filters.orders.doc_id_prefixes must not contain an empty prefix",
+		},
+		{
+			name:          "type property without types",
+			filters:       ReplicationFiltersConfig{"orders": {DocTypeProperty: "kind"}},
+			expectedError: "replication_filters.orders.doc_type_property requires doc_types",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			dbConfig := DbConfig{Name: "db", ReplicationFilters: testCase.filters}
+			err := dbConfig.validate(base.TestCtx(t), false)
+			if testCase.expectedError == "" {
+				require.NoError(t, err)
+				filters := testCase.filters.toReplicationFilters()
+				require.Contains(t, filters, "orders")
+				assert.Equal(t, base.SetOf("store-1"), filters["orders"].Channels)
+				assert.Equal(t, base.SetOf("order"), filters["orders"].DocTypes)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testCase.expectedError)
+			}
+		})
+	}
+}
+
+func TestStartupConsistencyCheckConfig(t *testing.T) {
+	var nilConfig *StartupConsistencyCheckConfig
+	assert.Equal(t, db.SequenceCheckOptions{}, nilConfig.toSequenceCheckOptions())
+
+	options := (&StartupConsistencyCheckConfig{}).toSequenceCheckOptions()
+	assert.Equal(t, db.SequenceCheckOptions{Enabled: true, SevereSequenceGap: db.DefaultSevereSequenceGap}, options)
+
+	options = (&StartupConsistencyCheckConfig{Enabled: base.BoolPtr(false), Strict: base.BoolPtr(true), SevereSequenceGap: base.Uint64Ptr(100)}).toSequenceCheckOptions()
+	assert.Equal(t, db.SequenceCheckOptions{Strict: true, SevereSequenceGap: 100}, options)
+}
@@ -0,0 +1,63 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+const (
+	// consistencyTokenHeader is the response header set on document writes, identifying the write for read-your-own-writes
+	// consistency on subsequent _changes and _all_docs requests.
+	consistencyTokenHeader = "X-Consistency-Token"
+	// consistencyTokenParam is the query parameter (or _changes POST body property) used to pass a consistency token.
+	consistencyTokenParam = "consistency_token"
+)
+
+// formatConsistencyToken returns the consistency token for a write with the given sequence. Tokens are opaque to
+// clients, and are only valid for the database that issued them.
+func formatConsistencyToken(sequence uint64) string {
+	return strconv.FormatUint(sequence, 10)
+}
+
+// parseConsistencyToken returns the sequence identified by a consistency token. An empty token returns zero.
+func parseConsistencyToken(token string) (uint64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	sequence, err := strconv.ParseUint(token, 10, 64)
+	if err != nil {
+		return 0, base.HTTPErrorf(http.StatusBadRequest, "Invalid %s: %q", consistencyTokenParam, token)
+	}
+	return sequence, nil
+}
+
+// setConsistencyToken sets the consistency token response header for a write with the given sequence.
+func (h *handler) setConsistencyToken(sequence uint64) {
+	if sequence == 0 {
+		return
+	}
+	h.setHeader(consistencyTokenHeader, formatConsistencyToken(sequence))
+}
+
+// waitForConsistencyToken blocks until the change cache has received the sequence identified by the request's
+// consistency_token query parameter, if present.  Returns a 503 if the cache doesn't catch up within the wait limit.
+func (h *handler) waitForConsistencyToken() error {
+	sequence, err := parseConsistencyToken(h.getQuery(consistencyTokenParam))
+	if err != nil || sequence == 0 {
+		return err
+	}
+	if err := h.collection.WaitForSequenceNotSkipped(h.ctx(), sequence); err != nil {
+		return base.HTTPErrorf(http.StatusServiceUnavailable, "Timed out waiting for %s %d: %v", consistencyTokenParam, sequence, err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsistencyToken(t *testing.T) {
+	rt := NewRestTester(t, nil)
+	defer rt.Close()
+
+	resp := rt.SendAdminRequest(http.MethodPut, "/{{.keyspace}}/doc1", `{"foo":"bar"}`)
+	RequireStatus(t, resp, http.StatusCreated)
+	token := resp.Header().Get(consistencyTokenHeader)
+	require.NotEmpty(t, token)
+	assert.Equal(t, strconv.FormatUint(rt.GetDocumentSequence("doc1"), 10), token)
+
+	resp = rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/_changes?consistency_token="+token, "")
+	RequireStatus(t, resp, http.StatusOK)
+	var changes ChangesResults
+	require.NoError(t, json.Unmarshal(resp.BodyBytes(), &changes))
+	require.Len(t, changes.Results, 1)
+	assert.Equal(t, "doc1", changes.Results[0].ID)
+
+	resp = rt.SendAdminRequest(http.MethodPost, "/{{.keyspace}}/_changes", `{"consistency_token":"`+token+`"}`)
+	RequireStatus(t, resp, http.StatusOK)
+
+	resp = rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/_all_docs?consistency_token="+token, "")
+	RequireStatus(t, resp, http.StatusOK)
+
+	resp = rt.SendAdminRequest(http.MethodPost, "/{{.keyspace}}/_bulk_docs", `{"docs":[{"_id":"doc2"},{"_id":"doc3"}]}`)
+	RequireStatus(t, resp, http.StatusCreated)
+	assert.Equal(t, strconv.FormatUint(rt.GetDocumentSequence("doc3"), 10), resp.Header().Get(consistencyTokenHeader))
+
+	version, _ := rt.GetDoc("doc1")
+	resp = rt.SendAdminRequest(http.MethodDelete, "/{{.keyspace}}/doc1?rev="+version.RevID, "")
+	RequireStatus(t, resp, http.StatusOK)
+	assert.Equal(t, strconv.FormatUint(rt.GetDocumentSequence("doc1"), 10), resp.Header().Get(consistencyTokenHeader))
+
+	// Malformed tokens are rejected
+	resp = rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/_changes?consistency_token=abc", "")
+	RequireStatus(t, resp, http.StatusBadRequest)
+	resp = rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/_all_docs?consistency_token=abc", "")
+	RequireStatus(t, resp, http.StatusBadRequest)
+}
@@ -0,0 +1,52 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+const (
+	// correlationIDHeader is the request header a client can use to pass its own ID for a request, which is used to
+	// correlate the request's logs. The correlation ID used is always returned in the response header.
+	correlationIDHeader = "X-Correlation-ID"
+	// maxCorrelationIDLength is the maximum length of a client-supplied correlation ID.
+	maxCorrelationIDLength = 128
+)
+
+// isValidCorrelationID returns true if a client-supplied correlation ID can be included in logs and headers as-is.
+// Only letters, digits and '-', '_', '.', ':' are allowed.
+func isValidCorrelationID(id string) bool {
+	if id == "" || len(id) > maxCorrelationIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// clientCorrelationID returns the correlation ID supplied by the client in the X-Correlation-ID header, or empty if
+// not supplied or invalid.
+func (h *handler) clientCorrelationID() string {
+	if id := h.rq.Header.Get(correlationIDHeader); isValidCorrelationID(id) {
+		return id
+	}
+	return ""
+}
+
+// correlationID returns the ID used to correlate the request's logs: the client-supplied correlation ID if set,
+// otherwise the request's serial number.
+func (h *handler) correlationID() string {
+	if id := h.clientCorrelationID(); id != "" {
+		return id
+	}
+	return h.formatSerialNumber()
+}
@@ -0,0 +1,50 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorrelationIDHeader(t *testing.T) {
+	rt := NewRestTester(t, nil)
+	defer rt.Close()
+
+	// A client-supplied correlation ID is returned, including in error responses
+	resp := rt.SendAdminRequestWithHeaders(http.MethodGet, "/{{.keyspace}}/missing", "", map[string]string{correlationIDHeader: "replication-batch:42"})
+	RequireStatus(t, resp, http.StatusNotFound)
+	assert.Equal(t, "replication-batch:42", resp.Header().Get(correlationIDHeader))
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(resp.BodyBytes(), &body))
+	assert.Equal(t, "replication-batch:42", body["correlation_id"])
+
+	// Otherwise the request's serial number is used
+	resp = rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/missing", "")
+	RequireStatus(t, resp, http.StatusNotFound)
+	assert.True(t, strings.HasPrefix(resp.Header().Get(correlationIDHeader), "#"))
+
+	// Invalid correlation IDs are ignored
+	resp = rt.SendAdminRequestWithHeaders(http.MethodGet, "/{{.db}}/", "", map[string]string{correlationIDHeader: "bad id\n"})
+	RequireStatus(t, resp, http.StatusOK)
+	assert.True(t, strings.HasPrefix(resp.Header().Get(correlationIDHeader), "#"))
+}
+
+func TestIsValidCorrelationID(t *testing.T) {
+	assert.True(t, isValidCorrelationID("abc-123_DEF.4:5"))
+	assert.False(t, isValidCorrelationID(""))
+	assert.False(t, isValidCorrelationID("has space"))
+	assert.False(t, isValidCorrelationID("quote\""))
+	assert.False(t, isValidCorrelationID(strings.Repeat("a", maxCorrelationIDLength+1)))
+}
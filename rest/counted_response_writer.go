@@ -67,6 +67,13 @@ func (w *CountedResponseWriter) WriteHeader(statusCode int) {
 	w.writer.WriteHeader(statusCode)
 }
 
+// Flush passes through to the underlying ResponseWriter, if it supports flushing.
+func (w *CountedResponseWriter) Flush() {
+	if f, ok := w.writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 // reportStats reports bytes written by this response writer, since the last report. This will only report stats if the stat is defined. This is not locked, so is only safe to call while no one is calling EncodedResponseWriter.Write. If updateImmediately is set, the stats are reported immediately, otherwise they are reported if enough time has elapsed since last reporting.
 func (w *CountedResponseWriter) reportStats(updateImmediately bool) {
 	currentTime := time.Now()
@@ -23,6 +23,10 @@ import (
 	"github.com/couchbase/sync_gateway/db"
 )
 
+// deltaSourceHeader is set on a document GET response containing a delta, identifying the revision the delta was
+// generated from.
+const deltaSourceHeader = "X-Delta-Source"
+
 // HTTP handler for a GET of a document
 func (h *handler) handleGetDoc() error {
 	docid := h.PathVar("docid")
@@ -30,6 +34,10 @@ func (h *handler) handleGetDoc() error {
 	openRevs := h.getQuery("open_revs")
 	showExp := h.getBoolQuery("show_exp")
 
+	if err := h.db.CheckRequestQuota(h.ctx(), db.QuotaReads); err != nil {
+		return err
+	}
+
 	if replicator2, _ := h.getOptBoolQuery("replicator2", false); replicator2 {
 		return h.handleGetDocReplicator2(docid, revid)
 	}
@@ -66,7 +74,13 @@ func (h *handler) handleGetDoc() error {
 	}
 
 	if openRevs == "" {
-		// Single-revision GET:
+		// Single-revision GET, returned as a delta when requested and available:
+		if deltaSrcRevID := h.getQuery("delta_src"); deltaSrcRevID != "" && revsLimit == 0 && attachmentsSince == nil && !showExp && h.db.DeltaSyncEnabled() {
+			if sent, err := h.sendDocDelta(docid, revid, deltaSrcRevID); sent || err != nil {
+				return err
+			}
+		}
+
 		value, err := h.collection.Get1xRevBodyWithHistory(h.ctx(), docid, revid, revsLimit, revsFrom, attachmentsSince, showExp)
 		if err != nil {
 			if err == base.ErrImportCancelledPurged {
@@ -86,9 +100,19 @@ func (h *handler) handleGetDoc() error {
 			}
 			return kNotFoundError
 		}
-		h.setEtag(value[db.BodyRev].(string))
+		currentRev := value[db.BodyRev].(string)
+		h.setEtag(currentRev)
+		if ifNoneMatch := h.rq.Header.Get("If-None-Match"); ifNoneMatch != "" && etagHeaderMatches(ifNoneMatch, currentRev) {
+			h.response.WriteHeader(http.StatusNotModified)
+			h.setStatus(http.StatusNotModified, http.StatusText(http.StatusNotModified))
+			return nil
+		}
+		if ifMatch := h.rq.Header.Get("If-Match"); ifMatch != "" && !etagHeaderMatches(ifMatch, currentRev) {
+			return base.HTTPErrorf(http.StatusPreconditionFailed, "Document revision does not match If-Match header")
+		}
 
 		h.db.DbStats.Database().NumDocReadsRest.Add(1)
+		h.db.RecordRequestQuota(db.QuotaReads)
 		hasBodies := attachmentsSince != nil && value[db.BodyAttachments] != nil
 		if h.requestAccepts("multipart/") && (hasBodies || !h.requestAccepts("application/json")) {
 			canCompress := strings.Contains(h.rq.Header.Get("X-Accept-Part-Encoding"), "gzip")
@@ -130,6 +154,9 @@ func (h *handler) handleGetDoc() error {
 					}
 					_ = WriteRevisionAsPart(h.ctx(), h.db.DatabaseContext.DbStats.CBLReplicationPull(), revBody, err != nil, false, writer)
 					h.db.DbStats.Database().NumDocReadsRest.Add(1)
+					if err == nil {
+						h.db.RecordRequestQuota(db.QuotaReads)
+					}
 				}
 				return nil
 			})
@@ -155,11 +182,46 @@ func (h *handler) handleGetDoc() error {
 			}
 			_, _ = h.response.Write([]byte(`]`))
 			h.db.DbStats.Database().NumDocReadsRest.Add(1)
+			h.db.RecordRequestQuota(db.QuotaReads)
 		}
 	}
 	return nil
 }
 
+// sendDocDelta writes the delta from deltaSrcRevID to revid (or the current revision, if revid is empty) of a document.
+// Returns false when a delta isn't available, in which case the caller should return the full body.
+func (h *handler) sendDocDelta(docid, revid, deltaSrcRevID string) (sent bool, err error) {
+	if revid == "" {
+		rev, err := h.collection.GetRev(h.ctx(), docid, "", false, nil)
+		if err != nil {
+			// Let the full body GET return the appropriate error
+			return false, nil
+		}
+		revid = rev.RevID
+	}
+
+	h.db.DbStats.DeltaSync().DeltasRequested.Add(1)
+	delta, redactedRev, err := h.collection.GetDelta(h.ctx(), docid, deltaSrcRevID, revid)
+	if err == db.ErrForbidden {
+		return false, err
+	} else if base.IsFleeceDeltaError(err) {
+		base.WarnfCtx(h.ctx(), "Falling back to full body. Error generating delta from %s to %s for key %s - err: %v", deltaSrcRevID, revid, base.UD(docid), err)
+		return false, nil
+	} else if err != nil || delta == nil || redactedRev != nil || delta.ToDeleted {
+		// Removals and tombstones are returned as a full body, so the client can see the _removed/_deleted property
+		base.DebugfCtx(h.ctx(), base.KeyCRUD, "Falling back to full body. Couldn't get delta from %s to %s for key %s - err: %v", deltaSrcRevID, revid, base.UD(docid), err)
+		return false, nil
+	}
+
+	h.setEtag(revid)
+	h.setHeader(deltaSourceHeader, deltaSrcRevID)
+	h.writeRawJSON(delta.DeltaBytes)
+	h.db.DbStats.Database().NumDocReadsRest.Add(1)
+	h.db.RecordRequestQuota(db.QuotaReads)
+	h.db.DbStats.DeltaSync().DeltasSent.Add(1)
+	return true, nil
+}
+
 func (h *handler) handleGetDocReplicator2(docid, revid string) error {
 	if !base.IsEnterpriseEdition() {
 		return base.HTTPErrorf(http.StatusNotImplemented, "replicator2 endpoints are only supported in EE")
@@ -182,6 +244,7 @@ func (h *handler) handleGetDocReplicator2(docid, revid string) error {
 	h.setHeader("Content-Type", "application/json")
 	_, _ = h.response.Write(bodyBytes)
 	h.db.DbStats.Database().NumDocReadsRest.Add(1)
+	h.db.RecordRequestQuota(db.QuotaReads)
 
 	return nil
 }
@@ -221,7 +284,14 @@ func (h *handler) handleGetAttachment() error {
 		return nil
 	}
 
-	status, start, end := h.handleRange(uint64(len(data)))
+	h.setEtag(digest)
+	if ifNoneMatch := h.rq.Header.Get("If-None-Match"); ifNoneMatch != "" && etagHeaderMatches(ifNoneMatch, digest) {
+		h.response.WriteHeader(http.StatusNotModified)
+		h.setStatus(http.StatusNotModified, http.StatusText(http.StatusNotModified))
+		return nil
+	}
+
+	status, start, end := h.handleRange(uint64(len(data)), digest)
 	if status > 299 {
 		return base.HTTPErrorf(status, "")
 	} else if status == http.StatusPartialContent {
@@ -232,8 +302,6 @@ func (h *handler) handleGetAttachment() error {
 	// #720
 	setContentDisposition := h.privs == adminPrivs
 
-	h.setEtag(digest)
-
 	// Request will be returned with the same content type as is set on the attachment. The caveat to this is if the
 	// attachment has a content type which is vulnerable to a phishing attack. If this is the case we will return with
 	// the Content Disposition header so that browsers will download the attachment rather than attempt to render it
@@ -280,6 +348,11 @@ func (h *handler) handleGetAttachment() error {
 		h.setHeader("Content-Disposition", "attachment")
 
 	}
+	if h.rq.Method == http.MethodHead {
+		// HEAD returns the length and digest (as the Etag) without the attachment body
+		h.response.WriteHeader(status)
+		return nil
+	}
 	h.db.DbStats.CBLReplicationPull().AttachmentPullCount.Add(1)
 	h.db.DbStats.CBLReplicationPull().AttachmentPullBytes.Add(int64(len(data)))
 	h.response.WriteHeader(status)
@@ -445,16 +518,30 @@ func (h *handler) handlePutDoc() error {
 		return base.HTTPErrorf(http.StatusBadRequest, "The document ID provided in the body does not match the document ID in the path")
 	}
 
+	write, err := h.beginIdempotentWrite(docid, body)
+	if err != nil || write.isReplayed() {
+		return err
+	}
+	defer write.release()
+
+	if err := h.db.CheckRequestQuota(h.ctx(), db.QuotaWrites); err != nil {
+		return err
+	}
+
 	var newRev string
 	var doc *db.Document
 
 	if h.getQuery("new_edits") != "false" {
 		// Regular PUT:
 		bodyRev := body[db.BodyRev]
-		if oldRev := h.getQuery("rev"); oldRev != "" {
-			body[db.BodyRev] = oldRev
-		} else if ifMatch, _ := h.getEtag("If-Match"); ifMatch != "" {
-			body[db.BodyRev] = ifMatch
+		parentRev, conditional, err := h.getDocWritePrecondition(docid)
+		if err != nil {
+			return err
+		}
+		if parentRev != "" {
+			body[db.BodyRev] = parentRev
+		} else if conditional && bodyRev != nil {
+			return base.HTTPErrorf(http.StatusBadRequest, "If-None-Match: * can't be used to update an existing revision")
 		}
 		if bodyRev != nil && bodyRev != body[db.BodyRev] {
 			return base.HTTPErrorf(http.StatusBadRequest, "Revision IDs provided do not match")
@@ -462,7 +549,7 @@ func (h *handler) handlePutDoc() error {
 
 		newRev, doc, err = h.collection.Put(h.ctx(), docid, body)
 		if err != nil {
-			return err
+			return conditionalWriteError(err, conditional)
 		}
 		h.setEtag(newRev)
 	} else {
@@ -476,14 +563,18 @@ func (h *handler) handlePutDoc() error {
 			return err
 		}
 	}
+	h.db.RecordRequestQuota(db.QuotaWrites)
 
-	if doc != nil && roundTrip {
-		if err := h.collection.WaitForSequenceNotSkipped(h.ctx(), doc.Sequence); err != nil {
-			return err
+	if doc != nil {
+		if roundTrip {
+			if err := h.collection.WaitForSequenceNotSkipped(h.ctx(), doc.Sequence); err != nil {
+				return err
+			}
 		}
+		h.setConsistencyToken(doc.Sequence)
 	}
 
-	h.writeRawJSONStatus(http.StatusCreated, []byte(`{"id":`+base.ConvertToJSONString(docid)+`,"ok":true,"rev":"`+newRev+`"}`))
+	write.writeResponse(h, http.StatusCreated, []byte(`{"id":`+base.ConvertToJSONString(docid)+`,"ok":true,"rev":"`+newRev+`"}`))
 	return nil
 }
 
@@ -554,10 +645,13 @@ func (h *handler) handlePutDocReplicator2(docid string, roundTrip bool) (err err
 		return err
 	}
 
-	if doc != nil && roundTrip {
-		if err := h.collection.WaitForSequenceNotSkipped(h.ctx(), doc.Sequence); err != nil {
-			return err
+	if doc != nil {
+		if roundTrip {
+			if err := h.collection.WaitForSequenceNotSkipped(h.ctx(), doc.Sequence); err != nil {
+				return err
+			}
 		}
+		h.setConsistencyToken(doc.Sequence)
 	}
 
 	h.writeRawJSONStatus(http.StatusCreated, []byte(`{"id":`+base.ConvertToJSONString(docid)+`,"ok":true,"rev":"`+rev+`"}`))
@@ -576,42 +670,107 @@ func (h *handler) handlePostDoc() error {
 		return err
 	}
 
+	write, err := h.beginIdempotentWrite("", body)
+	if err != nil || write.isReplayed() {
+		return err
+	}
+	defer write.release()
+
+	if err := h.db.CheckRequestQuota(h.ctx(), db.QuotaWrites); err != nil {
+		return err
+	}
 	docid, newRev, doc, err := h.collection.Post(h.ctx(), body)
 	if err != nil {
 		return err
 	}
+	h.db.RecordRequestQuota(db.QuotaWrites)
 
-	if doc != nil && roundTrip {
-		err := h.collection.WaitForSequenceNotSkipped(h.ctx(), doc.Sequence)
-		if err != nil {
-			return err
+	if doc != nil {
+		if roundTrip {
+			err := h.collection.WaitForSequenceNotSkipped(h.ctx(), doc.Sequence)
+			if err != nil {
+				return err
+			}
 		}
+		h.setConsistencyToken(doc.Sequence)
 	}
 
 	h.setHeader("Location", docid)
 	h.setEtag(newRev)
-	h.writeRawJSON([]byte(`{"id":"` + docid + `","ok":true,"rev":"` + newRev + `"}`))
+	write.writeResponse(h, http.StatusOK, []byte(`{"id":"`+docid+`","ok":true,"rev":"`+newRev+`"}`))
 	return nil
 }
 
 // HTTP handler for a DELETE of a document
 func (h *handler) handleDeleteDoc() error {
 	docid := h.PathVar("docid")
-	revid := h.getQuery("rev")
-	if revid == "" {
-		var err error
-		revid, err = h.getEtag("If-Match")
-		if err != nil {
-			return err
+	revid, conditional, err := h.getDocWritePrecondition(docid)
+	if err != nil {
+		return err
+	}
+	newRev, sequence, err := h.collection.DeleteDoc(h.ctx(), docid, revid)
+	if err != nil {
+		return conditionalWriteError(err, conditional)
+	}
+	h.setConsistencyToken(sequence)
+	h.writeRawJSONStatus(http.StatusOK, []byte(`{"id":`+base.ConvertToJSONString(docid)+`,"ok":true,"rev":"`+newRev+`"}`))
+	return nil
+}
+
+// getDocWritePrecondition returns the revision a document write should replace, from the rev query parameter or else
+// the If-Match header, where If-Match: * matches the current revision of an existing document.  conditional is true
+// when the revision comes from an If-Match or If-None-Match: * header, in which case a conflict is reported as
+// 412 Precondition Failed rather than 409 Conflict.
+func (h *handler) getDocWritePrecondition(docid string) (revid string, conditional bool, err error) {
+	if revid = h.getQuery("rev"); revid != "" {
+		return revid, false, nil
+	}
+	if strings.TrimSpace(h.rq.Header.Get("If-None-Match")) == "*" {
+		// Only create the document, with no parent revision
+		return "", true, nil
+	}
+	if strings.TrimSpace(h.rq.Header.Get("If-Match")) == "*" {
+		rev, err := h.collection.GetRev(h.ctx(), docid, "", false, nil)
+		if base.IsDocNotFoundError(err) || (err == nil && rev.Deleted) {
+			return "", true, base.HTTPErrorf(http.StatusPreconditionFailed, "Document does not exist")
+		} else if err != nil {
+			return "", true, err
 		}
+		return rev.RevID, true, nil
 	}
-	newRev, err := h.collection.DeleteDoc(h.ctx(), docid, revid)
-	if err == nil {
-		h.writeRawJSONStatus(http.StatusOK, []byte(`{"id":`+base.ConvertToJSONString(docid)+`,"ok":true,"rev":"`+newRev+`"}`))
+	revid, err = h.getEtag("If-Match")
+	if err != nil {
+		return "", false, err
+	}
+	return revid, revid != "", nil
+}
+
+// conditionalWriteError returns a 412 Precondition Failed in place of a conflict when a write's revision came from
+// an If-Match or If-None-Match header.
+func conditionalWriteError(err error, conditional bool) error {
+	if !conditional {
+		return err
+	}
+	if status, _ := base.ErrorAsHTTPStatus(err); status == http.StatusConflict {
+		return base.HTTPErrorf(http.StatusPreconditionFailed, "Document revision does not match If-Match or If-None-Match header")
 	}
 	return err
 }
 
+// etagHeaderMatches returns true if an If-Match or If-None-Match header value is "*", or a comma-separated list of
+// quoted entity tags including etag.  Weak entity tags are compared as if strong.
+func etagHeaderMatches(header, etag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == `"`+etag+`"` {
+			return true
+		}
+	}
+	return false
+}
+
 // ////// LOCAL DOCS:
 
 // HTTP handler for a GET of a _local document
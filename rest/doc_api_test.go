@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
 	"github.com/couchbase/sync_gateway/db"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -173,3 +174,150 @@ func TestGuestReadOnly(t *testing.T) {
 	RequireStatus(t, response, http.StatusForbidden)
 
 }
+
+func TestGetDocDelta(t *testing.T) {
+	if !base.IsEnterpriseEdition() {
+		t.Skip("Delta sync only supported in EE")
+	}
+
+	deltaSyncEnabled := true
+	rt := NewRestTester(t, &RestTesterConfig{
+		DatabaseConfig: &DatabaseConfig{DbConfig: DbConfig{
+			DeltaSync: &DeltaSyncConfig{
+				Enabled: &deltaSyncEnabled,
+			},
+		}},
+	})
+	defer rt.Close()
+
+	version1 := rt.PutDoc("doc1", `{"greeting": "hello", "count": 1}`)
+	version2 := rt.UpdateDoc("doc1", version1, `{"greeting": "hello", "count": 2}`)
+
+	// Delta to the current revision
+	response := rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/doc1?delta_src="+version1.RevID, "")
+	RequireStatus(t, response, http.StatusOK)
+	assert.Equal(t, version1.RevID, response.Header().Get(deltaSourceHeader))
+	assert.Equal(t, `"`+version2.RevID+`"`, response.Header().Get("Etag"))
+	assert.JSONEq(t, `{"count": 2}`, response.Body.String())
+
+	// Delta to a specific revision
+	response = rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/doc1?rev="+version2.RevID+"&delta_src="+version1.RevID, "")
+	RequireStatus(t, response, http.StatusOK)
+	assert.Equal(t, version1.RevID, response.Header().Get(deltaSourceHeader))
+	assert.JSONEq(t, `{"count": 2}`, response.Body.String())
+
+	// Unknown delta source falls back to the full body
+	response = rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/doc1?delta_src=1-abc", "")
+	RequireStatus(t, response, http.StatusOK)
+	assert.Empty(t, response.Header().Get(deltaSourceHeader))
+	var body db.Body
+	require.NoError(t, base.JSONUnmarshal(response.Body.Bytes(), &body))
+	assert.Equal(t, version2.RevID, body[db.BodyRev])
+	assert.Equal(t, "hello", body["greeting"])
+
+	// Deltas aren't returned with revision history
+	response = rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/doc1?revs=true&delta_src="+version1.RevID, "")
+	RequireStatus(t, response, http.StatusOK)
+	assert.Empty(t, response.Header().Get(deltaSourceHeader))
+
+	// Tombstones are returned as a full body
+	version3 := rt.DeleteDocReturnVersion("doc1", version2)
+	response = rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/doc1?rev="+version3.RevID+"&delta_src="+version2.RevID, "")
+	RequireStatus(t, response, http.StatusOK)
+	assert.Empty(t, response.Header().Get(deltaSourceHeader))
+}
+
+// TestDocETagPreconditions ensures If-Match and If-None-Match headers are evaluated against the current revision of
+// a document on GET, PUT and DELETE.
+func TestDocETagPreconditions(t *testing.T) {
+	rt := NewRestTester(t, nil)
+	defer rt.Close()
+
+	sendWithHeader := func(method, resource, body, header, value string) *TestResponse {
+		return rt.SendAdminRequestWithHeaders(method, resource, body, map[string]string{header: value})
+	}
+
+	// If-None-Match: * only creates a document that doesn't exist
+	response := sendWithHeader(http.MethodPut, "/{{.keyspace}}/doc1", `{"count": 1}`, "If-None-Match", "*")
+	RequireStatus(t, response, http.StatusCreated)
+	rev1 := strings.Trim(response.Header().Get("Etag"), `"`)
+	response = sendWithHeader(http.MethodPut, "/{{.keyspace}}/doc1", `{"count": 1}`, "If-None-Match", "*")
+	RequireStatus(t, response, http.StatusPreconditionFailed)
+
+	// GET returns 304 Not Modified when the current revision matches If-None-Match, and 412 when it doesn't match If-Match
+	response = sendWithHeader(http.MethodGet, "/{{.keyspace}}/doc1", "", "If-None-Match", `"`+rev1+`"`)
+	RequireStatus(t, response, http.StatusNotModified)
+	assert.Empty(t, response.Body.String())
+	response = sendWithHeader(http.MethodGet, "/{{.keyspace}}/doc1", "", "If-None-Match", `"1-abc", W/"`+rev1+`"`)
+	RequireStatus(t, response, http.StatusNotModified)
+	response = sendWithHeader(http.MethodGet, "/{{.keyspace}}/doc1", "", "If-None-Match", `"1-abc"`)
+	RequireStatus(t, response, http.StatusOK)
+	response = sendWithHeader(http.MethodGet, "/{{.keyspace}}/doc1", "", "If-Match", `"1-abc"`)
+	RequireStatus(t, response, http.StatusPreconditionFailed)
+
+	// PUT with a stale If-Match fails with 412, rather than 409
+	response = sendWithHeader(http.MethodPut, "/{{.keyspace}}/doc1", `{"count": 2}`, "If-Match", `"`+rev1+`"`)
+	RequireStatus(t, response, http.StatusCreated)
+	rev2 := strings.Trim(response.Header().Get("Etag"), `"`)
+	response = sendWithHeader(http.MethodPut, "/{{.keyspace}}/doc1", `{"count": 3}`, "If-Match", `"`+rev1+`"`)
+	RequireStatus(t, response, http.StatusPreconditionFailed)
+	response = rt.SendAdminRequest(http.MethodPut, "/{{.keyspace}}/doc1?rev="+rev1, `{"count": 3}`)
+	RequireStatus(t, response, http.StatusConflict)
+
+	// If-Match: * updates the current revision, but only of an existing document
+	response = sendWithHeader(http.MethodPut, "/{{.keyspace}}/doc1", `{"count": 3}`, "If-Match", "*")
+	RequireStatus(t, response, http.StatusCreated)
+	rev3 := strings.Trim(response.Header().Get("Etag"), `"`)
+	assert.NotEqual(t, rev2, rev3)
+	response = sendWithHeader(http.MethodPut, "/{{.keyspace}}/doc2", `{"count": 1}`, "If-Match", "*")
+	RequireStatus(t, response, http.StatusPreconditionFailed)
+
+	// DELETE with a stale If-Match fails with 412
+	response = sendWithHeader(http.MethodDelete, "/{{.keyspace}}/doc1", "", "If-Match", `"`+rev2+`"`)
+	RequireStatus(t, response, http.StatusPreconditionFailed)
+	response = sendWithHeader(http.MethodDelete, "/{{.keyspace}}/doc1", "", "If-Match", `"`+rev3+`"`)
+	RequireStatus(t, response, http.StatusOK)
+}
+
+// TestRequestQuotasRest ensures reads and writes through the REST API are counted towards, and limited by, the
+// user's daily request quotas.
+func TestRequestQuotasRest(t *testing.T) {
+	rt := NewRestTester(t, &RestTesterConfig{
+		SyncFn: channels.DocChannelsSyncFunction,
+		DatabaseConfig: &DatabaseConfig{DbConfig: DbConfig{
+			RequestQuotas: &RequestQuotaConfig{DailyReads: base.Uint64Ptr(2), DailyWrites: base.Uint64Ptr(3)},
+		}},
+	})
+	defer rt.Close()
+	rt.CreateUser("alice", []string{"A"})
+
+	// Writes by PUT and _bulk_docs are counted, and those over quota rejected, while admin writes aren't limited
+	response := rt.SendUserRequest(http.MethodPut, "/{{.keyspace}}/doc1", `{"channels": "A"}`, "alice")
+	RequireStatus(t, response, http.StatusCreated)
+	response = rt.SendUserRequest(http.MethodPost, "/{{.keyspace}}/_bulk_docs", `{"docs": [{"_id": "doc2", "channels": "A"}, {"_id": "doc3", "channels": "A"}, {"_id": "doc4", "channels": "A"}]}`, "alice")
+	RequireStatus(t, response, http.StatusCreated)
+	var results []db.Body
+	require.NoError(t, base.JSONUnmarshal(response.Body.Bytes(), &results))
+	require.Len(t, results, 3)
+	assert.NotContains(t, results[0], "error")
+	assert.NotContains(t, results[1], "error")
+	assert.Equal(t, float64(http.StatusTooManyRequests), results[2]["status"])
+	response = rt.SendUserRequest(http.MethodPut, "/{{.keyspace}}/doc4", `{"channels": "A"}`, "alice")
+	RequireStatus(t, response, http.StatusTooManyRequests)
+	RequireStatus(t, rt.SendAdminRequest(http.MethodPut, "/{{.keyspace}}/doc4", `{"channels": "A"}`), http.StatusCreated)
+
+	// Reads by GET and _bulk_get are counted, and those over quota rejected
+	response = rt.SendUserRequest(http.MethodGet, "/{{.keyspace}}/doc1", "", "alice")
+	RequireStatus(t, response, http.StatusOK)
+	response = rt.SendUserRequest(http.MethodPost, "/{{.keyspace}}/_bulk_get", `{"docs": [{"id": "doc2"}, {"id": "doc3"}]}`, "alice")
+	RequireStatus(t, response, http.StatusOK)
+	assert.Equal(t, 1, strings.Count(response.Body.String(), `"status":429`), "body: %s", response.Body.String())
+	response = rt.SendUserRequest(http.MethodGet, "/{{.keyspace}}/doc1", "", "alice")
+	RequireStatus(t, response, http.StatusTooManyRequests)
+	RequireStatus(t, rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/doc1", ""), http.StatusOK)
+
+	usage, err := rt.GetDatabase().GetRequestQuotaUsage(rt.Context(), "alice")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), usage.Reads)
+	assert.Equal(t, uint64(3), usage.Writes)
+}
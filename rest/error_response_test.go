@@ -0,0 +1,62 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorResponse(t *testing.T) {
+	rt := NewRestTester(t, nil)
+	defer rt.Close()
+
+	resp := rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/missing", "")
+	RequireStatus(t, resp, http.StatusNotFound)
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(resp.BodyBytes(), &body))
+	assert.Equal(t, "not_found", body["error"])
+	assert.Equal(t, "missing", body["reason"])
+	assert.Equal(t, string(base.ErrorCodeNotFound), body["code"])
+	assert.Equal(t, false, body["retryable"])
+	assert.NotEmpty(t, body["correlation_id"])
+	assert.NotContains(t, body, "details")
+
+	// Errors with a specific code are reported with it, rather than the code of their status
+	rt.TakeDbOffline()
+	resp = rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/missing", "")
+	RequireStatus(t, resp, http.StatusServiceUnavailable)
+	body = nil
+	require.NoError(t, json.Unmarshal(resp.BodyBytes(), &body))
+	assert.Equal(t, string(base.ErrorCodeDatabaseOffline), body["code"])
+	assert.Equal(t, false, body["retryable"])
+}
+
+func TestLegacyErrorResponse(t *testing.T) {
+	rt := NewRestTester(t, &RestTesterConfig{
+		MutateStartupConfig: func(config *StartupConfig) {
+			config.API.LegacyErrorFormat = base.BoolPtr(true)
+		},
+	})
+	defer rt.Close()
+
+	resp := rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/missing", "")
+	RequireStatus(t, resp, http.StatusNotFound)
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(resp.BodyBytes(), &body))
+	assert.Len(t, body, 3)
+	assert.Equal(t, "not_found", body["error"])
+	assert.Equal(t, "missing", body["reason"])
+	assert.Contains(t, body, "correlation_id")
+}
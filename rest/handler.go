@@ -91,6 +91,8 @@ type handler struct {
 	collection            *db.DatabaseCollectionWithUser
 	user                  auth.User
 	authorizedAdminUser   string
+	adminAuthSource       string // How the admin user was authorized, for audit logging
+	adminRole             string // Sync Gateway admin role the admin user was authorized by, if any
 	privs                 handlerPrivs
 	startTime             time.Time
 	serialNumber          uint64
@@ -124,6 +126,7 @@ func makeHandlerWithOptions(server *ServerContext, privs handlerPrivs, accessPer
 		h := newHandler(server, privs, r, rq, options)
 		err := h.invoke(method, accessPermissions, responsePermissions)
 		h.writeError(err)
+		h.logAdminAudit()
 		if !options.skipLogDuration {
 			h.logDuration(true)
 		}
@@ -193,6 +196,7 @@ func newHandler(server *ServerContext, privs handlerPrivs, r http.ResponseWriter
 
 	// initialize h.rqCtx
 	_ = h.ctx()
+	h.setHeader(correlationIDHeader, h.correlationID())
 
 	return h
 }
@@ -200,7 +204,7 @@ func newHandler(server *ServerContext, privs handlerPrivs, r http.ResponseWriter
 // ctx returns the request-scoped context for logging/cancellation.
 func (h *handler) ctx() context.Context {
 	if h.rqCtx == nil {
-		h.rqCtx = base.CorrelationIDLogCtx(h.rq.Context(), h.formatSerialNumber())
+		h.rqCtx = base.CorrelationIDLogCtx(h.rq.Context(), h.correlationID())
 	}
 	return h.rqCtx
 }
@@ -217,6 +221,12 @@ func (h *handler) addCollectionLogContext(collectionName string) {
 	}
 }
 
+func (h *handler) addUserLogContext(username string) {
+	if username != "" {
+		h.rqCtx = base.UserLogCtx(h.ctx(), username)
+	}
+}
+
 // ParseKeyspace will return a db, scope and collection for a given '.' separated keyspace string.
 // Returns nil for scope and/or collection if not present in the keyspace string.
 func ParseKeyspace(ks string) (db string, scope, collection *string, err error) {
@@ -316,6 +326,16 @@ func (h *handler) validateAndWriteHeaders(method handlerMethod, accessPermission
 	// user credentials
 	shouldCheckAdminAuth := (h.privs == adminPrivs && *h.server.Config.API.AdminInterfaceAuthentication) || (h.privs == metricsPrivs && *h.server.Config.API.MetricsInterfaceAuthentication)
 
+	// Metrics requests can also be authenticated by a bearer token or client certificate, and once either is configured
+	// are rejected without credentials even if metrics API authentication is disabled
+	if h.privs == metricsPrivs && h.server.Config.API.metricsCredentialsConfigured() {
+		if h.hasMetricsCredentials() {
+			shouldCheckAdminAuth = false
+		} else if !shouldCheckAdminAuth {
+			return ErrLoginRequired
+		}
+	}
+
 	keyspaceDb := h.PathVar("db")
 	var keyspaceScope, keyspaceCollection *string
 
@@ -397,10 +417,13 @@ func (h *handler) validateAndWriteHeaders(method handlerMethod, accessPermission
 			// if dbState == db.DBOnline, continue flow and invoke the handler method
 			if dbState == db.DBOffline {
 				// DB is offline, only handlers with runOffline true can run in this state
-				return base.HTTPErrorf(http.StatusServiceUnavailable, "DB is currently under maintenance")
+				return base.NewCodedError(base.ErrorCodeDatabaseOffline, base.HTTPErrorf(http.StatusServiceUnavailable, "DB is currently under maintenance"), nil)
+			} else if dbState == db.DBPaused && h.isBlipSync() {
+				// Replications can connect to a paused DB, and start once it's resumed
 			} else if dbState != db.DBOnline {
 				// DB is in transition state, no calls will be accepted until it is Online or Offline state
-				return base.HTTPErrorf(http.StatusServiceUnavailable, fmt.Sprintf("DB is %v - try again later", db.RunStateString[dbState]))
+				return base.NewCodedError(base.ErrorCodeDatabaseNotReady, base.HTTPErrorf(http.StatusServiceUnavailable, fmt.Sprintf("DB is %v - try again later", db.RunStateString[dbState])),
+					map[string]interface{}{"state": db.RunStateString[dbState]})
 			}
 		}
 	}
@@ -417,6 +440,9 @@ func (h *handler) validateAndWriteHeaders(method handlerMethod, accessPermission
 			}
 			return err
 		}
+		if h.user != nil {
+			h.addUserLogContext(h.user.Name())
+		}
 		if h.user != nil && h.user.Name() == "" && dbContext != nil && dbContext.IsGuestReadOnly() {
 			// Prevent read-only guest access to any endpoint requiring write permissions except
 			// blipsync.  Read-only guest handling for websocket replication (blipsync) is evaluated
@@ -444,7 +470,12 @@ func (h *handler) validateAndWriteHeaders(method handlerMethod, accessPermission
 			}
 		}
 	}
-	if shouldCheckAdminAuth {
+	basicAuthUsername, basicAuthPassword := h.getBasicAuth()
+	if localAdminAccount := h.server.Config.API.AdminRBAC.localAccount(basicAuthUsername); shouldCheckAdminAuth && localAdminAccount != nil {
+		if err := h.authorizeLocalAdmin(basicAuthUsername, basicAuthPassword, localAdminAccount, accessPermissions, responsePermissions); err != nil {
+			return err
+		}
+	} else if shouldCheckAdminAuth {
 		// If server is walrus but auth is enabled we should just kick the user out as invalid as we have nothing to
 		// validate credentials against
 		if base.ServerIsWalrus(h.server.Config.Bootstrap.Server) {
@@ -494,6 +525,18 @@ func (h *handler) validateAndWriteHeaders(method handlerMethod, accessPermission
 			return base.HTTPErrorf(http.StatusInternalServerError, "")
 		}
 
+		h.adminAuthSource = adminAuthSourceServer
+
+		// A user authenticated by Couchbase Server without the roles required can be authorized by Sync Gateway admin roles
+		if statusCode == http.StatusForbidden {
+			if authorized, role, rolePermissions := authorizeAdminRoles(h.server.Config.API.AdminRBAC.userRoles(username), h.rq.Method, accessPermissions, responsePermissions); authorized {
+				statusCode = http.StatusOK
+				permissions = rolePermissions
+				h.adminAuthSource = adminAuthSourceRole
+				h.adminRole = role
+			}
+		}
+
 		if statusCode != http.StatusOK {
 			base.InfofCtx(h.ctx(), base.KeyAuth, "%s: User %s failed to auth as an admin statusCode: %d", h.formatSerialNumber(), base.UD(username), statusCode)
 			if statusCode == http.StatusUnauthorized {
@@ -1010,7 +1053,12 @@ func (h *handler) getOptBoolQuery(query string, defaultValue bool) (result, isSe
 
 // Returns the integer value of a URL query, defaulting to 0 if unparseable
 func (h *handler) getIntQuery(query string, defaultValue uint64) (value uint64) {
-	return base.GetRestrictedIntQuery(h.getQueryValues(), query, defaultValue, 0, 0, false)
+	return h.getRestrictedIntQuery(query, defaultValue, 0, 0, false)
+}
+
+// getRestrictedIntQuery returns the value of an integer query parameter, clamped to between minValue and maxValue.
+func (h *handler) getRestrictedIntQuery(query string, defaultValue, minValue, maxValue uint64, allowZero bool) uint64 {
+	return base.GetRestrictedIntQuery(h.getQueryValues(), query, defaultValue, minValue, maxValue, allowZero)
 }
 
 func (h *handler) getJSONStringArrayQuery(param string) ([]string, error) {
@@ -1092,7 +1140,7 @@ func (h *handler) readSanitizeJSON(val interface{}) error {
 
 	// Expand environment variables.
 	if base.BoolDefault(h.server.Config.Unsupported.AllowDbConfigEnvVars, true) {
-		content, err = expandEnv(h.ctx(), content)
+		content, err = expandEnv(h.ctx(), content, false)
 		if err != nil {
 			return err
 		}
@@ -1439,7 +1487,17 @@ func (h *handler) flush() {
 func (h *handler) writeError(err error) {
 	if err != nil {
 		status, message := base.ErrorAsHTTPStatus(err)
-		h.writeStatus(status, message)
+		if status < 300 {
+			// Some handlers return success statuses, such as 201 Created, as errors
+			h.writeStatus(status, message)
+			return
+		}
+		var retryAfterErr *base.RetryAfterError
+		if errors.As(err, &retryAfterErr) {
+			h.setHeader("Retry-After", strconv.Itoa(int(math.Ceil(retryAfterErr.RetryAfter.Seconds()))))
+		}
+		code, details := base.ErrorAsCode(err, status)
+		h.writeErrorStatus(status, message, code, details)
 		if status >= 500 {
 			// Log additional context when the handler has a database reference
 			if h.db != nil {
@@ -1458,7 +1516,22 @@ func (h *handler) writeStatus(status int, message string) {
 		h.setStatus(status, message)
 		return
 	}
-	// Got an error:
+	h.writeErrorStatus(status, message, base.StatusErrorCode(status), nil)
+}
+
+// errorResponse is the JSON body of an error response.  The code, retryable and details fields are omitted when
+// api.legacy_error_format is enabled.
+type errorResponse struct {
+	Error         string                 `json:"error"`
+	Reason        string                 `json:"reason"`
+	CorrelationID string                 `json:"correlation_id"`
+	Code          base.ErrorCode         `json:"code,omitempty"`
+	Retryable     *bool                  `json:"retryable,omitempty"`
+	Details       map[string]interface{} `json:"details,omitempty"`
+}
+
+// writeErrorStatus writes an error response status code, and a JSON description of the error to the body.
+func (h *handler) writeErrorStatus(status int, message string, code base.ErrorCode, details map[string]interface{}) {
 	var errorStr string
 	switch status {
 	case http.StatusNotFound:
@@ -1477,7 +1550,27 @@ func (h *handler) writeStatus(status int, message string) {
 	h.response.WriteHeader(status)
 	h.setStatus(status, message)
 
-	_, _ = h.response.Write([]byte(`{"error":"` + errorStr + `","reason":` + base.ConvertToJSONString(message) + `}`))
+	body := errorResponse{
+		Error:         errorStr,
+		Reason:        message,
+		CorrelationID: h.response.Header().Get(correlationIDHeader),
+	}
+	if !h.legacyErrorFormat() {
+		body.Code = code
+		body.Retryable = base.BoolPtr(code.Retryable())
+		body.Details = details
+	}
+	bodyBytes, err := base.JSONMarshal(body)
+	if err != nil {
+		base.WarnfCtx(h.ctx(), "Unable to marshal error response: %v", err)
+		return
+	}
+	_, _ = h.response.Write(bodyBytes)
+}
+
+// legacyErrorFormat returns true if error responses should only have the fields of the legacy format.
+func (h *handler) legacyErrorFormat() bool {
+	return h.server != nil && h.server.Config != nil && base.BoolDefault(h.server.Config.API.LegacyErrorFormat, false)
 }
 
 var kRangeRegex = regexp.MustCompile("^bytes=(\\d+)?-(\\d+)?$")
@@ -1488,22 +1581,31 @@ var kRangeRegex = regexp.MustCompile("^bytes=(\\d+)?-(\\d+)?$")
 // If there is no Range: request header, or if its valid is invalid, returns a status of 200,
 // meaning that the caller should return the entire response as usual.
 //
-// If there is a request range but it exceeds the contentLength, returns status 416. The caller
-// should treat this as an error and abort, returning that HTTP status code.
+// If there is a request range but it exceeds the contentLength, returns status 416 and adds a "Content-Range"
+// response header with the content length. The caller should treat this as an error and abort, returning that
+// HTTP status code.
+//
+// If there is an If-Range request header that doesn't match etag, the range is ignored and status 200 is returned,
+// so a client resuming a download of content that has since changed gets the whole of the new content.
 //
 // If there is a useable range, it returns status 206 and the start and end in Go slice terms, i.e.
 // starting at 0 and with the end non-inclusive. It also adds a "Content-Range" response header.
 // It is then the _caller's_ responsibility to set it as the response status code (by calling
 // h.response.WriteHeader(status)), and then write the indicated subrange of the response data.
-func (h *handler) handleRange(contentLength uint64) (status int, start uint64, end uint64) {
+func (h *handler) handleRange(contentLength uint64, etag string) (status int, start uint64, end uint64) {
 	status = http.StatusOK
 	if h.rq.Method == "GET" || h.rq.Method == "HEAD" {
 		h.setHeader("Accept-Ranges", "bytes")
+		if ifRange := h.rq.Header.Get("If-Range"); ifRange != "" && ifRange != `"`+etag+`"` {
+			return
+		}
 		status, start, end = parseHTTPRangeHeader(h.rq.Header.Get("Range"), contentLength)
 		if status == http.StatusPartialContent {
 			h.setHeader("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, contentLength))
 			h.setStatus(http.StatusPartialContent, "Partial Content")
 			end += 1 // make end non-inclusive, as in Go slices
+		} else if status == http.StatusRequestedRangeNotSatisfiable {
+			h.setHeader("Content-Range", fmt.Sprintf("bytes */%d", contentLength))
 		}
 	}
 	return
@@ -0,0 +1,111 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/db"
+)
+
+const (
+	// idempotencyKeyHeader is the request header a client can set on a document write, so that retries of the write
+	// with the same key are given the original response rather than making the write again.
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	// idempotentReplayedHeader is set on responses replayed for a retried write.
+	idempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// idempotentResponseHeaders are the response headers recorded along with a write's response, to be replayed.
+var idempotentResponseHeaders = []string{"Etag", "Location", consistencyTokenHeader}
+
+// idempotentWrite is a document write made with an idempotency key.  Its methods are safe to call on a nil
+// idempotentWrite, for writes made without a key.
+type idempotentWrite struct {
+	h        *handler
+	key      *db.IdempotencyKey
+	replayed bool // Set if the write's original response was replayed, and the write shouldn't be made
+	recorded bool
+}
+
+// beginIdempotentWrite reserves the request's idempotency key for a write of docID with the given body, if the
+// request has one.  If the key has already been used for the same write, the original response is written and
+// replayed is set on the returned idempotentWrite.  The returned idempotentWrite's release must be called once the
+// write is complete.
+func (h *handler) beginIdempotentWrite(docID string, body db.Body) (*idempotentWrite, error) {
+	key := h.rq.Header.Get(idempotencyKeyHeader)
+	if key == "" {
+		return nil, nil
+	}
+
+	bodyBytes, err := base.JSONMarshalCanonical(body)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	for _, part := range [][]byte{[]byte(h.rq.Method), []byte(docID), []byte(h.rq.URL.RawQuery), bodyBytes} {
+		_, _ = hash.Write(part)
+		_, _ = hash.Write([]byte{0})
+	}
+
+	username := ""
+	if h.user != nil {
+		username = h.user.Name()
+	}
+	keyspace := h.collection.ScopeName + base.ScopeCollectionSeparator + h.collection.Name
+	idempotencyKey, err := h.db.NewIdempotencyKey(username, keyspace, key, hex.EncodeToString(hash.Sum(nil)))
+	if err != nil {
+		return nil, err
+	}
+	response, err := h.db.ReserveIdempotencyKey(h.ctx(), idempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+	write := &idempotentWrite{h: h, key: idempotencyKey}
+	if response != nil {
+		write.replayed = true
+		for name, value := range response.Headers {
+			h.setHeader(name, value)
+		}
+		h.setHeader(idempotentReplayedHeader, "true")
+		h.writeRawJSONStatus(response.Status, response.Body)
+	}
+	return write, nil
+}
+
+// isReplayed returns true if the write's original response has been replayed.
+func (w *idempotentWrite) isReplayed() bool {
+	return w != nil && w.replayed
+}
+
+// writeResponse writes the response to the write, recording it to be replayed for retries.
+func (w *idempotentWrite) writeResponse(h *handler, status int, body []byte) {
+	if w != nil {
+		headers := make(map[string]string)
+		for _, name := range idempotentResponseHeaders {
+			if value := h.response.Header().Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		h.db.RecordIdempotentResponse(h.ctx(), w.key, status, headers, body)
+		w.recorded = true
+	}
+	h.writeRawJSONStatus(status, body)
+}
+
+// release releases the idempotency key if the write failed, so that it can be retried.
+func (w *idempotentWrite) release() {
+	if w == nil || w.replayed || w.recorded {
+		return
+	}
+	w.h.db.ReleaseIdempotencyKey(w.h.ctx(), w.key)
+}
@@ -0,0 +1,88 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotentPostDoc(t *testing.T) {
+	rt := NewRestTester(t, nil)
+	defer rt.Close()
+	headers := map[string]string{idempotencyKeyHeader: "post-1"}
+
+	resp := rt.SendAdminRequestWithHeaders(http.MethodPost, "/{{.keyspace}}/", `{"foo":"bar"}`, headers)
+	RequireStatus(t, resp, http.StatusOK)
+	assert.Empty(t, resp.Header().Get(idempotentReplayedHeader))
+	var first map[string]interface{}
+	require.NoError(t, json.Unmarshal(resp.BodyBytes(), &first))
+	location := resp.Header().Get("Location")
+	require.NotEmpty(t, location)
+
+	// A retry is given the original response, rather than creating another document
+	resp = rt.SendAdminRequestWithHeaders(http.MethodPost, "/{{.keyspace}}/", `{"foo":"bar"}`, headers)
+	RequireStatus(t, resp, http.StatusOK)
+	assert.Equal(t, "true", resp.Header().Get(idempotentReplayedHeader))
+	assert.Equal(t, location, resp.Header().Get("Location"))
+	var retried map[string]interface{}
+	require.NoError(t, json.Unmarshal(resp.BodyBytes(), &retried))
+	assert.Equal(t, first, retried)
+	assert.Equal(t, int64(1), rt.GetDatabase().DbStats.Database().NumIdempotentReplays.Value())
+
+	resp = rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/_all_docs", "")
+	RequireStatus(t, resp, http.StatusOK)
+	var allDocs struct {
+		Rows []interface{} `json:"rows"`
+	}
+	require.NoError(t, json.Unmarshal(resp.BodyBytes(), &allDocs))
+	assert.Len(t, allDocs.Rows, 1)
+
+	// The same key can't be used for a different request
+	resp = rt.SendAdminRequestWithHeaders(http.MethodPost, "/{{.keyspace}}/", `{"foo":"baz"}`, headers)
+	RequireStatus(t, resp, http.StatusUnprocessableEntity)
+	assert.Contains(t, string(resp.BodyBytes()), string(base.ErrorCodeIdempotencyKeyReused))
+
+	resp = rt.SendAdminRequestWithHeaders(http.MethodPost, "/{{.keyspace}}/", `{"foo":"bar"}`, map[string]string{idempotencyKeyHeader: strings.Repeat("a", 256)})
+	RequireStatus(t, resp, http.StatusBadRequest)
+}
+
+func TestIdempotentPutDoc(t *testing.T) {
+	rt := NewRestTester(t, nil)
+	defer rt.Close()
+
+	// A failed write releases the key, so the write can be retried with it
+	headers := map[string]string{idempotencyKeyHeader: "put-1"}
+	resp := rt.SendAdminRequestWithHeaders(http.MethodPut, "/{{.keyspace}}/doc1?rev=1-abc", `{"foo":"bar"}`, headers)
+	RequireStatus(t, resp, http.StatusConflict)
+
+	resp = rt.SendAdminRequestWithHeaders(http.MethodPut, "/{{.keyspace}}/doc1", `{"foo":"bar"}`, headers)
+	RequireStatus(t, resp, http.StatusCreated)
+	etag := resp.Header().Get("Etag")
+	assert.Empty(t, resp.Header().Get(idempotentReplayedHeader))
+
+	// Without the key, a retry would conflict with the revision it created
+	resp = rt.SendAdminRequestWithHeaders(http.MethodPut, "/{{.keyspace}}/doc1", `{"foo":"bar"}`, headers)
+	RequireStatus(t, resp, http.StatusCreated)
+	assert.Equal(t, "true", resp.Header().Get(idempotentReplayedHeader))
+	assert.Equal(t, etag, resp.Header().Get("Etag"))
+	RequireStatus(t, rt.SendAdminRequest(http.MethodPut, "/{{.keyspace}}/doc1", `{"foo":"bar"}`), http.StatusConflict)
+
+	// Keys are scoped to the user
+	rt.CreateUser("alice", []string{"*"})
+	resp = rt.SendUserRequestWithHeaders(http.MethodPut, "/{{.keyspace}}/doc2", `{"foo":"bar"}`, headers, "alice", RestTesterDefaultUserPassword)
+	RequireStatus(t, resp, http.StatusCreated)
+	assert.Empty(t, resp.Header().Get(idempotentReplayedHeader))
+}
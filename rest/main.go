@@ -22,6 +22,7 @@ import (
 
 	"github.com/couchbase/sync_gateway/auth"
 	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/rest/simulate"
 	pkgerrors "github.com/pkg/errors"
 )
 
@@ -30,6 +31,12 @@ import (
 // does the initial setup and finally starts the server.
 func ServerMain() {
 	ctx := context.Background() // main context
+	if len(os.Args) > 1 && os.Args[1] == simulate.SubcommandName {
+		if err := simulate.Main(ctx, os.Args[2:], os.Stdout); err != nil {
+			base.FatalfCtx(ctx, "Simulation failed: %v", err)
+		}
+		return
+	}
 	if err := serverMain(ctx, os.Args); err != nil {
 		base.FatalfCtx(ctx, "Couldn't start Sync Gateway: %v", err)
 	}
@@ -0,0 +1,32 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"crypto/subtle"
+)
+
+// metricsCredentialsConfigured returns true if metrics API requests can be authenticated by a bearer token or client
+// certificate.
+func (c *APIConfig) metricsCredentialsConfigured() bool {
+	return len(c.MetricsBearerTokens) > 0 || c.MetricsClientCACertPath != ""
+}
+
+// hasMetricsCredentials returns true if the request has one of the metrics API's bearer tokens, or a client
+// certificate verified against the metrics API's client CA.
+func (h *handler) hasMetricsCredentials() bool {
+	if token := h.getBearerToken(); token != "" {
+		for _, metricsToken := range h.server.Config.API.MetricsBearerTokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(metricsToken)) == 1 {
+				return true
+			}
+		}
+	}
+	return h.server.Config.API.MetricsClientCACertPath != "" && h.rq.TLS != nil && len(h.rq.TLS.VerifiedChains) > 0
+}
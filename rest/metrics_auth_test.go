@@ -0,0 +1,39 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMetricsBearerTokens(t *testing.T) {
+	rt := NewRestTester(t, &RestTesterConfig{
+		MutateStartupConfig: func(config *StartupConfig) {
+			config.API.MetricsBearerTokens = []string{"token1", "token2"}
+		},
+	})
+	defer rt.Close()
+
+	sendWithToken := func(resource, token string) *TestResponse {
+		request := Request(http.MethodGet, resource, "")
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		return rt.sendMetrics(request)
+	}
+
+	for _, resource := range []string{"/_metrics", "/_expvar"} {
+		// Rejected without a token, even though metrics API authentication is disabled
+		RequireStatus(t, sendWithToken(resource, ""), http.StatusUnauthorized)
+		RequireStatus(t, sendWithToken(resource, "wrong"), http.StatusUnauthorized)
+		RequireStatus(t, sendWithToken(resource, "token1"), http.StatusOK)
+		RequireStatus(t, sendWithToken(resource, "token2"), http.StatusOK)
+	}
+}
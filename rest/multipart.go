@@ -175,6 +175,20 @@ func hasInlineAttachments(body db.Body) bool {
 	return false
 }
 
+// inlineAttachmentsSize returns the total size of the attachment data inlined in a document body, which is buffered
+// when the document is written as a multipart part.
+func inlineAttachmentsSize(body db.Body) int64 {
+	var size int64
+	for _, value := range db.GetBodyAttachments(body) {
+		if meta, ok := value.(map[string]interface{}); ok {
+			if data, ok := meta["data"].([]byte); ok {
+				size += int64(len(data))
+			}
+		}
+	}
+	return size
+}
+
 // Adds a new part to the given multipart writer, containing the given revision.
 // The revision will be written as a nested multipart body if it has attachments.
 func WriteRevisionAsPart(ctx context.Context, cblReplicationPullStats *base.CBLReplicationPullStats, revBody db.Body, isError bool, compressPart bool, writer *multipart.Writer) error {
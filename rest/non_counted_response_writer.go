@@ -31,6 +31,13 @@ func NewNonCountedResponseWriter(writer http.ResponseWriter) *NonCountedResponse
 func (w *NonCountedResponseWriter) reportStats(updateImmediately bool) {
 }
 
+// Flush passes through to the underlying ResponseWriter, if it supports flushing.
+func (w *NonCountedResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 // Hijack implement http.Hijcker interface to satisfy the upgrade to websockets
 func (w *NonCountedResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	h, ok := w.ResponseWriter.(http.Hijacker)
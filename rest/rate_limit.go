@@ -0,0 +1,289 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// Classes of public API endpoint, each rate limited separately.
+const (
+	rateLimitClassAuth    = "auth"    // Session and OpenID Connect/social login endpoints
+	rateLimitClassChanges = "changes" // Changes feeds and BLIP replications
+	rateLimitClassDocs    = "docs"    // Document CRUD and all other database endpoints
+)
+
+// rateLimitAuthEndpoints are the database endpoints in the auth class.
+var rateLimitAuthEndpoints = []string{"_session", "_facebook", "_google", "_oidc", "_oidc_callback", "_oidc_challenge", "_oidc_refresh", "_oidc_testing"}
+
+// rateLimitChangesEndpoints are the database endpoints in the changes class.
+var rateLimitChangesEndpoints = []string{"_changes", "_blipsync"}
+
+// maxRateLimitBuckets is the number of client buckets tracked.  Once reached, the least recently used bucket is only
+// discarded after it has refilled, and requests from new clients are throttled until then.
+const maxRateLimitBuckets = 10000
+
+// rateLimitIPv6PrefixLen is the prefix length of the IPv6 networks limited as a single client, as a client is usually
+// assigned a whole /64 network and could otherwise send each request from a different address.
+const rateLimitIPv6PrefixLen = 64
+
+// RateLimitConfig limits the rate of public API requests from each client IP address.  Each class of endpoint is
+// limited separately, and is unlimited if it has no rule.
+type RateLimitConfig struct {
+	Auth         *RateLimitRuleConfig `json:"auth,omitempty"`          // Limit for session and OpenID Connect/social login endpoints
+	Changes      *RateLimitRuleConfig `json:"changes,omitempty"`       // Limit for changes feeds and BLIP replications
+	Docs         *RateLimitRuleConfig `json:"docs,omitempty"`          // Limit for document CRUD and all other database endpoints
+	AllowedCIDRs []string             `json:"allowed_cidrs,omitempty"` // Client address ranges that aren't rate limited, such as load balancers or trusted networks
+}
+
+// RateLimitRuleConfig is a token bucket limit: requests are allowed at the given rate per second on average, with up
+// to burst requests allowed at once.
+type RateLimitRuleConfig struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst,omitempty"` // Defaults to the rate, rounded up
+}
+
+// validate returns an error if a rule's rate or burst is invalid, or an allowed CIDR can't be parsed.
+func (c *RateLimitConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	var multiError *base.MultiError
+	for class, rule := range c.rules() {
+		if rule == nil {
+			continue
+		}
+		if rule.Rate <= 0 {
+			multiError = multiError.Append(fmt.Errorf("api.rate_limit.%s: rate must be greater than 0", class))
+		}
+		if rule.Burst < 0 {
+			multiError = multiError.Append(fmt.Errorf("api.rate_limit.%s: burst cannot be negative", class))
+		}
+	}
+	for _, cidr := range c.AllowedCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			multiError = multiError.Append(fmt.Errorf("api.rate_limit.allowed_cidrs: %w", err))
+		}
+	}
+	return multiError.ErrorOrNil()
+}
+
+func (c *RateLimitConfig) rules() map[string]*RateLimitRuleConfig {
+	return map[string]*RateLimitRuleConfig{
+		rateLimitClassAuth:    c.Auth,
+		rateLimitClassChanges: c.Changes,
+		rateLimitClassDocs:    c.Docs,
+	}
+}
+
+// burst returns the maximum number of requests allowed at once.
+func (r *RateLimitRuleConfig) burst() float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	return math.Ceil(r.Rate)
+}
+
+// rateLimitKey identifies the token bucket of a client for a class of endpoint.
+type rateLimitKey struct {
+	class  string
+	client string // IPv4 address, or IPv6 network of the client
+}
+
+// tokenBucket holds a client's remaining requests for a class of endpoint, refilling at the rule's rate.  Stored as
+// the Value of an element in the rate limiter's LRU list.
+type tokenBucket struct {
+	key     rateLimitKey
+	tokens  float64
+	updated time.Time
+}
+
+// rateLimiter limits the rate of public API requests from each client IP address, using a token bucket per client
+// and class of endpoint.
+type rateLimiter struct {
+	rules   map[string]*RateLimitRuleConfig
+	allowed []*net.IPNet
+	lock    sync.Mutex
+	buckets map[rateLimitKey]*list.Element // Fast lookup of a bucket's list element by key
+	lruList *list.List                     // Buckets ordered by most recent request (Front is newest)
+	now     func() time.Time               // Returns the current time, overridden by tests
+}
+
+// newRateLimiter returns a rate limiter for the given config, or nil if no limits are configured.
+func newRateLimiter(config *RateLimitConfig) *rateLimiter {
+	if config == nil {
+		return nil
+	}
+	rl := &rateLimiter{
+		rules:   make(map[string]*RateLimitRuleConfig),
+		buckets: make(map[rateLimitKey]*list.Element),
+		lruList: list.New(),
+		now:     time.Now,
+	}
+	for class, rule := range config.rules() {
+		if rule != nil {
+			rl.rules[class] = rule
+		}
+	}
+	if len(rl.rules) == 0 {
+		return nil
+	}
+	// CIDRs have already been validated with the rest of the config
+	for _, cidr := range config.AllowedCIDRs {
+		if _, ipNet, err := net.ParseCIDR(cidr); err == nil {
+			rl.allowed = append(rl.allowed, ipNet)
+		}
+	}
+	return rl
+}
+
+// rateLimitClass returns the class of endpoint a public API request path belongs to, or an empty string for
+// endpoints that aren't rate limited, such as the server root and health checks.
+func rateLimitClass(path string) string {
+	segments := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 3)
+	if segments[0] == "" || strings.HasPrefix(segments[0], "_") {
+		return ""
+	}
+	if len(segments) > 1 {
+		if base.StringSliceContains(rateLimitAuthEndpoints, segments[1]) {
+			return rateLimitClassAuth
+		}
+		if base.StringSliceContains(rateLimitChangesEndpoints, segments[1]) {
+			return rateLimitClassChanges
+		}
+	}
+	return rateLimitClassDocs
+}
+
+// clientIP returns the IP address a request was sent from.
+func clientIP(rq *http.Request) string {
+	host, _, err := net.SplitHostPort(rq.RemoteAddr)
+	if err != nil {
+		return rq.RemoteAddr
+	}
+	return host
+}
+
+// rateLimitClient returns the client an IP address is limited as: the address for IPv4, or its /64 network for IPv6.
+func rateLimitClient(ip string) string {
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return ip
+	}
+	if ipv4 := parsedIP.To4(); ipv4 != nil {
+		return ipv4.String()
+	}
+	mask := net.CIDRMask(rateLimitIPv6PrefixLen, 8*net.IPv6len)
+	return (&net.IPNet{IP: parsedIP.Mask(mask), Mask: mask}).String()
+}
+
+// isAllowed returns true if the client IP is in an allowed CIDR, so isn't rate limited.
+func (rl *rateLimiter) isAllowed(ip string) bool {
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return false
+	}
+	for _, ipNet := range rl.allowed {
+		if ipNet.Contains(parsedIP) {
+			return true
+		}
+	}
+	return false
+}
+
+// allow takes a token from the client's bucket for the class of endpoint.  If the bucket is empty, or there's no
+// room to track a new client, returns false along with how long until the client's next request would be allowed.
+func (rl *rateLimiter) allow(class, ip string) (ok bool, retryAfter time.Duration) {
+	rule, limited := rl.rules[class]
+	if !limited || rl.isAllowed(ip) {
+		return true, 0
+	}
+
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	now := rl.now()
+	key := rateLimitKey{class: class, client: rateLimitClient(ip)}
+	var bucket *tokenBucket
+	if elem, exists := rl.buckets[key]; exists {
+		rl.lruList.MoveToFront(elem)
+		bucket = elem.Value.(*tokenBucket)
+	} else {
+		// A discarded bucket would be recreated full, so the least recently used bucket is only discarded once it
+		// has refilled.  Otherwise clients could reset their own limits by sending requests from enough addresses.
+		if len(rl.buckets) >= maxRateLimitBuckets {
+			oldest := rl.lruList.Back().Value.(*tokenBucket)
+			oldestRule := rl.rules[oldest.key.class]
+			oldest.refill(oldestRule, now)
+			if oldest.tokens < oldestRule.burst() {
+				wait := (oldestRule.burst() - oldest.tokens) / oldestRule.Rate
+				return false, time.Duration(wait * float64(time.Second))
+			}
+			rl.lruList.Remove(rl.lruList.Back())
+			delete(rl.buckets, oldest.key)
+		}
+		bucket = &tokenBucket{key: key, tokens: rule.burst(), updated: now}
+		rl.buckets[key] = rl.lruList.PushFront(bucket)
+	}
+	bucket.refill(rule, now)
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := (1 - bucket.tokens) / rule.Rate
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+// refill adds the tokens accumulated since the bucket was last updated, up to the rule's burst.
+func (b *tokenBucket) refill(rule *RateLimitRuleConfig, now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(rule.burst(), b.tokens+elapsed*rule.Rate)
+		b.updated = now
+	}
+}
+
+// checkRequest takes a token from the client's bucket for the class of endpoint a public API request belongs to.  If
+// the client has gone over the rate limit, writes a 429 response with the CORS headers of the database named in the
+// route variables, and returns false.
+func (rl *rateLimiter) checkRequest(sc *ServerContext, response http.ResponseWriter, rq *http.Request, vars map[string]string) bool {
+	class := rateLimitClass(rq.URL.Path)
+	if class == "" {
+		return true
+	}
+	ok, retryAfter := rl.allow(class, clientIP(rq))
+	if ok {
+		return true
+	}
+	base.SyncGatewayStats.GlobalStats.ResourceUtilizationStats().RateLimitedRequestCount.Add(1)
+	h := newHandler(sc, regularPrivs, response, rq, handlerOptions{})
+	h.logRequestLine()
+	keyspace := vars["keyspace"]
+	if keyspace == "" {
+		keyspace = vars["db"]
+	}
+	if cors := keyspaceCORSConfig(sc, keyspace); cors != nil {
+		cors.AddResponseHeaders(rq, response)
+	}
+	h.setHeader("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	h.writeStatus(http.StatusTooManyRequests, fmt.Sprintf("Too many %s requests from this client. Retry the request later.", class))
+	h.logDuration(true)
+	return false
+}
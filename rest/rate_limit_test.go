@@ -0,0 +1,171 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitClass(t *testing.T) {
+	testCases := map[string]string{
+		"/":                             "",
+		"/_ping":                        "",
+		"/_expvar":                      "",
+		"/db/":                          rateLimitClassDocs,
+		"/db/doc1":                      rateLimitClassDocs,
+		"/db.scope.collection/doc1":     rateLimitClassDocs,
+		"/db/_bulk_docs":                rateLimitClassDocs,
+		"/db/_session":                  rateLimitClassAuth,
+		"/db/_oidc_callback":            rateLimitClassAuth,
+		"/db/_changes":                  rateLimitClassChanges,
+		"/db.scope.collection/_changes": rateLimitClassChanges,
+		"/db/_blipsync":                 rateLimitClassChanges,
+	}
+	for path, expected := range testCases {
+		assert.Equal(t, expected, rateLimitClass(path), "path %s", path)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	config := &RateLimitConfig{
+		Auth:         &RateLimitRuleConfig{Rate: 1, Burst: 2},
+		Docs:         &RateLimitRuleConfig{Rate: 0.5},
+		AllowedCIDRs: []string{"10.0.0.0/8"},
+	}
+	require.NoError(t, config.validate())
+	rl := newRateLimiter(config)
+	require.NotNil(t, rl)
+	now := time.Now()
+	rl.now = func() time.Time { return now }
+
+	// The burst is allowed at once, then requests are rejected until a token is refilled
+	for i := 0; i < 2; i++ {
+		ok, _ := rl.allow(rateLimitClassAuth, "192.0.2.1")
+		assert.True(t, ok)
+	}
+	ok, retryAfter := rl.allow(rateLimitClassAuth, "192.0.2.1")
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, retryAfter)
+
+	// Other clients and classes have their own buckets, and classes without a rule aren't limited
+	ok, _ = rl.allow(rateLimitClassAuth, "192.0.2.2")
+	assert.True(t, ok)
+	ok, _ = rl.allow(rateLimitClassDocs, "192.0.2.1")
+	assert.True(t, ok)
+	for i := 0; i < 10; i++ {
+		ok, _ = rl.allow(rateLimitClassChanges, "192.0.2.1")
+		assert.True(t, ok)
+	}
+
+	// The burst defaults to the rate rounded up, and tokens refill at the rate
+	ok, retryAfter = rl.allow(rateLimitClassDocs, "192.0.2.1")
+	assert.False(t, ok)
+	assert.Equal(t, 2*time.Second, retryAfter)
+	now = now.Add(2 * time.Second)
+	ok, _ = rl.allow(rateLimitClassDocs, "192.0.2.1")
+	assert.True(t, ok)
+
+	// Allowed CIDRs aren't limited
+	for i := 0; i < 10; i++ {
+		ok, _ = rl.allow(rateLimitClassAuth, "10.1.2.3")
+		assert.True(t, ok)
+	}
+
+	// IPv6 clients are limited by /64 network
+	for i := 0; i < 2; i++ {
+		ok, _ = rl.allow(rateLimitClassAuth, "2001:db8::1")
+		assert.True(t, ok)
+	}
+	ok, _ = rl.allow(rateLimitClassAuth, "2001:db8::ffff:2")
+	assert.False(t, ok)
+	ok, _ = rl.allow(rateLimitClassAuth, "2001:db8:0:1::1")
+	assert.True(t, ok)
+	assert.Equal(t, "192.0.2.1", rateLimitClient("::ffff:192.0.2.1"))
+
+	// Once the maximum number of buckets is tracked, new clients are throttled until the least recently used bucket
+	// has refilled, rather than discarding the buckets of limited clients
+	rl = newRateLimiter(config)
+	rl.now = func() time.Time { return now }
+	for i := 0; i < maxRateLimitBuckets; i++ {
+		ok, _ = rl.allow(rateLimitClassAuth, fmt.Sprintf("198.51.%d.%d", i/256, i%256))
+		assert.True(t, ok)
+	}
+	assert.Len(t, rl.buckets, maxRateLimitBuckets)
+	assert.Equal(t, maxRateLimitBuckets, rl.lruList.Len())
+	ok, retryAfter = rl.allow(rateLimitClassAuth, "192.0.2.3")
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, retryAfter)
+	assert.NotContains(t, rl.buckets, rateLimitKey{class: rateLimitClassAuth, client: "192.0.2.3"})
+	assert.Contains(t, rl.buckets, rateLimitKey{class: rateLimitClassAuth, client: "198.51.0.0"})
+
+	now = now.Add(time.Second)
+	ok, _ = rl.allow(rateLimitClassAuth, "192.0.2.3")
+	assert.True(t, ok)
+	assert.Contains(t, rl.buckets, rateLimitKey{class: rateLimitClassAuth, client: "192.0.2.3"})
+	assert.NotContains(t, rl.buckets, rateLimitKey{class: rateLimitClassAuth, client: "198.51.0.0"})
+	assert.Len(t, rl.buckets, maxRateLimitBuckets)
+
+	// No rules means no limiter
+	assert.Nil(t, newRateLimiter(&RateLimitConfig{AllowedCIDRs: []string{"10.0.0.0/8"}}))
+	assert.Nil(t, newRateLimiter(nil))
+}
+
+func TestRateLimitConfigValidate(t *testing.T) {
+	config := &RateLimitConfig{
+		Auth:         &RateLimitRuleConfig{Rate: 0},
+		Changes:      &RateLimitRuleConfig{Rate: 1, Burst: -1},
+		AllowedCIDRs: []string{"10.0.0.0/8", "10.0.0.1"},
+	}
+	err := config.validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "api.rate_limit.auth: rate must be greater than 0")
+	assert.Contains(t, err.Error(), "api.rate_limit.changes: burst cannot be negative")
+	assert.Contains(t, err.Error(), "api.rate_limit.allowed_cidrs")
+	assert.NotContains(t, err.Error(), "10.0.0.0/8")
+}
+
+func TestRateLimitPublicAPI(t *testing.T) {
+	rt := NewRestTester(t, nil)
+	defer rt.Close()
+
+	rt.ServerContext().rateLimiter = newRateLimiter(&RateLimitConfig{
+		Docs: &RateLimitRuleConfig{Rate: 0.001, Burst: 1},
+	})
+	rateLimited := base.SyncGatewayStats.GlobalStats.ResourceUtilizationStats().RateLimitedRequestCount
+	rateLimitedBefore := rateLimited.Value()
+
+	sendFrom := func(remoteAddr, resource string) *TestResponse {
+		request := Request(http.MethodGet, rt.mustTemplateResource(resource), "")
+		request.RemoteAddr = remoteAddr
+		request.Header.Set("Origin", "http://example.com")
+		return rt.Send(request)
+	}
+
+	response := sendFrom("192.0.2.1:5000", "/{{.keyspace}}/doc1")
+	RequireStatus(t, response, http.StatusUnauthorized)
+	response = sendFrom("192.0.2.1:5001", "/{{.keyspace}}/doc1")
+	RequireStatus(t, response, http.StatusTooManyRequests)
+	assert.Equal(t, "1000", response.Header().Get("Retry-After"))
+	// Rejected requests get the database's CORS headers, so browser clients can see the response
+	assert.Equal(t, "http://example.com", response.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, rateLimitedBefore+1, rateLimited.Value())
+
+	// Another client, and health checks, aren't affected
+	response = sendFrom("192.0.2.2:5000", "/{{.keyspace}}/doc1")
+	RequireStatus(t, response, http.StatusUnauthorized)
+	response = sendFrom("192.0.2.1:5002", "/")
+	RequireStatus(t, response, http.StatusOK)
+}
@@ -14,6 +14,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/couchbase/sync_gateway/auth"
 	"github.com/gorilla/mux"
 )
 
@@ -43,6 +44,8 @@ func createCommonRouter(sc *ServerContext, privs handlerPrivs) (root, db, keyspa
 	// Global operations:
 	root.Handle("/", makeHandler(sc, privs, nil, nil, (*handler).handleRoot)).Methods("GET", "HEAD")
 	root.Handle("/_ping", makeSilentHandler(sc, publicPrivs, nil, nil, (*handler).handlePing)).Methods("GET", "HEAD")
+	root.Handle("/_health", makeSilentHandler(sc, publicPrivs, nil, nil, (*handler).handleHealth)).Methods("GET", "HEAD")
+	root.Handle("/_ready", makeSilentHandler(sc, publicPrivs, nil, nil, (*handler).handleReady)).Methods("GET", "HEAD")
 
 	// Operations on databases:
 	root.Handle("/{db:"+dbRegex+"}/", makeOfflineHandler(sc, privs, []Permission{PermDevOps}, nil, (*handler).handleGetDB)).Methods("GET", "HEAD")
@@ -82,6 +85,9 @@ func createCommonRouter(sc *ServerContext, privs handlerPrivs) (root, db, keyspa
 	// These have public privileges so that they can be called without being logged in already
 	dbr.Handle("/_session", makeHandler(sc, publicPrivs, nil, nil, (*handler).handleSessionGET)).Methods("GET", "HEAD")
 
+	// Capabilities are public so that clients can check them before logging in
+	dbr.Handle("/_capabilities", makeHandler(sc, publicPrivs, nil, nil, (*handler).handleGetCapabilities)).Methods("GET")
+
 	if sc.Config.DeprecatedConfig != nil {
 		if sc.Config.DeprecatedConfig.Facebook != nil {
 			dbr.Handle("/_facebook", makeHandler(sc, publicPrivs, nil, nil, (*handler).handleFacebookPOST)).Methods("POST")
@@ -124,6 +130,9 @@ func createCommonRouter(sc *ServerContext, privs handlerPrivs) (root, db, keyspa
 
 // CreatePublicHandler Creates the HTTP handler for the public API of a gateway server.
 func CreatePublicHandler(sc *ServerContext) http.Handler {
+	if sc.Config.API.replicationOnly() {
+		return wrapRouter(sc, regularPrivs, createReplicationOnlyRouter(sc))
+	}
 	r, dbr, _ := createCommonRouter(sc, regularPrivs)
 
 	dbr.Handle("/_session", makeHandler(sc, publicPrivs, nil, nil, (*handler).handleSessionPOST)).Methods("POST")
@@ -136,14 +145,52 @@ func CreatePublicHandler(sc *ServerContext) http.Handler {
 	return wrapRouter(sc, regularPrivs, r)
 }
 
+// createReplicationOnlyRouter creates the router for the public API when running as a passive replication target.
+// Only the BLIP sync endpoint, sessions for authenticating replications, and health checks are served.
+func createReplicationOnlyRouter(sc *ServerContext) *mux.Router {
+	root := mux.NewRouter()
+	root.StrictSlash(true)
+	root.Handle("/", makeHandler(sc, regularPrivs, nil, nil, (*handler).handleRoot)).Methods("GET", "HEAD")
+	root.Handle("/_ping", makeSilentHandler(sc, publicPrivs, nil, nil, (*handler).handlePing)).Methods("GET", "HEAD")
+	root.Handle("/_health", makeSilentHandler(sc, publicPrivs, nil, nil, (*handler).handleHealth)).Methods("GET", "HEAD")
+	root.Handle("/_ready", makeSilentHandler(sc, publicPrivs, nil, nil, (*handler).handleReady)).Methods("GET", "HEAD")
+
+	dbr := root.PathPrefix("/{db:" + dbRegex + "}/").Subrouter()
+	dbr.StrictSlash(true)
+	dbr.Handle("/_blipsync", makeHandler(sc, regularPrivs, []Permission{PermWriteAppData}, nil, (*handler).handleBLIPSync)).Methods("GET")
+	dbr.Handle("/_session", makeHandler(sc, publicPrivs, nil, nil, (*handler).handleSessionGET)).Methods("GET", "HEAD")
+	dbr.Handle("/_session", makeHandler(sc, publicPrivs, nil, nil, (*handler).handleSessionPOST)).Methods("POST")
+	dbr.Handle("/_session", makeHandler(sc, regularPrivs, nil, nil, (*handler).handleSessionDELETE)).Methods("DELETE")
+	return root
+}
+
 // ////// ADMIN API:
 
 // CreateAdminHandler Creates the HTTP handler for the PRIVATE admin API of a gateway server.
 func CreateAdminHandler(sc *ServerContext) http.Handler {
 	router := CreateAdminRouter(sc)
+	if sc.Config.API.replicationOnly() {
+		return readOnlyAdminHandler(sc, wrapRouter(sc, adminPrivs, router))
+	}
 	return wrapRouter(sc, adminPrivs, router)
 }
 
+// readOnlyAdminHandler rejects admin API requests that could make changes, when running as a passive replication
+// target.
+func readOnlyAdminHandler(sc *ServerContext, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, rq *http.Request) {
+		switch rq.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(response, rq)
+			return
+		}
+		h := newHandler(sc, adminPrivs, response, rq, handlerOptions{})
+		h.logRequestLine()
+		h.writeStatus(http.StatusForbidden, "The admin API is read-only when api.replication_only is enabled")
+		h.logDuration(true)
+	})
+}
+
 // CreateAdminRouter Creates the HTTP handler for the PRIVATE admin API of a gateway server.
 func CreateAdminRouter(sc *ServerContext) *mux.Router {
 	r, dbr, keyspace := createCommonRouter(sc, adminPrivs)
@@ -155,8 +202,32 @@ func CreateAdminRouter(sc *ServerContext) *mux.Router {
 		makeHandler(sc, adminPrivs, []Permission{PermReadAppData}, nil, (*handler).handleGetRawDoc)).Methods("GET", "HEAD")
 	keyspace.Handle("/_revtree/{docid:"+docRegex+"}",
 		makeHandler(sc, adminPrivs, []Permission{PermReadAppData}, nil, (*handler).handleGetRevTree)).Methods("GET")
+	keyspace.Handle("/_access/{docid:"+docRegex+"}",
+		makeHandler(sc, adminPrivs, []Permission{PermReadAppData}, nil, (*handler).handleGetDocAccess)).Methods("GET")
 	keyspace.Handle("/_dumpchannel/{channel}",
 		makeHandler(sc, adminPrivs, []Permission{PermReadAppData}, nil, (*handler).handleDumpChannel)).Methods("GET")
+	keyspace.Handle("/_export_channel/{channel}",
+		makeHandler(sc, adminPrivs, []Permission{PermReadAppData}, nil, (*handler).handleExportChannel)).Methods("GET")
+	keyspace.Handle("/_import_archive",
+		makeHandler(sc, adminPrivs, []Permission{PermWriteAppData}, nil, (*handler).handleImportArchive)).Methods("POST")
+	keyspace.Handle("/_channel_sizes",
+		makeHandler(sc, adminPrivs, []Permission{PermReadAppData}, nil, (*handler).handleGetChannelSizes)).Methods("GET")
+	keyspace.Handle("/_import_quarantine/{docid:"+docRegex+"}",
+		makeHandler(sc, adminPrivs, []Permission{PermWriteAppData}, nil, (*handler).handleRetryQuarantinedImport)).Methods("POST")
+	keyspace.Handle("/{docid:"+docRegex+"}/_resync",
+		makeHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handlePostDocResync)).Methods("POST")
+	keyspace.Handle("/{docid:"+docRegex+"}/_repair_revtree",
+		makeHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handlePostRepairRevTree)).Methods("POST")
+	keyspace.Handle("/_migrate_checkpoints",
+		makeHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handleMigrateCheckpoints)).Methods("POST")
+	keyspace.Handle("/_verify_checkpoint",
+		makeHandler(sc, adminPrivs, []Permission{PermReadAppData}, nil, (*handler).handleVerifyCheckpoint)).Methods("POST")
+	keyspace.Handle("/_rev_cache/pinned",
+		makeHandler(sc, adminPrivs, []Permission{PermReadAppData}, nil, (*handler).handleGetPinnedRevCacheDocs)).Methods("GET")
+	keyspace.Handle("/_rev_cache/pinned/{docid:"+docRegex+"}",
+		makeHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handlePinRevCacheDoc)).Methods("PUT")
+	keyspace.Handle("/_rev_cache/pinned/{docid:"+docRegex+"}",
+		makeHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handleUnpinRevCacheDoc)).Methods("DELETE")
 
 	// Database handlers (multi collection):
 	dbr.Handle("/_resync",
@@ -167,6 +238,42 @@ func CreateAdminRouter(sc *ServerContext) *mux.Router {
 		makeHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handleCompact)).Methods("POST")
 	dbr.Handle("/_compact",
 		makeHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handleGetCompact)).Methods("GET")
+	dbr.Handle("/_attachment_verify",
+		makeHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handleAttachmentVerify)).Methods("POST")
+	dbr.Handle("/_attachment_verify",
+		makeHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handleGetAttachmentVerify)).Methods("GET")
+	dbr.Handle("/_import_backfill",
+		makeHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handleImportBackfill)).Methods("POST")
+	dbr.Handle("/_import_backfill",
+		makeHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handleGetImportBackfill)).Methods("GET")
+	dbr.Handle("/_channel_rename",
+		makeHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handleChannelRename)).Methods("POST")
+	dbr.Handle("/_channel_rename",
+		makeHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handleGetChannelRename)).Methods("GET")
+	dbr.Handle("/_background_tasks",
+		makeOfflineHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handleGetBackgroundTasks)).Methods("GET")
+	dbr.Handle("/_background_tasks",
+		makeOfflineHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handleBackgroundTasks)).Methods("POST")
+	dbr.Handle("/_maintenance_window",
+		makeHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handleGetMaintenanceWindow)).Methods("GET")
+	dbr.Handle("/_maintenance_window",
+		makeHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handlePutMaintenanceWindow)).Methods("PUT")
+	dbr.Handle("/_sequence_report",
+		makeHandler(sc, adminPrivs, []Permission{PermReadAppData}, nil, (*handler).handleGetSequenceReport)).Methods("GET")
+	dbr.Handle("/_release_sequences",
+		makeHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handleReleaseSequences)).Methods("POST")
+	dbr.Handle("/_import_quarantine",
+		makeHandler(sc, adminPrivs, []Permission{PermReadAppData}, nil, (*handler).handleGetImportQuarantine)).Methods("GET")
+	dbr.Handle("/_connected_clients",
+		makeHandler(sc, adminPrivs, []Permission{PermReadAppData}, nil, (*handler).handleGetConnectedClients)).Methods("GET")
+	dbr.Handle("/_replication_errors",
+		makeHandler(sc, adminPrivs, []Permission{PermReadAppData}, nil, (*handler).handleGetReplicationErrors)).Methods("GET")
+	dbr.Handle("/_flight_recorder",
+		makeHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handleGetFlightRecorder)).Methods("GET")
+	dbr.Handle("/_flight_recorder",
+		makeHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handlePostFlightRecorder)).Methods("POST")
+	dbr.Handle("/_flight_recorder",
+		makeHandler(sc, adminPrivs, []Permission{PermUpdateDb}, nil, (*handler).handleDeleteFlightRecorder)).Methods("DELETE")
 	dbr.Handle("/_session",
 		makeHandler(sc, adminPrivs, []Permission{PermWritePrincipal}, nil, (*handler).createUserSession)).Methods("POST")
 	dbr.Handle("/_session/{sessionid}",
@@ -189,6 +296,18 @@ func CreateAdminRouter(sc *ServerContext) *mux.Router {
 		makeHandler(sc, adminPrivs, []Permission{PermWritePrincipal}, nil, (*handler).deleteUserSessions)).Methods("DELETE")
 	dbr.Handle("/_user/{name}/_session/{sessionid}",
 		makeHandler(sc, adminPrivs, []Permission{PermWritePrincipal}, nil, (*handler).deleteUserSession)).Methods("DELETE")
+	dbr.Handle("/_user/{name}/_collection_access/{scope}/{collection}",
+		makeHandler(sc, adminPrivs, []Permission{PermReadPrincipal}, []Permission{PermReadPrincipalAppData}, (*handler).getUserCollectionAccess)).Methods("GET", "HEAD")
+	dbr.Handle("/_user/{name}/_collection_access/{scope}/{collection}",
+		makeHandler(sc, adminPrivs, []Permission{PermWritePrincipal}, nil, (*handler).putUserCollectionAccess)).Methods("PUT")
+	dbr.Handle("/_user/{name}/_collection_access/{scope}/{collection}",
+		makeHandler(sc, adminPrivs, []Permission{PermWritePrincipal}, nil, (*handler).deleteUserCollectionAccess)).Methods("DELETE")
+	dbr.Handle("/_user/{name}/_quota",
+		makeHandler(sc, adminPrivs, []Permission{PermReadPrincipal}, nil, (*handler).getUserQuota)).Methods("GET", "HEAD")
+	dbr.Handle("/_user/{name}/_quota",
+		makeHandler(sc, adminPrivs, []Permission{PermWritePrincipal}, nil, (*handler).deleteUserQuota)).Methods("DELETE")
+	dbr.Handle("/_user/{name}/_access_snapshot",
+		makeHandler(sc, adminPrivs, []Permission{PermReadPrincipal}, nil, (*handler).getUserAccessSnapshot)).Methods("GET", "HEAD")
 
 	dbr.Handle("/_role/",
 		makeHandler(sc, adminPrivs, []Permission{PermReadPrincipal}, nil, (*handler).getRoles)).Methods("GET", "HEAD")
@@ -200,6 +319,12 @@ func CreateAdminRouter(sc *ServerContext) *mux.Router {
 		makeHandler(sc, adminPrivs, []Permission{PermWritePrincipal}, nil, (*handler).putRole)).Methods("PUT")
 	dbr.Handle("/_role/{name}",
 		makeHandler(sc, adminPrivs, []Permission{PermWritePrincipal}, nil, (*handler).deleteRole)).Methods("DELETE")
+	dbr.Handle("/_role/{name}/_collection_access/{scope}/{collection}",
+		makeHandler(sc, adminPrivs, []Permission{PermReadPrincipal}, []Permission{PermReadPrincipalAppData}, (*handler).getRoleCollectionAccess)).Methods("GET", "HEAD")
+	dbr.Handle("/_role/{name}/_collection_access/{scope}/{collection}",
+		makeHandler(sc, adminPrivs, []Permission{PermWritePrincipal}, nil, (*handler).putRoleCollectionAccess)).Methods("PUT")
+	dbr.Handle("/_role/{name}/_collection_access/{scope}/{collection}",
+		makeHandler(sc, adminPrivs, []Permission{PermWritePrincipal}, nil, (*handler).deleteRoleCollectionAccess)).Methods("DELETE")
 
 	dbr.Handle("/_replication/",
 		makeHandler(sc, adminPrivs, []Permission{PermReadReplications}, nil, (*handler).getReplications)).Methods("GET", "HEAD")
@@ -259,6 +384,9 @@ func CreateAdminRouter(sc *ServerContext) *mux.Router {
 	r.Handle("/_config",
 		makeHandler(sc, adminPrivs, []Permission{PermDevOps}, nil, (*handler).handlePutConfig)).Methods("PUT")
 
+	r.Handle("/_reload_tls_certificate",
+		makeHandler(sc, adminPrivs, []Permission{PermDevOps}, nil, (*handler).handleReloadTLSCertificate)).Methods("POST")
+
 	r.Handle("/_cluster_info",
 		makeHandler(sc, adminPrivs, []Permission{PermDevOps}, nil, (*handler).handleGetClusterInfo)).Methods("GET")
 
@@ -349,6 +477,8 @@ func CreateMetricRouter(sc *ServerContext) *mux.Router {
 	r := mux.NewRouter()
 	r.StrictSlash(true)
 	r.Handle("/_ping", makeSilentHandler(sc, publicPrivs, nil, nil, (*handler).handlePing)).Methods("GET", "HEAD")
+	r.Handle("/_health", makeSilentHandler(sc, publicPrivs, nil, nil, (*handler).handleHealth)).Methods("GET", "HEAD")
+	r.Handle("/_ready", makeSilentHandler(sc, publicPrivs, nil, nil, (*handler).handleReady)).Methods("GET", "HEAD")
 
 	r.Handle("/metrics", makeSilentHandler(sc, metricsPrivs, []Permission{PermStatsExport}, nil, (*handler).handleMetrics)).Methods("GET")
 	r.Handle("/_metrics", makeSilentHandler(sc, metricsPrivs, []Permission{PermStatsExport}, nil, (*handler).handleMetrics)).Methods("GET")
@@ -364,7 +494,11 @@ func wrapRouter(sc *ServerContext, privs handlerPrivs, router *mux.Router) http.
 	return http.HandlerFunc(func(response http.ResponseWriter, rq *http.Request) {
 		FixQuotedSlashes(rq)
 		var match mux.RouteMatch
-		if router.Match(rq, &match) {
+		matched := router.Match(rq, &match)
+		if privs == regularPrivs && sc.rateLimiter != nil && !sc.rateLimiter.checkRequest(sc, response, rq, match.Vars) {
+			return
+		}
+		if matched {
 			router.ServeHTTP(response, rq)
 		} else {
 			// Log the request
@@ -385,14 +519,7 @@ func wrapRouter(sc *ServerContext, privs handlerPrivs, router *mux.Router) http.
 				}
 			}
 
-			cors := sc.Config.API.CORS
-			dbName, _, _, _ := ParseKeyspace(keyspace)
-			if dbName != "" {
-				db, err := h.server.GetActiveDatabase(dbName)
-				if err == nil {
-					cors = db.CORS
-				}
-			}
+			cors := keyspaceCORSConfig(sc, keyspace)
 			if cors != nil && privs != adminPrivs && privs != metricsPrivs {
 				cors.AddResponseHeaders(rq, response)
 			}
@@ -416,6 +543,18 @@ func wrapRouter(sc *ServerContext, privs handlerPrivs, router *mux.Router) http.
 	})
 }
 
+// keyspaceCORSConfig returns the CORS config of the database in the keyspace, or the server's CORS config if the
+// keyspace doesn't name an active database.
+func keyspaceCORSConfig(sc *ServerContext, keyspace string) *auth.CORSConfig {
+	dbName, _, _, _ := ParseKeyspace(keyspace)
+	if dbName != "" {
+		if db, err := sc.GetActiveDatabase(dbName); err == nil {
+			return db.CORS
+		}
+	}
+	return sc.Config.API.CORS
+}
+
 func FixQuotedSlashes(rq *http.Request) {
 	uri := rq.RequestURI
 	if docWithSlashPathRegex.MatchString(uri) {
@@ -57,24 +57,30 @@ type ServerContext struct {
 	persistentConfig              bool
 	dbRegistry                    map[string]struct{}               // registry of dbNames, used to ensure uniqueness even when db isn't active
 	collectionRegistry            map[string]string                 // map of fully qualified collection name to db name, used for local uniqueness checks
+	dbAliases                     map[string]string                 // map of database alias to the name of the database it's served as
 	dbConfigs                     map[string]*RuntimeDatabaseConfig // dbConfigs is a map of db name to the RuntimeDatabaseConfig
 	databases_                    map[string]*db.DatabaseContext    // databases_ is a map of dbname to db.DatabaseContext
 	lock                          sync.RWMutex
 	statsContext                  *statsContext
 	BootstrapContext              *bootstrapContext
 	HTTPClient                    *http.Client
-	cpuPprofFileMutex             sync.Mutex           // Protect cpuPprofFile from concurrent Start and Stop CPU profiling requests
-	cpuPprofFile                  *os.File             // An open file descriptor holds the reference during CPU profiling
-	_httpServers                  []*http.Server       // A list of HTTP servers running under the ServerContext
-	GoCBAgent                     *gocbcore.Agent      // GoCB Agent to use when obtaining management endpoints
-	NoX509HTTPClient              *http.Client         // httpClient for the cluster that doesn't include x509 credentials, even if they are configured for the cluster
-	hasStarted                    chan struct{}        // A channel that is closed via PostStartup once the ServerContext has fully started
-	LogContextID                  string               // ID to differentiate log messages from different server context
-	fetchConfigsLastUpdate        time.Time            // The last time fetchConfigsWithTTL() updated dbConfigs
-	allowScopesInPersistentConfig bool                 // Test only backdoor to allow scopes in persistent config, not supported for multiple databases with different collections targeting the same bucket
-	DatabaseInitManager           *DatabaseInitManager // Manages database initialization (index creation and readiness) independent of database stop/start/reload, when using persistent config
+	cpuPprofFileMutex             sync.Mutex                // Protect cpuPprofFile from concurrent Start and Stop CPU profiling requests
+	cpuPprofFile                  *os.File                  // An open file descriptor holds the reference during CPU profiling
+	_httpServers                  []*http.Server            // A list of HTTP servers running under the ServerContext
+	_certReloader                 *base.CertificateReloader // Serves the TLS certificate for the HTTP servers, allowing hot reloads
+	serverDNSWatcher              *base.ServerDNSWatcher    // Re-resolves the Couchbase Server connection string, reconnecting when it changes
+	GoCBAgent                     *gocbcore.Agent           // GoCB Agent to use when obtaining management endpoints
+	NoX509HTTPClient              *http.Client              // httpClient for the cluster that doesn't include x509 credentials, even if they are configured for the cluster
+	hasStarted                    chan struct{}             // A channel that is closed via PostStartup once the ServerContext has fully started
+	LogContextID                  string                    // ID to differentiate log messages from different server context
+	fetchConfigsLastUpdate        time.Time                 // The last time fetchConfigsWithTTL() updated dbConfigs
+	allowScopesInPersistentConfig bool                      // Test only backdoor to allow scopes in persistent config, not supported for multiple databases with different collections targeting the same bucket
+	DatabaseInitManager           *DatabaseInitManager      // Manages database initialization (index creation and readiness) independent of database stop/start/reload, when using persistent config
 	ActiveReplicationsCounter
 	invalidDatabaseConfigTracking invalidDatabaseConfigs
+	blipSyncContexts              blipSyncContextRegistry // BLIP replication connections open on the node, drained on shutdown
+	bulkMemory                    *bulkMemoryGuard        // Limits the memory used by in-flight bulk requests
+	rateLimiter                   *rateLimiter            // Limits the rate of public API requests from each client IP, if configured
 }
 
 type ActiveReplicationsCounter struct {
@@ -138,6 +144,7 @@ func NewServerContext(ctx context.Context, config *StartupConfig, persistentConf
 		persistentConfig:   persistentConfig,
 		dbRegistry:         map[string]struct{}{},
 		collectionRegistry: map[string]string{},
+		dbAliases:          map[string]string{},
 		dbConfigs:          map[string]*RuntimeDatabaseConfig{},
 		databases_:         map[string]*db.DatabaseContext{},
 		HTTPClient:         http.DefaultClient,
@@ -162,6 +169,11 @@ func NewServerContext(ctx context.Context, config *StartupConfig, persistentConf
 	if config.Replicator.MaxConcurrentReplications != 0 {
 		sc.ActiveReplicationsCounter.activeReplicatorLimit = config.Replicator.MaxConcurrentReplications
 	}
+	sc.bulkMemory = newBulkMemoryGuard(
+		int64(base.IntDefault(config.API.MaxBulkRequestMemory, DefaultMaxBulkRequestMemory)),
+		int64(base.IntDefault(config.API.MaxBulkMemory, DefaultMaxBulkMemory)),
+	)
+	sc.rateLimiter = newRateLimiter(config.API.RateLimit)
 
 	if sc.persistentConfig {
 		sc.DatabaseInitManager = &DatabaseInitManager{}
@@ -237,6 +249,20 @@ func (sc *ServerContext) Close(ctx context.Context) {
 	}
 	sc._httpServers = nil
 
+	if sc._certReloader != nil {
+		if err := sc._certReloader.StopWatching(serverContextStopMaxWait); err != nil {
+			base.InfofCtx(ctx, base.KeyAll, "Couldn't stop TLS certificate watcher: %v", err)
+		}
+		sc._certReloader = nil
+	}
+
+	if sc.serverDNSWatcher != nil {
+		if err := sc.serverDNSWatcher.StopWatching(serverContextStopMaxWait); err != nil {
+			base.InfofCtx(ctx, base.KeyAll, "Couldn't stop Couchbase Server address watcher: %v", err)
+		}
+		sc.serverDNSWatcher = nil
+	}
+
 	if agent := sc.GoCBAgent; agent != nil {
 		if err := agent.Close(); err != nil {
 			base.WarnfCtx(ctx, "Error closing agent connection: %v", err)
@@ -258,7 +284,7 @@ func (sc *ServerContext) GetDatabase(ctx context.Context, name string) (*db.Data
 // validated to make sure it's valid and then an error returned.
 func (sc *ServerContext) GetActiveDatabase(name string) (*db.DatabaseContext, error) {
 	sc.lock.RLock()
-	dbc := sc.databases_[name]
+	dbc := sc.databases_[sc._resolveDatabaseAlias(name)]
 	sc.lock.RUnlock()
 	if dbc != nil {
 		return dbc, nil
@@ -272,6 +298,7 @@ func (sc *ServerContext) GetActiveDatabase(name string) (*db.DatabaseContext, er
 // database, and if that fails, try to load the database from the buckets.
 // This should be used if GetActiveDatabase fails. Turns the database context, a variable to say if the config exists, and an error.
 func (sc *ServerContext) GetInactiveDatabase(ctx context.Context, name string) (*db.DatabaseContext, bool, error) {
+	name = sc.resolveDatabaseAlias(name)
 	dbc, err := sc.unsuspendDatabase(ctx, name)
 	if err != nil && err != base.ErrNotFound && err != ErrSuspendingDisallowed {
 		return nil, false, err
@@ -309,6 +336,44 @@ func (sc *ServerContext) GetInactiveDatabase(ctx context.Context, name string) (
 	return nil, dbConfigFound, httpErr
 }
 
+// resolveDatabaseAlias returns the name of the database served under the given alias, or the name itself if it isn't
+// an alias.
+func (sc *ServerContext) resolveDatabaseAlias(name string) string {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	return sc._resolveDatabaseAlias(name)
+}
+
+func (sc *ServerContext) _resolveDatabaseAlias(name string) string {
+	if dbName, ok := sc.dbAliases[name]; ok {
+		return dbName
+	}
+	return name
+}
+
+// checkDatabaseAliases returns an error if the database's name or aliases are already in use by another database.
+func (sc *ServerContext) checkDatabaseAliases(dbName string, aliases []string) error {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	return sc._checkDatabaseAliases(dbName, aliases)
+}
+
+// _checkDatabaseAliases returns an error if the database's name or aliases are already in use by another database.
+func (sc *ServerContext) _checkDatabaseAliases(dbName string, aliases []string) error {
+	if aliasOf, ok := sc.dbAliases[dbName]; ok && aliasOf != dbName {
+		return base.HTTPErrorf(http.StatusPreconditionFailed, "Database name %q is already an alias of database %q", dbName, aliasOf)
+	}
+	for _, alias := range aliases {
+		if _, ok := sc.dbRegistry[alias]; ok {
+			return base.HTTPErrorf(http.StatusPreconditionFailed, "Database alias %q is already the name of a database", alias)
+		}
+		if aliasOf, ok := sc.dbAliases[alias]; ok && aliasOf != dbName {
+			return base.HTTPErrorf(http.StatusPreconditionFailed, "Database alias %q is already an alias of database %q", alias, aliasOf)
+		}
+	}
+	return nil
+}
+
 func (sc *ServerContext) GetDbConfig(name string) *DbConfig {
 	if dbConfig := sc.GetDatabaseConfig(name); dbConfig != nil {
 		return &dbConfig.DbConfig
@@ -356,6 +421,22 @@ func (sc *ServerContext) allDatabaseSummaries() []dbSummary {
 	return dbs
 }
 
+// healthReport probes each database's dependencies.  The server is degraded if any database isn't ready.
+func (sc *ServerContext) healthReport(ctx context.Context) healthReport {
+	report := healthReport{
+		Status:    db.HealthStatusOK,
+		Databases: make(map[string]db.DatabaseHealth),
+	}
+	for name, dbctx := range sc.AllDatabases() {
+		health := dbctx.CheckHealth(ctx)
+		if !health.Ready() {
+			report.Status = db.HealthStatusDegraded
+		}
+		report.Databases[name] = health
+	}
+	return report
+}
+
 // AllDatabases returns a copy of the databases_ map.
 func (sc *ServerContext) AllDatabases() map[string]*db.DatabaseContext {
 	sc.lock.RLock()
@@ -444,6 +525,56 @@ func (sc *ServerContext) _reloadDatabaseWithConfig(ctx context.Context, config D
 	return err
 }
 
+// _reloadCollectionSyncFunction applies a collection's updated sync function to the running database without taking the
+// database offline, when the sync function is the only difference between the running config and the updated config.
+// previousVersion is the version of the persisted config the update was applied to. If the running config is not at
+// that version, the database is fully reloaded with the updated config instead. Requires the server context lock.
+func (sc *ServerContext) _reloadCollectionSyncFunction(ctx context.Context, config DatabaseConfig, previousVersion string, scopeName, collectionName string, syncFn string) error {
+	runtimeConfig, ok := sc.dbConfigs[config.Name]
+	dbCtx := sc.databases_[config.Name]
+	if !ok || dbCtx == nil || runtimeConfig.Version != previousVersion {
+		return sc._reloadDatabaseWithConfig(ctx, config, false, false)
+	}
+	collection, err := dbCtx.GetDatabaseCollection(scopeName, collectionName)
+	if err != nil {
+		return sc._reloadDatabaseWithConfig(ctx, config, false, false)
+	}
+	changed, err := collection.UpdateSyncFun(ctx, syncFn)
+	if err != nil {
+		return err
+	}
+	if changed {
+		base.InfofCtx(ctx, base.KeyAll, "**NOTE:** %q's sync function has changed for collection %s.%s. The new function may assign different channels to documents, or permissions to users. You may want to re-sync the database to update these.", base.MD(config.Name), base.MD(scopeName), base.MD(collectionName))
+	}
+	runtimeConfig.DatabaseConfig = config
+	return nil
+}
+
+// getOrConnectFederatedBucket returns the connection to an additional bucket storing some of a database's collections,
+// connecting with the database's bucket spec if not already present in federatedBuckets.
+func (sc *ServerContext) getOrConnectFederatedBucket(ctx context.Context, spec base.BucketSpec, bucketName string, federatedBuckets map[string]base.Bucket, options getOrAddDatabaseConfigOptions) (bucket base.Bucket, err error) {
+	if bucket, ok := federatedBuckets[bucketName]; ok {
+		return bucket, nil
+	}
+	spec.BucketName = bucketName
+	base.InfofCtx(ctx, base.KeyAll, "Opening federated bucket %q, pool %q, server <%s>",
+		base.MD(spec.BucketName), base.SD(base.DefaultPool), base.SD(spec.Server))
+	if options.connectToBucketFn != nil {
+		bucket, err = options.connectToBucketFn(ctx, spec, options.failFast)
+	} else {
+		bucket, err = db.ConnectToBucket(ctx, spec, options.failFast)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !bucket.IsSupported(sgbucket.BucketStoreFeatureCollections) {
+		bucket.Close(ctx)
+		return nil, errCollectionsUnsupported
+	}
+	federatedBuckets[bucketName] = bucket
+	return bucket, nil
+}
+
 // Adds a database to the ServerContext.  Attempts a read after it gets the write
 // lock to see if it's already been added by another process. If so, returns either the
 // existing DatabaseContext or an error based on the useExisting flag.
@@ -488,6 +619,7 @@ func GetBucketSpec(ctx context.Context, config *DatabaseConfig, serverConfig *St
 		operationTimeout := time.Millisecond * time.Duration(*config.BucketOpTimeoutMs)
 		spec.BucketOpTimeout = &operationTimeout
 	}
+	spec.KvCircuitBreaker = config.KVCircuitBreaker.toCircuitBreakerOptions(spec.BucketOpTimeout)
 	return spec, nil
 }
 
@@ -497,6 +629,7 @@ func GetBucketSpec(ctx context.Context, config *DatabaseConfig, serverConfig *St
 // Pass in a bucketFromBucketSpecFn to replace the default ConnectToBucket function. This will cause the failFast argument to be ignored
 func (sc *ServerContext) _getOrAddDatabaseFromConfig(ctx context.Context, config DatabaseConfig, options getOrAddDatabaseConfigOptions) (dbcontext *db.DatabaseContext, returnedError error) {
 	var bucket base.Bucket
+	federatedBuckets := make(map[string]base.Bucket) // Additional buckets storing collections, keyed by bucket name
 
 	// Generate bucket spec and validate whether db already exists
 	spec, err := GetBucketSpec(ctx, &config, sc.Config)
@@ -519,8 +652,13 @@ func (sc *ServerContext) _getOrAddDatabaseFromConfig(ctx context.Context, config
 		}
 		if dbcontext != nil {
 			dbcontext.Close(ctx) // will close underlying bucket
-		} else if bucket != nil {
-			bucket.Close(ctx)
+		} else {
+			if bucket != nil {
+				bucket.Close(ctx)
+			}
+			for _, federatedBucket := range federatedBuckets {
+				federatedBucket.Close(ctx)
+			}
 		}
 	}()
 
@@ -559,6 +697,10 @@ func (sc *ServerContext) _getOrAddDatabaseFromConfig(ctx context.Context, config
 		return nil, err
 	}
 
+	if err := sc._checkDatabaseAliases(dbName, config.Aliases); err != nil {
+		return nil, err
+	}
+
 	// Connect to bucket
 	base.InfofCtx(ctx, base.KeyAll, "Opening db /%s as bucket %q, pool %q, server <%s>",
 		base.MD(dbName), base.MD(spec.BucketName), base.SD(base.DefaultPool), base.SD(spec.Server))
@@ -615,21 +757,28 @@ func (sc *ServerContext) _getOrAddDatabaseFromConfig(ctx context.Context, config
 
 		hasDefaultCollection := false
 		for scopeName, scopeConfig := range config.Scopes {
-			for collectionName, _ := range scopeConfig.Collections {
+			for collectionName, collectionConfig := range scopeConfig.Collections {
 				var dataStore sgbucket.DataStore
 
 				var err error
+				collectionBucket := bucket
+				if federatedBucketName := collectionConfig.FederatedBucketName(spec.BucketName); federatedBucketName != "" {
+					collectionBucket, err = sc.getOrConnectFederatedBucket(ctx, spec, federatedBucketName, federatedBuckets, options)
+					if err != nil {
+						return nil, err
+					}
+				}
 				if options.failFast {
-					dataStore, err = bucket.NamedDataStore(base.ScopeAndCollectionName{Scope: scopeName, Collection: collectionName})
+					dataStore, err = collectionBucket.NamedDataStore(base.ScopeAndCollectionName{Scope: scopeName, Collection: collectionName})
 				} else {
 					waitForCollection := func() (bool, error, interface{}) {
-						dataStore, err = bucket.NamedDataStore(base.ScopeAndCollectionName{Scope: scopeName, Collection: collectionName})
+						dataStore, err = collectionBucket.NamedDataStore(base.ScopeAndCollectionName{Scope: scopeName, Collection: collectionName})
 						return err != nil, err, nil
 					}
 
 					err, _ = base.RetryLoop(
 						ctx,
-						fmt.Sprintf("waiting for %s.%s.%s to exist", base.MD(bucket.GetName()), base.MD(scopeName), base.MD(collectionName)),
+						fmt.Sprintf("waiting for %s.%s.%s to exist", base.MD(collectionBucket.GetName()), base.MD(scopeName), base.MD(collectionName)),
 						waitForCollection,
 						base.CreateMaxDoublingSleeperFunc(30, 10, 1000))
 				}
@@ -689,8 +838,9 @@ func (sc *ServerContext) _getOrAddDatabaseFromConfig(ctx context.Context, config
 		}
 
 		// If database has been requested to start offline, or there's an active async initialization, use async initialization
-		// DatabaseInitManager will be nil if persistent config is not being used.
-		if sc.DatabaseInitManager != nil && (startOffline || sc.DatabaseInitManager.HasActiveInitialization(dbName)) {
+		// DatabaseInitManager will be nil if persistent config is not being used. Async initialization only supports a
+		// single bucket, so databases with federated buckets are always initialized synchronously.
+		if sc.DatabaseInitManager != nil && len(federatedBuckets) == 0 && (startOffline || sc.DatabaseInitManager.HasActiveInitialization(dbName)) {
 			// Initialize indexes asynchronously using DatabaseInitManager.
 			dbInitDoneChan, err = sc.DatabaseInitManager.InitializeDatabase(ctx, sc.Config, &config)
 			if err != nil {
@@ -810,11 +960,21 @@ func (sc *ServerContext) _getOrAddDatabaseFromConfig(ctx context.Context, config
 					importFilter = db.NewImportFilterFunction(ctx, *collCfg.ImportFilter, javascriptTimeout)
 				}
 
+				collectionBucketName := spec.BucketName
+				federatedBucketName := collCfg.FederatedBucketName(spec.BucketName)
+				if federatedBucketName != "" {
+					collectionBucketName = federatedBucketName
+				}
 				contextOptions.Scopes[scopeName].Collections[collName] = db.CollectionOptions{
-					Sync:         collCfg.SyncFn,
-					ImportFilter: importFilter,
+					Sync:             collCfg.SyncFn,
+					ImportFilter:     importFilter,
+					Bucket:           federatedBucketName,
+					AttachmentPolicy: collCfg.AttachmentPolicy.toAttachmentPolicy(),
+					StagedSync:       collCfg.StagedSync.toStagedSyncFunction(ctx, javascriptTimeout),
+					AllowConflicts:   collCfg.AllowConflicts,
+					RevsLimit:        collCfg.revsLimit(&config.DbConfig),
 				}
-				fqCollections = append(fqCollections, base.FullyQualifiedCollectionName(spec.BucketName, scopeName, collName))
+				fqCollections = append(fqCollections, base.FullyQualifiedCollectionName(collectionBucketName, scopeName, collName))
 			}
 		}
 	} else {
@@ -854,6 +1014,7 @@ func (sc *ServerContext) _getOrAddDatabaseFromConfig(ctx context.Context, config
 	}
 
 	contextOptions.BlipStatsReportingInterval = defaultBytesStatsReportingInterval.Milliseconds()
+	contextOptions.FederatedBuckets = federatedBuckets
 	// Create the DB Context
 	dbcontext, err = db.NewDatabaseContext(ctx, dbName, bucket, autoImport, contextOptions)
 	if err != nil {
@@ -911,6 +1072,9 @@ func (sc *ServerContext) _getOrAddDatabaseFromConfig(ctx context.Context, config
 	sc.databases_[dbcontext.Name] = dbcontext
 	sc.dbConfigs[dbcontext.Name] = &RuntimeDatabaseConfig{DatabaseConfig: config}
 	sc.dbRegistry[dbName] = struct{}{}
+	for _, alias := range config.Aliases {
+		sc.dbAliases[alias] = dbName
+	}
 	for _, name := range fqCollections {
 		sc.collectionRegistry[name] = dbName
 	}
@@ -941,6 +1105,13 @@ func (sc *ServerContext) _getOrAddDatabaseFromConfig(ctx context.Context, config
 		}
 		base.InfofCtx(ctx, base.KeyAll, "Database init completed, starting online processes")
 		if err := dbcontext.StartOnlineProcesses(ctx); err != nil {
+			if errors.Is(err, db.ErrSequenceInconsistent) {
+				// Keep the database loaded but offline, so the mismatch can be investigated
+				base.ErrorfCtx(ctx, "Database %s failed its startup consistency check and will remain offline: %v", base.MD(dbName), err)
+				atomic.StoreUint32(&dbcontext.State, db.DBOffline)
+				_ = dbcontext.EventMgr.RaiseDBStateChangeEvent(ctx, dbName, "offline", err.Error(), &sc.Config.API.AdminInterface)
+				return dbcontext, nil
+			}
 			return nil, err
 		}
 		atomic.StoreUint32(&dbcontext.State, db.DBOnline)
@@ -1020,7 +1191,12 @@ func getJavascriptTimeout(config *DbConfig) time.Duration {
 func newBaseImportOptions(config *DbConfig, serverless bool) *db.ImportOptions {
 	// Identify import options
 	importOptions := &db.ImportOptions{
-		BackupOldRev: base.BoolDefault(config.ImportBackupOldRev, false),
+		BackupOldRev:        base.BoolDefault(config.ImportBackupOldRev, false),
+		QuarantineThreshold: db.DefaultImportQuarantineThreshold,
+		BinaryDocs:          base.BoolDefault(config.ImportBinaryDocs, false),
+	}
+	if config.ImportQuarantineThreshold != nil {
+		importOptions.QuarantineThreshold = *config.ImportQuarantineThreshold
 	}
 
 	if config.ImportPartitions == nil {
@@ -1075,6 +1251,12 @@ func dbcOptionsFromConfig(ctx context.Context, sc *ServerContext, config *DbConf
 			if config.CacheConfig.ChannelCacheConfig.MaxNumber != nil {
 				cacheOptions.MaxNumChannels = *config.CacheConfig.ChannelCacheConfig.MaxNumber
 			}
+			if config.CacheConfig.ChannelCacheConfig.MaxBackfillQueries != nil {
+				cacheOptions.MaxBackfillConcurrency = *config.CacheConfig.ChannelCacheConfig.MaxBackfillQueries
+			}
+			if config.CacheConfig.ChannelCacheConfig.MaxFeedBuffer != nil {
+				cacheOptions.FeedBufferSize = *config.CacheConfig.ChannelCacheConfig.MaxFeedBuffer
+			}
 			if config.CacheConfig.ChannelCacheConfig.HighWatermarkPercent != nil && *config.CacheConfig.ChannelCacheConfig.HighWatermarkPercent > 0 {
 				cacheOptions.CompactHighWatermarkPercent = *config.CacheConfig.ChannelCacheConfig.HighWatermarkPercent
 			}
@@ -1090,6 +1272,19 @@ func dbcOptionsFromConfig(ctx context.Context, sc *ServerContext, config *DbConf
 			if config.CacheConfig.RevCacheConfig.ShardCount != nil {
 				revCacheOptions.ShardCount = *config.CacheConfig.RevCacheConfig.ShardCount
 			}
+			if config.CacheConfig.RevCacheConfig.MaxMemoryBytes != nil {
+				revCacheOptions.MaxBytes = *config.CacheConfig.RevCacheConfig.MaxMemoryBytes
+			}
+			revCacheOptions.PinnedDocIDs = config.CacheConfig.RevCacheConfig.PinnedDocs
+		}
+
+		if config.CacheConfig.DeltaCacheConfig != nil {
+			if config.CacheConfig.DeltaCacheConfig.ExpirySeconds != nil {
+				revCacheOptions.DeltaExpiry = time.Duration(*config.CacheConfig.DeltaCacheConfig.ExpirySeconds) * time.Second
+			}
+			if config.CacheConfig.DeltaCacheConfig.MaxMemoryBytes != nil {
+				revCacheOptions.DeltaMaxBytes = *config.CacheConfig.DeltaCacheConfig.MaxMemoryBytes
+			}
 		}
 	}
 
@@ -1125,6 +1320,17 @@ func dbcOptionsFromConfig(ctx context.Context, sc *ServerContext, config *DbConf
 	}
 	base.InfofCtx(ctx, base.KeyAll, "delta_sync enabled=%t with rev_max_age_seconds=%d for database %s", deltaSyncOptions.Enabled, deltaSyncOptions.RevMaxAgeSeconds, dbName)
 
+	var oldRevBackupOptions db.OldRevBackupOptions
+	if config.OldRevBackup != nil {
+		oldRevBackupOptions.Disabled = !base.BoolDefault(config.OldRevBackup.Enabled, true)
+		if config.OldRevBackup.MaxPerDoc != nil {
+			oldRevBackupOptions.MaxPerDoc = *config.OldRevBackup.MaxPerDoc
+		}
+	}
+	if oldRevBackupOptions.Disabled && deltaSyncOptions.Enabled {
+		base.InfofCtx(ctx, base.KeyAll, "old_rev_backup is disabled for database %s, so deltas can only be generated from revisions in the revision cache", base.MD(dbName))
+	}
+
 	compactIntervalSecs := uint32(db.DefaultCompactInterval.Seconds())
 	if config.CompactIntervalDays != nil {
 		compactIntervalSecs = uint32(*config.CompactIntervalDays * 60 * 60 * 24)
@@ -1203,6 +1409,40 @@ func dbcOptionsFromConfig(ctx context.Context, sc *ServerContext, config *DbConf
 		slowQueryWarningThreshold = time.Duration(*config.SlowQueryWarningThresholdMs) * time.Millisecond
 	}
 
+	var slowChangesThreshold time.Duration
+	if config.SlowChangesThresholdMs != nil {
+		slowChangesThreshold = time.Duration(*config.SlowChangesThresholdMs) * time.Millisecond
+	}
+
+	var maxRevMessageHistory uint32
+	if config.MaxRevMessageHistory != nil {
+		maxRevMessageHistory = *config.MaxRevMessageHistory
+	}
+
+	deferredAttachmentWindow := db.DefaultDeferredAttachmentWindow
+	if config.DeferredAttachmentWindowSecs != nil {
+		deferredAttachmentWindow = time.Duration(*config.DeferredAttachmentWindowSecs) * time.Second
+	}
+
+	idempotencyKeyTTL := db.DefaultIdempotencyKeyTTL
+	if config.IdempotencyKeyTTLSecs != nil {
+		idempotencyKeyTTL = time.Duration(*config.IdempotencyKeyTTLSecs) * time.Second
+	}
+
+	maxPendingRevs := db.DefaultMaxPendingRevs
+	if config.MaxPendingRevs != nil {
+		maxPendingRevs = *config.MaxPendingRevs
+	}
+	var guestChannelAllowlist base.Set
+	if config.GuestChannelAllowlist != nil {
+		guestChannelAllowlist = base.SetFromArray(config.GuestChannelAllowlist)
+	}
+
+	maxPendingRevBytes := int64(db.DefaultMaxPendingRevBytes)
+	if config.MaxPendingRevBytes != nil {
+		maxPendingRevBytes = *config.MaxPendingRevBytes
+	}
+
 	groupID := ""
 	if sc.Config.Bootstrap.ConfigGroupID != PersistentConfigDefaultGroupID {
 		groupID = sc.Config.Bootstrap.ConfigGroupID
@@ -1221,6 +1461,16 @@ func dbcOptionsFromConfig(ctx context.Context, sc *ServerContext, config *DbConf
 		sendWWWAuthenticate = base.BoolPtr(false)
 	}
 
+	maintenanceWindows, err := toMaintenanceWindows(config.MaintenanceWindows)
+	if err != nil {
+		return db.DatabaseContextOptions{}, err
+	}
+
+	sessionCookieKeys, err := toSessionCookieKeys(config.SessionCookieKeys)
+	if err != nil {
+		return db.DatabaseContextOptions{}, err
+	}
+
 	contextOptions := db.DatabaseContextOptions{
 		CacheOptions:                  &cacheOptions,
 		RevisionCacheOptions:          revCacheOptions,
@@ -1239,6 +1489,8 @@ func dbcOptionsFromConfig(ctx context.Context, sc *ServerContext, config *DbConf
 		AllowConflicts:                config.ConflictsAllowed(),
 		SendWWWAuthenticateHeader:     sendWWWAuthenticate,
 		DisablePasswordAuthentication: base.BoolDefault(config.DisablePasswordAuth, false),
+		GuestChannelAllowlist:         guestChannelAllowlist,
+		SystemChannels:                base.SetFromArray(config.SystemChannels),
 		DeltaSyncOptions:              deltaSyncOptions,
 		CompactInterval:               compactIntervalSecs,
 		QueryPaginationLimit:          queryPaginationLimit,
@@ -1247,13 +1499,31 @@ func dbcOptionsFromConfig(ctx context.Context, sc *ServerContext, config *DbConf
 			Enabled:               sgReplicateEnabled,
 			WebsocketPingInterval: sgReplicateWebsocketPingInterval,
 		},
+		OldRevBackupOptions:       oldRevBackupOptions,
+		SessionCookieKeys:         sessionCookieKeys,
 		SlowQueryWarningThreshold: slowQueryWarningThreshold,
+		SlowChangesThreshold:      slowChangesThreshold,
+		MaxRevMessageHistory:      maxRevMessageHistory,
+		MaxPendingRevs:            maxPendingRevs,
+		MaxPendingRevBytes:        maxPendingRevBytes,
+		DeferredAttachmentWindow:  deferredAttachmentWindow,
+		IdempotencyKeyTTL:         idempotencyKeyTTL,
 		ClientPartitionWindow:     clientPartitionWindow,
 		BcryptCost:                bcryptCost,
 		GroupID:                   groupID,
 		JavascriptTimeout:         javascriptTimeout,
 		Serverless:                sc.Config.IsServerless(),
 		ChangesRequestPlus:        base.BoolDefault(config.ChangesRequestPlus, false),
+		ChangesIncludeChannels:    base.BoolDefault(config.ChangesIncludeChannels, false),
+		AttachmentPolicy:          config.AttachmentPolicy.toAttachmentPolicy(),
+		ReplicationFilters:        config.ReplicationFilters.toReplicationFilters(),
+		ChannelHistoryOptions:     config.ChannelHistory.toChannelHistoryOptions(),
+		MaintenanceWindows:        maintenanceWindows,
+		RequestQuotaOptions:       config.RequestQuotas.toRequestQuotaOptions(),
+		AccessSnapshotOptions:     config.AccessSnapshots.toAccessSnapshotOptions(),
+		ExternalAuthorizerOptions: config.ExternalAuthorizer.toExternalAuthorizerOptions(),
+		StatsPersistenceOptions:   config.StatsPersistence.toStatsPersistenceOptions(ctx),
+		SequenceCheckOptions:      config.StartupConsistencyCheck.toSequenceCheckOptions(),
 		// UserQueries:               config.UserQueries,   // behind feature flag (see below)
 		// UserFunctions:             config.UserFunctions, // behind feature flag (see below)
 		// GraphQL:                   config.GraphQL,       // behind feature flag (see below)
@@ -1262,6 +1532,11 @@ func dbcOptionsFromConfig(ctx context.Context, sc *ServerContext, config *DbConf
 	// Per-database console logging config overrides
 	contextOptions.LoggingConfig.Console = config.toDbConsoleLogConfig(ctx)
 
+	if config.CheckpointMirror != nil {
+		contextOptions.CheckpointMirrorClusterID = config.CheckpointMirror.ClusterID
+	}
+	contextOptions.FenceDuplicateCheckpoints = base.BoolDefault(config.FenceDuplicateCheckpointWriters, false)
+
 	if sc.Config.Unsupported.UserQueries != nil && *sc.Config.Unsupported.UserQueries {
 		var err error
 		if config.UserFunctions != nil {
@@ -1342,9 +1617,9 @@ func validateEventConfigOptions(eventType db.EventType, eventConfig *EventConfig
 	case db.DocumentChange:
 		for k, v := range eventConfig.Options {
 			switch k {
-			case db.EventOptionDocumentChangedWinningRevOnly:
+			case db.EventOptionDocumentChangedWinningRevOnly, db.EventOptionAtLeastOnce, db.EventOptionEventMetadata:
 				if _, ok := v.(bool); !ok {
-					errs = errs.Append(fmt.Errorf("Event option %q must be of type bool", db.EventOptionDocumentChangedWinningRevOnly))
+					errs = errs.Append(fmt.Errorf("Event option %q must be of type bool", k))
 				}
 			default:
 				errs = errs.Append(fmt.Errorf("unknown option %q found for event type %q", k, eventType))
@@ -1365,6 +1640,22 @@ func validateEventConfigOptions(eventType db.EventType, eventConfig *EventConfig
 	return nil
 }
 
+// validateEventConfigRoute returns an error if the event config's route can't be applied to the event type.
+func validateEventConfigRoute(eventType db.EventType, eventConfig *EventConfig) error {
+	if eventConfig == nil || eventConfig.Route == nil {
+		return nil
+	}
+	if eventType != db.DocumentChange {
+		return fmt.Errorf("route can't be set for event type %q", eventType)
+	}
+	for _, collection := range eventConfig.Route.Collections {
+		if scopeName, collectionName, ok := strings.Cut(collection, "."); !ok || scopeName == "" || collectionName == "" {
+			return fmt.Errorf("route collection %q must be of the form scope.collection", collection)
+		}
+	}
+	return nil
+}
+
 // Initialize event handlers, if present
 func (sc *ServerContext) initEventHandlers(ctx context.Context, dbcontext *db.DatabaseContext, config *DbConfig) (err error) {
 	if config.EventHandlers == nil {
@@ -1381,6 +1672,9 @@ func (sc *ServerContext) initEventHandlers(ctx context.Context, dbcontext *db.Da
 			if err := validateEventConfigOptions(eventType, conf); err != nil {
 				return err
 			}
+			if err := validateEventConfigRoute(eventType, conf); err != nil {
+				return err
+			}
 
 			// Load external webhook filter function
 			insecureSkipVerify := false
@@ -1445,6 +1739,14 @@ func (sc *ServerContext) processEventHandlersForEvent(ctx context.Context, event
 				base.WarnfCtx(ctx, "Error creating webhook %v", err)
 				return err
 			}
+			wh.SetRoute(event.Route.toEventRoute())
+			if wh.AtLeastOnce() {
+				// At-least-once webhooks follow the changes feed, rather than handling events raised on write
+				if err := dbcontext.StartAtLeastOnceWebhook(ctx, wh); err != nil {
+					return err
+				}
+				continue
+			}
 			dbcontext.EventMgr.RegisterEventHandler(ctx, wh, eventType)
 		default:
 			return errors.New(fmt.Sprintf("Unknown event handler type %s", event.HandlerType))
@@ -1492,6 +1794,11 @@ func (sc *ServerContext) _removeDatabase(ctx context.Context, dbName string) boo
 	}
 	delete(sc.dbConfigs, dbName)
 	delete(sc.dbRegistry, dbName)
+	for alias, aliasOf := range sc.dbAliases {
+		if dbName == aliasOf {
+			delete(sc.dbAliases, alias)
+		}
+	}
 	for fqCollection, registryDbName := range sc.collectionRegistry {
 		if dbName == registryDbName {
 			delete(sc.collectionRegistry, fqCollection)
@@ -1669,7 +1976,7 @@ func (sc *ServerContext) updateCalculatedStats(ctx context.Context) {
 	defer sc.lock.RUnlock()
 	for _, dbContext := range sc.databases_ {
 		dbState := atomic.LoadUint32(&dbContext.State)
-		if dbState == db.DBOnline {
+		if dbState == db.DBOnline || dbState == db.DBPaused {
 			dbContext.UpdateCalculatedStats(ctx)
 		}
 	}
@@ -2096,10 +2403,54 @@ func (sc *ServerContext) initializeCouchbaseServerConnections(ctx context.Contex
 		}
 	}
 
+	if interval := sc.Config.Bootstrap.DNSRefreshInterval.Value(); interval > 0 {
+		watcher, err := base.NewServerDNSWatcher(ctx, sc.Config.Bootstrap.Server, nil, base.SyncGatewayStats.GlobalStats.ServerConnection, sc.reconnectToServer)
+		if err != nil {
+			base.WarnfCtx(ctx, "Unable to resolve Couchbase Server addresses, DNS changes won't be detected: %v", err)
+		} else {
+			watcher.StartWatching(ctx, interval)
+			sc.serverDNSWatcher = watcher
+		}
+	}
+
 	base.InfofCtx(ctx, base.KeyAll, "Finished initializing server connections")
 	return nil
 }
 
+// reconnectToServer is called when the addresses resolved from the Couchbase Server connection string change. It
+// closes the existing connections and reloads the databases on the server, so that they reconnect to the newly
+// resolved addresses.
+func (sc *ServerContext) reconnectToServer(ctx context.Context, previous, current []string) {
+	if cluster, ok := sc.BootstrapContext.Connection.(*base.CouchbaseCluster); ok {
+		cluster.Reconnect(ctx)
+	}
+
+	goCBAgent, err := sc.initializeGoCBAgent(ctx)
+	if err != nil {
+		base.WarnfCtx(ctx, "Unable to reconnect management agent to Couchbase Server, continuing to use existing connection: %v", err)
+	} else {
+		previousAgent := sc.GoCBAgent
+		sc.GoCBAgent = goCBAgent
+		if previousAgent != nil {
+			if err := previousAgent.Close(); err != nil {
+				base.WarnfCtx(ctx, "Error closing agent connection: %v", err)
+			}
+		}
+	}
+
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	for dbName, config := range sc.dbConfigs {
+		if config.Server != nil && *config.Server != sc.Config.Bootstrap.Server {
+			continue
+		}
+		base.InfofCtx(ctx, base.KeyAll, "Reloading database %s to reconnect to Couchbase Server", base.MD(dbName))
+		if _, err := sc._reloadDatabase(ctx, dbName, false, false); err != nil {
+			base.WarnfCtx(ctx, "Unable to reload database %s after Couchbase Server addresses changed: %v", base.MD(dbName), err)
+		}
+	}
+}
+
 func (sc *ServerContext) AddServerLogContext(parent context.Context) context.Context {
 	// ServerLogContext is separate from standard LogContext, so this does not reset the log context
 	if sc != nil && sc.LogContextID != "" {
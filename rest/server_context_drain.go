@@ -0,0 +1,121 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/db"
+)
+
+// signalServerContext is the ServerContext drained by the signal handler on SIGINT/SIGTERM. Set once the
+// REST APIs have been started.
+var signalServerContext atomic.Pointer[ServerContext]
+
+// blipSyncContextRegistry tracks the BLIP replication connections currently open on the node, so that
+// they can be drained on shutdown.
+type blipSyncContextRegistry struct {
+	draining atomic.Bool
+	lock     sync.Mutex
+	contexts map[*db.BlipSyncContext]struct{}
+}
+
+// add registers an open connection. Returns false if the node is draining and the connection should be
+// rejected.
+func (r *blipSyncContextRegistry) add(bsc *db.BlipSyncContext) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.draining.Load() {
+		return false
+	}
+	if r.contexts == nil {
+		r.contexts = make(map[*db.BlipSyncContext]struct{})
+	}
+	r.contexts[bsc] = struct{}{}
+	return true
+}
+
+func (r *blipSyncContextRegistry) remove(bsc *db.BlipSyncContext) {
+	r.lock.Lock()
+	delete(r.contexts, bsc)
+	r.lock.Unlock()
+}
+
+// forDatabase returns the connections open for the given database.
+func (r *blipSyncContextRegistry) forDatabase(database *db.DatabaseContext) []*db.BlipSyncContext {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	contexts := make([]*db.BlipSyncContext, 0)
+	for bsc := range r.contexts {
+		if bsc.DatabaseContext() == database {
+			contexts = append(contexts, bsc)
+		}
+	}
+	return contexts
+}
+
+// startDraining marks the registry as draining, and returns the connections open at that point. Returns
+// false if draining had already been started.
+func (r *blipSyncContextRegistry) startDraining() ([]*db.BlipSyncContext, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.draining.CompareAndSwap(false, true) {
+		return nil, false
+	}
+	contexts := make([]*db.BlipSyncContext, 0, len(r.contexts))
+	for bsc := range r.contexts {
+		contexts = append(contexts, bsc)
+	}
+	return contexts, true
+}
+
+// IsDraining returns true once Drain has been called, after which new replications are rejected.
+func (sc *ServerContext) IsDraining() bool {
+	return sc.blipSyncContexts.draining.Load()
+}
+
+// Drain prepares the node for shutdown during a rolling upgrade. New replications are rejected, and every
+// connected replication client is sent a goAway message asking it to reconnect after
+// replicator.drain_reconnect_after (to another node, via the load balancer). Drain then waits up to
+// replicator.drain_timeout for in-flight rev batches to complete.
+func (sc *ServerContext) Drain(ctx context.Context) {
+	contexts, ok := sc.blipSyncContexts.startDraining()
+	if !ok {
+		return
+	}
+
+	timeout := sc.Config.Replicator.DrainTimeout.Value()
+	reconnectAfter := sc.Config.Replicator.DrainReconnectAfter.Value()
+	base.InfofCtx(ctx, base.KeyAll, "Draining %d replication connections with timeout %v", len(contexts), timeout)
+
+	drainCtx, cancelFn := context.WithTimeout(ctx, timeout)
+	defer cancelFn()
+
+	var wg sync.WaitGroup
+	var timedOut int32
+	for _, bsc := range contexts {
+		wg.Add(1)
+		go func(bsc *db.BlipSyncContext) {
+			defer wg.Done()
+			if !bsc.Drain(drainCtx, reconnectAfter) {
+				atomic.AddInt32(&timedOut, 1)
+			}
+		}(bsc)
+	}
+	wg.Wait()
+
+	if timedOut > 0 {
+		base.WarnfCtx(ctx, "Drain timeout reached with %d of %d replication connections still in flight", timedOut, len(contexts))
+	} else {
+		base.InfofCtx(ctx, base.KeyAll, "Drained %d replication connections", len(contexts))
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -928,3 +929,71 @@ func TestCompactIntervalFromConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestDatabaseAliases(t *testing.T) {
+	rt := NewRestTester(t, &RestTesterConfig{
+		DatabaseConfig: &DatabaseConfig{DbConfig: DbConfig{
+			Aliases: []string{"app_v1", "app_v2"},
+		}},
+	})
+	defer rt.Close()
+
+	// Both aliases are served by the same database, which reports its own name
+	for _, alias := range []string{"app_v1", "app_v2"} {
+		response := rt.SendAdminRequest(http.MethodGet, "/"+alias+"/", "")
+		RequireStatus(t, response, http.StatusOK)
+		var dbRoot DatabaseRoot
+		require.NoError(t, base.JSONUnmarshal(response.BodyBytes(), &dbRoot))
+		assert.Equal(t, "db", dbRoot.DBName)
+	}
+	dbc, err := rt.ServerContext().GetActiveDatabase("app_v1")
+	require.NoError(t, err)
+	assert.Equal(t, rt.GetDatabase(), dbc)
+	assert.Equal(t, []string{"db"}, rt.ServerContext().AllDatabaseNames())
+
+	// Checkpoints written through one alias are read through the other, and through the database's name
+	aliasKeyspace := func(alias string) string {
+		return alias + strings.TrimPrefix(rt.GetSingleKeyspace(), "db")
+	}
+	response := rt.SendAdminRequest(http.MethodPut, "/"+aliasKeyspace("app_v1")+"/_local/checkpoint1", `{"last_sequence": "5"}`)
+	RequireStatus(t, response, http.StatusCreated)
+	response = rt.SendAdminRequest(http.MethodGet, "/"+aliasKeyspace("app_v2")+"/_local/checkpoint1", "")
+	RequireStatus(t, response, http.StatusOK)
+	assert.Contains(t, response.Body.String(), `"last_sequence":"5"`)
+	response = rt.SendAdminRequest(http.MethodGet, "/{{.keyspace}}/_local/checkpoint1", "")
+	RequireStatus(t, response, http.StatusOK)
+
+	// Sessions created through an alias authenticate requests to the database under any name
+	rt.CreateUser("alice", []string{"*"})
+	response = rt.SendRequest(http.MethodPost, "/app_v1/_session", `{"name":"alice", "password":"`+RestTesterDefaultUserPassword+`"}`)
+	RequireStatus(t, response, http.StatusOK)
+	cookie := response.Header().Get("Set-Cookie")
+	require.NotEmpty(t, cookie)
+	for _, dbName := range []string{"app_v2", "db"} {
+		response = rt.SendRequestWithHeaders(http.MethodGet, "/"+dbName+"/_session", "", map[string]string{"Cookie": cookie})
+		RequireStatus(t, response, http.StatusOK)
+		assert.Contains(t, response.Body.String(), `"name":"alice"`)
+	}
+
+	// Aliases can't be used as the name or alias of another database
+	assert.Error(t, rt.ServerContext().checkDatabaseAliases("app_v1", nil))
+	assert.Error(t, rt.ServerContext().checkDatabaseAliases("db2", []string{"db"}))
+	assert.Error(t, rt.ServerContext().checkDatabaseAliases("db2", []string{"app_v2"}))
+	assert.NoError(t, rt.ServerContext().checkDatabaseAliases("db", []string{"app_v1"}))
+}
+
+func TestValidateEventConfigRoute(t *testing.T) {
+	route := &EventRouteConfig{Channels: []string{"orders"}, Collections: []string{"sales.orders"}, DocIDPrefixes: []string{"order::"}}
+	assert.NoError(t, validateEventConfigRoute(db.DocumentChange, &EventConfig{Route: route}))
+	assert.NoError(t, validateEventConfigRoute(db.DBStateChange, &EventConfig{}))
+	assert.Error(t, validateEventConfigRoute(db.DBStateChange, &EventConfig{Route: route}))
+	for _, collection := range []string{"orders", ".orders", "sales."} {
+		assert.Error(t, validateEventConfigRoute(db.DocumentChange, &EventConfig{Route: &EventRouteConfig{Collections: []string{collection}}}), collection)
+	}
+
+	dbRoute := route.toEventRoute()
+	assert.Equal(t, base.SetOf("orders"), dbRoute.Channels)
+	assert.Equal(t, base.SetOf("sales.orders"), dbRoute.Collections)
+	assert.Equal(t, []string{"order::"}, dbRoute.DocIDPrefixes)
+	assert.Nil(t, (*EventRouteConfig)(nil).toEventRoute())
+}
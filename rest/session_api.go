@@ -104,7 +104,10 @@ func (h *handler) handleSessionDELETE() error {
 		}
 	}
 
-	cookie := h.db.Authenticator(h.ctx()).DeleteSessionForCookie(h.rq)
+	cookie, err := h.db.Authenticator(h.ctx()).DeleteSessionForCookie(h.rq)
+	if err != nil {
+		return err
+	}
 	if cookie == nil {
 		return base.HTTPErrorf(http.StatusNotFound, "no session")
 	}
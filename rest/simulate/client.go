@@ -0,0 +1,314 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package simulate
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/couchbase/go-blip"
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/db"
+)
+
+// virtualClient is a single simulated replication client, which pushes new documents and pulls changes over its own
+// BLIP connection.
+type virtualClient struct {
+	id         int
+	config     *Config
+	ctx        context.Context
+	sender     *blip.Sender
+	collection string // Value of the collection property sent on messages, empty when using the default collection
+	docCount   int    // Number of documents pushed by the client
+
+	pullLock   sync.Mutex
+	pullState  *pullState      // State of the in-progress pull, nil between pulls
+	since      json.RawMessage // Last sequence pulled, used as since for the next pull
+	docsPulled atomic.Int64
+}
+
+// pullState tracks a single one-shot pull, which is complete once the client has been sent an empty changes message
+// and all requested revisions have been received.
+type pullState struct {
+	pending  int
+	caughtUp bool
+	done     chan struct{}
+}
+
+// newVirtualClient opens a BLIP connection to the target database and, if a collection is configured, negotiates it
+// using getCollections.
+func newVirtualClient(ctx context.Context, config *Config, id int) (*virtualClient, error) {
+	client := &virtualClient{
+		id:     id,
+		config: config,
+		ctx:    ctx,
+	}
+
+	blipContext, err := db.NewSGBlipContext(ctx, fmt.Sprintf("simulate-%d", id))
+	if err != nil {
+		return nil, err
+	}
+	blipContext.HandlerForProfile[db.MessageChanges] = client.handleChanges
+	blipContext.HandlerForProfile[db.MessageRev] = client.handleRev
+	blipContext.HandlerForProfile[db.MessageNoRev] = client.handleNoRev
+
+	// Credentials are sent using a basic auth header rather than in the websocket URL
+	target := *config.TargetURL
+	if target.Scheme == "https" {
+		target.Scheme = "wss"
+	} else {
+		target.Scheme = "ws"
+	}
+	var header http.Header
+	if target.User != nil {
+		password, _ := target.User.Password()
+		header = http.Header{
+			"Authorization": []string{"Basic " + base64.StdEncoding.EncodeToString([]byte(target.User.Username()+":"+password))},
+		}
+		target.User = nil
+	}
+	dialOptions := blip.DialOptions{
+		URL:        strings.TrimSuffix(target.String(), "/") + "/_blipsync",
+		HTTPClient: base.GetHttpClientForWebSocket(config.InsecureSkipVerify),
+		HTTPHeader: header,
+	}
+
+	client.sender, err = blipContext.DialConfig(&dialOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Collection != "" {
+		if err := client.getCollections(); err != nil {
+			client.close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// getCollections negotiates the configured collection, which is then referenced by index on subsequent messages.
+func (c *virtualClient) getCollections() error {
+	msg, err := db.NewGetCollectionsMessage(db.GetCollectionsRequestBody{
+		Collections:   []string{c.config.Collection},
+		CheckpointIDs: []string{fmt.Sprintf("simulate-%d", c.id)},
+	})
+	if err != nil {
+		return err
+	}
+	response, err := c.sendRequest(msg)
+	if err != nil {
+		return err
+	}
+	var checkpoints []json.RawMessage
+	if err := response.ReadJSONBody(&checkpoints); err != nil {
+		return err
+	}
+	if len(checkpoints) != 1 || string(checkpoints[0]) == "null" {
+		return fmt.Errorf("collection %q not found", c.config.Collection)
+	}
+	c.collection = "0"
+	return nil
+}
+
+// push creates a new document with a random body of the configured size, and pushes it using proposeChanges and rev.
+func (c *virtualClient) push(random *rand.Rand) error {
+	c.docCount++
+	docID := fmt.Sprintf("simulate_%d_%d_%d", c.id, time.Now().UnixNano(), c.docCount)
+	revDigest := make([]byte, 16)
+	_, _ = random.Read(revDigest)
+	revID := "1-" + hex.EncodeToString(revDigest)
+
+	body := map[string]interface{}{
+		"payload": randomPayload(random, c.config.DocSizeBytes),
+	}
+	if len(c.config.Channels) > 0 {
+		body["channels"] = []string{c.config.Channels[random.Intn(len(c.config.Channels))]}
+	}
+
+	proposeChanges := blip.NewRequest()
+	proposeChanges.SetProfile(db.MessageProposeChanges)
+	if err := proposeChanges.SetJSONBody([][]string{{docID, revID}}); err != nil {
+		return err
+	}
+	response, err := c.sendRequest(proposeChanges)
+	if err != nil {
+		return err
+	}
+	var statuses []int
+	if err := response.ReadJSONBody(&statuses); err != nil {
+		return err
+	}
+	if len(statuses) > 0 && statuses[0] != 0 {
+		return fmt.Errorf("proposeChanges for %q rejected with status %d", docID, statuses[0])
+	}
+
+	rev := blip.NewRequest()
+	rev.SetProfile(db.MessageRev)
+	rev.Properties[db.RevMessageID] = docID
+	rev.Properties[db.RevMessageRev] = revID
+	if err := rev.SetJSONBody(body); err != nil {
+		return err
+	}
+	_, err = c.sendRequest(rev)
+	return err
+}
+
+// pull runs a one-shot subChanges from the last sequence pulled, and waits for the client to catch up.
+func (c *virtualClient) pull() error {
+	state := &pullState{done: make(chan struct{})}
+	c.pullLock.Lock()
+	c.pullState = state
+	since := c.since
+	c.pullLock.Unlock()
+	defer func() {
+		c.pullLock.Lock()
+		c.pullState = nil
+		c.pullLock.Unlock()
+	}()
+
+	subChanges := blip.NewRequest()
+	subChanges.SetProfile(db.MessageSubChanges)
+	subChanges.Properties[db.SubChangesContinuous] = "false"
+	subChanges.Properties[db.SubChangesBatch] = strconv.Itoa(c.config.PullBatch)
+	if since != nil {
+		subChanges.Properties[db.SubChangesSince] = string(since)
+	}
+	if len(c.config.Channels) > 0 {
+		subChanges.Properties[db.SubChangesFilter] = base.ByChannelFilter
+		subChanges.Properties[db.SubChangesChannels] = strings.Join(c.config.Channels, ",")
+	}
+	if _, err := c.sendRequest(subChanges); err != nil {
+		return err
+	}
+
+	select {
+	case <-state.done:
+		return nil
+	case <-time.After(c.config.OpTimeout):
+		return errors.New("timed out waiting for pull to complete")
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	}
+}
+
+// handleChanges requests every revision in the changes message.  An empty changes message indicates the client has
+// caught up.
+func (c *virtualClient) handleChanges(msg *blip.Message) {
+	var changes [][]json.RawMessage
+	if err := msg.ReadJSONBody(&changes); err != nil {
+		msg.Response().SetError(blip.BLIPErrorDomain, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.pullLock.Lock()
+	defer c.pullLock.Unlock()
+	state := c.pullState
+	if len(changes) == 0 {
+		if state != nil {
+			state.caughtUp = true
+			state.checkDone()
+		}
+		return
+	}
+	c.since = changes[len(changes)-1][0]
+	if msg.NoReply() {
+		return
+	}
+	if state != nil {
+		state.pending += len(changes)
+	}
+
+	// An empty list of known revs for each change requests the revision
+	knownRevs := make([][]string, len(changes))
+	for i := range knownRevs {
+		knownRevs[i] = []string{}
+	}
+	_ = msg.Response().SetJSONBody(knownRevs)
+}
+
+// handleRev receives a revision requested by a pull.
+func (c *virtualClient) handleRev(msg *blip.Message) {
+	if _, err := msg.Body(); err != nil {
+		msg.Response().SetError(blip.BLIPErrorDomain, http.StatusBadRequest, err.Error())
+	}
+	c.docsPulled.Add(1)
+	c.revReceived()
+}
+
+// handleNoRev handles a revision that couldn't be sent by Sync Gateway.
+func (c *virtualClient) handleNoRev(msg *blip.Message) {
+	c.revReceived()
+}
+
+// revReceived marks a requested revision as handled by the in-progress pull.
+func (c *virtualClient) revReceived() {
+	c.pullLock.Lock()
+	defer c.pullLock.Unlock()
+	if c.pullState != nil && c.pullState.pending > 0 {
+		c.pullState.pending--
+		c.pullState.checkDone()
+	}
+}
+
+// checkDone signals the pull is complete once the client has caught up and received all requested revisions.
+// Requires the client's pullLock to be held.
+func (s *pullState) checkDone() {
+	if s.caughtUp && s.pending == 0 {
+		select {
+		case <-s.done:
+		default:
+			close(s.done)
+		}
+	}
+}
+
+// sendRequest sends a request and waits for the response, returning an error if the response is a BLIP error.
+func (c *virtualClient) sendRequest(msg *blip.Message) (*blip.Message, error) {
+	if c.collection != "" && msg.Profile() != db.MessageGetCollections {
+		msg.Properties[db.BlipCollection] = c.collection
+	}
+	if !c.sender.Send(msg) {
+		return nil, fmt.Errorf("unable to send %s request, connection closed", msg.Profile())
+	}
+	response := msg.Response()
+	if errCode := response.Properties[db.BlipErrorCode]; errCode != "" {
+		body, _ := response.Body()
+		return nil, fmt.Errorf("%s request failed with error %s %s: %s", msg.Profile(), response.Properties[db.BlipErrorDomain], errCode, body)
+	}
+	return response, nil
+}
+
+// close closes the client's BLIP connection.
+func (c *virtualClient) close() {
+	if c.sender != nil {
+		c.sender.Close()
+	}
+}
+
+// randomPayload returns a random string of the given length.
+func randomPayload(random *rand.Rand, length int) string {
+	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	payload := make([]byte, length)
+	for i := range payload {
+		payload[i] = letters[random.Intn(len(letters))]
+	}
+	return string(payload)
+}
@@ -0,0 +1,106 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package simulate
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Report is the result of a simulation.
+type Report struct {
+	Clients         int           `json:"clients"`
+	DurationSeconds float64       `json:"duration_secs"`
+	Push            LatencyReport `json:"push"`
+	Pull            LatencyReport `json:"pull"`
+	DocsPulled      int64         `json:"docs_pulled"` // Number of revisions received by all clients
+}
+
+// LatencyReport summarises the latency of one type of operation.  Latencies are in milliseconds, and only include
+// successful operations.
+type LatencyReport struct {
+	Count     int     `json:"count"`
+	Errors    int     `json:"errors"`
+	OpsPerSec float64 `json:"ops_per_sec"`
+	Mean      float64 `json:"mean_ms"`
+	P50       float64 `json:"p50_ms"`
+	P90       float64 `json:"p90_ms"`
+	P95       float64 `json:"p95_ms"`
+	P99       float64 `json:"p99_ms"`
+	Max       float64 `json:"max_ms"`
+}
+
+// latencyRecorder records the latency of operations from multiple clients.
+type latencyRecorder struct {
+	lock      sync.Mutex
+	latencies []time.Duration
+	errors    int
+}
+
+func newLatencyRecorder() *latencyRecorder {
+	return &latencyRecorder{}
+}
+
+// record records the latency of a successful operation, or counts an error.
+func (r *latencyRecorder) record(latency time.Duration, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if err != nil {
+		r.errors++
+		return
+	}
+	r.latencies = append(r.latencies, latency)
+}
+
+// report returns the summary of the recorded operations, which ran over elapsed.
+func (r *latencyRecorder) report(elapsed time.Duration) LatencyReport {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	report := LatencyReport{
+		Count:  len(r.latencies),
+		Errors: r.errors,
+	}
+	if len(r.latencies) == 0 {
+		return report
+	}
+	if elapsed > 0 {
+		report.OpsPerSec = float64(len(r.latencies)) / elapsed.Seconds()
+	}
+
+	sorted := make([]time.Duration, len(r.latencies))
+	copy(sorted, r.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, latency := range sorted {
+		total += latency
+	}
+	report.Mean = toMillis(total / time.Duration(len(sorted)))
+	report.P50 = toMillis(percentile(sorted, 50))
+	report.P90 = toMillis(percentile(sorted, 90))
+	report.P95 = toMillis(percentile(sorted, 95))
+	report.P99 = toMillis(percentile(sorted, 99))
+	report.Max = toMillis(sorted[len(sorted)-1])
+	return report
+}
+
+// percentile returns the nearest-rank percentile of the sorted latencies.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func toMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,74 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package simulate
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyRecorderReport(t *testing.T) {
+	recorder := newLatencyRecorder()
+	assert.Equal(t, LatencyReport{}, recorder.report(time.Second))
+
+	for i := 100; i > 0; i-- {
+		recorder.record(time.Duration(i)*time.Millisecond, nil)
+	}
+	recorder.record(time.Hour, errors.New("failed"))
+
+	report := recorder.report(10 * time.Second)
+	assert.Equal(t, 100, report.Count)
+	assert.Equal(t, 1, report.Errors)
+	assert.Equal(t, 10.0, report.OpsPerSec)
+	assert.Equal(t, 50.5, report.Mean)
+	assert.Equal(t, 50.0, report.P50)
+	assert.Equal(t, 90.0, report.P90)
+	assert.Equal(t, 95.0, report.P95)
+	assert.Equal(t, 99.0, report.P99)
+	assert.Equal(t, 100.0, report.Max)
+}
+
+func TestConfigValidate(t *testing.T) {
+	targetURL, err := url.Parse("http://localhost:4984/db")
+	require.NoError(t, err)
+	noDbURL, err := url.Parse("http://localhost:4984/")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		modify      func(*Config)
+		expectError bool
+	}{
+		{name: "default", modify: func(*Config) {}},
+		{name: "noURL", modify: func(c *Config) { c.TargetURL = nil }, expectError: true},
+		{name: "noDatabase", modify: func(c *Config) { c.TargetURL = noDbURL }, expectError: true},
+		{name: "collection", modify: func(c *Config) { c.Collection = "scope.collection" }},
+		{name: "invalidCollection", modify: func(c *Config) { c.Collection = "collection" }, expectError: true},
+		{name: "noClients", modify: func(c *Config) { c.NumClients = 0 }, expectError: true},
+		{name: "pushOnly", modify: func(c *Config) { c.PushRatio = 1 }},
+		{name: "invalidPushRatio", modify: func(c *Config) { c.PushRatio = 1.5 }, expectError: true},
+		{name: "noPullBatch", modify: func(c *Config) { c.PullBatch = 0 }, expectError: true},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig(targetURL)
+			test.modify(&config)
+			if test.expectError {
+				assert.Error(t, config.Validate())
+			} else {
+				assert.NoError(t, config.Validate())
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2023-Present Couchbase, Inc.
+//
+// Use of this software is governed by the Business Source License included
+// in the file licenses/BSL-Couchbase.txt.  As of the Change Date specified
+// in that file, in accordance with the Business Source License, use of this
+// software will be governed by the Apache License, Version 2.0, included in
+// the file licenses/APL2.txt.
+
+package rest
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+	"github.com/couchbase/sync_gateway/channels"
+	"github.com/couchbase/sync_gateway/rest/simulate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSimulate runs a short simulation against a RestTester, and ensures pushes and pulls are reported.
+func TestSimulate(t *testing.T) {
+	rt := NewRestTester(t, &RestTesterConfig{SyncFn: channels.DocChannelsSyncFunction})
+	defer rt.Close()
+
+	const username = "alice"
+	rt.CreateUser(username, []string{"a"})
+
+	srv := httptest.NewServer(rt.TestPublicHandler())
+	defer srv.Close()
+
+	targetURL, err := url.Parse(srv.URL + "/" + rt.GetDatabase().Name)
+	require.NoError(t, err)
+	targetURL.User = url.UserPassword(username, RestTesterDefaultUserPassword)
+
+	config := simulate.DefaultConfig(targetURL)
+	config.NumClients = 2
+	config.Duration = 2 * time.Second
+	config.DocSizeBytes = 100
+	config.Channels = []string{"a"}
+	if collection := rt.GetSingleTestDatabaseCollection(); !collection.IsDefaultCollection() {
+		config.Collection = collection.ScopeName + "." + collection.Name
+	}
+
+	report, err := simulate.Run(base.TestCtx(t), config)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, report.Clients)
+	assert.Greater(t, report.Push.Count, 0)
+	assert.Equal(t, 0, report.Push.Errors)
+	assert.Greater(t, report.Pull.Count, 0)
+	assert.Equal(t, 0, report.Pull.Errors)
+	assert.Greater(t, report.DocsPulled, int64(0))
+	assert.LessOrEqual(t, report.Push.P50, report.Push.P99)
+	assert.LessOrEqual(t, report.Push.P99, report.Push.Max)
+}
@@ -413,6 +413,43 @@ func TestSyncFnTimeout(t *testing.T) {
 	}()
 	timeoutErr := WaitWithTimeout(&syncFnFinishedWG, time.Second*15)
 	assert.NoError(t, timeoutErr)
+	assert.Equal(t, int64(1), rt.GetDatabase().DbStats.Database().SyncFunctionTimeoutCount.Value())
+	assert.Equal(t, int64(0), rt.GetDatabase().DbStats.Database().SyncFunctionExceptionCount.Value())
+}
+
+// TestSyncFunctionDurationStats ensures sync function executions are recorded in the duration histogram by outcome.
+func TestSyncFunctionDurationStats(t *testing.T) {
+	rtConfig := RestTesterConfig{
+		SyncFn: `function(doc) {
+			if (doc.throwException) {
+				throw("Explicit exception");
+			}
+			if (doc.reject) {
+				throw({forbidden: "rejected"});
+			}
+			channel(doc.channels);
+		}`,
+	}
+	rt := NewRestTester(t, &rtConfig)
+	defer rt.Close()
+
+	durationStat := rt.GetDatabase().DbStats.Database().SyncFunctionDuration
+	acceptedStart, _ := durationStat.Totals("accepted")
+	rejectedStart, _ := durationStat.Totals("rejected")
+	exceptionStart, _ := durationStat.Totals("exception")
+
+	RequireStatus(t, rt.SendAdminRequest(http.MethodPut, "/{{.keyspace}}/doc1", `{"channels":["A"]}`), http.StatusCreated)
+	RequireStatus(t, rt.SendAdminRequest(http.MethodPut, "/{{.keyspace}}/doc2", `{"channels":["A"]}`), http.StatusCreated)
+	RequireStatus(t, rt.SendAdminRequest(http.MethodPut, "/{{.keyspace}}/doc3", `{"reject":true}`), http.StatusForbidden)
+	RequireStatus(t, rt.SendAdminRequest(http.MethodPut, "/{{.keyspace}}/doc4", `{"throwException":true}`), http.StatusInternalServerError)
+
+	accepted, acceptedSum := durationStat.Totals("accepted")
+	assert.Equal(t, acceptedStart+2, accepted)
+	assert.Greater(t, acceptedSum, float64(0))
+	rejected, _ := durationStat.Totals("rejected")
+	assert.Equal(t, rejectedStart+1, rejected)
+	exception, _ := durationStat.Totals("exception")
+	assert.Equal(t, exceptionStart+1, exception)
 }
 
 // Take DB offline and ensure can post _resync
@@ -1696,6 +1696,56 @@ func TestPutUserCollectionAccess(t *testing.T) {
 	}
 }
 
+func TestPrincipalCollectionAccessEndpoints(t *testing.T) {
+	base.RequireNumTestDataStores(t, 2)
+
+	rt := NewRestTesterMultipleCollections(t, &RestTesterConfig{}, 2)
+	defer rt.Close()
+
+	scopeName := rt.GetDbCollections()[0].ScopeName
+	collection1Name := rt.GetDbCollections()[0].Name
+	collection2Name := rt.GetDbCollections()[1].Name
+
+	RequireStatus(t, rt.SendAdminRequest(http.MethodPut, "/db/_user/bob", `{"password":"letmein"}`), http.StatusCreated)
+	RequireStatus(t, rt.SendAdminRequest(http.MethodPut, "/db/_role/staff", `{}`), http.StatusCreated)
+
+	for _, principalPath := range []string{"/db/_user/bob", "/db/_role/staff"} {
+		collection1Path := principalPath + "/_collection_access/" + scopeName + "/" + collection1Name
+		collection2Path := principalPath + "/_collection_access/" + scopeName + "/" + collection2Name
+
+		RequireStatus(t, rt.SendAdminRequest(http.MethodPut, collection1Path, `{"admin_channels":["a","b"]}`), http.StatusOK)
+		RequireStatus(t, rt.SendAdminRequest(http.MethodPut, collection2Path, `{"admin_channels":["c"]}`), http.StatusOK)
+
+		var access auth.CollectionAccessConfig
+		response := rt.SendAdminRequest(http.MethodGet, collection1Path, "")
+		RequireStatus(t, response, http.StatusOK)
+		require.NoError(t, base.JSONUnmarshal(response.BodyBytes(), &access))
+		assert.Equal(t, base.SetOf("a", "b"), access.ExplicitChannels_)
+
+		// Removing access to one collection leaves the other unchanged
+		RequireStatus(t, rt.SendAdminRequest(http.MethodDelete, collection1Path, ""), http.StatusOK)
+		access = auth.CollectionAccessConfig{}
+		response = rt.SendAdminRequest(http.MethodGet, collection1Path, "")
+		RequireStatus(t, response, http.StatusOK)
+		require.NoError(t, base.JSONUnmarshal(response.BodyBytes(), &access))
+		assert.Empty(t, access.ExplicitChannels_)
+
+		response = rt.SendAdminRequest(http.MethodGet, collection2Path, "")
+		RequireStatus(t, response, http.StatusOK)
+		require.NoError(t, base.JSONUnmarshal(response.BodyBytes(), &access))
+		assert.Equal(t, base.SetOf("c"), access.ExplicitChannels_)
+
+		// Only configured, non-default collections can be managed, and read-only properties can't be set
+		RequireStatus(t, rt.SendAdminRequest(http.MethodPut, principalPath+"/_collection_access/"+scopeName+"/unknown", `{"admin_channels":["a"]}`), http.StatusNotFound)
+		RequireStatus(t, rt.SendAdminRequest(http.MethodPut, principalPath+"/_collection_access/_default/_default", `{"admin_channels":["a"]}`), http.StatusBadRequest)
+		RequireStatus(t, rt.SendAdminRequest(http.MethodPut, collection1Path, `{"all_channels":["a"]}`), http.StatusBadRequest)
+	}
+
+	// The principal must already exist
+	RequireStatus(t, rt.SendAdminRequest(http.MethodPut, "/db/_user/alice/_collection_access/"+scopeName+"/"+collection1Name, `{"admin_channels":["a"]}`), http.StatusNotFound)
+	RequireStatus(t, rt.SendAdminRequest(http.MethodGet, "/db/_role/managers/_collection_access/"+scopeName+"/"+collection1Name, ""), http.StatusNotFound)
+}
+
 func TestUnauthorizedAccessForDB(t *testing.T) {
 	rt := NewRestTester(t, &RestTesterConfig{
 		PersistentConfig: true,
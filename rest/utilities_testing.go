@@ -2496,6 +2496,7 @@ func (rt *RestTester) getCollectionsForBLIP() []string {
 			Collection: collection.Name,
 		}.String())
 	}
+	sort.Strings(collections)
 	return collections
 }
 